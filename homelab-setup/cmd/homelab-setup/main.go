@@ -4,8 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/cli"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/pkg/version"
 )
 
@@ -19,6 +24,19 @@ func main() {
 
 	// Define flags
 	showVersion := flag.Bool("version", false, "Print version information")
+	quiet := flag.Bool("quiet", false, "Only print errors and final results")
+	verbose := flag.Bool("verbose", false, "Print debug output, including command invocations and timings")
+	systemInfo := flag.String("system-info", "", "Print a system info report for bug reports (text or json) and exit")
+	dashboard := flag.Bool("dashboard", false, "Show a live status dashboard until q is pressed (a single snapshot when not on a terminal) and exit")
+	showConfig := flag.Bool("show-config", false, "Print every config key with its effective value and source (file or default) and exit")
+	dryRun := flag.Bool("dry-run", false, "Print privileged actions instead of running them; no steps are marked complete (see DRY_RUN)")
+	skipPreflight := flag.String("skip-preflight", "", "Comma-separated preflight checks to skip for this run (e.g. network,nfs; see PREFLIGHT_SKIP)")
+	remotePreflight := flag.String("remote-preflight", "", "Run the network, NFS and port preflight checks on [user@]host[:port] over SSH and exit")
+	backupAppdata := flag.String("backup-appdata", "", "Back up the appdata of the given services (comma-separated, or \"all\") and exit")
+	verifyWrites := flag.Bool("verify-writes", false, "Read the config file back after each change and fail if the value did not stick")
+	profile := flag.String("profile", "", "Profile whose config file and markers to use (default: $HOMELAB_PROFILE, or the default profile)")
+	nonInteractive := flag.Bool("non-interactive", false, "Accept the default answer to every prompt instead of asking (for scripts)")
+	configPath := flag.String("config", "", "Path to the config file (default: $XDG_CONFIG_HOME/homelab-setup/homelab-setup.conf or ~/.homelab-setup.conf)")
 	flag.Parse()
 
 	// Handle version flag
@@ -27,17 +45,145 @@ func main() {
 		return
 	}
 
+	if *quiet && *verbose {
+		fmt.Fprintln(os.Stderr, "Error: --quiet and --verbose cannot be used together")
+		os.Exit(cli.ExitUsage)
+	}
+
+	// "run STEP..." runs steps without the menu and exits with their status
+	var runSteps []string
+	if flag.NArg() > 0 && flag.Arg(0) == "run" {
+		runSteps = flag.Args()[1:]
+		if err := cli.ValidateStepNames(runSteps); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitUsage)
+		}
+	}
+
+	if *remotePreflight != "" {
+		if _, err := system.ParseSSHTarget(*remotePreflight); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --remote-preflight: %v\n", err)
+			os.Exit(cli.ExitUsage)
+		}
+	}
+
+	ui.ConfigureTerminal()
+
+	profileName := *profile
+	if profileName == "" {
+		profileName = os.Getenv(config.ProfileEnv)
+	}
+	if err := config.SetProfile(profileName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(cli.ExitUsage)
+	}
+
 	// Initialize setup context
-	ctx, err := cli.NewSetupContext()
+	ctx, err := cli.NewSetupContext(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to initialize setup context: %v\n", err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
+	}
+
+	if *systemInfo != "" {
+		if err := printSystemInfo(ctx, *systemInfo); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	if *showConfig {
+		if err := steps.RunEffectiveConfig(ctx.Config, ctx.UI); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	if *nonInteractive {
+		ctx.UI.SetNonInteractive(true)
+	}
+
+	if *dryRun {
+		ctx.SetDryRun(true)
+	}
+
+	if *verifyWrites {
+		ctx.SetVerifyWrites(true)
+	}
+
+	if *skipPreflight != "" {
+		ctx.PreflightSkip = strings.Split(*skipPreflight, ",")
+	}
+
+	switch {
+	case *quiet:
+		ctx.SetVerbosity(ui.VerbosityQuiet)
+	case *verbose:
+		ctx.SetVerbosity(ui.VerbosityVerbose)
+	}
+
+	if *remotePreflight != "" {
+		if err := cli.RunRemotePreflight(ctx, *remotePreflight); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	if *dashboard {
+		if err := cli.RunDashboard(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	if *backupAppdata != "" {
+		if err := steps.BackupAppdataServices(ctx.Config, ctx.UI, *backupAppdata); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	if runSteps != nil {
+		if err := cli.RunSteps(ctx, runSteps); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+
+	// Collect the essential settings before showing the menu on a first run
+	if ctx.FirstRun {
+		if err := steps.RunFirstRunWizard(ctx.Config, ctx.UI); err != nil {
+			ctx.UI.Errorf("First-run wizard failed: %v", err)
+			ctx.UI.Info("Continuing to the menu; each setup step will ask for the values it needs")
+		}
+		// The menu clears the screen, so let the wizard's output be read first
+		_ = ctx.UI.PromptContinue("Press Enter to continue to the menu...")
 	}
 
 	// Launch interactive menu
 	menu := cli.NewMenu(ctx)
 	if err := menu.Show(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
+	}
+}
+
+// printSystemInfo writes the system info report to stdout in the given format
+func printSystemInfo(ctx *cli.SetupContext, format string) error {
+	info, err := steps.CollectDiagnostics(ctx.Config)
+	if err != nil {
+		return err
+	}
+	report, err := steps.FormatSystemInfo(info, format)
+	if err != nil {
+		return err
 	}
+	fmt.Println(report)
+	return nil
 }
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+)
+
+// RunDashboard shows the live dashboard (see steps.RunDashboard). Leaving it
+// with Ctrl-C is not an error.
+func RunDashboard(ctx *SetupContext) error {
+	opCtx, done := ctx.beginOperation()
+	defer done()
+
+	err := steps.RunDashboard(opCtx, ctx.Config, ctx.UI)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
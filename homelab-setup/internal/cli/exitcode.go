@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+)
+
+// Exit codes of homelab-setup. They are a stable contract for scripts
+// wrapping the tool; keep them in sync with the README.
+const (
+	ExitOK         = 0
+	ExitFailure    = 1 // any error not covered below
+	ExitUsage      = 2 // invalid command-line usage (also used by flag parsing)
+	ExitConfig     = 3
+	ExitDeployment = 4
+	ExitAborted    = 5
+	ExitPreflight  = 6
+)
+
+// Error categories mapped to exit codes by ExitCode. Wrap them with %w,
+// alongside the underlying error, where the failure is detected.
+var (
+	// ErrConfig is returned when the config file cannot be loaded or used
+	ErrConfig = errors.New("configuration error")
+	// ErrPreflightFailed is returned when a fatal preflight check fails
+	ErrPreflightFailed = errors.New("preflight checks failed")
+	// ErrDeploymentFailed is returned when service deployment or its
+	// verification fails
+	ErrDeploymentFailed = errors.New("deployment failed")
+	// ErrAborted is returned when the user cancels an operation; steps
+	// report declined confirmations as steps.ErrCancelled
+	ErrAborted = steps.ErrCancelled
+)
+
+// categorizedError attaches an error category to err without changing its
+// message
+type categorizedError struct {
+	category error
+	err      error
+}
+
+// Error implements error, keeping the underlying message
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns both the category and the underlying error for errors.Is
+func (e *categorizedError) Unwrap() []error {
+	return []error{e.category, e.err}
+}
+
+// withCategory marks err as belonging to category for ExitCode
+func withCategory(category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categorizedError{category: category, err: err}
+}
+
+// stepErrorCategories are the categories reported when a step fails
+var stepErrorCategories = map[string]error{
+	"preflight":  ErrPreflightFailed,
+	"deployment": ErrDeploymentFailed,
+}
+
+// ExitCode returns the process exit code for err
+func ExitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, ErrExit):
+		return ExitOK
+	case errors.Is(err, ErrAborted), errors.Is(err, context.Canceled):
+		return ExitAborted
+	case errors.Is(err, ErrConfig), errors.Is(err, config.ErrConfigCorrupt), errors.Is(err, config.ErrConfigLocked):
+		return ExitConfig
+	case errors.Is(err, ErrPreflightFailed):
+		return ExitPreflight
+	case errors.Is(err, ErrDeploymentFailed):
+		return ExitDeployment
+	default:
+		return ExitFailure
+	}
+}
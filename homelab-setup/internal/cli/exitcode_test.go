@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"menu exit", ErrExit, ExitOK},
+		{"generic", errors.New("boom"), ExitFailure},
+		{"config load", withCategory(ErrConfig, errors.New("failed to load configuration")), ExitConfig},
+		{"corrupt config", fmt.Errorf("load: %w", config.ErrConfigCorrupt), ExitConfig},
+		{"preflight step", withCategory(stepErrorCategories["preflight"], errors.New("step failed")), ExitPreflight},
+		{"deployment step", withCategory(stepErrorCategories["deployment"], errors.New("step failed")), ExitDeployment},
+		{"declined confirmation", fmt.Errorf("NFS setup %w", steps.ErrCancelled), ExitAborted},
+		{"interrupted", fmt.Errorf("step user: %w", context.Canceled), ExitAborted},
+		{"cancelled deployment", withCategory(ErrDeploymentFailed, fmt.Errorf("x: %w", context.Canceled)), ExitAborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCategoryKeepsMessage(t *testing.T) {
+	err := withCategory(ErrConfig, errors.New("config file is locked"))
+	if err.Error() != "config file is locked" {
+		t.Errorf("Error() = %q, want the underlying message", err.Error())
+	}
+	if withCategory(ErrConfig, nil) != nil {
+		t.Error("withCategory(nil) should be nil")
+	}
+}
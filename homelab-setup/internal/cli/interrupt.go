@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// interruptExitCode is the conventional exit status for SIGINT
+const interruptExitCode = 130
+
+// interruptHandler turns Ctrl-C into cancellation of the running operation.
+// The first interrupt cancels the operation's context so it can stop cleanly
+// and return to the menu; a second interrupt, or one received while no
+// operation is running, exits the process.
+type interruptHandler struct {
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+	interrupted bool
+	signals     chan os.Signal
+	exit        func(code int)
+}
+
+// newInterruptHandler creates a handler and starts listening for SIGINT/SIGTERM
+func newInterruptHandler() *interruptHandler {
+	h := &interruptHandler{
+		signals: make(chan os.Signal, 2),
+		exit:    os.Exit,
+	}
+	signal.Notify(h.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range h.signals {
+			h.handleInterrupt()
+		}
+	}()
+	return h
+}
+
+// handleInterrupt cancels the active operation or exits
+func (h *interruptHandler) handleInterrupt() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cancel == nil || h.interrupted {
+		fmt.Fprintln(os.Stderr, "\nExiting.")
+		h.exit(interruptExitCode)
+		return
+	}
+
+	h.interrupted = true
+	fmt.Fprintln(os.Stderr, "\nInterrupted - stopping current operation (press Ctrl-C again to force exit)")
+	h.cancel()
+}
+
+// begin starts a cancellable operation; call the returned function when done
+func (h *interruptHandler) begin() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mu.Lock()
+	h.cancel = cancel
+	h.interrupted = false
+	h.mu.Unlock()
+
+	return ctx, func() {
+		h.mu.Lock()
+		h.cancel = nil
+		h.interrupted = false
+		h.mu.Unlock()
+		cancel()
+	}
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+)
+
+// runMaintenanceMenu shows the maintenance submenu until the user goes back
+func (m *Menu) runMaintenanceMenu() error {
+	for {
+		clearScreen()
+		m.displayMaintenanceMenu()
+
+		choice, err := m.ctx.UI.PromptInput("Enter your choice", "")
+		if err != nil {
+			return err
+		}
+
+		choice = strings.ToUpper(strings.TrimSpace(choice))
+
+		if err := m.handleMaintenanceChoice(choice); err != nil {
+			if errors.Is(err, ErrBack) {
+				return nil
+			}
+			m.ctx.UI.Error(fmt.Sprintf("%v", err))
+			m.ctx.UI.Print("")
+			m.waitEnter()
+		}
+	}
+}
+
+// displayMaintenanceMenu displays the maintenance options
+func (m *Menu) displayMaintenanceMenu() {
+	bold := color.New(color.Bold)
+
+	m.ctx.UI.Header("Maintenance")
+
+	bold.Print("  [1] ")
+	fmt.Println("Fix Appdata Permissions")
+
+	bold.Print("  [2] ")
+	fmt.Println("View Service Logs")
+
+	bold.Print("  [3] ")
+	fmt.Println("Backup Configuration File")
+
+	bold.Print("  [4] ")
+	fmt.Println("Configure Firewall Ports")
+
+	bold.Print("  [5] ")
+	fmt.Println("Start Services at Boot")
+
+	bold.Print("  [6] ")
+	fmt.Println("Service Control (start, stop, restart)")
+
+	bold.Print("  [7] ")
+	fmt.Println("Prune Unused Images and Volumes")
+
+	bold.Print("  [8] ")
+	fmt.Println("Back Up Service Appdata")
+
+	bold.Print("  [9] ")
+	fmt.Println("Restore Service Appdata")
+
+	bold.Print("  [10] ")
+	fmt.Println("Schedule Appdata Backups")
+
+	bold.Print("  [11] ")
+	fmt.Println("Compare Configured and Running Services")
+	fmt.Println()
+
+	bold.Print("  [B] ")
+	fmt.Println("Back to Main Menu")
+	fmt.Println()
+}
+
+// handleMaintenanceChoice processes a maintenance submenu choice
+func (m *Menu) handleMaintenanceChoice(choice string) error {
+	switch choice {
+	case "1":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunRepairAppdataPermissions(m.ctx.Config, m.ctx.UI)
+		})
+	case "2":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunViewServiceLogs(m.ctx.Config, m.ctx.UI)
+		})
+	case "3":
+		return m.runMaintenanceAction(m.backupConfig)
+	case "4":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunFirewallSetup(m.ctx.Config, m.ctx.UI)
+		})
+	case "5":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunServiceBootSettings(m.ctx.Config, m.ctx.UI)
+		})
+	case "6":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunServiceControl(m.ctx.Config, m.ctx.UI)
+		})
+	case "7":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunPruneContainerResources(m.ctx.Config, m.ctx.UI)
+		})
+	case "8":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunAppdataBackup(m.ctx.Config, m.ctx.UI)
+		})
+	case "9":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunAppdataRestore(m.ctx.Config, m.ctx.UI)
+		})
+	case "10":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunScheduleBackups(m.ctx.Config, m.ctx.UI)
+		})
+	case "11":
+		return m.runMaintenanceAction(func() error {
+			opCtx, done := m.ctx.beginOperation()
+			defer done()
+			return steps.RunServiceDrift(opCtx, m.ctx.Config, m.ctx.UI)
+		})
+	case "B":
+		return ErrBack
+	default:
+		return fmt.Errorf("invalid choice: %s", choice)
+	}
+}
+
+// runMaintenanceAction clears the screen, runs action and waits for Enter
+func (m *Menu) runMaintenanceAction(action func() error) error {
+	clearScreen()
+	err := action()
+	m.ctx.UI.Print("")
+	m.waitEnter()
+	return err
+}
+
+// backupConfig copies the configuration file to a timestamped backup
+func (m *Menu) backupConfig() error {
+	m.ctx.UI.Header("Backup Configuration")
+
+	backupPath, err := system.BackupFile(m.ctx.Config.FilePath())
+	if err != nil {
+		return fmt.Errorf("failed to back up configuration: %w", err)
+	}
+	if backupPath == "" {
+		m.ctx.UI.Warningf("No configuration file found at %s", m.ctx.Config.FilePath())
+		return nil
+	}
+
+	m.ctx.UI.Successf("Configuration backed up to %s", backupPath)
+	return nil
+}
@@ -5,13 +5,23 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/pkg/version"
 )
 
 // ErrExit is returned when the user chooses to exit the menu
 var ErrExit = errors.New("exit")
 
+// ErrBack is returned when the user leaves a submenu to return to the main menu
+var ErrBack = errors.New("back")
+
 // Menu provides an interactive menu interface
 type Menu struct {
 	ctx *SetupContext
@@ -25,10 +35,23 @@ func NewMenu(ctx *SetupContext) *Menu {
 // clearScreen clears the terminal screen using ANSI escape codes
 // This is more portable than calling the 'clear' command
 func clearScreen() {
+	// Escape codes would print literally on a dumb terminal or in a log
+	if !ui.CapableTerminal() {
+		fmt.Println()
+		fmt.Println(strings.Repeat("#", 70))
+		fmt.Println()
+		return
+	}
 	// ANSI escape codes: \033[2J clears screen, \033[H moves cursor to home
 	fmt.Print("\033[2J\033[H")
 }
 
+// waitEnter pauses until the user presses Enter
+func (m *Menu) waitEnter() {
+	m.ctx.UI.Info("Press Enter to return to menu...")
+	_, _ = fmt.Scanln()
+}
+
 // Show displays the main menu and handles user input
 func (m *Menu) Show() error {
 	for {
@@ -63,12 +86,19 @@ func (m *Menu) displayMenu() {
 	// Header
 	border := strings.Repeat("=", 70)
 	cyan.Println(border)
-	cyan.Println("  UBlue uCore Homelab Setup")
+	cyan.Printf("  UBlue uCore Homelab Setup (%s)\n", version.Short())
 	cyan.Println(border)
 	fmt.Println()
 
 	m.ctx.UI.Info("Welcome to the homelab setup wizard!")
 	fmt.Println()
+
+	if system.DryRun() {
+		m.ctx.UI.Warning("Dry-run mode: privileged actions are printed, not run, and no steps are marked complete")
+	}
+	for _, reason := range system.RebootReasons() {
+		m.ctx.UI.Warningf("Reboot pending: %s (sudo systemctl reboot)", reason)
+	}
 	m.ctx.UI.Info("This tool will guide you through setting up your homelab environment")
 	m.ctx.UI.Info("on UBlue uCore (immutable Fedora with rpm-ostree).")
 	fmt.Println()
@@ -84,6 +114,9 @@ func (m *Menu) displayMenu() {
 
 	bold.Print("  [Q] ")
 	fmt.Println("Quick Setup (Skip WireGuard)")
+
+	bold.Print("  [F] ")
+	fmt.Println("Repair Incomplete Setup (re-run only unfinished steps)")
 	fmt.Println()
 
 	// Individual Steps
@@ -96,9 +129,9 @@ func (m *Menu) displayMenu() {
 
 	for i, step := range steps {
 		// Check step status
-		status := "  "
+		status := ui.GlyphPending()
 		if m.ctx.Config.IsComplete(step.MarkerName) {
-			status = green.Sprint("✓")
+			status = green.Sprint(ui.GlyphOK())
 		}
 
 		bold.Printf("  [%d] ", i)
@@ -107,6 +140,16 @@ func (m *Menu) displayMenu() {
 		fmt.Println()
 	}
 
+	// Maintenance
+	cyan.Println(strings.Repeat("-", 70))
+	m.ctx.UI.Info("Maintenance:")
+	cyan.Println(strings.Repeat("-", 70))
+	fmt.Println()
+
+	bold.Print("  [M] ")
+	fmt.Println("Maintenance (permissions, logs, backups)")
+	fmt.Println()
+
 	// Other Options
 	cyan.Println(strings.Repeat("-", 70))
 	m.ctx.UI.Info("Other Options:")
@@ -119,12 +162,24 @@ func (m *Menu) displayMenu() {
 	bold.Print("  [S] ")
 	fmt.Println("Show Setup Status")
 
+	bold.Print("  [V] ")
+	fmt.Println("Verify Deployment")
+
+	bold.Print("  [O] ")
+	fmt.Println("Dashboard (live overview)")
+
+	bold.Print("  [D] ")
+	fmt.Println("Plan Changes (review, then apply)")
+
 	bold.Print("  [P] ")
 	fmt.Println("Add WireGuard Peer")
 
 	bold.Print("  [R] ")
 	fmt.Println("Reset Setup (Clear markers)")
 
+	bold.Print("  [C] ")
+	fmt.Printf("Switch Profile (current: %s)\n", config.ActiveProfile())
+
 	bold.Print("  [H] ")
 	fmt.Println("Help")
 
@@ -140,18 +195,33 @@ func (m *Menu) handleChoice(choice string) error {
 		return m.runAllSteps(false)
 	case "Q":
 		return m.runAllSteps(true)
+	case "F":
+		return m.repairSetup()
 	case "0", "1", "2", "3", "4", "5", "6":
 		return m.runIndividualStep(choice)
+	case "M":
+		return m.runMaintenanceMenu()
 	case "T":
 		return m.runTroubleshoot()
 	case "S":
 		return m.showStatus()
+	case "V":
+		return m.verifyDeployment()
+	case "O":
+		return m.showDashboard()
+	case "D":
+		return m.planAndApply()
 	case "P":
 		return m.addWireGuardPeer()
 	case "R":
 		return m.resetSetup()
+	case "C":
+		return m.switchProfile()
 	case "H":
 		return m.showHelp()
+	case "Z":
+		// Not listed in the menu; see Help
+		return m.testStep()
 	case "X":
 		return ErrExit
 	default:
@@ -171,8 +241,20 @@ func (m *Menu) runAllSteps(skipWireGuard bool) error {
 	err := RunAll(m.ctx, skipWireGuard)
 
 	fmt.Println()
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
+
+	return err
+}
+
+// repairSetup re-runs the steps that are not complete
+func (m *Menu) repairSetup() error {
+	clearScreen()
+	m.ctx.UI.Header("Repair Incomplete Setup")
+
+	err := RunRepair(m.ctx)
+
+	fmt.Println()
+	m.waitEnter()
 
 	return err
 }
@@ -194,35 +276,103 @@ func (m *Menu) runIndividualStep(choice string) error {
 	err := RunStep(m.ctx, step.ShortName)
 
 	fmt.Println()
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
 
 	return err
 }
 
-// runTroubleshoot runs the troubleshooting tool
-func (m *Menu) runTroubleshoot() error {
+func (m *Menu) addWireGuardPeer() error {
 	clearScreen()
-	m.ctx.UI.Header("Troubleshooting Tool")
+	m.ctx.UI.Header("Add WireGuard Peer")
+	err := AddWireGuardPeer(m.ctx, nil)
+	m.ctx.UI.Print("")
+	m.waitEnter()
+	return err
+}
+
+// testStep runs a chosen step in test mode (see RunStepTest)
+func (m *Menu) testStep() error {
+	clearScreen()
+	m.ctx.UI.Header("Test a Single Step")
+
+	allSteps := GetAllSteps()
+	options := make([]string, len(allSteps))
+	for i, step := range allSteps {
+		options[i] = step.Name
+	}
+	choice, err := m.ctx.UI.PromptSelect("Step to test:", options)
+	if err != nil {
+		return err
+	}
 
-	m.ctx.UI.Warning("Troubleshooting tool not yet implemented in Go version")
-	m.ctx.UI.Info("For now, please use: /usr/share/home-lab-setup-scripts/scripts/troubleshoot.sh")
+	err = RunStepTest(m.ctx, allSteps[choice].ShortName)
 
 	fmt.Println()
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
 
-	return nil
+	return err
 }
 
-func (m *Menu) addWireGuardPeer() error {
+// newProfileOption is the switchProfile choice that creates a profile
+const newProfileOption = "New profile..."
+
+// switchProfile switches to an existing profile or creates a new one
+func (m *Menu) switchProfile() error {
 	clearScreen()
-	m.ctx.UI.Header("Add WireGuard Peer")
-	err := AddWireGuardPeer(m.ctx, nil)
-	m.ctx.UI.Print("")
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
-	return err
+	m.ctx.UI.Header("Switch Profile")
+	m.ctx.UI.Infof("Current profile: %s", config.ActiveProfile())
+	fmt.Println()
+
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		return err
+	}
+	choice, err := m.ctx.UI.PromptSelect("Profile:", append(profiles, newProfileOption))
+	if err != nil {
+		return err
+	}
+
+	var profile string
+	if choice < len(profiles) {
+		profile = profiles[choice]
+	} else {
+		profile, err = m.ctx.UI.PromptInput("New profile name", "")
+		if err != nil {
+			return err
+		}
+		profile = strings.TrimSpace(profile)
+		if err := config.ValidateProfileName(profile); err != nil {
+			return err
+		}
+	}
+
+	if err := m.ctx.SwitchProfile(profile); err != nil {
+		return err
+	}
+	m.ctx.UI.Successf("Switched to profile %s", profile)
+	m.ctx.UI.Infof("Configuration file: %s", m.ctx.Config.FilePath())
+	m.ctx.UI.Infof("Marker directory: %s", m.ctx.Config.MarkerDir())
+	if m.ctx.FirstRun {
+		m.ctx.UI.Info("This profile has no configuration yet; run the setup steps to create it")
+	}
+
+	fmt.Println()
+	m.waitEnter()
+
+	return nil
+}
+
+// stepStatusLine renders one step of the status screen. took is appended
+// to the parenthesised detail as is.
+func stepStatusLine(index int, step StepInfo, complete bool, completedAt time.Time, took string) string {
+	switch {
+	case !complete:
+		return fmt.Sprintf("[%d] %s %s (not completed%s)", index, ui.GlyphPending(), step.Name, took)
+	case completedAt.IsZero():
+		return fmt.Sprintf("[%d] %s %s (completed, time unknown%s)", index, ui.GlyphOK(), step.Name, took)
+	default:
+		return fmt.Sprintf("[%d] %s %s (completed %s%s)", index, ui.GlyphOK(), step.Name, completedAt.Local().Format("2006-01-02 15:04:05"), took)
+	}
 }
 
 // showStatus shows the current setup status
@@ -238,11 +388,17 @@ func (m *Menu) showStatus() error {
 	completedCount := 0
 
 	for i, step := range steps {
+		took := ""
+		if elapsed, ok := m.ctx.StepDuration(step.ShortName); ok {
+			took = fmt.Sprintf(", took %s", formatElapsed(elapsed))
+		}
+
 		if IsStepComplete(m.ctx.Config, step.MarkerName) {
-			m.ctx.UI.Successf("[%d] ✓ %s", i, step.Name)
+			completedAt, _ := m.ctx.Config.MarkerInfo(step.MarkerName)
+			m.ctx.UI.Success(stepStatusLine(i, step, true, completedAt, took))
 			completedCount++
 		} else {
-			m.ctx.UI.Infof("[%d] - %s (not completed)", i, step.Name)
+			m.ctx.UI.Info(stepStatusLine(i, step, false, time.Time{}, took))
 		}
 	}
 
@@ -253,6 +409,15 @@ func (m *Menu) showStatus() error {
 	cyan.Println(strings.Repeat("-", 70))
 	fmt.Println()
 
+	// Stacks the drift check found running without being selected
+	if review := m.ctx.Config.GetOrDefault(config.KeyServicesReview, ""); review != "" {
+		m.ctx.UI.Warningf("Running but not in SELECTED_SERVICES (review): %s", review)
+		m.ctx.UI.Info("Compare again from Maintenance → Compare Configured and Running Services")
+		fmt.Println()
+	}
+
+	m.ctx.UI.Infof("Profile: %s", config.ActiveProfile())
+
 	// Show configuration file location
 	if _, err := os.Stat(m.ctx.Config.FilePath()); err == nil {
 		m.ctx.UI.Infof("Configuration file: %s", m.ctx.Config.FilePath())
@@ -265,12 +430,78 @@ func (m *Menu) showStatus() error {
 	}
 
 	fmt.Println()
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
 
 	return nil
 }
 
+// verifyDeployment checks that every selected service is deployed and running
+func (m *Menu) verifyDeployment() error {
+	clearScreen()
+
+	err := steps.VerifyDeployment(m.ctx.Config, m.ctx.UI)
+
+	fmt.Println()
+	m.waitEnter()
+
+	return err
+}
+
+// showDashboard shows the live dashboard until the user presses q
+func (m *Menu) showDashboard() error {
+	clearScreen()
+	if err := RunDashboard(m.ctx); err != nil {
+		return err
+	}
+	// Without a terminal the dashboard prints one snapshot and returns
+	if !m.ctx.UI.CanRedraw() {
+		fmt.Println()
+		m.waitEnter()
+	}
+	return nil
+}
+
+// planAndApply shows what the setup would change and applies it on confirmation
+func (m *Menu) planAndApply() error {
+	clearScreen()
+	m.ctx.UI.Header("Setup Plan")
+
+	err := m.runPlan()
+
+	fmt.Println()
+	m.waitEnter()
+
+	return err
+}
+
+// runPlan computes and prints the plan, then applies exactly that plan if confirmed
+func (m *Menu) runPlan() error {
+	plan, err := steps.ComputePlan(m.ctx.Config)
+	if err != nil {
+		return fmt.Errorf("failed to compute plan: %w", err)
+	}
+
+	plan.Print(m.ctx.UI)
+	if plan.Empty() {
+		return nil
+	}
+
+	fmt.Println()
+	apply, err := m.ctx.UI.PromptYesNo("Apply this plan?", false)
+	if err != nil {
+		return err
+	}
+	if !apply {
+		m.ctx.UI.Info("Plan not applied, no changes made")
+		return nil
+	}
+
+	opCtx, done := m.ctx.beginOperation()
+	defer done()
+
+	return steps.Apply(opCtx, m.ctx.Config, m.ctx.UI, plan)
+}
+
 // resetSetup resets all completion markers
 func (m *Menu) resetSetup() error {
 	clearScreen()
@@ -288,8 +519,7 @@ func (m *Menu) resetSetup() error {
 	if !confirm {
 		m.ctx.UI.Info("Reset cancelled")
 		fmt.Println()
-		m.ctx.UI.Info("Press Enter to return to menu...")
-		_, _ = fmt.Scanln()
+		m.waitEnter()
 		return nil
 	}
 
@@ -301,8 +531,7 @@ func (m *Menu) resetSetup() error {
 	m.ctx.UI.Info("You can now run the setup steps again")
 
 	fmt.Println()
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
 
 	return nil
 }
@@ -363,12 +592,58 @@ RECOMMENDED APPROACH:
   "Quick Setup" (skips WireGuard).
 
   If a step fails, you can re-run just that step using the individual
-  step options (0-6).
+  step options (0-6), or use [F] "Repair Incomplete Setup" to re-run
+  every unfinished step in order. Repair skips completed steps and any
+  step whose prerequisites are still incomplete.
+
+MAINTENANCE:
+
+  Option [M] opens the maintenance menu for day-2 tasks such as fixing
+  appdata permissions, viewing service logs, starting, stopping and
+  restarting services, choosing which services start at boot, pruning
+  unused images, backing up and restoring a service's appdata (now or
+  on a systemd timer) and backing up the configuration file. Redeploying a stack also offers an
+  appdata backup first, since new images may migrate its databases.
+  "Compare Configured and Running Services" lists stacks selected in
+  SELECTED_SERVICES that are not running, and stacks running without
+  being selected (e.g. after a manual "docker compose up"), and offers
+  to deploy the former and mark the latter for review.
+
+  Option [D] computes a plan of what the setup would change (users,
+  directories, packages, config keys, services) without touching the
+  system, prints it for review, and applies exactly that plan if you
+  confirm.
+
+  Option [Z] (not listed in the menu) is for developing and debugging
+  a step: it runs the chosen step in test mode with verbose output,
+  even if already complete, without writing or clearing its completion
+  marker, and then shows the configuration changes the step made.
+  Combine it with --dry-run to also skip privileged actions.
+
+  Option [V] verifies the deployment: for each selected service it
+  checks the service directory, compose file, systemd unit, start-at-boot
+  setting, web UI health and appdata directories, without relying on
+  completion markers.
+
+  Option [O] opens a read-only dashboard with the pre-flight and
+  deployment status, each selected service's unit and web UI health,
+  memory and disk space, and the NFS mount and WireGuard peers. It
+  refreshes every DASHBOARD_REFRESH seconds (default 5); press q to
+  return to the menu.
 
 CONFIGURATION FILES:
 
-	Configuration: ~/.homelab-setup.conf
-	Markers: ~/.local/homelab-setup/
+	Configuration: ~/.homelab-setup.conf, or
+	  $XDG_CONFIG_HOME/homelab-setup/homelab-setup.conf when set
+	  (override with --config <path>)
+	Markers: ~/.local/homelab-setup/, or $XDG_STATE_HOME/homelab-setup/
+	  (then $XDG_DATA_HOME/homelab-setup/) when set
+	Audit log: audit.log in the markers directory, listing every
+	  sudo command run with its time and exit status
+	Profiles: --profile NAME (or HOMELAB_PROFILE=NAME) keeps the
+	  configuration and markers in <markers directory>/profiles/NAME/;
+	  the default profile uses the locations above. Option [C]
+	  switches profile from the menu.
 
 AUTOMATION NOTES:
 
@@ -382,8 +657,7 @@ For more information, see the project README.
 `
 
 	fmt.Println(help)
-	m.ctx.UI.Info("Press Enter to return to menu...")
-	_, _ = fmt.Scanln()
+	m.waitEnter()
 
 	return nil
 }
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestStepStatusLine(t *testing.T) {
+	t.Cleanup(func() { ui.SetASCIIGlyphs(false) })
+
+	step := StepInfo{Name: "NFS Setup"}
+	completedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	// "✓" and "✗" misread as Latin-1 and re-encoded
+	mojibake := []string{"âœ“", "âœ—"}
+
+	tests := []struct {
+		ascii    bool
+		complete bool
+		want     string
+	}{
+		{false, true, "[3] ✓ NFS Setup (completed 2026-01-02 03:04:05, took 1.5s)"},
+		{false, false, "[3] - NFS Setup (not completed, took 1.5s)"},
+		{true, true, "[3] [x] NFS Setup (completed 2026-01-02 03:04:05, took 1.5s)"},
+		{true, false, "[3] [ ] NFS Setup (not completed, took 1.5s)"},
+	}
+	for _, tt := range tests {
+		ui.SetASCIIGlyphs(tt.ascii)
+		got := stepStatusLine(3, step, tt.complete, completedAt, ", took 1.5s")
+		if got != tt.want {
+			t.Errorf("stepStatusLine(ascii=%v, complete=%v) = %q, want %q", tt.ascii, tt.complete, got, tt.want)
+		}
+		for _, bad := range mojibake {
+			if strings.Contains(got, bad) {
+				t.Errorf("stepStatusLine() = %q contains mojibake %q", got, bad)
+			}
+		}
+	}
+
+	ui.SetASCIIGlyphs(false)
+	if got := stepStatusLine(0, step, true, time.Time{}, ""); got != "[0] ✓ NFS Setup (completed, time unknown)" {
+		t.Errorf("stepStatusLine() without completion time = %q", got)
+	}
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+)
+
+// RunRemotePreflight runs the network-facing preflight checks on target over
+// SSH (see steps.RunRemotePreflight), honouring ctx.PreflightSkip. Failures
+// are reported as ErrPreflightFailed.
+func RunRemotePreflight(ctx *SetupContext, target string) error {
+	opCtx, done := ctx.beginOperation()
+	defer done()
+
+	err := steps.RunRemotePreflight(opCtx, ctx.Config, ctx.UI, target, ctx.PreflightSkip...)
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return withCategory(ErrPreflightFailed, err)
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+// optionalStepSelected reports whether an optional step was chosen, so that
+// repair does not run steps the user skipped on purpose (e.g. Quick Setup)
+func optionalStepSelected(cfg *config.Config, step StepInfo) bool {
+	switch step.ShortName {
+	case "wireguard":
+		return cfg.GetOrDefault("WIREGUARD_ENABLED", "") == "true"
+	default:
+		return false
+	}
+}
+
+// RunRepair re-runs the steps that are not complete, in order, and reports
+// what it did. Completed steps and unselected optional steps are left alone.
+// A step whose prerequisites are still incomplete (for example deployment
+// after a failed directory setup) is skipped rather than run; steps that do
+// not depend on a failed step still run.
+func RunRepair(ctx *SetupContext) error {
+	allSteps := GetAllSteps()
+	byName := make(map[string]StepInfo, len(allSteps))
+	for _, step := range allSteps {
+		byName[step.ShortName] = step
+	}
+
+	ctx.stepStatuses = nil
+	start := time.Now()
+	// A step that succeeded in this run counts as done even when no marker
+	// was written (dry-run)
+	done := func(step StepInfo) bool {
+		return IsStepComplete(ctx.Config, step.MarkerName) || ctx.stepStatuses[step.ShortName] == stepDone
+	}
+
+	var rows [][]string
+	var failed []string
+	ran := 0
+	for _, step := range allSteps {
+		if IsStepComplete(ctx.Config, step.MarkerName) {
+			rows = append(rows, []string{step.Name, "already complete"})
+			continue
+		}
+		if step.Optional && !optionalStepSelected(ctx.Config, step) {
+			rows = append(rows, []string{step.Name, "skipped (not selected)"})
+			continue
+		}
+
+		var blockedBy []string
+		for _, required := range step.Requires {
+			if !done(byName[required]) {
+				blockedBy = append(blockedBy, byName[required].Name)
+			}
+		}
+		if len(blockedBy) > 0 {
+			ctx.UI.Warningf("Skipping %s: %s not complete", step.Name, strings.Join(blockedBy, ", "))
+			rows = append(rows, []string{step.Name, "blocked (needs " + strings.Join(blockedBy, ", ") + ")"})
+			continue
+		}
+
+		ran++
+		if err := RunStep(ctx, step.ShortName); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			ctx.UI.Errorf("%s failed: %v", step.Name, err)
+			failed = append(failed, step.ShortName)
+			rows = append(rows, []string{step.Name, "failed"})
+			continue
+		}
+		rows = append(rows, []string{step.Name, "repaired"})
+	}
+
+	ctx.UI.Header("Repair Summary")
+	ctx.UI.Table([]string{"Step", "Result"}, rows)
+	ctx.UI.Printf("Total: %s", formatElapsed(time.Since(start)))
+
+	if ran == 0 {
+		ctx.UI.Result("Nothing to repair: every selected step is complete")
+		return nil
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("repair incomplete, failed steps: %s", strings.Join(failed, ", "))
+	}
+	ctx.UI.Result(fmt.Sprintf("Repaired %d step(s)", ran))
+	return promptRebootIfRequired(ctx)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runAllSteps selects every step for RunSteps
+const runAllSteps = "all"
+
+// ValidateStepNames checks the step names given to "run": one or more short
+// names from GetAllSteps, or "all" on its own
+func ValidateStepNames(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("run needs a step name or %q", runAllSteps)
+	}
+	if len(names) == 1 && names[0] == runAllSteps {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	var shortNames []string
+	for _, step := range GetAllSteps() {
+		known[step.ShortName] = true
+		shortNames = append(shortNames, step.ShortName)
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown step %q (want %s, or %s)", name, strings.Join(shortNames, ", "), runAllSteps)
+		}
+	}
+	return nil
+}
+
+// RunSteps runs the named steps in order without the menu, or every step with
+// "all", stopping at the first failure. Unlike the menu it returns that
+// failure, so its exit code (see ExitCode) reaches the caller.
+func RunSteps(ctx *SetupContext, names []string) error {
+	if len(names) == 1 && names[0] == runAllSteps {
+		return RunAll(ctx, ctx.SkipWireGuard)
+	}
+	for _, name := range names {
+		if err := RunStep(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package cli
+
+import "testing"
+
+func TestValidateStepNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []string
+		wantErr bool
+	}{
+		{"single step", []string{"preflight"}, false},
+		{"several steps", []string{"nfs", "container", "deployment"}, false},
+		{"all", []string{"all"}, false},
+		{"none", nil, true},
+		{"unknown", []string{"preflight", "backup"}, true},
+		{"all with others", []string{"all", "nfs"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateStepNames(tt.steps); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStepNames(%v) error = %v, wantErr %v", tt.steps, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,10 +4,16 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
 )
 
@@ -17,30 +23,218 @@ type SetupContext struct {
 	UI     *ui.UI
 	// SkipWireGuard indicates whether WireGuard should be skipped when running all steps
 	SkipWireGuard bool
+	// PreflightSkip names preflight checks to skip in addition to PREFLIGHT_SKIP
+	PreflightSkip []string
+	// FirstRun is true when no config file existed at startup
+	FirstRun bool
+	// interrupts cancels the running step on Ctrl-C (nil when signals are not handled)
+	interrupts *interruptHandler
+	// stepDurations records how long each step took in this session, by short name
+	stepDurations map[string]time.Duration
+	// stepStatuses records how each step ended in this session, by short name
+	stepStatuses map[string]stepStatus
+	// testMode runs completed steps again without asking and without
+	// clearing their markers (see RunStepTest)
+	testMode bool
+	// verifyWrites is applied to every config the context uses (see
+	// SetVerifyWrites)
+	verifyWrites bool
 }
 
-// NewSetupContext creates a new SetupContext with all dependencies initialized
-func NewSetupContext() (*SetupContext, error) {
-	return NewSetupContextWithOptions(false, false)
+// stepStatus is how a step ended when it last ran in this session
+type stepStatus string
+
+const (
+	stepDone    stepStatus = "done"
+	stepSkipped stepStatus = "skipped"
+	stepFailed  stepStatus = "failed"
+)
+
+// errStepAlreadyComplete is returned by a step runner when the step was
+// already completed and the user chose not to run it again
+var errStepAlreadyComplete = errors.New("step already completed")
+
+// recordStepStatus stores how a step ended in this session
+func (ctx *SetupContext) recordStepStatus(shortName string, status stepStatus) {
+	if ctx.stepStatuses == nil {
+		ctx.stepStatuses = make(map[string]stepStatus)
+	}
+	ctx.stepStatuses[shortName] = status
+}
+
+// recordStepDuration stores the duration of a step run in this session
+func (ctx *SetupContext) recordStepDuration(shortName string, elapsed time.Duration) {
+	if ctx.stepDurations == nil {
+		ctx.stepDurations = make(map[string]time.Duration)
+	}
+	ctx.stepDurations[shortName] = elapsed
+}
+
+// StepDuration returns how long a step took when it last ran in this session
+func (ctx *SetupContext) StepDuration(shortName string) (time.Duration, bool) {
+	elapsed, ok := ctx.stepDurations[shortName]
+	return elapsed, ok
+}
+
+// formatElapsed renders a duration as seconds with one decimal, e.g. "12.3s"
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// beginOperation returns a context cancelled by Ctrl-C for the duration of an
+// operation, and a function that ends the operation
+func (ctx *SetupContext) beginOperation() (context.Context, func()) {
+	if ctx.interrupts == nil {
+		return context.Background(), func() {}
+	}
+	return ctx.interrupts.begin()
+}
+
+// SetVerbosity sets the UI output level. At VerbosityVerbose external command
+// invocations and their durations are printed as debug output, and the output
+// of long-running commands such as compose pulls is streamed as they run.
+func (ctx *SetupContext) SetVerbosity(v ui.Verbosity) {
+	ctx.UI.SetVerbosity(v)
+
+	if v < ui.VerbosityVerbose {
+		system.SetCommandTracer(nil)
+		system.SetCommandOutput(nil)
+		return
+	}
+	system.SetCommandOutput(os.Stderr)
+	system.SetCommandTracer(func(commandLine string, elapsed time.Duration, err error) {
+		if err != nil {
+			ctx.UI.Debugf("ran %s (%s): %v", commandLine, elapsed.Round(time.Millisecond), err)
+			return
+		}
+		ctx.UI.Debugf("ran %s (%s)", commandLine, elapsed.Round(time.Millisecond))
+	})
+}
+
+// SetDryRun enables or disables dry-run mode: privileged system operations
+// are printed instead of run, and completion markers are not written
+func (ctx *SetupContext) SetDryRun(enabled bool) {
+	ctx.Config.SetDryRun(enabled)
+	if !enabled {
+		system.SetDryRun(false, nil)
+		return
+	}
+	system.SetDryRun(true, func(action string) {
+		ctx.UI.Infof("[dry-run] would %s", action)
+	})
+}
+
+// SetVerifyWrites makes config writes read the file back to confirm them
+// (see config.Config.SetVerifyWrites)
+func (ctx *SetupContext) SetVerifyWrites(enabled bool) {
+	ctx.verifyWrites = enabled
+	ctx.Config.SetVerifyWrites(enabled)
+}
+
+// NewSetupContext creates a new SetupContext with all dependencies initialized.
+// An empty configPath selects config.DefaultConfigPath.
+func NewSetupContext(configPath string) (*SetupContext, error) {
+	return NewSetupContextWithOptions(configPath, false, false)
 }
 
 // NewSetupContextWithOptions creates a new SetupContext with custom options
-func NewSetupContextWithOptions(nonInteractive bool, skipWireGuard bool) (*SetupContext, error) {
+func NewSetupContextWithOptions(configPath string, nonInteractive bool, skipWireGuard bool) (*SetupContext, error) {
 	// Initialize configuration
-	cfg := config.New("")
+	cfg := config.New(configPath)
+	_, statErr := os.Stat(cfg.FilePath())
+	firstRun := os.IsNotExist(statErr)
 	if err := cfg.Load(); err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, withCategory(ErrConfig, fmt.Errorf("failed to load config: %w", err))
 	}
 
 	// Initialize UI
 	uiInstance := ui.New()
 	uiInstance.SetNonInteractive(nonInteractive)
 
-	return &SetupContext{
-		Config:        cfg,
+	ctx := &SetupContext{
 		UI:            uiInstance,
 		SkipWireGuard: skipWireGuard,
-	}, nil
+		FirstRun:      firstRun,
+		interrupts:    newInterruptHandler(),
+	}
+	ctx.useConfig(cfg)
+	return ctx, nil
+}
+
+// useConfig makes cfg the context's config and applies its settings: command
+// timeouts, secrets to mask, the audit log location and marker migrations
+func (ctx *SetupContext) useConfig(cfg *config.Config) {
+	ctx.Config = cfg
+	cfg.SetVerifyWrites(ctx.verifyWrites)
+
+	// Apply command timeouts from config
+	system.SetCommandTimeouts(
+		configSeconds(cfg, config.KeyCommandTimeout),
+		configSeconds(cfg, config.KeyServiceStartTimeout),
+	)
+
+	ctx.UI.AddSecrets(cfg.SecretValues())
+
+	// Record every sudo command, with secrets masked, next to the markers
+	system.SetAuditLog(cfg.AuditLogPath(), ctx.UI.Redact)
+
+	// Import legacy marker files when completion state is kept in the config file
+	if cfg.MarkersInConfig() {
+		migrated, err := cfg.MigrateMarkersToConfig()
+		if err != nil {
+			ctx.UI.Warning(fmt.Sprintf("Failed to migrate marker files into config: %v", err))
+		} else if migrated > 0 {
+			ctx.UI.Infof("Migrated %d completion marker(s) into %s", migrated, cfg.FilePath())
+		}
+	}
+
+	// Rename markers recorded under older step names so completed steps are not re-run
+	if migrated, err := cfg.CanonicalizeLegacyMarkers(); err != nil {
+		ctx.UI.Warning(fmt.Sprintf("Failed to migrate legacy completion markers: %v", err))
+	} else if migrated > 0 {
+		ctx.UI.Infof("Migrated %d legacy completion marker(s)", migrated)
+	}
+
+	if cfg.GetOrDefault(config.KeyDryRun, "") == "true" {
+		ctx.SetDryRun(true)
+	}
+}
+
+// SwitchProfile makes profile the active profile and loads its config file
+// and markers. Dry-run mode stays enabled if it was. On error the current
+// profile is kept.
+func (ctx *SetupContext) SwitchProfile(profile string) error {
+	previous := config.ActiveProfile()
+	if err := config.SetProfile(profile); err != nil {
+		return err
+	}
+
+	cfg := config.New("")
+	_, statErr := os.Stat(cfg.FilePath())
+	if err := cfg.Load(); err != nil {
+		_ = config.SetProfile(previous)
+		return withCategory(ErrConfig, fmt.Errorf("failed to load config of profile %s: %w", profile, err))
+	}
+
+	dryRun := system.DryRun()
+	ctx.FirstRun = os.IsNotExist(statErr)
+	ctx.stepStatuses = nil
+	ctx.stepDurations = nil
+	ctx.useConfig(cfg)
+	if dryRun {
+		ctx.SetDryRun(true)
+	}
+	return nil
+}
+
+// configSeconds reads a duration in whole seconds from config, returning 0 if
+// the value is missing or invalid
+func configSeconds(cfg *config.Config, key string) time.Duration {
+	seconds, err := strconv.Atoi(cfg.GetOrDefault(key, ""))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 // StepInfo contains metadata about a setup step
@@ -50,18 +244,20 @@ type StepInfo struct {
 	Description string
 	MarkerName  string
 	Optional    bool
+	// Requires lists the short names of steps that must be complete first
+	Requires []string
 }
 
 // GetAllSteps returns information about all steps in order
 func GetAllSteps() []StepInfo {
 	return []StepInfo{
 		{Name: "Pre-flight Check", ShortName: "preflight", Description: "Verify system requirements", MarkerName: "preflight-complete", Optional: false},
-		{Name: "User Setup", ShortName: "user", Description: "Configure user account and permissions", MarkerName: "user-setup-complete", Optional: false},
-		{Name: "Directory Setup", ShortName: "directory", Description: "Create directory structure", MarkerName: "directory-setup-complete", Optional: false},
-		{Name: "WireGuard Setup", ShortName: "wireguard", Description: "Configure VPN (optional)", MarkerName: "wireguard-setup-complete", Optional: true},
-		{Name: "NFS Setup", ShortName: "nfs", Description: "Configure network storage", MarkerName: "nfs-setup-complete", Optional: false},
-		{Name: "Container Setup", ShortName: "container", Description: "Configure container services", MarkerName: "container-setup-complete", Optional: false},
-		{Name: "Service Deployment", ShortName: "deployment", Description: "Deploy and start services", MarkerName: "service-deployment-complete", Optional: false},
+		{Name: "User Setup", ShortName: "user", Description: "Configure user account and permissions", MarkerName: "user-setup-complete", Optional: false, Requires: []string{"preflight"}},
+		{Name: "Directory Setup", ShortName: "directory", Description: "Create directory structure", MarkerName: "directory-setup-complete", Optional: false, Requires: []string{"user"}},
+		{Name: "WireGuard Setup", ShortName: "wireguard", Description: "Configure VPN (optional)", MarkerName: "wireguard-setup-complete", Optional: true, Requires: []string{"preflight"}},
+		{Name: "NFS Setup", ShortName: "nfs", Description: "Configure network storage", MarkerName: "nfs-setup-complete", Optional: false, Requires: []string{"preflight"}},
+		{Name: "Container Setup", ShortName: "container", Description: "Configure container services", MarkerName: "container-setup-complete", Optional: false, Requires: []string{"directory"}},
+		{Name: "Service Deployment", ShortName: "deployment", Description: "Deploy and start services", MarkerName: "service-deployment-complete", Optional: false, Requires: []string{"directory", "container"}},
 	}
 }
 
@@ -79,36 +275,80 @@ func removeMarkerIfRerun(ui *ui.UI, cfg *config.Config, markerName string, rerun
 	}
 }
 
+// refreshSudo re-primes the sudo credential cache before a privileged step,
+// so credentials cached during preflight do not expire halfway through a
+// long run
+func (ctx *SetupContext) refreshSudo() error {
+	checker := system.NewSudoChecker()
+	checker.SetNonInteractive(ctx.UI.IsNonInteractive())
+	err := checker.Refresh()
+	if errors.Is(err, system.ErrSudoCredentialsExpired) && ctx.UI.IsNonInteractive() {
+		ctx.UI.Info(checker.SetupPasswordlessSudo())
+	}
+	return err
+}
+
 // RunStep executes a specific step by short name
 func RunStep(ctx *SetupContext, shortName string) error {
 	ctx.UI.Header(fmt.Sprintf("Running: %s", shortName))
 
+	// Preflight validates sudo itself; every later step runs privileged commands
+	if shortName != "preflight" {
+		if err := ctx.refreshSudo(); err != nil {
+			ctx.recordStepStatus(shortName, stepFailed)
+			return fmt.Errorf("step '%s' needs sudo: %w", shortName, err)
+		}
+	}
+
+	opCtx, done := ctx.beginOperation()
+	defer done()
+
+	start := time.Now()
+
 	var err error
 
 	switch shortName {
 	case "preflight":
-		err = runPreflight(ctx)
+		err = runPreflight(opCtx, ctx)
 	case "user":
-		err = runUser(ctx)
+		err = runUser(opCtx, ctx)
 	case "directory":
-		err = runDirectory(ctx)
+		err = runDirectory(opCtx, ctx)
 	case "wireguard":
-		err = runWireGuard(ctx)
+		err = runWireGuard(opCtx, ctx)
 	case "nfs":
-		err = runNFS(ctx)
+		err = runNFS(opCtx, ctx)
 	case "container":
-		err = runContainer(ctx)
+		err = runContainer(opCtx, ctx)
 	case "deployment":
-		err = runDeployment(ctx)
+		err = runDeployment(opCtx, ctx)
 	default:
 		return fmt.Errorf("unknown step: %s", shortName)
 	}
 
+	elapsed := time.Since(start)
+	ctx.recordStepDuration(shortName, elapsed)
+
+	if errors.Is(err, errStepAlreadyComplete) {
+		ctx.recordStepStatus(shortName, stepSkipped)
+		ctx.UI.Infof("Step '%s' skipped (already completed)", shortName)
+		return nil
+	}
 	if err != nil {
+		ctx.recordStepStatus(shortName, stepFailed)
+		ctx.UI.Infof("elapsed: %s", formatElapsed(elapsed))
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("step '%s' interrupted: %w", shortName, err)
+		}
+		if category, ok := stepErrorCategories[shortName]; ok {
+			return withCategory(category, err)
+		}
 		return err
 	}
 
+	ctx.recordStepStatus(shortName, stepDone)
 	ctx.UI.Success(fmt.Sprintf("Step '%s' completed successfully!", shortName))
+	ctx.UI.Infof("elapsed: %s", formatElapsed(elapsed))
 	return nil
 }
 
@@ -118,126 +358,180 @@ func AddWireGuardPeer(ctx *SetupContext, opts *steps.WireGuardPeerWorkflowOption
 }
 
 // Individual step runners
-func runPreflight(ctx *SetupContext) error {
+func runPreflight(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "preflight-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "preflight-complete") {
 		ctx.UI.Info("Pre-flight check already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "preflight-complete", rerun)
 	}
 
-	return steps.RunPreflightChecks(ctx.Config, ctx.UI)
+	return steps.RunPreflightChecks(opCtx, ctx.Config, ctx.UI, ctx.PreflightSkip...)
 }
 
-func runUser(ctx *SetupContext) error {
+func runUser(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "user-setup-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "user-setup-complete") {
 		ctx.UI.Info("User setup already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "user-setup-complete", rerun)
 	}
 
-	return steps.RunUserSetup(ctx.Config, ctx.UI)
+	return steps.RunUserSetup(opCtx, ctx.Config, ctx.UI)
 }
 
-func runDirectory(ctx *SetupContext) error {
+func runDirectory(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "directory-setup-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "directory-setup-complete") {
 		ctx.UI.Info("Directory setup already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "directory-setup-complete", rerun)
 	}
 
-	return steps.RunDirectorySetup(ctx.Config, ctx.UI)
+	return steps.RunDirectorySetup(opCtx, ctx.Config, ctx.UI)
 }
 
-func runWireGuard(ctx *SetupContext) error {
+func runWireGuard(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "wireguard-setup-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "wireguard-setup-complete") {
 		ctx.UI.Info("WireGuard setup already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "wireguard-setup-complete", rerun)
 	}
 
 	// Use RunWireGuardSetup function
 	// This handles all the logic including prompting, key generation, config writing, etc.
-	return steps.RunWireGuardSetup(ctx.Config, ctx.UI)
+	return steps.RunWireGuardSetup(opCtx, ctx.Config, ctx.UI)
 }
 
-func runNFS(ctx *SetupContext) error {
+func runNFS(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "nfs-setup-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "nfs-setup-complete") {
 		ctx.UI.Info("NFS setup already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "nfs-setup-complete", rerun)
 	}
 
 	// Use RunNFSSetup function
-	return steps.RunNFSSetup(ctx.Config, ctx.UI)
+	return steps.RunNFSSetup(opCtx, ctx.Config, ctx.UI)
 }
 
-func runContainer(ctx *SetupContext) error {
+func runContainer(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "container-setup-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "container-setup-complete") {
 		ctx.UI.Info("Container setup already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "container-setup-complete", rerun)
 	}
 
 	// Use RunContainerSetup function
-	return steps.RunContainerSetup(ctx.Config, ctx.UI)
+	return steps.RunContainerSetup(opCtx, ctx.Config, ctx.UI)
 }
 
-func runDeployment(ctx *SetupContext) error {
+func runDeployment(opCtx context.Context, ctx *SetupContext) error {
 	// Check if already completed
-	if IsStepComplete(ctx.Config, "service-deployment-complete") {
+	if !ctx.testMode && IsStepComplete(ctx.Config, "service-deployment-complete") {
 		ctx.UI.Info("Service deployment already completed")
 		rerun, err := ctx.UI.PromptYesNo("Run again?", false)
 		if err != nil || !rerun {
-			return nil
+			return errStepAlreadyComplete
 		}
 		removeMarkerIfRerun(ctx.UI, ctx.Config, "service-deployment-complete", rerun)
 	}
 
 	// Use RunDeployment function
-	return steps.RunDeployment(ctx.Config, ctx.UI)
+	return steps.RunDeployment(opCtx, ctx.Config, ctx.UI)
 }
 
-// RunAll runs all setup steps in order
-
+// RunAll runs all setup steps in order and finishes with a summary of the
+// run, also printed when a step fails
 func RunAll(ctx *SetupContext, skipWireGuard bool) error {
-	steps := []string{"preflight", "user", "directory"}
+	stepNames := []string{"preflight", "user", "directory"}
 
 	if !skipWireGuard {
-		steps = append(steps, "wireguard")
+		stepNames = append(stepNames, "wireguard")
 	}
 
-	steps = append(steps, "nfs", "container", "deployment")
+	stepNames = append(stepNames, "nfs", "container", "deployment")
 
-	for _, step := range steps {
+	// The summary reports only what happens in this run
+	ctx.stepStatuses = nil
+	start := time.Now()
+	warningsBefore := len(ctx.UI.Warnings())
+	summary := func(verifyErr error) {
+		printRunSummary(ctx, stepNames, time.Since(start), ctx.UI.Warnings()[warningsBefore:], verifyErr)
+	}
+
+	for _, step := range stepNames {
 		if err := RunStep(ctx, step); err != nil {
-			return fmt.Errorf("step %s failed: %w", step, err)
+			summary(nil)
+			return fmt.Errorf("step %s failed after %s total: %w", step, formatElapsed(time.Since(start)), err)
 		}
 	}
 
-	ctx.UI.Success("All steps completed successfully!")
+	ctx.UI.Result(fmt.Sprintf("All steps completed successfully! (total: %s)", formatElapsed(time.Since(start))))
+
+	verifyErr := steps.VerifyDeployment(ctx.Config, ctx.UI)
+	summary(verifyErr)
+	if verifyErr != nil {
+		return withCategory(ErrDeploymentFailed, verifyErr)
+	}
+
+	if err := promptRebootIfRequired(ctx); err != nil {
+		return err
+	}
 	return nil
 }
+
+// promptRebootIfRequired tells the user why a reboot is pending and offers to
+// reboot now. The reboot itself needs a second, explicit confirmation.
+func promptRebootIfRequired(ctx *SetupContext) error {
+	reasons := system.RebootReasons()
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	ctx.UI.Print("")
+	ctx.UI.Warning("A reboot is required:")
+	for _, reason := range reasons {
+		ctx.UI.Infof("  - %s", reason)
+	}
+
+	rebootNow, err := ctx.UI.PromptYesNo("Reboot now?", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for reboot: %w", err)
+	}
+	if !rebootNow {
+		ctx.UI.Info("Reboot later with: sudo systemctl reboot")
+		return nil
+	}
+
+	confirm, err := ctx.UI.PromptYesNo("The system will reboot immediately. Continue?", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for reboot: %w", err)
+	}
+	if !confirm {
+		ctx.UI.Info("Reboot cancelled")
+		return nil
+	}
+
+	return system.Reboot()
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// RunStepTest runs one step in isolation for development and debugging: it
+// runs even when already complete, with verbose output, does not write or
+// clear its completion marker, and shows the configuration changes it made.
+// Privileged actions still run unless dry-run mode is enabled.
+func RunStepTest(ctx *SetupContext, shortName string) error {
+	verbosity := ctx.UI.Verbosity()
+	if verbosity < ui.VerbosityVerbose {
+		ctx.SetVerbosity(ui.VerbosityVerbose)
+	}
+	ctx.Config.SetDryRun(true)
+	ctx.testMode = true
+	defer func() {
+		ctx.testMode = false
+		ctx.Config.SetDryRun(system.DryRun())
+		ctx.SetVerbosity(verbosity)
+	}()
+
+	ctx.UI.Warning("Test mode: the step runs even if complete and its completion marker is left unchanged")
+	before := ctx.Config.GetAll()
+	err := RunStep(ctx, shortName)
+
+	ctx.UI.Header("Configuration Changes")
+	changes := ctx.Config.DiffFrom(before)
+	if len(changes) == 0 {
+		ctx.UI.Info("No configuration changes")
+	}
+	for _, change := range changes {
+		ctx.UI.Print(change.String())
+	}
+	if err != nil {
+		return fmt.Errorf("step test failed: %w", err)
+	}
+	return nil
+}
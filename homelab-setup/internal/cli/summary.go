@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+)
+
+// printRunSummary prints the end-of-run report for RunAll: the result and
+// time of every step, the main configuration values, the warnings issued
+// during the run and what to do next. verifyErr is the outcome of the
+// post-run deployment verification, if it ran.
+func printRunSummary(ctx *SetupContext, stepNames []string, total time.Duration, warnings []string, verifyErr error) {
+	cfg := ctx.Config
+	u := ctx.UI
+
+	u.Header("Setup Summary")
+
+	planned := make(map[string]bool, len(stepNames))
+	for _, name := range stepNames {
+		planned[name] = true
+	}
+	failed := false
+	rows := make([][]string, 0, len(stepNames))
+	for _, step := range GetAllSteps() {
+		result, took := "not run", "-"
+		if !planned[step.ShortName] {
+			result = "skipped (not selected)"
+		} else if status, ok := ctx.stepStatuses[step.ShortName]; ok {
+			result = string(status)
+			if elapsed, ok := ctx.StepDuration(step.ShortName); ok && status != stepSkipped {
+				took = formatElapsed(elapsed)
+			}
+			failed = failed || status == stepFailed
+		}
+		rows = append(rows, []string{step.Name, result, took})
+	}
+	u.Table([]string{"Step", "Result", "Time"}, rows)
+	u.Printf("Total: %s", formatElapsed(total))
+
+	u.Print("")
+	u.Info("Configuration:")
+	for _, item := range []struct{ label, value string }{
+		{"User", cfg.GetOrDefault(config.KeyHomelabUser, "")},
+		{"Containers", cfg.GetOrDefault(config.KeyContainersBase, "")},
+		{"Appdata", cfg.GetOrDefault("APPDATA_BASE", "")},
+		{"Services", strings.Join(steps.SelectedServices(cfg), ", ")},
+	} {
+		if item.value == "" {
+			item.value = "(not set)"
+		}
+		u.Printf("  %-11s %s", item.label+":", item.value)
+	}
+
+	if len(warnings) > 0 {
+		u.Print("")
+		u.Infof("Warnings (%d):", len(warnings))
+		for _, warning := range warnings {
+			u.Printf("  - %s", warning)
+		}
+	}
+
+	u.Print("")
+	u.Info("Next steps:")
+	switch {
+	case failed:
+		u.Print("  - Fix the error above and run all steps again; completed steps can be skipped")
+	case verifyErr != nil:
+		u.Print("  - Deployment verification found problems; see Troubleshooting for diagnostics")
+	}
+	for _, reason := range system.RebootReasons() {
+		u.Printf("  - Reboot required: %s (sudo systemctl reboot)", reason)
+	}
+	if !failed {
+		host, err := system.GetLocalIP()
+		if err != nil || host == "" {
+			host = "localhost"
+		}
+		for _, access := range steps.AccessURLs(cfg, host) {
+			u.Printf("  - Open %s at %s", access.App, access.URL)
+		}
+	}
+	u.Print("")
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/steps"
+)
+
+// runTroubleshoot shows the troubleshooting submenu until the user goes back
+func (m *Menu) runTroubleshoot() error {
+	for {
+		clearScreen()
+		m.displayTroubleshootMenu()
+
+		choice, err := m.ctx.UI.PromptInput("Enter your choice", "")
+		if err != nil {
+			return err
+		}
+
+		choice = strings.ToUpper(strings.TrimSpace(choice))
+
+		if err := m.handleTroubleshootChoice(choice); err != nil {
+			if errors.Is(err, ErrBack) {
+				return nil
+			}
+			m.ctx.UI.Error(fmt.Sprintf("%v", err))
+			m.ctx.UI.Print("")
+			m.waitEnter()
+		}
+	}
+}
+
+// displayTroubleshootMenu displays the troubleshooting options
+func (m *Menu) displayTroubleshootMenu() {
+	bold := color.New(color.Bold)
+
+	m.ctx.UI.Header("Troubleshooting Tool")
+
+	bold.Print("  [1] ")
+	fmt.Println("Interfaces & Routes")
+	bold.Print("  [2] ")
+	fmt.Println("Connectivity Matrix")
+	bold.Print("  [3] ")
+	fmt.Println("WireGuard Health")
+	bold.Print("  [4] ")
+	fmt.Println("Port Scan")
+	bold.Print("  [5] ")
+	fmt.Println("System Info Report")
+	bold.Print("  [6] ")
+	fmt.Println("Effective Configuration")
+	fmt.Println()
+
+	m.ctx.UI.Info("For additional checks, use: /usr/share/home-lab-setup-scripts/scripts/troubleshoot.sh")
+	fmt.Println()
+
+	bold.Print("  [B] ")
+	fmt.Println("Back to Main Menu")
+	fmt.Println()
+}
+
+// handleTroubleshootChoice processes a troubleshooting submenu choice
+func (m *Menu) handleTroubleshootChoice(choice string) error {
+	switch choice {
+	case "1":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunInterfacesAndRoutes(m.ctx.Config, m.ctx.UI)
+		})
+	case "2":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunConnectivityMatrix(m.ctx.Config, m.ctx.UI)
+		})
+	case "3":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunWireGuardHealth(m.ctx.Config, m.ctx.UI)
+		})
+	case "4":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunPortScan(m.ctx.Config, m.ctx.UI)
+		})
+	case "5":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunSystemInfo(m.ctx.Config, m.ctx.UI)
+		})
+	case "6":
+		return m.runMaintenanceAction(func() error {
+			return steps.RunEffectiveConfig(m.ctx.Config, m.ctx.UI)
+		})
+	case "B":
+		return ErrBack
+	default:
+		return fmt.Errorf("invalid choice: %s", choice)
+	}
+}
@@ -0,0 +1,5 @@
+package common
+
+// RedactedValue replaces secret values wherever they would be shown: in
+// redacted config listings and in UI output
+const RedactedValue = "***"
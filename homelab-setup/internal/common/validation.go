@@ -4,8 +4,12 @@
 package common
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -89,3 +93,281 @@ func ValidateUsername(username string) error {
 
 	return nil
 }
+
+// ValidatePort validates a TCP/UDP port number (1-65535)
+func ValidatePort(port string) error {
+	if port == "" {
+		return fmt.Errorf("port cannot be empty")
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("port must be numeric: %s", port)
+	}
+
+	if n < 1 || n > 65535 {
+		return fmt.Errorf("port out of range (1-65535): %s", port)
+	}
+
+	return nil
+}
+
+// maxID is the largest valid UID/GID; 4294967295 ((uid_t)-1) is reserved
+const maxID = 4294967294
+
+// ValidateUID validates a numeric user ID such as PUID
+func ValidateUID(uid string) error {
+	return validateID("UID", uid)
+}
+
+// ValidateGID validates a numeric group ID such as PGID
+func ValidateGID(gid string) error {
+	return validateID("GID", gid)
+}
+
+// validateID checks that id is a decimal number within the valid ID range
+func validateID(kind, id string) error {
+	if id == "" {
+		return fmt.Errorf("%s cannot be empty", kind)
+	}
+
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a non-negative number: %s", kind, id)
+	}
+
+	if n > maxID {
+		return fmt.Errorf("%s out of range (0-%d): %s", kind, maxID, id)
+	}
+
+	return nil
+}
+
+// ValidateHostname validates a DNS name or IP address. Single-label names
+// such as "nas" are allowed for local mDNS/NetBIOS hosts.
+func ValidateHostname(host string) error {
+	if host == "" {
+		return fmt.Errorf("hostname cannot be empty")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("hostname too long (max 253 characters): %s", host)
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("hostname has an empty or overlong label: %s", host)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname label cannot start or end with a hyphen: %s", host)
+		}
+		for _, c := range label {
+			if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-') {
+				return fmt.Errorf("hostname contains invalid character: %s", host)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateIPAny validates an IPv4 or IPv6 address
+func ValidateIPAny(ip string) error {
+	if ip == "" {
+		return fmt.Errorf("IP address cannot be empty")
+	}
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid IP address: %s", ip)
+	}
+	return nil
+}
+
+// ValidateEndpoint validates a WireGuard endpoint of the form host:port, where
+// host is a DNS name or IP address (IPv6 addresses in brackets)
+func ValidateEndpoint(ep string) error {
+	if ep == "" {
+		return fmt.Errorf("endpoint cannot be empty")
+	}
+
+	host, port, err := net.SplitHostPort(ep)
+	if err != nil {
+		return fmt.Errorf("endpoint must be host:port: %s", ep)
+	}
+	if err := ValidateHostname(host); err != nil {
+		return fmt.Errorf("invalid endpoint host: %w", err)
+	}
+	if err := ValidatePort(port); err != nil {
+		return fmt.Errorf("invalid endpoint port: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateCIDR validates an IPv4 or IPv6 address in CIDR notation (e.g. "10.253.0.2/32")
+func ValidateCIDR(cidr string) error {
+	if cidr == "" {
+		return fmt.Errorf("CIDR cannot be empty")
+	}
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return fmt.Errorf("invalid CIDR notation: %s", cidr)
+	}
+	return nil
+}
+
+// CIDRsOverlap reports whether two networks in CIDR notation share any
+// address, i.e. one contains the other. Networks of different IP families
+// never overlap.
+func CIDRsOverlap(a, b string) (bool, error) {
+	_, netA, err := net.ParseCIDR(a)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR notation: %s", a)
+	}
+	_, netB, err := net.ParseCIDR(b)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR notation: %s", b)
+	}
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP), nil
+}
+
+// ValidateWireGuardKey validates a WireGuard private, public or preshared
+// key: 44 characters of standard base64 decoding to 32 bytes
+func ValidateWireGuardKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	if len(key) != 44 || !strings.HasSuffix(key, "=") {
+		return fmt.Errorf("key must be 44 base64 characters ending with '='")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("key is not valid base64")
+	}
+	if len(decoded) != 32 {
+		return fmt.Errorf("key must decode to 32 bytes, got %d", len(decoded))
+	}
+	return nil
+}
+
+// ValidateTimezone checks that tz is a well-formed zoneinfo name such as
+// "America/Chicago" or "UTC". Use system.TimezoneExists to confirm the zone
+// is actually installed.
+func ValidateTimezone(tz string) error {
+	if tz == "" {
+		return fmt.Errorf("timezone cannot be empty")
+	}
+
+	if strings.HasPrefix(tz, "/") || strings.HasSuffix(tz, "/") || strings.Contains(tz, "//") {
+		return fmt.Errorf("timezone must be a zone name like Region/City, not a path: %s", tz)
+	}
+
+	for _, part := range strings.Split(tz, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("timezone contains path traversal: %s", tz)
+		}
+	}
+
+	for _, c := range tz {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '+' || c == '/') {
+			return fmt.Errorf("timezone contains invalid character: %s", tz)
+		}
+	}
+
+	return nil
+}
+
+// plexClaimTokenPrefix is the prefix plex.tv puts on every claim token
+const plexClaimTokenPrefix = "claim-"
+
+// ValidatePlexClaimToken checks that token looks like a Plex claim token
+// (e.g. "claim-abc123"). It does not check whether the token has expired.
+func ValidatePlexClaimToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("claim token cannot be empty")
+	}
+
+	if !strings.HasPrefix(token, plexClaimTokenPrefix) || len(token) == len(plexClaimTokenPrefix) {
+		return fmt.Errorf("claim token must start with %q followed by the token (get one from https://plex.tv/claim)", plexClaimTokenPrefix)
+	}
+
+	for _, c := range token[len(plexClaimTokenPrefix):] {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-') {
+			return fmt.Errorf("claim token contains invalid character: %q", c)
+		}
+	}
+
+	return nil
+}
+
+// ErrDangerousPath is returned for paths that must never be used as a base
+// directory for service trees or as the target of cleanup
+var ErrDangerousPath = errors.New("refusing to use dangerous path")
+
+// ErrPathNotAllowlisted is returned for base paths outside AllowedBasePrefixes.
+// Callers may proceed only after explicit confirmation.
+var ErrPathNotAllowlisted = errors.New("path is outside the allowed base directories")
+
+// dangerousPaths are system roots that are never valid base directories.
+// Only exact matches are rejected; subdirectories such as /srv/containers are fine.
+var dangerousPaths = map[string]bool{
+	"/":         true,
+	"/bin":      true,
+	"/boot":     true,
+	"/dev":      true,
+	"/etc":      true,
+	"/home":     true,
+	"/lib":      true,
+	"/lib64":    true,
+	"/media":    true,
+	"/mnt":      true,
+	"/opt":      true,
+	"/proc":     true,
+	"/root":     true,
+	"/run":      true,
+	"/sbin":     true,
+	"/srv":      true,
+	"/sys":      true,
+	"/tmp":      true,
+	"/usr":      true,
+	"/var":      true,
+	"/var/home": true,
+	"/var/lib":  true,
+	"/var/mnt":  true,
+	"/var/srv":  true,
+	"/sysroot":  true,
+}
+
+// AllowedBasePrefixes are the locations base directories are expected under.
+// CoreOS symlinks /srv, /mnt and /home into /var, so both forms are listed.
+var AllowedBasePrefixes = []string{
+	"/srv/",
+	"/var/srv/",
+	"/var/lib/containers/",
+	"/mnt/",
+	"/var/mnt/",
+	"/opt/",
+	"/var/opt/",
+}
+
+// ValidateBasePath checks a directory that service trees are created under,
+// or that cleanup may remove. It rejects empty, relative and unsafe paths and
+// the dangerous system roots (ErrDangerousPath), and returns
+// ErrPathNotAllowlisted for paths outside AllowedBasePrefixes.
+func ValidateBasePath(path string) error {
+	if err := ValidateSafePath(path); err != nil {
+		return err
+	}
+
+	cleaned := filepath.Clean(path)
+	if dangerousPaths[cleaned] {
+		return fmt.Errorf("%w: %s", ErrDangerousPath, path)
+	}
+
+	for _, prefix := range AllowedBasePrefixes {
+		if strings.HasPrefix(cleaned+"/", prefix) && cleaned+"/" != prefix {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (expected under %s)", ErrPathNotAllowlisted, path, strings.Join(AllowedBasePrefixes, ", "))
+}
@@ -0,0 +1,242 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateBasePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr error // nil for success; sentinel to match with errors.Is
+		anyErr  bool  // other validation errors (empty, relative, metacharacters)
+	}{
+		{"/srv/containers", nil, false},
+		{"/var/lib/containers/appdata", nil, false},
+		{"/var/srv/containers/", nil, false},
+		{"/mnt/nas/media", nil, false},
+		{"/", ErrDangerousPath, false},
+		{"/home", ErrDangerousPath, false},
+		{"/var", ErrDangerousPath, false},
+		{"/etc", ErrDangerousPath, false},
+		{"/etc/", ErrDangerousPath, false},
+		{"/srv", ErrDangerousPath, false},
+		{"/srv/../var", ErrDangerousPath, false},
+		{"/var/lib", ErrDangerousPath, false},
+		{"/usr/local/containers", ErrPathNotAllowlisted, false},
+		{"/var/lib/containers", ErrPathNotAllowlisted, false},
+		{"", nil, true},
+		{"srv/containers", nil, true},
+		{"/srv/containers;rm", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := ValidateBasePath(tt.path)
+			switch {
+			case tt.anyErr:
+				if err == nil {
+					t.Errorf("ValidateBasePath(%q) expected error", tt.path)
+				}
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Errorf("ValidateBasePath(%q) unexpected error: %v", tt.path, err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateBasePath(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePlexClaimToken(t *testing.T) {
+	tests := []struct {
+		token   string
+		wantErr bool
+	}{
+		{"claim-AbC123xyz_-9", false},
+		{"", true},
+		{"claim-", true},
+		{"AbC123xyz", true},
+		{"CLAIM-abc", true},
+		{" claim-abc", true},
+		{"claim-abc def", true},
+		{"claim-abc;rm", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePlexClaimToken(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePlexClaimToken(%q) error = %v, wantErr %v", tt.token, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	tests := []struct {
+		port    string
+		wantErr bool
+	}{
+		{"1", false},
+		{"80", false},
+		{"32400", false},
+		{"65535", false},
+		{"", true},
+		{"0", true},
+		{"-1", true},
+		{"65536", true},
+		{"99999999999", true},
+		{"http", true},
+		{"80/tcp", true},
+		{" 80", true},
+		{"8080 ", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePort(tt.port)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePort(%q) error = %v, wantErr %v", tt.port, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	tests := []struct {
+		tz      string
+		wantErr bool
+	}{
+		{"America/Chicago", false},
+		{"America/Argentina/Buenos_Aires", false},
+		{"Etc/GMT+5", false},
+		{"UTC", false},
+		{"", true},
+		{"/usr/share/zoneinfo/UTC", true},
+		{"Europe/", true},
+		{"../etc/passwd", true},
+		{"Europe/Berlin; rm -rf /", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTimezone(tt.tz)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTimezone(%q) error = %v, wantErr %v", tt.tz, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{"vpn.example.com:51820", false},
+		{"1.2.3.4:51820", false},
+		{"[2001:db8::1]:51820", false},
+		{"nas:51820", false},
+		{"", true},
+		{"vpn.example.com", true},
+		{"1.2.3.4", true},
+		{"vpn.example.com:", true},
+		{"vpn.example.com:abc", true},
+		{"vpn.example.com:70000", true},
+		{"vpn.example.com:0", true},
+		{":51820", true},
+		{"-bad.example.com:51820", true},
+		{"vpn..example.com:51820", true},
+		{"vpn example.com:51820", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			err := ValidateEndpoint(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEndpoint(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCIDR(t *testing.T) {
+	for _, cidr := range []string{"10.253.0.2/32", "192.168.1.0/24", "::/0"} {
+		if err := ValidateCIDR(cidr); err != nil {
+			t.Errorf("ValidateCIDR(%q) unexpected error: %v", cidr, err)
+		}
+	}
+	for _, cidr := range []string{"", "10.253.0.2", "10.253.0.300/32", "10.0.0.0/33"} {
+		if err := ValidateCIDR(cidr); err == nil {
+			t.Errorf("ValidateCIDR(%q) expected error", cidr)
+		}
+	}
+}
+
+func TestCIDRsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.253.0.2/32", "10.253.0.2/32", true},
+		{"10.253.0.0/24", "10.253.0.7/32", true},
+		{"0.0.0.0/0", "192.168.1.0/24", true},
+		{"10.253.0.2/32", "10.253.0.3/32", false},
+		{"10.0.0.0/8", "fd00::/8", false},
+	}
+	for _, tt := range tests {
+		got, err := CIDRsOverlap(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("CIDRsOverlap(%q, %q) unexpected error: %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("CIDRsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if _, err := CIDRsOverlap("10.253.0.2", "10.253.0.0/24"); err == nil {
+		t.Error("CIDRsOverlap() with a bare address expected error")
+	}
+}
+
+func TestValidateWireGuardKey(t *testing.T) {
+	if err := ValidateWireGuardKey("YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="); err != nil {
+		t.Errorf("ValidateWireGuardKey() unexpected error: %v", err)
+	}
+	for _, key := range []string{
+		"",
+		"YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk",  // padding stripped
+		"YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBm!=", // not base64
+	} {
+		if err := ValidateWireGuardKey(key); err == nil {
+			t.Errorf("ValidateWireGuardKey(%q) expected error", key)
+		}
+	}
+}
+
+func TestValidateIPAny(t *testing.T) {
+	for _, ip := range []string{"192.168.1.1", "10.253.0.1", "fd00::1"} {
+		if err := ValidateIPAny(ip); err != nil {
+			t.Errorf("ValidateIPAny(%q) unexpected error: %v", ip, err)
+		}
+	}
+	for _, ip := range []string{"", "dns.lan", "192.168.1.256", "10.0.0.0/8"} {
+		if err := ValidateIPAny(ip); err == nil {
+			t.Errorf("ValidateIPAny(%q) expected error", ip)
+		}
+	}
+}
+
+func TestValidateUID(t *testing.T) {
+	for _, uid := range []string{"0", "1000", "65534", "4294967294"} {
+		if err := ValidateUID(uid); err != nil {
+			t.Errorf("ValidateUID(%q) unexpected error: %v", uid, err)
+		}
+	}
+	for _, uid := range []string{"", "-1", "1000abc", "abc", " 1000", "4294967295", "99999999999"} {
+		if err := ValidateUID(uid); err == nil {
+			t.Errorf("ValidateUID(%q) expected error", uid)
+		}
+	}
+	if err := ValidateGID("media"); err == nil || !strings.Contains(err.Error(), "GID") {
+		t.Errorf("ValidateGID(%q) error = %v, want a GID error", "media", err)
+	}
+}
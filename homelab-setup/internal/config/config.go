@@ -5,22 +5,26 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 )
 
 // Config manages homelab setup configuration and completion markers with thread-safe operations
 type Config struct {
-	filePath  string
-	markerDir string
-	data      map[string]string
-	loaded    bool // Track if configuration has been loaded from disk
-	mu        sync.RWMutex
+	filePath     string
+	markerDir    string
+	data         map[string]string
+	secrets      map[string]bool // Keys annotated with "# @secret" in the config file
+	loaded       bool            // Track if configuration has been loaded from disk
+	dryRun       bool            // Completion markers are not written (see SetDryRun)
+	verifyWrites bool            // Saved values are read back (see SetVerifyWrites)
+	mu           sync.RWMutex
 }
 
 // ensureLoaded loads configuration data from disk once before read operations.
@@ -33,75 +37,86 @@ func (c *Config) ensureLoaded() error {
 	return c.Load()
 }
 
-// New creates a new Config instance
+// New creates a new Config instance. An empty filePath selects the config
+// file of the active profile (see SetProfile). Markers always live in the
+// active profile's marker directory.
 func New(filePath string) *Config {
-	var markerDir string
+	profile := ActiveProfile()
 	if filePath == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			home = "/var/home/core" // Fallback for CoreOS
-		}
-		filePath = filepath.Join(home, ".homelab-setup.conf")
-		markerDir = filepath.Join(home, ".local", "homelab-setup")
-	} else {
-		// If custom config path provided, use adjacent directory for markers
-		home, err := os.UserHomeDir()
-		if err != nil {
-			home = "/var/home/core"
-		}
-		markerDir = filepath.Join(home, ".local", "homelab-setup")
+		filePath = ProfileConfigPath(profile)
 	}
 
 	return &Config{
 		filePath:  filePath,
-		markerDir: markerDir,
+		markerDir: ProfileMarkerDir(profile),
 		data:      make(map[string]string),
+		secrets:   make(map[string]bool),
 	}
 }
 
-// Load reads configuration from file
-func (c *Config) Load() error {
-	// If file doesn't exist, that's okay - we'll create it on Save
-	if _, err := os.Stat(c.filePath); os.IsNotExist(err) {
-		c.loaded = true
-		return nil
-	}
+// SecretAnnotation is the comment placed on the line preceding a key to mark
+// its value as sensitive. Annotated values are masked by GetAllRedacted.
+const SecretAnnotation = "# @secret"
 
-	file, err := os.Open(c.filePath)
+// RedactedValue replaces secret values in redacted output
+const RedactedValue = common.RedactedValue
+
+// Load reads configuration from file. A file that looks corrupt is copied to
+// <file>.corrupt and reported as ErrConfigCorrupt without loading anything, so
+// a later write cannot overwrite the remaining settings.
+func (c *Config) Load() error {
+	data, secrets, err := c.readFile()
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %w", err)
+		return err
+	}
+	for key, value := range data {
+		c.data[key] = value
+	}
+	for key := range secrets {
+		c.secrets[key] = true
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	c.loaded = true
+	return nil
+}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+// readFile parses the config file into fresh maps without touching c. A
+// missing file yields empty maps.
+func (c *Config) readFile() (map[string]string, map[string]bool, error) {
+	content, err := os.ReadFile(c.filePath)
+	// If file doesn't exist, that's okay - we'll create it on Save
+	if os.IsNotExist(err) {
+		return map[string]string{}, map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open config file: %w", err)
+	}
 
-		// Parse key=value
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			c.data[key] = value
-		}
+	data, secrets, err := parseConfig(content)
+	if err != nil {
+		return nil, nil, c.corruptError(content, err)
 	}
+	return data, secrets, nil
+}
 
-	if err := scanner.Err(); err != nil {
+// Save writes configuration to file using atomic write pattern while holding
+// the config file lock. This prevents data loss if the write operation fails
+// midway or another instance writes at the same time.
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	lock, err := acquireFileLock(c.lockPath(), lockTimeout)
+	if err != nil {
 		return err
 	}
-
-	c.loaded = true
-	return nil
+	defer lock.release()
+	return c.save()
 }
 
-// Save writes configuration to file using atomic write pattern
-// This prevents data loss if the write operation fails midway
-func (c *Config) Save() error {
+// save writes the configuration file atomically. The caller must hold the
+// config file lock (see lockForWrite).
+func (c *Config) save() error {
 	// Ensure directory exists
 	dir := filepath.Dir(c.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -121,14 +136,21 @@ func (c *Config) Save() error {
 		tmpFile.Close()
 		return fmt.Errorf("failed to set permissions on temp file: %w", err)
 	}
+	if err := matchDirOwner(tmpFile, dir); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to set owner of temp file: %w", err)
+	}
 
 	// Write header
 	fmt.Fprintln(tmpFile, "# UBlue uCore Homelab Setup Configuration")
 	fmt.Fprintf(tmpFile, "# Generated: %s\n", time.Now().Format(time.RFC3339))
 	fmt.Fprintln(tmpFile, "")
 
-	// Write key-value pairs
+	// Write key-value pairs, preserving secret annotations
 	for key, value := range c.data {
+		if c.secrets[key] {
+			fmt.Fprintln(tmpFile, SecretAnnotation)
+		}
 		fmt.Fprintf(tmpFile, "%s=%s\n", key, value)
 	}
 
@@ -186,21 +208,22 @@ func (c *Config) GetOrDefault(key, defaultValue string) string {
 }
 
 // Set sets a configuration value (thread-safe)
-// Automatically loads existing configuration if not already loaded to prevent data loss
+// Reloads the file under the config file lock so changes saved by another
+// instance are kept. If the save (or, with SetVerifyWrites, its verification)
+// fails, the previous value is kept in memory.
 func (c *Config) Set(key, value string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Load existing configuration first to avoid overwriting
-	// Note: We're holding c.mu.Lock, so calling c.Load() directly is safe
-	if !c.loaded {
-		if err := c.Load(); err != nil {
-			return fmt.Errorf("failed to load existing config before set: %w", err)
-		}
+	release, err := c.lockForWrite()
+	if err != nil {
+		return err
 	}
+	defer release()
 
+	previous, existed := c.data[key]
 	c.data[key] = value
-	return c.Save()
+	return c.saveKey(key, previous, existed, c.secrets[key])
 }
 
 // Exists checks if a key exists (thread-safe)
@@ -231,21 +254,108 @@ func (c *Config) GetAll() map[string]string {
 	return result
 }
 
+// GetAllRedacted returns all configuration data with secret values masked (thread-safe)
+// Use this instead of GetAll whenever values are displayed or logged
+func (c *Config) GetAllRedacted() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.ensureLoaded(); err != nil {
+		return map[string]string{}
+	}
+	result := make(map[string]string, len(c.data))
+	for k, v := range c.data {
+		if (c.secrets[k] || isSensitiveKey(k)) && v != "" {
+			v = RedactedValue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// IsSecret reports whether a key is annotated as secret or listed in
+// SensitiveKeys (thread-safe)
+func (c *Config) IsSecret(key string) bool {
+	if isSensitiveKey(key) {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.ensureLoaded(); err != nil {
+		return false
+	}
+	return c.secrets[key]
+}
+
+// SecretValues returns the non-empty values of all secret keys (thread-safe)
+// Used to register values with the UI so they are masked in output
+func (c *Config) SecretValues() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.ensureLoaded(); err != nil {
+		return nil
+	}
+	var values []string
+	for k, v := range c.data {
+		if v != "" && (c.secrets[k] || isSensitiveKey(k)) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// SetSecret sets a configuration value and annotates it as secret (thread-safe)
+func (c *Config) SetSecret(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	release, err := c.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	previous, existed := c.data[key]
+	wasSecret := c.secrets[key]
+	c.data[key] = value
+	c.secrets[key] = true
+	return c.saveKey(key, previous, existed, wasSecret)
+}
+
 // Delete removes a configuration key (thread-safe)
-// Automatically loads existing configuration if not already loaded to prevent data loss
+// Reloads the file under the config file lock so changes saved by another
+// instance are kept. If the save (or, with SetVerifyWrites, its
+// verification) fails, the key is kept in memory.
 func (c *Config) Delete(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Load existing configuration first to avoid overwriting
-	if !c.loaded {
-		if err := c.Load(); err != nil {
-			return fmt.Errorf("failed to load existing config before delete: %w", err)
-		}
+	release, err := c.lockForWrite()
+	if err != nil {
+		return err
 	}
+	defer release()
 
+	previous, existed := c.data[key]
+	wasSecret := c.secrets[key]
 	delete(c.data, key)
-	return c.Save()
+	delete(c.secrets, key)
+
+	err = c.save()
+	if err == nil && c.verifyWrites {
+		err = c.verifySaved(nil, key)
+	}
+	if err != nil && existed {
+		// The file may still hold the key, so memory keeps it too
+		c.data[key] = previous
+		if wasSecret {
+			c.secrets[key] = true
+		}
+	}
+	return err
 }
 
 // FilePath returns the configuration file path
@@ -253,6 +363,14 @@ func (c *Config) FilePath() string {
 	return c.filePath
 }
 
+// isSecretAnnotation reports whether a trimmed config line is a secret annotation
+func isSecretAnnotation(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "#")) == "@secret"
+}
+
 // ===== Marker Management Methods =====
 
 // validateMarkerName ensures the marker name is safe and doesn't contain path traversal characters
@@ -269,32 +387,51 @@ func validateMarkerName(name string) error {
 	return nil
 }
 
-// MarkComplete creates a completion marker file (idempotent)
+// MarkComplete records a step as complete with the current time (idempotent).
+// The marker is stored in the config file when MarkersInConfig is enabled,
+// otherwise as a file in the marker directory.
 func (c *Config) MarkComplete(name string) error {
 	if err := validateMarkerName(name); err != nil {
 		return err
 	}
+	if c.isDryRun() {
+		return nil
+	}
+
+	if c.MarkersInConfig() {
+		return c.Set(MarkerKeyPrefix+name, strings.TrimSpace(string(markerTimestamp())))
+	}
 
 	if err := os.MkdirAll(c.markerDir, 0755); err != nil {
 		return fmt.Errorf("failed to create marker directory: %w", err)
 	}
 
 	markerPath := filepath.Join(c.markerDir, name)
-	file, err := os.Create(markerPath)
-	if err != nil {
+	if err := os.WriteFile(markerPath, markerTimestamp(), 0644); err != nil {
 		return fmt.Errorf("failed to create marker file: %w", err)
 	}
-	defer file.Close()
 
 	return nil
 }
 
+// markerTimestamp returns the completion time recorded inside marker files
+func markerTimestamp() []byte {
+	return []byte(time.Now().Format(time.RFC3339) + "\n")
+}
+
 // MarkCompleteIfNotExists atomically creates a marker only if it doesn't exist
 // Returns (wasCreated, error) where wasCreated indicates if this call created the marker
 func (c *Config) MarkCompleteIfNotExists(name string) (bool, error) {
 	if err := validateMarkerName(name); err != nil {
 		return false, err
 	}
+	if c.isDryRun() {
+		return !c.IsComplete(name), nil
+	}
+
+	if c.MarkersInConfig() {
+		return c.markCompleteInConfigIfNotExists(name)
+	}
 
 	if err := os.MkdirAll(c.markerDir, 0755); err != nil {
 		return false, fmt.Errorf("failed to create marker directory: %w", err)
@@ -310,6 +447,10 @@ func (c *Config) MarkCompleteIfNotExists(name string) (bool, error) {
 	}
 	defer file.Close()
 
+	if _, err := file.Write(markerTimestamp()); err != nil {
+		return true, fmt.Errorf("failed to write marker timestamp: %w", err)
+	}
+
 	return true, nil
 }
 
@@ -320,8 +461,36 @@ func (c *Config) IsComplete(name string) bool {
 	}
 
 	markerPath := filepath.Join(c.markerDir, name)
-	_, err := os.Stat(markerPath)
-	return err == nil
+	if _, err := os.Stat(markerPath); err == nil {
+		return true
+	}
+	return c.Exists(MarkerKeyPrefix + name)
+}
+
+// MarkerInfo returns when a step was marked complete. ok is false if the marker
+// does not exist. Markers created by older versions are empty; for those ok is
+// true and completedAt is the zero time (completion time unknown).
+func (c *Config) MarkerInfo(name string) (completedAt time.Time, ok bool) {
+	if err := validateMarkerName(name); err != nil {
+		return time.Time{}, false
+	}
+
+	var value string
+	if stored, err := c.Get(MarkerKeyPrefix + name); err == nil {
+		value = stored
+	} else {
+		data, err := os.ReadFile(filepath.Join(c.markerDir, name))
+		if err != nil {
+			return time.Time{}, false
+		}
+		value = string(data)
+	}
+
+	completedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, true
+	}
+	return completedAt, true
 }
 
 // ClearMarker removes a completion marker
@@ -330,6 +499,12 @@ func (c *Config) ClearMarker(name string) error {
 		return err
 	}
 
+	if c.Exists(MarkerKeyPrefix + name) {
+		if err := c.Delete(MarkerKeyPrefix + name); err != nil {
+			return err
+		}
+	}
+
 	markerPath := filepath.Join(c.markerDir, name)
 	err := os.Remove(markerPath)
 	if os.IsNotExist(err) {
@@ -338,18 +513,50 @@ func (c *Config) ClearMarker(name string) error {
 	return err
 }
 
-// ClearAllMarkers removes all marker files
+// ClearAllMarkers removes all marker files and config-stored markers. The
+// audit log and the named profiles are kept.
 func (c *Config) ClearAllMarkers() error {
-	if _, err := os.Stat(c.markerDir); os.IsNotExist(err) {
+	if err := c.clearConfigMarkers(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(c.markerDir)
+	if os.IsNotExist(err) {
 		return nil
 	}
-	return os.RemoveAll(c.markerDir)
+	if err != nil {
+		return fmt.Errorf("failed to read marker directory: %w", err)
+	}
+	for _, entry := range entries {
+		// The default profile's marker directory also holds the named profiles
+		if entry.IsDir() && entry.Name() == profilesDirName {
+			continue
+		}
+		// The audit log shares the directory but is not a marker
+		if entry.Name() == AuditLogName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.markerDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// ListMarkers returns all marker names
+// ListMarkers returns all marker names from both the marker directory and the config file
 func (c *Config) ListMarkers() ([]string, error) {
+	markers := []string{}
+	seen := make(map[string]bool)
+
+	for key := range c.GetAll() {
+		if name, ok := strings.CutPrefix(key, MarkerKeyPrefix); ok && !seen[name] {
+			markers = append(markers, name)
+			seen[name] = true
+		}
+	}
+
 	if _, err := os.Stat(c.markerDir); os.IsNotExist(err) {
-		return []string{}, nil
+		return markers, nil
 	}
 
 	entries, err := os.ReadDir(c.markerDir)
@@ -357,10 +564,10 @@ func (c *Config) ListMarkers() ([]string, error) {
 		return nil, fmt.Errorf("failed to read marker directory: %w", err)
 	}
 
-	var markers []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && entry.Name() != AuditLogName && !seen[entry.Name()] {
 			markers = append(markers, entry.Name())
+			seen[entry.Name()] = true
 		}
 	}
 
@@ -371,3 +578,12 @@ func (c *Config) ListMarkers() ([]string, error) {
 func (c *Config) MarkerDir() string {
 	return c.markerDir
 }
+
+// AuditLogName is the privileged command audit log kept in the marker
+// directory. Clearing markers leaves it in place.
+const AuditLogName = "audit.log"
+
+// AuditLogPath returns the path of the audit log for this config's profile
+func (c *Config) AuditLogPath() string {
+	return filepath.Join(c.markerDir, AuditLogName)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrConfigCorrupt is returned by Load when the config file does not look
+// like a key=value file
+var ErrConfigCorrupt = errors.New("config file appears to be corrupt")
+
+// parseConfig parses key=value content, returning the values and the keys
+// annotated as secret. It fails on content that is not UTF-8 text or where
+// most non-comment lines are not key=value pairs, which points to truncation
+// or a binary blob rather than hand edits.
+func parseConfig(content []byte) (map[string]string, map[string]bool, error) {
+	if !utf8.Valid(content) {
+		return nil, nil, fmt.Errorf("content is not valid UTF-8 text")
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil, nil, fmt.Errorf("content contains NUL bytes")
+	}
+
+	data := make(map[string]string)
+	secrets := make(map[string]bool)
+	var parsed, unparseable int
+	var firstBad string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	// The whole file is already in memory, so allow a line as long as the
+	// file rather than failing on values past bufio's 64 KiB default
+	scanner.Buffer(nil, len(content)+1)
+	pendingSecret := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// A "# @secret" comment applies to the next key=value line
+		if isSecretAnnotation(line) {
+			pendingSecret = true
+			continue
+		}
+
+		// Skip empty lines and comments. The annotation only applies to
+		// the line right after it.
+		if line == "" || strings.HasPrefix(line, "#") {
+			pendingSecret = false
+			continue
+		}
+
+		// Parse key=value
+		parts := strings.SplitN(line, "=", 2)
+		key := ""
+		if len(parts) == 2 {
+			key = strings.TrimSpace(parts[0])
+		}
+		if key == "" {
+			unparseable++
+			if firstBad == "" {
+				firstBad = line
+			}
+			pendingSecret = false
+			continue
+		}
+
+		parsed++
+		data[key] = strings.TrimSpace(parts[1])
+		if pendingSecret {
+			secrets[key] = true
+		}
+		pendingSecret = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if unparseable > parsed {
+		return nil, nil, fmt.Errorf("%d of %d lines are not key=value pairs (first: %q)", unparseable, parsed+unparseable, firstBad)
+	}
+	return data, secrets, nil
+}
+
+// corruptError backs up a corrupt config file to <file>.corrupt and returns
+// an ErrConfigCorrupt error telling the user what to do
+func (c *Config) corruptError(content []byte, cause error) error {
+	backup := c.filePath + ".corrupt"
+	if existing, err := os.ReadFile(backup); err != nil || !bytes.Equal(existing, content) {
+		if err := os.WriteFile(backup, content, 0600); err != nil {
+			return fmt.Errorf("%w: %s: %v (backup to %s failed: %v)", ErrConfigCorrupt, c.filePath, cause, backup, err)
+		}
+	}
+	return fmt.Errorf("%w: %s: %v; a copy was saved to %s. Fix or remove the file and run again; it will not be overwritten until then",
+		ErrConfigCorrupt, c.filePath, cause, backup)
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadReportsCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"binary blob", "\x00\x01\x02\xffPK\x03\x04\x00\x00garbage"},
+		{"invalid UTF-8", "KEY=value\n\xc3\x28\n"},
+		{"mostly garbage", "KEY=value\nlorem ipsum\ndolor sit amet\nconsectetur\n"},
+		{"truncated", "CONTAINER_RUN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			if err := os.WriteFile(cfg.FilePath(), []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := cfg.Load()
+			if !errors.Is(err, ErrConfigCorrupt) {
+				t.Fatalf("Load() error = %v, want ErrConfigCorrupt", err)
+			}
+			if !strings.Contains(err.Error(), cfg.FilePath()+".corrupt") {
+				t.Errorf("Load() error should name the backup file: %v", err)
+			}
+
+			backup, err := os.ReadFile(cfg.FilePath() + ".corrupt")
+			if err != nil || string(backup) != tt.content {
+				t.Errorf("backup = %q, %v; want the original content", backup, err)
+			}
+
+			// Writes must not replace the corrupt file
+			if err := cfg.Set("NEW_KEY", "value"); !errors.Is(err, ErrConfigCorrupt) {
+				t.Errorf("Set() error = %v, want ErrConfigCorrupt", err)
+			}
+			current, _ := os.ReadFile(cfg.FilePath())
+			if string(current) != tt.content {
+				t.Errorf("corrupt config was overwritten with %q", current)
+			}
+		})
+	}
+}
+
+func TestLoadToleratesStrayLines(t *testing.T) {
+	cfg := newTestConfig(t)
+	content := "# comment\nKEY=value\nOTHER=1\nstray line\n\n"
+	if err := os.WriteFile(cfg.FilePath(), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.GetOrDefault("KEY", ""); got != "value" {
+		t.Errorf("KEY = %q, want value", got)
+	}
+	if _, err := os.Stat(cfg.FilePath() + ".corrupt"); !os.IsNotExist(err) {
+		t.Error("no backup should be written for a healthy file")
+	}
+}
+
+func TestLoadAcceptsLongValues(t *testing.T) {
+	cfg := newTestConfig(t)
+	long := strings.Repeat("a", 100*1024)
+	if err := os.WriteFile(cfg.FilePath(), []byte("KEY="+long+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.GetOrDefault("KEY", ""); got != long {
+		t.Errorf("KEY has %d bytes, want %d", len(got), len(long))
+	}
+}
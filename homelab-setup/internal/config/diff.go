@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChangeKind describes how a key differs between the saved and proposed config
+type ChangeKind int
+
+// Change kinds reported by Diff
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeModified
+	ChangeRemoved
+)
+
+// String returns the diff marker for the kind
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "+"
+	case ChangeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Change is one key that differs between the saved and proposed config.
+// Secret values are replaced with RedactedValue.
+type Change struct {
+	Key  string
+	Kind ChangeKind
+	Old  string // empty for ChangeAdded
+	New  string // empty for ChangeRemoved
+}
+
+// String renders the change as a single diff line
+func (ch Change) String() string {
+	switch ch.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s=%s", ch.Key, ch.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s=%s", ch.Key, ch.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", ch.Key, ch.Old, ch.New)
+	}
+}
+
+// Diff compares proposed, a complete replacement for the saved configuration,
+// against the current values and returns the differences sorted by key. Keys
+// missing from proposed are reported as removed. Secret values are redacted
+// (thread-safe).
+func (c *Config) Diff(proposed map[string]string) []Change {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var current map[string]string
+	if err := c.ensureLoaded(); err == nil {
+		current = c.data
+	}
+	return c.diffValues(current, proposed)
+}
+
+// DiffFrom returns how the current values differ from previous, a snapshot
+// taken earlier with GetAll, sorted by key. Secret values are redacted
+// (thread-safe).
+func (c *Config) DiffFrom(previous map[string]string) []Change {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var current map[string]string
+	if err := c.ensureLoaded(); err == nil {
+		current = c.data
+	}
+	return c.diffValues(previous, current)
+}
+
+// diffValues returns the changes from before to after; the caller holds c.mu
+func (c *Config) diffValues(before, after map[string]string) []Change {
+	redact := func(key, value string) string {
+		if value != "" && (c.secrets[key] || isSensitiveKey(key)) {
+			return RedactedValue
+		}
+		return value
+	}
+
+	var changes []Change
+	for key, newValue := range after {
+		oldValue, exists := before[key]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Key: key, Kind: ChangeAdded, New: redact(key, newValue)})
+		case oldValue != newValue:
+			changes = append(changes, Change{Key: key, Kind: ChangeModified, Old: redact(key, oldValue), New: redact(key, newValue)})
+		}
+	}
+	for key, oldValue := range before {
+		if _, kept := after[key]; !kept {
+			changes = append(changes, Change{Key: key, Kind: ChangeRemoved, Old: redact(key, oldValue)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// DiffSetMany returns the changes SetMany(values) would make
+func (c *Config) DiffSetMany(values map[string]string) []Change {
+	proposed := c.GetAll()
+	for key, value := range values {
+		proposed[key] = value
+	}
+	return c.Diff(proposed)
+}
+
+// SetMany sets several configuration values and saves them in a single write
+// (thread-safe). If the save (or, with SetVerifyWrites, its verification)
+// fails, the previous values are kept in memory.
+func (c *Config) SetMany(values map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	release, err := c.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	previous := make(map[string]string, len(values))
+	for key, value := range values {
+		if old, exists := c.data[key]; exists {
+			previous[key] = old
+		}
+		c.data[key] = value
+	}
+
+	err = c.save()
+	if err == nil && c.verifyWrites {
+		err = c.verifySaved(values)
+	}
+	if err != nil {
+		// Keep memory consistent with the file on failure
+		for key := range values {
+			if old, exists := previous[key]; exists {
+				c.data[key] = old
+			} else {
+				delete(c.data, key)
+			}
+		}
+		return err
+	}
+	return nil
+}
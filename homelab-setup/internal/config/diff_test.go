@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SetMany(map[string]string{
+		"CONTAINERS_BASE": "/srv/containers",
+		"HOMELAB_USER":    "core",
+		"TZ":              "UTC",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSecret("PLEX_CLAIM_TOKEN", "claim-old"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := cfg.Diff(map[string]string{
+		"CONTAINERS_BASE":  "/var/srv/containers",
+		"HOMELAB_USER":     "core",
+		"PLEX_CLAIM_TOKEN": "claim-new",
+		"APPDATA_BASE":     "/var/lib/containers/appdata",
+	})
+
+	want := []Change{
+		{Key: "APPDATA_BASE", Kind: ChangeAdded, New: "/var/lib/containers/appdata"},
+		{Key: "CONTAINERS_BASE", Kind: ChangeModified, Old: "/srv/containers", New: "/var/srv/containers"},
+		{Key: "PLEX_CLAIM_TOKEN", Kind: ChangeModified, Old: RedactedValue, New: RedactedValue},
+		{Key: "TZ", Kind: ChangeRemoved, Old: "UTC"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Diff()[%d] = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestDiffSetManyOnlyReportsTouchedKeys(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SetMany(map[string]string{"HOMELAB_USER": "core", "TZ": "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := cfg.DiffSetMany(map[string]string{"HOMELAB_USER": "homelab", "PUID": "1001"})
+	if len(changes) != 2 {
+		t.Fatalf("DiffSetMany() = %v, want 2 changes", changes)
+	}
+	if got := changes[0].String(); got != "~ HOMELAB_USER: core -> homelab" {
+		t.Errorf("changes[0] = %q", got)
+	}
+	if got := changes[1].String(); got != "+ PUID=1001" {
+		t.Errorf("changes[1] = %q", got)
+	}
+}
+
+func TestDiffFrom(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SetMany(map[string]string{"HOMELAB_USER": "core", "TZ": "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	before := cfg.GetAll()
+	if err := cfg.SetMany(map[string]string{"TZ": "Europe/Berlin", "PUID": "1001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := cfg.DiffFrom(before)
+	if len(changes) != 2 {
+		t.Fatalf("DiffFrom() = %v, want 2 changes", changes)
+	}
+	if got := changes[0].String(); got != "+ PUID=1001" {
+		t.Errorf("changes[0] = %q", got)
+	}
+	if got := changes[1].String(); got != "~ TZ: UTC -> Europe/Berlin" {
+		t.Errorf("changes[1] = %q", got)
+	}
+}
+
+func TestSetManyPersists(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SetMany(map[string]string{"PUID": "1000", "PGID": "1000"}); err != nil {
+		t.Fatalf("SetMany() error = %v", err)
+	}
+
+	reloaded := New(cfg.FilePath())
+	for _, key := range []string{"PUID", "PGID"} {
+		if got, err := reloaded.Get(key); err != nil || got != "1000" {
+			t.Errorf("Get(%s) = (%q, %v), want 1000", key, got, err)
+		}
+	}
+}
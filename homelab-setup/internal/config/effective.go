@@ -0,0 +1,48 @@
+package config
+
+import "sort"
+
+// Sources of an effective value
+const (
+	SourceFile    = "file"    // set in the config file
+	SourceDefault = "default" // taken from the Defaults table
+)
+
+// EffectiveValue is the value GetOrDefault resolves for a key and where it
+// comes from. Secret values are replaced with RedactedValue.
+type EffectiveValue struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Effective returns the resolved value of every key set in the config file
+// or listed in Defaults, sorted by key. Values in the file take precedence
+// over defaults, as in GetOrDefault. The fallback a caller passes to
+// GetOrDefault is not known here, so keys outside Defaults that are not in
+// the file are omitted (thread-safe).
+func (c *Config) Effective() []EffectiveValue {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var data map[string]string
+	if err := c.ensureLoaded(); err == nil {
+		data = c.data
+	}
+
+	values := make([]EffectiveValue, 0, len(data)+len(Defaults))
+	for key, value := range data {
+		if value != "" && (c.secrets[key] || isSensitiveKey(key)) {
+			value = RedactedValue
+		}
+		values = append(values, EffectiveValue{Key: key, Value: value, Source: SourceFile})
+	}
+	for key, value := range Defaults {
+		if _, set := data[key]; !set {
+			values = append(values, EffectiveValue{Key: key, Value: value, Source: SourceDefault})
+		}
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestEffective(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SetMany(map[string]string{
+		KeyNFSServer:        "192.168.1.10",
+		KeyContainerRuntime: "podman",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSecret("PLEX_CLAIM_TOKEN", "claim-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]EffectiveValue)
+	for _, v := range cfg.Effective() {
+		got[v.Key] = v
+	}
+
+	tests := []EffectiveValue{
+		{Key: KeyNFSServer, Value: "192.168.1.10", Source: SourceFile},
+		{Key: KeyContainerRuntime, Value: "podman", Source: SourceFile}, // overrides the default
+		{Key: KeyNFSMountPoint, Value: Defaults[KeyNFSMountPoint], Source: SourceDefault},
+		{Key: "PLEX_CLAIM_TOKEN", Value: RedactedValue, Source: SourceFile},
+	}
+	for _, want := range tests {
+		if got[want.Key] != want {
+			t.Errorf("Effective()[%s] = %+v, want %+v", want.Key, got[want.Key], want)
+		}
+	}
+	if len(got) != len(Defaults)+2 {
+		t.Errorf("Effective() returned %d keys, want %d", len(got), len(Defaults)+2)
+	}
+}
@@ -9,44 +9,117 @@ const (
 	KeyHomelabTimezone = "HOMELAB_TIMEZONE"
 
 	// Directory configuration
-	KeyContainersBase = "CONTAINERS_BASE" // Base directory for container services (/srv/containers)
+	KeyContainersBase        = "CONTAINERS_BASE"         // Base directory for container services (/srv/containers)
+	KeyAppdataPathPrefix     = "APPDATA_PATH_"           // Per-application appdata directory override, suffixed with the upper-case app name (e.g. APPDATA_PATH_PLEX, APPDATA_PATH_IMMICH_DB)
+	KeyAppdataBackupDir      = "APPDATA_BACKUP_DIR"      // Directory holding appdata backup archives
+	KeyAppdataBackupSchedule = "APPDATA_BACKUP_SCHEDULE" // OnCalendar= schedule of the appdata backup timer (empty = not scheduled)
 
 	// NFS configuration
-	KeyNFSServer         = "NFS_SERVER"
-	KeyNFSExport         = "NFS_EXPORT"
-	KeyNFSMountPoint     = "NFS_MOUNT_POINT"      // User-friendly mount point (may be symlink)
-	KeyNFSMountPointReal = "NFS_MOUNT_POINT_REAL" // Actual resolved mount point (for systemd)
-	KeyNFSMountOptions   = "NFS_MOUNT_OPTIONS"
-	KeyNFSMountCount     = "NFS_MOUNT_COUNT" // Number of NFS mounts configured (first mount uses keys above, additional use indexed keys)
+	KeyNFSServer          = "NFS_SERVER"
+	KeyNFSExport          = "NFS_EXPORT"
+	KeyNFSMountPoint      = "NFS_MOUNT_POINT"      // User-friendly mount point (may be symlink)
+	KeyNFSMountPointReal  = "NFS_MOUNT_POINT_REAL" // Actual resolved mount point (for systemd)
+	KeyNFSVersion         = "NFS_VERSION"          // NFS protocol version: 3, 4, 4.0, 4.1 or 4.2 (default 4.2)
+	KeyNFSMountOptions    = "NFS_MOUNT_OPTIONS"    // Comma-separated extra mount options (e.g. hard,noatime), checked against the known NFS options
+	KeyNFSMountCount      = "NFS_MOUNT_COUNT"      // Number of NFS mounts configured (first mount uses keys above, additional use indexed keys)
+	KeyNFSExpectedExports = "NFS_EXPECTED_EXPORTS" // Comma-separated export paths that must be exported to this host
 
 	// WireGuard configuration
-	KeyWGInterface   = "WG_INTERFACE"
-	KeyWGInterfaceIP = "WG_INTERFACE_IP"
-	KeyWGListenPort  = "WG_LISTEN_PORT"
-	KeyWGConfigPath  = "WG_CONFIG_PATH"
+	KeyWGInterface       = "WIREGUARD_INTERFACE"
+	KeyWGInterfaceIP     = "WG_INTERFACE_IP"
+	KeyWGListenPort      = "WG_LISTEN_PORT"
+	KeyWGConfigPath      = "WG_CONFIG_PATH"
+	KeyWGListenInterface = "WG_LISTEN_INTERFACE"  // Interface whose firewalld zone gets the listen port (empty = default zone)
+	KeyWGClientDNS       = "WIREGUARD_CLIENT_DNS" // Comma-separated DNS server IPs written to generated client configs (empty = no DNS line)
+	KeyWGKeepalive       = "WIREGUARD_KEEPALIVE"  // PersistentKeepalive seconds for generated peers (0 disables)
 
 	// Container configuration
-	KeyContainerRuntime   = "CONTAINER_RUNTIME"
-	KeySelectedServices   = "SELECTED_SERVICES"
-	KeyComposeProjectName = "COMPOSE_PROJECT_NAME"
-	KeyComposeCommand     = "COMPOSE_COMMAND" // Resolved compose command (e.g., "docker compose" or "docker-compose")
+	KeyContainerRuntime     = "CONTAINER_RUNTIME"
+	KeyContainerRuntimeMode = "CONTAINER_RUNTIME_MODE" // "rootful" or "rootless" (rootless Podman detected by preflight)
+	KeySelectedServices     = "SELECTED_SERVICES"
+	KeyServicesReview       = "SERVICES_REVIEW" // Stacks found running without being selected, recorded by the service drift check for review
+	KeyComposeProjectName   = "COMPOSE_PROJECT_NAME"
+	KeyComposeCommand       = "COMPOSE_COMMAND"  // Resolved compose command (e.g., "docker compose" or "docker-compose")
+	KeyBootEnabledPrefix    = "BOOT_ENABLED_"    // Per-stack "true"/"false" for starting the compose unit at boot, suffixed with the upper-case stack name (default true)
+	KeyComposeSHA256Prefix  = "COMPOSE_SHA256_"  // Expected SHA-256 of a stack's compose template, suffixed with the upper-case stack name (overrides the template directory's SHA256SUMS)
+	KeyBulkConcurrency      = "BULK_CONCURRENCY" // Stacks deployed, started, stopped or restarted at once by the "all services" operations (default 1)
+
+	// Media stack
+	KeyPlexClaimToken      = "PLEX_CLAIM_TOKEN"
+	KeyPlexClaimTokenSetAt = "PLEX_CLAIM_TOKEN_SET_AT" // RFC3339 time the claim token was entered (tokens expire within minutes)
+	KeyTranscodeDevice     = "TRANSCODE_DEVICE"        // Render node passed to Plex/Jellyfin (empty = software transcoding)
 
 	// Network configuration
-	KeyNetworkTestRetries = "NETWORK_TEST_RETRIES"
-	KeyNetworkTestTimeout = "NETWORK_TEST_TIMEOUT"
+	KeyNetworkTestRetries  = "NETWORK_TEST_RETRIES"
+	KeyNetworkTestTimeout  = "NETWORK_TEST_TIMEOUT"
+	KeyNetworkSource       = "NETWORK_SOURCE"        // Interface or address connectivity pings are sent from (empty = routing table)
+	KeyVPSHost             = "VPS_HOST"              // Public VPS fronting the homelab over WireGuard (used by diagnostics)
+	KeyPortScanConcurrency = "PORT_SCAN_CONCURRENCY" // Maximum simultaneous dials in the troubleshooting port scan
+	KeyPreflightSkip       = "PREFLIGHT_SKIP"        // Comma-separated preflight checks to skip: os, packages, runtime, sudo, user, group, disk, network, time, nfs, modules, ports, transcode
+	KeyNTPServer           = "NTP_SERVER"            // Server preflight compares the clock against (empty = rely on chronyd's tracking)
+	KeyClockSkewThreshold  = "CLOCK_SKEW_THRESHOLD"  // Seconds of clock offset above which preflight warns
+
+	// Completion state
+	KeyMarkerStorage = "COMPLETION_STATE_STORAGE" // "file" (marker files) or "config" (MARKER_* keys in this file)
+
+	// Command execution
+	KeyCommandTimeout      = "COMMAND_TIMEOUT"       // Seconds before systemctl/compose control commands are killed
+	KeyServiceStartTimeout = "SERVICE_START_TIMEOUT" // Seconds before service starts and image pulls are killed
+	KeyDryRun              = "DRY_RUN"               // "true" logs privileged actions instead of running them and writes no completion markers
+
+	// Health probes
+	KeyHealthPathPrefix    = "HEALTH_PATH_"             // Per-app health path override, suffixed with the upper-case app name (e.g. HEALTH_PATH_PLEX)
+	KeyHealthTimeout       = "HEALTH_TIMEOUT"           // Seconds to wait for a deployed stack's web UIs to become healthy
+	KeyHealthTimeoutPrefix = "HEALTH_TIMEOUT_"          // Per-stack override of HEALTH_TIMEOUT, suffixed with the upper-case stack name (e.g. HEALTH_TIMEOUT_CLOUD)
+	KeyPublicURLSuffix     = "_PUBLIC_URL"              // Public URL of an app behind the reverse proxy, prefixed with the upper-case app name (e.g. JELLYFIN_PUBLIC_URL)
+	KeyHealthCheckPublic   = "HEALTH_CHECK_PUBLIC_URLS" // "true" to also probe each app's public URL through the reverse proxy when verifying
+	KeyHealthTLSVerify     = "HEALTH_TLS_VERIFY"        // "false" to accept self-signed certificates when probing public URLs
+	KeyDashboardRefresh    = "DASHBOARD_REFRESH"        // Seconds between refreshes of the live dashboard (default 5)
 
 	// System configuration
 	KeyConfigVersion = "CONFIG_VERSION"
 )
 
+// SensitiveKeys lists keys whose values are always treated as secret, in
+// addition to any key annotated with "# @secret" in the config file
+var SensitiveKeys = []string{
+	"PLEX_CLAIM_TOKEN",
+	"OVERSEERR_API_KEY",
+	"NEXTCLOUD_ADMIN_PASSWORD",
+	"NEXTCLOUD_DB_PASSWORD",
+	"COLLABORA_PASSWORD",
+	"IMMICH_DB_PASSWORD",
+	"WIREGUARD_PRIVATE_KEY",
+}
+
+// isSensitiveKey reports whether key is in SensitiveKeys
+func isSensitiveKey(key string) bool {
+	for _, k := range SensitiveKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 // Default values for configuration keys
 var Defaults = map[string]string{
-	KeyContainersBase:     "/srv/containers",
-	KeyContainerRuntime:   "docker", // Docker is the default runtime (Podman also supported)
-	KeyNFSMountPoint:      "/mnt/nas",
-	KeyNetworkTestRetries: "5",
-	KeyNetworkTestTimeout: "10",
-	KeyConfigVersion:      "1",
-	KeyWGInterface:        "wg0",
-	KeyWGListenPort:       "51820",
+	KeyContainersBase:      "/srv/containers",
+	KeyAppdataBackupDir:    "/var/lib/containers/appdata-backups",
+	KeyContainerRuntime:    "docker", // Docker is the default runtime (Podman also supported)
+	KeyNFSMountPoint:       "/mnt/nas",
+	KeyNetworkTestRetries:  "5",
+	KeyNetworkTestTimeout:  "10",
+	KeyPortScanConcurrency: "64",
+	KeyBulkConcurrency:     "1",
+	KeyNTPServer:           "pool.ntp.org",
+	KeyClockSkewThreshold:  "5",
+	KeyCommandTimeout:      "120",
+	KeyServiceStartTimeout: "660",
+	KeyHealthTimeout:       "180",
+	KeyDashboardRefresh:    "5",
+	KeyConfigVersion:       "1",
+	KeyWGInterface:         "wg0",
+	KeyWGListenPort:        "51820",
+	KeyWGKeepalive:         "25",
 }
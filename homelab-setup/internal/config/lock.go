@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrConfigLocked is returned when another process holds the config file lock
+// for longer than lockTimeout
+var ErrConfigLocked = errors.New("another instance is modifying config")
+
+// lockTimeout bounds how long a writer waits for the config file lock
+var lockTimeout = 10 * time.Second
+
+// lockPollInterval is the pause between attempts to take the lock
+const lockPollInterval = 100 * time.Millisecond
+
+// fileLock is an advisory flock held on a lockfile next to the config file.
+// The kernel releases it when the file is closed, including on process exit.
+type fileLock struct {
+	file *os.File
+}
+
+// acquireFileLock takes an exclusive flock on path, creating it if needed,
+// and gives up with ErrConfigLocked after timeout. The file is opened
+// read-only, which is all flock needs, and a lockfile created by root is
+// handed to the owner of the config directory so a later run as that user
+// can still take the lock.
+func acquireFileLock(path string, timeout time.Duration) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock file: %w", err)
+	}
+	if err := matchDirOwner(file, filepath.Dir(path)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to set owner of config lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return &fileLock{file: file}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%w (waited %s for %s)", ErrConfigLocked, timeout, path)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// matchDirOwner gives file the owner and group of dir when they differ. Only
+// root can change them, so for anyone else it is a no-op.
+func matchDirOwner(file *os.File, dir string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	dirStat, ok := dirInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	fileStat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok || (fileStat.Uid == dirStat.Uid && fileStat.Gid == dirStat.Gid) {
+		return nil
+	}
+	return file.Chown(int(dirStat.Uid), int(dirStat.Gid))
+}
+
+// release unlocks and closes the lockfile. The lockfile itself is left in
+// place so concurrent lockers always contend on the same inode.
+func (l *fileLock) release() {
+	_ = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+// lockPath returns the lockfile guarding the config file
+func (c *Config) lockPath() string {
+	return c.filePath + ".lock"
+}
+
+// lockForWrite takes the config file lock and reloads the file so changes
+// saved by another instance since it was last read are not overwritten. The
+// caller must hold c.mu.Lock and call the returned release function when done.
+func (c *Config) lockForWrite() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(c.filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	lock, err := acquireFileLock(c.lockPath(), lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse into fresh maps and swap them in only once the read succeeded,
+	// so a failed reload leaves the values already in memory untouched
+	data, secrets, err := c.readFile()
+	if err != nil {
+		lock.release()
+		return nil, fmt.Errorf("failed to load existing config before write: %w", err)
+	}
+	c.data = data
+	c.secrets = secrets
+	c.loaded = true
+	return lock.release, nil
+}
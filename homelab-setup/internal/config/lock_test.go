@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAcquireFileLockTimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.lock")
+
+	first, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("first acquireFileLock() error = %v", err)
+	}
+
+	start := time.Now()
+	if _, err := acquireFileLock(path, 200*time.Millisecond); !errors.Is(err, ErrConfigLocked) {
+		t.Fatalf("second acquireFileLock() error = %v, want ErrConfigLocked", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("second locker gave up after %s, before the timeout", waited)
+	}
+
+	first.release()
+	second, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock() after release error = %v", err)
+	}
+	second.release()
+}
+
+func TestSetFailsWhileAnotherInstanceHoldsLock(t *testing.T) {
+	cfg := newTestConfig(t)
+	previous := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = previous })
+
+	lock, err := acquireFileLock(cfg.lockPath(), time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock() error = %v", err)
+	}
+	defer lock.release()
+
+	if err := cfg.Set("KEY", "value"); !errors.Is(err, ErrConfigLocked) {
+		t.Errorf("Set() error = %v, want ErrConfigLocked", err)
+	}
+}
+
+func TestConcurrentInstancesKeepEachOthersChanges(t *testing.T) {
+	first := newTestConfig(t)
+	second := New(first.FilePath())
+
+	// Both instances load the file before either writes
+	first.GetAll()
+	second.GetAll()
+
+	if err := first.Set("FIRST", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Set("SECOND", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := New(first.FilePath())
+	if got := reloaded.GetOrDefault("FIRST", ""); got != "1" {
+		t.Errorf("FIRST = %q, want 1 (overwritten by the second instance)", got)
+	}
+	if got := reloaded.GetOrDefault("SECOND", ""); got != "2" {
+		t.Errorf("SECOND = %q, want 2", got)
+	}
+}
+
+func TestFailedReloadKeepsLoadedValues(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.Set("KEEP", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another writer leaves a file this instance cannot parse
+	if err := os.WriteFile(cfg.FilePath(), []byte("\x00\x01"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("OTHER", "2"); !errors.Is(err, ErrConfigCorrupt) {
+		t.Fatalf("Set() error = %v, want ErrConfigCorrupt", err)
+	}
+	if got := cfg.GetOrDefault("KEEP", ""); got != "1" {
+		t.Errorf("KEEP = %q after a failed reload, want 1", got)
+	}
+}
+
+func TestLockFileBelongsToConfigDirOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner needs root")
+	}
+	dir := t.TempDir()
+	if err := os.Chown(dir, 4242, 4243); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.lock")
+
+	lock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock() error = %v", err)
+	}
+	lock.release()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != 4242 || stat.Gid != 4243 {
+		t.Errorf("lock file owned by %d:%d, want 4242:4243", stat.Uid, stat.Gid)
+	}
+}
+
+func TestAcquireFileLockWithReadOnlyLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.lock")
+	if err := os.WriteFile(path, nil, 0400); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock() on a read-only lock file error = %v", err)
+	}
+	lock.release()
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MarkerKeyPrefix is the reserved config key prefix for completion markers
+// stored inside the config file (e.g. MARKER_preflight-complete=<RFC3339 time>)
+const MarkerKeyPrefix = "MARKER_"
+
+// Marker storage backends for KeyMarkerStorage
+const (
+	MarkerStorageFile   = "file"
+	MarkerStorageConfig = "config"
+)
+
+// SetDryRun stops completion markers from being written while enabled, so a
+// dry run does not record steps as complete (thread-safe)
+func (c *Config) SetDryRun(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dryRun = enabled
+}
+
+// isDryRun reports whether SetDryRun is enabled
+func (c *Config) isDryRun() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dryRun
+}
+
+// MarkersInConfig reports whether new completion markers are written to the
+// config file instead of individual marker files
+func (c *Config) MarkersInConfig() bool {
+	return c.GetOrDefault(KeyMarkerStorage, MarkerStorageFile) == MarkerStorageConfig
+}
+
+// markCompleteInConfigIfNotExists stores a marker in the config file unless
+// it is already present in either backend
+func (c *Config) markCompleteInConfigIfNotExists(name string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(c.markerDir, name)); err == nil {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	release, err := c.lockForWrite()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	key := MarkerKeyPrefix + name
+	if _, exists := c.data[key]; exists {
+		return false, nil
+	}
+
+	c.data[key] = strings.TrimSpace(string(markerTimestamp()))
+	if err := c.save(); err != nil {
+		delete(c.data, key)
+		return false, err
+	}
+	return true, nil
+}
+
+// clearConfigMarkers removes every MARKER_ key from the config file
+func (c *Config) clearConfigMarkers() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	release, err := c.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	removed := false
+	for key := range c.data {
+		if strings.HasPrefix(key, MarkerKeyPrefix) {
+			delete(c.data, key)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return c.save()
+}
+
+// MigrateMarkersToConfig imports existing marker files into the config file
+// and removes the files once the config has been saved. Empty legacy markers
+// take the file's modification time as their completion time. Returns the
+// number of markers imported.
+func (c *Config) MigrateMarkersToConfig() (int, error) {
+	entries, err := os.ReadDir(c.markerDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read marker directory: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	release, err := c.lockForWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	var migrated []string
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == AuditLogName || validateMarkerName(entry.Name()) != nil {
+			continue
+		}
+
+		path := filepath.Join(c.markerDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read marker %s: %w", entry.Name(), err)
+		}
+
+		value := strings.TrimSpace(string(data))
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			info, statErr := entry.Info()
+			if statErr != nil {
+				return 0, fmt.Errorf("failed to stat marker %s: %w", entry.Name(), statErr)
+			}
+			value = info.ModTime().Format(time.RFC3339)
+		}
+
+		key := MarkerKeyPrefix + entry.Name()
+		if _, exists := c.data[key]; !exists {
+			c.data[key] = value
+		}
+		migrated = append(migrated, path)
+	}
+
+	if len(migrated) == 0 {
+		return 0, nil
+	}
+
+	if err := c.save(); err != nil {
+		return 0, fmt.Errorf("failed to save migrated markers: %w", err)
+	}
+
+	for _, path := range migrated {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return len(migrated), fmt.Errorf("markers migrated but failed to remove %s: %w", path, err)
+		}
+	}
+
+	return len(migrated), nil
+}
+
+// LegacyMarkerNames maps each canonical completion marker to the names the
+// bash scripts and earlier Go releases recorded for the same step
+var LegacyMarkerNames = map[string][]string{
+	"user-setup-complete":         {"user-configured"},
+	"directory-setup-complete":    {"directories-created"},
+	"wireguard-setup-complete":    {"wireguard-configured", "wireguard-skipped"},
+	"nfs-setup-complete":          {"nfs-configured", "nfs-skipped"},
+	"service-deployment-complete": {"deployment-complete"},
+}
+
+// CanonicalizeMarker reports whether the canonical marker, or any of the legacy
+// names for the same step, is complete. A legacy marker is migrated to the
+// canonical name so later checks take the fast path.
+//
+// Safe to call concurrently from multiple processes: the canonical marker is
+// created with MarkCompleteIfNotExists, and only the caller that created it
+// removes the legacy marker (best-effort, errors ignored). A caller that loses
+// the race still returns (true, nil).
+func (c *Config) CanonicalizeMarker(canonical string, legacy ...string) (bool, error) {
+	// Fast path for steps completed under the current name
+	if c.IsComplete(canonical) {
+		return true, nil
+	}
+
+	for _, legacyName := range legacy {
+		if legacyName == "" || legacyName == canonical || !c.IsComplete(legacyName) {
+			continue
+		}
+
+		wasCreated, err := c.MarkCompleteIfNotExists(canonical)
+		if err != nil {
+			return false, err
+		}
+		if wasCreated {
+			_ = c.ClearMarker(legacyName)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// CanonicalizeLegacyMarkers migrates every marker in LegacyMarkerNames to its
+// canonical name, so completion status is correct before any step runs.
+// Returns the number of steps migrated.
+func (c *Config) CanonicalizeLegacyMarkers() (int, error) {
+	migrated := 0
+	for canonical, legacy := range LegacyMarkerNames {
+		if c.IsComplete(canonical) {
+			continue
+		}
+		found, err := c.CanonicalizeMarker(canonical, legacy...)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to migrate legacy marker for %s: %w", canonical, err)
+		}
+		if found {
+			migrated++
+		}
+	}
+	return migrated, nil
+}
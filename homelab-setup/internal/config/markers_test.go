@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestConfig returns a config whose file and marker directory live under
+// a temporary HOME
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_DATA_HOME"} {
+		t.Setenv(env, "")
+	}
+	return New(filepath.Join(home, ".homelab-setup.conf"))
+}
+
+func TestCanonicalizeMarkerMigratesLegacy(t *testing.T) {
+	for _, storage := range []string{MarkerStorageFile, MarkerStorageConfig} {
+		t.Run(storage, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			if err := cfg.Set(KeyMarkerStorage, storage); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.MarkComplete("directories-created"); err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := cfg.CanonicalizeMarker("directory-setup-complete", "directories-created")
+			if err != nil {
+				t.Fatalf("CanonicalizeMarker() error = %v", err)
+			}
+			if !found {
+				t.Fatal("expected legacy marker to be found")
+			}
+			if !cfg.IsComplete("directory-setup-complete") {
+				t.Error("expected canonical marker to be created")
+			}
+			if cfg.IsComplete("directories-created") {
+				t.Error("expected legacy marker to be removed")
+			}
+		})
+	}
+}
+
+func TestCanonicalizeMarkerKeepsLegacyWhenCanonicalExists(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.MarkComplete("nfs-setup-complete"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.MarkComplete("nfs-configured"); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := cfg.CanonicalizeMarker("nfs-setup-complete", "nfs-configured")
+	if err != nil || !found {
+		t.Fatalf("CanonicalizeMarker() = (%v, %v), want (true, nil)", found, err)
+	}
+	if !cfg.IsComplete("nfs-configured") {
+		t.Error("legacy marker should be left alone when the canonical marker already exists")
+	}
+}
+
+func TestCanonicalizeMarkerNotComplete(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	found, err := cfg.CanonicalizeMarker("nfs-setup-complete", "nfs-configured", "nfs-skipped")
+	if err != nil || found {
+		t.Fatalf("CanonicalizeMarker() = (%v, %v), want (false, nil)", found, err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.MarkerDir(), "nfs-setup-complete")); !os.IsNotExist(err) {
+		t.Error("canonical marker should not be created without a legacy marker")
+	}
+}
+
+func TestCanonicalizeLegacyMarkers(t *testing.T) {
+	cfg := newTestConfig(t)
+	for _, legacy := range []string{"user-configured", "wireguard-skipped", "deployment-complete"} {
+		if err := cfg.MarkComplete(legacy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrated, err := cfg.CanonicalizeLegacyMarkers()
+	if err != nil {
+		t.Fatalf("CanonicalizeLegacyMarkers() error = %v", err)
+	}
+	if migrated != 3 {
+		t.Errorf("CanonicalizeLegacyMarkers() = %d, want 3", migrated)
+	}
+	for _, canonical := range []string{"user-setup-complete", "wireguard-setup-complete", "service-deployment-complete"} {
+		if !cfg.IsComplete(canonical) {
+			t.Errorf("expected %s to be complete", canonical)
+		}
+	}
+	if cfg.IsComplete("directory-setup-complete") || cfg.IsComplete("nfs-setup-complete") {
+		t.Error("steps without legacy markers should stay incomplete")
+	}
+
+	// A second run has nothing left to migrate
+	if migrated, err := cfg.CanonicalizeLegacyMarkers(); err != nil || migrated != 0 {
+		t.Errorf("second CanonicalizeLegacyMarkers() = (%d, %v), want (0, nil)", migrated, err)
+	}
+}
+
+func TestDryRunSkipsMarkers(t *testing.T) {
+	for _, storage := range []string{MarkerStorageFile, MarkerStorageConfig} {
+		t.Run(storage, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			if err := cfg.Set(KeyMarkerStorage, storage); err != nil {
+				t.Fatal(err)
+			}
+			cfg.SetDryRun(true)
+
+			if err := cfg.MarkComplete("user-setup-complete"); err != nil {
+				t.Fatalf("MarkComplete() error = %v", err)
+			}
+			created, err := cfg.MarkCompleteIfNotExists("preflight-complete")
+			if err != nil || !created {
+				t.Fatalf("MarkCompleteIfNotExists() = (%v, %v), want (true, nil)", created, err)
+			}
+			for _, name := range []string{"user-setup-complete", "preflight-complete"} {
+				if cfg.IsComplete(name) {
+					t.Errorf("IsComplete(%s) = true after a dry run", name)
+				}
+			}
+		})
+	}
+}
+
+func TestClearAllMarkersKeepsAuditLog(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.MarkComplete("preflight-complete"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.AuditLogPath(), []byte("sudo true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	markers, err := cfg.ListMarkers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, marker := range markers {
+		if marker == AuditLogName {
+			t.Errorf("ListMarkers() = %v, should not list the audit log", markers)
+		}
+	}
+
+	if err := cfg.ClearAllMarkers(); err != nil {
+		t.Fatalf("ClearAllMarkers() error = %v", err)
+	}
+	if cfg.IsComplete("preflight-complete") {
+		t.Error("marker still present after ClearAllMarkers()")
+	}
+	if _, err := os.Stat(cfg.AuditLogPath()); err != nil {
+		t.Errorf("ClearAllMarkers() removed the audit log: %v", err)
+	}
+}
+
+func TestMigrateMarkersToConfigKeepsAuditLog(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.MarkComplete("preflight-complete"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.AuditLogPath(), []byte("sudo true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := cfg.MigrateMarkersToConfig()
+	if err != nil {
+		t.Fatalf("MigrateMarkersToConfig() error = %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("MigrateMarkersToConfig() = %d, want 1", migrated)
+	}
+	if cfg.Exists(MarkerKeyPrefix + AuditLogName) {
+		t.Error("MigrateMarkersToConfig() imported the audit log as a marker")
+	}
+	if content, err := os.ReadFile(cfg.AuditLogPath()); err != nil || string(content) != "sudo true\n" {
+		t.Errorf("audit log after migration = %q, %v; want it untouched", content, err)
+	}
+}
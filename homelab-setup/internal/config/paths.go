@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// appDirName is the directory name used under the XDG base directories
+const appDirName = "homelab-setup"
+
+// homeDir returns the user's home directory, falling back to the CoreOS
+// default user's home
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "/var/home/core"
+	}
+	return home
+}
+
+// xdgDir returns $<envVar>/homelab-setup when envVar holds an absolute path.
+// Relative values are ignored, as the XDG base directory spec requires.
+func xdgDir(envVar string) (string, bool) {
+	base := os.Getenv(envVar)
+	if base == "" || !filepath.IsAbs(base) {
+		return "", false
+	}
+	return filepath.Join(base, appDirName), true
+}
+
+// exists reports whether path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// DefaultConfigPath returns the config file used when no path is given:
+// $XDG_CONFIG_HOME/homelab-setup/homelab-setup.conf when XDG_CONFIG_HOME is
+// set, otherwise ~/.homelab-setup.conf. An existing ~/.homelab-setup.conf is
+// kept in use until a file exists at the XDG location.
+func DefaultConfigPath() string {
+	legacy := filepath.Join(homeDir(), ".homelab-setup.conf")
+	dir, ok := xdgDir("XDG_CONFIG_HOME")
+	if !ok {
+		return legacy
+	}
+	path := filepath.Join(dir, "homelab-setup.conf")
+	if !exists(path) && exists(legacy) {
+		return legacy
+	}
+	return path
+}
+
+// DefaultStateDir returns the directory for completion markers and other
+// state: $XDG_STATE_HOME/homelab-setup, then $XDG_DATA_HOME/homelab-setup,
+// otherwise ~/.local/homelab-setup. An existing ~/.local/homelab-setup is kept
+// in use until the XDG directory exists.
+func DefaultStateDir() string {
+	legacy := filepath.Join(homeDir(), ".local", appDirName)
+	dir, ok := xdgDir("XDG_STATE_HOME")
+	if !ok {
+		dir, ok = xdgDir("XDG_DATA_HOME")
+	}
+	if !ok {
+		return legacy
+	}
+	if !exists(dir) && exists(legacy) {
+		return legacy
+	}
+	return dir
+}
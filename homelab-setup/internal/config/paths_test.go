@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setXDG points HOME at a temporary directory and sets the XDG variables,
+// returning the home directory
+func setXDG(t *testing.T, configHome, stateHome, dataHome string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_STATE_HOME", stateHome)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	return home
+}
+
+func TestDefaultPathsWithoutXDG(t *testing.T) {
+	home := setXDG(t, "", "", "")
+
+	if got, want := DefaultConfigPath(), filepath.Join(home, ".homelab-setup.conf"); got != want {
+		t.Errorf("DefaultConfigPath() = %q, want %q", got, want)
+	}
+	if got, want := DefaultStateDir(), filepath.Join(home, ".local", "homelab-setup"); got != want {
+		t.Errorf("DefaultStateDir() = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultPathsWithXDG(t *testing.T) {
+	xdg := t.TempDir()
+	setXDG(t, filepath.Join(xdg, "config"), filepath.Join(xdg, "state"), filepath.Join(xdg, "data"))
+
+	if got, want := DefaultConfigPath(), filepath.Join(xdg, "config", "homelab-setup", "homelab-setup.conf"); got != want {
+		t.Errorf("DefaultConfigPath() = %q, want %q", got, want)
+	}
+	if got, want := DefaultStateDir(), filepath.Join(xdg, "state", "homelab-setup"); got != want {
+		t.Errorf("DefaultStateDir() = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultStateDirFallsBackToDataHome(t *testing.T) {
+	xdg := t.TempDir()
+	setXDG(t, "", "", filepath.Join(xdg, "data"))
+
+	if got, want := DefaultStateDir(), filepath.Join(xdg, "data", "homelab-setup"); got != want {
+		t.Errorf("DefaultStateDir() = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultPathsIgnoreRelativeXDG(t *testing.T) {
+	home := setXDG(t, "relative/config", "relative/state", "")
+
+	if got, want := DefaultConfigPath(), filepath.Join(home, ".homelab-setup.conf"); got != want {
+		t.Errorf("DefaultConfigPath() = %q, want %q", got, want)
+	}
+	if got, want := DefaultStateDir(), filepath.Join(home, ".local", "homelab-setup"); got != want {
+		t.Errorf("DefaultStateDir() = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultPathsKeepExistingLegacyFiles(t *testing.T) {
+	xdg := t.TempDir()
+	home := setXDG(t, filepath.Join(xdg, "config"), filepath.Join(xdg, "state"), "")
+
+	legacyConfig := filepath.Join(home, ".homelab-setup.conf")
+	if err := os.WriteFile(legacyConfig, []byte("KEY=value\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	legacyState := filepath.Join(home, ".local", "homelab-setup")
+	if err := os.MkdirAll(legacyState, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := DefaultConfigPath(); got != legacyConfig {
+		t.Errorf("DefaultConfigPath() = %q, want existing %q", got, legacyConfig)
+	}
+	if got := DefaultStateDir(); got != legacyState {
+		t.Errorf("DefaultStateDir() = %q, want existing %q", got, legacyState)
+	}
+}
+
+func TestNewUsesExplicitPath(t *testing.T) {
+	xdg := t.TempDir()
+	setXDG(t, filepath.Join(xdg, "config"), filepath.Join(xdg, "state"), "")
+
+	path := filepath.Join(t.TempDir(), "custom.conf")
+	cfg := New(path)
+	if cfg.FilePath() != path {
+		t.Errorf("FilePath() = %q, want %q", cfg.FilePath(), path)
+	}
+	if got, want := cfg.MarkerDir(), filepath.Join(xdg, "state", "homelab-setup"); got != want {
+		t.Errorf("MarkerDir() = %q, want %q", got, want)
+	}
+}
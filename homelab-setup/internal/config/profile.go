@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// ProfileEnv is the environment variable that selects the profile when
+// --profile is not given
+const ProfileEnv = "HOMELAB_PROFILE"
+
+// DefaultProfile is the profile that keeps the config file and markers at
+// their historical locations (DefaultConfigPath and DefaultStateDir)
+const DefaultProfile = "default"
+
+// profilesDirName is the directory under DefaultStateDir holding one
+// directory per named profile
+const profilesDirName = "profiles"
+
+// profileNamePattern limits profile names to something safe to use as a
+// directory name
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+var (
+	profileMu     sync.RWMutex
+	activeProfile = DefaultProfile
+)
+
+// ValidateProfileName checks that name can be used as a profile name
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 64 letters, digits, '-' or '_', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// SetProfile selects the profile used by New. An empty name selects
+// DefaultProfile.
+func SetProfile(name string) error {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	activeProfile = name
+	return nil
+}
+
+// ActiveProfile returns the profile selected with SetProfile
+func ActiveProfile() string {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	return activeProfile
+}
+
+// ProfileDir returns the directory holding the config file and markers of
+// profile: DefaultStateDir for the default profile, otherwise
+// DefaultStateDir/profiles/<profile>
+func ProfileDir(profile string) string {
+	if profile == DefaultProfile {
+		return DefaultStateDir()
+	}
+	return filepath.Join(DefaultStateDir(), profilesDirName, profile)
+}
+
+// ProfileConfigPath returns the config file of profile. The default profile
+// uses DefaultConfigPath.
+func ProfileConfigPath(profile string) string {
+	if profile == DefaultProfile {
+		return DefaultConfigPath()
+	}
+	return filepath.Join(ProfileDir(profile), "homelab-setup.conf")
+}
+
+// ProfileMarkerDir returns the completion marker directory of profile. Named
+// profiles keep markers in a subdirectory so the config file is not mistaken
+// for a marker.
+func ProfileMarkerDir(profile string) string {
+	if profile == DefaultProfile {
+		return DefaultStateDir()
+	}
+	return filepath.Join(ProfileDir(profile), "markers")
+}
+
+// ListProfiles returns the default profile followed by the named profiles
+// that exist on disk, sorted by name
+func ListProfiles() ([]string, error) {
+	profiles := []string{DefaultProfile}
+
+	entries, err := os.ReadDir(filepath.Join(DefaultStateDir(), profilesDirName))
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+
+	var named []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultProfile && ValidateProfileName(entry.Name()) == nil {
+			named = append(named, entry.Name())
+		}
+	}
+	sort.Strings(named)
+	return append(profiles, named...), nil
+}
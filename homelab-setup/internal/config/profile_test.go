@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useProfile selects profile for the rest of the test
+func useProfile(t *testing.T, profile string) {
+	t.Helper()
+	if err := SetProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetProfile(DefaultProfile) })
+}
+
+func TestProfilePaths(t *testing.T) {
+	home := setXDG(t, "", "", "")
+	state := filepath.Join(home, ".local", "homelab-setup")
+
+	tests := []struct {
+		profile    string
+		configPath string
+		markerDir  string
+	}{
+		{DefaultProfile, filepath.Join(home, ".homelab-setup.conf"), state},
+		{"media", filepath.Join(state, "profiles", "media", "homelab-setup.conf"), filepath.Join(state, "profiles", "media", "markers")},
+	}
+	for _, tt := range tests {
+		if got := ProfileConfigPath(tt.profile); got != tt.configPath {
+			t.Errorf("ProfileConfigPath(%q) = %q, want %q", tt.profile, got, tt.configPath)
+		}
+		if got := ProfileMarkerDir(tt.profile); got != tt.markerDir {
+			t.Errorf("ProfileMarkerDir(%q) = %q, want %q", tt.profile, got, tt.markerDir)
+		}
+
+		useProfile(t, tt.profile)
+		cfg := New("")
+		if cfg.FilePath() != tt.configPath || cfg.MarkerDir() != tt.markerDir {
+			t.Errorf("New() with profile %q uses %q and %q, want %q and %q",
+				tt.profile, cfg.FilePath(), cfg.MarkerDir(), tt.configPath, tt.markerDir)
+		}
+	}
+}
+
+func TestProfilePathsWithXDG(t *testing.T) {
+	xdg := t.TempDir()
+	setXDG(t, filepath.Join(xdg, "config"), filepath.Join(xdg, "state"), "")
+
+	if got, want := ProfileConfigPath("backup"), filepath.Join(xdg, "state", "homelab-setup", "profiles", "backup", "homelab-setup.conf"); got != want {
+		t.Errorf("ProfileConfigPath() = %q, want %q", got, want)
+	}
+
+	// An explicit path overrides the profile's config file but not its markers
+	useProfile(t, "backup")
+	path := filepath.Join(t.TempDir(), "custom.conf")
+	cfg := New(path)
+	if cfg.FilePath() != path {
+		t.Errorf("FilePath() = %q, want %q", cfg.FilePath(), path)
+	}
+	if got, want := cfg.MarkerDir(), filepath.Join(xdg, "state", "homelab-setup", "profiles", "backup", "markers"); got != want {
+		t.Errorf("MarkerDir() = %q, want %q", got, want)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	useProfile(t, "media")
+	if err := SetProfile(""); err != nil {
+		t.Fatalf("SetProfile(\"\") error = %v", err)
+	}
+	if got := ActiveProfile(); got != DefaultProfile {
+		t.Errorf("ActiveProfile() after SetProfile(\"\") = %q, want %q", got, DefaultProfile)
+	}
+
+	for _, name := range []string{"../etc", "a/b", ".hidden", "-x", "with space"} {
+		if err := SetProfile(name); err == nil {
+			t.Errorf("SetProfile(%q) succeeded, want an error", name)
+		}
+	}
+	if got := ActiveProfile(); got != DefaultProfile {
+		t.Errorf("ActiveProfile() after invalid names = %q, want %q", got, DefaultProfile)
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	home := setXDG(t, "", "", "")
+
+	got, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{DefaultProfile}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListProfiles() = %v, want %v", got, want)
+	}
+
+	profiles := filepath.Join(home, ".local", "homelab-setup", "profiles")
+	for _, name := range []string{"media", "backup", ".tmp"} {
+		if err := os.MkdirAll(filepath.Join(profiles, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{DefaultProfile, "backup", "media"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListProfiles() = %v, want %v", got, want)
+	}
+}
+
+func TestClearAllMarkersKeepsProfiles(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.MarkComplete("preflight-complete"); err != nil {
+		t.Fatal(err)
+	}
+	named := filepath.Join(ProfileDir("media"), "homelab-setup.conf")
+	if err := os.MkdirAll(filepath.Dir(named), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(named, []byte("TZ=UTC\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.ClearAllMarkers(); err != nil {
+		t.Fatalf("ClearAllMarkers() error = %v", err)
+	}
+	if cfg.IsComplete("preflight-complete") {
+		t.Error("marker still present after ClearAllMarkers()")
+	}
+	if _, err := os.Stat(named); err != nil {
+		t.Errorf("ClearAllMarkers() removed another profile's config: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigSecretAnnotations(t *testing.T) {
+	content := strings.Join([]string{
+		"# @secret",
+		"PLEX_CLAIM_TOKEN=claim-abc",
+		"HOMELAB_USER=core",
+		"# @secret",
+		"",
+		"TZ=UTC",
+		"# @secret",
+		"# explains the next key",
+		"NFS_SERVER=192.168.1.10",
+		"# @secret",
+		"not a key value line",
+		"NFS_EXPORT=/mnt/tank",
+		"#@secret",
+		"API_KEY=abc123",
+	}, "\n")
+
+	data, secrets, err := parseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if data["PLEX_CLAIM_TOKEN"] != "claim-abc" {
+		t.Errorf("PLEX_CLAIM_TOKEN = %q", data["PLEX_CLAIM_TOKEN"])
+	}
+	// Only the line right after an annotation is secret
+	want := map[string]bool{"PLEX_CLAIM_TOKEN": true, "API_KEY": true}
+	for key := range data {
+		if secrets[key] != want[key] {
+			t.Errorf("secret[%s] = %v, want %v", key, secrets[key], want[key])
+		}
+	}
+}
+
+func TestSetSecretRoundTrip(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.Set("HOMELAB_USER", "core"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSecret("NEXTCLOUD_DB_PASSWORD", "hunter22"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSecret("CUSTOM_TOKEN", "tok-123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(cfg.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), SecretAnnotation+"\nCUSTOM_TOKEN=tok-123456\n") {
+		t.Errorf("annotation not written before CUSTOM_TOKEN:\n%s", raw)
+	}
+
+	// A fresh instance reads the annotation back
+	reloaded := New(cfg.FilePath())
+	if !reloaded.IsSecret("CUSTOM_TOKEN") {
+		t.Error("CUSTOM_TOKEN not secret after reload")
+	}
+	if reloaded.IsSecret("HOMELAB_USER") {
+		t.Error("HOMELAB_USER must not be secret")
+	}
+
+	redacted := reloaded.GetAllRedacted()
+	want := map[string]string{
+		"HOMELAB_USER":          "core",
+		"NEXTCLOUD_DB_PASSWORD": RedactedValue, // listed in SensitiveKeys
+		"CUSTOM_TOKEN":          RedactedValue,
+	}
+	for key, value := range want {
+		if redacted[key] != value {
+			t.Errorf("GetAllRedacted()[%s] = %q, want %q", key, redacted[key], value)
+		}
+	}
+
+	values := reloaded.SecretValues()
+	if len(values) != 2 {
+		t.Errorf("SecretValues() = %v, want the two secret values", values)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ErrWriteNotVerified is returned by Set, SetSecret, SetMany and Delete in
+// verified-save mode when the saved file does not hold what was written
+var ErrWriteNotVerified = errors.New("config write not verified")
+
+// readSavedConfig reads the config file back after a save. Tests replace it
+// to simulate a write that did not stick.
+var readSavedConfig = os.ReadFile
+
+// SetVerifyWrites enables verified-save mode: after each Set, SetSecret,
+// SetMany or Delete the config file is read back and the changed keys
+// checked, at the cost of an extra read per write (thread-safe)
+func (c *Config) SetVerifyWrites(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verifyWrites = enabled
+}
+
+// verifySaved re-reads the config file and checks that each key in values
+// holds its value and each key in removed is gone. The caller must hold
+// c.mu.Lock.
+func (c *Config) verifySaved(values map[string]string, removed ...string) error {
+	content, err := readSavedConfig(c.filePath)
+	if err != nil {
+		return fmt.Errorf("%w: failed to re-read %s: %v", ErrWriteNotVerified, c.filePath, err)
+	}
+	data, _, err := parseConfig(content)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrWriteNotVerified, c.filePath, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		saved, exists := data[key]
+		if !exists {
+			return fmt.Errorf("%w: %s is missing from %s", ErrWriteNotVerified, key, c.filePath)
+		}
+		if saved != values[key] {
+			return fmt.Errorf("%w: %s in %s does not hold the written value", ErrWriteNotVerified, key, c.filePath)
+		}
+	}
+	for _, key := range removed {
+		if _, exists := data[key]; exists {
+			return fmt.Errorf("%w: %s is still in %s after it was deleted", ErrWriteNotVerified, key, c.filePath)
+		}
+	}
+	return nil
+}
+
+// saveKey saves the config after key was changed in memory, verifying the
+// write if enabled. On failure the previous in-memory value is restored so
+// memory does not claim a value the file may not hold. The caller must hold
+// c.mu.Lock and the config file lock.
+func (c *Config) saveKey(key, previous string, existed, wasSecret bool) error {
+	err := c.save()
+	if err == nil && c.verifyWrites {
+		err = c.verifySaved(map[string]string{key: c.data[key]})
+	}
+	if err == nil {
+		return nil
+	}
+
+	if existed {
+		c.data[key] = previous
+	} else {
+		delete(c.data, key)
+	}
+	if !wasSecret {
+		delete(c.secrets, key)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSetVerifyWrites(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SetVerifyWrites(true)
+	if err := cfg.Set("TZ", "UTC"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Simulate storage that drops the write: the file read back still holds
+	// the old value
+	readSavedConfig = func(path string) ([]byte, error) {
+		content, err := os.ReadFile(path)
+		return bytes.ReplaceAll(content, []byte("TZ=America/Chicago"), []byte("TZ=UTC")), err
+	}
+	t.Cleanup(func() { readSavedConfig = os.ReadFile })
+
+	err := cfg.Set("TZ", "America/Chicago")
+	if !errors.Is(err, ErrWriteNotVerified) {
+		t.Fatalf("Set() error = %v, want ErrWriteNotVerified", err)
+	}
+	if got := cfg.GetOrDefault("TZ", ""); got != "UTC" {
+		t.Errorf("TZ after failed verification = %q, want the prior value %q", got, "UTC")
+	}
+
+	// A new key that does not appear in the re-read file is removed again
+	readSavedConfig = func(path string) ([]byte, error) {
+		content, err := os.ReadFile(path)
+		return bytes.ReplaceAll(content, []byte("HOMELAB_USER=core\n"), nil), err
+	}
+	if err := cfg.Set("HOMELAB_USER", "core"); !errors.Is(err, ErrWriteNotVerified) {
+		t.Fatalf("Set() error = %v, want ErrWriteNotVerified", err)
+	}
+	if cfg.Exists("HOMELAB_USER") {
+		t.Error("HOMELAB_USER kept in memory after failed verification")
+	}
+
+	// Without verification the mismatch goes unnoticed
+	cfg.SetVerifyWrites(false)
+	if err := cfg.Set("HOMELAB_USER", "core"); err != nil {
+		t.Errorf("Set() without verification error = %v", err)
+	}
+}
+
+func TestSetManyAndDeleteVerifyWrites(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SetVerifyWrites(true)
+	if err := cfg.SetMany(map[string]string{"TZ": "UTC", "PUID": "1000"}); err != nil {
+		t.Fatalf("SetMany() error = %v", err)
+	}
+	t.Cleanup(func() { readSavedConfig = os.ReadFile })
+
+	// One of the keys does not stick
+	readSavedConfig = func(path string) ([]byte, error) {
+		content, err := os.ReadFile(path)
+		return bytes.ReplaceAll(content, []byte("PUID=1001"), []byte("PUID=1000")), err
+	}
+	err := cfg.SetMany(map[string]string{"TZ": "America/Chicago", "PUID": "1001"})
+	if !errors.Is(err, ErrWriteNotVerified) {
+		t.Fatalf("SetMany() error = %v, want ErrWriteNotVerified", err)
+	}
+	if got := cfg.GetOrDefault("PUID", ""); got != "1000" {
+		t.Errorf("PUID after failed verification = %q, want the prior value 1000", got)
+	}
+	if got := cfg.GetOrDefault("TZ", ""); got != "UTC" {
+		t.Errorf("TZ after failed verification = %q, want the prior value UTC", got)
+	}
+
+	// The deleted key is still in the file read back
+	readSavedConfig = func(path string) ([]byte, error) {
+		content, err := os.ReadFile(path)
+		return append(content, []byte("TZ=UTC\n")...), err
+	}
+	if err := cfg.Delete("TZ"); !errors.Is(err, ErrWriteNotVerified) {
+		t.Fatalf("Delete() error = %v, want ErrWriteNotVerified", err)
+	}
+	if !cfg.Exists("TZ") {
+		t.Error("TZ removed from memory after failed delete verification")
+	}
+
+	readSavedConfig = os.ReadFile
+	if err := cfg.Delete("TZ"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+}
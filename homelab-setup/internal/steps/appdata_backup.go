@@ -0,0 +1,390 @@
+package steps
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// appdataBackupTimeFormat is the timestamp embedded in backup archive names
+const appdataBackupTimeFormat = "20060102-150405"
+
+// appdataBackupDir returns the directory backup archives are written to
+func appdataBackupDir(cfg *config.Config) string {
+	return cfg.GetOrDefault(config.KeyAppdataBackupDir, config.Defaults[config.KeyAppdataBackupDir])
+}
+
+// appdataBackupName returns the archive name for a stack's backup taken at t
+func appdataBackupName(serviceName string, t time.Time) string {
+	return fmt.Sprintf("%s-appdata-%s.tar.gz", serviceName, t.Format(appdataBackupTimeFormat))
+}
+
+// stackAppdataPaths returns the existing appdata directories of a stack,
+// honouring APPDATA_PATH_<APP> overrides
+func stackAppdataPaths(cfg *config.Config, serviceName string) ([]string, error) {
+	apps, ok := stackAppdataDirs[serviceName]
+	if !ok {
+		return nil, fmt.Errorf("unknown service: %s", serviceName)
+	}
+
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata")
+	var paths []string
+	for _, app := range apps {
+		path, _, err := appdataPath(cfg, appdataBase, app)
+		if err != nil {
+			return nil, err
+		}
+		if exists, _ := directoryFS.DirectoryExists(path); exists {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// listAppdataBackups returns a stack's backup archives, newest first
+func listAppdataBackups(cfg *config.Config, serviceName string) ([]string, error) {
+	dir := appdataBackupDir(cfg)
+	entries, err := directoryFS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory %s: %w", dir, err)
+	}
+
+	prefix := serviceName + "-appdata-"
+	var archives []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ".tar.gz") {
+			archives = append(archives, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// The timestamp format sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(archives)))
+	return archives, nil
+}
+
+// checkBackupSpace fails when the backup directory's filesystem has less free
+// space than the uncompressed size of the data, the worst case for the archive
+func checkBackupSpace(backupDir string, needed int64) error {
+	_, _, free, err := system.GetDiskUsage(backupDir)
+	if err != nil {
+		return fmt.Errorf("failed to check free space in %s: %w", backupDir, err)
+	}
+	if uint64(needed) > free {
+		return fmt.Errorf("not enough free space in %s: appdata is %s but only %s is free",
+			backupDir, system.FormatBytes(needed), system.FormatBytes(int64(free)))
+	}
+	return nil
+}
+
+// BackupAppdata archives a stack's appdata directories to a timestamped
+// .tar.gz in APPDATA_BACKUP_DIR and returns the archive path. With
+// stopService the stack is stopped during the copy, so databases are
+// consistent, and started again afterwards if it was running.
+func BackupAppdata(cfg *config.Config, ui *ui.UI, serviceName string, stopService bool) (string, error) {
+	paths, err := stackAppdataPaths(cfg, serviceName)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no appdata directories found for %s", serviceName)
+	}
+
+	size, err := system.DirectorySize(paths...)
+	if err != nil {
+		return "", err
+	}
+	ui.Infof("Appdata for %s: %s in %d directories", serviceName, system.FormatBytes(size), len(paths))
+
+	backupDir := appdataBackupDir(cfg)
+	// Listable by the homelab user; the archives themselves are root-only
+	if err := system.EnsureDirectory(backupDir, "root:root", 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory %s: %w", backupDir, err)
+	}
+	if err := checkBackupSpace(backupDir, size); err != nil {
+		return "", err
+	}
+
+	if stopService {
+		unit := getServiceInfo(cfg, serviceName).UnitName
+		if active, _ := system.IsServiceActive(unit); active {
+			if err := StopService(cfg, ui, serviceName); err != nil {
+				return "", fmt.Errorf("failed to stop %s for backup: %w", serviceName, err)
+			}
+			defer func() {
+				if err := StartService(cfg, ui, serviceName); err != nil {
+					ui.Warningf("Failed to start %s after backup: %v", serviceName, err)
+				}
+			}()
+		}
+	}
+
+	archive := filepath.Join(backupDir, appdataBackupName(serviceName, time.Now()))
+	spinner := ui.Spinner(fmt.Sprintf("Backing up %s appdata...", serviceName))
+	spinner.Start()
+	if err := system.ArchiveDirectories(archive, paths); err != nil {
+		spinner.Fail("Backup failed")
+		// Do not leave a truncated archive that looks restorable
+		_ = system.RemoveFile(archive)
+		return "", err
+	}
+
+	spinner.Success(fmt.Sprintf("Backed up %s appdata to %s", serviceName, archive))
+
+	if archiveSize, err := system.GetFileSize(archive); err == nil {
+		ui.Infof("Archive size: %s (from %s of appdata)", system.FormatBytes(archiveSize), system.FormatBytes(size))
+	}
+	return archive, nil
+}
+
+// withinDir reports whether path is dir or inside it
+func withinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// checkArchiveEntries ensures every member of an archive, and every link
+// target, stays inside the stack's own appdata directories, guarding against
+// path traversal. It returns the appdata directories the archive restores.
+func checkArchiveEntries(cfg *config.Config, serviceName string, entries []system.ArchiveEntry) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("archive is empty")
+	}
+
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata")
+	var allowed []string
+	for _, app := range stackAppdataDirs[serviceName] {
+		path, _, err := appdataPath(cfg, appdataBase, app)
+		if err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, path)
+	}
+	rootOf := func(path string) string {
+		for _, dir := range allowed {
+			if withinDir(path, dir) {
+				return dir
+			}
+		}
+		return ""
+	}
+
+	var roots []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for _, part := range strings.Split(entry.Name, "/") {
+			if part == ".." {
+				return nil, fmt.Errorf("member %q escapes its directory", entry.Name)
+			}
+		}
+		root := rootOf(entry.Path)
+		if root == "" {
+			return nil, fmt.Errorf("member %q is not inside an appdata directory of %s", entry.Name, serviceName)
+		}
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+
+		switch entry.Type {
+		case tar.TypeLink:
+			// Hard link targets are archive member names
+			if target := filepath.Join("/", entry.Linkname); rootOf(target) != root {
+				return nil, fmt.Errorf("hard link %q points outside %s", entry.Name, root)
+			}
+		case tar.TypeSymlink:
+			target := entry.Linkname
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(entry.Path), target)
+			}
+			if !withinDir(filepath.Clean(target), root) {
+				return nil, fmt.Errorf("symlink %q points outside %s", entry.Name, root)
+			}
+		}
+	}
+	return roots, nil
+}
+
+// moveAppdataPath renames appdata directories during a restore, replaced in
+// tests
+var moveAppdataPath = system.MovePath
+
+// RestoreAppdata replaces a stack's appdata with the contents of an archive
+// created by BackupAppdata. The stack is stopped during the restore and the
+// current directories are kept beside the originals with a .pre-restore
+// suffix; they are put back if the restore fails. Restored files keep the
+// owners recorded in the archive, and only each restored directory itself is
+// handed to the homelab user.
+func RestoreAppdata(cfg *config.Config, ui *ui.UI, serviceName, archive string) error {
+	backupDir := appdataBackupDir(cfg)
+	if filepath.Dir(archive) != filepath.Clean(backupDir) {
+		return fmt.Errorf("refusing to restore %s: not in backup directory %s", archive, backupDir)
+	}
+
+	homelabUser := cfg.GetOrDefault("HOMELAB_USER", "")
+	if homelabUser == "" {
+		return fmt.Errorf("homelab user not configured (run user configuration first)")
+	}
+
+	entries, err := system.ReadArchiveEntries(archive)
+	if err != nil {
+		return err
+	}
+	roots, err := checkArchiveEntries(cfg, serviceName, entries)
+	if err != nil {
+		return fmt.Errorf("refusing to restore %s: %w", archive, err)
+	}
+
+	unit := getServiceInfo(cfg, serviceName).UnitName
+	if active, _ := system.IsServiceActive(unit); active {
+		if err := StopService(cfg, ui, serviceName); err != nil {
+			return fmt.Errorf("failed to stop %s for restore: %w", serviceName, err)
+		}
+		defer func() {
+			if err := StartService(cfg, ui, serviceName); err != nil {
+				ui.Warningf("Failed to start %s after restore: %v", serviceName, err)
+			}
+		}()
+	}
+
+	stamp := time.Now().Format(appdataBackupTimeFormat)
+	suffix := ".pre-restore." + stamp
+	var kept []string
+	for _, dir := range roots {
+		if exists, _ := directoryFS.DirectoryExists(dir); !exists {
+			continue
+		}
+		if err := moveAppdataPath(dir, dir+suffix); err != nil {
+			return undoPreRestore(ui, kept, suffix, stamp, err)
+		}
+		kept = append(kept, dir)
+		ui.Infof("Kept current %s as %s", dir, dir+suffix)
+	}
+
+	spinner := ui.Spinner(fmt.Sprintf("Restoring %s appdata...", serviceName))
+	spinner.Start()
+	if err := system.ExtractArchive(archive); err != nil {
+		spinner.Fail("Restore failed")
+		return undoPreRestore(ui, kept, suffix, stamp, err)
+	}
+	spinner.Success(fmt.Sprintf("Restored %s appdata from %s", serviceName, filepath.Base(archive)))
+
+	databaseDirs := make(map[string]bool)
+	for _, dir := range databaseAppdataDirs(cfg, cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata")) {
+		databaseDirs[dir] = true
+	}
+	for _, dir := range roots {
+		// Database containers own their directory under their own user
+		if databaseDirs[dir] {
+			continue
+		}
+		changed, err := system.RepairAppdataPermissions(dir, homelabUser, false)
+		if err != nil {
+			return fmt.Errorf("failed to fix ownership of %s: %w", dir, err)
+		}
+		if changed > 0 {
+			ui.Infof("Fixed ownership of %s", dir)
+		}
+	}
+	return nil
+}
+
+// undoPreRestore puts the directories kept with suffix back in place after a
+// failed restore. Anything already extracted is moved aside with a
+// .failed-restore suffix rather than deleted. It returns cause, or a wrapped
+// error naming what could not be put back.
+func undoPreRestore(ui *ui.UI, kept []string, suffix, stamp string, cause error) error {
+	for _, dir := range kept {
+		if exists, _ := directoryFS.DirectoryExists(dir); exists {
+			failed := dir + ".failed-restore." + stamp
+			if err := moveAppdataPath(dir, failed); err != nil {
+				return fmt.Errorf("%w (the previous %s is still at %s: %v)", cause, dir, dir+suffix, err)
+			}
+			ui.Infof("Moved the partial restore of %s to %s", dir, failed)
+		}
+		if err := moveAppdataPath(dir+suffix, dir); err != nil {
+			return fmt.Errorf("%w (the previous %s is still at %s: %v)", cause, dir, dir+suffix, err)
+		}
+		ui.Infof("Put back %s", dir)
+	}
+	return cause
+}
+
+// promptStack asks which selected stack to act on
+func promptStack(cfg *config.Config, ui *ui.UI, prompt string) (string, error) {
+	services, err := getSelectedServices(cfg)
+	if err != nil {
+		return "", err
+	}
+	index, err := ui.PromptSelect(prompt, services)
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt: %w", err)
+	}
+	return services[index], nil
+}
+
+// RunAppdataBackup backs up the appdata of a stack chosen by the user
+func RunAppdataBackup(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Back Up Appdata")
+	ui.Infof("Archives are written to %s", appdataBackupDir(cfg))
+	ui.Print("")
+
+	serviceName, err := promptStack(cfg, ui, "Select service to back up")
+	if err != nil {
+		return err
+	}
+	stop, err := ui.PromptYesNo("Stop the service during the backup for a consistent copy?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+
+	_, err = BackupAppdata(cfg, ui, serviceName, stop)
+	return err
+}
+
+// RunAppdataRestore restores a stack's appdata from a backup chosen by the user
+func RunAppdataRestore(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Restore Appdata")
+
+	serviceName, err := promptStack(cfg, ui, "Select service to restore")
+	if err != nil {
+		return err
+	}
+	archives, err := listAppdataBackups(cfg, serviceName)
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		ui.Infof("No backups of %s found in %s", serviceName, appdataBackupDir(cfg))
+		return nil
+	}
+
+	names := make([]string, len(archives))
+	for i, archive := range archives {
+		names[i] = filepath.Base(archive)
+	}
+	index, err := ui.PromptSelect("Select backup to restore", names)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+
+	ui.Warningf("This stops %s and replaces its appdata with %s", serviceName, names[index])
+	confirmed, err := ui.PromptYesNo("Restore this backup?", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !confirmed {
+		ui.Info("Restore cancelled")
+		return nil
+	}
+
+	return RestoreAppdata(cfg, ui, serviceName, archives[index])
+}
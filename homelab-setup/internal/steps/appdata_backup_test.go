@@ -0,0 +1,164 @@
+package steps
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestAppdataBackupName(t *testing.T) {
+	at := time.Date(2024, 11, 3, 14, 5, 9, 0, time.UTC)
+	if got, want := appdataBackupName("cloud", at), "cloud-appdata-20241103-140509.tar.gz"; got != want {
+		t.Errorf("appdataBackupName() = %q, want %q", got, want)
+	}
+}
+
+func TestListAppdataBackups(t *testing.T) {
+	const backups = "/var/lib/containers/appdata-backups"
+	mem := useMemFS(t)
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+
+	if got, err := listAppdataBackups(cfg, "media"); err != nil || got != nil {
+		t.Fatalf("listAppdataBackups() without a backup dir = (%v, %v), want (nil, nil)", got, err)
+	}
+
+	for _, name := range []string{
+		"media-appdata-20240101-000000.tar.gz",
+		"media-appdata-20240301-120000.tar.gz",
+		"web-appdata-20240201-000000.tar.gz",
+		"media-appdata-notes.txt",
+	} {
+		mem.AddFile(backups+"/"+name, []byte("x"))
+	}
+
+	got, err := listAppdataBackups(cfg, "media")
+	if err != nil {
+		t.Fatalf("listAppdataBackups() error = %v", err)
+	}
+	want := []string{
+		backups + "/media-appdata-20240301-120000.tar.gz",
+		backups + "/media-appdata-20240101-000000.tar.gz",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listAppdataBackups() = %v, want %v", got, want)
+	}
+}
+
+func TestStackAppdataPaths(t *testing.T) {
+	const appdata = "/var/lib/containers/appdata"
+	mem := useMemFS(t)
+	mem.AddDir(appdata + "/jellyfin")
+	mem.AddDir("/mnt/ssd/plex")
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("APPDATA_PATH_PLEX", "/mnt/ssd/plex"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	got, err := stackAppdataPaths(cfg, "media")
+	if err != nil {
+		t.Fatalf("stackAppdataPaths() error = %v", err)
+	}
+	if want := []string{"/mnt/ssd/plex", appdata + "/jellyfin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stackAppdataPaths() = %v, want %v", got, want)
+	}
+
+	if _, err := stackAppdataPaths(cfg, "unknown"); err == nil {
+		t.Error("expected an error for an unknown service")
+	}
+}
+
+func TestCheckArchiveEntries(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("APPDATA_PATH_PLEX", "/mnt/ssd/plex"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	entry := func(name string, typ byte, link string) system.ArchiveEntry {
+		return system.ArchiveEntry{Name: name, Path: filepath.Join("/", name), Type: typ, Linkname: link}
+	}
+	dir := func(name string) system.ArchiveEntry { return entry(name, tar.TypeDir, "") }
+	file := func(name string) system.ArchiveEntry { return entry(name, tar.TypeReg, "") }
+
+	tests := []struct {
+		name      string
+		entries   []system.ArchiveEntry
+		wantRoots []string
+		wantErr   bool
+	}{
+		{
+			name: "own appdata",
+			entries: []system.ArchiveEntry{
+				dir("mnt/ssd/plex/"), file("mnt/ssd/plex/Preferences.xml"),
+				dir("var/lib/containers/appdata/tautulli/"), file("var/lib/containers/appdata/tautulli/tautulli.db"),
+				entry("var/lib/containers/appdata/tautulli/current", tar.TypeSymlink, "tautulli.db"),
+				entry("var/lib/containers/appdata/tautulli/copy.db", tar.TypeLink, "var/lib/containers/appdata/tautulli/tautulli.db"),
+			},
+			wantRoots: []string{"/mnt/ssd/plex", "/var/lib/containers/appdata/tautulli"},
+		},
+		{name: "empty", wantErr: true},
+		{name: "other stack", entries: []system.ArchiveEntry{dir("var/lib/containers/appdata/nextcloud/")}, wantErr: true},
+		{name: "outside appdata", entries: []system.ArchiveEntry{file("etc/passwd")}, wantErr: true},
+		{name: "dot-dot traversal", entries: []system.ArchiveEntry{
+			dir("mnt/ssd/plex/"), file("mnt/ssd/plex/../plex/x"),
+		}, wantErr: true},
+		{name: "absolute symlink", entries: []system.ArchiveEntry{
+			entry("mnt/ssd/plex/shadow", tar.TypeSymlink, "/etc/shadow"),
+		}, wantErr: true},
+		{name: "escaping relative symlink", entries: []system.ArchiveEntry{
+			entry("mnt/ssd/plex/up", tar.TypeSymlink, "../../.."),
+		}, wantErr: true},
+		{name: "hard link to another root", entries: []system.ArchiveEntry{
+			entry("mnt/ssd/plex/db", tar.TypeLink, "var/lib/containers/appdata/jellyfin/db"),
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots, err := checkArchiveEntries(cfg, "media", tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkArchiveEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(roots, tt.wantRoots) {
+				t.Errorf("checkArchiveEntries() roots = %v, want %v", roots, tt.wantRoots)
+			}
+		})
+	}
+}
+
+func TestUndoPreRestore(t *testing.T) {
+	const suffix = ".pre-restore.20241103-140509"
+	mem := useMemFS(t)
+	// plex was partly extracted, jellyfin not at all
+	mem.AddDir("/var/lib/containers/appdata/plex")
+
+	orig := moveAppdataPath
+	t.Cleanup(func() { moveAppdataPath = orig })
+	var moves []string
+	moveAppdataPath = func(src, dst string) error {
+		moves = append(moves, src+" -> "+dst)
+		return nil
+	}
+
+	cause := errors.New("tar failed")
+	kept := []string{"/var/lib/containers/appdata/plex", "/var/lib/containers/appdata/jellyfin"}
+	err := undoPreRestore(ui.NewWithWriter(&bytes.Buffer{}), kept, suffix, "20241103-140509", cause)
+	if !errors.Is(err, cause) {
+		t.Errorf("undoPreRestore() error = %v, want the restore error", err)
+	}
+
+	want := []string{
+		"/var/lib/containers/appdata/plex -> /var/lib/containers/appdata/plex.failed-restore.20241103-140509",
+		"/var/lib/containers/appdata/plex" + suffix + " -> /var/lib/containers/appdata/plex",
+		"/var/lib/containers/appdata/jellyfin" + suffix + " -> /var/lib/containers/appdata/jellyfin",
+	}
+	if !reflect.DeepEqual(moves, want) {
+		t.Errorf("moves = %v, want %v", moves, want)
+	}
+}
@@ -0,0 +1,228 @@
+package steps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// Units generated by RunScheduleBackups
+const (
+	backupServiceUnit = "homelab-appdata-backup.service"
+	backupTimerUnit   = "homelab-appdata-backup.timer"
+)
+
+// backupScheduleShorthands are the schedules accepted without consulting
+// systemd-analyze; all are valid OnCalendar= values
+var backupScheduleShorthands = map[string]bool{
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+}
+
+// validateCalendarSpec checks OnCalendar= expressions that are not
+// shorthands, overridable in tests
+var validateCalendarSpec = system.ValidateCalendarSpec
+
+// validateBackupSchedule accepts a shorthand (daily, weekly, ...) or any
+// OnCalendar= expression systemd understands, e.g. "Sun *-*-* 03:00:00"
+func validateBackupSchedule(spec string) error {
+	if spec == "" {
+		return fmt.Errorf("schedule cannot be empty")
+	}
+	// The spec is written into a unit file verbatim
+	if strings.IndexFunc(spec, unicode.IsControl) >= 0 {
+		return fmt.Errorf("schedule contains control characters")
+	}
+	if backupScheduleShorthands[strings.ToLower(spec)] {
+		return nil
+	}
+	return validateCalendarSpec(spec)
+}
+
+// parseBackupServices resolves "all" (the selected services) or a
+// comma-separated list of stack names
+func parseBackupServices(cfg *config.Config, spec string) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "all" {
+		return getSelectedServices(cfg)
+	}
+
+	var services []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := stackAppdataDirs[name]; !ok {
+			return nil, fmt.Errorf("unknown service: %q", name)
+		}
+		services = append(services, name)
+	}
+	return services, nil
+}
+
+// BackupAppdataServices backs up each service in spec ("all" or a
+// comma-separated list) without prompting, stopping each one during its
+// copy. It continues past failures and returns an error naming every
+// service that failed. This is what the backup timer runs.
+func BackupAppdataServices(cfg *config.Config, ui *ui.UI, spec string) error {
+	services, err := parseBackupServices(cfg, spec)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, serviceName := range services {
+		if _, err := BackupAppdata(cfg, ui, serviceName, true); err != nil {
+			ui.Errorf("Backup of %s failed: %v", serviceName, err)
+			failed = append(failed, serviceName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("appdata backup failed for: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// renderBackupUnits returns the service and timer units that run the backup
+// command of binary against configPath on schedule
+func renderBackupUnits(binary, configPath, services, schedule string) (service, timer string) {
+	service = fmt.Sprintf(`[Unit]
+Description=Homelab appdata backup
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=%s --config %s --backup-appdata %s
+`, binary, configPath, services)
+
+	timer = fmt.Sprintf(`[Unit]
+Description=Scheduled homelab appdata backup
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+RandomizedDelaySec=15m
+
+[Install]
+WantedBy=timers.target
+`, schedule)
+	return service, timer
+}
+
+// installBackupTimer writes the backup units, enables the timer and saves
+// the schedule
+func installBackupTimer(cfg *config.Config, ui *ui.UI, schedule string) error {
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the homelab-setup binary: %w", err)
+	}
+	configPath, err := filepath.Abs(cfg.FilePath())
+	if err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	// Unit files split ExecStart on whitespace
+	if strings.ContainsAny(binary+configPath, " \t") {
+		return fmt.Errorf("cannot schedule backups: %s or %s contains whitespace", binary, configPath)
+	}
+
+	service, timer := renderBackupUnits(binary, configPath, "all", schedule)
+	for unit, content := range map[string]string{backupServiceUnit: service, backupTimerUnit: timer} {
+		path := filepath.Join("/etc/systemd/system", unit)
+		if err := system.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		ui.Successf("Wrote %s", path)
+	}
+
+	if err := system.SystemdDaemonReload(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	if err := system.EnableService(backupTimerUnit); err != nil {
+		return err
+	}
+	if err := system.RestartService(backupTimerUnit); err != nil {
+		return err
+	}
+
+	if err := cfg.Set(config.KeyAppdataBackupSchedule, schedule); err != nil {
+		ui.Warningf("Failed to save backup schedule: %v", err)
+	}
+	ui.Successf("Appdata backups scheduled (%s)", schedule)
+	ui.Infof("Check the next run with: systemctl list-timers %s", backupTimerUnit)
+	return nil
+}
+
+// removeBackupTimer disables the timer and deletes both units
+func removeBackupTimer(cfg *config.Config, ui *ui.UI) error {
+	if err := system.StopService(backupTimerUnit); err != nil {
+		ui.Warningf("Failed to stop %s: %v", backupTimerUnit, err)
+	}
+	if err := system.DisableService(backupTimerUnit); err != nil {
+		ui.Warningf("Failed to disable %s: %v", backupTimerUnit, err)
+	}
+	for _, unit := range []string{backupTimerUnit, backupServiceUnit} {
+		path := filepath.Join("/etc/systemd/system", unit)
+		if err := system.RemoveFile(path); err != nil {
+			return err
+		}
+		ui.Successf("Removed %s", path)
+	}
+	if err := system.SystemdDaemonReload(); err != nil {
+		ui.Warningf("Failed to reload systemd: %v", err)
+	}
+
+	if err := cfg.Delete(config.KeyAppdataBackupSchedule); err != nil {
+		ui.Warningf("Failed to clear backup schedule: %v", err)
+	}
+	ui.Success("Scheduled appdata backups removed")
+	return nil
+}
+
+// RunScheduleBackups installs, updates or removes the systemd timer that
+// backs up the appdata of every selected service
+func RunScheduleBackups(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Schedule Appdata Backups")
+
+	if _, err := getSelectedServices(cfg); err != nil {
+		return err
+	}
+
+	installed, err := system.ServiceExists(backupTimerUnit)
+	if err != nil {
+		return err
+	}
+	current := cfg.GetOrDefault(config.KeyAppdataBackupSchedule, "")
+	if installed {
+		ui.Infof("Backups are scheduled (%s)", current)
+		remove, err := ui.PromptYesNo("Remove the backup schedule?", false)
+		if err != nil {
+			return fmt.Errorf("failed to prompt: %w", err)
+		}
+		if remove {
+			return removeBackupTimer(cfg, ui)
+		}
+	}
+
+	ui.Infof("Each run backs up every selected service to %s, stopping it during its copy", appdataBackupDir(cfg))
+	ui.Info("Schedule: daily, weekly, monthly or an OnCalendar expression such as \"Sun *-*-* 03:00:00\"")
+	if current == "" {
+		current = "daily"
+	}
+	schedule, err := ui.PromptInput("Backup schedule", current)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	schedule = strings.TrimSpace(schedule)
+	if err := validateBackupSchedule(schedule); err != nil {
+		return err
+	}
+
+	return installBackupTimer(cfg, ui, schedule)
+}
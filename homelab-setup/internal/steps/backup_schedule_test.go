@@ -0,0 +1,88 @@
+package steps
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+func TestValidateBackupSchedule(t *testing.T) {
+	orig := validateCalendarSpec
+	t.Cleanup(func() { validateCalendarSpec = orig })
+	var checked []string
+	validateCalendarSpec = func(spec string) error {
+		checked = append(checked, spec)
+		if spec == "someday" {
+			return errors.New("invalid calendar spec")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"daily", false},
+		{"Weekly", false},
+		{"Sun *-*-* 03:00:00", false},
+		{"someday", true},
+		{"", true},
+		{"daily\nExecStart=/bin/sh", true},
+	}
+	for _, tt := range tests {
+		if err := validateBackupSchedule(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("validateBackupSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+	if want := []string{"Sun *-*-* 03:00:00", "someday"}; !reflect.DeepEqual(checked, want) {
+		t.Errorf("systemd-analyze consulted for %v, want only %v", checked, want)
+	}
+}
+
+func TestParseBackupServices(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if _, err := parseBackupServices(cfg, "all"); err == nil {
+		t.Error("expected an error for \"all\" with no selected services")
+	}
+	if err := cfg.Set(config.KeySelectedServices, "media cloud"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"all", []string{"media", "cloud"}, false},
+		{"", []string{"media", "cloud"}, false},
+		{"web, cloud", []string{"web", "cloud"}, false},
+		{"media,plex", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBackupServices(cfg, tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBackupServices(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBackupServices(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBackupUnits(t *testing.T) {
+	service, timer := renderBackupUnits("/usr/bin/homelab-setup", "/home/core/.homelab-setup.conf", "all", "weekly")
+
+	if !strings.Contains(service, "Type=oneshot\nExecStart=/usr/bin/homelab-setup --config /home/core/.homelab-setup.conf --backup-appdata all\n") {
+		t.Errorf("service unit missing backup command:\n%s", service)
+	}
+	for _, want := range []string{"OnCalendar=weekly\n", "Persistent=true\n", "WantedBy=timers.target\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+}
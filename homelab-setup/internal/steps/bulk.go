@@ -0,0 +1,103 @@
+package steps
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// bulkConcurrency returns BULK_CONCURRENCY, falling back to 1 (one stack at
+// a time)
+func bulkConcurrency(cfg *config.Config) int {
+	n, err := strconv.Atoi(cfg.GetOrDefault(config.KeyBulkConcurrency, "1"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// runBulk calls fn for each name with at most limit calls in flight and
+// returns their errors in the order of names. Names not started because ctx
+// was cancelled get ctx's error.
+func runBulk(ctx context.Context, names []string, limit int, fn func(ctx context.Context, index int, name string) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+	errs := make([]error, len(names))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < limit && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				errs[index] = fn(ctx, index, names[index])
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(names); next++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for ; next < len(names); next++ {
+		errs[next] = ctx.Err()
+	}
+	return errs
+}
+
+// forEachService runs fn on each stack, BULK_CONCURRENCY at a time, and
+// returns the errors in the order of serviceNames. Run one at a time, fn
+// prints to ui directly and may prompt. Run concurrently, each stack gets a
+// buffered, non-interactive UI whose output is printed once the stack and
+// every stack before it have finished, so the transcript reads in order.
+func forEachService(ctx context.Context, cfg *config.Config, u *ui.UI, serviceNames []string, fn func(ctx context.Context, cfg *config.Config, ui *ui.UI, serviceName string) error) []error {
+	limit := bulkConcurrency(cfg)
+	if limit == 1 || len(serviceNames) < 2 {
+		return runBulk(ctx, serviceNames, 1, func(ctx context.Context, _ int, serviceName string) error {
+			return fn(ctx, cfg, u, serviceName)
+		})
+	}
+
+	if limit > len(serviceNames) {
+		limit = len(serviceNames)
+	}
+	u.Infof("Working on up to %d stacks at once (%s); each stack's output is shown when it finishes, and prompts take their defaults",
+		limit, config.KeyBulkConcurrency)
+
+	buffers := make([]*ui.UI, len(serviceNames))
+	for i := range buffers {
+		buffers[i] = u.Buffered()
+	}
+	var mu sync.Mutex
+	done := make([]bool, len(serviceNames))
+	flushed := 0
+
+	return runBulk(ctx, serviceNames, limit, func(ctx context.Context, index int, serviceName string) error {
+		err := fn(ctx, cfg, buffers[index], serviceName)
+
+		mu.Lock()
+		defer mu.Unlock()
+		done[index] = true
+		for flushed < len(serviceNames) && done[flushed] {
+			buffers[flushed].Flush()
+			flushed++
+		}
+		return err
+	})
+}
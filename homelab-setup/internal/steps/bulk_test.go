@@ -0,0 +1,104 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestRunBulkRespectsLimit(t *testing.T) {
+	names := make([]string, 12)
+	for i := range names {
+		names[i] = fmt.Sprintf("stack%d", i)
+	}
+
+	for _, limit := range []int{1, 3, 20} {
+		var inFlight, peak atomic.Int32
+		errs := runBulk(context.Background(), names, limit, func(_ context.Context, index int, _ string) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if index%4 == 0 {
+				return fmt.Errorf("failed %d", index)
+			}
+			return nil
+		})
+
+		if got := int(peak.Load()); got > limit {
+			t.Errorf("limit %d: %d calls ran at once", limit, got)
+		}
+		if limit > 1 && peak.Load() < 2 {
+			t.Errorf("limit %d: calls never overlapped", limit)
+		}
+		for i, err := range errs {
+			if wantErr := i%4 == 0; (err != nil) != wantErr || (wantErr && err.Error() != fmt.Sprintf("failed %d", i)) {
+				t.Errorf("limit %d: errs[%d] = %v", limit, i, err)
+			}
+		}
+	}
+}
+
+func TestRunBulkCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	errs := runBulk(ctx, []string{"media", "web", "cloud"}, 1, func(context.Context, int, string) error {
+		started.Add(1)
+		cancel()
+		return nil
+	})
+	if started.Load() != 1 {
+		t.Errorf("%d stacks started after cancellation, want 1", started.Load())
+	}
+	if errs[0] != nil || !errors.Is(errs[1], context.Canceled) || !errors.Is(errs[2], context.Canceled) {
+		t.Errorf("runBulk() = %v, want the unstarted stacks cancelled", errs)
+	}
+}
+
+func TestForEachServiceOrdersOutput(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyBulkConcurrency, "3"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	u := ui.NewWithWriter(&buf)
+
+	// Earlier stacks finish last
+	delays := map[string]time.Duration{"media": 30 * time.Millisecond, "web": 15 * time.Millisecond, "cloud": 0}
+	errs := forEachService(context.Background(), cfg, u, []string{"media", "web", "cloud"},
+		func(_ context.Context, _ *config.Config, ui *ui.UI, serviceName string) error {
+			time.Sleep(delays[serviceName])
+			ui.Infof("deployed %s", serviceName)
+			if serviceName == "web" {
+				ui.Warning("web is slow")
+				return errors.New("web failed")
+			}
+			return nil
+		})
+
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("forEachService() = %v", errs)
+	}
+	out := buf.String()
+	media, web, cloud := strings.Index(out, "deployed media"), strings.Index(out, "deployed web"), strings.Index(out, "deployed cloud")
+	if media < 0 || !(media < web && web < cloud) {
+		t.Errorf("output not in stack order:\n%s", out)
+	}
+	if warnings := u.Warnings(); len(warnings) != 1 || warnings[0] != "web is slow" {
+		t.Errorf("Warnings() = %v, want the buffered stack's warning", warnings)
+	}
+}
@@ -0,0 +1,44 @@
+package steps
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// ErrCancelled is wrapped by the errors returned when the user declines to
+// continue at a confirmation prompt
+var ErrCancelled = errors.New("cancelled")
+
+// saveConfigValues persists values in one write. When any of them would
+// overwrite or remove a saved setting, the changes are shown as a diff and
+// the user must confirm; adding new keys never prompts.
+func saveConfigValues(cfg *config.Config, ui *ui.UI, values map[string]string) error {
+	var overwrites []config.Change
+	for _, change := range cfg.DiffSetMany(values) {
+		if change.Kind != config.ChangeAdded {
+			overwrites = append(overwrites, change)
+		}
+	}
+
+	if len(overwrites) > 0 {
+		ui.Warning("This will change existing configuration values:")
+		for _, change := range overwrites {
+			ui.Printf("  %s", change)
+		}
+		confirmed, err := ui.PromptYesNo("Save these changes?", true)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("configuration changes not saved: %w", ErrCancelled)
+		}
+	}
+
+	if err := cfg.SetMany(values); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	return nil
+}
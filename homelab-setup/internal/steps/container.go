@@ -1,15 +1,18 @@
 package steps
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
-
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
@@ -166,12 +169,59 @@ func discoverStacks(_ *config.Config, ui *ui.UI, templateDir string) (map[string
 	return stacks, nil
 }
 
-// selectStacks allows user to select which stacks to setup
+// stackDescription describes a stack option by the apps it contains, as listed
+// in containerServiceDirs, falling back to the template file name
+func stackDescription(name, templateFile string) string {
+	for _, svc := range containerServiceDirs {
+		if svc.name == name {
+			return fmt.Sprintf("%s - %s", name, svc.description)
+		}
+	}
+	return fmt.Sprintf("%s (%s)", name, templateFile)
+}
+
+// previousSelection returns the stacks saved in SELECTED_SERVICES that are
+// still available, in their saved order
+func previousSelection(cfg *config.Config, stackNames []string) []string {
+	available := make(map[string]bool, len(stackNames))
+	for _, name := range stackNames {
+		available[name] = true
+	}
+
+	var selected []string
+	for _, name := range SelectedServices(cfg) {
+		if available[name] {
+			selected = append(selected, name)
+		}
+	}
+	return selected
+}
+
+// selectStacks lets the user pick which stacks to set up and saves the
+// choice to SELECTED_SERVICES
 func selectStacks(cfg *config.Config, ui *ui.UI, stacks map[string]string) ([]string, error) {
+	selected, err := pickStacks(cfg, ui, stacks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save selected services to config
+	value, err := normalizeSelectedServices(strings.Join(selected, " "), stacks)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Set(config.KeySelectedServices, value); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to save selected services: %v", err))
+	}
+
+	return selected, nil
+}
+
+// pickStacks lets the user pick stacks from stacks, offering the previous
+// selection as the default
+func pickStacks(cfg *config.Config, ui *ui.UI, stacks map[string]string) ([]string, error) {
 	ui.Step("Container Stack Selection")
 	ui.Print("")
-	ui.Info("Available container stacks:")
-	ui.Print("")
 
 	// Sort stack names for consistent ordering
 	var stackNames []string
@@ -180,68 +230,49 @@ func selectStacks(cfg *config.Config, ui *ui.UI, stacks map[string]string) ([]st
 	}
 	sort.Strings(stackNames)
 
-	// Display available stacks
-	for i, name := range stackNames {
-		ui.Printf("  %d) %s (%s)", i+1, name, stacks[name])
-	}
-	ui.Printf("  %d) All stacks", len(stackNames)+1)
-	ui.Print("")
-
-	// Prompt for selection
-	ui.Info("Select which container stacks to setup:")
-	ui.Info("  - You can select multiple stacks using Space, then press Enter")
-	ui.Info("  - Or select 'All stacks' to setup everything")
-	ui.Print("")
-
-	// Build options for multi-select
-	var options []string
-	for _, name := range stackNames {
-		options = append(options, fmt.Sprintf("%s (%s)", name, stacks[name]))
-	}
-	options = append(options, "All stacks")
-
-	// Use multi-select prompt
-	selectedIndices, err := ui.PromptMultiSelect("Select stacks to setup", options)
-	if err != nil {
-		return nil, fmt.Errorf("failed to prompt for stack selection: %w", err)
+	selected := previousSelection(cfg, stackNames)
+	if len(selected) > 0 {
+		ui.Infof("Previously selected stacks: %s", strings.Join(selected, ", "))
+		keep, err := ui.PromptYesNo("Keep this selection?", true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prompt for stack selection: %w", err)
+		}
+		if !keep {
+			selected = nil
+		}
 	}
 
-	if len(selectedIndices) == 0 {
-		return nil, fmt.Errorf("no stacks selected")
-	}
+	if len(selected) == 0 {
+		options := make([]string, 0, len(stackNames)+1)
+		for _, name := range stackNames {
+			options = append(options, stackDescription(name, stacks[name]))
+		}
+		options = append(options, "All stacks")
 
-	// Check if "All stacks" was selected
-	allStacksIndex := len(stackNames)
-	for _, idx := range selectedIndices {
-		if idx == allStacksIndex {
-			ui.Success("Selected: All stacks")
-			// Save selected services to config before returning
-			if err := cfg.Set("SELECTED_SERVICES", strings.Join(stackNames, " ")); err != nil {
-				ui.Warning(fmt.Sprintf("Failed to save selected services: %v", err))
-			}
-			return stackNames, nil
+		selectedIndices, err := ui.PromptMultiSelect("Select stacks to setup", options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prompt for stack selection: %w", err)
+		}
+		if len(selectedIndices) == 0 {
+			return nil, fmt.Errorf("no stacks selected")
 		}
-	}
 
-	// Get selected stack names
-	var selected []string
-	for _, idx := range selectedIndices {
-		if idx < len(stackNames) {
+		allStacksIndex := len(stackNames)
+		for _, idx := range selectedIndices {
+			if idx == allStacksIndex {
+				selected = stackNames
+				break
+			}
 			selected = append(selected, stackNames[idx])
 		}
 	}
 
 	ui.Success("Selected stacks:")
 	for _, name := range selected {
-		ui.Infof("  - %s", name)
+		ui.Infof("  - %s", stackDescription(name, stacks[name]))
 	}
 	ui.Print("")
 
-	// Save selected services to config
-	if err := cfg.Set("SELECTED_SERVICES", strings.Join(selected, " ")); err != nil {
-		ui.Warning(fmt.Sprintf("Failed to save selected services: %v", err))
-	}
-
 	return selected, nil
 }
 
@@ -254,12 +285,21 @@ func copyTemplates(cfg *config.Config, ui *ui.UI, templateDir string, stacks map
 		return fmt.Errorf("homelab user not configured")
 	}
 
+	manifest, err := loadChecksumManifest(templateDir)
+	if err != nil {
+		return err
+	}
+
 	for _, serviceName := range selectedStacks {
 		templateFile := stacks[serviceName]
 		srcPath := filepath.Join(templateDir, templateFile)
 		dstDir := serviceDirectory(cfg, serviceName)
 		dstPath := filepath.Join(dstDir, "compose.yml")
 
+		if err := verifyTemplateChecksum(cfg, ui, manifest, serviceName, srcPath); err != nil {
+			return err
+		}
+
 		// Ensure destination directory exists
 		if err := system.EnsureDirectory(dstDir, setupUser, 0755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dstDir, err)
@@ -280,7 +320,7 @@ func copyTemplates(cfg *config.Config, ui *ui.UI, templateDir string, stacks map
 			return fmt.Errorf("failed to set permissions on %s: %w", dstPath, err)
 		}
 
-		ui.Successf("✓ %s/compose.yml", serviceName)
+		ui.Successf("%s %s/compose.yml", ui.GlyphOK(), serviceName)
 
 		// Also create docker-compose.yml symlink for compatibility
 		altDstPath := filepath.Join(dstDir, "docker-compose.yml")
@@ -295,8 +335,99 @@ func copyTemplates(cfg *config.Config, ui *ui.UI, templateDir string, stacks map
 	return nil
 }
 
+// checksumManifestName is the sha256sum-format file in a template directory
+// listing the expected digest of each template
+const checksumManifestName = "SHA256SUMS"
+
+// loadChecksumManifest reads the template directory's SHA256SUMS, returning
+// nil when there is none
+func loadChecksumManifest(templateDir string) (map[string]string, error) {
+	content, err := os.ReadFile(filepath.Join(templateDir, checksumManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum manifest: %w", err)
+	}
+	return system.ParseChecksumManifest(string(content))
+}
+
+// verifyTemplateChecksum checks a compose template against the digest from
+// COMPOSE_SHA256_<STACK> or, failing that, the checksum manifest. Templates
+// are read from a local directory, so one with no expected digest is used
+// with a note rather than a warning; a mismatch is an error.
+func verifyTemplateChecksum(cfg *config.Config, ui *ui.UI, manifest map[string]string, serviceName, templatePath string) error {
+	expected := cfg.GetOrDefault(config.KeyComposeSHA256Prefix+strings.ToUpper(serviceName), "")
+	if expected == "" {
+		expected = manifest[filepath.Base(templatePath)]
+	}
+	if expected == "" {
+		ui.Infof("No checksum configured for %s; copying it without an integrity check", filepath.Base(templatePath))
+		return nil
+	}
+
+	if err := system.VerifyChecksum(templatePath, expected); err != nil {
+		return fmt.Errorf("refusing to deploy %s: %w", serviceName, err)
+	}
+	ui.Successf("Checksum verified for %s", filepath.Base(templatePath))
+	return nil
+}
+
+// defaultPUIDPGID returns the invoking user's UID/GID as strings, falling back
+// to 1000:1000 when they cannot be determined
+func defaultPUIDPGID() (string, string) {
+	uid, gid, err := system.CurrentUIDGID()
+	if err != nil {
+		return "1000", "1000"
+	}
+	return fmt.Sprintf("%d", uid), fmt.Sprintf("%d", gid)
+}
+
+// ID lookups used by puidPGIDWarnings, overridable in tests
+var (
+	userIDExists   = system.UserIDExists
+	groupIDExists  = system.GroupIDExists
+	lookupUserInfo = system.GetUserInfo
+)
+
+// puidPGIDWarnings validates PUID/PGID and describes how they disagree with
+// the system: IDs no user or group has, and IDs that differ from the homelab
+// user's. Values that are not valid IDs are an error.
+func puidPGIDWarnings(cfg *config.Config, puid, pgid string) ([]string, error) {
+	if err := common.ValidateUID(puid); err != nil {
+		return nil, fmt.Errorf("invalid PUID: %w", err)
+	}
+	if err := common.ValidateGID(pgid); err != nil {
+		return nil, fmt.Errorf("invalid PGID: %w", err)
+	}
+
+	var warnings []string
+	if exists, err := userIDExists(puid); err == nil && !exists {
+		warnings = append(warnings, fmt.Sprintf("PUID=%s does not belong to any user on this system", puid))
+	}
+	if exists, err := groupIDExists(pgid); err == nil && !exists {
+		warnings = append(warnings, fmt.Sprintf("PGID=%s does not belong to any group on this system", pgid))
+	}
+
+	username, err := getServiceUser(cfg)
+	if err != nil {
+		return warnings, nil
+	}
+	homelabUser, err := lookupUserInfo(username)
+	if err != nil {
+		return warnings, nil
+	}
+	if homelabUser.Uid != puid {
+		warnings = append(warnings, fmt.Sprintf("PUID=%s but homelab user '%s' has UID %s", puid, username, homelabUser.Uid))
+	}
+	if homelabUser.Gid != pgid {
+		warnings = append(warnings, fmt.Sprintf("PGID=%s but homelab user '%s' has primary GID %s", pgid, username, homelabUser.Gid))
+	}
+	return warnings, nil
+}
+
 // createBaseEnvConfig creates base environment configuration
-func createBaseEnvConfig(cfg *config.Config, ui *ui.UI) {
+func createBaseEnvConfig(cfg *config.Config, ui *ui.UI) error {
 	ui.Step("Validating Base Environment Configuration")
 
 	// Verify PUID/PGID are set from user setup
@@ -304,10 +435,45 @@ func createBaseEnvConfig(cfg *config.Config, ui *ui.UI) {
 	pgid := cfg.GetOrDefault("PGID", "")
 
 	if puid == "" || pgid == "" {
-		ui.Warning("PUID/PGID not found in config - using defaults (1000:1000)")
+		ui.Warning("PUID/PGID not found in config")
 		ui.Info("Run user setup first to set proper service account IDs")
-		puid = "1000"
-		pgid = "1000"
+
+		defaultUID, defaultGID := defaultPUIDPGID()
+		if currentUser, err := system.GetCurrentUser(); err == nil {
+			ui.Infof("Current user: %s (UID: %s, GID: %s)", currentUser.Username, defaultUID, defaultGID)
+		}
+
+		var err error
+		if puid == "" {
+			puid, err = ui.PromptInputWithValidation("PUID for containers", defaultUID, common.ValidateUID)
+			if err != nil {
+				return fmt.Errorf("failed to prompt for PUID: %w", err)
+			}
+			if err := cfg.Set("PUID", puid); err != nil {
+				return fmt.Errorf("failed to save PUID: %w", err)
+			}
+		}
+		if pgid == "" {
+			pgid, err = ui.PromptInputWithValidation("PGID for containers", defaultGID, common.ValidateGID)
+			if err != nil {
+				return fmt.Errorf("failed to prompt for PGID: %w", err)
+			}
+			if err := cfg.Set("PGID", pgid); err != nil {
+				return fmt.Errorf("failed to save PGID: %w", err)
+			}
+		}
+	}
+
+	warnings, err := puidPGIDWarnings(cfg, puid, pgid)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		ui.Warning(warning)
+	}
+	if len(warnings) > 0 {
+		ui.Info("Files created by containers will not be owned by the homelab user")
+		ui.Info("Re-run user setup to store the homelab user's IDs as PUID/PGID")
 	}
 
 	tz := cfg.GetOrDefault("TZ", "America/Chicago")
@@ -322,6 +488,7 @@ func createBaseEnvConfig(cfg *config.Config, ui *ui.UI) {
 	ui.Infof("  PGID=%s (containers will run as this GID)", pgid)
 	ui.Infof("  TZ=%s", tz)
 	ui.Infof("  APPDATA_PATH=%s", appdataPath)
+	return nil
 }
 
 // configureStackEnv configures environment for a specific stack
@@ -346,16 +513,26 @@ func configureMediaEnv(cfg *config.Config, ui *ui.UI) error {
 	// Get Plex claim token
 	ui.Info("Plex Setup:")
 	ui.Info("  Get your claim token from: https://plex.tv/claim")
-	plexClaim, err := ui.PromptInput("Plex claim token (optional)", "")
+	ui.Info("  Tokens expire a few minutes after they are issued, so fetch one right before deploying")
+	plexClaim, err := ui.PromptInputWithValidation("Plex claim token (optional)", "", validateOptionalPlexClaimToken)
 	if err != nil {
 		return err
 	}
 	if plexClaim != "" {
-		if err := cfg.Set("PLEX_CLAIM_TOKEN", plexClaim); err != nil {
-			return fmt.Errorf("failed to save PLEX_CLAIM_TOKEN: %w", err)
+		if err := savePlexClaimToken(cfg, ui, plexClaim, time.Now()); err != nil {
+			return err
 		}
 	}
 
+	// Hardware transcoding device
+	device, err := selectTranscodeDevice(ui)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(config.KeyTranscodeDevice, device); err != nil {
+		return fmt.Errorf("failed to save %s: %w", config.KeyTranscodeDevice, err)
+	}
+
 	// Jellyfin public URL
 	jellyfinURL, err := ui.PromptInput("Jellyfin public URL (optional)", "")
 	if err != nil {
@@ -370,6 +547,171 @@ func configureMediaEnv(cfg *config.Config, ui *ui.UI) error {
 	return nil
 }
 
+// selectTranscodeDevice picks the render node for hardware transcoding,
+// prompting when there are several. It returns "" when none is present.
+func selectTranscodeDevice(ui *ui.UI) (string, error) {
+	devices, err := system.DetectRenderDevices()
+	if err != nil {
+		return "", err
+	}
+
+	switch len(devices) {
+	case 0:
+		ui.Warning("No GPU render device found under /dev/dri")
+		ui.Info("  Plex and Jellyfin will use software transcoding")
+		return "", nil
+	case 1:
+		ui.Infof("Hardware transcoding device: %s", describeRenderDevice(devices[0]))
+		return devices[0], nil
+	}
+
+	options := make([]string, len(devices))
+	for i, device := range devices {
+		options[i] = describeRenderDevice(device)
+	}
+	idx, err := ui.PromptSelect("Multiple GPU render devices found. Select one for transcoding:", options)
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt for transcode device: %w", err)
+	}
+	return devices[idx], nil
+}
+
+// describeRenderDevice labels a render node with its GPU vendor when known
+func describeRenderDevice(device string) string {
+	if vendor := system.RenderDeviceVendor(device); vendor != "" {
+		return fmt.Sprintf("%s (%s)", device, vendor)
+	}
+	return device
+}
+
+// transcodeDevice returns the configured render node, detecting one when
+// media env configuration has not run. The result is "" when none is available.
+func transcodeDevice(cfg *config.Config) string {
+	if cfg.Exists(config.KeyTranscodeDevice) {
+		return cfg.GetOrDefault(config.KeyTranscodeDevice, "")
+	}
+	devices, err := system.DetectRenderDevices()
+	if err != nil || len(devices) == 0 {
+		return ""
+	}
+	return devices[0]
+}
+
+// transcodeEnvLines renders the hardware transcoding section of the media .env
+func transcodeEnvLines(device string) string {
+	if device == "" {
+		return "# No GPU render device was found; TRANSCODE_DEVICE is omitted so\n" +
+			"# Plex and Jellyfin fall back to software transcoding\n"
+	}
+	return "# GPU render node used for hardware transcoding\n" +
+		"TRANSCODE_DEVICE=" + device + "\n"
+}
+
+// plexClaimTokenMaxAge is how old a claim token may be before we warn that it
+// has probably expired (plex.tv tokens are only valid for a few minutes)
+const plexClaimTokenMaxAge = 4 * time.Minute
+
+// validateOptionalPlexClaimToken accepts an empty value or a well-formed claim token
+func validateOptionalPlexClaimToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	return common.ValidatePlexClaimToken(token)
+}
+
+// savePlexClaimToken stores the claim token as a secret along with the time it was entered
+func savePlexClaimToken(cfg *config.Config, ui *ui.UI, token string, now time.Time) error {
+	ui.AddSecret(token)
+	if err := cfg.SetSecret(config.KeyPlexClaimToken, token); err != nil {
+		return fmt.Errorf("failed to save %s: %w", config.KeyPlexClaimToken, err)
+	}
+	if err := cfg.Set(config.KeyPlexClaimTokenSetAt, now.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save %s: %w", config.KeyPlexClaimTokenSetAt, err)
+	}
+	return nil
+}
+
+// plexClaimTokenAge returns how long ago the claim token was entered. ok is
+// false when no valid entry time was recorded (e.g. tokens saved by older versions).
+func plexClaimTokenAge(cfg *config.Config, now time.Time) (age time.Duration, ok bool) {
+	setAt, err := time.Parse(time.RFC3339, cfg.GetOrDefault(config.KeyPlexClaimTokenSetAt, ""))
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(setAt), true
+}
+
+// readPlexPreferences reads Plex's Preferences.xml, overridable in tests
+var readPlexPreferences = system.ReadFile
+
+// plexOnlineTokenPattern matches the non-empty account token Plex records in
+// Preferences.xml once the server has been claimed
+var plexOnlineTokenPattern = regexp.MustCompile(`\bPlexOnlineToken="[^"]+"`)
+
+// plexServerClaimed reports whether the Plex server in the plex appdata
+// directory (mounted at /config) has been claimed. A missing or unreadable
+// Preferences.xml counts as not claimed.
+func plexServerClaimed(cfg *config.Config) bool {
+	dir, _, err := appdataPath(cfg, cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata"), "plex")
+	if err != nil {
+		return false
+	}
+	content, err := readPlexPreferences(filepath.Join(dir, "Library", "Application Support", "Plex Media Server", "Preferences.xml"))
+	if err != nil {
+		return false
+	}
+	return plexOnlineTokenPattern.Match(content)
+}
+
+// ensureFreshPlexClaimToken clears the stored claim token once the Plex
+// server has been claimed, since a token is single-use and only matters on
+// first start. Otherwise it warns when the token has probably expired and
+// offers to replace it. It reports whether the token was cleared or replaced.
+func ensureFreshPlexClaimToken(cfg *config.Config, ui *ui.UI) (bool, error) {
+	if cfg.GetOrDefault(config.KeyPlexClaimToken, "") == "" {
+		return false, nil
+	}
+
+	if plexServerClaimed(cfg) {
+		for _, key := range []string{config.KeyPlexClaimToken, config.KeyPlexClaimTokenSetAt} {
+			if err := cfg.Delete(key); err != nil {
+				return false, fmt.Errorf("failed to clear %s: %w", key, err)
+			}
+		}
+		ui.Info("Plex server is already claimed; cleared the stored claim token")
+		return true, nil
+	}
+
+	age, ok := plexClaimTokenAge(cfg, time.Now())
+	switch {
+	case !ok:
+		ui.Warning("Plex claim token age is unknown; it may have expired")
+	case age > plexClaimTokenMaxAge:
+		ui.Warningf("Plex claim token was entered %s ago and has probably expired", age.Round(time.Second))
+	default:
+		return false, nil
+	}
+	ui.Info("  Without a valid token Plex will not be claimed on first start")
+
+	refresh, err := ui.PromptYesNo("Enter a fresh Plex claim token now?", true)
+	if err != nil {
+		return false, fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !refresh {
+		return false, nil
+	}
+
+	ui.Info("  Get your claim token from: https://plex.tv/claim")
+	token, err := ui.PromptInputWithValidation("Plex claim token", "", common.ValidatePlexClaimToken)
+	if err != nil {
+		return false, err
+	}
+	if err := savePlexClaimToken(cfg, ui, token, time.Now()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // configureWebEnv configures web stack environment
 func configureWebEnv(cfg *config.Config, ui *ui.UI) error {
 	ui.Step("Configuring Web Stack Environment")
@@ -380,14 +722,78 @@ func configureWebEnv(cfg *config.Config, ui *ui.UI) error {
 		return err
 	}
 	if overseerrAPI != "" {
-		if err := cfg.Set("OVERSEERR_API_KEY", overseerrAPI); err != nil {
+		ui.AddSecret(overseerrAPI)
+		if err := cfg.SetSecret("OVERSEERR_API_KEY", overseerrAPI); err != nil {
 			return fmt.Errorf("failed to save OVERSEERR_API_KEY: %w", err)
 		}
 	}
 
+	// Host ports (defaults match the compose file)
+	for _, hp := range webHostPorts {
+		port, err := promptHostPort(ui, hp.Name, cfg.GetOrDefault(hp.Key, hp.Default))
+		if err != nil {
+			return err
+		}
+		if err := cfg.Set(hp.Key, port); err != nil {
+			return fmt.Errorf("failed to save %s: %w", hp.Key, err)
+		}
+	}
+
 	return nil
 }
 
+// hostPort is a configurable host port published by a stack
+type hostPort struct {
+	Key     string // Config and .env key
+	Name    string
+	Default string
+}
+
+// webHostPorts are the host ports published by the web stack
+var webHostPorts = []hostPort{
+	{"OVERSEERR_PORT", "Overseerr", "5055"},
+	{"WIZARR_PORT", "Wizarr", "5690"},
+	{"ORGANIZR_PORT", "Organizr", "9983"},
+	{"HOMEPAGE_PORT", "Homepage", "3000"},
+}
+
+// promptHostPort prompts for a host port and warns if something is already
+// listening on it, so conflicts surface before deployment
+func promptHostPort(ui *ui.UI, name, defaultPort string) (string, error) {
+	for {
+		port, err := ui.PromptInputWithValidation(fmt.Sprintf("%s host port", name), defaultPort, common.ValidatePort)
+		if err != nil {
+			return "", fmt.Errorf("failed to prompt for %s port: %w", name, err)
+		}
+
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return "", fmt.Errorf("invalid port number: %s", port)
+		}
+		free, err := system.IsPortFree(n)
+		if err != nil {
+			ui.Warningf("Could not check whether port %s is free: %v", port, err)
+			return port, nil
+		}
+		if free {
+			return port, nil
+		}
+
+		ui.Warningf("port %s is already in use by another process.", port)
+		ui.Info("  If this is the existing container for this service, it is safe to keep")
+		if ui.IsNonInteractive() {
+			return port, nil
+		}
+		useAnyway, err := ui.PromptYesNo(fmt.Sprintf("Use port %s anyway?", port), false)
+		if err != nil {
+			return "", fmt.Errorf("failed to prompt: %w", err)
+		}
+		if useAnyway {
+			return port, nil
+		}
+	}
+}
+
 // configureCloudEnv configures cloud stack environment
 func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 	ui.Step("Configuring Cloud Stack Environment")
@@ -409,7 +815,8 @@ func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 	if err != nil {
 		return err
 	}
-	if err := cfg.Set("NEXTCLOUD_ADMIN_PASSWORD", nextcloudAdminPass); err != nil {
+	ui.AddSecret(nextcloudAdminPass)
+	if err := cfg.SetSecret("NEXTCLOUD_ADMIN_PASSWORD", nextcloudAdminPass); err != nil {
 		return fmt.Errorf("failed to save NEXTCLOUD_ADMIN_PASSWORD: %w", err)
 	}
 
@@ -426,7 +833,8 @@ func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 	if err != nil {
 		return err
 	}
-	if err := cfg.Set("NEXTCLOUD_DB_PASSWORD", nextcloudDBPass); err != nil {
+	ui.AddSecret(nextcloudDBPass)
+	if err := cfg.SetSecret("NEXTCLOUD_DB_PASSWORD", nextcloudDBPass); err != nil {
 		return fmt.Errorf("failed to save NEXTCLOUD_DB_PASSWORD: %w", err)
 	}
 
@@ -489,7 +897,8 @@ func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 		if err != nil {
 			return err
 		}
-		if err := cfg.Set("COLLABORA_PASSWORD", collaboraPass); err != nil {
+		ui.AddSecret(collaboraPass)
+		if err := cfg.SetSecret("COLLABORA_PASSWORD", collaboraPass); err != nil {
 			return fmt.Errorf("failed to save COLLABORA_PASSWORD: %w", err)
 		}
 	} else {
@@ -497,7 +906,7 @@ func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 		if err := cfg.Set("COLLABORA_USERNAME", "admin"); err != nil {
 			return fmt.Errorf("failed to save COLLABORA_USERNAME: %w", err)
 		}
-		if err := cfg.Set("COLLABORA_PASSWORD", ""); err != nil {
+		if err := cfg.SetSecret("COLLABORA_PASSWORD", ""); err != nil {
 			return fmt.Errorf("failed to save COLLABORA_PASSWORD: %w", err)
 		}
 	}
@@ -525,7 +934,8 @@ func configureCloudEnv(cfg *config.Config, ui *ui.UI) error {
 	if err != nil {
 		return err
 	}
-	if err := cfg.Set("IMMICH_DB_PASSWORD", immichDBPass); err != nil {
+	ui.AddSecret(immichDBPass)
+	if err := cfg.SetSecret("IMMICH_DB_PASSWORD", immichDBPass); err != nil {
 		return fmt.Errorf("failed to save IMMICH_DB_PASSWORD: %w", err)
 	}
 
@@ -553,7 +963,16 @@ func createEnvFiles(cfg *config.Config, ui *ui.UI, selectedStacks []string) erro
 		envPath := filepath.Join(serviceDirectory(cfg, serviceName), ".env")
 		ui.Infof("Creating environment file: %s", envPath)
 
-		content := generateEnvContent(cfg, serviceName)
+		if serviceName == "media" {
+			if _, err := ensureFreshPlexClaimToken(cfg, ui); err != nil {
+				return err
+			}
+		}
+
+		content, err := generateEnvContent(cfg, serviceName)
+		if err != nil {
+			return err
+		}
 
 		// Write file
 		if err := system.WriteFile(envPath, []byte(content), 0600); err != nil {
@@ -571,124 +990,12 @@ func createEnvFiles(cfg *config.Config, ui *ui.UI, selectedStacks []string) erro
 	return nil
 }
 
-// generateEnvContent generates .env file content for a service
-func generateEnvContent(cfg *config.Config, serviceName string) string {
-	// Use PUID/PGID directly from user setup (not ENV_PUID/ENV_PGID)
-	// This ensures containers run with the actual service account UID/GID
-	puid := cfg.GetOrDefault("PUID", "1000")
-	pgid := cfg.GetOrDefault("PGID", "1000")
-	tz := cfg.GetOrDefault("TZ", "America/Chicago")
-	// Try APPDATA_BASE first (new standard), fall back to ENV_APPDATA_PATH (legacy)
-	appdataPath := cfg.GetOrDefault("APPDATA_BASE", "")
-	if appdataPath == "" {
-		appdataPath = cfg.GetOrDefault("ENV_APPDATA_PATH", "/var/lib/containers/appdata")
-	}
-
-	// Use cases.Title instead of deprecated strings.Title
-	caser := cases.Title(language.English)
-
-	content := fmt.Sprintf(`# UBlue uCore Homelab - %s Stack Environment
-# Generated by homelab-setup
-
-# User/Group Configuration
-PUID=%s
-PGID=%s
-TZ=%s
-
-# Paths
-APPDATA_PATH=%s
-
-`, caser.String(serviceName), puid, pgid, tz, appdataPath)
-
-	// Add service-specific variables
-	switch serviceName {
-	case "media":
-		content += fmt.Sprintf(`# Plex Configuration
-PLEX_CLAIM_TOKEN=%s
-# Get your claim token from: https://www.plex.tv/claim/
-
-# Jellyfin Configuration
-JELLYFIN_PUBLIC_URL=%s
-
-# Hardware Transcoding
-# Intel QuickSync device for hardware transcoding
-TRANSCODE_DEVICE=/dev/dri
-
-# Note: Media paths are configured in the compose file
-# Ensure NFS mounts are set up at /mnt/nas-media before starting services
-
-`, cfg.GetOrDefault("PLEX_CLAIM_TOKEN", ""),
-			cfg.GetOrDefault("JELLYFIN_PUBLIC_URL", ""))
-
-	case "web":
-		content += fmt.Sprintf(`# Overseerr Configuration (optional - configure in UI)
-OVERSEERR_API_KEY=%s
-
-# Web Service Ports (default values from compose file)
-OVERSEERR_PORT=5055
-WIZARR_PORT=5690
-ORGANIZR_PORT=9983
-HOMEPAGE_PORT=3000
-
-# Note: These services are typically accessed via reverse proxy
-# Configure your reverse proxy to route to these ports via WireGuard tunnel
-
-`, cfg.GetOrDefault("OVERSEERR_API_KEY", ""))
-
-	case "cloud":
-		content += fmt.Sprintf(`# Nextcloud Admin Credentials (for initial setup)
-NEXTCLOUD_ADMIN_USER=%s
-NEXTCLOUD_ADMIN_PASSWORD=%s
-
-# Nextcloud Database Configuration
-NEXTCLOUD_DB_USERNAME=%s
-NEXTCLOUD_DB_PASSWORD=%s
-NEXTCLOUD_DB_DATABASE=%s
-
-# Nextcloud Domain Configuration
-NEXTCLOUD_TRUSTED_DOMAINS=%s
-NEXTCLOUD_OVERWRITE_HOST=%s
-
-# Nextcloud PHP Limits
-NEXTCLOUD_PHP_MEMORY_LIMIT=%s
-NEXTCLOUD_PHP_UPLOAD_LIMIT=%s
-
-# Collabora Online Configuration (optional)
-COLLABORA_USERNAME=%s
-COLLABORA_PASSWORD=%s
-COLLABORA_DOMAIN=%s
-
-# Immich Database Configuration
-IMMICH_DB_USERNAME=%s
-IMMICH_DB_PASSWORD=%s
-IMMICH_DB_DATABASE=%s
-
-`, cfg.GetOrDefault("NEXTCLOUD_ADMIN_USER", "admin"),
-			cfg.GetOrDefault("NEXTCLOUD_ADMIN_PASSWORD", ""),
-			cfg.GetOrDefault("NEXTCLOUD_DB_USERNAME", "nc_user"),
-			cfg.GetOrDefault("NEXTCLOUD_DB_PASSWORD", ""),
-			cfg.GetOrDefault("NEXTCLOUD_DB_DATABASE", "nextcloud"),
-			cfg.GetOrDefault("NEXTCLOUD_TRUSTED_DOMAINS", "localhost"),
-			cfg.GetOrDefault("NEXTCLOUD_OVERWRITE_HOST", "localhost"),
-			cfg.GetOrDefault("NEXTCLOUD_PHP_MEMORY_LIMIT", "1024M"),
-			cfg.GetOrDefault("NEXTCLOUD_PHP_UPLOAD_LIMIT", "1024M"),
-			cfg.GetOrDefault("COLLABORA_USERNAME", "admin"),
-			cfg.GetOrDefault("COLLABORA_PASSWORD", ""),
-			cfg.GetOrDefault("COLLABORA_DOMAIN", "localhost"),
-			cfg.GetOrDefault("IMMICH_DB_USERNAME", "postgres"),
-			cfg.GetOrDefault("IMMICH_DB_PASSWORD", ""),
-			cfg.GetOrDefault("IMMICH_DB_DATABASE", "immich"))
-	}
-
-	return content
-}
-
 // RunContainerSetup executes the container setup step
-func RunContainerSetup(cfg *config.Config, ui *ui.UI) error {
+func RunContainerSetup(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Check if already completed
 	if cfg.IsComplete(containerSetupCompletionMarker) {
 		ui.Info("Container setup already completed (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + containerSetupCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), containerSetupCompletionMarker))
 		return nil
 	}
 
@@ -726,7 +1033,9 @@ func RunContainerSetup(cfg *config.Config, ui *ui.UI) error {
 	}
 
 	// Create base environment configuration
-	createBaseEnvConfig(cfg, ui)
+	if err := createBaseEnvConfig(cfg, ui); err != nil {
+		return fmt.Errorf("failed to configure base environment: %w", err)
+	}
 
 	// Configure each selected stack
 	for _, serviceName := range selectedStacks {
@@ -736,6 +1045,11 @@ func RunContainerSetup(cfg *config.Config, ui *ui.UI) error {
 		}
 	}
 
+	// Stop before persisting anything if the operation was interrupted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Create .env files
 	if err := createEnvFiles(cfg, ui, selectedStacks); err != nil {
 		return fmt.Errorf("failed to create .env files: %w", err)
@@ -743,7 +1057,7 @@ func RunContainerSetup(cfg *config.Config, ui *ui.UI) error {
 
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ Container stack setup completed")
+	ui.Successf("%s Container stack setup completed", ui.GlyphOK())
 	ui.Infof("Configured %d stack(s): %s", len(selectedStacks), strings.Join(selectedStacks, ", "))
 
 	// Create completion marker
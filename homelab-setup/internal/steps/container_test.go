@@ -0,0 +1,271 @@
+package steps
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestPlexClaimTokenAge(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		setAt   string
+		wantAge time.Duration
+		wantOK  bool
+	}{
+		{"fresh", now.Add(-90 * time.Second).Format(time.RFC3339), 90 * time.Second, true},
+		{"stale", now.Add(-10 * time.Minute).Format(time.RFC3339), 10 * time.Minute, true},
+		{"missing", "", 0, false},
+		{"malformed", "yesterday", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New(filepath.Join(t.TempDir(), "config"))
+			if tt.setAt != "" {
+				if err := cfg.Set(config.KeyPlexClaimTokenSetAt, tt.setAt); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+
+			age, ok := plexClaimTokenAge(cfg, now)
+			if ok != tt.wantOK || age != tt.wantAge {
+				t.Errorf("plexClaimTokenAge() = %v, %v; want %v, %v", age, ok, tt.wantAge, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEnsureFreshPlexClaimTokenClearsAfterClaim(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences string
+		wantCleared bool
+	}{
+		{"claimed", `<Preferences MachineIdentifier="abc" PlexOnlineToken="xyz123" PlexOnlineUsername="me"/>`, true},
+		{"unclaimed", `<Preferences MachineIdentifier="abc" PlexOnlineToken=""/>`, false},
+		{"first start", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readPath string
+			orig := readPlexPreferences
+			t.Cleanup(func() { readPlexPreferences = orig })
+			readPlexPreferences = func(path string) ([]byte, error) {
+				readPath = path
+				if tt.preferences == "" {
+					return nil, os.ErrNotExist
+				}
+				return []byte(tt.preferences), nil
+			}
+
+			cfg := config.New(filepath.Join(t.TempDir(), "config"))
+			for key, value := range map[string]string{
+				"APPDATA_BASE":                "/var/lib/containers/appdata",
+				config.KeyPlexClaimToken:      "claim-abc123",
+				config.KeyPlexClaimTokenSetAt: time.Now().UTC().Format(time.RFC3339),
+			} {
+				if err := cfg.Set(key, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changed, err := ensureFreshPlexClaimToken(cfg, ui.NewWithWriter(&bytes.Buffer{}))
+			if err != nil {
+				t.Fatalf("ensureFreshPlexClaimToken() error = %v", err)
+			}
+			if changed != tt.wantCleared {
+				t.Errorf("ensureFreshPlexClaimToken() = %v, want %v", changed, tt.wantCleared)
+			}
+			if cleared := !cfg.Exists(config.KeyPlexClaimToken); cleared != tt.wantCleared {
+				t.Errorf("token cleared = %v, want %v", cleared, tt.wantCleared)
+			}
+			if want := "/var/lib/containers/appdata/plex/Library/Application Support/Plex Media Server/Preferences.xml"; readPath != want {
+				t.Errorf("read %q, want %q", readPath, want)
+			}
+		})
+	}
+}
+
+func TestTranscodeEnvLines(t *testing.T) {
+	withDevice := transcodeEnvLines("/dev/dri/renderD128")
+	if !strings.Contains(withDevice, "TRANSCODE_DEVICE=/dev/dri/renderD128\n") {
+		t.Errorf("expected TRANSCODE_DEVICE line, got %q", withDevice)
+	}
+
+	none := transcodeEnvLines("")
+	if strings.Contains(none, "TRANSCODE_DEVICE=") {
+		t.Errorf("expected TRANSCODE_DEVICE to be omitted, got %q", none)
+	}
+}
+
+func TestTranscodeDeviceUsesConfiguredValue(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyTranscodeDevice, ""); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := transcodeDevice(cfg); got != "" {
+		t.Errorf("transcodeDevice() = %q, want empty (software transcoding chosen)", got)
+	}
+
+	if err := cfg.Set(config.KeyTranscodeDevice, "/dev/dri/renderD129"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := transcodeDevice(cfg); got != "/dev/dri/renderD129" {
+		t.Errorf("transcodeDevice() = %q, want /dev/dri/renderD129", got)
+	}
+}
+
+func TestStackDescription(t *testing.T) {
+	if got := stackDescription("media", "media.yml"); got != "media - Plex, Jellyfin, Tautulli" {
+		t.Errorf("stackDescription(media) = %q", got)
+	}
+	if got := stackDescription("extra", "extra.yml"); got != "extra (extra.yml)" {
+		t.Errorf("stackDescription(extra) = %q", got)
+	}
+}
+
+func TestPreviousSelection(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := previousSelection(cfg, []string{"cloud", "media", "web"}); len(got) != 0 {
+		t.Errorf("previousSelection() with nothing saved = %v, want empty", got)
+	}
+
+	if err := cfg.Set(config.KeySelectedServices, "web removed media"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got := previousSelection(cfg, []string{"cloud", "media", "web"})
+	if strings.Join(got, " ") != "web media" {
+		t.Errorf("previousSelection() = %v, want [web media]", got)
+	}
+}
+
+func TestVerifyTemplateChecksum(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "media.yml")
+	if err := os.WriteFile(templatePath, []byte("services:\n  plex: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := system.FileSHA256(templatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := strings.Repeat("0", 64)
+
+	if err := os.WriteFile(filepath.Join(dir, checksumManifestName), []byte(digest+"  media.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := loadChecksumManifest(dir)
+	if err != nil {
+		t.Fatalf("loadChecksumManifest() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		override string
+		manifest map[string]string
+		wantErr  bool
+		wantOut  string
+	}{
+		{"manifest match", "", manifest, false, "Checksum verified"},
+		{"config overrides manifest", wrong, manifest, true, ""},
+		{"config match", digest, nil, false, "Checksum verified"},
+		{"no checksum notes it", "", nil, false, "No checksum configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New(filepath.Join(t.TempDir(), "config"))
+			if tt.override != "" {
+				if err := cfg.Set("COMPOSE_SHA256_MEDIA", tt.override); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+			var buf bytes.Buffer
+			u := ui.NewWithWriter(&buf)
+			err := verifyTemplateChecksum(cfg, u, tt.manifest, "media", templatePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyTemplateChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, system.ErrChecksumMismatch) {
+				t.Errorf("error = %v, want ErrChecksumMismatch", err)
+			}
+			if !strings.Contains(buf.String(), tt.wantOut) {
+				t.Errorf("output missing %q:\n%s", tt.wantOut, buf.String())
+			}
+			if warnings := u.Warnings(); len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %v", warnings)
+			}
+		})
+	}
+}
+
+func TestLoadChecksumManifestMissing(t *testing.T) {
+	manifest, err := loadChecksumManifest(t.TempDir())
+	if err != nil || manifest != nil {
+		t.Errorf("loadChecksumManifest() = (%v, %v), want (nil, nil)", manifest, err)
+	}
+}
+
+func TestPickStacksDoesNotSave(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	u := ui.NewWithWriter(&bytes.Buffer{})
+	// Non-interactive mode selects every option, including "All stacks"
+	u.SetNonInteractive(true)
+
+	got, err := pickStacks(cfg, u, standardStacks())
+	if err != nil {
+		t.Fatalf("pickStacks() error = %v", err)
+	}
+	if strings.Join(got, " ") != "cloud media web" {
+		t.Errorf("pickStacks() = %v, want [cloud media web]", got)
+	}
+	if cfg.Exists(config.KeySelectedServices) {
+		t.Error("pickStacks() saved SELECTED_SERVICES, want it left to the caller")
+	}
+}
+
+func TestPUIDPGIDWarnings(t *testing.T) {
+	origUser, origGroup, origInfo := userIDExists, groupIDExists, lookupUserInfo
+	t.Cleanup(func() { userIDExists, groupIDExists, lookupUserInfo = origUser, origGroup, origInfo })
+	userIDExists = func(uid string) (bool, error) { return uid == "1000" || uid == "1001", nil }
+	groupIDExists = func(gid string) (bool, error) { return gid == "1000" || gid == "1001", nil }
+	lookupUserInfo = func(username string) (*user.User, error) {
+		return &user.User{Username: username, Uid: "1001", Gid: "1001"}, nil
+	}
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyHomelabUser, "media"); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings, err := puidPGIDWarnings(cfg, "1001", "1001")
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("puidPGIDWarnings(1001, 1001) = %v, %v; want no warnings", warnings, err)
+	}
+
+	warnings, err = puidPGIDWarnings(cfg, "1000", "1001")
+	if err != nil || len(warnings) != 1 || warnings[0] != "PUID=1000 but homelab user 'media' has UID 1001" {
+		t.Errorf("puidPGIDWarnings(1000, 1001) = %q, %v; want a UID mismatch warning", warnings, err)
+	}
+
+	warnings, err = puidPGIDWarnings(cfg, "1001", "4242")
+	if err != nil || len(warnings) != 2 || !strings.Contains(warnings[0], "PGID=4242 does not belong to any group") {
+		t.Errorf("puidPGIDWarnings(1001, 4242) = %q, %v; want unknown group and mismatch warnings", warnings, err)
+	}
+
+	if _, err := puidPGIDWarnings(cfg, "media", "1001"); err == nil {
+		t.Error("puidPGIDWarnings() with a non-numeric PUID succeeded, want error")
+	}
+}
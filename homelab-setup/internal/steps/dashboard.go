@@ -0,0 +1,287 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// Sources read by the dashboard, replaced in tests
+var (
+	dashboardUnits        system.ServiceManager = system.NewServiceManager()
+	dashboardProbeHealth                        = probeHealth
+	dashboardMemInfo                            = system.ReadMemInfo
+	dashboardDiskUsage                          = system.GetDiskUsage
+	dashboardFSType                             = system.FilesystemType
+	dashboardPeers                              = system.GetWireGuardPeerStatus
+	readDashboardKeys                           = ui.ReadKeys
+	dashboardHasInterface                       = func(name string) bool {
+		_, err := net.InterfaceByName(name)
+		return err == nil
+	}
+)
+
+// dashboardSection is one table of the dashboard
+type dashboardSection struct {
+	title   string
+	headers []string
+	rows    [][]string
+}
+
+// dashboardSnapshot is everything the dashboard shows at one point in time
+type dashboardSnapshot struct {
+	taken    time.Time
+	sections []dashboardSection
+}
+
+// dashboardRefresh returns DASHBOARD_REFRESH as a duration
+func dashboardRefresh(cfg *config.Config) time.Duration {
+	seconds, err := strconv.Atoi(cfg.GetOrDefault(config.KeyDashboardRefresh, "5"))
+	if err != nil || seconds < 1 {
+		seconds = 5
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// markerRow describes whether a setup step has completed and when
+func markerRow(cfg *config.Config, label, marker string) []string {
+	completedAt, ok := cfg.MarkerInfo(marker)
+	switch {
+	case ok && !completedAt.IsZero():
+		return []string{label, "passed", completedAt.Local().Format("2006-01-02 15:04")}
+	case ok:
+		return []string{label, "passed", ""}
+	default:
+		return []string{label, "not run", ""}
+	}
+}
+
+// dashboardSetupSection reports the preflight and deployment markers
+func dashboardSetupSection(cfg *config.Config) dashboardSection {
+	return dashboardSection{
+		title:   "Setup",
+		headers: []string{"Step", "Status", "Completed"},
+		rows: [][]string{
+			markerRow(cfg, "Pre-flight checks", preflightCompletionMarker),
+			markerRow(cfg, "Service deployment", deploymentCompletionMarker),
+		},
+	}
+}
+
+// dashboardServicesSection reports each selected stack's unit and the
+// health of its web UIs. Stacks whose unit is not active are not probed.
+func dashboardServicesSection(ctx context.Context, cfg *config.Config) dashboardSection {
+	section := dashboardSection{
+		title:   "Services",
+		headers: []string{"Stack", "Unit", "Health"},
+	}
+	for _, name := range SelectedServices(cfg) {
+		serviceInfo := getServiceInfo(cfg, name)
+		active, err := dashboardUnits.IsActive(serviceInfo.UnitName)
+		switch {
+		case err != nil:
+			section.rows = append(section.rows, []string{name, "unknown", err.Error()})
+			continue
+		case !active:
+			section.rows = append(section.rows, []string{name, "inactive", "-"})
+			continue
+		}
+
+		health := "no web UI"
+		if len(serviceInfo.Health) > 0 {
+			var failing []string
+			for i, result := range dashboardProbeHealth(ctx, serviceInfo) {
+				if result.State != system.HealthOK {
+					failing = append(failing, fmt.Sprintf("%s %s", serviceInfo.Health[i].Name, result.State))
+				}
+			}
+			if len(failing) == 0 {
+				health = fmt.Sprintf("%d/%d healthy", len(serviceInfo.Health), len(serviceInfo.Health))
+			} else {
+				health = strings.Join(failing, ", ")
+			}
+		}
+		section.rows = append(section.rows, []string{name, "active", health})
+	}
+	return section
+}
+
+// dashboardResourcesSection reports memory and the space left on the
+// container and appdata filesystems
+func dashboardResourcesSection(cfg *config.Config) dashboardSection {
+	section := dashboardSection{
+		title:   "Resources",
+		headers: []string{"Resource", "Used", "Free", "Status"},
+	}
+
+	if mem, err := dashboardMemInfo(); err != nil {
+		section.rows = append(section.rows, []string{"Memory", "", "", err.Error()})
+	} else {
+		used := mem.TotalBytes - mem.AvailableBytes
+		section.rows = append(section.rows, []string{
+			"Memory",
+			fmt.Sprintf("%s of %s", system.FormatBytes(int64(used)), system.FormatBytes(int64(mem.TotalBytes))),
+			system.FormatBytes(int64(mem.AvailableBytes)),
+			"ok",
+		})
+	}
+
+	for _, path := range []string{
+		cfg.GetOrDefault(config.KeyContainersBase, "/srv/containers"),
+		cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata"),
+	} {
+		total, used, free, err := dashboardDiskUsage(path)
+		if err != nil {
+			section.rows = append(section.rows, []string{path, "", "", "not available"})
+			continue
+		}
+		status := "ok"
+		if free < lowDiskSpaceThreshold {
+			status = "low"
+		}
+		section.rows = append(section.rows, []string{
+			path,
+			fmt.Sprintf("%s of %s", system.FormatBytes(int64(used)), system.FormatBytes(int64(total))),
+			system.FormatBytes(int64(free)),
+			status,
+		})
+	}
+	return section
+}
+
+// dashboardNetworkSection reports whether the NFS share is mounted and the
+// WireGuard peers with a recent handshake
+func dashboardNetworkSection(cfg *config.Config, now time.Time) dashboardSection {
+	section := dashboardSection{
+		title:   "Network",
+		headers: []string{"Component", "Status", "Detail"},
+	}
+
+	if server := cfg.GetOrDefault(config.KeyNFSServer, ""); server == "" {
+		section.rows = append(section.rows, []string{"NFS", "not configured", ""})
+	} else {
+		mountPoint := cfg.GetOrDefault(config.KeyNFSMountPoint, "/mnt/nas")
+		fsType, err := dashboardFSType(mountPoint)
+		switch {
+		case err != nil:
+			section.rows = append(section.rows, []string{"NFS", "unknown", err.Error()})
+		case system.IsNetworkFilesystem(fsType):
+			section.rows = append(section.rows, []string{"NFS", "mounted", fmt.Sprintf("%s on %s (%s)", server, mountPoint, fsType)})
+		default:
+			section.rows = append(section.rows, []string{"NFS", "not mounted", mountPoint})
+		}
+	}
+
+	interfaceName := cfg.GetOrDefault(config.KeyWGInterface, "wg0")
+	label := "WireGuard " + interfaceName
+	if !dashboardHasInterface(interfaceName) {
+		section.rows = append(section.rows, []string{label, "down", "interface not present"})
+		return section
+	}
+	peers, err := dashboardPeers(interfaceName)
+	if err != nil {
+		section.rows = append(section.rows, []string{label, "up", err.Error()})
+		return section
+	}
+	recent := 0
+	for _, peer := range peers {
+		if _, stale := handshakeAge(peer.LatestHandshake, now); !stale {
+			recent++
+		}
+	}
+	section.rows = append(section.rows, []string{label, "up", fmt.Sprintf("%d/%d peer(s) with a recent handshake", recent, len(peers))})
+	return section
+}
+
+// collectDashboard gathers one snapshot of the dashboard. It only reads
+// state; nothing is started, stopped or written.
+func collectDashboard(ctx context.Context, cfg *config.Config) dashboardSnapshot {
+	now := time.Now()
+	return dashboardSnapshot{
+		taken: now,
+		sections: []dashboardSection{
+			dashboardSetupSection(cfg),
+			dashboardServicesSection(ctx, cfg),
+			dashboardResourcesSection(cfg),
+			dashboardNetworkSection(cfg, now),
+		},
+	}
+}
+
+// renderDashboard prints a snapshot as one table per section
+func renderDashboard(ui *ui.UI, snapshot dashboardSnapshot) {
+	ui.Header("Homelab Dashboard")
+	ui.Infof("Profile %s, updated %s", config.ActiveProfile(), snapshot.taken.Format("15:04:05"))
+	for _, section := range snapshot.sections {
+		ui.Print("")
+		ui.Step(section.title)
+		if len(section.rows) == 0 {
+			ui.Print("  (none)")
+			continue
+		}
+		ui.Table(section.headers, section.rows)
+	}
+}
+
+// dashboardFrame returns the draw function for one frame of the live
+// dashboard
+func dashboardFrame(snapshot dashboardSnapshot, refresh time.Duration) func(*ui.UI) {
+	return func(frame *ui.UI) {
+		renderDashboard(frame, snapshot)
+		frame.Print("")
+		frame.Infof("Refreshing every %s; press q to quit, any other key to refresh now", refresh)
+	}
+}
+
+// isDashboardQuitKey reports whether key leaves the live dashboard: q, Esc
+// or Ctrl-C, which raw mode delivers as a byte instead of a signal
+func isDashboardQuitKey(key byte) bool {
+	return key == 'q' || key == 'Q' || key == 27 || key == 3
+}
+
+// RunDashboard shows the setup markers, the state and health of the selected
+// stacks, memory and disk space, and the NFS and WireGuard status on one
+// screen, refreshed every DASHBOARD_REFRESH seconds until q is pressed. It is
+// read-only. Without an interactive terminal it prints a single snapshot.
+func RunDashboard(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+	if !ui.CanRedraw() {
+		spinner := ui.Spinner("Collecting status...")
+		spinner.Start()
+		snapshot := collectDashboard(ctx, cfg)
+		spinner.Success("Collected status")
+		renderDashboard(ui, snapshot)
+		return nil
+	}
+
+	keys, restore, err := readDashboardKeys()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	refresh := dashboardRefresh(cfg)
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	for {
+		snapshot := collectDashboard(ctx, cfg)
+		ui.Redraw(dashboardFrame(snapshot, refresh))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case key := <-keys:
+			if isDashboardQuitKey(key) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
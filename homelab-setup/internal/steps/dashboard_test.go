@@ -0,0 +1,128 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// fakeDashboard replaces the dashboard's sources for one test
+func fakeDashboard(t *testing.T) {
+	t.Helper()
+	origUnits, origProbe, origMem, origDisk := dashboardUnits, dashboardProbeHealth, dashboardMemInfo, dashboardDiskUsage
+	origFS, origPeers, origIface := dashboardFSType, dashboardPeers, dashboardHasInterface
+	t.Cleanup(func() {
+		dashboardUnits, dashboardProbeHealth, dashboardMemInfo, dashboardDiskUsage = origUnits, origProbe, origMem, origDisk
+		dashboardFSType, dashboardPeers, dashboardHasInterface = origFS, origPeers, origIface
+	})
+
+	dashboardUnits = activeUnits{"docker-compose-media.service": true}
+	dashboardProbeHealth = func(_ context.Context, info *ServiceInfo) []system.HealthResult {
+		results := make([]system.HealthResult, len(info.Health))
+		for i, endpoint := range info.Health {
+			if endpoint.Name == "Jellyfin" {
+				results[i].State = system.HealthRefused
+			}
+		}
+		return results
+	}
+	dashboardMemInfo = func() (system.MemInfo, error) {
+		return system.MemInfo{TotalBytes: 8 << 30, AvailableBytes: 6 << 30}, nil
+	}
+	dashboardDiskUsage = func(path string) (uint64, uint64, uint64, error) {
+		if path == "/srv/containers" {
+			return 100 << 30, 95 << 30, 5 << 30, nil
+		}
+		return 0, 0, 0, errors.New("no such file or directory")
+	}
+	dashboardFSType = func(string) (string, error) { return "nfs4", nil }
+	dashboardHasInterface = func(string) bool { return true }
+	dashboardPeers = func(string) ([]system.WireGuardPeerStatus, error) {
+		return []system.WireGuardPeerStatus{
+			{PublicKey: "a", LatestHandshake: time.Now().Add(-time.Minute)},
+			{PublicKey: "b"},
+		}, nil
+	}
+}
+
+func TestCollectDashboard(t *testing.T) {
+	fakeDashboard(t)
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.SetMany(map[string]string{
+		config.KeySelectedServices: "media web",
+		config.KeyNFSServer:        "192.168.1.10",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := collectDashboard(context.Background(), cfg)
+	rows := make(map[string][][]string)
+	for _, section := range snapshot.sections {
+		rows[section.title] = section.rows
+	}
+
+	wantServices := [][]string{
+		{"media", "active", "Jellyfin not up yet"},
+		{"web", "inactive", "-"},
+	}
+	if !reflect.DeepEqual(rows["Services"], wantServices) {
+		t.Errorf("Services = %v, want %v", rows["Services"], wantServices)
+	}
+
+	resources := rows["Resources"]
+	if len(resources) != 3 {
+		t.Fatalf("Resources = %v, want memory and two filesystems", resources)
+	}
+	if got := resources[1]; got[0] != "/srv/containers" || got[3] != "low" {
+		t.Errorf("containers row = %v, want low free space", got)
+	}
+	if got := resources[2][3]; got != "not available" {
+		t.Errorf("appdata status = %q, want not available", got)
+	}
+
+	wantNetwork := [][]string{
+		{"NFS", "mounted", "192.168.1.10 on /mnt/nas (nfs4)"},
+		{"WireGuard wg0", "up", "1/2 peer(s) with a recent handshake"},
+	}
+	if !reflect.DeepEqual(rows["Network"], wantNetwork) {
+		t.Errorf("Network = %v, want %v", rows["Network"], wantNetwork)
+	}
+}
+
+func TestRunDashboardPrintsSnapshotWithoutTerminal(t *testing.T) {
+	fakeDashboard(t)
+	orig := readDashboardKeys
+	readDashboardKeys = func() (<-chan byte, func(), error) {
+		t.Fatal("the dashboard must not read keys without a terminal")
+		return nil, nil, nil
+	}
+	t.Cleanup(func() { readDashboardKeys = orig })
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	var buf bytes.Buffer
+	u := ui.NewWithWriter(&buf)
+	u.SetNonInteractive(true)
+
+	if err := RunDashboard(context.Background(), cfg, u); err != nil {
+		t.Fatalf("RunDashboard() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Homelab Dashboard", "Pre-flight checks", "not run", "NFS", "not configured"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\033[2J") {
+		t.Error("snapshot output must not clear the screen")
+	}
+}
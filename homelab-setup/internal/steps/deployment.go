@@ -1,11 +1,16 @@
 package steps
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -29,6 +34,7 @@ type ServiceInfo struct {
 	DisplayName string
 	Directory   string
 	UnitName    string
+	Health      []HealthEndpoint // Web UIs probed once the stack is running
 }
 
 // getServiceInfo returns information about a service
@@ -48,6 +54,7 @@ func getServiceInfo(cfg *config.Config, serviceName string) *ServiceInfo {
 		DisplayName: caser.String(serviceName),
 		Directory:   filepath.Join(getServiceBaseDir(cfg), serviceName),
 		UnitName:    fmt.Sprintf("%s-%s.service", unitPrefix, serviceName),
+		Health:      healthEndpoints(cfg, serviceName),
 	}
 }
 
@@ -58,17 +65,63 @@ func getNFSMountPointReal(cfg *config.Config) string {
 	return cfg.GetOrDefault(config.KeyNFSMountPointReal, cfg.GetOrDefault(config.KeyNFSMountPoint, ""))
 }
 
+// splitServiceList splits a SELECTED_SERVICES value on commas and
+// whitespace, so "media,web" and "media web" read the same, dropping
+// repeated names
+func splitServiceList(value string) []string {
+	seen := make(map[string]bool)
+	var services []string
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if !seen[name] {
+			seen[name] = true
+			services = append(services, name)
+		}
+	}
+	return services
+}
+
+// SelectedServices returns the stacks in SELECTED_SERVICES, in order
+func SelectedServices(cfg *config.Config) []string {
+	return splitServiceList(cfg.GetOrDefault(config.KeySelectedServices, ""))
+}
+
 // getSelectedServices returns the list of selected services from config
 func getSelectedServices(cfg *config.Config) ([]string, error) {
-	selectedStr := cfg.GetOrDefault("SELECTED_SERVICES", "")
-	if selectedStr == "" {
+	services := SelectedServices(cfg)
+	if len(services) == 0 {
 		return nil, fmt.Errorf("no services selected (run container setup first)")
 	}
-
-	services := strings.Fields(selectedStr)
 	return services, nil
 }
 
+// stackNamePattern matches stack names that can be stored in
+// SELECTED_SERVICES and used in unit and directory names
+var stackNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// normalizeSelectedServices returns the canonical SELECTED_SERVICES value
+// for a comma- or space-separated list: space-separated, without
+// duplicates, in the given order. Every name must be a stack in catalog
+// (stack name to template file) unless catalog is nil.
+func normalizeSelectedServices(value string, catalog map[string]string) (string, error) {
+	services := splitServiceList(value)
+	for _, name := range services {
+		if !stackNamePattern.MatchString(name) {
+			return "", fmt.Errorf("invalid stack name %q", name)
+		}
+		if catalog != nil {
+			if _, ok := catalog[name]; !ok {
+				available := make([]string, 0, len(catalog))
+				for stack := range catalog {
+					available = append(available, stack)
+				}
+				sort.Strings(available)
+				return "", fmt.Errorf("unknown stack %q (available: %s)", name, strings.Join(available, ", "))
+			}
+		}
+	}
+	return strings.Join(services, " "), nil
+}
+
 // checkExistingService checks if a systemd service exists
 func checkExistingService(_ *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) (bool, error) {
 	ui.Infof("Checking for service: %s", serviceInfo.UnitName)
@@ -162,6 +215,141 @@ func formatComposeCommandForSystemd(composeCmd string) string {
 	return composeCmd
 }
 
+// composeOverrideFile is the per-service file users edit to customise a stack.
+// The tool never writes it, so local changes survive template updates.
+const composeOverrideFile = "compose.override.yml"
+
+// composeFileArgs returns the -f arguments selecting a service's compose
+// files: the generated compose file first, then compose.override.yml when
+// present so its values take precedence. Paths are relative to dir. Returns
+// nil if dir has no compose file.
+func composeFileArgs(dir string) (args []string, overridden bool) {
+	composeFile, ok := findComposeFile(dir)
+	if !ok {
+		return nil, false
+	}
+	args = []string{"-f", filepath.Base(composeFile)}
+	if exists, _ := system.FileExists(filepath.Join(dir, composeOverrideFile)); exists {
+		args = append(args, "-f", composeOverrideFile)
+		overridden = true
+	}
+	return args, overridden
+}
+
+// unitHeader opens every generated stack unit. The compose files are passed
+// as -f arguments, which are fixed when the unit is written.
+const unitHeader = `# Generated by homelab-setup. The compose files (-f) are fixed when this
+# unit is written: re-run service deployment after adding or removing
+# ` + composeOverrideFile + `.
+`
+
+// unitFilePath returns where a stack's systemd unit is installed
+func unitFilePath(serviceInfo *ServiceInfo) string {
+	return filepath.Join("/etc/systemd/system", serviceInfo.UnitName)
+}
+
+// unitOverrideMismatch compares the compose files baked into a unit with
+// whether compose.override.yml exists now, returning an error describing a
+// difference that needs the unit to be regenerated
+func unitOverrideMismatch(unitContent string, overridden bool) error {
+	inUnit := strings.Contains(unitContent, "-f "+composeOverrideFile)
+	switch {
+	case overridden && !inUnit:
+		return fmt.Errorf("%s was added after the unit was written and is ignored; re-run service deployment", composeOverrideFile)
+	case !overridden && inUnit:
+		return fmt.Errorf("the unit uses %s, which no longer exists, so the stack will fail to start; re-run service deployment", composeOverrideFile)
+	}
+	return nil
+}
+
+// listComposeServices reads the services declared by compose files, replaced
+// in tests
+var listComposeServices = system.ListComposeServices
+
+// expectedComposeServices returns the compose services a stack should
+// declare. The appdata directories are named after the services, so
+// stackAppdataDirs doubles as the list; custom stacks have no expectation.
+func expectedComposeServices(serviceName string) []string {
+	return stackAppdataDirs[serviceName]
+}
+
+// compareComposeServices returns the expected services missing from declared
+// and the declared services that are not expected, both sorted
+func compareComposeServices(expected, declared []string) (missing, extra []string) {
+	declaredSet := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		declaredSet[name] = true
+	}
+	expectedSet := make(map[string]bool, len(expected))
+	for _, name := range expected {
+		expectedSet[name] = true
+		if !declaredSet[name] {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range declared {
+		if !expectedSet[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+// checkComposeServices reads the services the stack's compose file (and
+// override) declares and warns when they differ from what the stack is
+// expected to run, so a media stack without a plex service is noticed before
+// anything is pulled. Differences are warnings: users may trim or extend a
+// stack on purpose.
+func checkComposeServices(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
+	composeFile, ok := findComposeFile(serviceInfo.Directory)
+	if !ok {
+		return fmt.Errorf("no compose file found in %s", serviceInfo.Directory)
+	}
+	files := []string{composeFile}
+	if _, overridden := composeFileArgs(serviceInfo.Directory); overridden {
+		files = append(files, filepath.Join(serviceInfo.Directory, composeOverrideFile))
+	}
+
+	runtime, err := getRuntimeFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	composeCmd, err := detectComposeCommand(cfg, runtime)
+	if err != nil {
+		return fmt.Errorf("failed to detect compose command: %w", err)
+	}
+
+	declared, err := listComposeServices(composeCmd, files...)
+	if err != nil {
+		return err
+	}
+	if len(declared) == 0 {
+		return fmt.Errorf("%s declares no services", filepath.Base(composeFile))
+	}
+	ui.Infof("Compose services: %s", strings.Join(declared, ", "))
+
+	expected := expectedComposeServices(serviceInfo.Name)
+	if len(expected) == 0 {
+		return nil
+	}
+	missing, extra := compareComposeServices(expected, declared)
+	if len(missing) > 0 {
+		ui.Warningf("%s stack is expected to run %s, but the compose file has no such service(s)",
+			serviceInfo.DisplayName, strings.Join(missing, ", "))
+		ui.Infof("Check that %s is the %s compose file", composeFile, serviceInfo.Name)
+	}
+	if len(extra) > 0 {
+		ui.Warningf("Compose file declares service(s) not normally part of the %s stack: %s",
+			serviceInfo.Name, strings.Join(extra, ", "))
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		ui.Successf("Compose file declares the expected %s services", serviceInfo.Name)
+	}
+	return nil
+}
+
 // createComposeService creates a systemd service for docker-compose/podman-compose
 // For Docker runtime, creates system-level units that depend on docker.service and NFS mounts
 // For Podman runtime, maintains rootless behavior with User= directive
@@ -237,6 +425,12 @@ After=%s
 
 	// Format compose command for systemd Exec directives
 	execComposeCmd := formatComposeCommandForSystemd(composeCmd)
+	if fileArgs, overridden := composeFileArgs(serviceInfo.Directory); len(fileArgs) > 0 {
+		execComposeCmd += " " + strings.Join(fileArgs, " ")
+		if overridden {
+			ui.Infof("Compose override in effect: %s", filepath.Join(serviceInfo.Directory, composeOverrideFile))
+		}
+	}
 
 	// Add ExecStartPre to verify NFS mount if configured
 	var preExecChecks string
@@ -305,12 +499,12 @@ TimeoutStartSec=600
 	}
 
 	// Build complete unit content
-	unitContent := unitSection + serviceSection + `[Install]
+	unitContent := unitHeader + unitSection + serviceSection + `[Install]
 WantedBy=multi-user.target
 `
 
 	// Write service file
-	unitPath := filepath.Join("/etc/systemd/system", serviceInfo.UnitName)
+	unitPath := unitFilePath(serviceInfo)
 	if err := system.WriteFile(unitPath, []byte(unitContent), 0644); err != nil {
 		return fmt.Errorf("failed to write service file: %w", err)
 	}
@@ -328,7 +522,7 @@ WantedBy=multi-user.target
 }
 
 // pullImages pulls container images for a service
-func pullImages(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
+func pullImages(ctx context.Context, cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
 	ui.Step(fmt.Sprintf("Pulling Container Images for %s", serviceInfo.DisplayName))
 
 	// Check if compose file exists
@@ -356,31 +550,31 @@ func pullImages(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
 		return fmt.Errorf("failed to detect compose command: %w", err)
 	}
 
-	// Change to service directory and pull
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	if err := os.Chdir(serviceInfo.Directory); err != nil {
-		return fmt.Errorf("failed to change to service directory: %w", err)
-	}
-	defer func() {
-		if err := os.Chdir(originalDir); err != nil {
-			ui.Warning(fmt.Sprintf("Failed to restore working directory: %v", err))
-		}
-	}()
-
-	// Execute compose pull
-	ui.Infof("Running: %s pull", composeCmd)
-
 	// For compatibility, we need to handle both "podman-compose" and "podman compose" formats
 	cmdParts := strings.Fields(composeCmd)
 	if len(cmdParts) == 0 {
 		return fmt.Errorf("compose command is empty")
 	}
+	fileArgs, overridden := composeFileArgs(serviceInfo.Directory)
+	if overridden {
+		ui.Infof("Compose override in effect: %s", composeOverrideFile)
+	}
+	// Absolute paths instead of changing directory, which would affect stacks
+	// pulled concurrently. The first file's directory is the project directory.
+	for i := 1; i < len(fileArgs); i += 2 {
+		fileArgs[i] = filepath.Join(serviceInfo.Directory, fileArgs[i])
+	}
+	cmdParts = append(cmdParts, fileArgs...)
 	cmdParts = append(cmdParts, "pull")
 
-	if err := system.RunSystemCommand(cmdParts[0], cmdParts[1:]...); err != nil {
+	// Execute compose pull
+	ui.Infof("Running: %s", strings.Join(cmdParts, " "))
+	ui.Info("This can take several minutes; use --verbose to follow the pull progress")
+
+	if err := system.RunSystemCommandContext(ctx, cmdParts[0], cmdParts[1:]...); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		ui.Error(fmt.Sprintf("Failed to pull images: %v", err))
 		ui.Info("You may need to pull images manually later")
 		return nil // Non-critical error, continue
@@ -391,19 +585,24 @@ func pullImages(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
 }
 
 // enableAndStartService enables and starts a systemd service
-func enableAndStartService(_ *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
+func enableAndStartService(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
 	ui.Step(fmt.Sprintf("Enabling and Starting %s Service", serviceInfo.DisplayName))
 
-	// Enable service
-	ui.Infof("Enabling service: %s", serviceInfo.UnitName)
-	if err := system.EnableService(serviceInfo.UnitName); err != nil {
-		return fmt.Errorf("failed to enable service: %w", err)
+	// Enable service at boot unless the user opted out for this stack
+	if bootEnabled(cfg, serviceInfo.Name) {
+		ui.Infof("Enabling service: %s", serviceInfo.UnitName)
+		if err := system.EnableService(serviceInfo.UnitName); err != nil {
+			return fmt.Errorf("failed to enable service: %w", err)
+		}
+		ui.Success("Service enabled")
+	} else {
+		ui.Infof("Not enabling %s at boot (%s%s=false)", serviceInfo.UnitName, config.KeyBootEnabledPrefix, strings.ToUpper(serviceInfo.Name))
 	}
-	ui.Success("Service enabled")
 
 	// Start service
 	ui.Infof("Starting service: %s", serviceInfo.UnitName)
 	if err := system.StartService(serviceInfo.UnitName); err != nil {
+		showUnitFailureLogs(ui, serviceInfo.UnitName)
 		return fmt.Errorf("failed to start service: %w", err)
 	}
 	ui.Success("Service started")
@@ -411,6 +610,40 @@ func enableAndStartService(_ *config.Config, ui *ui.UI, serviceInfo *ServiceInfo
 	return nil
 }
 
+// serviceJournalLogs reads a unit's recent journal, replaced in tests
+var serviceJournalLogs = system.GetServiceJournalLogs
+
+// failureLogLines returns how many journal lines to show for a unit that
+// failed to start: none when quiet, more when verbose
+func failureLogLines(verbosity ui.Verbosity) int {
+	switch {
+	case verbosity <= ui.VerbosityQuiet:
+		return 0
+	case verbosity >= ui.VerbosityVerbose:
+		return 200
+	default:
+		return 50
+	}
+}
+
+// showUnitFailureLogs prints the end of a unit's journal after it failed to
+// start, where the container's own error usually is
+func showUnitFailureLogs(ui *ui.UI, unitName string) {
+	lines := failureLogLines(ui.Verbosity())
+	if lines == 0 {
+		return
+	}
+
+	logs, err := serviceJournalLogs(unitName, lines)
+	if err != nil {
+		ui.Warningf("Could not read the journal of %s: %v", unitName, err)
+		ui.Infof("Check the logs with: sudo journalctl -u %s -n %d --no-pager", unitName, lines)
+		return
+	}
+	ui.Step(fmt.Sprintf("Last %d lines of %s", lines, unitName))
+	ui.Print(ui.Redact(strings.TrimRight(logs, "\n")))
+}
+
 // verifyContainers verifies that containers are running
 func verifyContainers(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) error {
 	ui.Step(fmt.Sprintf("Verifying %s Containers", serviceInfo.DisplayName))
@@ -459,6 +692,73 @@ func verifyContainers(cfg *config.Config, ui *ui.UI, serviceInfo *ServiceInfo) e
 	return nil
 }
 
+// stackPorts maps each stack to the default host port of its web UIs. Read
+// it through resolveStackPorts, which applies port overrides.
+var stackPorts = map[string]map[string]string{
+	"media": {
+		"Plex":     "32400",
+		"Jellyfin": "8096",
+		"Tautulli": "8181",
+	},
+	"web": {
+		"Overseerr": "5055",
+		"Wizarr":    "5690",
+		"Organizr":  "9983",
+		"Homepage":  "3000",
+	},
+	"cloud": {
+		"Nextcloud": "8080",
+		"Collabora": "9980",
+		"Immich":    "2283",
+	},
+}
+
+// resolveStackPorts returns the host port of each of a stack's web UIs,
+// applying the port keys configured for the web stack (OVERSEERR_PORT and
+// the like) over the defaults in stackPorts
+func resolveStackPorts(cfg *config.Config, stack string) map[string]string {
+	ports := make(map[string]string, len(stackPorts[stack]))
+	for app, port := range stackPorts[stack] {
+		for _, hp := range webHostPorts {
+			if hp.Name == app {
+				port = cfg.GetOrDefault(hp.Key, port)
+			}
+		}
+		ports[app] = port
+	}
+	return ports
+}
+
+// AccessURL is the address of one deployed web UI
+type AccessURL struct {
+	Stack string
+	App   string
+	URL   string
+}
+
+// AccessURLs returns the web UI addresses of the selected services on host,
+// sorted by stack and app
+func AccessURLs(cfg *config.Config, host string) []AccessURL {
+	selectedServices, _ := getSelectedServices(cfg)
+	var urls []AccessURL
+	for _, service := range selectedServices {
+		for app, port := range resolveStackPorts(cfg, service) {
+			urls = append(urls, AccessURL{
+				Stack: service,
+				App:   app,
+				URL:   fmt.Sprintf("http://%s", net.JoinHostPort(host, port)),
+			})
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if urls[i].Stack != urls[j].Stack {
+			return urls[i].Stack < urls[j].Stack
+		}
+		return urls[i].App < urls[j].App
+	})
+	return urls
+}
+
 // displayAccessInfo displays service access information
 func displayAccessInfo(cfg *config.Config, ui *ui.UI) {
 	ui.Print("")
@@ -466,33 +766,13 @@ func displayAccessInfo(cfg *config.Config, ui *ui.UI) {
 	ui.Separator()
 	ui.Print("")
 
-	// Common service ports
-	servicePorts := map[string]map[string]string{
-		"media": {
-			"Plex":     "32400",
-			"Jellyfin": "8096",
-			"Tautulli": "8181",
-		},
-		"web": {
-			"Overseerr": "5055",
-			"Wizarr":    "5690",
-			"Organizr":  "9983",
-			"Homepage":  "3000",
-		},
-		"cloud": {
-			"Nextcloud": "8080",
-			"Collabora": "9980",
-			"Immich":    "2283",
-		},
-	}
-
 	selectedServices, _ := getSelectedServices(cfg)
 
 	// Use cases.Title instead of deprecated strings.Title
 	caser := cases.Title(language.English)
 
 	for _, service := range selectedServices {
-		if ports, ok := servicePorts[service]; ok {
+		if ports := resolveStackPorts(cfg, service); len(ports) > 0 {
 			ui.Infof("%s Stack:", caser.String(service))
 			for name, port := range ports {
 				ui.Printf("  - %s: http://localhost:%s", name, port)
@@ -549,7 +829,7 @@ func displayManagementInfo(cfg *config.Config, ui *ui.UI) {
 }
 
 // deployService deploys a single service
-func deployService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+func deployService(ctx context.Context, cfg *config.Config, ui *ui.UI, serviceName string) error {
 	serviceInfo := getServiceInfo(cfg, serviceName)
 
 	ui.Header(fmt.Sprintf("Deploying %s Stack", serviceInfo.DisplayName))
@@ -565,14 +845,38 @@ func deployService(cfg *config.Config, ui *ui.UI, serviceName string) error {
 		if err := createComposeService(cfg, ui, serviceInfo); err != nil {
 			return fmt.Errorf("failed to create service: %w", err)
 		}
+	} else if err := offerPreDeployBackup(cfg, ui, serviceName); err != nil {
+		return err
+	}
+
+	// Claim tokens expire quickly, so re-check right before Plex first starts
+	if serviceName == "media" {
+		refreshed, err := ensureFreshPlexClaimToken(cfg, ui)
+		if err != nil {
+			return err
+		}
+		if refreshed {
+			if err := createEnvFiles(cfg, ui, []string{serviceName}); err != nil {
+				return fmt.Errorf("failed to update environment file: %w", err)
+			}
+		}
+	}
+
+	// Make sure the compose file is the one this stack expects
+	if err := checkComposeServices(cfg, ui, serviceInfo); err != nil {
+		ui.Warningf("Could not check compose services: %v", err)
 	}
 
 	// Pull images
-	if err := pullImages(cfg, ui, serviceInfo); err != nil {
+	if err := pullImages(ctx, cfg, ui, serviceInfo); err != nil {
 		ui.Warning(fmt.Sprintf("Image pull had issues: %v", err))
 		// Continue anyway
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Enable and start service
 	if err := enableAndStartService(cfg, ui, serviceInfo); err != nil {
 		return fmt.Errorf("failed to enable/start service: %w", err)
@@ -584,12 +888,89 @@ func deployService(cfg *config.Config, ui *ui.UI, serviceName string) error {
 		// Continue anyway
 	}
 
+	// Wait for the web UIs to answer before reporting success. The stack is
+	// running either way; a slow first start (database migrations, library
+	// scans) can outlast the timeout, so that is only a warning.
+	if err := waitForHealthy(ctx, ui, serviceInfo, healthTimeout(cfg, serviceName)); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		ui.Warningf("%s stack started but is not healthy yet: %v", serviceInfo.DisplayName, err)
+		ui.Info("  Check again later with Verify Deployment ([V] in the menu)")
+		ui.Print("")
+		ui.Successf("%s %s stack deployed", ui.GlyphOK(), serviceInfo.DisplayName)
+		return nil
+	}
+
 	ui.Print("")
-	ui.Successf("✓ %s stack deployed successfully", serviceInfo.DisplayName)
+	ui.Successf("%s %s stack deployed successfully", ui.GlyphOK(), serviceInfo.DisplayName)
 
 	return nil
 }
 
+// offerPreDeployBackup offers to back up a stack's appdata before it is
+// redeployed, since new images may migrate its databases (e.g. Nextcloud,
+// Immich). A failed backup aborts the deployment unless the user continues.
+func offerPreDeployBackup(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	paths, err := stackAppdataPaths(cfg, serviceName)
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+
+	backup, err := ui.PromptYesNo(fmt.Sprintf("Back up %s appdata before redeploying?", serviceName), false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !backup {
+		return nil
+	}
+
+	if _, err := BackupAppdata(cfg, ui, serviceName, true); err != nil {
+		ui.Errorf("Backup failed: %v", err)
+		proceed, promptErr := ui.PromptYesNo("Continue the deployment without a backup?", false)
+		if promptErr != nil {
+			return fmt.Errorf("failed to prompt: %w", promptErr)
+		}
+		if !proceed {
+			return fmt.Errorf("deployment of %s cancelled: backup failed: %w", serviceName, err)
+		}
+	}
+	return nil
+}
+
+// serviceLogLines is the number of journal lines shown by RunViewServiceLogs
+const serviceLogLines = 100
+
+// RunViewServiceLogs lets the user pick a deployed service and shows its recent journal
+func RunViewServiceLogs(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Service Logs")
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	options := make([]string, len(selectedServices))
+	for i, service := range selectedServices {
+		options[i] = getServiceInfo(cfg, service).UnitName
+	}
+
+	index, err := ui.PromptSelect("Select service", options)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for service: %w", err)
+	}
+
+	unitName := options[index]
+	logs, err := system.GetServiceJournalLogs(unitName, serviceLogLines)
+	if err != nil {
+		return err
+	}
+
+	ui.Step(fmt.Sprintf("Last %d lines of %s", serviceLogLines, unitName))
+	ui.Print(logs)
+	return nil
+}
+
 // migratePodmanToDocker migrates from Podman to Docker runtime
 func migratePodmanToDocker(cfg *config.Config, ui *ui.UI) error {
 	runtimeStr := cfg.GetOrDefault(config.KeyContainerRuntime, "docker")
@@ -625,14 +1006,12 @@ func migratePodmanToDocker(cfg *config.Config, ui *ui.UI) error {
 		ui.Infof("Stopping and disabling %s...", oldUnitName)
 
 		// Stop service
-		cmd := exec.Command("sudo", "-n", "systemctl", "stop", oldUnitName)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := system.RunPrivileged("systemctl", "stop", oldUnitName); err != nil {
 			ui.Warning(fmt.Sprintf("Failed to stop %s: %v\nOutput: %s", oldUnitName, err, string(output)))
 		}
 
 		// Disable service
-		cmd = exec.Command("sudo", "-n", "systemctl", "disable", oldUnitName)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := system.RunPrivileged("systemctl", "disable", oldUnitName); err != nil {
 			ui.Warning(fmt.Sprintf("Failed to disable %s: %v\nOutput: %s", oldUnitName, err, string(output)))
 		}
 
@@ -647,14 +1026,13 @@ func migratePodmanToDocker(cfg *config.Config, ui *ui.UI) error {
 
 	// Reload systemd daemon
 	ui.Info("Reloading systemd daemon...")
-	cmd := exec.Command("sudo", "-n", "systemctl", "daemon-reload")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := system.RunPrivileged("systemctl", "daemon-reload"); err != nil {
 		ui.Warning(fmt.Sprintf("Failed to reload systemd: %v\nOutput: %s", err, string(output)))
 	} else {
 		ui.Success("Systemd daemon reloaded")
 	}
 
-	ui.Success("✓ Migration from Podman to Docker completed")
+	ui.Successf("%s Migration from Podman to Docker completed", ui.GlyphOK())
 	ui.Info("Service files will be regenerated for Docker on next deployment")
 	ui.Print("")
 
@@ -794,20 +1172,31 @@ func runDeploymentPreflight(cfg *config.Config, ui *ui.UI) error {
 		ui.Successf("Using compose command: %s", composeCmd)
 	}
 
+	if err := checkPortConflicts(cfg, ui); err != nil {
+		return err
+	}
+
+	if mediaSelected(cfg) {
+		ui.Info("Checking hardware transcoding...")
+		if err := checkTranscodeCapability(cfg, ui); err != nil {
+			ui.Warning(err.Error())
+		}
+	}
+
 	ui.Success("Preflight checks passed")
 	return nil
 }
 
 // RunDeployment executes the deployment step
-func RunDeployment(cfg *config.Config, ui *ui.UI) error {
+func RunDeployment(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Check if already completed (and migrate legacy markers)
-	completed, err := ensureCanonicalMarker(cfg, deploymentCompletionMarker, "deployment-complete")
+	completed, err := cfg.CanonicalizeMarker(deploymentCompletionMarker, config.LegacyMarkerNames[deploymentCompletionMarker]...)
 	if err != nil {
 		return fmt.Errorf("failed to check marker: %w", err)
 	}
 	if completed {
 		ui.Info("Service deployment already completed (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + deploymentCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), deploymentCompletionMarker))
 		return nil
 	}
 
@@ -830,15 +1219,24 @@ func RunDeployment(cfg *config.Config, ui *ui.UI) error {
 	ui.Infof("Deploying %d service(s): %s", len(selectedServices), strings.Join(selectedServices, ", "))
 	ui.Print("")
 
-	// Deploy each service
-	for _, serviceName := range selectedServices {
-		if err := deployService(cfg, ui, serviceName); err != nil {
-			ui.Error(fmt.Sprintf("Failed to deploy %s: %v", serviceName, err))
-			ui.Info("Continuing with remaining services...")
-			// Continue with other services
+	// Deploy each service, continuing past failures
+	errs := forEachService(ctx, cfg, ui, selectedServices, deployService)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("deployment interrupted: %w", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			ui.Error(fmt.Sprintf("Failed to deploy %s: %v", selectedServices[i], err))
 		}
 	}
 
+	// Open firewall ports for the deployed stacks
+	ui.Step("Firewall")
+	if err := configureFirewallPorts(cfg, ui); err != nil {
+		ui.Warning(fmt.Sprintf("Firewall configuration had issues: %v", err))
+		// Non-critical error, continue
+	}
+
 	// Display access information
 	displayAccessInfo(cfg, ui)
 
@@ -847,7 +1245,7 @@ func RunDeployment(cfg *config.Config, ui *ui.UI) error {
 
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ Service deployment completed")
+	ui.Successf("%s Service deployment completed", ui.GlyphOK())
 	ui.Infof("Deployed %d stack(s)", len(selectedServices))
 
 	// Create completion marker
@@ -1,9 +1,16 @@
 package steps
 
 import (
+	"bytes"
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
 )
 
 // TestFstabMountToSystemdUnit tests the systemd-escape path conversion
@@ -72,3 +79,265 @@ func TestDetectComposeCommandCaching(t *testing.T) {
 	// In a real implementation, we would use a mock config to verify caching
 	t.Skip("Requires mock config implementation")
 }
+
+func TestComposeFileArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          []string
+		wantArgs       string
+		wantOverridden bool
+	}{
+		{"no compose file", nil, "", false},
+		{"compose only", []string{"compose.yml"}, "-f compose.yml", false},
+		{"with override", []string{"compose.yml", "compose.override.yml"}, "-f compose.yml -f compose.override.yml", true},
+		{"legacy name with override", []string{"docker-compose.yml", "compose.override.yml"}, "-f docker-compose.yml -f compose.override.yml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("services: {}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args, overridden := composeFileArgs(dir)
+			if got := strings.Join(args, " "); got != tt.wantArgs || overridden != tt.wantOverridden {
+				t.Errorf("composeFileArgs() = (%q, %v), want (%q, %v)", got, overridden, tt.wantArgs, tt.wantOverridden)
+			}
+		})
+	}
+}
+
+func TestUnitOverrideMismatch(t *testing.T) {
+	const plain = "ExecStart=/usr/bin/docker compose -f compose.yml up -d --remove-orphans\n"
+	const withOverride = "ExecStart=/usr/bin/docker compose -f compose.yml -f compose.override.yml up -d --remove-orphans\n"
+
+	tests := []struct {
+		name       string
+		unit       string
+		overridden bool
+		wantErr    string
+	}{
+		{"no override", plain, false, ""},
+		{"override in unit", withOverride, true, ""},
+		{"override added later", plain, true, "added after the unit was written"},
+		{"override removed", withOverride, false, "no longer exists"},
+	}
+
+	for _, tt := range tests {
+		err := unitOverrideMismatch(unitHeader+tt.unit, tt.overridden)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unitOverrideMismatch() error = %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: unitOverrideMismatch() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBootEnabled(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if !bootEnabled(cfg, "media") {
+		t.Error("stacks should start at boot by default")
+	}
+
+	if err := cfg.Set(config.KeyBootEnabledPrefix+"MEDIA", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if bootEnabled(cfg, "media") {
+		t.Error("expected BOOT_ENABLED_MEDIA=false to disable boot start")
+	}
+	if !bootEnabled(cfg, "web") {
+		t.Error("setting for one stack should not affect another")
+	}
+
+	if err := cfg.Set(config.KeyBootEnabledPrefix+"WEB", "maybe"); err != nil {
+		t.Fatal(err)
+	}
+	if !bootEnabled(cfg, "web") {
+		t.Error("invalid values should fall back to enabled")
+	}
+}
+
+func TestAccessURLs(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := AccessURLs(cfg, "192.168.1.10"); len(got) != 0 {
+		t.Errorf("AccessURLs() without services = %v, want none", got)
+	}
+
+	if err := cfg.Set(config.KeySelectedServices, "web media"); err != nil {
+		t.Fatal(err)
+	}
+	got := AccessURLs(cfg, "192.168.1.10")
+	if len(got) == 0 || got[0].Stack != "media" || got[len(got)-1].Stack != "web" {
+		t.Fatalf("AccessURLs() = %v, want media stack first and web last", got)
+	}
+	for _, u := range got {
+		if u.App == "Plex" && u.URL != "http://192.168.1.10:32400" {
+			t.Errorf("AccessURLs() Plex URL = %q", u.URL)
+		}
+	}
+}
+
+func TestResolveStackPortsAppliesOverrides(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.SetMany(map[string]string{
+		config.KeySelectedServices: "web",
+		"OVERSEERR_PORT":           "5056",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := resolveStackPorts(cfg, "web")
+	if ports["Overseerr"] != "5056" || ports["Homepage"] != "3000" {
+		t.Errorf("resolveStackPorts() = %v, want the Overseerr override and the Homepage default", ports)
+	}
+	if stackPorts["web"]["Overseerr"] != "5055" {
+		t.Error("resolveStackPorts() modified the defaults")
+	}
+
+	for _, u := range AccessURLs(cfg, "192.168.1.10") {
+		if u.App == "Overseerr" && u.URL != "http://192.168.1.10:5056" {
+			t.Errorf("AccessURLs() Overseerr URL = %q", u.URL)
+		}
+	}
+	for _, endpoint := range healthEndpoints(cfg, "web") {
+		if endpoint.Name == "Overseerr" && !strings.HasPrefix(endpoint.URL, "http://localhost:5056/") {
+			t.Errorf("healthEndpoints() Overseerr URL = %q", endpoint.URL)
+		}
+	}
+	for _, p := range stackFirewallPorts(cfg, "web") {
+		if p.Name == "Overseerr" && p.Port != "5056" {
+			t.Errorf("stackFirewallPorts() Overseerr port = %q", p.Port)
+		}
+	}
+}
+
+func TestCheckComposeServices(t *testing.T) {
+	orig := listComposeServices
+	t.Cleanup(func() { listComposeServices = orig })
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.SetMany(map[string]string{
+		config.KeyContainersBase:   t.TempDir(),
+		config.KeyContainerRuntime: "docker",
+		config.KeyComposeCommand:   "docker compose",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	serviceInfo := getServiceInfo(cfg, "media")
+	if err := os.MkdirAll(serviceInfo.Directory, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"compose.yml", composeOverrideFile} {
+		if err := os.WriteFile(filepath.Join(serviceInfo.Directory, name), []byte("services: {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var gotFiles []string
+	listComposeServices = func(composeCmd string, files ...string) ([]string, error) {
+		gotFiles = files
+		return []string{"jellyfin", "tautulli", "sonarr"}, nil
+	}
+
+	var buf bytes.Buffer
+	if err := checkComposeServices(cfg, ui.NewWithWriter(&buf), serviceInfo); err != nil {
+		t.Fatalf("checkComposeServices() error = %v", err)
+	}
+	if len(gotFiles) != 2 || filepath.Base(gotFiles[1]) != composeOverrideFile {
+		t.Errorf("checkComposeServices() read %v, want the compose file and its override", gotFiles)
+	}
+	out := buf.String()
+	for _, want := range []string{"no such service(s)", "plex", "not normally part of the media stack: sonarr"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestShowUnitFailureLogs(t *testing.T) {
+	orig := serviceJournalLogs
+	t.Cleanup(func() { serviceJournalLogs = orig })
+
+	var requested int
+	serviceJournalLogs = func(unit string, lines int) (string, error) {
+		requested = lines
+		if unit == "broken.service" {
+			return "", errors.New("sudo: a password is required")
+		}
+		return "plex  | Error: PLEX_CLAIM rejected\n", nil
+	}
+
+	var buf bytes.Buffer
+	u := ui.NewWithWriter(&buf)
+	showUnitFailureLogs(u, "docker-compose-media.service")
+	if requested != 50 || !strings.Contains(buf.String(), "PLEX_CLAIM rejected") {
+		t.Errorf("requested %d lines, output:\n%s", requested, buf.String())
+	}
+
+	buf.Reset()
+	u.SetVerbosity(ui.VerbosityVerbose)
+	showUnitFailureLogs(u, "broken.service")
+	if requested != 200 || !strings.Contains(buf.String(), "journalctl -u broken.service -n 200") {
+		t.Errorf("requested %d lines, output:\n%s", requested, buf.String())
+	}
+
+	requested = 0
+	u.SetVerbosity(ui.VerbosityQuiet)
+	showUnitFailureLogs(u, "docker-compose-media.service")
+	if requested != 0 {
+		t.Errorf("quiet output read %d journal lines", requested)
+	}
+}
+
+func TestNormalizeSelectedServices(t *testing.T) {
+	catalog := map[string]string{"media": "media.yml", "web": "web.yml", "cloud": "cloud.yml"}
+	tests := []struct {
+		name    string
+		value   string
+		catalog map[string]string
+		want    string
+		wantErr string
+	}{
+		{"space", "media web", catalog, "media web", ""},
+		{"comma", "media,web", catalog, "media web", ""},
+		{"mixed", " media, web\tcloud ,", catalog, "media web cloud", ""},
+		{"duplicates", "web media web,media", catalog, "web media", ""},
+		{"empty", " , ", catalog, "", ""},
+		{"unknown stack", "media,plex", catalog, "", `unknown stack "plex" (available: cloud, media, web)`},
+		{"no catalog", "custom media", nil, "custom media", ""},
+		{"invalid name", "media ../etc", nil, "", `invalid stack name "../etc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeSelectedServices(tt.value, tt.catalog)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("normalizeSelectedServices(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("normalizeSelectedServices(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectedServicesAcceptsCommas(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	// Written by hand or by an older version
+	if err := cfg.Set(config.KeySelectedServices, "media,web media"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := getSelectedServices(cfg)
+	if err != nil || strings.Join(got, " ") != "media web" {
+		t.Errorf("getSelectedServices() = %v, %v, want [media web]", got, err)
+	}
+	if !mediaSelected(cfg) {
+		t.Error("mediaSelected() = false for a comma-separated list")
+	}
+}
@@ -1,10 +1,14 @@
 package steps
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
@@ -12,16 +16,28 @@ import (
 
 const directoryCompletionMarker = "directory-setup-complete"
 
+// directoryFS is the filesystem used to verify and display the directory
+// tree, overridable in tests
+var directoryFS = system.NewFileSystem()
+
+// Directory creation, overridable in tests. Only directories the setup owns
+// outright go through ensureDirectoryStrict, which corrects owner and mode
+// drift on an existing directory.
+var (
+	createDirectory       = system.EnsureDirectory
+	ensureDirectoryStrict = system.EnsureDirectoryStrict
+)
+
 // RunDirectorySetup executes the directory setup step
-func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
+func RunDirectorySetup(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Check if already completed (and migrate legacy markers)
-	completed, err := ensureCanonicalMarker(cfg, directoryCompletionMarker, "directories-created")
+	completed, err := cfg.CanonicalizeMarker(directoryCompletionMarker, config.LegacyMarkerNames[directoryCompletionMarker]...)
 	if err != nil {
 		return fmt.Errorf("failed to check marker: %w", err)
 	}
 	if completed {
 		ui.Info("Directory structure already created (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + directoryCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), directoryCompletionMarker))
 		return nil
 	}
 
@@ -66,10 +82,39 @@ func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
 		containersBase = input
 	}
 
+	containersBase, err = normalizeBasePath(containersBase)
+	if err != nil {
+		return fmt.Errorf("invalid containers base directory: %w", err)
+	}
+	if err := confirmBasePath(containersBase, ui); err != nil {
+		return err
+	}
+
 	// Appdata directory (fixed location per documentation)
 	appdataBase := "/var/lib/containers/appdata"
 	ui.Print("")
 	ui.Info("Application data will be stored in: " + appdataBase)
+	if err := checkAppdataFilesystem(cfg, appdataBase, ui); err != nil {
+		return err
+	}
+
+	// Take over a tree left by the old setup scripts instead of recreating it
+	found, err := findExistingStructure(cfg, containersBase, appdataBase)
+	if err != nil {
+		return err
+	}
+	if found.complete() {
+		adopted, err := adoptExistingStructure(cfg, found, containersBase, appdataBase, homelabUser, ui)
+		if err != nil {
+			return err
+		}
+		if adopted {
+			if err := cfg.MarkComplete(directoryCompletionMarker); err != nil {
+				return fmt.Errorf("failed to create completion marker: %w", err)
+			}
+			return nil
+		}
+	}
 
 	// Create container service directories
 	ui.Step("Creating Container Service Directories")
@@ -79,7 +124,7 @@ func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
 
 	// Create appdata directories
 	ui.Step("Creating Application Data Directories")
-	if err := createAppdataDirs(appdataBase, homelabUser, ui); err != nil {
+	if err := createAppdataDirs(cfg, appdataBase, homelabUser, ui); err != nil {
 		return fmt.Errorf("failed to create appdata directories: %w", err)
 	}
 
@@ -89,6 +134,13 @@ func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
 		return fmt.Errorf("permission verification failed: %w", err)
 	}
 
+	// Label appdata for container access when SELinux is enforcing
+	ui.Step("SELinux Context")
+	if err := configureSELinuxContext(appdataBase, ui); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to configure SELinux context: %v", err))
+		// Non-critical error, continue
+	}
+
 	// Create NFS mount points if needed
 	ui.Step("NFS Mount Points")
 	if err := createNFSMountPoints(cfg, ui); err != nil {
@@ -105,23 +157,20 @@ func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
 	// Display structure
 	displayStructure(containersBase, appdataBase, ui)
 
+	// Stop before persisting anything if the operation was interrupted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Save configuration
 	ui.Step("Saving Configuration")
-	if err := cfg.Set("CONTAINERS_BASE", containersBase); err != nil {
-		return fmt.Errorf("failed to save containers base directory: %w", err)
-	}
-	// Use APPDATA_BASE as per architecture document
-	if err := cfg.Set("APPDATA_BASE", appdataBase); err != nil {
-		return fmt.Errorf("failed to save appdata base: %w", err)
-	}
-	// Also set APPDATA_PATH for backwards compatibility with legacy configs and .env files
-	if err := cfg.Set("APPDATA_PATH", appdataBase); err != nil {
-		return fmt.Errorf("failed to save appdata path: %w", err)
+	if err := saveConfigValues(cfg, ui, directoryConfigValues(containersBase, appdataBase)); err != nil {
+		return err
 	}
 
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ Directory structure created successfully")
+	ui.Successf("%s Directory structure created successfully", ui.GlyphOK())
 	ui.Infof("Container services: %s", containersBase)
 	ui.Infof("Application data: %s", appdataBase)
 
@@ -133,82 +182,277 @@ func RunDirectorySetup(cfg *config.Config, ui *ui.UI) error {
 	return nil
 }
 
+// directoryConfigValues returns the configuration saved by the directory step
+func directoryConfigValues(containersBase, appdataBase string) map[string]string {
+	return map[string]string{
+		"CONTAINERS_BASE": containersBase,
+		// Use APPDATA_BASE as per architecture document
+		"APPDATA_BASE": appdataBase,
+		// Also set APPDATA_PATH for backwards compatibility with legacy configs and .env files
+		"APPDATA_PATH": appdataBase,
+	}
+}
+
+// containerServiceDirs are the stack directories created under CONTAINERS_BASE
+var containerServiceDirs = []struct {
+	name        string
+	description string
+}{
+	{"media", "Plex, Jellyfin, Tautulli"},
+	{"web", "Overseerr, Wizarr, Organizr, Homepage"},
+	{"cloud", "Nextcloud, Immich, Collabora"},
+}
+
+// appdataDirs are the per-application directories created under APPDATA_BASE
+var appdataDirs = []string{
+	"plex",
+	"jellyfin",
+	"tautulli",
+	"overseerr",
+	"wizarr",
+	"organizr",
+	"homepage",
+	"nextcloud",
+	"nextcloud-db",
+	"nextcloud-redis",
+	"collabora",
+	"immich",
+	"immich-db",
+	"immich-redis",
+	"immich-ml",
+}
+
+// appdataOverrideKey returns the config key overriding an app's appdata
+// directory, e.g. APPDATA_PATH_NEXTCLOUD_DB for "nextcloud-db"
+func appdataOverrideKey(app string) string {
+	return config.KeyAppdataPathPrefix + strings.ToUpper(strings.ReplaceAll(app, "-", "_"))
+}
+
+// appdataPath returns the appdata directory for app: its APPDATA_PATH_<APP>
+// override when set, otherwise <appdataBase>/<app>
+func appdataPath(cfg *config.Config, appdataBase, app string) (path string, overridden bool, err error) {
+	override := cfg.GetOrDefault(appdataOverrideKey(app), "")
+	if override == "" {
+		return filepath.Join(appdataBase, app), false, nil
+	}
+	if err := common.ValidateSafePath(override); err != nil {
+		return "", false, fmt.Errorf("invalid %s: %w", appdataOverrideKey(app), err)
+	}
+	return filepath.Clean(override), true, nil
+}
+
+// appdataFilesystemRisk explains why appdata must not live on a filesystem of
+// type fsType, or returns "" when it is suitable
+func appdataFilesystemRisk(fsType string) string {
+	switch {
+	case system.IsNetworkFilesystem(fsType):
+		return "network filesystems break the file locking databases rely on, corrupting Nextcloud, Immich and Plex data"
+	case system.IsMemoryFilesystem(fsType):
+		return "it is kept in memory, so all application data is lost on reboot"
+	case fsType == "overlay":
+		return "overlay mounts are usually a container's writable layer and are discarded with it"
+	}
+	return ""
+}
+
+// checkAppdataFilesystem warns when the appdata base or a per-application
+// override lives on a network, in-memory or overlay filesystem, and asks
+// before continuing (default no)
+func checkAppdataFilesystem(cfg *config.Config, appdataBase string, ui *ui.UI) error {
+	paths := []string{appdataBase}
+	for _, app := range appdataDirs {
+		if path, overridden, err := appdataPath(cfg, appdataBase, app); err == nil && overridden {
+			paths = append(paths, path)
+		}
+	}
+
+	unsafe := false
+	for _, path := range paths {
+		fsType, err := system.FilesystemType(path)
+		if err != nil {
+			ui.Warningf("Could not determine the filesystem of %s: %v", path, err)
+			continue
+		}
+		risk := appdataFilesystemRisk(fsType)
+		if risk == "" {
+			continue
+		}
+		unsafe = true
+		ui.Errorf("%s is on a %s filesystem: %s", path, fsType, risk)
+	}
+	if !unsafe {
+		return nil
+	}
+
+	ui.Warning("Keep application data on local storage (e.g. the default /var/lib/containers/appdata)")
+	ui.Info("Media libraries can stay on NFS; only appdata needs a local disk")
+	proceed, err := ui.PromptYesNo("Continue with this appdata location anyway?", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !proceed {
+		return fmt.Errorf("appdata is not on local storage")
+	}
+	ui.Warning("Continuing with appdata on non-local storage")
+	return nil
+}
+
+// normalizeBasePath returns the cleaned form of a base directory entered by
+// the user, so /srv/containers/ and /srv/containers are stored the same way.
+// ".." components are rejected rather than resolved.
+func normalizeBasePath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path must not contain '..': %s", path)
+		}
+	}
+	if err := common.ValidateSafePath(path); err != nil {
+		return "", err
+	}
+	return filepath.Clean(path), nil
+}
+
+// confirmBasePath rejects dangerous base directories outright and asks for
+// explicit confirmation before using one outside the usual locations
+func confirmBasePath(path string, ui *ui.UI) error {
+	err := common.ValidateBasePath(path)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, common.ErrPathNotAllowlisted) {
+		return fmt.Errorf("invalid containers base directory: %w", err)
+	}
+
+	ui.Warning(err.Error())
+	confirmed, promptErr := ui.PromptYesNo(fmt.Sprintf("Use %s anyway?", path), false)
+	if promptErr != nil {
+		return fmt.Errorf("failed to prompt: %w", promptErr)
+	}
+	if !confirmed {
+		return fmt.Errorf("containers base directory %s not confirmed: %w", path, ErrCancelled)
+	}
+	return nil
+}
+
+// ensureDirectory creates path with the given owner and mode, noting any
+// ownership or mode drift on an existing directory that was corrected
+func ensureDirectory(path, owner string, perms os.FileMode, ui *ui.UI) error {
+	drift, err := ensureDirectoryStrict(path, owner, perms)
+	if err != nil {
+		return err
+	}
+	if drift != nil {
+		ui.Warningf("  %s", drift)
+	}
+	return nil
+}
+
 // createBaseStructure creates the base directory structure
 func createBaseStructure(baseDir, owner string, ui *ui.UI) error {
 	ui.Infof("Creating container service directories in %s...", baseDir)
 	ui.Print("")
 
-	// Define container service directories (media, web, cloud)
-	serviceDirs := []struct {
-		name        string
-		description string
-	}{
-		{"media", "Plex, Jellyfin, Tautulli"},
-		{"web", "Overseerr, Wizarr, Organizr, Homepage"},
-		{"cloud", "Nextcloud, Immich, Collabora"},
-	}
-
 	// Create base containers directory
-	if err := system.EnsureDirectory(baseDir, owner, 0755); err != nil {
+	if err := ensureDirectory(baseDir, owner, 0755, ui); err != nil {
 		return fmt.Errorf("failed to create base directory %s: %w", baseDir, err)
 	}
-	ui.Successf("  ✓ Created %s", baseDir)
+	ui.Successf("  %s Created %s", ui.GlyphOK(), baseDir)
 
 	// Create each service directory
-	for _, svc := range serviceDirs {
+	for _, svc := range containerServiceDirs {
 		svcPath := filepath.Join(baseDir, svc.name)
 		ui.Infof("Creating %s - %s", svcPath, svc.description)
 
-		if err := system.EnsureDirectory(svcPath, owner, 0755); err != nil {
+		if err := ensureDirectory(svcPath, owner, 0755, ui); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", svcPath, err)
 		}
 
-		ui.Successf("  ✓ Created %s/", svc.name)
+		ui.Successf("  %s Created %s/", ui.GlyphOK(), svc.name)
 	}
 
 	return nil
 }
 
-// createAppdataDirs creates application data directories
-func createAppdataDirs(appdataBase, owner string, ui *ui.UI) error {
+// createAppdataDirs creates application data directories, honouring
+// per-application APPDATA_PATH_<APP> overrides
+func createAppdataDirs(cfg *config.Config, appdataBase, owner string, ui *ui.UI) error {
 	ui.Print("")
 	ui.Infof("Creating application data directories in %s...", appdataBase)
 
-	// Define appdata directories for each service
-	appdataDirs := []string{
-		"plex",
-		"jellyfin",
-		"tautulli",
-		"overseerr",
-		"wizarr",
-		"organizr",
-		"homepage",
-		"nextcloud",
-		"nextcloud-db",
-		"nextcloud-redis",
-		"collabora",
-		"immich",
-		"immich-db",
-		"immich-redis",
-		"immich-ml",
-	}
-
 	// Create base appdata directory
-	if err := system.EnsureDirectory(appdataBase, owner, 0755); err != nil {
+	if err := ensureDirectory(appdataBase, owner, 0755, ui); err != nil {
 		return fmt.Errorf("failed to create appdata base directory %s: %w", appdataBase, err)
 	}
-	ui.Successf("  ✓ Created %s", appdataBase)
+	ui.Successf("  %s Created %s", ui.GlyphOK(), appdataBase)
 
 	// Create each appdata directory
 	for _, service := range appdataDirs {
-		serviceDir := filepath.Join(appdataBase, service)
+		serviceDir, overridden, err := appdataPath(cfg, appdataBase, service)
+		if err != nil {
+			return err
+		}
 
-		if err := system.EnsureDirectory(serviceDir, owner, 0755); err != nil {
+		// A database directory belongs to the user inside its container, so
+		// an existing one is left as it is
+		if isDatabaseApp(service) {
+			err = createDirectory(serviceDir, owner, 0755)
+		} else {
+			err = ensureDirectory(serviceDir, owner, 0755, ui)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to create appdata directory %s: %w", serviceDir, err)
 		}
+		if overridden {
+			ui.Successf("  %s Created %s (%s override)", ui.GlyphOK(), serviceDir, service)
+		}
+	}
+
+	ui.Successf("  %s Created %d appdata directories", ui.GlyphOK(), len(appdataDirs))
+	return nil
+}
+
+// configureSELinuxContext previews and applies the container_file_t label on
+// appdata so containers can read it despite correct ownership
+func configureSELinuxContext(appdataBase string, ui *ui.UI) error {
+	mode, err := system.GetSELinuxMode()
+	if err != nil {
+		ui.Warning(fmt.Sprintf("Could not determine SELinux mode: %v", err))
+		return nil
+	}
+	if mode != "Enforcing" {
+		ui.Infof("SELinux is %s, no relabeling needed", mode)
+		return nil
 	}
 
-	ui.Successf("  ✓ Created %d appdata directories", len(appdataDirs))
+	current, err := system.GetSELinuxType(appdataBase)
+	if err != nil {
+		return err
+	}
+	if current == system.ContainerFileContext {
+		ui.Successf("%s already labeled %s", appdataBase, system.ContainerFileContext)
+		return nil
+	}
+
+	ui.Infof("SELinux is enforcing and %s is labeled %s", appdataBase, current)
+	ui.Infof("Would relabel %s recursively to %s", appdataBase, system.ContainerFileContext)
+	if !system.CommandExists("semanage") {
+		ui.Warning("semanage not found; chcon will be used and the label will not survive a full relabel")
+	}
+
+	apply, err := ui.PromptYesNo("Apply SELinux container context?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !apply {
+		ui.Info("Skipped; add :z to volume mounts if containers report permission denied")
+		return nil
+	}
+
+	if err := system.ApplyContainerFileContext(appdataBase); err != nil {
+		return err
+	}
+	ui.Successf("Applied %s to %s", system.ContainerFileContext, appdataBase)
 	return nil
 }
 
@@ -241,7 +485,7 @@ func createNFSMountPoints(cfg *config.Config, ui *ui.UI) error {
 			return fmt.Errorf("failed to create mount point %s: %w", mp.path, err)
 		}
 
-		ui.Successf("  ✓ Created %s", mp.path)
+		ui.Successf("  %s Created %s", ui.GlyphOK(), mp.path)
 	}
 
 	return nil
@@ -256,7 +500,7 @@ func verifyStructure(containersBase, appdataBase string, ui *ui.UI) error {
 	serviceDirs := []string{"media", "web", "cloud"}
 	for _, service := range serviceDirs {
 		serviceDir := filepath.Join(containersBase, service)
-		exists, err := system.DirectoryExists(serviceDir)
+		exists, err := directoryFS.DirectoryExists(serviceDir)
 		if err != nil {
 			return fmt.Errorf("failed to check directory %s: %w", serviceDir, err)
 		}
@@ -265,21 +509,21 @@ func verifyStructure(containersBase, appdataBase string, ui *ui.UI) error {
 			return fmt.Errorf("directory %s was not created", serviceDir)
 		}
 
-		ui.Successf("  ✓ %s exists", serviceDir)
+		ui.Successf("  %s %s exists", ui.GlyphOK(), serviceDir)
 	}
 
 	// Check appdata base directory
-	exists, err := system.DirectoryExists(appdataBase)
+	exists, err := directoryFS.DirectoryExists(appdataBase)
 	if err != nil {
 		return fmt.Errorf("failed to check appdata directory: %w", err)
 	}
 	if !exists {
 		return fmt.Errorf("appdata directory %s was not created", appdataBase)
 	}
-	ui.Successf("  ✓ %s exists", appdataBase)
+	ui.Successf("  %s %s exists", ui.GlyphOK(), appdataBase)
 
 	// Count appdata subdirectories
-	entries, err := os.ReadDir(appdataBase)
+	entries, err := directoryFS.ReadDir(appdataBase)
 	if err == nil {
 		count := 0
 		for _, entry := range entries {
@@ -287,7 +531,7 @@ func verifyStructure(containersBase, appdataBase string, ui *ui.UI) error {
 				count++
 			}
 		}
-		ui.Successf("  ✓ Found %d appdata subdirectories", count)
+		ui.Successf("  %s Found %d appdata subdirectories", ui.GlyphOK(), count)
 	}
 
 	return nil
@@ -310,7 +554,7 @@ func displayStructure(containersBase, appdataBase string, ui *ui.UI) {
 	ui.Printf("%s/", appdataBase)
 
 	// Show sample appdata directories
-	entries, err := os.ReadDir(appdataBase)
+	entries, err := directoryFS.ReadDir(appdataBase)
 	if err == nil && len(entries) > 0 {
 		count := 0
 		for _, entry := range entries {
@@ -337,26 +581,26 @@ func verifyAppdataPermissions(appdataBase, owner string, ui *ui.UI) error {
 	testContent := []byte("permission test")
 
 	// Try to write test file
-	if err := system.WriteFile(testFilePath, testContent, 0644); err != nil {
+	if err := directoryFS.WriteFile(testFilePath, testContent, 0644); err != nil {
 		return fmt.Errorf("cannot write to appdata directory %s: %w (check owner is %s)", appdataBase, err, owner)
 	}
 
 	// Verify we can read it back
-	readContent, err := os.ReadFile(testFilePath)
+	readContent, err := directoryFS.ReadFile(testFilePath)
 	if err != nil {
 		// Clean up test file even if read fails
-		os.Remove(testFilePath)
+		directoryFS.Remove(testFilePath)
 		return fmt.Errorf("cannot read from appdata directory %s: %w", appdataBase, err)
 	}
 
 	// Verify content matches
 	if string(readContent) != string(testContent) {
-		os.Remove(testFilePath)
+		directoryFS.Remove(testFilePath)
 		return fmt.Errorf("appdata directory write verification failed: content mismatch")
 	}
 
 	// Clean up test file
-	if err := os.Remove(testFilePath); err != nil {
+	if err := directoryFS.Remove(testFilePath); err != nil {
 		ui.Warning(fmt.Sprintf("Could not remove test file %s: %v", testFilePath, err))
 	}
 
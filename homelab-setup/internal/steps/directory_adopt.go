@@ -0,0 +1,203 @@
+package steps
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// previewPermissions returns the ownership and mode fixes a directory needs,
+// overridable in tests
+var previewPermissions = system.PreviewAppdataPermissions
+
+// existingStructure describes a directory tree found on disk, e.g. one left
+// by the old setup scripts
+type existingStructure struct {
+	serviceDirs    []string // stack directories under CONTAINERS_BASE
+	appdataDirs    []string // per-application appdata directories present
+	missingAppdata []string // per-application appdata directories absent
+}
+
+// complete reports whether the tree can be adopted as is: every stack
+// directory and the appdata base exist
+func (s *existingStructure) complete() bool {
+	return s != nil && len(s.serviceDirs) == len(containerServiceDirs)
+}
+
+// findExistingStructure inspects containersBase and appdataBase. It returns
+// nil when either base directory is missing.
+func findExistingStructure(cfg *config.Config, containersBase, appdataBase string) (*existingStructure, error) {
+	for _, base := range []string{containersBase, appdataBase} {
+		exists, err := directoryFS.DirectoryExists(base)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory %s: %w", base, err)
+		}
+		if !exists {
+			return nil, nil
+		}
+	}
+
+	found := &existingStructure{}
+	for _, svc := range containerServiceDirs {
+		dir := filepath.Join(containersBase, svc.name)
+		exists, err := directoryFS.DirectoryExists(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory %s: %w", dir, err)
+		}
+		if exists {
+			found.serviceDirs = append(found.serviceDirs, dir)
+		}
+	}
+
+	for _, app := range appdataDirs {
+		dir, _, err := appdataPath(cfg, appdataBase, app)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := directoryFS.DirectoryExists(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory %s: %w", dir, err)
+		}
+		if exists {
+			found.appdataDirs = append(found.appdataDirs, dir)
+		} else {
+			found.missingAppdata = append(found.missingAppdata, dir)
+		}
+	}
+
+	return found, nil
+}
+
+// findOwnershipDrift returns the directories of the tree whose owner or mode
+// differs from what the setup would create. Only the directories themselves
+// are checked, not their contents. Database directories are skipped: their
+// containers chown them to their own user.
+func findOwnershipDrift(cfg *config.Config, found *existingStructure, containersBase, appdataBase, owner string) ([]system.PermissionChange, error) {
+	dirs := append([]string{containersBase, appdataBase}, found.serviceDirs...)
+	dirs = append(dirs, found.appdataDirs...)
+
+	skip := make(map[string]bool)
+	for _, dir := range databaseAppdataDirs(cfg, appdataBase) {
+		skip[dir] = true
+	}
+
+	var drift []system.PermissionChange
+	for _, dir := range dirs {
+		if skip[dir] {
+			continue
+		}
+		changes, err := previewPermissions(dir, owner, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check permissions of %s: %w", dir, err)
+		}
+		drift = append(drift, changes...)
+	}
+	return drift, nil
+}
+
+// adoptExistingStructure offers to take over a complete directory tree
+// instead of creating one. It reports what was found, offers to repair
+// ownership, creates only the missing appdata directories and saves the
+// configuration. It returns false when the user prefers a fresh setup.
+func adoptExistingStructure(cfg *config.Config, found *existingStructure, containersBase, appdataBase, owner string, ui *ui.UI) (bool, error) {
+	ui.Step("Existing Directory Structure")
+	ui.Infof("Found an existing directory structure in %s", containersBase)
+	for _, dir := range found.serviceDirs {
+		ui.Successf("  %s %s exists", ui.GlyphOK(), dir)
+	}
+	ui.Successf("  %s %s exists with %d of %d application directories", ui.GlyphOK(), appdataBase, len(found.appdataDirs), len(appdataDirs))
+	for _, dir := range found.missingAppdata {
+		ui.Infof("  Missing: %s", dir)
+	}
+	ui.Print("")
+
+	adopt, err := ui.PromptYesNo("Adopt the existing structure without recreating it?", true)
+	if err != nil {
+		return false, fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !adopt {
+		return false, nil
+	}
+
+	ui.Step("Checking Ownership")
+	drift, err := findOwnershipDrift(cfg, found, containersBase, appdataBase, owner)
+	if err != nil {
+		return false, err
+	}
+	if len(drift) == 0 {
+		ui.Successf("All directories are owned by %s with the expected permissions", owner)
+	} else {
+		ui.Warningf("%d directories have unexpected ownership or permissions:", len(drift))
+		for _, change := range drift {
+			ui.Printf("  %s  owner %s (want %s)  mode %o (want %o)", change.Path, change.OldOwner, change.NewOwner, change.OldMode, change.NewMode)
+		}
+	}
+
+	if len(found.missingAppdata) > 0 {
+		ui.Step("Creating Missing Application Data Directories")
+		for _, dir := range found.missingAppdata {
+			if err := ensureDirectory(dir, owner, 0755, ui); err != nil {
+				return false, fmt.Errorf("failed to create appdata directory %s: %w", dir, err)
+			}
+			ui.Successf("  %s Created %s", ui.GlyphOK(), dir)
+		}
+	}
+
+	// Saved before repairing so the repair sees the adopted appdata location
+	ui.Step("Saving Configuration")
+	if err := saveConfigValues(cfg, ui, directoryConfigValues(containersBase, appdataBase)); err != nil {
+		return false, err
+	}
+
+	if len(drift) > 0 {
+		if err := repairAdoptedOwnership(cfg, drift, ui); err != nil {
+			return false, err
+		}
+	}
+
+	ui.Print("")
+	ui.Separator()
+	ui.Successf("%s Existing directory structure adopted", ui.GlyphOK())
+	ui.Infof("Container services: %s", containersBase)
+	ui.Infof("Application data: %s", appdataBase)
+	return true, nil
+}
+
+// repairAdoptedOwnership offers to fix the ownership drift of an adopted
+// tree. Directories outside APPDATA_BASE are fixed in place; APPDATA_BASE
+// goes through the appdata permission repair so its contents are covered too.
+func repairAdoptedOwnership(cfg *config.Config, drift []system.PermissionChange, ui *ui.UI) error {
+	ui.Print("")
+	fix, err := ui.PromptYesNo("Fix ownership and permissions now?", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !fix {
+		ui.Warning("Ownership left unchanged; use Maintenance > Fix Appdata Permissions to fix it later")
+		return nil
+	}
+
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "")
+	for _, change := range drift {
+		if change.Path == appdataBase || strings.HasPrefix(change.Path, appdataBase+"/") {
+			continue
+		}
+		if change.NeedsChown() {
+			if err := system.Chown(change.Path, change.NewOwner); err != nil {
+				return fmt.Errorf("failed to fix ownership of %s: %w", change.Path, err)
+			}
+		}
+		if change.NeedsChmod() {
+			if err := system.Chmod(change.Path, change.NewMode); err != nil {
+				return fmt.Errorf("failed to fix permissions of %s: %w", change.Path, err)
+			}
+		}
+		ui.Successf("  %s Fixed %s", ui.GlyphOK(), change.Path)
+	}
+
+	return RunRepairAppdataPermissions(cfg, ui)
+}
@@ -0,0 +1,96 @@
+package steps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+)
+
+func TestFindExistingStructure(t *testing.T) {
+	const containers = "/srv/containers"
+	const appdata = "/var/lib/containers/appdata"
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+
+	t.Run("missing base", func(t *testing.T) {
+		useMemFS(t).AddDir(containers)
+		found, err := findExistingStructure(cfg, containers, appdata)
+		if err != nil {
+			t.Fatalf("findExistingStructure() error = %v", err)
+		}
+		if found.complete() {
+			t.Error("structure without an appdata base should not be complete")
+		}
+	})
+
+	t.Run("incomplete stacks", func(t *testing.T) {
+		mem := useMemFS(t)
+		mem.AddDir(containers + "/media")
+		mem.AddDir(appdata)
+		found, err := findExistingStructure(cfg, containers, appdata)
+		if err != nil {
+			t.Fatalf("findExistingStructure() error = %v", err)
+		}
+		if found.complete() {
+			t.Error("structure missing web and cloud should not be complete")
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		mem := useMemFS(t)
+		for _, svc := range []string{"media", "web", "cloud"} {
+			mem.AddDir(containers + "/" + svc)
+		}
+		for _, app := range appdataDirs[1:] {
+			mem.AddDir(appdata + "/" + app)
+		}
+		found, err := findExistingStructure(cfg, containers, appdata)
+		if err != nil {
+			t.Fatalf("findExistingStructure() error = %v", err)
+		}
+		if !found.complete() {
+			t.Fatal("expected structure to be complete")
+		}
+		if len(found.appdataDirs) != len(appdataDirs)-1 {
+			t.Errorf("found %d appdata dirs, want %d", len(found.appdataDirs), len(appdataDirs)-1)
+		}
+		if want := []string{appdata + "/plex"}; !reflect.DeepEqual(found.missingAppdata, want) {
+			t.Errorf("missingAppdata = %v, want %v", found.missingAppdata, want)
+		}
+	})
+}
+
+func TestFindOwnershipDrift(t *testing.T) {
+	orig := previewPermissions
+	t.Cleanup(func() { previewPermissions = orig })
+
+	var checked []string
+	previewPermissions = func(dir, owner string, recursive bool, _ ...string) ([]system.PermissionChange, error) {
+		if recursive {
+			t.Errorf("drift check of %s should not be recursive", dir)
+		}
+		checked = append(checked, dir)
+		if dir == "/srv/containers/web" {
+			return []system.PermissionChange{{Path: dir, OldOwner: "0:0", NewOwner: "1000:1000", OldMode: 0755, NewMode: 0755}}, nil
+		}
+		return nil, nil
+	}
+
+	found := &existingStructure{
+		serviceDirs: []string{"/srv/containers/media", "/srv/containers/web"},
+		appdataDirs: []string{"/var/lib/containers/appdata/plex", "/var/lib/containers/appdata/immich-db"},
+	}
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	drift, err := findOwnershipDrift(cfg, found, "/srv/containers", "/var/lib/containers/appdata", "core")
+	if err != nil {
+		t.Fatalf("findOwnershipDrift() error = %v", err)
+	}
+	if len(drift) != 1 || drift[0].Path != "/srv/containers/web" {
+		t.Errorf("findOwnershipDrift() = %v, want only /srv/containers/web", drift)
+	}
+	if len(checked) != 5 {
+		t.Errorf("checked %d directories, want 5 (database directories skipped): %v", len(checked), checked)
+	}
+}
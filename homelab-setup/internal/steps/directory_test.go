@@ -0,0 +1,281 @@
+package steps
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func useMemFS(t *testing.T) *system.MemFileSystem {
+	t.Helper()
+	mem := system.NewMemFileSystem()
+	orig := directoryFS
+	directoryFS = mem
+	t.Cleanup(func() { directoryFS = orig })
+	return mem
+}
+
+func TestVerifyStructure(t *testing.T) {
+	const containers = "/srv/containers"
+	const appdata = "/var/lib/containers/appdata"
+
+	t.Run("complete", func(t *testing.T) {
+		mem := useMemFS(t)
+		for _, svc := range []string{"media", "web", "cloud"} {
+			mem.AddDir(containers + "/" + svc)
+		}
+		mem.AddDir(appdata + "/plex")
+		mem.AddDir(appdata + "/jellyfin")
+		mem.AddFile(appdata+"/notes.txt", []byte("x"))
+
+		var buf bytes.Buffer
+		if err := verifyStructure(containers, appdata, ui.NewWithWriter(&buf)); err != nil {
+			t.Fatalf("verifyStructure() error = %v", err)
+		}
+		if !strings.Contains(buf.String(), "Found 2 appdata subdirectories") {
+			t.Errorf("expected subdirectory count of 2, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("missing service dir", func(t *testing.T) {
+		mem := useMemFS(t)
+		mem.AddDir(containers + "/media")
+		mem.AddDir(containers + "/cloud")
+		mem.AddDir(appdata)
+
+		err := verifyStructure(containers, appdata, ui.NewWithWriter(&bytes.Buffer{}))
+		if err == nil || !strings.Contains(err.Error(), containers+"/web") {
+			t.Errorf("expected error naming %s/web, got %v", containers, err)
+		}
+	})
+
+	t.Run("missing appdata", func(t *testing.T) {
+		mem := useMemFS(t)
+		for _, svc := range []string{"media", "web", "cloud"} {
+			mem.AddDir(containers + "/" + svc)
+		}
+
+		err := verifyStructure(containers, appdata, ui.NewWithWriter(&bytes.Buffer{}))
+		if err == nil || !strings.Contains(err.Error(), "appdata directory") {
+			t.Errorf("expected missing appdata error, got %v", err)
+		}
+	})
+
+	t.Run("appdata is a file", func(t *testing.T) {
+		mem := useMemFS(t)
+		for _, svc := range []string{"media", "web", "cloud"} {
+			mem.AddDir(containers + "/" + svc)
+		}
+		mem.AddFile(appdata, []byte("not a dir"))
+
+		if err := verifyStructure(containers, appdata, ui.NewWithWriter(&bytes.Buffer{})); err == nil {
+			t.Error("expected error when appdata is a regular file")
+		}
+	})
+}
+
+func TestDisplayStructure(t *testing.T) {
+	const containers = "/srv/containers"
+	const appdata = "/var/lib/containers/appdata"
+
+	t.Run("truncates after five", func(t *testing.T) {
+		mem := useMemFS(t)
+		for i := 0; i < 8; i++ {
+			mem.AddDir(fmt.Sprintf("%s/app%d", appdata, i))
+		}
+
+		var buf bytes.Buffer
+		displayStructure(containers, appdata, ui.NewWithWriter(&buf))
+		out := buf.String()
+
+		for i := 0; i < 5; i++ {
+			if !strings.Contains(out, fmt.Sprintf("app%d/", i)) {
+				t.Errorf("expected app%d in output", i)
+			}
+		}
+		if strings.Contains(out, "app5/") {
+			t.Error("expected entries past the fifth to be hidden")
+		}
+		if !strings.Contains(out, "... and 3 more") {
+			t.Errorf("expected overflow line, got:\n%s", out)
+		}
+	})
+
+	t.Run("no overflow line at five", func(t *testing.T) {
+		mem := useMemFS(t)
+		for i := 0; i < 5; i++ {
+			mem.AddDir(fmt.Sprintf("%s/app%d", appdata, i))
+		}
+
+		var buf bytes.Buffer
+		displayStructure(containers, appdata, ui.NewWithWriter(&buf))
+		if strings.Contains(buf.String(), "more") {
+			t.Errorf("unexpected overflow line:\n%s", buf.String())
+		}
+	})
+
+	t.Run("missing appdata", func(t *testing.T) {
+		useMemFS(t)
+
+		var buf bytes.Buffer
+		displayStructure(containers, appdata, ui.NewWithWriter(&buf))
+		if n := strings.Count(buf.String(), "├──"); n != 2 {
+			t.Errorf("expected only the container tree, got:\n%s", buf.String())
+		}
+	})
+}
+
+func TestVerifyAppdataPermissions(t *testing.T) {
+	const appdata = "/var/lib/containers/appdata"
+
+	t.Run("cleans up test file", func(t *testing.T) {
+		mem := useMemFS(t)
+		mem.AddDir(appdata)
+
+		if err := verifyAppdataPermissions(appdata, "homelab", ui.NewWithWriter(&bytes.Buffer{})); err != nil {
+			t.Fatalf("verifyAppdataPermissions() error = %v", err)
+		}
+		entries, err := mem.ReadDir(appdata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected test file to be removed, found %d entries", len(entries))
+		}
+	})
+
+	t.Run("write failure", func(t *testing.T) {
+		mem := useMemFS(t)
+		mem.WriteErr = fmt.Errorf("permission denied")
+
+		err := verifyAppdataPermissions(appdata, "homelab", ui.NewWithWriter(&bytes.Buffer{}))
+		if err == nil || !strings.Contains(err.Error(), "homelab") {
+			t.Errorf("expected write error mentioning owner, got %v", err)
+		}
+	})
+}
+
+func TestAppdataOverrideKey(t *testing.T) {
+	tests := map[string]string{
+		"plex":         "APPDATA_PATH_PLEX",
+		"nextcloud-db": "APPDATA_PATH_NEXTCLOUD_DB",
+	}
+	for app, want := range tests {
+		if got := appdataOverrideKey(app); got != want {
+			t.Errorf("appdataOverrideKey(%q) = %q, want %q", app, got, want)
+		}
+	}
+}
+
+func TestAppdataPath(t *testing.T) {
+	const base = "/var/lib/containers/appdata"
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("APPDATA_PATH_PLEX", "/mnt/ssd/plex/"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set("APPDATA_PATH_IMMICH_DB", "relative/path"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	tests := []struct {
+		app        string
+		want       string
+		overridden bool
+		wantErr    bool
+	}{
+		{"plex", "/mnt/ssd/plex", true, false},
+		{"jellyfin", base + "/jellyfin", false, false},
+		{"immich-db", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.app, func(t *testing.T) {
+			got, overridden, err := appdataPath(cfg, base, tt.app)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("appdataPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || overridden != tt.overridden {
+				t.Errorf("appdataPath() = (%q, %v), want (%q, %v)", got, overridden, tt.want, tt.overridden)
+			}
+		})
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"/srv/containers", "/srv/containers", false},
+		{"/srv/containers/", "/srv/containers", false},
+		{" /srv//containers/./ ", "/srv/containers", false},
+		{"/srv/containers/../etc", "", true},
+		{"../containers", "", true},
+		{"srv/containers", "", true},
+		{"/srv/$HOME", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeBasePath(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeBasePath(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAppdataFilesystemRisk(t *testing.T) {
+	for _, fsType := range []string{"nfs4", "cifs", "tmpfs", "overlay"} {
+		if appdataFilesystemRisk(fsType) == "" {
+			t.Errorf("appdataFilesystemRisk(%q) = \"\", want a warning", fsType)
+		}
+	}
+	for _, fsType := range []string{"xfs", "ext4", "btrfs"} {
+		if risk := appdataFilesystemRisk(fsType); risk != "" {
+			t.Errorf("appdataFilesystemRisk(%q) = %q, want none", fsType, risk)
+		}
+	}
+}
+
+func TestCreateAppdataDirsLeavesDatabaseDirectories(t *testing.T) {
+	var created, corrected []string
+	origCreate, origStrict := createDirectory, ensureDirectoryStrict
+	t.Cleanup(func() { createDirectory, ensureDirectoryStrict = origCreate, origStrict })
+	createDirectory = func(path, owner string, perms os.FileMode) error {
+		created = append(created, filepath.Base(path))
+		return nil
+	}
+	ensureDirectoryStrict = func(path, owner string, perms os.FileMode) (*system.DirectoryDrift, error) {
+		corrected = append(corrected, filepath.Base(path))
+		return nil, nil
+	}
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := createAppdataDirs(cfg, "/var/lib/containers/appdata", "homelab", ui.NewWithWriter(&bytes.Buffer{})); err != nil {
+		t.Fatalf("createAppdataDirs() error = %v", err)
+	}
+
+	if want := []string{"nextcloud-db", "immich-db"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("created without drift correction = %v, want %v", created, want)
+	}
+	for _, dir := range corrected {
+		if isDatabaseApp(dir) {
+			t.Errorf("drift correction applied to database directory %s", dir)
+		}
+	}
+	// The appdata base plus every non-database directory
+	if len(corrected) != len(appdataDirs)-1 {
+		t.Errorf("drift correction applied to %d directories, want %d", len(corrected), len(appdataDirs)-1)
+	}
+}
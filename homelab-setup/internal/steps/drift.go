@@ -0,0 +1,175 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// driftServices queries the systemd units of the stacks for the drift check,
+// replaced in tests
+var driftServices = system.NewServiceManager()
+
+// runningComposeProjects lists the running compose projects, replaced in tests
+var runningComposeProjects = system.RunningComposeProjects
+
+// serviceDrift is the difference between SELECTED_SERVICES and the stacks
+// actually running
+type serviceDrift struct {
+	running    []string // selected and running
+	missing    []string // selected but not running
+	unexpected []string // running but not selected
+}
+
+// detectServiceDrift compares the selected stacks with the stacks whose
+// systemd unit is active or whose compose project has running containers. A
+// stack started by hand with "compose up" counts as running.
+func detectServiceDrift(cfg *config.Config) (serviceDrift, error) {
+	runtime, err := getRuntimeFromConfig(cfg)
+	if err != nil {
+		return serviceDrift{}, err
+	}
+	projects, err := runningComposeProjects(runtime)
+	if err != nil {
+		return serviceDrift{}, err
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range SelectedServices(cfg) {
+		selected[name] = true
+	}
+	running := make(map[string]bool)
+	for _, project := range projects {
+		running[project] = true
+	}
+
+	// Check the units of every stack that could be deployed
+	candidates := make(map[string]bool)
+	for name := range selected {
+		candidates[name] = true
+	}
+	for name := range stackAppdataDirs {
+		candidates[name] = true
+	}
+	for name := range candidates {
+		if running[name] {
+			continue
+		}
+		unit := getServiceInfo(cfg, name).UnitName
+		active, err := driftServices.IsActive(unit)
+		if err != nil {
+			return serviceDrift{}, fmt.Errorf("failed to query %s: %w", unit, err)
+		}
+		running[name] = active
+	}
+
+	var drift serviceDrift
+	for name := range selected {
+		if running[name] {
+			drift.running = append(drift.running, name)
+		} else {
+			drift.missing = append(drift.missing, name)
+		}
+	}
+	for name, isRunning := range running {
+		if isRunning && !selected[name] {
+			drift.unexpected = append(drift.unexpected, name)
+		}
+	}
+	sort.Strings(drift.running)
+	sort.Strings(drift.missing)
+	sort.Strings(drift.unexpected)
+	return drift, nil
+}
+
+// RunServiceDrift reports stacks that are selected but not running, and
+// stacks that are running without being selected (for example after a manual
+// "docker compose up"). It offers to deploy the missing stacks and to record
+// the unexpected ones in SERVICES_REVIEW, which the status screen lists.
+func RunServiceDrift(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Configured vs Running Services")
+
+	drift, err := detectServiceDrift(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to compare services: %w", err)
+	}
+
+	var rows [][]string
+	for _, name := range drift.running {
+		rows = append(rows, []string{name, "yes", "yes", "ok"})
+	}
+	for _, name := range drift.missing {
+		rows = append(rows, []string{name, "yes", "no", "configured but not running"})
+	}
+	for _, name := range drift.unexpected {
+		rows = append(rows, []string{name, "no", "yes", "running but not configured"})
+	}
+	if len(rows) == 0 {
+		ui.Info("No stacks are selected or running")
+		return nil
+	}
+	ui.Table([]string{"Stack", "Selected", "Running", "Status"}, rows)
+	ui.Print("")
+
+	if len(drift.missing) == 0 && len(drift.unexpected) == 0 {
+		ui.Success("Running stacks match SELECTED_SERVICES")
+		return clearServicesReview(cfg)
+	}
+
+	if len(drift.missing) > 0 {
+		deploy, err := ui.PromptYesNo(fmt.Sprintf("Deploy the missing stack(s) %s now?", strings.Join(drift.missing, ", ")), false)
+		if err != nil {
+			return err
+		}
+		if deploy {
+			var failed []string
+			for _, name := range drift.missing {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := deployService(ctx, cfg, ui, name); err != nil {
+					ui.Errorf("Failed to deploy %s: %v", name, err)
+					failed = append(failed, name)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("failed to deploy: %s", strings.Join(failed, ", "))
+			}
+		}
+	}
+
+	if len(drift.unexpected) > 0 {
+		ui.Warningf("Not in SELECTED_SERVICES: %s", strings.Join(drift.unexpected, ", "))
+		ui.Info("Add them to SELECTED_SERVICES to manage them here, or stop them if they were started by mistake")
+		mark, err := ui.PromptYesNo("Mark them for review?", true)
+		if err != nil {
+			return err
+		}
+		if mark {
+			if err := cfg.Set(config.KeyServicesReview, strings.Join(drift.unexpected, " ")); err != nil {
+				return fmt.Errorf("failed to save %s: %w", config.KeyServicesReview, err)
+			}
+			ui.Successf("Recorded in %s", config.KeyServicesReview)
+		}
+	} else if err := clearServicesReview(cfg); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// clearServicesReview removes SERVICES_REVIEW once no stack needs review
+func clearServicesReview(cfg *config.Config) error {
+	if !cfg.Exists(config.KeyServicesReview) {
+		return nil
+	}
+	if err := cfg.Delete(config.KeyServicesReview); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", config.KeyServicesReview, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package steps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+)
+
+// activeUnits is a ServiceManager reporting the listed units as active
+type activeUnits map[string]bool
+
+func (a activeUnits) Exists(unit string) (bool, error)   { return a[unit], nil }
+func (a activeUnits) IsActive(unit string) (bool, error) { return a[unit], nil }
+func (a activeUnits) Start(string) error                 { return nil }
+func (a activeUnits) Enable(string) error                { return nil }
+
+func TestDetectServiceDrift(t *testing.T) {
+	origServices, origProjects := driftServices, runningComposeProjects
+	t.Cleanup(func() { driftServices, runningComposeProjects = origServices, origProjects })
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeySelectedServices, "media web"); err != nil {
+		t.Fatal(err)
+	}
+
+	// media runs under its unit, web is down, cloud and a hand-started
+	// "portainer" project run without being selected
+	driftServices = activeUnits{"docker-compose-media.service": true}
+	runningComposeProjects = func(system.ContainerRuntime) ([]string, error) {
+		return []string{"cloud", "portainer"}, nil
+	}
+
+	drift, err := detectServiceDrift(cfg)
+	if err != nil {
+		t.Fatalf("detectServiceDrift() error = %v", err)
+	}
+	want := serviceDrift{
+		running:    []string{"media"},
+		missing:    []string{"web"},
+		unexpected: []string{"cloud", "portainer"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("detectServiceDrift() = %+v, want %+v", drift, want)
+	}
+}
@@ -0,0 +1,24 @@
+package steps
+
+import (
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// RunEffectiveConfig shows every key set in the config file or the Defaults
+// table, with its value and which of the two it comes from. Fallbacks a step
+// passes to GetOrDefault itself are not listed. Secrets are redacted.
+func RunEffectiveConfig(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Effective Configuration")
+	ui.Infof("Config file: %s", cfg.FilePath())
+	ui.Info("Showing config file values and table defaults; keys not listed use the default of the step that reads them")
+	ui.Print("")
+
+	values := cfg.Effective()
+	rows := make([][]string, 0, len(values))
+	for _, v := range values {
+		rows = append(rows, []string{v.Key, v.Value, v.Source})
+	}
+	ui.Table([]string{"Key", "Value", "Source"}, rows)
+	return nil
+}
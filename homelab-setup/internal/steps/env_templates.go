@@ -0,0 +1,191 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+// envBaseTemplate renders the variables every stack's .env shares
+const envBaseTemplate = `# UBlue uCore Homelab - {{ .Title }} Stack Environment
+# Generated by homelab-setup
+
+# User/Group Configuration
+PUID={{ .PUID }}
+PGID={{ .PGID }}
+TZ={{ .TZ }}
+
+# Paths
+APPDATA_PATH={{ .AppdataPath }}
+
+`
+
+// envServiceTemplates holds each stack's extra .env variables, appended after
+// envBaseTemplate. {{ cfg "KEY" "default" }} reads a config value. Adding a
+// stack's environment only needs a new entry here.
+var envServiceTemplates = map[string]string{
+	"media": `# Plex Configuration
+PLEX_CLAIM_TOKEN={{ cfg "PLEX_CLAIM_TOKEN" "" }}
+# Get your claim token from: https://www.plex.tv/claim/
+
+# Jellyfin Configuration
+JELLYFIN_PUBLIC_URL={{ cfg "JELLYFIN_PUBLIC_URL" "" }}
+
+# Hardware Transcoding
+{{ transcode }}
+# Note: Media paths are configured in the compose file
+# Ensure NFS mounts are set up at /mnt/nas-media before starting services
+
+`,
+
+	"web": `# Overseerr Configuration (optional - configure in UI)
+OVERSEERR_API_KEY={{ cfg "OVERSEERR_API_KEY" "" }}
+
+# Web Service Ports (default values from compose file)
+OVERSEERR_PORT={{ cfg "OVERSEERR_PORT" "5055" }}
+WIZARR_PORT={{ cfg "WIZARR_PORT" "5690" }}
+ORGANIZR_PORT={{ cfg "ORGANIZR_PORT" "9983" }}
+HOMEPAGE_PORT={{ cfg "HOMEPAGE_PORT" "3000" }}
+
+# Note: These services are typically accessed via reverse proxy
+# Configure your reverse proxy to route to these ports via WireGuard tunnel
+
+`,
+
+	"cloud": `# Nextcloud Admin Credentials (for initial setup)
+NEXTCLOUD_ADMIN_USER={{ cfg "NEXTCLOUD_ADMIN_USER" "admin" }}
+NEXTCLOUD_ADMIN_PASSWORD={{ cfg "NEXTCLOUD_ADMIN_PASSWORD" "" }}
+
+# Nextcloud Database Configuration
+NEXTCLOUD_DB_USERNAME={{ cfg "NEXTCLOUD_DB_USERNAME" "nc_user" }}
+NEXTCLOUD_DB_PASSWORD={{ cfg "NEXTCLOUD_DB_PASSWORD" "" }}
+NEXTCLOUD_DB_DATABASE={{ cfg "NEXTCLOUD_DB_DATABASE" "nextcloud" }}
+
+# Nextcloud Domain Configuration
+NEXTCLOUD_TRUSTED_DOMAINS={{ cfg "NEXTCLOUD_TRUSTED_DOMAINS" "localhost" }}
+NEXTCLOUD_OVERWRITE_HOST={{ cfg "NEXTCLOUD_OVERWRITE_HOST" "localhost" }}
+
+# Nextcloud PHP Limits
+NEXTCLOUD_PHP_MEMORY_LIMIT={{ cfg "NEXTCLOUD_PHP_MEMORY_LIMIT" "1024M" }}
+NEXTCLOUD_PHP_UPLOAD_LIMIT={{ cfg "NEXTCLOUD_PHP_UPLOAD_LIMIT" "1024M" }}
+
+# Collabora Online Configuration (optional)
+COLLABORA_USERNAME={{ cfg "COLLABORA_USERNAME" "admin" }}
+COLLABORA_PASSWORD={{ cfg "COLLABORA_PASSWORD" "" }}
+COLLABORA_DOMAIN={{ cfg "COLLABORA_DOMAIN" "localhost" }}
+
+# Immich Database Configuration
+IMMICH_DB_USERNAME={{ cfg "IMMICH_DB_USERNAME" "postgres" }}
+IMMICH_DB_PASSWORD={{ cfg "IMMICH_DB_PASSWORD" "" }}
+IMMICH_DB_DATABASE={{ cfg "IMMICH_DB_DATABASE" "immich" }}
+
+`,
+}
+
+// envBaseData holds the values of envBaseTemplate
+type envBaseData struct {
+	Title       string
+	PUID        string
+	PGID        string
+	TZ          string
+	AppdataPath string
+}
+
+// newEnvBaseData reads the shared .env values for a stack from config
+func newEnvBaseData(cfg *config.Config, serviceName string) envBaseData {
+	// Use PUID/PGID directly from user setup (not ENV_PUID/ENV_PGID)
+	// This ensures containers run with the actual service account UID/GID
+	defaultUID, defaultGID := defaultPUIDPGID()
+	// Try APPDATA_BASE first (new standard), fall back to ENV_APPDATA_PATH (legacy)
+	appdataPath := cfg.GetOrDefault("APPDATA_BASE", "")
+	if appdataPath == "" {
+		appdataPath = cfg.GetOrDefault("ENV_APPDATA_PATH", "/var/lib/containers/appdata")
+	}
+
+	return envBaseData{
+		Title:       cases.Title(language.English).String(serviceName),
+		PUID:        cfg.GetOrDefault("PUID", defaultUID),
+		PGID:        cfg.GetOrDefault("PGID", defaultGID),
+		TZ:          cfg.GetOrDefault("TZ", "America/Chicago"),
+		AppdataPath: appdataPath,
+	}
+}
+
+// envTemplateFuncs returns the functions available to env templates
+func envTemplateFuncs(cfg *config.Config) template.FuncMap {
+	return template.FuncMap{
+		"cfg": cfg.GetOrDefault,
+		"transcode": func() string {
+			return transcodeEnvLines(transcodeDevice(cfg))
+		},
+	}
+}
+
+// renderEnvTemplate renders one env template with data
+func renderEnvTemplate(cfg *config.Config, name, text string, data any) (string, error) {
+	tmpl, err := template.New(name).Funcs(envTemplateFuncs(cfg)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s env template: %w", name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render %s env template: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// generateEnvContent generates .env file content for a service: the shared
+// base variables followed by the service's own template, if it has one
+func generateEnvContent(cfg *config.Config, serviceName string) (string, error) {
+	data := newEnvBaseData(cfg, serviceName)
+	if err := common.ValidateUID(data.PUID); err != nil {
+		return "", fmt.Errorf("invalid PUID: %w", err)
+	}
+	if err := common.ValidateGID(data.PGID); err != nil {
+		return "", fmt.Errorf("invalid PGID: %w", err)
+	}
+	content, err := renderEnvTemplate(cfg, "base", envBaseTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	if text, ok := envServiceTemplates[serviceName]; ok {
+		extra, err := renderEnvTemplate(cfg, serviceName, text, nil)
+		if err != nil {
+			return "", err
+		}
+		content += extra
+	}
+
+	overrides, err := appdataOverrideLines(cfg, serviceName)
+	if err != nil {
+		return "", err
+	}
+	return content + overrides, nil
+}
+
+// appdataOverrideLines renders APPDATA_PATH_<APP> variables for the stack's
+// apps whose appdata is overridden, or "" when none are. Compose files can
+// mount them as ${APPDATA_PATH_PLEX:-${APPDATA_PATH}/plex}.
+func appdataOverrideLines(cfg *config.Config, serviceName string) (string, error) {
+	var lines strings.Builder
+	for _, app := range stackAppdataDirs[serviceName] {
+		path, overridden, err := appdataPath(cfg, "", app)
+		if err != nil {
+			return "", err
+		}
+		if overridden {
+			fmt.Fprintf(&lines, "%s=%s\n", appdataOverrideKey(app), path)
+		}
+	}
+	if lines.Len() == 0 {
+		return "", nil
+	}
+	return "# Per-application appdata overrides\n" + lines.String() + "\n", nil
+}
@@ -0,0 +1,129 @@
+package steps
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+func TestEnvServiceTemplatesRender(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyTranscodeDevice, ""); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	for name, text := range envServiceTemplates {
+		t.Run(name, func(t *testing.T) {
+			out, err := renderEnvTemplate(cfg, name, text, nil)
+			if err != nil {
+				t.Fatalf("renderEnvTemplate() error = %v", err)
+			}
+			if strings.Contains(out, "{{") || strings.Contains(out, "<no value>") {
+				t.Errorf("template left unrendered output:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestWebEnvTemplateUsesConfigAndDefaults(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("OVERSEERR_PORT", "15055"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	out, err := renderEnvTemplate(cfg, "web", envServiceTemplates["web"], nil)
+	if err != nil {
+		t.Fatalf("renderEnvTemplate() error = %v", err)
+	}
+	for _, line := range []string{"OVERSEERR_PORT=15055\n", "WIZARR_PORT=5690\n", "OVERSEERR_API_KEY=\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("web env missing %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestGenerateEnvContent(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	for key, value := range map[string]string{
+		"PUID":                    "1001",
+		"PGID":                    "1002",
+		"TZ":                      "UTC",
+		"APPDATA_BASE":            "/srv/appdata",
+		"PLEX_CLAIM_TOKEN":        "claim-abc",
+		config.KeyTranscodeDevice: "/dev/dri/renderD128",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	media, err := generateEnvContent(cfg, "media")
+	if err != nil {
+		t.Fatalf("generateEnvContent() error = %v", err)
+	}
+	wantPrefix := "# UBlue uCore Homelab - Media Stack Environment\n# Generated by homelab-setup\n\n" +
+		"# User/Group Configuration\nPUID=1001\nPGID=1002\nTZ=UTC\n\n# Paths\nAPPDATA_PATH=/srv/appdata\n\n# Plex Configuration\n"
+	if !strings.HasPrefix(media, wantPrefix) {
+		t.Errorf("media env header mismatch:\n%s", media)
+	}
+	for _, line := range []string{"PLEX_CLAIM_TOKEN=claim-abc\n", "TRANSCODE_DEVICE=/dev/dri/renderD128\n"} {
+		if !strings.Contains(media, line) {
+			t.Errorf("media env missing %q", line)
+		}
+	}
+
+	// Stacks without a service template get only the shared variables
+	other, err := generateEnvContent(cfg, "other")
+	if err != nil {
+		t.Fatalf("generateEnvContent() error = %v", err)
+	}
+	if !strings.HasSuffix(other, "APPDATA_PATH=/srv/appdata\n\n") {
+		t.Errorf("unexpected content for stack without a template:\n%s", other)
+	}
+}
+
+func TestGenerateEnvContentAppdataOverrides(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("APPDATA_PATH_PLEX", "/mnt/ssd/plex"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	media, err := generateEnvContent(cfg, "media")
+	if err != nil {
+		t.Fatalf("generateEnvContent() error = %v", err)
+	}
+	if !strings.HasSuffix(media, "# Per-application appdata overrides\nAPPDATA_PATH_PLEX=/mnt/ssd/plex\n\n") {
+		t.Errorf("media env missing plex override:\n%s", media)
+	}
+
+	// Other stacks do not get the override
+	web, err := generateEnvContent(cfg, "web")
+	if err != nil {
+		t.Fatalf("generateEnvContent() error = %v", err)
+	}
+	if strings.Contains(web, "APPDATA_PATH_PLEX") {
+		t.Errorf("web env should not contain the plex override:\n%s", web)
+	}
+
+	if err := cfg.Set("APPDATA_PATH_PLEX", "/mnt/$(evil)"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := generateEnvContent(cfg, "media"); err == nil {
+		t.Error("expected an error for an unsafe override")
+	}
+}
+
+func TestGenerateEnvContentRejectsInvalidIDs(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("PUID", "1000"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set("PGID", "users"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := generateEnvContent(cfg, "web"); err == nil || !strings.Contains(err.Error(), "PGID") {
+		t.Errorf("generateEnvContent() error = %v, want an invalid PGID error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package steps
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// firewalld operations used by the WireGuard port check, replaced in tests
+var (
+	firewallRunning  = system.IsFirewalldRunning
+	firewallZoneOf   = system.FirewallZoneOfInterface
+	firewallPortOpen = system.IsFirewallPortOpen
+	openFirewallPort = system.OpenFirewallPort
+)
+
+// firewallPort is a port a stack needs reachable from the LAN
+type firewallPort struct {
+	Name     string
+	Port     string
+	Protocol string
+}
+
+// stackFirewallPorts returns the ports for a stack sorted by port name
+func stackFirewallPorts(cfg *config.Config, stack string) []firewallPort {
+	var ports []firewallPort
+	for name, port := range resolveStackPorts(cfg, stack) {
+		ports = append(ports, firewallPort{Name: name, Port: port, Protocol: "tcp"})
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Name < ports[j].Name
+	})
+	return ports
+}
+
+// configureFirewallPorts reports the firewalld state of each selected stack's
+// ports and offers to open missing ones, per stack
+func configureFirewallPorts(cfg *config.Config, ui *ui.UI) error {
+	if !system.CommandExists("firewall-cmd") {
+		ui.Info("firewalld not installed, skipping firewall configuration")
+		return nil
+	}
+	if !system.IsFirewalldRunning() {
+		ui.Info("firewalld is not running, skipping firewall configuration")
+		return nil
+	}
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, stack := range selectedServices {
+		ports := stackFirewallPorts(cfg, stack)
+		if len(ports) == 0 {
+			continue
+		}
+
+		ui.Infof("%s stack:", stack)
+		var closed []firewallPort
+		for _, p := range ports {
+			if err := common.ValidatePort(p.Port); err != nil {
+				ui.Warningf("  Skipping %s: %v", p.Name, err)
+				continue
+			}
+			open, err := system.IsFirewallPortOpen(p.Port, p.Protocol, "")
+			if err != nil {
+				return err
+			}
+			if open {
+				ui.Successf("  %s %s/%s: open", p.Name, p.Port, p.Protocol)
+			} else {
+				ui.Warningf("  %s %s/%s: closed", p.Name, p.Port, p.Protocol)
+				closed = append(closed, p)
+			}
+		}
+
+		if len(closed) == 0 {
+			continue
+		}
+
+		openPorts, err := ui.PromptYesNo(fmt.Sprintf("Open %d port(s) for the %s stack in firewalld?", len(closed), stack), false)
+		if err != nil {
+			return fmt.Errorf("failed to prompt: %w", err)
+		}
+		if !openPorts {
+			ui.Info("  Leaving firewall unchanged")
+			continue
+		}
+
+		for _, p := range closed {
+			if err := system.OpenFirewallPort(p.Port, p.Protocol, ""); err != nil {
+				return err
+			}
+			ui.Successf("  Opened %s/%s (%s)", p.Port, p.Protocol, p.Name)
+		}
+	}
+
+	return nil
+}
+
+// openWireGuardFirewallPort offers to open the WireGuard listen port. With a
+// listen interface chosen, the port is opened only in that interface's
+// firewalld zone so peers cannot reach it through other networks.
+func openWireGuardFirewallPort(ui *ui.UI, listenPort, listenInterface string) error {
+	if !firewallRunning() {
+		ui.Info("firewalld is not running, skipping firewall configuration")
+		return nil
+	}
+
+	zone, where := "", "the default zone"
+	if listenInterface != "" {
+		var err error
+		if zone, err = firewallZoneOf(listenInterface); err != nil {
+			return err
+		}
+		if zone == "" {
+			where = fmt.Sprintf("the default zone (%s is not bound to a zone)", listenInterface)
+		} else {
+			where = fmt.Sprintf("zone %s (%s)", zone, listenInterface)
+		}
+	}
+
+	open, err := firewallPortOpen(listenPort, "udp", zone)
+	if err != nil {
+		return err
+	}
+	if open {
+		ui.Successf("%s/udp is open in %s", listenPort, where)
+		return nil
+	}
+
+	ui.Warningf("%s/udp is closed in %s", listenPort, where)
+	openPort, err := ui.PromptYesNo(fmt.Sprintf("Open %s/udp in %s?", listenPort, where), false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !openPort {
+		ui.Info("Leaving firewall unchanged; peers cannot connect until the port is open")
+		return nil
+	}
+	if err := openFirewallPort(listenPort, "udp", zone); err != nil {
+		return err
+	}
+	ui.Successf("Opened %s/udp in %s", listenPort, where)
+	return nil
+}
+
+// RunFirewallSetup checks and opens firewall ports for the selected stacks
+func RunFirewallSetup(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Firewall Ports")
+	return configureFirewallPorts(cfg, ui)
+}
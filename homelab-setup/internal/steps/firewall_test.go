@@ -0,0 +1,75 @@
+package steps
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// fakeFirewall replaces the firewalld operations with a fake in which the
+// given interfaces are bound to zones and ports are open per zone
+func fakeFirewall(t *testing.T, zones map[string]string, open map[string]bool) *[]string {
+	t.Helper()
+	running, zoneOf, portOpen, openPort := firewallRunning, firewallZoneOf, firewallPortOpen, openFirewallPort
+	t.Cleanup(func() {
+		firewallRunning, firewallZoneOf, firewallPortOpen, openFirewallPort = running, zoneOf, portOpen, openPort
+	})
+
+	var queried []string
+	firewallRunning = func() bool { return true }
+	firewallZoneOf = func(name string) (string, error) { return zones[name], nil }
+	firewallPortOpen = func(port, protocol, zone string) (bool, error) {
+		queried = append(queried, port+"/"+protocol+"@"+zone)
+		return open[zone], nil
+	}
+	openFirewallPort = func(port, protocol, zone string) error {
+		t.Errorf("opened %s/%s in %q without confirmation", port, protocol, zone)
+		return nil
+	}
+	return &queried
+}
+
+func TestOpenWireGuardFirewallPortUsesInterfaceZone(t *testing.T) {
+	tests := []struct {
+		name            string
+		listenInterface string
+		wantQuery       string
+		wantOutput      string
+	}{
+		{"any interface", "", "51820/udp@", "closed in the default zone"},
+		{"bound interface", "enp2s0", "51820/udp@external", "closed in zone external (enp2s0)"},
+		{"unbound interface", "enp3s0", "51820/udp@", "enp3s0 is not bound to a zone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried := fakeFirewall(t, map[string]string{"enp2s0": "external"}, nil)
+			var buf bytes.Buffer
+			u := ui.NewWithWriter(&buf)
+			u.SetNonInteractive(true)
+
+			if err := openWireGuardFirewallPort(u, "51820", tt.listenInterface); err != nil {
+				t.Fatalf("openWireGuardFirewallPort() error = %v", err)
+			}
+			if len(*queried) != 1 || (*queried)[0] != tt.wantQuery {
+				t.Errorf("queried %v, want [%s]", *queried, tt.wantQuery)
+			}
+			if !strings.Contains(buf.String(), tt.wantOutput) {
+				t.Errorf("output missing %q:\n%s", tt.wantOutput, buf.String())
+			}
+		})
+	}
+}
+
+func TestOpenWireGuardFirewallPortAlreadyOpen(t *testing.T) {
+	fakeFirewall(t, map[string]string{"enp2s0": "external"}, map[string]bool{"external": true})
+	var buf bytes.Buffer
+	if err := openWireGuardFirewallPort(ui.NewWithWriter(&buf), "51820", "enp2s0"); err != nil {
+		t.Fatalf("openWireGuardFirewallPort() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "51820/udp is open in zone external") {
+		t.Errorf("output = %q", buf.String())
+	}
+}
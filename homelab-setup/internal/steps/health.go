@@ -0,0 +1,222 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// healthProbeTimeout bounds each HTTP health probe
+const healthProbeTimeout = 5 * time.Second
+
+// healthPollInterval is the pause between rounds of probes in waitForHealthy
+var healthPollInterval = 3 * time.Second
+
+// stackHealthTimeouts overrides HEALTH_TIMEOUT for stacks known to start
+// slowly. Immich's machine-learning container downloads models on first start.
+var stackHealthTimeouts = map[string]time.Duration{
+	"cloud": 10 * time.Minute,
+}
+
+// defaultHealthPaths maps each web UI to an endpoint that answers 2xx without
+// authentication once the app is ready. Apps not listed are probed at "/".
+var defaultHealthPaths = map[string]string{
+	"Plex":      "/identity",
+	"Jellyfin":  "/health",
+	"Tautulli":  "/status",
+	"Overseerr": "/api/v1/status",
+	"Nextcloud": "/status.php",
+	"Collabora": "/hosting/discovery",
+	"Immich":    "/api/server/ping",
+}
+
+// HealthEndpoint is an HTTP endpoint that reports whether one app is ready
+type HealthEndpoint struct {
+	Name string
+	URL  string
+}
+
+// healthPath returns the probe path for app, preferring a HEALTH_PATH_<APP>
+// override from config
+func healthPath(cfg *config.Config, app string) string {
+	path := defaultHealthPaths[app]
+	if path == "" {
+		path = "/"
+	}
+	path = cfg.GetOrDefault(config.KeyHealthPathPrefix+strings.ToUpper(app), path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// healthEndpoints returns the health endpoints of a stack's web UIs, sorted by name
+func healthEndpoints(cfg *config.Config, serviceName string) []HealthEndpoint {
+	ports := resolveStackPorts(cfg, serviceName)
+	endpoints := make([]HealthEndpoint, 0, len(ports))
+	for app, port := range ports {
+		endpoints = append(endpoints, HealthEndpoint{
+			Name: app,
+			URL:  fmt.Sprintf("http://localhost:%s%s", port, healthPath(cfg, app)),
+		})
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Name < endpoints[j].Name })
+	return endpoints
+}
+
+// publicURLKey returns the config key holding app's public URL, e.g.
+// JELLYFIN_PUBLIC_URL
+func publicURLKey(app string) string {
+	return strings.ToUpper(app) + config.KeyPublicURLSuffix
+}
+
+// publicHealthEndpoints returns the health endpoints of a stack's web UIs as
+// reached through the reverse proxy, for apps with a public URL configured,
+// sorted by name
+func publicHealthEndpoints(cfg *config.Config, serviceName string) []HealthEndpoint {
+	var endpoints []HealthEndpoint
+	for app := range resolveStackPorts(cfg, serviceName) {
+		base := strings.TrimRight(strings.TrimSpace(cfg.GetOrDefault(publicURLKey(app), "")), "/")
+		if base == "" {
+			continue
+		}
+		endpoints = append(endpoints, HealthEndpoint{Name: app, URL: base + healthPath(cfg, app)})
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Name < endpoints[j].Name })
+	return endpoints
+}
+
+// publicHealthEnabled reports whether HEALTH_CHECK_PUBLIC_URLS is set
+func publicHealthEnabled(cfg *config.Config) bool {
+	enabled, err := strconv.ParseBool(cfg.GetOrDefault(config.KeyHealthCheckPublic, "false"))
+	return err == nil && enabled
+}
+
+// publicHealthTLSVerify reports whether public URL probes validate TLS
+// certificates (HEALTH_TLS_VERIFY, default true)
+func publicHealthTLSVerify(cfg *config.Config) bool {
+	verify, err := strconv.ParseBool(cfg.GetOrDefault(config.KeyHealthTLSVerify, "true"))
+	return err != nil || verify
+}
+
+// probePublicHealth probes endpoints end to end through the reverse proxy,
+// following redirects
+func probePublicHealth(ctx context.Context, endpoints []HealthEndpoint, verifyTLS bool) []system.HealthResult {
+	results := make([]system.HealthResult, len(endpoints))
+	for i, endpoint := range endpoints {
+		results[i] = system.ProbePublicURL(ctx, endpoint.URL, healthProbeTimeout, verifyTLS)
+	}
+	return results
+}
+
+// publicHealthCheck converts a public URL probe result into a verification
+// report line, naming the redirect target and hinting at HEALTH_TLS_VERIFY
+// when the certificate is not trusted
+func publicHealthCheck(name string, result system.HealthResult) verifyCheck {
+	check := healthCheck(name+" public", result)
+	switch {
+	case check.err == nil && result.FinalURL != "":
+		check.detail += " via " + result.FinalURL
+	case result.IsCertificateError():
+		check.err = fmt.Errorf("%w; set %s=false if the proxy uses a self-signed certificate", check.err, config.KeyHealthTLSVerify)
+	}
+	return check
+}
+
+// probeHealth probes every endpoint of a service in order
+func probeHealth(ctx context.Context, serviceInfo *ServiceInfo) []system.HealthResult {
+	results := make([]system.HealthResult, len(serviceInfo.Health))
+	for i, endpoint := range serviceInfo.Health {
+		results[i] = system.ProbeHTTP(ctx, endpoint.URL, healthProbeTimeout, nil)
+	}
+	return results
+}
+
+// healthCheck converts a probe result into a verification report line that
+// includes the status code and latency
+func healthCheck(name string, result system.HealthResult) verifyCheck {
+	check := verifyCheck{name: name + " health"}
+	latency := result.Latency.Round(time.Millisecond)
+	switch result.State {
+	case system.HealthOK:
+		check.detail = fmt.Sprintf("HTTP %d in %s (%s)", result.StatusCode, latency, result.URL)
+	case system.HealthBadStatus:
+		check.err = fmt.Errorf("%s: HTTP %d in %s (%s)", result.State, result.StatusCode, latency, result.URL)
+	default:
+		check.err = fmt.Errorf("%s: %s (%v)", result.State, result.URL, result.Err)
+	}
+	return check
+}
+
+// healthTimeout returns how long to wait for a stack to become healthy:
+// HEALTH_TIMEOUT_<STACK> if set, then the stack's built-in default, then
+// HEALTH_TIMEOUT
+func healthTimeout(cfg *config.Config, serviceName string) time.Duration {
+	if v := cfg.GetOrDefault(config.KeyHealthTimeoutPrefix+strings.ToUpper(serviceName), ""); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if timeout, ok := stackHealthTimeouts[serviceName]; ok {
+		return timeout
+	}
+	seconds, err := strconv.Atoi(cfg.GetOrDefault(config.KeyHealthTimeout, "180"))
+	if err != nil || seconds <= 0 {
+		seconds = 180
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// waitForHealthy polls a service's health endpoints until all of them are
+// healthy or timeout elapses. On timeout the error carries the last result of
+// every endpoint that was still failing.
+func waitForHealthy(ctx context.Context, ui *ui.UI, serviceInfo *ServiceInfo, timeout time.Duration) error {
+	if len(serviceInfo.Health) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spinner := ui.Spinner(fmt.Sprintf("Waiting for %s to become healthy...", serviceInfo.DisplayName))
+	spinner.Start()
+
+	start := time.Now()
+	var failing []string
+	for {
+		var round []string
+		for i, result := range probeHealth(ctx, serviceInfo) {
+			if result.State != system.HealthOK {
+				check := healthCheck(serviceInfo.Health[i].Name, result)
+				round = append(round, fmt.Sprintf("%s: %v", check.name, check.err))
+			}
+		}
+		// A round cut short by the deadline says nothing new; keep the last
+		// complete one for the error
+		if ctx.Err() == nil || failing == nil {
+			failing = round
+		}
+		if len(failing) == 0 {
+			spinner.Success(fmt.Sprintf("%s healthy after %s", serviceInfo.DisplayName, time.Since(start).Round(time.Second)))
+			return nil
+		}
+
+		healthy := len(serviceInfo.Health) - len(failing)
+		spinner.UpdateMessage(fmt.Sprintf("Waiting for %s to become healthy (%d/%d up, %s elapsed)...",
+			serviceInfo.DisplayName, healthy, len(serviceInfo.Health), time.Since(start).Round(time.Second)))
+
+		select {
+		case <-ctx.Done():
+			spinner.Fail(fmt.Sprintf("%s not healthy after %s", serviceInfo.DisplayName, timeout))
+			return fmt.Errorf("timed out after %s waiting for %s: %s", timeout, serviceInfo.DisplayName, strings.Join(failing, "; "))
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
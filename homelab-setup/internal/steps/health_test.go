@@ -0,0 +1,144 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyHealthPathPrefix+"JELLYFIN", "System/Ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := healthEndpoints(cfg, "media")
+	want := []HealthEndpoint{
+		{"Jellyfin", "http://localhost:8096/System/Ping"},
+		{"Plex", "http://localhost:32400/identity"},
+		{"Tautulli", "http://localhost:8181/status"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("healthEndpoints() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("healthEndpoints()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPublicHealthEndpoints(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := publicHealthEndpoints(cfg, "media"); len(got) != 0 {
+		t.Errorf("publicHealthEndpoints() without public URLs = %v, want none", got)
+	}
+
+	if err := cfg.Set("JELLYFIN_PUBLIC_URL", "https://jellyfin.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("PLEX_PUBLIC_URL", "https://example.com/plex"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := publicHealthEndpoints(cfg, "media")
+	want := []HealthEndpoint{
+		{"Jellyfin", "https://jellyfin.example.com/health"},
+		{"Plex", "https://example.com/plex/identity"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("publicHealthEndpoints() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("publicHealthEndpoints()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPublicHealthCheckSelfSigned(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	endpoints := []HealthEndpoint{{"Jellyfin", srv.URL + "/health"}}
+	check := publicHealthCheck("Jellyfin", probePublicHealth(context.Background(), endpoints, true)[0])
+	if check.err == nil || !strings.Contains(check.err.Error(), config.KeyHealthTLSVerify) {
+		t.Errorf("publicHealthCheck() err = %v, want hint about %s", check.err, config.KeyHealthTLSVerify)
+	}
+
+	check = publicHealthCheck("Jellyfin", probePublicHealth(context.Background(), endpoints, false)[0])
+	if check.err != nil {
+		t.Errorf("publicHealthCheck() without TLS verification err = %v", check.err)
+	}
+}
+
+func TestHealthPathDefaultsToRoot(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := healthPath(cfg, "Homepage"); got != "/" {
+		t.Errorf("healthPath(Homepage) = %q, want /", got)
+	}
+}
+
+func TestHealthTimeout(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := healthTimeout(cfg, "media"); got != 180*time.Second {
+		t.Errorf("healthTimeout(media) = %s, want default 3m0s", got)
+	}
+	if got := healthTimeout(cfg, "cloud"); got != 10*time.Minute {
+		t.Errorf("healthTimeout(cloud) = %s, want built-in 10m0s", got)
+	}
+	if err := cfg.Set(config.KeyHealthTimeoutPrefix+"CLOUD", "30"); err != nil {
+		t.Fatal(err)
+	}
+	if got := healthTimeout(cfg, "cloud"); got != 30*time.Second {
+		t.Errorf("healthTimeout(cloud) = %s, want override 30s", got)
+	}
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	orig := healthPollInterval
+	healthPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { healthPollInterval = orig })
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" || calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	out := ui.NewWithWriter(&bytes.Buffer{})
+
+	t.Run("becomes healthy", func(t *testing.T) {
+		info := &ServiceInfo{DisplayName: "Media", Health: []HealthEndpoint{{"Plex", srv.URL + "/identity"}}}
+		if err := waitForHealthy(context.Background(), out, info, 5*time.Second); err != nil {
+			t.Fatalf("waitForHealthy() error = %v", err)
+		}
+		if calls.Load() < 3 {
+			t.Errorf("expected polling until healthy, got %d probes", calls.Load())
+		}
+	})
+
+	t.Run("times out with last detail", func(t *testing.T) {
+		info := &ServiceInfo{DisplayName: "Web", Health: []HealthEndpoint{{"Overseerr", srv.URL + "/broken"}}}
+		err := waitForHealthy(context.Background(), out, info, 100*time.Millisecond)
+		if err == nil {
+			t.Fatal("expected timeout error")
+		}
+		if !strings.Contains(err.Error(), "Overseerr health") || !strings.Contains(err.Error(), "HTTP 503") {
+			t.Errorf("expected last probe detail in error, got %v", err)
+		}
+	})
+}
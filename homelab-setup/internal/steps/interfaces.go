@@ -0,0 +1,55 @@
+package steps
+
+import (
+	"fmt"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// defaultInterfaceOption is the first choice in interface prompts and keeps
+// the kernel's routing decision
+const defaultInterfaceOption = "Default (let the routing table decide)"
+
+// usableInterfaces returns interfaces that are up, not loopback and have an address
+func usableInterfaces() ([]system.NetInterface, error) {
+	interfaces, err := system.ListInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var usable []system.NetInterface
+	for _, iface := range interfaces {
+		if iface.Up && !iface.Loopback && len(iface.Addresses) > 0 {
+			usable = append(usable, iface)
+		}
+	}
+	return usable, nil
+}
+
+// promptForInterface asks the user to pick a network interface on multi-homed
+// hosts. Returns "" when there is only one usable interface or the user keeps
+// the default.
+func promptForInterface(ui *ui.UI, prompt string) (string, error) {
+	interfaces, err := usableInterfaces()
+	if err != nil {
+		return "", err
+	}
+	if len(interfaces) < 2 {
+		return "", nil
+	}
+
+	options := []string{defaultInterfaceOption}
+	for _, iface := range interfaces {
+		options = append(options, iface.String())
+	}
+
+	index, err := ui.PromptSelect(prompt, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt for interface: %w", err)
+	}
+	if index == 0 {
+		return "", nil
+	}
+	return interfaces[index-1].Name, nil
+}
@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os/exec"
@@ -214,21 +215,15 @@ func validateNFSConnection(cfg *config.Config, ui *ui.UI, host string) error {
 			return fmt.Errorf("failed to prompt: %w", err)
 		}
 		if !continueAnyway {
-			return fmt.Errorf("NFS setup cancelled")
+			return fmt.Errorf("NFS setup %w", ErrCancelled)
 		}
 	} else {
 		ui.Success("NFS server has accessible exports")
 
 		// Try to display exports
-		exports, err := system.GetNFSExports(host)
-		if err == nil && exports != "" {
+		if exports, err := system.GetNFSExports(host); err == nil && strings.TrimSpace(exports) != "" {
 			ui.Print("")
-			ui.Info("Available NFS exports:")
-			for _, line := range strings.Split(exports, "\n") {
-				if strings.TrimSpace(line) != "" {
-					ui.Printf("  %s", line)
-				}
-			}
+			showNFSExports(ui, exports)
 			ui.Print("")
 		}
 	}
@@ -236,6 +231,37 @@ func validateNFSConnection(cfg *config.Config, ui *ui.UI, host string) error {
 	return nil
 }
 
+// showNFSExports prints a server's export list as a table, falling back to
+// the raw showmount output when it cannot be parsed. It returns the parsed
+// exports, or nil if parsing failed.
+func showNFSExports(ui *ui.UI, raw string) []system.NFSExport {
+	ui.Info("Available NFS exports:")
+	exports, err := system.ParseNFSExports(raw)
+	if err != nil {
+		for _, line := range strings.Split(raw, "\n") {
+			if strings.TrimSpace(line) != "" {
+				ui.Printf("  %s", line)
+			}
+		}
+		return nil
+	}
+	if len(exports) == 0 {
+		ui.Info("  (none)")
+		return exports
+	}
+
+	rows := make([][]string, 0, len(exports))
+	for _, export := range exports {
+		clients := strings.Join(export.Clients, ", ")
+		if clients == "" {
+			clients = "(unrestricted)"
+		}
+		rows = append(rows, []string{export.Path, clients})
+	}
+	ui.Table([]string{"Export", "Clients"}, rows)
+	return exports
+}
+
 // validateNFSExport verifies that the specified export path exists on the NFS server
 func validateNFSExport(_ *config.Config, ui *ui.UI, host, export string) error {
 	ui.Infof("Verifying export path '%s' on server...", export)
@@ -248,26 +274,19 @@ func validateNFSExport(_ *config.Config, ui *ui.UI, host, export string) error {
 		return nil // Non-critical, let mount attempt reveal the issue
 	}
 
-	// Parse exports and check if our export exists
-	// showmount output format: "Export list for <host>:"
-	// "/export/path client1,client2"
-	exportFound := false
-	lines := strings.Split(exports, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "Export list") {
-			continue
-		}
+	parsed, err := system.ParseNFSExports(exports)
+	if err != nil {
+		ui.Warning(fmt.Sprintf("Could not verify export path: %v", err))
+		ui.Info("Proceeding without verification - mount will fail if export doesn't exist")
+		return nil
+	}
 
-		// Extract the export path (first field)
-		fields := strings.Fields(line)
-		if len(fields) > 0 {
-			serverExport := fields[0]
-			if serverExport == export {
-				exportFound = true
-				ui.Successf("Export path '%s' exists on server", export)
-				break
-			}
+	exportFound := false
+	for _, serverExport := range parsed {
+		if serverExport.Path == export {
+			exportFound = true
+			ui.Successf("Export path '%s' exists on server", export)
+			break
 		}
 	}
 
@@ -283,7 +302,7 @@ func validateNFSExport(_ *config.Config, ui *ui.UI, host, export string) error {
 			return fmt.Errorf("failed to prompt: %w", err)
 		}
 		if !continueAnyway {
-			return fmt.Errorf("NFS setup cancelled - export path not verified")
+			return fmt.Errorf("NFS setup %w - export path not verified", ErrCancelled)
 		}
 	}
 
@@ -322,23 +341,115 @@ func mountPointToUnitBaseName(mountPoint string) string {
 	return name
 }
 
-// getNFSMountOptions returns the NFS mount options from config or a default
-func getNFSMountOptions(cfg *config.Config) string {
-	// Base options enforce safe boot behavior and network readiness
-	baseOptions := []string{"defaults", "nfsvers=4.2", "_netdev", "nofail"}
+// defaultNFSVersion is used when NFS_VERSION is unset
+const defaultNFSVersion = "4.2"
+
+// nfsVersions are the accepted NFS_VERSION values; "4" negotiates the
+// highest minor version both sides support
+var nfsVersions = []string{"3", "4", "4.0", "4.1", "4.2"}
+
+// nfsFlagOptions are the known mount options that take no value
+var nfsFlagOptions = map[string]bool{
+	"defaults": true, "_netdev": true, "nofail": true, "auto": true, "noauto": true,
+	"user": true, "nouser": true, "ro": true, "rw": true, "sync": true, "async": true,
+	"exec": true, "noexec": true, "suid": true, "nosuid": true, "dev": true, "nodev": true,
+	"atime": true, "noatime": true, "relatime": true, "nodiratime": true,
+	"hard": true, "soft": true, "softerr": true, "intr": true, "nointr": true,
+	"bg": true, "fg": true, "ac": true, "noac": true, "cto": true, "nocto": true,
+	"lock": true, "nolock": true, "acl": true, "noacl": true, "fsc": true, "nofsc": true,
+	"sharecache": true, "nosharecache": true, "resvport": true, "noresvport": true,
+	"rdirplus": true, "nordirplus": true, "posix": true, "noposix": true,
+	"tcp": true, "udp": true, "rdma": true,
+}
 
-	// Parse user options into a map for easy lookup
+// nfsValueOptions are the known mount options that need a value
+var nfsValueOptions = map[string]bool{
+	"nfsvers": true, "vers": true, "minorversion": true, "proto": true, "port": true,
+	"mountproto": true, "mountport": true, "mountvers": true, "timeo": true, "retrans": true,
+	"retry": true, "rsize": true, "wsize": true, "sec": true, "actimeo": true,
+	"acregmin": true, "acregmax": true, "acdirmin": true, "acdirmax": true,
+	"clientaddr": true, "nconnect": true, "lookupcache": true, "local_lock": true,
+	"namlen": true, "max_connect": true, "xprtsec": true,
+	// SELinux labels, e.g. context=system_u:object_r:container_file_t:s0
+	"context": true, "fscontext": true, "defcontext": true, "rootcontext": true,
+}
+
+// validateNFSVersion checks an NFS_VERSION value
+func validateNFSVersion(version string) error {
+	for _, v := range nfsVersions {
+		if version == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported NFS version %q (use one of %s)", version, strings.Join(nfsVersions, ", "))
+}
+
+// validateNFSMountOption checks one mount option against the known NFS and
+// fstab options. systemd's x-systemd.* options are passed through.
+func validateNFSMountOption(option string) error {
+	if strings.ContainsAny(option, " \t#") {
+		return fmt.Errorf("invalid mount option %q", option)
+	}
+	name, value, hasValue := strings.Cut(option, "=")
+	switch {
+	case strings.HasPrefix(name, "x-systemd."):
+		return nil
+	case nfsFlagOptions[name]:
+		if hasValue {
+			return fmt.Errorf("mount option %s does not take a value", name)
+		}
+	case nfsValueOptions[name]:
+		if value == "" {
+			return fmt.Errorf("mount option %s needs a value (%s=...)", name, name)
+		}
+		if optionKey(name) == "nfsvers" {
+			return validateNFSVersion(value)
+		}
+	default:
+		return fmt.Errorf("unknown NFS mount option %q", name)
+	}
+	return nil
+}
+
+// buildNFSMountOptions assembles the mount options: the base options with the
+// NFS version, overridden or extended by extra, a comma-separated list of
+// options. An empty version uses the version set in extra, or
+// defaultNFSVersion.
+func buildNFSMountOptions(version, extra string) (string, error) {
+	// Parse user options into a map for easy lookup, remembering their order
 	userOptions := make(map[string]string)
-	rawOptions := cfg.GetOrDefault(config.KeyNFSMountOptions, "")
-	for _, raw := range strings.Split(rawOptions, ",") {
+	var userOrder []string
+	for _, raw := range strings.Split(extra, ",") {
 		opt := strings.TrimSpace(raw)
 		if opt == "" {
 			continue
 		}
+		if err := validateNFSMountOption(opt); err != nil {
+			return "", err
+		}
 		key := optionKey(opt)
+		if _, exists := userOptions[key]; !exists {
+			userOrder = append(userOrder, key)
+		}
 		userOptions[key] = opt
 	}
 
+	if version != "" {
+		if err := validateNFSVersion(version); err != nil {
+			return "", err
+		}
+		if opt, exists := userOptions["nfsvers"]; exists {
+			if _, v, _ := strings.Cut(opt, "="); v != version {
+				return "", fmt.Errorf("%s sets %s but %s is %s", config.KeyNFSMountOptions, opt, config.KeyNFSVersion, version)
+			}
+		}
+	} else {
+		version = defaultNFSVersion
+	}
+
+	// Base options enforce safe boot behavior and network readiness
+	baseOptions := []string{"defaults", "nfsvers=" + version, "_netdev", "nofail"}
+
 	// Build final list: base options with user overrides, then append new user options
 	var result []string
 	seen := make(map[string]bool)
@@ -348,24 +459,48 @@ func getNFSMountOptions(cfg *config.Config) string {
 		key := optionKey(baseOpt)
 		if userOpt, exists := userOptions[key]; exists {
 			result = append(result, userOpt)
-			seen[key] = true
 		} else {
 			result = append(result, baseOpt)
-			seen[key] = true
 		}
+		seen[key] = true
 	}
 
 	// Second pass: append any new user options not in base
-	for key, userOpt := range userOptions {
+	for _, key := range userOrder {
 		if !seen[key] {
-			result = append(result, userOpt)
+			result = append(result, userOptions[key])
 		}
 	}
 
-	return strings.Join(result, ",")
+	return strings.Join(result, ","), nil
+}
+
+// getNFSMountOptions returns the NFS mount options for NFS_VERSION and
+// NFS_MOUNT_OPTIONS, or an error if either is invalid
+func getNFSMountOptions(cfg *config.Config) (string, error) {
+	options, err := buildNFSMountOptions(
+		strings.TrimSpace(cfg.GetOrDefault(config.KeyNFSVersion, "")),
+		cfg.GetOrDefault(config.KeyNFSMountOptions, ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid NFS mount configuration: %w", err)
+	}
+	return options, nil
+}
+
+// nfsVersion returns the NFS major version the mount will use
+func nfsVersion(options string) string {
+	for _, opt := range strings.Split(options, ",") {
+		if optionKey(opt) == "nfsvers" {
+			_, v, _ := strings.Cut(opt, "=")
+			major, _, _ := strings.Cut(v, ".")
+			return major
+		}
+	}
+	return "4"
 }
 
-// optionKey normalizes an option name for override checks (e.g., nfsvers=4.2 -> nfsvers)
+// optionKey normalizes an option name for override checks (e.g., nfsvers=4.2 -> nfsvers).
+// vers is an alias of nfsvers.
 func optionKey(option string) string {
 	if option == "" {
 		return ""
@@ -373,7 +508,11 @@ func optionKey(option string) string {
 
 	// Split once to keep the base key (everything before '=')
 	parts := strings.SplitN(option, "=", 2)
-	return strings.TrimSpace(parts[0])
+	key := strings.TrimSpace(parts[0])
+	if key == "vers" {
+		return "nfsvers"
+	}
+	return key
 }
 
 // createFstabEntry adds an NFS mount entry to /etc/fstab with validation
@@ -381,11 +520,14 @@ func createFstabEntry(cfg *config.Config, ui *ui.UI, host, export, mountPoint st
 	ui.Info("Adding NFS mount to /etc/fstab...")
 
 	// Build fstab entry with resilient options
-	// nfsvers=4.2 - Use NFSv4.2 for best performance (overridable via config)
+	// nfsvers=4.2 - Use NFSv4.2 for best performance (NFS_VERSION)
 	// _netdev - Mount only after network is available
 	// nofail - Don't block boot if mount fails
 	// defaults - Use default mount options
-	mountOptions := getNFSMountOptions(cfg)
+	mountOptions, err := getNFSMountOptions(cfg)
+	if err != nil {
+		return err
+	}
 	fstabEntry := fmt.Sprintf("%s:%s %s nfs %s 0 0", host, export, mountPoint, mountOptions)
 
 	// Read current fstab
@@ -425,7 +567,7 @@ func createFstabEntry(cfg *config.Config, ui *ui.UI, host, export, mountPoint st
 					return fmt.Errorf("failed to prompt: %w", err)
 				}
 				if !continueAnyway {
-					return fmt.Errorf("fstab entry creation cancelled")
+					return fmt.Errorf("fstab entry creation %w", ErrCancelled)
 				}
 
 				// Comment out the old entry and mark for replacement
@@ -464,8 +606,7 @@ func createFstabEntry(cfg *config.Config, ui *ui.UI, host, export, mountPoint st
 
 	// Validate fstab syntax with mount -a --fake (dry run)
 	ui.Info("Validating fstab syntax...")
-	cmd := exec.Command("sudo", "-n", "mount", "-a", "--fake")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := system.RunPrivileged("mount", "-a", "--fake"); err != nil {
 		ui.Error(fmt.Sprintf("fstab validation failed: %v", err))
 		ui.Error(fmt.Sprintf("Output: %s", string(output)))
 		ui.Warning("You may need to manually fix /etc/fstab")
@@ -475,8 +616,7 @@ func createFstabEntry(cfg *config.Config, ui *ui.UI, host, export, mountPoint st
 
 	// Attempt to mount
 	ui.Infof("Mounting %s...", mountPoint)
-	cmd = exec.Command("sudo", "-n", "mount", "-a")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := system.RunPrivileged("mount", "-a"); err != nil {
 		ui.Warning(fmt.Sprintf("Mount command reported issues: %v", err))
 		ui.Warning(fmt.Sprintf("Output: %s", string(output)))
 		ui.Info("This may be non-critical if other mounts failed. Checking target mount...")
@@ -484,7 +624,7 @@ func createFstabEntry(cfg *config.Config, ui *ui.UI, host, export, mountPoint st
 
 	// Verify the mount with findmnt
 	ui.Infof("Verifying mount at %s...", mountPoint)
-	cmd = exec.Command("findmnt", mountPoint)
+	cmd := exec.Command("findmnt", mountPoint)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		ui.Error(fmt.Sprintf("Mount verification failed: %v", err))
@@ -530,8 +670,7 @@ func migrateSystemdMountToFstab(_ *config.Config, ui *ui.UI, mountPoint string)
 	// Stop and disable automount unit if it exists
 	if automountExists {
 		ui.Infof("Stopping and disabling %s...", automountUnitName)
-		cmd := exec.Command("sudo", "-n", "systemctl", "disable", "--now", automountUnitName)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := system.RunPrivileged("systemctl", "disable", "--now", automountUnitName); err != nil {
 			ui.Warning(fmt.Sprintf("Failed to disable automount unit: %v\nOutput: %s", err, string(output)))
 		} else {
 			ui.Success("Automount unit disabled")
@@ -548,8 +687,7 @@ func migrateSystemdMountToFstab(_ *config.Config, ui *ui.UI, mountPoint string)
 	// Stop and disable mount unit if it exists
 	if mountExists {
 		ui.Infof("Stopping and disabling %s...", mountUnitName)
-		cmd := exec.Command("sudo", "-n", "systemctl", "disable", "--now", mountUnitName)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := system.RunPrivileged("systemctl", "disable", "--now", mountUnitName); err != nil {
 			ui.Warning(fmt.Sprintf("Failed to disable mount unit: %v\nOutput: %s", err, string(output)))
 		} else {
 			ui.Success("Mount unit disabled")
@@ -565,8 +703,7 @@ func migrateSystemdMountToFstab(_ *config.Config, ui *ui.UI, mountPoint string)
 
 	// Reload systemd daemon
 	ui.Info("Reloading systemd daemon...")
-	cmd := exec.Command("sudo", "-n", "systemctl", "daemon-reload")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := system.RunPrivileged("systemctl", "daemon-reload"); err != nil {
 		ui.Warning(fmt.Sprintf("Failed to reload systemd: %v\nOutput: %s", err, string(output)))
 	} else {
 		ui.Success("Systemd daemon reloaded")
@@ -576,15 +713,15 @@ func migrateSystemdMountToFstab(_ *config.Config, ui *ui.UI, mountPoint string)
 }
 
 // RunNFSSetup executes the NFS configuration step
-func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
+func RunNFSSetup(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Check if already completed (and migrate legacy markers)
-	completed, err := ensureCanonicalMarker(cfg, nfsCompletionMarker, "nfs-configured", "nfs-skipped")
+	completed, err := cfg.CanonicalizeMarker(nfsCompletionMarker, config.LegacyMarkerNames[nfsCompletionMarker]...)
 	if err != nil {
 		return fmt.Errorf("failed to check marker: %w", err)
 	}
 	if completed {
 		ui.Info("NFS already configured (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + nfsCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), nfsCompletionMarker))
 		return nil
 	}
 
@@ -601,7 +738,7 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 
 	if !useNFS {
 		ui.Info("Skipping NFS configuration")
-		ui.Info("To configure NFS later, remove marker: ~/.local/homelab-setup/" + nfsCompletionMarker)
+		ui.Info("To configure NFS later, remove marker: " + filepath.Join(cfg.MarkerDir(), nfsCompletionMarker))
 		if err := cfg.MarkComplete(nfsCompletionMarker); err != nil {
 			return fmt.Errorf("failed to create completion marker: %w", err)
 		}
@@ -623,7 +760,10 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 
 	// Validate NFS connection
 	ui.Step("Validating NFS Connection")
-	if err := validateNFSConnection(cfg, ui, host); err != nil {
+	stopTimer := timeOperation(ui, "NFS connection check")
+	err = validateNFSConnection(cfg, ui, host)
+	stopTimer()
+	if err != nil {
 		ui.Error(fmt.Sprintf("NFS validation failed: %v", err))
 
 		continueAnyway, err := ui.PromptYesNo("Continue with NFS setup despite validation errors?", false)
@@ -631,7 +771,7 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 			return fmt.Errorf("failed to prompt: %w", err)
 		}
 		if !continueAnyway {
-			return fmt.Errorf("NFS setup cancelled due to validation errors")
+			return fmt.Errorf("NFS setup %w due to validation errors", ErrCancelled)
 		}
 	}
 
@@ -641,6 +781,11 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 		return fmt.Errorf("export path verification failed: %w", err)
 	}
 
+	// Stop before persisting anything if the operation was interrupted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Create mount point
 	ui.Step("Creating Mount Point")
 	if err := createMountPoint(cfg, ui, mountPoint); err != nil {
@@ -695,7 +840,7 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 	for {
 		ui.Print("")
 		ui.Separator()
-		ui.Success("✓ NFS mount configured successfully")
+		ui.Successf("%s NFS mount configured successfully", ui.GlyphOK())
 		ui.Infof("Export: %s", export)
 		ui.Infof("Mount Point: %s", mountPoint)
 		ui.Print("")
@@ -779,7 +924,7 @@ func RunNFSSetup(cfg *config.Config, ui *ui.UI) error {
 	// Final summary
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ NFS configuration completed")
+	ui.Successf("%s NFS configuration completed", ui.GlyphOK())
 	ui.Infof("Server: %s", host)
 	ui.Infof("Total mounts configured: %d", mountCount)
 	ui.Print("")
@@ -11,7 +11,10 @@ import (
 // TestFstabReplacement tests the fstab entry replacement logic
 func TestFstabReplacement(t *testing.T) {
 	cfg := config.New(filepath.Join(t.TempDir(), "config"))
-	options := getNFSMountOptions(cfg)
+	options, err := getNFSMountOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name          string
@@ -1,12 +1,14 @@
 package steps
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
 )
 
 // TestMountPointToUnitBaseName tests the mount point to unit name conversion
@@ -104,7 +106,10 @@ func TestGetNFSMountOptions(t *testing.T) {
 				}
 			}
 
-			got := getNFSMountOptions(cfg)
+			got, err := getNFSMountOptions(cfg)
+			if err != nil {
+				t.Fatalf("getNFSMountOptions() error = %v", err)
+			}
 			if got != tt.expected {
 				t.Fatalf("getNFSMountOptions() = %q, want %q", got, tt.expected)
 			}
@@ -112,10 +117,68 @@ func TestGetNFSMountOptions(t *testing.T) {
 	}
 }
 
+// TestBuildNFSMountOptions tests option assembly from NFS_VERSION and
+// NFS_MOUNT_OPTIONS
+func TestBuildNFSMountOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		extra   string
+		want    string
+		wantErr string
+	}{
+		{name: "defaults", want: "defaults,nfsvers=4.2,_netdev,nofail"},
+		{name: "NFSv3", version: "3", extra: "hard,timeo=600", want: "defaults,nfsvers=3,_netdev,nofail,hard,timeo=600"},
+		{name: "vers alias replaces default version", extra: "vers=4.2,hard,noatime", want: "defaults,vers=4.2,_netdev,nofail,hard,noatime"},
+		{name: "matching version in both keys", version: "4.1", extra: "nfsvers=4.1", want: "defaults,nfsvers=4.1,_netdev,nofail"},
+		{name: "systemd options pass through", extra: "x-systemd.automount,x-systemd.idle-timeout=600", want: "defaults,nfsvers=4.2,_netdev,nofail,x-systemd.automount,x-systemd.idle-timeout=600"},
+		{name: "transport and SELinux context", version: "3", extra: "tcp,context=system_u:object_r:container_file_t:s0", want: "defaults,nfsvers=3,_netdev,nofail,tcp,context=system_u:object_r:container_file_t:s0"},
+		{name: "conflicting versions", version: "3", extra: "vers=4.2", wantErr: "NFS_VERSION is 3"},
+		{name: "unsupported version", version: "5", wantErr: "unsupported NFS version"},
+		{name: "unsupported version in options", extra: "nfsvers=2", wantErr: "unsupported NFS version"},
+		{name: "unknown option", extra: "hard,turbo", wantErr: `unknown NFS mount option "turbo"`},
+		{name: "flag with value", extra: "noatime=1", wantErr: "does not take a value"},
+		{name: "missing value", extra: "timeo=", wantErr: "needs a value"},
+		{name: "whitespace inside an option", extra: "sec=sys krb5", wantErr: "invalid mount option"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildNFSMountOptions(tt.version, tt.extra)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("buildNFSMountOptions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildNFSMountOptions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("buildNFSMountOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNFSVersion(t *testing.T) {
+	for options, want := range map[string]string{
+		"defaults,nfsvers=4.2,_netdev": "4",
+		"defaults,vers=3,_netdev":      "3",
+		"defaults,_netdev":             "4",
+	} {
+		if got := nfsVersion(options); got != want {
+			t.Errorf("nfsVersion(%q) = %q, want %q", options, got, want)
+		}
+	}
+}
+
 // TestFstabEntryFormat tests the format of fstab entries
 func TestFstabEntryFormat(t *testing.T) {
 	cfg := config.New(filepath.Join(t.TempDir(), "config"))
-	options := getNFSMountOptions(cfg)
+	options, err := getNFSMountOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name       string
@@ -154,7 +217,10 @@ func TestFstabEntryFormat(t *testing.T) {
 // TestFstabDuplicateDetection tests duplicate entry detection logic
 func TestFstabDuplicateDetection(t *testing.T) {
 	cfg := config.New(filepath.Join(t.TempDir(), "config"))
-	options := getNFSMountOptions(cfg)
+	options, err := getNFSMountOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name            string
@@ -236,3 +302,23 @@ func TestFstabDuplicateDetection(t *testing.T) {
 		})
 	}
 }
+
+func TestShowNFSExports(t *testing.T) {
+	var buf bytes.Buffer
+	exports := showNFSExports(ui.NewWithWriter(&buf), "Export list for nas:\n/volume1/media 192.168.1.0/24,*.lan\n")
+	if len(exports) != 1 || exports[0].Path != "/volume1/media" {
+		t.Errorf("showNFSExports() = %+v", exports)
+	}
+	if out := buf.String(); !strings.Contains(out, "Clients") || !strings.Contains(out, "192.168.1.0/24, *.lan") {
+		t.Errorf("expected an export table, got:\n%s", out)
+	}
+
+	buf.Reset()
+	raw := "rpc mount export: RPC: Authentication error\n"
+	if exports := showNFSExports(ui.NewWithWriter(&buf), raw); exports != nil {
+		t.Errorf("showNFSExports() = %+v for unparseable output", exports)
+	}
+	if out := buf.String(); !strings.Contains(out, "RPC: Authentication error") {
+		t.Errorf("expected the raw output as a fallback, got:\n%s", out)
+	}
+}
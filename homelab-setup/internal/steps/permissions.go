@@ -0,0 +1,109 @@
+package steps
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// maxPreviewEntries limits how many pending changes are listed in a dry run
+const maxPreviewEntries = 20
+
+// databaseAppdataDirs returns the appdata directories of the database
+// containers in stackAppdataDirs. Their data is owned by the database user
+// inside the container (e.g. postgres) with mode 0700, so they must never be
+// chowned to the homelab user.
+func databaseAppdataDirs(cfg *config.Config, appdataBase string) []string {
+	var dirs []string
+	for _, apps := range stackAppdataDirs {
+		for _, app := range apps {
+			if !isDatabaseApp(app) {
+				continue
+			}
+			path, _, err := appdataPath(cfg, appdataBase, app)
+			if err != nil {
+				path = filepath.Join(appdataBase, app)
+			}
+			dirs = append(dirs, path)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// isDatabaseApp reports whether an appdata directory name belongs to a
+// database container
+func isDatabaseApp(app string) bool {
+	return strings.HasSuffix(app, "-db")
+}
+
+// RunRepairAppdataPermissions fixes ownership and modes of the appdata tree so
+// containers running as the homelab user can write to it
+func RunRepairAppdataPermissions(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Repair Appdata Permissions")
+
+	homelabUser := cfg.GetOrDefault("HOMELAB_USER", "")
+	if homelabUser == "" {
+		return fmt.Errorf("homelab user not configured (run user configuration first)")
+	}
+
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "")
+	if appdataBase == "" {
+		appdataBase = cfg.GetOrDefault("APPDATA_PATH", "/var/lib/containers/appdata")
+	}
+
+	ui.Infof("Appdata directory: %s", appdataBase)
+	ui.Infof("Owner: %s", homelabUser)
+	skip := databaseAppdataDirs(cfg, appdataBase)
+	ui.Infof("Database directories are left unchanged: %s", strings.Join(skip, ", "))
+	ui.Print("")
+
+	recursive, err := ui.PromptYesNo("Apply to all files and subdirectories?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+
+	ui.Step("Scanning Permissions")
+	changes, err := system.PreviewAppdataPermissions(appdataBase, homelabUser, recursive, skip...)
+	if err != nil {
+		return fmt.Errorf("failed to scan appdata permissions: %w", err)
+	}
+
+	if len(changes) == 0 {
+		ui.Success("All appdata entries already have the correct owner and permissions")
+		return nil
+	}
+
+	ui.Infof("%d entries need changes:", len(changes))
+	for i, change := range changes {
+		if i >= maxPreviewEntries {
+			ui.Infof("  ... and %d more", len(changes)-maxPreviewEntries)
+			break
+		}
+		ui.Printf("  %s  owner %s -> %s  mode %o -> %o", change.Path, change.OldOwner, change.NewOwner, change.OldMode, change.NewMode)
+	}
+	ui.Print("")
+
+	apply, err := ui.PromptYesNo("Apply these changes? (No = dry run only)", false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !apply {
+		ui.Info("Dry run complete, no changes made")
+		return nil
+	}
+
+	ui.Step("Repairing Permissions")
+	changed, err := system.RepairAppdataPermissions(appdataBase, homelabUser, recursive, skip...)
+	if err != nil {
+		return fmt.Errorf("failed to repair permissions: %w", err)
+	}
+
+	ui.Successf("Repaired %d entries under %s", changed, appdataBase)
+	return nil
+}
@@ -0,0 +1,22 @@
+package steps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+func TestDatabaseAppdataDirs(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(appdataOverrideKey("immich-db"), "/fast/immich-db"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := databaseAppdataDirs(cfg, "/var/lib/containers/appdata")
+	want := []string{"/fast/immich-db", "/var/lib/containers/appdata/nextcloud-db"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("databaseAppdataDirs() = %v, want %v", got, want)
+	}
+}
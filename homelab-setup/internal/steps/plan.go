@@ -0,0 +1,258 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// PlanActionKind identifies what a planned action changes
+type PlanActionKind string
+
+const (
+	PlanCreateUser      PlanActionKind = "create-user"
+	PlanCreateDirectory PlanActionKind = "create-directory"
+	PlanInstallPackage  PlanActionKind = "install-package"
+	PlanSetConfig       PlanActionKind = "set-config"
+	PlanDeployService   PlanActionKind = "deploy-service"
+)
+
+// PlanAction is a single change the setup would make
+type PlanAction struct {
+	Step   string // Short name of the step the action belongs to
+	Kind   PlanActionKind
+	Target string // User, path, package, config key or service
+	Value  string // Directory owner or config value, depending on Kind
+}
+
+// String describes the action for review
+func (a PlanAction) String() string {
+	switch a.Kind {
+	case PlanCreateUser:
+		return fmt.Sprintf("create user %s", a.Target)
+	case PlanCreateDirectory:
+		return fmt.Sprintf("create directory %s (owner %s)", a.Target, a.Value)
+	case PlanInstallPackage:
+		return fmt.Sprintf("install package %s (reboot required)", a.Target)
+	case PlanSetConfig:
+		return fmt.Sprintf("set %s=%s", a.Target, a.Value)
+	case PlanDeployService:
+		return fmt.Sprintf("deploy %s stack (systemd unit, image pull, start)", a.Target)
+	}
+	return fmt.Sprintf("%s %s", a.Kind, a.Target)
+}
+
+// Plan is an inspectable list of changes computed without side effects.
+// Unresolved lists work that needs interactive input and must be done by
+// running the corresponding step.
+type Plan struct {
+	Actions    []PlanAction
+	Unresolved []string
+}
+
+// Empty reports whether the plan has nothing to apply
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Print writes the plan for review, grouped by step
+func (p *Plan) Print(ui *ui.UI) {
+	if p.Empty() {
+		ui.Success("Nothing to do: the system already matches the configuration")
+	} else {
+		currentStep := ""
+		for _, action := range p.Actions {
+			if action.Step != currentStep {
+				currentStep = action.Step
+				ui.Step(currentStep)
+			}
+			ui.Printf("  + %s", action)
+		}
+		ui.Print("")
+		ui.Infof("Plan: %d change(s)", len(p.Actions))
+	}
+
+	if len(p.Unresolved) > 0 {
+		ui.Print("")
+		ui.Warning("Not covered by the plan (needs interactive setup):")
+		for _, note := range p.Unresolved {
+			ui.Printf("  - %s", note)
+		}
+	}
+}
+
+func (p *Plan) add(step string, kind PlanActionKind, target, value string) {
+	p.Actions = append(p.Actions, PlanAction{Step: step, Kind: kind, Target: target, Value: value})
+}
+
+// ComputePlan works out what the setup steps would change for the current
+// configuration, reading system state only. Steps already marked complete
+// are skipped. Nothing is modified.
+func ComputePlan(cfg *config.Config) (*Plan, error) {
+	plan := &Plan{}
+
+	// User
+	homelabUser := cfg.GetOrDefault(config.KeyHomelabUser, "")
+	if homelabUser == "" {
+		plan.Unresolved = append(plan.Unresolved, "user: HOMELAB_USER is not configured")
+	} else if !cfg.IsComplete(userCompletionMarker) {
+		exists, err := system.UserExists(homelabUser)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check user %s: %w", homelabUser, err)
+		}
+		if !exists {
+			plan.add("user", PlanCreateUser, homelabUser, "")
+		}
+	}
+
+	// Directories
+	containersBase := getServiceBaseDir(cfg)
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata")
+	for _, base := range []string{containersBase, appdataBase} {
+		if err := common.ValidateBasePath(base); err != nil && !errors.Is(err, common.ErrPathNotAllowlisted) {
+			return nil, fmt.Errorf("refusing to plan changes under %s: %w", base, err)
+		}
+	}
+	if !cfg.IsComplete(directoryCompletionMarker) && homelabUser != "" {
+		dirs := []string{containersBase}
+		for _, svc := range containerServiceDirs {
+			dirs = append(dirs, filepath.Join(containersBase, svc.name))
+		}
+		dirs = append(dirs, appdataBase)
+		for _, name := range appdataDirs {
+			dir, _, err := appdataPath(cfg, appdataBase, name)
+			if err != nil {
+				return nil, err
+			}
+			dirs = append(dirs, dir)
+		}
+
+		for _, dir := range dirs {
+			exists, err := system.DirectoryExists(dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check directory %s: %w", dir, err)
+			}
+			if !exists {
+				plan.add("directory", PlanCreateDirectory, dir, homelabUser)
+			}
+		}
+
+		for _, kv := range [][2]string{
+			{"CONTAINERS_BASE", containersBase},
+			{"APPDATA_BASE", appdataBase},
+			{"APPDATA_PATH", appdataBase},
+		} {
+			if !cfg.Exists(kv[0]) {
+				plan.add("directory", PlanSetConfig, kv[0], kv[1])
+			}
+		}
+	}
+
+	// Packages
+	var packages []string
+	if cfg.GetOrDefault(config.KeyNFSServer, "") != "" {
+		packages = append(packages, "nfs-utils")
+	}
+	if cfg.GetOrDefault("WIREGUARD_ENABLED", "") == "true" {
+		packages = append(packages, "wireguard-tools")
+	}
+	for _, pkg := range packages {
+		installed, err := system.IsPackageInstalled(pkg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check package %s: %w", pkg, err)
+		}
+		if !installed {
+			plan.add("packages", PlanInstallPackage, pkg, "")
+		}
+	}
+	if cfg.GetOrDefault(config.KeyNFSServer, "") != "" && !cfg.IsComplete(nfsCompletionMarker) {
+		plan.Unresolved = append(plan.Unresolved, "nfs: fstab entry and mount are created by the NFS step")
+	}
+
+	// Services
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		plan.Unresolved = append(plan.Unresolved, "container: no services selected")
+		return plan, nil
+	}
+	if !cfg.IsComplete(deploymentCompletionMarker) {
+		for _, serviceName := range selectedServices {
+			serviceInfo := getServiceInfo(cfg, serviceName)
+			if _, ok := findComposeFile(serviceInfo.Directory); !ok {
+				plan.Unresolved = append(plan.Unresolved, fmt.Sprintf("container: %s has no compose file yet", serviceName))
+				continue
+			}
+			active, err := system.IsServiceActive(serviceInfo.UnitName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", serviceInfo.UnitName, err)
+			}
+			if !active {
+				plan.add("deployment", PlanDeployService, serviceName, "")
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply executes exactly the actions in plan, stopping at the first failure.
+// Actions run in plan order except package installs, which are layered
+// together in one rpm-ostree transaction after every other action; they only
+// take effect after a reboot, so nothing else in the plan depends on them.
+// Actions are not recomputed, so review the plan before applying.
+func Apply(ctx context.Context, cfg *config.Config, ui *ui.UI, plan *Plan) error {
+	var packages []string
+	for i, action := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ui.Infof("[%d/%d] %s", i+1, len(plan.Actions), action)
+
+		var err error
+		switch action.Kind {
+		case PlanCreateUser:
+			// Same account types as the user step: a service account for
+			// Docker, a regular user for rootless Podman
+			if cfg.GetOrDefault(config.KeyContainerRuntime, "docker") == "docker" {
+				uid, _ := strconv.Atoi(cfg.GetOrDefault(config.KeyHomelabUID, "0"))
+				gid, _ := strconv.Atoi(cfg.GetOrDefault(config.KeyHomelabGID, "0"))
+				err = system.CreateSystemUserWithIDs(action.Target, uid, gid, "/sbin/nologin")
+			} else {
+				err = system.CreateUser(action.Target, true)
+			}
+		case PlanCreateDirectory:
+			err = system.EnsureDirectory(action.Target, action.Value, 0755)
+		case PlanSetConfig:
+			err = cfg.Set(action.Target, action.Value)
+		case PlanInstallPackage:
+			// Packages are layered in a single transaction below
+			packages = append(packages, action.Target)
+		case PlanDeployService:
+			err = deployService(ctx, cfg, ui, action.Target)
+		default:
+			err = fmt.Errorf("unknown plan action: %s", action.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s: %w", action, err)
+		}
+	}
+
+	if len(packages) > 0 {
+		ui.Infof("Layering %d package(s): %s", len(packages), strings.Join(packages, " "))
+		if err := system.InstallPackages(packages...); err != nil {
+			return err
+		}
+	}
+
+	ui.Successf("Applied %d change(s)", len(plan.Actions))
+	return nil
+}
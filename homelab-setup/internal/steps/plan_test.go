@@ -0,0 +1,63 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestComputePlanReportsUnresolvedWithoutConfig(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+
+	plan, err := ComputePlan(cfg)
+	if err != nil {
+		t.Fatalf("ComputePlan() error = %v", err)
+	}
+	if !plan.Empty() {
+		t.Errorf("expected no actions without configuration, got %+v", plan.Actions)
+	}
+	if len(plan.Unresolved) == 0 {
+		t.Error("expected unresolved notes for missing user and services")
+	}
+}
+
+func TestApplyExecutesPlannedConfigActions(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	var buf bytes.Buffer
+	out := ui.NewWithWriter(&buf)
+
+	plan := &Plan{}
+	plan.add("directory", PlanSetConfig, "APPDATA_BASE", "/var/lib/containers/appdata")
+	plan.add("directory", PlanSetConfig, "CONTAINERS_BASE", "/srv/containers")
+
+	if err := Apply(context.Background(), cfg, out, plan); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := cfg.GetOrDefault("CONTAINERS_BASE", ""); got != "/srv/containers" {
+		t.Errorf("CONTAINERS_BASE = %q, want /srv/containers", got)
+	}
+	if !strings.Contains(buf.String(), "[2/2] set CONTAINERS_BASE=/srv/containers") {
+		t.Errorf("expected progress output for each action, got:\n%s", buf.String())
+	}
+}
+
+func TestApplyStopsWhenCancelled(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	plan := &Plan{}
+	plan.add("directory", PlanSetConfig, "APPDATA_BASE", "/x")
+
+	if err := Apply(ctx, cfg, ui.NewWithWriter(&bytes.Buffer{}), plan); err == nil {
+		t.Fatal("expected error from cancelled context")
+	}
+	if cfg.Exists("APPDATA_BASE") {
+		t.Error("no action should run after cancellation")
+	}
+}
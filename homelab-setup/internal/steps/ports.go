@@ -0,0 +1,378 @@
+package steps
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// publishedPort is a host port published by a compose service
+type publishedPort struct {
+	Stack     string // Selected service (stack) the compose file belongs to
+	Container string // Compose service name
+	HostIP    string // Empty means all addresses
+	HostPort  int
+	Protocol  string
+}
+
+// portConflict lists every published port that binds the same host port
+type portConflict struct {
+	HostPort int
+	Protocol string
+	Users    []publishedPort
+}
+
+// envVarPattern matches ${VAR}, ${VAR:-default} and ${VAR-default}
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::?-([^}]*))?\}`)
+
+// expandComposeVars substitutes compose-style variables from env, falling back
+// to inline defaults
+func expandComposeVars(value string, env map[string]string) string {
+	return envVarPattern.ReplaceAllStringFunc(value, func(match string) string {
+		parts := envVarPattern.FindStringSubmatch(match)
+		if v, ok := env[parts[1]]; ok && v != "" {
+			return v
+		}
+		return parts[2]
+	})
+}
+
+// readEnvFile parses a compose .env file into a map. Missing files yield an empty map.
+func readEnvFile(path string) map[string]string {
+	env := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return env
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return env
+}
+
+// indentOf returns the number of leading spaces in line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// unquote strips surrounding YAML quotes and trailing comments
+func unquote(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
+
+// parsePortRange parses a single port ("8080") or an inclusive range
+// ("8000-8002")
+func parsePortRange(part string) (first, last int, err error) {
+	start, end := part, part
+	if a, b, ok := strings.Cut(part, "-"); ok {
+		start, end = a, b
+	}
+	first, err = strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid host port %q", part)
+	}
+	last, err = strconv.Atoi(end)
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid host port range %q", part)
+	}
+	return first, last, nil
+}
+
+// parsePortSpec parses a short-syntax port mapping such as "8080:80",
+// "127.0.0.1:8080:80/tcp" or "8000-8002:8000-8002". Container-only ports
+// (no host part) are not published to a fixed host port and return nil.
+func parsePortSpec(spec string) ([]publishedPort, error) {
+	protocol := "tcp"
+	if base, proto, ok := strings.Cut(spec, "/"); ok {
+		spec, protocol = base, proto
+	}
+
+	var hostIP, hostPart string
+	// IPv6 host addresses are written in brackets: "[::1]:8080:80"
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid port mapping: %s", spec)
+		}
+		hostIP = spec[1:end]
+		spec = strings.TrimPrefix(spec[end+1:], ":")
+	}
+
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		return nil, nil
+	case 2:
+		hostPart = parts[0]
+	case 3:
+		hostIP, hostPart = parts[0], parts[1]
+	default:
+		return nil, fmt.Errorf("invalid port mapping: %s", spec)
+	}
+	if hostPart == "" {
+		return nil, nil
+	}
+
+	first, last, err := parsePortRange(hostPart)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, spec)
+	}
+
+	var ports []publishedPort
+	for p := first; p <= last; p++ {
+		ports = append(ports, publishedPort{HostIP: hostIP, HostPort: p, Protocol: protocol})
+	}
+	return ports, nil
+}
+
+// parseComposePorts does a light, line-based parse of a compose file and
+// returns the host ports published under each service's ports: section.
+// Both short ("8080:80") and long (published:/target:) syntax are supported.
+func parseComposePorts(content string, env map[string]string) ([]publishedPort, error) {
+	var (
+		ports          []publishedPort
+		inServices     bool
+		serviceIndent  = -1
+		currentService string
+		portsIndent    = -1
+		longEntry      *publishedPort
+	)
+
+	flushLong := func() {
+		if longEntry != nil && longEntry.HostPort != 0 {
+			ports = append(ports, *longEntry)
+		}
+		longEntry = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := indentOf(raw)
+
+		// Top-level keys
+		if indent == 0 {
+			flushLong()
+			inServices = trimmed == "services:"
+			currentService, portsIndent = "", -1
+			continue
+		}
+		if !inServices {
+			continue
+		}
+
+		// Service names are the first nesting level below services:
+		if serviceIndent < 0 {
+			serviceIndent = indent
+		}
+		if indent == serviceIndent && strings.HasSuffix(trimmed, ":") {
+			flushLong()
+			currentService = strings.TrimSuffix(trimmed, ":")
+			portsIndent = -1
+			continue
+		}
+		if currentService == "" {
+			continue
+		}
+
+		if trimmed == "ports:" {
+			flushLong()
+			portsIndent = indent
+			continue
+		}
+		if portsIndent < 0 {
+			continue
+		}
+		if indent <= portsIndent && !strings.HasPrefix(trimmed, "-") {
+			// Left the ports: block
+			flushLong()
+			portsIndent = -1
+			continue
+		}
+
+		item := trimmed
+		isNewItem := strings.HasPrefix(item, "-")
+		if isNewItem {
+			flushLong()
+			item = strings.TrimSpace(strings.TrimPrefix(item, "-"))
+		}
+
+		key, value, isMapping := strings.Cut(item, ":")
+		isLongKey := isMapping && (key == "published" || key == "target" || key == "host_ip" || key == "protocol" || key == "mode")
+
+		if isNewItem && !isLongKey {
+			spec := expandComposeVars(unquote(item), env)
+			parsed, err := parsePortSpec(spec)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", currentService, err)
+			}
+			for _, p := range parsed {
+				p.Container = currentService
+				ports = append(ports, p)
+			}
+			continue
+		}
+
+		if isLongKey {
+			if longEntry == nil {
+				longEntry = &publishedPort{Container: currentService, Protocol: "tcp"}
+			}
+			value = expandComposeVars(unquote(value), env)
+			switch key {
+			case "published":
+				port, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("service %s: invalid published port %q", currentService, value)
+				}
+				longEntry.HostPort = port
+			case "host_ip":
+				longEntry.HostIP = value
+			case "protocol":
+				longEntry.Protocol = value
+			}
+		}
+	}
+	flushLong()
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+// bindsAllAddresses reports whether a host IP binds every address
+func bindsAllAddresses(hostIP string) bool {
+	return hostIP == "" || hostIP == "0.0.0.0" || hostIP == "::"
+}
+
+// findPortConflicts groups published ports by host port and protocol and
+// returns those bound more than once on overlapping addresses
+func findPortConflicts(ports []publishedPort) []portConflict {
+	type key struct {
+		port     int
+		protocol string
+	}
+	groups := make(map[key][]publishedPort)
+	for _, p := range ports {
+		k := key{p.HostPort, strings.ToLower(p.Protocol)}
+		groups[k] = append(groups[k], p)
+	}
+
+	var conflicts []portConflict
+	for k, users := range groups {
+		if len(users) < 2 {
+			continue
+		}
+
+		overlapping := false
+		for i := 0; i < len(users) && !overlapping; i++ {
+			for j := i + 1; j < len(users); j++ {
+				a, b := users[i].HostIP, users[j].HostIP
+				if bindsAllAddresses(a) || bindsAllAddresses(b) || a == b {
+					overlapping = true
+					break
+				}
+			}
+		}
+		if overlapping {
+			conflicts = append(conflicts, portConflict{HostPort: k.port, Protocol: k.protocol, Users: users})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].HostPort != conflicts[j].HostPort {
+			return conflicts[i].HostPort < conflicts[j].HostPort
+		}
+		return conflicts[i].Protocol < conflicts[j].Protocol
+	})
+	return conflicts
+}
+
+// collectPublishedPorts reads the compose file of every selected service
+func collectPublishedPorts(cfg *config.Config, selectedServices []string) ([]publishedPort, error) {
+	var all []publishedPort
+	for _, serviceName := range selectedServices {
+		serviceInfo := getServiceInfo(cfg, serviceName)
+		composeFile, ok := findComposeFile(serviceInfo.Directory)
+		if !ok {
+			continue
+		}
+
+		content, err := os.ReadFile(composeFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", composeFile, err)
+		}
+
+		env := readEnvFile(filepath.Join(serviceInfo.Directory, ".env"))
+		ports, err := parseComposePorts(string(content), env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ports in %s: %w", composeFile, err)
+		}
+		for i := range ports {
+			ports[i].Stack = serviceName
+		}
+		all = append(all, ports...)
+	}
+	return all, nil
+}
+
+// checkPortConflicts reports host ports published by more than one service
+// across the selected stacks
+func checkPortConflicts(cfg *config.Config, ui *ui.UI) error {
+	ui.Info("Checking for host port conflicts...")
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	ports, err := collectPublishedPorts(cfg, selectedServices)
+	if err != nil {
+		return err
+	}
+
+	conflicts := findPortConflicts(ports)
+	if len(conflicts) == 0 {
+		ui.Successf("No host port conflicts (%d published ports)", len(ports))
+		return nil
+	}
+
+	ui.Error("Host port conflicts found:")
+	for _, conflict := range conflicts {
+		ui.Errorf("  Port %d/%s is published by:", conflict.HostPort, conflict.Protocol)
+		for _, user := range conflict.Users {
+			address := "all addresses"
+			if !bindsAllAddresses(user.HostIP) {
+				address = user.HostIP
+			}
+			ui.Infof("    - %s (%s stack, %s)", user.Container, user.Stack, address)
+		}
+	}
+	ui.Info("Change the host side of one mapping in the compose file or .env before deploying")
+
+	return fmt.Errorf("%d host port conflict(s) between selected services", len(conflicts))
+}
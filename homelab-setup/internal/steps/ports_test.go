@@ -0,0 +1,93 @@
+package steps
+
+import "testing"
+
+func TestParseComposePorts(t *testing.T) {
+	content := `name: media
+services:
+  plex:
+    image: plexinc/pms-docker
+    ports:
+      - "32400:32400"
+      - 1900:1900/udp
+  jellyfin:
+    image: jellyfin/jellyfin
+    ports:
+      - "127.0.0.1:${JELLYFIN_PORT:-8096}:8096"
+      - "7359"
+    volumes:
+      - /srv:/srv
+  tautulli:
+    ports:
+      - target: 8181
+        published: 8181
+        protocol: tcp
+networks:
+  default: {}
+`
+	ports, err := parseComposePorts(content, map[string]string{})
+	if err != nil {
+		t.Fatalf("parseComposePorts() error = %v", err)
+	}
+
+	want := []publishedPort{
+		{Container: "plex", HostPort: 32400, Protocol: "tcp"},
+		{Container: "plex", HostPort: 1900, Protocol: "udp"},
+		{Container: "jellyfin", HostIP: "127.0.0.1", HostPort: 8096, Protocol: "tcp"},
+		{Container: "tautulli", HostPort: 8181, Protocol: "tcp"},
+	}
+	if len(ports) != len(want) {
+		t.Fatalf("got %d ports (%+v), want %d", len(ports), ports, len(want))
+	}
+	for i := range want {
+		if ports[i] != want[i] {
+			t.Errorf("port %d = %+v, want %+v", i, ports[i], want[i])
+		}
+	}
+}
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec      string
+		wantCount int
+		wantErr   bool
+	}{
+		{"8080:80", 1, false},
+		{"8000-8002:8000-8002", 3, false},
+		{"[::1]:8080:80", 1, false},
+		{"80", 0, false},
+		{"abc:80", 0, true},
+		{"9000-8000:80", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ports, err := parsePortSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePortSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if len(ports) != tt.wantCount {
+				t.Errorf("parsePortSpec(%q) returned %d ports, want %d", tt.spec, len(ports), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestFindPortConflicts(t *testing.T) {
+	ports := []publishedPort{
+		{Stack: "web", Container: "homepage", HostPort: 3000, Protocol: "tcp"},
+		{Stack: "cloud", Container: "grafana", HostPort: 3000, Protocol: "tcp"},
+		{Stack: "media", Container: "plex", HostPort: 1900, Protocol: "udp"},
+		{Stack: "web", Container: "ssdp", HostPort: 1900, Protocol: "tcp"},
+		{Stack: "web", Container: "a", HostIP: "127.0.0.1", HostPort: 9000, Protocol: "tcp"},
+		{Stack: "web", Container: "b", HostIP: "192.168.1.10", HostPort: 9000, Protocol: "tcp"},
+	}
+
+	conflicts := findPortConflicts(ports)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d: %+v", len(conflicts), conflicts)
+	}
+	if conflicts[0].HostPort != 3000 || len(conflicts[0].Users) != 2 {
+		t.Errorf("unexpected conflict: %+v", conflicts[0])
+	}
+}
@@ -0,0 +1,167 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// portScanTimeout bounds each dial of the port scan
+const portScanTimeout = time.Duration(matrixTimeoutSeconds) * time.Second
+
+// largePortScan is the number of ports above which a scan needs confirmation
+const largePortScan = 1024
+
+// labeledPort is a TCP port with the service expected to listen on it
+type labeledPort struct {
+	Port  int
+	Label string
+}
+
+// knownServicePorts returns the ports of common host services and every
+// stack web UI, sorted by port
+func knownServicePorts(cfg *config.Config) []labeledPort {
+	ports := []labeledPort{
+		{22, "SSH"},
+		{53, "DNS"},
+		{80, "HTTP"},
+		{111, "rpcbind"},
+		{443, "HTTPS"},
+		{2049, "NFS"},
+	}
+	for stack := range stackPorts {
+		for app, port := range resolveStackPorts(cfg, stack) {
+			if n, err := strconv.Atoi(port); err == nil {
+				ports = append(ports, labeledPort{n, app})
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// parsePortList parses a comma-separated list of ports and ranges such as
+// "22,80,8000-8100" into sorted, de-duplicated port numbers
+func parsePortList(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, err := parsePortRange(part)
+		if err != nil {
+			return nil, err
+		}
+		if first < 1 || last > 65535 {
+			return nil, fmt.Errorf("port out of range (1-65535): %s", part)
+		}
+		for p := first; p <= last; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports given")
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
+// portScanConcurrency returns PORT_SCAN_CONCURRENCY, falling back to 64
+func portScanConcurrency(cfg *config.Config) int {
+	n, err := strconv.Atoi(cfg.GetOrDefault(config.KeyPortScanConcurrency, "64"))
+	if err != nil || n < 1 {
+		return 64
+	}
+	return n
+}
+
+// RunPortScan scans TCP ports on a host. With no port list it checks the
+// known service ports and labels each as open or closed; with a list or
+// range it reports only the open ports. Read-only.
+func RunPortScan(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Port Scan")
+
+	host, err := ui.PromptInput("Host to scan", "localhost")
+	if err != nil {
+		return err
+	}
+	spec, err := ui.PromptInput("Ports or ranges, e.g. 22,8000-8100 (blank for known services)", "")
+	if err != nil {
+		return err
+	}
+
+	known := knownServicePorts(cfg)
+	var ports []int
+	if strings.TrimSpace(spec) == "" {
+		for _, kp := range known {
+			ports = append(ports, kp.Port)
+		}
+	} else {
+		ports, err = parsePortList(spec)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(ports) > largePortScan {
+		ui.Warningf("This will probe %d ports on %s", len(ports), host)
+		proceed, err := ui.PromptYesNo("Scan this many ports?", false)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			ui.Info("Port scan cancelled")
+			return nil
+		}
+	}
+
+	concurrency := portScanConcurrency(cfg)
+	spinner := ui.Spinner(fmt.Sprintf("Scanning %d port(s) on %s (%d at a time)...", len(ports), host, concurrency))
+	spinner.Start()
+	start := time.Now()
+	open := system.ScanPorts(context.Background(), host, ports, concurrency, portScanTimeout)
+	spinner.Success(fmt.Sprintf("Scanned %d port(s) in %s", len(ports), time.Since(start).Round(time.Millisecond)))
+	ui.Print("")
+
+	isOpen := make(map[int]bool, len(open))
+	for _, port := range open {
+		isOpen[port] = true
+	}
+	labels := make(map[int]string, len(known))
+	for _, kp := range known {
+		labels[kp.Port] = kp.Label
+	}
+
+	var rows [][]string
+	if strings.TrimSpace(spec) == "" {
+		for _, kp := range known {
+			state := "closed"
+			if isOpen[kp.Port] {
+				state = "open"
+			}
+			rows = append(rows, []string{strconv.Itoa(kp.Port), kp.Label, state})
+		}
+	} else {
+		if len(open) == 0 {
+			ui.Infof("No open ports found on %s", host)
+			return nil
+		}
+		for _, port := range open {
+			rows = append(rows, []string{strconv.Itoa(port), labels[port], "open"})
+		}
+	}
+	ui.Table([]string{"Port", "Service", "State"}, rows)
+	return nil
+}
@@ -0,0 +1,70 @@
+package steps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+func TestParsePortList(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"22", []int{22}, false},
+		{"443, 22,8000-8002", []int{22, 443, 8000, 8001, 8002}, false},
+		{"80,80,79-81", []int{79, 80, 81}, false},
+		{"", nil, true},
+		{"0-10", nil, true},
+		{"65530-65536", nil, true},
+		{"8100-8000", nil, true},
+		{"ssh", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parsePortList(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePortList(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePortList(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnownServicePortsSorted(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set("HOMEPAGE_PORT", "3030"); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := knownServicePorts(cfg)
+	labels := make(map[int]string)
+	for i, kp := range ports {
+		if i > 0 && ports[i-1].Port > kp.Port {
+			t.Fatalf("knownServicePorts() not sorted at %d: %v", i, ports)
+		}
+		labels[kp.Port] = kp.Label
+	}
+	if labels[22] != "SSH" || labels[32400] != "Plex" || labels[3030] != "Homepage" {
+		t.Errorf("knownServicePorts() missing expected labels: %v", ports)
+	}
+}
+
+func TestPortScanConcurrency(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := portScanConcurrency(cfg); got != 64 {
+		t.Errorf("portScanConcurrency() default = %d, want 64", got)
+	}
+	if err := cfg.Set(config.KeyPortScanConcurrency, "0"); err != nil {
+		t.Fatal(err)
+	}
+	if got := portScanConcurrency(cfg); got != 64 {
+		t.Errorf("portScanConcurrency() with invalid value = %d, want 64", got)
+	}
+}
@@ -1,7 +1,15 @@
 package steps
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
@@ -61,9 +69,9 @@ func checkRequiredPackages(ui *ui.UI) error {
 		missingPackages := []string{}
 		for _, pkg := range corePackages {
 			if results[pkg] {
-				ui.Successf("  ✓ %s is installed", pkg)
+				ui.Successf("  %s %s is installed", ui.GlyphOK(), pkg)
 			} else {
-				ui.Errorf("  ✗ %s is NOT installed", pkg)
+				ui.Errorf("  %s %s is NOT installed", ui.GlyphFail(), pkg)
 				missingPackages = append(missingPackages, pkg)
 			}
 		}
@@ -90,7 +98,7 @@ func checkRequiredPackages(ui *ui.UI) error {
 			missingOptional := []string{}
 			for _, pkg := range optionalPackages {
 				if results[pkg] {
-					ui.Successf("  ✓ %s is installed", pkg)
+					ui.Successf("  %s %s is installed", ui.GlyphOK(), pkg)
 				} else {
 					ui.Infof("  - %s is not installed (optional)", pkg)
 					missingOptional = append(missingOptional, pkg)
@@ -110,33 +118,165 @@ func checkRequiredPackages(ui *ui.UI) error {
 	return nil
 }
 
-// checkContainerRuntime verifies Docker is available and configured
-func checkContainerRuntime(cfg *config.Config, ui *ui.UI) error {
-	ui.Info("Checking container runtime...")
+// dockerServiceUnit is the systemd unit of the Docker daemon
+const dockerServiceUnit = "docker.service"
+
+// preflightServices manages docker.service for the runtime check,
+// overridable in tests
+var preflightServices = system.NewServiceManager()
+
+// validateSudo caches sudo credentials before preflight changes system
+// state, overridable in tests
+var validateSudo = func() error {
+	return system.NewSudoChecker().ValidateAccess()
+}
+
+// detectRootlessPodman finds a rootless Podman socket, overridable in tests
+var detectRootlessPodman = system.DetectRootlessPodman
+
+// privilegedPorts returns the published ports below start, which rootless
+// containers cannot bind
+func privilegedPorts(ports []publishedPort, start int) []publishedPort {
+	var low []publishedPort
+	for _, port := range ports {
+		if port.HostPort < start {
+			low = append(low, port)
+		}
+	}
+	return low
+}
+
+// warnRootlessPodman explains what a rootless Podman host means for this
+// setup. Stacks are still deployed as Docker system units, so the user's
+// Podman containers and --user units are left alone.
+func warnRootlessPodman(cfg *config.Config, ui *ui.UI, socket string) {
+	ui.Warningf("  Rootless Podman detected (%s) and no root container daemon", socket)
+	ui.Info("  Stacks are deployed with Docker as system units (systemctl, not systemctl --user);")
+	ui.Info("  containers run by rootless Podman are not managed by this setup")
+	ui.Info("  Rootless containers map IDs through /etc/subuid, so files they create in appdata")
+	ui.Info("  are owned by subordinate IDs rather than the configured PUID/PGID")
+
+	selected, err := getSelectedServices(cfg)
+	if err != nil {
+		return
+	}
+	ports, err := collectPublishedPorts(cfg, selected)
+	if err != nil {
+		return
+	}
+	start := system.UnprivilegedPortStart()
+	low := privilegedPorts(ports, start)
+	if len(low) == 0 {
+		return
+	}
+	ui.Warningf("  %d published port(s) are below %d and cannot be bound by rootless containers:", len(low), start)
+	lowest := start
+	for _, port := range low {
+		ui.Infof("    %d/%s (%s/%s)", port.HostPort, port.Protocol, port.Stack, port.Container)
+		lowest = min(lowest, port.HostPort)
+	}
+	ui.Info("  Run the stacks with Docker, or lower the limit with:")
+	ui.Infof("    sudo sysctl net.ipv4.ip_unprivileged_port_start=%d", lowest)
+}
+
+// ensureDockerService makes sure docker.service is running. An installed but
+// stopped service is started and enabled after confirmation; only a missing
+// installation or a service that will not start is an error. When
+// CONTAINER_RUNTIME_MODE is rootless the host runs rootless Podman, so
+// starting the rootful daemon is left to the user instead of offered.
+func ensureDockerService(cfg *config.Config, ui *ui.UI) error {
+	installed, err := preflightServices.Exists(dockerServiceUnit)
+	if err != nil {
+		return fmt.Errorf("failed to check for %s: %w", dockerServiceUnit, err)
+	}
+	if !installed {
+		ui.Errorf("  %s Docker is not installed (no docker.service unit)", ui.GlyphFail())
+		ui.Info("Install Docker, then reboot:")
+		ui.Info("  sudo rpm-ostree install moby-engine")
+		ui.Info("  sudo systemctl reboot")
+		return fmt.Errorf("docker is not installed")
+	}
 
-	// Check if Docker service is active
-	if err := system.CheckDockerService(); err != nil {
-		ui.Error("  ✗ docker.service is not active")
+	active, err := preflightServices.IsActive(dockerServiceUnit)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", dockerServiceUnit, err)
+	}
+	if active {
+		return nil
+	}
+
+	ui.Warning("  docker.service is installed but not running")
+	if cfg.GetOrDefault(config.KeyContainerRuntimeMode, "rootful") == "rootless" {
+		ui.Info("This host uses rootless Podman. The stacks need the rootful Docker daemon;")
+		ui.Info("if you want them managed here, start it yourself and run preflight again:")
+		ui.Info("  sudo systemctl enable --now docker.service")
+		return fmt.Errorf("docker.service is not active")
+	}
+	start, err := ui.PromptYesNo("Start and enable docker.service now?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !start {
 		ui.Info("Docker must be running. Start it with:")
 		ui.Info("  sudo systemctl start docker.service")
 		ui.Info("  sudo systemctl enable docker.service")
 		return fmt.Errorf("docker.service is not active")
 	}
-	ui.Success("  ✓ Docker service is available")
+
+	if err := validateSudo(); err != nil {
+		return fmt.Errorf("cannot start %s: %w", dockerServiceUnit, err)
+	}
+	if err := preflightServices.Start(dockerServiceUnit); err != nil {
+		ui.Info("Check why it failed with: journalctl -u docker.service")
+		return err
+	}
+	if err := preflightServices.Enable(dockerServiceUnit); err != nil {
+		ui.Warningf("docker.service started but could not be enabled at boot: %v", err)
+	}
+
+	active, err = preflightServices.IsActive(dockerServiceUnit)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", dockerServiceUnit, err)
+	}
+	if !active {
+		ui.Info("Check why it stopped with: journalctl -u docker.service")
+		return fmt.Errorf("docker.service did not stay active after starting")
+	}
+	ui.Successf("  %s Started and enabled docker.service", ui.GlyphOK())
+	return nil
+}
+
+// checkContainerRuntime verifies Docker is available and configured
+func checkContainerRuntime(cfg *config.Config, ui *ui.UI) error {
+	ui.Info("Checking container runtime...")
+
+	mode := "rootful"
+	if socket, rootless := detectRootlessPodman(); rootless {
+		mode = "rootless"
+		warnRootlessPodman(cfg, ui, socket)
+	}
+	if err := cfg.Set(config.KeyContainerRuntimeMode, mode); err != nil {
+		ui.Warning("Failed to save container runtime mode to config")
+	}
+
+	if err := ensureDockerService(cfg, ui); err != nil {
+		return err
+	}
+	ui.Successf("  %s Docker service is available", ui.GlyphOK())
 
 	// Check for Docker Compose (prefer V2 plugin, fallback to V1)
 	if err := system.CheckDockerComposeV2(); err == nil {
-		ui.Success("  ✓ Docker Compose V2 (docker compose) is available")
+		ui.Successf("  %s Docker Compose V2 (docker compose) is available", ui.GlyphOK())
 		if err := cfg.Set(config.KeyComposeCommand, "docker compose"); err != nil {
 			ui.Warning("Failed to save compose command to config")
 		}
 	} else if err := system.CheckDockerComposeV1(); err == nil {
-		ui.Success("  ✓ Docker Compose V1 (docker-compose) is available")
+		ui.Successf("  %s Docker Compose V1 (docker-compose) is available", ui.GlyphOK())
 		if err := cfg.Set(config.KeyComposeCommand, "docker-compose"); err != nil {
 			ui.Warning("Failed to save compose command to config")
 		}
 	} else {
-		ui.Error("  ✗ Docker Compose is not available")
+		ui.Errorf("  %s Docker Compose is not available", ui.GlyphFail())
 		ui.Info("Install Docker Compose V2 (preferred):")
 		ui.Info("  Follow: https://docs.docker.com/compose/install/")
 		ui.Info("Or install V1 standalone:")
@@ -173,6 +313,11 @@ func checkSudoAccess(ui *ui.UI) error {
 		ui.Info("  sudo chmod 440 /etc/sudoers.d/$USER")
 		ui.Print("")
 
+		// A password prompt would hang or fail later in an unattended run
+		if ui.IsNonInteractive() {
+			return fmt.Errorf("sudo requires a password, which cannot be entered in non-interactive mode")
+		}
+
 		// Try to authenticate once
 		ui.Info("Validating sudo access (you may be prompted for password)...")
 		if err := sudoChecker.ValidateAccess(); err != nil {
@@ -187,18 +332,223 @@ func checkSudoAccess(ui *ui.UI) error {
 	return nil
 }
 
+// currentIdentity returns the effective UID and user name, overridable in
+// tests
+var currentIdentity = func() (int, string, error) {
+	current, err := system.GetCurrentUser()
+	if err != nil {
+		return 0, "", err
+	}
+	return os.Geteuid(), current.Username, nil
+}
+
+// checkRunningUser warns when setup runs as root, which leaves appdata owned
+// by root and unwritable by containers running as the homelab user's PUID,
+// or as a different user than HOMELAB_USER
+func checkRunningUser(cfg *config.Config, ui *ui.UI) error {
+	euid, username, err := currentIdentity()
+	if err != nil {
+		return fmt.Errorf("could not determine the current user: %w", err)
+	}
+	homelabUser := cfg.GetOrDefault(config.KeyHomelabUser, "")
+
+	if euid == 0 {
+		ui.Info("Run homelab-setup as the homelab user; it uses sudo only where root is needed")
+		if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != "root" {
+			ui.Infof("  Re-run without sudo as %s", sudoUser)
+		} else if homelabUser != "" {
+			ui.Infof("  Log in as %s and re-run", homelabUser)
+		}
+		return fmt.Errorf("running as root: files created now may be owned by root and unwritable by containers")
+	}
+
+	if homelabUser != "" && homelabUser != username {
+		ui.Infof("Log in as %s and re-run, or change HOMELAB_USER in User Setup", homelabUser)
+		return fmt.Errorf("running as %s but HOMELAB_USER is %s; appdata ownership may not match the containers", username, homelabUser)
+	}
+
+	ui.Successf("Running as non-root user %s", username)
+	return nil
+}
+
+// Group lookups used by checkRuntimeGroup, overridable in tests
+var (
+	groupExists          = system.GroupExists
+	isUserInGroup        = system.IsUserInGroup
+	currentProcessGroups = system.CurrentProcessGroups
+	addUserToGroup       = system.AddUserToGroup
+)
+
+// groupMembership is a user's standing in the container runtime group
+type groupMembership int
+
+const (
+	groupMissing      groupMembership = iota // the group does not exist
+	groupNotMember                           // the user is not in the group
+	groupPendingLogin                        // in the group, but not in this login session yet
+	groupMember                              // in the group and effective
+)
+
+// runtimeGroupMembership looks up username's membership of group. When
+// username runs this process, membership added since the user logged in is
+// reported as groupPendingLogin, since it only applies to new sessions.
+func runtimeGroupMembership(username, group string, isCurrentUser bool) (groupMembership, error) {
+	exists, err := groupExists(group)
+	if err != nil {
+		return groupNotMember, fmt.Errorf("failed to check group %s: %w", group, err)
+	}
+	if !exists {
+		return groupMissing, nil
+	}
+
+	member, err := isUserInGroup(username, group)
+	if err != nil {
+		return groupNotMember, fmt.Errorf("failed to check %s group membership: %w", group, err)
+	}
+	if !member {
+		return groupNotMember, nil
+	}
+	if !isCurrentUser {
+		return groupMember, nil
+	}
+
+	sessionGroups, err := currentProcessGroups()
+	if err != nil {
+		return groupNotMember, err
+	}
+	for _, g := range sessionGroups {
+		if g == group {
+			return groupMember, nil
+		}
+	}
+	return groupPendingLogin, nil
+}
+
+// checkRuntimeGroup verifies that the homelab user (or the current user) is
+// in the group that grants access to the container runtime, without which
+// compose commands fail with "permission denied" unless run as root, and
+// offers to add them (defaulting to no)
+func checkRuntimeGroup(cfg *config.Config, ui *ui.UI) error {
+	group := runtimeGroupName(cfg.GetOrDefault(config.KeyContainerRuntime, "docker"))
+	if group == "" {
+		ui.Info("Container runtime does not use a group for access, skipping")
+		return nil
+	}
+
+	_, current, err := currentIdentity()
+	if err != nil {
+		return fmt.Errorf("could not determine the current user: %w", err)
+	}
+	username := cfg.GetOrDefault(config.KeyHomelabUser, "")
+	if username == "" {
+		username = current
+	}
+
+	membership, err := runtimeGroupMembership(username, group, username == current)
+	if err != nil {
+		return err
+	}
+
+	switch membership {
+	case groupMember:
+		ui.Successf("User %s is in the %s group", username, group)
+		return nil
+	case groupMissing:
+		return fmt.Errorf("group %s does not exist (is %s installed?)", group, group)
+	case groupPendingLogin:
+		ui.Infof("User %s was added to the %s group after this session started", username, group)
+		ui.Info("  Log out and back in (or reboot) for the change to apply")
+		return fmt.Errorf("%s group membership of %s is not active in this session yet", group, username)
+	}
+
+	ui.Warningf("User %s is not in the %s group; compose commands will fail with \"permission denied\"", username, group)
+	add, err := ui.PromptYesNo(fmt.Sprintf("Add %s to the %s group now (requires sudo)?", username, group), false)
+	if err != nil {
+		return err
+	}
+	if !add {
+		ui.Info("To add the user later:")
+		ui.Infof("  sudo usermod -aG %s %s", group, username)
+		return fmt.Errorf("user %s is not in the %s group", username, group)
+	}
+	if err := addUserToGroup(username, group); err != nil {
+		return err
+	}
+	ui.Successf("Added %s to the %s group", username, group)
+	ui.Info("  Log out and back in (or reboot) for the change to apply")
+	return fmt.Errorf("added %s to the %s group; it takes effect after the next login", username, group)
+}
+
 // checkNetworkConnectivity tests basic network connectivity
-func checkNetworkConnectivity(ui *ui.UI) error {
+func checkNetworkConnectivity(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	ui.Info("Checking network connectivity...")
 
-	// Test connectivity to a reliable host
-	reachable, err := system.TestConnectivity("8.8.8.8", 3)
+	retries, err := strconv.Atoi(cfg.GetOrDefault(config.KeyNetworkTestRetries, "5"))
+	if err != nil || retries < 1 {
+		retries = 1
+	}
+
+	source, err := connectivitySource(cfg, ui)
 	if err != nil {
-		return fmt.Errorf("failed to test connectivity: %w", err)
+		return err
+	}
+	if source != "" {
+		ui.Infof("Sending connectivity checks from %s", source)
 	}
+	ping := pingFrom(source)
 
-	if !reachable {
-		ui.Error("No internet connectivity detected")
+	families := []struct {
+		name       string
+		target     string
+		getGateway func() (string, error)
+		// skipWithoutGateway avoids retrying a family that has no default route
+		skipWithoutGateway bool
+	}{
+		{"IPv4", ipv4ConnectivityTarget, system.GetDefaultGateway, false},
+		{"IPv6", ipv6ConnectivityTarget, system.GetDefaultGatewayV6, true},
+	}
+
+	reachableCount := 0
+	for _, family := range families {
+		gateway, gwErr := family.getGateway()
+		if gwErr != nil {
+			ui.Warningf("%s: could not determine default gateway: %v", family.name, gwErr)
+			if family.skipWithoutGateway {
+				continue
+			}
+		} else {
+			ui.Infof("%s default gateway: %s", family.name, gateway)
+		}
+
+		reachable, err := pingWithRetries(ctx, ping, family.target, retries, pingRetryInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			ui.Warningf("%s: failed to test connectivity: %v", family.name, err)
+			continue
+		}
+
+		if reachable {
+			ui.Successf("%s internet connectivity confirmed (%s)", family.name, family.target)
+			reachableCount++
+		} else {
+			ui.Warningf("%s: %s is unreachable", family.name, family.target)
+		}
+
+		// Test gateway connectivity
+		if gateway != "" {
+			gwReachable, _ := system.TestConnectivityFromContext(ctx, gateway, source, 2)
+			if gwReachable {
+				ui.Successf("%s default gateway is reachable", family.name)
+			} else {
+				ui.Warningf("%s default gateway is not responding to ping", family.name)
+			}
+		}
+	}
+
+	if reachableCount == 0 {
+		ui.Error("No internet connectivity detected over IPv4 or IPv6")
 		ui.Info("Please check:")
 		ui.Info("  1. Network cable is connected")
 		ui.Info("  2. Network configuration is correct")
@@ -206,29 +556,77 @@ func checkNetworkConnectivity(ui *ui.UI) error {
 		return fmt.Errorf("no internet connectivity")
 	}
 
-	ui.Success("Internet connectivity confirmed")
+	return nil
+}
+
+// Well-known anycast DNS resolvers used to test internet reachability
+const (
+	ipv4ConnectivityTarget = "8.8.8.8"
+	ipv6ConnectivityTarget = "2001:4860:4860::8888"
+)
+
+// pingRetryInterval is the delay between connectivity attempts
+const pingRetryInterval = time.Second
+
+// pingFunc reports whether host answered a single ping
+type pingFunc func(ctx context.Context, host string) (bool, error)
+
+// pingFrom returns a pingFunc that pings once with a 3 second timeout from
+// source (interface or address; empty uses the routing table)
+func pingFrom(source string) pingFunc {
+	return func(ctx context.Context, host string) (bool, error) {
+		return system.TestConnectivityFromContext(ctx, host, source, 3)
+	}
+}
+
+// connectivitySource returns the configured NETWORK_SOURCE. On multi-homed
+// hosts where it has never been set, the user is asked once and the answer
+// (including the default) is saved.
+func connectivitySource(cfg *config.Config, ui *ui.UI) (string, error) {
+	if cfg.Exists(config.KeyNetworkSource) {
+		return cfg.GetOrDefault(config.KeyNetworkSource, ""), nil
+	}
 
-	// Get and display default gateway
-	gateway, err := system.GetDefaultGateway()
+	source, err := promptForInterface(ui, "Multiple network interfaces found. Send connectivity checks from")
 	if err != nil {
-		ui.Warning(fmt.Sprintf("Could not determine default gateway: %v", err))
-	} else {
-		ui.Infof("Default gateway: %s", gateway)
+		ui.Warningf("Could not list network interfaces: %v", err)
+		return "", nil
+	}
 
-		// Test gateway connectivity
-		gwReachable, _ := system.TestConnectivity(gateway, 2)
-		if gwReachable {
-			ui.Success("Default gateway is reachable")
-		} else {
-			ui.Warning("Default gateway is not responding to ping")
-		}
+	if err := cfg.Set(config.KeyNetworkSource, source); err != nil {
+		return "", fmt.Errorf("failed to save network source: %w", err)
 	}
+	return source, nil
+}
 
-	return nil
+// pingWithRetries pings host up to attempts times, waiting interval between
+// attempts, and stops as soon as ctx is cancelled
+func pingWithRetries(ctx context.Context, ping pingFunc, host string, attempts int, interval time.Duration) (bool, error) {
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		reachable, err := ping(ctx, host)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
+		if err != nil {
+			return false, err
+		}
+		if reachable {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // checkNFSServer validates NFS server is accessible if configured
-func checkNFSServer(host string, ui *ui.UI) error {
+func checkNFSServer(cfg *config.Config, host string, ui *ui.UI) error {
 	if host == "" {
 		ui.Info("NFS server not configured yet, skipping NFS check")
 		return nil
@@ -236,6 +634,14 @@ func checkNFSServer(host string, ui *ui.UI) error {
 
 	ui.Infof("Checking NFS server: %s", host)
 
+	options, err := getNFSMountOptions(cfg)
+	if err != nil {
+		ui.Errorf("%v", err)
+		ui.Infof("Check %s and %s in %s", config.KeyNFSVersion, config.KeyNFSMountOptions, cfg.FilePath())
+		return err
+	}
+	version := nfsVersion(options)
+
 	// First check basic connectivity
 	reachable, err := system.TestConnectivity(host, 5)
 	if err != nil {
@@ -259,6 +665,16 @@ func checkNFSServer(host string, ui *ui.UI) error {
 		return fmt.Errorf("failed to check NFS exports: %w", err)
 	}
 
+	// showmount speaks the NFSv3 MOUNT protocol, which NFSv4-only servers do
+	// not offer; for v4 the NFS port answering is enough to go on
+	if !hasExports && version == "4" {
+		if open, _ := isPortOpen(host, nfsPort, 5); open {
+			ui.Successf("NFS server answers on port %d (NFSv4)", nfsPort)
+			ui.Info("showmount got no answer, which is normal for NFSv4-only servers; exports cannot be listed")
+			return checkNFSExportMount(cfg, host, ui)
+		}
+	}
+
 	if !hasExports {
 		ui.Warning("NFS server is reachable but showmount failed")
 		ui.Info("This might indicate:")
@@ -271,80 +687,606 @@ func checkNFSServer(host string, ui *ui.UI) error {
 	ui.Success("NFS server has accessible exports")
 
 	// Try to get and display exports
-	exports, err := system.GetNFSExports(host)
-	if err == nil && exports != "" {
-		ui.Info("Available NFS exports:")
-		ui.Print(exports)
+	raw, err := system.GetNFSExports(host)
+	if err != nil {
+		ui.Warningf("Could not list NFS exports: %v", err)
+	} else {
+		exports := showNFSExports(ui, raw)
+		if expected := cfg.GetOrDefault(config.KeyNFSExpectedExports, ""); expected != "" {
+			if exports == nil {
+				ui.Warningf("Cannot compare %s: the export list could not be parsed", config.KeyNFSExpectedExports)
+			} else {
+				localIPs, err := system.GetLocalIPs()
+				if err != nil {
+					ui.Warning(fmt.Sprintf("Could not determine local addresses: %v", err))
+				}
+				hostname, _ := system.GetHostname()
+				if err := checkExpectedNFSExports(exports, splitExpectedExports(expected), localIPs, hostname, ui); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return checkNFSExportMount(cfg, host, ui)
+}
+
+// nfsPort is the NFS service port; NFSv4 needs nothing else
+const nfsPort = 2049
+
+// checkNFSExportMount is the optional deeper NFS check: it offers to actually
+// mount the configured export, with the configured version and options, and
+// write to it
+func checkNFSExportMount(cfg *config.Config, host string, ui *ui.UI) error {
+	export := cfg.GetOrDefault(config.KeyNFSExport, "")
+	if export == "" {
+		return nil
+	}
+
+	runTest, err := ui.PromptYesNo(fmt.Sprintf("Test mounting %s:%s read/write (temporary mount, requires sudo)?", host, export), false)
+	if err != nil || !runTest {
+		return nil
+	}
+
+	return checkNFSMountReadWrite(cfg, host, export, ui)
+}
+
+// splitExpectedExports parses the comma-separated NFS_EXPECTED_EXPORTS value
+func splitExpectedExports(value string) []string {
+	var paths []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// checkExpectedNFSExports reports pass/warn for every expected export: whether
+// the server exports it at all and whether its client ACL includes the host
+// with the given addresses and name
+func checkExpectedNFSExports(exports []system.NFSExport, expected []string, addrs []net.IP, hostname string, ui *ui.UI) error {
+	ui.Info("Checking expected NFS exports...")
+
+	byPath := make(map[string]system.NFSExport, len(exports))
+	for _, export := range exports {
+		byPath[export.Path] = export
+	}
+
+	var missing, denied []string
+	for _, path := range expected {
+		export, ok := byPath[path]
+		if !ok {
+			ui.Warning(fmt.Sprintf("  %s: not exported by server", path))
+			missing = append(missing, path)
+			continue
+		}
+
+		allowed, known := export.AllowsClient(addrs, hostname)
+		switch {
+		case allowed:
+			ui.Success(fmt.Sprintf("  %s: exported to this host", path))
+		case !known:
+			ui.Warning(fmt.Sprintf("  %s: exported to %s (could not confirm this host is included)", path, strings.Join(export.Clients, ",")))
+		default:
+			ui.Warning(fmt.Sprintf("  %s: exported only to %s, not to this host", path, strings.Join(export.Clients, ",")))
+			denied = append(denied, path)
+		}
+	}
+
+	if len(missing) > 0 || len(denied) > 0 {
+		var problems []string
+		if len(missing) > 0 {
+			problems = append(problems, "missing: "+strings.Join(missing, ", "))
+		}
+		if len(denied) > 0 {
+			problems = append(problems, "not exported to this host: "+strings.Join(denied, ", "))
+		}
+		return fmt.Errorf("expected NFS exports unavailable (%s)", strings.Join(problems, "; "))
 	}
 
 	return nil
 }
 
-// RunPreflightChecks executes all preflight checks
-func RunPreflightChecks(cfg *config.Config, ui *ui.UI) error {
-	// Check if already completed
-	if cfg.IsComplete(preflightCompletionMarker) {
-		ui.Info("Preflight checks already completed (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + preflightCompletionMarker)
+// checkNFSMountReadWrite mounts the export to a temporary mountpoint, writes and
+// reads back a test file, and reports the specific failure if any
+func checkNFSMountReadWrite(cfg *config.Config, host, export string, ui *ui.UI) error {
+	ui.Infof("Mounting %s:%s to a temporary mountpoint...", host, export)
+
+	options, err := getNFSMountOptions(cfg)
+	if err != nil {
+		return err
+	}
+	result, err := system.TestNFSMountReadWrite(host, export, options)
+	if err != nil {
+		ui.Error(fmt.Sprintf("NFS read/write test failed: %v", err))
+		return fmt.Errorf("NFS read/write test failed: %w", err)
+	}
+
+	ui.Success("NFS export is mountable, writable and readable")
+	if result.RootSquashed {
+		ui.Info("Root writes are squashed on this export (root_squash); files must be written as a regular UID")
+	} else {
+		ui.Warning("Root can write to this export (no_root_squash); consider enabling root_squash on the server")
+	}
+
+	return nil
+}
+
+// mediaSelected reports whether the media stack is among the selected services
+func mediaSelected(cfg *config.Config) bool {
+	services, err := getSelectedServices(cfg)
+	if err != nil {
+		return false
+	}
+	for _, service := range services {
+		if service == "media" {
+			return true
+		}
+	}
+	return false
+}
+
+// transcodeGroups are the groups that normally own GPU render nodes
+var transcodeGroups = []string{"render", "video"}
+
+// checkTranscodeCapability reports whether hardware transcoding is likely to
+// work: a render node is present and the service user can open it. Problems
+// are returned as a warning summary after printing remediation steps.
+func checkTranscodeCapability(cfg *config.Config, ui *ui.UI) error {
+	devices, err := system.DetectRenderDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		ui.Info("To enable hardware transcoding:")
+		ui.Info("  1. Enable the integrated GPU in the BIOS/UEFI")
+		ui.Info("  2. Check the driver is loaded: lsmod | grep -E 'i915|xe|amdgpu'")
+		ui.Info("  3. Verify: ls -l /dev/dri")
+		return fmt.Errorf("no GPU render device found; Plex and Jellyfin will use software transcoding")
+	}
+	for _, device := range devices {
+		ui.Successf("Render device found: %s", describeRenderDevice(device))
+	}
+
+	username, err := getServiceUser(cfg)
+	if err != nil {
+		current, curErr := system.GetCurrentUser()
+		if curErr != nil {
+			return fmt.Errorf("failed to determine user for transcode check: %w", curErr)
+		}
+		username = current.Username
+	}
+
+	device := cfg.GetOrDefault(config.KeyTranscodeDevice, devices[0])
+	if device == "" {
+		device = devices[0]
+	}
+	groups := transcodeGroups
+	if owner, err := system.DeviceGroup(device); err == nil {
+		groups = []string{owner}
+	}
+
+	for _, group := range groups {
+		member, err := system.IsUserInGroup(username, group)
+		if err != nil {
+			return fmt.Errorf("failed to check groups for %s: %w", username, err)
+		}
+		if member {
+			ui.Successf("User %s is in the %s group", username, group)
+			return nil
+		}
+	}
+
+	ui.Info("To give the service user access to the GPU:")
+	ui.Infof("  sudo usermod -aG %s %s", groups[0], username)
+	ui.Info("  Then log out and back in (or reboot) for the change to apply")
+	return fmt.Errorf("user %s is not in the %s group; hardware transcoding may fail", username, strings.Join(groups, "/"))
+}
+
+// lowDiskSpaceThreshold is the free space on container storage below which
+// kernelModule is a kernel module needed by an optional feature
+type kernelModule struct {
+	name    string
+	feature string
+}
+
+// requiredKernelModules returns the kernel modules needed by the configured
+// optional features. The host only mounts NFS shares, so the client module
+// is needed but nfsd is not.
+func requiredKernelModules(cfg *config.Config) []kernelModule {
+	var modules []kernelModule
+	if cfg.GetOrDefault(config.KeyNFSServer, "") != "" {
+		modules = append(modules, kernelModule{name: "nfs", feature: "NFS mounts"})
+	}
+	if cfg.GetOrDefault("WIREGUARD_ENABLED", "") == "true" {
+		modules = append(modules, kernelModule{name: "wireguard", feature: "WireGuard"})
+	}
+	return modules
+}
+
+// kernelModuleState looks up a kernel module, overridable in tests
+var kernelModuleState = system.KernelModuleState
+
+// checkKernelModules warns when a kernel module needed by a configured
+// feature is neither loaded nor installed for the running kernel
+func checkKernelModules(cfg *config.Config, ui *ui.UI) error {
+	var missing []string
+	for _, module := range requiredKernelModules(cfg) {
+		state, err := kernelModuleState(module.name)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case system.ModuleLoaded:
+			ui.Successf("Kernel module %s is loaded (%s)", module.name, module.feature)
+		case system.ModuleAvailable:
+			ui.Successf("Kernel module %s is available and loads on demand (%s)", module.name, module.feature)
+		default:
+			ui.Warningf("Kernel module %s not found for the running kernel (needed for %s)", module.name, module.feature)
+			missing = append(missing, module.name)
+		}
+	}
+	if len(missing) == 0 {
 		return nil
 	}
 
-	ui.Header("Pre-flight System Validation")
-	ui.Info("Verifying system requirements before setup...")
-	ui.Print("")
+	ui.Info("To install the missing modules:")
+	ui.Info("  sudo rpm-ostree install kernel-modules kernel-modules-extra")
+	ui.Info("  sudo systemctl reboot")
+	ui.Infof("  Verify: modinfo %s", strings.Join(missing, " "))
+	ui.Info("If you recently updated the kernel, reboot into it first; modules are installed per kernel version")
+	return fmt.Errorf("missing kernel modules: %s", strings.Join(missing, ", "))
+}
+
+// preflight warns; image pulls for all stacks need several GiB
+const lowDiskSpaceThreshold = 10 << 30
+
+// checkDiskSpace warns when the filesystem holding container images is low on
+// space and suggests pruning unused images and volumes
+func checkDiskSpace(cfg *config.Config, ui *ui.UI) error {
+	runtime, err := getRuntimeFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	free, root, err := system.ContainerStorageFree(runtime)
+	if err != nil {
+		return fmt.Errorf("could not check free space on container storage: %w", err)
+	}
+	if free >= lowDiskSpaceThreshold {
+		ui.Successf("%s free on %s", system.FormatBytes(int64(free)), root)
+		return nil
+	}
+
+	ui.Warningf("Only %s free on %s", system.FormatBytes(int64(free)), root)
+	if candidates, err := system.ListPruneCandidates(runtime); err == nil && !candidates.Empty() {
+		ui.Infof("  Found %s that can be removed", candidates)
+	}
+	ui.Info("  To free space, use Maintenance → Prune Unused Images and Volumes ([M] then [7])")
+	return fmt.Errorf("low disk space on container storage: %s free (recommended: %s)", system.FormatBytes(int64(free)), system.FormatBytes(lowDiskSpaceThreshold))
+}
+
+// defaultClockSkewThreshold is used when CLOCK_SKEW_THRESHOLD is unset or
+// invalid
+const defaultClockSkewThreshold = 5 * time.Second
+
+// ntpQueryTimeout bounds the query to NTP_SERVER
+const ntpQueryTimeout = 5 * time.Second
+
+// clockSkewThreshold returns the configured CLOCK_SKEW_THRESHOLD
+func clockSkewThreshold(cfg *config.Config) time.Duration {
+	seconds, err := strconv.ParseFloat(cfg.GetOrDefault(config.KeyClockSkewThreshold, "5"), 64)
+	if err != nil || seconds <= 0 {
+		return defaultClockSkewThreshold
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
 
-	hasErrors := false
-	errorMessages := []string{}
+// describeClockOffset renders an offset as e.g. "1.25s ahead"
+func describeClockOffset(offset time.Duration) string {
+	rounded := offset.Round(time.Millisecond)
+	switch {
+	case rounded > 0:
+		return rounded.String() + " ahead"
+	case rounded < 0:
+		return (-rounded).String() + " behind"
+	default:
+		return "less than 1ms off"
+	}
+}
 
-	// Run rpm-ostree check
-	ui.Step("Checking Operating System")
-	if err := checkRpmOstree(ui); err != nil {
-		hasErrors = true
-		errorMessages = append(errorMessages, err.Error())
+// measureClockOffset returns how far the clock is ahead of NTP_SERVER,
+// falling back to chronyd's own estimate when no server is configured or it
+// cannot be reached. The second value names where the offset came from.
+func measureClockOffset(ctx context.Context, cfg *config.Config, ui *ui.UI) (time.Duration, string, error) {
+	if server := cfg.GetOrDefault(config.KeyNTPServer, ""); server != "" {
+		offset, err := system.QueryNTPOffset(ctx, server, ntpQueryTimeout)
+		if err == nil {
+			return offset, server, nil
+		}
+		ui.Warningf("Could not query NTP server: %v", err)
 	}
+	offset, err := system.ChronyOffset()
+	return offset, "chronyd", err
+}
+
+// checkClockSync warns when time synchronization is disabled or the clock is
+// further from NTP time than CLOCK_SKEW_THRESHOLD. A wrong clock breaks TLS
+// certificate validation, image pulls and TOTP codes.
+func checkClockSync(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+	var problems []string
+
+	status, err := system.GetTimeSync()
+	switch {
+	case err != nil:
+		ui.Warningf("Could not query time synchronization: %v", err)
+	case !status.NTPEnabled:
+		problems = append(problems, "NTP time synchronization is not enabled")
+	case !status.Synchronized:
+		problems = append(problems, "the system clock is not synchronized yet")
+	default:
+		ui.Success("System clock is synchronized")
+	}
+
+	offset, source, err := measureClockOffset(ctx, cfg, ui)
+	if err != nil {
+		ui.Warningf("Could not measure clock offset: %v", err)
+	} else {
+		threshold := clockSkewThreshold(cfg)
+		if offset > threshold || offset < -threshold {
+			problems = append(problems, fmt.Sprintf("clock is %s according to %s (threshold %s)", describeClockOffset(offset), source, threshold))
+		} else {
+			ui.Successf("Clock is %s according to %s", describeClockOffset(offset), source)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	ui.Info("To enable time synchronization:")
+	ui.Info("  sudo systemctl enable --now chronyd")
+	ui.Info("  sudo timedatectl set-ntp true")
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// requiredHostPorts returns the TCP host ports the selected stacks will
+// publish, sorted by port. They are read from each stack's compose file,
+// which also covers a reverse proxy on 80/443; stacks without one fall back
+// to the default web UI ports.
+func requiredHostPorts(cfg *config.Config) []labeledPort {
+	selected, err := getSelectedServices(cfg)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var ports []labeledPort
+	add := func(port int, label string) {
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, labeledPort{port, label})
+		}
+	}
+	for _, stack := range selected {
+		published, err := collectPublishedPorts(cfg, []string{stack})
+		if err == nil && len(published) > 0 {
+			for _, port := range published {
+				if port.Protocol == "tcp" {
+					add(port.HostPort, port.Container)
+				}
+			}
+			continue
+		}
+		for app, port := range resolveStackPorts(cfg, stack) {
+			if n, err := strconv.Atoi(port); err == nil {
+				add(n, app)
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
 
-	// Run package checks
-	ui.Step("Checking Required Packages")
-	if err := checkRequiredPackages(ui); err != nil {
-		hasErrors = true
-		errorMessages = append(errorMessages, err.Error())
+// Port lookups, overridable in tests
+var (
+	isPortFree   = system.IsPortFree
+	isPortOpen   = system.IsPortOpen
+	portListener = system.PortListener
+)
+
+// portInUse reports whether something listens on the local TCP port. Binding
+// a privileged port needs root, so those are checked by connecting instead.
+func portInUse(port int) (bool, error) {
+	free, err := isPortFree(port)
+	if err == nil {
+		return !free, nil
+	}
+	open, dialErr := isPortOpen("127.0.0.1", port, 1)
+	if dialErr != nil {
+		return false, err
 	}
+	return open, nil
+}
 
-	// Run container runtime check
-	ui.Step("Checking Container Runtime")
-	if err := checkContainerRuntime(cfg, ui); err != nil {
-		hasErrors = true
-		errorMessages = append(errorMessages, err.Error())
+// checkHostPorts warns when a port the selected stacks publish is already in
+// use, which would otherwise only surface as a failed container start
+func checkHostPorts(cfg *config.Config, ui *ui.UI) error {
+	ports := requiredHostPorts(cfg)
+	var conflicts []string
+	for _, port := range ports {
+		inUse, err := portInUse(port.Port)
+		if err != nil {
+			ui.Warningf("Could not check port %d (%s): %v", port.Port, port.Label, err)
+			continue
+		}
+		if !inUse {
+			continue
+		}
+		if listener := portListener(port.Port); listener != "" {
+			ui.Warningf("Port %d (%s) is in use by %s", port.Port, port.Label, listener)
+		} else {
+			ui.Warningf("Port %d (%s) is in use by another process", port.Port, port.Label)
+		}
+		conflicts = append(conflicts, strconv.Itoa(port.Port))
+	}
+	if len(conflicts) == 0 {
+		ui.Successf("All %d required host ports are free", len(ports))
+		return nil
 	}
 
-	// Run sudo access check
-	ui.Step("Checking Sudo Access")
-	if err := checkSudoAccess(ui); err != nil {
-		hasErrors = true
-		errorMessages = append(errorMessages, err.Error())
+	ui.Info("If the stacks are already deployed, their own containers hold these ports and this is expected")
+	ui.Info("Otherwise stop the conflicting service or change the host port in the stack's .env before deploying")
+	ui.Info("  Find the process with: sudo ss -ltnp")
+	return fmt.Errorf("host ports already in use: %s", strings.Join(conflicts, ", "))
+}
+
+// preflightCheck is one step of RunPreflightChecks
+type preflightCheck struct {
+	name  string // as accepted by PREFLIGHT_SKIP
+	title string
+	// fatal failures block completion; the others are printed as warnings
+	fatal bool
+	// applies reports whether the check is relevant (nil = always)
+	applies func(cfg *config.Config) bool
+	run     func(ctx context.Context, cfg *config.Config, ui *ui.UI) error
+}
+
+// preflightChecks are run in order by RunPreflightChecks, overridable in
+// tests. Keep the names in sync with the PREFLIGHT_SKIP documentation.
+var preflightChecks = []preflightCheck{
+	{name: "os", title: "Checking Operating System", fatal: true,
+		run: func(_ context.Context, _ *config.Config, ui *ui.UI) error { return checkRpmOstree(ui) }},
+	{name: "packages", title: "Checking Required Packages", fatal: true,
+		run: func(_ context.Context, _ *config.Config, ui *ui.UI) error { return checkRequiredPackages(ui) }},
+	{name: "runtime", title: "Checking Container Runtime", fatal: true,
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkContainerRuntime(cfg, ui) }},
+	{name: "sudo", title: "Checking Sudo Access", fatal: true,
+		run: func(_ context.Context, _ *config.Config, ui *ui.UI) error { return checkSudoAccess(ui) }},
+	// Running as root is allowed but is a common cause of ownership problems
+	{name: "user", title: "Checking Current User",
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkRunningUser(cfg, ui) }},
+	// Compose still works through sudo, and a new membership needs a re-login
+	{name: "group", title: "Checking Container Runtime Group",
+		applies: func(cfg *config.Config) bool {
+			return runtimeGroupName(cfg.GetOrDefault(config.KeyContainerRuntime, "docker")) != ""
+		},
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkRuntimeGroup(cfg, ui) }},
+	// Setup can proceed on low disk space and pulls may still fit
+	{name: "disk", title: "Checking Disk Space",
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkDiskSpace(cfg, ui) }},
+	{name: "network", title: "Checking Network Connectivity", fatal: true,
+		run: func(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+			defer timeOperation(ui, "network connectivity check")()
+			return checkNetworkConnectivity(ctx, cfg, ui)
+		}},
+	// chronyd usually corrects clock skew shortly
+	{name: "time", title: "Checking Time Synchronization", run: checkClockSync},
+	{name: "nfs", title: "Checking NFS Server",
+		applies: func(cfg *config.Config) bool { return cfg.GetOrDefault(config.KeyNFSServer, "") != "" },
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error {
+			defer timeOperation(ui, "NFS server check")()
+			return checkNFSServer(cfg, cfg.GetOrDefault(config.KeyNFSServer, ""), ui)
+		}},
+	// A missing module only affects the optional feature that needs it
+	{name: "modules", title: "Checking Kernel Modules",
+		applies: func(cfg *config.Config) bool { return len(requiredKernelModules(cfg)) > 0 },
+		run:     func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkKernelModules(cfg, ui) }},
+	// A proxy elsewhere or the stack's own running containers may hold a port
+	{name: "ports", title: "Checking Host Ports",
+		applies: func(cfg *config.Config) bool { return len(requiredHostPorts(cfg)) > 0 },
+		run:     func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkHostPorts(cfg, ui) }},
+	// Software transcoding still works without a usable GPU
+	{name: "transcode", title: "Checking Hardware Transcoding", applies: mediaSelected,
+		run: func(_ context.Context, cfg *config.Config, ui *ui.UI) error { return checkTranscodeCapability(cfg, ui) }},
+}
+
+// preflightSkips returns the set of check names to skip from PREFLIGHT_SKIP
+// and extra, rejecting names that are not checks
+func preflightSkips(cfg *config.Config, extra []string) (map[string]bool, error) {
+	valid := make(map[string]bool, len(preflightChecks))
+	names := make([]string, 0, len(preflightChecks))
+	for _, check := range preflightChecks {
+		valid[check.name] = true
+		names = append(names, check.name)
 	}
 
-	// Run network connectivity check
-	ui.Step("Checking Network Connectivity")
-	if err := checkNetworkConnectivity(ui); err != nil {
-		hasErrors = true
-		errorMessages = append(errorMessages, err.Error())
+	skips := make(map[string]bool)
+	requested := append(strings.Split(cfg.GetOrDefault(config.KeyPreflightSkip, ""), ","), extra...)
+	for _, name := range requested {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !valid[name] {
+			return nil, fmt.Errorf("unknown preflight check %q to skip (valid: %s)", name, strings.Join(names, ", "))
+		}
+		skips[name] = true
 	}
+	return skips, nil
+}
 
-	// Check NFS server if configured
-	nfsServer := cfg.GetOrDefault("NFS_SERVER", "")
-	if nfsServer != "" {
-		ui.Step("Checking NFS Server")
-		if err := checkNFSServer(nfsServer, ui); err != nil {
-			// NFS errors are warnings, not critical errors
+// runPreflightList runs checks, skipping those named in skips, and returns
+// the messages of the fatal checks that failed and the number skipped. An
+// error is returned only if ctx is cancelled.
+func runPreflightList(ctx context.Context, cfg *config.Config, ui *ui.UI, checks []preflightCheck, skips map[string]bool) ([]string, int, error) {
+	var errorMessages []string
+	skipped := 0
+	for _, check := range checks {
+		if check.applies != nil && !check.applies(cfg) {
+			continue
+		}
+		ui.Step(check.title)
+		if skips[check.name] {
+			ui.Infof("Skipped (%q was requested to be skipped)", check.name)
+			skipped++
+			continue
+		}
+
+		err := check.run(ctx, cfg, ui)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, 0, ctxErr
+		}
+		switch {
+		case err == nil:
+		case check.fatal:
+			errorMessages = append(errorMessages, err.Error())
+		default:
 			ui.Warning(err.Error())
 		}
 	}
+	return errorMessages, skipped, nil
+}
+
+// RunPreflightChecks executes all preflight checks except those named in
+// PREFLIGHT_SKIP or skip. Skipped checks are reported as such and do not
+// block completion.
+func RunPreflightChecks(ctx context.Context, cfg *config.Config, ui *ui.UI, skip ...string) error {
+	// Check if already completed
+	if cfg.IsComplete(preflightCompletionMarker) {
+		ui.Info("Preflight checks already completed (marker found)")
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), preflightCompletionMarker))
+		return nil
+	}
+
+	skips, err := preflightSkips(cfg, skip)
+	if err != nil {
+		return err
+	}
+
+	ui.Header("Pre-flight System Validation")
+	ui.Info("Verifying system requirements before setup...")
+	ui.Print("")
+
+	errorMessages, skipped, err := runPreflightList(ctx, cfg, ui, preflightChecks, skips)
+	if err != nil {
+		return err
+	}
 
 	ui.Print("")
 	ui.Separator()
 
-	if hasErrors {
+	if len(errorMessages) > 0 {
 		ui.Error("Pre-flight checks FAILED")
 		ui.Info("Please resolve the issues above before continuing")
 		ui.Print("")
@@ -354,7 +1296,11 @@ func RunPreflightChecks(cfg *config.Config, ui *ui.UI) error {
 		return fmt.Errorf("preflight checks failed with %d error(s)", len(errorMessages))
 	}
 
-	ui.Success("✓ All pre-flight checks PASSED")
+	if skipped > 0 {
+		ui.Successf("%s All pre-flight checks PASSED (%d skipped)", ui.GlyphOK(), skipped)
+	} else {
+		ui.Successf("%s All pre-flight checks PASSED", ui.GlyphOK())
+	}
 	ui.Info("System is ready for homelab setup")
 
 	// Create completion marker
@@ -0,0 +1,215 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// remoteProbe is the part of system.RemoteHost the remote preflight uses, so
+// the checks can be tested without SSH
+type remoteProbe interface {
+	Hostname(ctx context.Context) (string, error)
+	Addresses(ctx context.Context) ([]net.IP, error)
+	Ping(ctx context.Context, host string, timeoutSeconds int) (bool, error)
+	TCPPortOpen(ctx context.Context, host string, port, timeoutSeconds int) (bool, error)
+	NFSExports(ctx context.Context, server string) (string, error)
+	PortListener(ctx context.Context, port int) (bool, string, error)
+}
+
+// newRemoteProbe connects the remote preflight to a host, replaced in tests
+var newRemoteProbe = func(target system.SSHTarget) remoteProbe {
+	return system.NewRemoteHost(target)
+}
+
+// remoteNetworkCheck checks that the remote host reaches the internet over
+// IPv4 or IPv6
+func remoteNetworkCheck(host remoteProbe) func(context.Context, *config.Config, *ui.UI) error {
+	return func(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+		retries, err := strconv.Atoi(cfg.GetOrDefault(config.KeyNetworkTestRetries, "5"))
+		if err != nil || retries < 1 {
+			retries = 1
+		}
+		ping := func(ctx context.Context, target string) (bool, error) {
+			return host.Ping(ctx, target, 3)
+		}
+
+		reachable := 0
+		for _, family := range []struct{ name, target string }{
+			{"IPv4", ipv4ConnectivityTarget},
+			{"IPv6", ipv6ConnectivityTarget},
+		} {
+			ok, err := pingWithRetries(ctx, ping, family.target, retries, pingRetryInterval)
+			switch {
+			case err != nil:
+				if ctx.Err() != nil {
+					return err
+				}
+				ui.Warningf("%s: failed to test connectivity: %v", family.name, err)
+			case ok:
+				ui.Successf("%s internet connectivity confirmed (%s)", family.name, family.target)
+				reachable++
+			default:
+				ui.Warningf("%s: %s is unreachable", family.name, family.target)
+			}
+		}
+		if reachable == 0 {
+			return fmt.Errorf("no internet connectivity")
+		}
+		return nil
+	}
+}
+
+// remoteNFSCheck checks that the remote host reaches the NFS server and sees
+// its exports. Mounting is left to the local preflight, which needs sudo.
+func remoteNFSCheck(host remoteProbe) func(context.Context, *config.Config, *ui.UI) error {
+	return func(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+		server := cfg.GetOrDefault(config.KeyNFSServer, "")
+		if server == "" {
+			ui.Info("NFS server not configured yet, skipping NFS check")
+			return nil
+		}
+		options, err := getNFSMountOptions(cfg)
+		if err != nil {
+			return err
+		}
+
+		reachable, err := host.Ping(ctx, server, 5)
+		if err != nil {
+			return fmt.Errorf("failed to test NFS server connectivity: %w", err)
+		}
+		if !reachable {
+			return fmt.Errorf("NFS server %s is unreachable", server)
+		}
+		ui.Successf("NFS server %s is reachable", server)
+
+		exports, err := host.NFSExports(ctx, server)
+		if err != nil {
+			// NFSv4-only servers do not answer showmount
+			if nfsVersion(options) == "4" {
+				if open, _ := host.TCPPortOpen(ctx, server, nfsPort, 5); open {
+					ui.Successf("NFS server answers on port %d (NFSv4)", nfsPort)
+					return nil
+				}
+			}
+			return fmt.Errorf("NFS server has no accessible exports: %w", err)
+		}
+		ui.Success("NFS server has accessible exports")
+		parsed := showNFSExports(ui, exports)
+
+		if expected := cfg.GetOrDefault(config.KeyNFSExpectedExports, ""); expected != "" {
+			if parsed == nil {
+				ui.Warningf("Cannot compare %s: the export list could not be parsed", config.KeyNFSExpectedExports)
+				return nil
+			}
+			// The export ACLs must include the remote host, not this one
+			addrs, err := host.Addresses(ctx)
+			if err != nil {
+				ui.Warningf("Could not determine the remote host's addresses: %v", err)
+			}
+			hostname, _ := host.Hostname(ctx)
+			return checkExpectedNFSExports(parsed, splitExpectedExports(expected), addrs, hostname, ui)
+		}
+		return nil
+	}
+}
+
+// remotePortsCheck warns when a host port the selected stacks publish is in
+// use on the remote host
+func remotePortsCheck(host remoteProbe) func(context.Context, *config.Config, *ui.UI) error {
+	return func(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
+		ports := requiredHostPorts(cfg)
+		var conflicts []string
+		for _, port := range ports {
+			inUse, listener, err := host.PortListener(ctx, port.Port)
+			if err != nil {
+				return fmt.Errorf("failed to check port %d: %w", port.Port, err)
+			}
+			if !inUse {
+				continue
+			}
+			if listener != "" {
+				ui.Warningf("Port %d (%s) is in use by %s", port.Port, port.Label, listener)
+			} else {
+				ui.Warningf("Port %d (%s) is in use by another process", port.Port, port.Label)
+			}
+			conflicts = append(conflicts, strconv.Itoa(port.Port))
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("host ports already in use: %s", strings.Join(conflicts, ", "))
+		}
+		ui.Successf("All %d required host ports are free", len(ports))
+		return nil
+	}
+}
+
+// remotePreflightChecks are the preflight checks that make sense from another
+// machine's point of view, under the same names as their local counterparts
+func remotePreflightChecks(host remoteProbe) []preflightCheck {
+	return []preflightCheck{
+		{name: "network", title: "Checking Network Connectivity", fatal: true, run: remoteNetworkCheck(host)},
+		{name: "nfs", title: "Checking NFS Server", fatal: true, run: remoteNFSCheck(host)},
+		{name: "ports", title: "Checking Host Ports", run: remotePortsCheck(host)},
+	}
+}
+
+// RunRemotePreflight runs the network-facing preflight checks (network, nfs
+// and ports) on another machine over SSH, using this machine's configuration,
+// so a fleet of identical hosts can be validated from one place. target is
+// [user@]host[:port]; authentication must work without a password prompt and
+// the host key must already be in known_hosts.
+// Checks named in PREFLIGHT_SKIP or skip are skipped. No completion marker is
+// written.
+func RunRemotePreflight(ctx context.Context, cfg *config.Config, ui *ui.UI, target string, skip ...string) error {
+	sshTarget, err := system.ParseSSHTarget(target)
+	if err != nil {
+		return err
+	}
+	skips, err := preflightSkips(cfg, skip)
+	if err != nil {
+		return err
+	}
+
+	ui.Header(fmt.Sprintf("Remote Pre-flight Validation: %s", sshTarget))
+
+	host := newRemoteProbe(sshTarget)
+	if closer, ok := host.(io.Closer); ok {
+		defer closer.Close()
+	}
+	ui.Step("Connecting over SSH")
+	hostname, err := host.Hostname(ctx)
+	if err != nil {
+		ui.Errorf("Cannot run commands on %s: %v", sshTarget, err)
+		ui.Info("Make sure key-based SSH login works, e.g. ssh " + sshTarget.String() + " true")
+		return fmt.Errorf("preflight checks failed: %w", err)
+	}
+	ui.Successf("Connected to %s (%s)", sshTarget, hostname)
+
+	errorMessages, skipped, err := runPreflightList(ctx, cfg, ui, remotePreflightChecks(host), skips)
+	if err != nil {
+		return err
+	}
+
+	ui.Print("")
+	ui.Separator()
+	if len(errorMessages) > 0 {
+		ui.Errorf("Remote pre-flight checks FAILED on %s", hostname)
+		for i, msg := range errorMessages {
+			ui.Errorf("%d. %s", i+1, msg)
+		}
+		return fmt.Errorf("preflight checks failed on %s with %d error(s)", sshTarget, len(errorMessages))
+	}
+	if skipped > 0 {
+		ui.Successf("%s Remote pre-flight checks PASSED on %s (%d skipped)", ui.GlyphOK(), hostname, skipped)
+	} else {
+		ui.Successf("%s Remote pre-flight checks PASSED on %s", ui.GlyphOK(), hostname)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// fakeRemote answers remote probes from fixed state
+type fakeRemote struct {
+	connectErr error
+	reachable  map[string]bool
+	exports    string // empty: showmount fails
+	nfsPortOK  bool
+	listeners  map[int]string
+}
+
+func (f *fakeRemote) Hostname(context.Context) (string, error) { return "mini1", f.connectErr }
+func (f *fakeRemote) Addresses(context.Context) ([]net.IP, error) {
+	return []net.IP{net.ParseIP("192.168.1.20")}, nil
+}
+func (f *fakeRemote) Ping(_ context.Context, host string, _ int) (bool, error) {
+	return f.reachable[host], nil
+}
+func (f *fakeRemote) TCPPortOpen(_ context.Context, _ string, port, _ int) (bool, error) {
+	return port == nfsPort && f.nfsPortOK, nil
+}
+func (f *fakeRemote) NFSExports(context.Context, string) (string, error) {
+	if f.exports == "" {
+		return "", errors.New("showmount -e nas: RPC: Program not registered")
+	}
+	return f.exports, nil
+}
+func (f *fakeRemote) PortListener(_ context.Context, port int) (bool, string, error) {
+	listener, ok := f.listeners[port]
+	return ok, listener, nil
+}
+
+func TestRunRemotePreflight(t *testing.T) {
+	orig := newRemoteProbe
+	t.Cleanup(func() { newRemoteProbe = orig })
+
+	newConfig := func(t *testing.T, values map[string]string) *config.Config {
+		cfg := config.New(filepath.Join(t.TempDir(), "config"))
+		values[config.KeyContainersBase] = t.TempDir()
+		values[config.KeyNetworkTestRetries] = "1"
+		if err := cfg.SetMany(values); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	online := map[string]bool{ipv4ConnectivityTarget: true, "nas": true}
+
+	tests := []struct {
+		name    string
+		remote  fakeRemote
+		values  map[string]string
+		wantErr string
+	}{
+		{"ssh fails", fakeRemote{connectErr: errors.New("ssh to mini1 failed: Permission denied")}, map[string]string{}, "Permission denied"},
+		{"all pass", fakeRemote{reachable: online, exports: "Export list for nas:\n/volume1/media 192.168.1.0/24\n"},
+			map[string]string{config.KeyNFSServer: "nas", config.KeyNFSExpectedExports: "/volume1/media", config.KeySelectedServices: "media"}, ""},
+		{"export not shared with the remote host", fakeRemote{reachable: online, exports: "Export list for nas:\n/volume1/media 10.0.0.0/24\n"},
+			map[string]string{config.KeyNFSServer: "nas", config.KeyNFSExpectedExports: "/volume1/media"}, "not exported to this host"},
+		{"NFSv4-only server", fakeRemote{reachable: online, nfsPortOK: true},
+			map[string]string{config.KeyNFSServer: "nas"}, ""},
+		{"offline", fakeRemote{reachable: map[string]bool{}}, map[string]string{}, "no internet connectivity"},
+		{"port conflict is only a warning", fakeRemote{reachable: online, listeners: map[int]string{32400: "plexmediaserver (pid 7)"}},
+			map[string]string{config.KeySelectedServices: "media"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := tt.remote
+			newRemoteProbe = func(system.SSHTarget) remoteProbe { return &remote }
+
+			var out bytes.Buffer
+			err := RunRemotePreflight(context.Background(), newConfig(t, tt.values), ui.NewWithWriter(&out), "core@mini1")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("RunRemotePreflight() error = %v\n%s", err, out.String())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(out.String()+err.Error(), tt.wantErr) {
+				t.Errorf("RunRemotePreflight() error = %v, want %q\n%s", err, tt.wantErr, out.String())
+			}
+		})
+	}
+
+	if err := RunRemotePreflight(context.Background(), newConfig(t, map[string]string{}), ui.NewWithWriter(&bytes.Buffer{}), "core@"); err == nil {
+		t.Error("RunRemotePreflight() accepted an invalid target")
+	}
+}
@@ -0,0 +1,487 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// TestPingWithRetriesStopsOnCancel verifies cancellation interrupts the retry loop promptly
+func TestPingWithRetriesStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	unreachable := func(ctx context.Context, host string) (bool, error) {
+		attempts++
+		if attempts == 2 {
+			cancel()
+		}
+		return false, nil
+	}
+
+	start := time.Now()
+	reachable, err := pingWithRetries(ctx, unreachable, "192.0.2.1", 100, 10*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("pingWithRetries() error = %v, want context.Canceled", err)
+	}
+	if reachable {
+		t.Error("pingWithRetries() reported reachable after cancellation")
+	}
+	if attempts != 2 {
+		t.Errorf("pingWithRetries() made %d attempts after cancel, want 2", attempts)
+	}
+	if elapsed > time.Second {
+		t.Errorf("pingWithRetries() took %v to stop, want prompt return", elapsed)
+	}
+}
+
+// TestPingWithRetriesSucceeds verifies the loop stops at the first reply
+func TestPingWithRetriesSucceeds(t *testing.T) {
+	attempts := 0
+	replyOnThird := func(ctx context.Context, host string) (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	}
+
+	reachable, err := pingWithRetries(context.Background(), replyOnThird, "192.0.2.1", 5, time.Millisecond)
+	if err != nil {
+		t.Fatalf("pingWithRetries() error = %v", err)
+	}
+	if !reachable || attempts != 3 {
+		t.Errorf("pingWithRetries() = %v after %d attempts, want true after 3", reachable, attempts)
+	}
+}
+
+func TestMediaSelected(t *testing.T) {
+	tests := []struct {
+		selected string
+		want     bool
+	}{
+		{"", false},
+		{"web cloud", false},
+		{"media", true},
+		{"web media", true},
+	}
+
+	for _, tt := range tests {
+		cfg := config.New(filepath.Join(t.TempDir(), "config"))
+		if tt.selected != "" {
+			if err := cfg.Set(config.KeySelectedServices, tt.selected); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+		if got := mediaSelected(cfg); got != tt.want {
+			t.Errorf("mediaSelected(%q) = %v, want %v", tt.selected, got, tt.want)
+		}
+	}
+}
+
+func TestClockSkewThreshold(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := clockSkewThreshold(cfg); got != 5*time.Second {
+		t.Errorf("clockSkewThreshold() default = %v, want 5s", got)
+	}
+
+	for value, want := range map[string]time.Duration{
+		"0.5":  500 * time.Millisecond,
+		"30":   30 * time.Second,
+		"0":    defaultClockSkewThreshold,
+		"soon": defaultClockSkewThreshold,
+	} {
+		if err := cfg.Set(config.KeyClockSkewThreshold, value); err != nil {
+			t.Fatal(err)
+		}
+		if got := clockSkewThreshold(cfg); got != want {
+			t.Errorf("clockSkewThreshold(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestDescribeClockOffset(t *testing.T) {
+	tests := map[time.Duration]string{
+		1250 * time.Millisecond: "1.25s ahead",
+		-90 * time.Second:       "1m30s behind",
+		200 * time.Microsecond:  "less than 1ms off",
+	}
+	for offset, want := range tests {
+		if got := describeClockOffset(offset); got != want {
+			t.Errorf("describeClockOffset(%v) = %q, want %q", offset, got, want)
+		}
+	}
+}
+
+func TestCheckRunningUser(t *testing.T) {
+	orig := currentIdentity
+	t.Cleanup(func() { currentIdentity = orig })
+	t.Setenv("SUDO_USER", "")
+
+	tests := []struct {
+		name        string
+		euid        int
+		username    string
+		homelabUser string
+		wantErr     string
+	}{
+		{"root", 0, "root", "core", "running as root"},
+		{"mismatch", 1000, "alice", "core", "HOMELAB_USER is core"},
+		{"expected user", 1000, "core", "core", ""},
+		{"unconfigured", 1000, "alice", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New(filepath.Join(t.TempDir(), "config"))
+			if tt.homelabUser != "" {
+				if err := cfg.Set(config.KeyHomelabUser, tt.homelabUser); err != nil {
+					t.Fatal(err)
+				}
+			}
+			currentIdentity = func() (int, string, error) { return tt.euid, tt.username, nil }
+
+			err := checkRunningUser(cfg, ui.NewWithWriter(&bytes.Buffer{}))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkRunningUser() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkRunningUser() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeGroups replaces the group lookups with an in-memory group database
+// and login session
+func fakeGroups(t *testing.T, database map[string][]string, session []string) *[]string {
+	t.Helper()
+	origExists, origMember, origSession, origAdd := groupExists, isUserInGroup, currentProcessGroups, addUserToGroup
+	t.Cleanup(func() {
+		groupExists, isUserInGroup, currentProcessGroups, addUserToGroup = origExists, origMember, origSession, origAdd
+	})
+
+	var added []string
+	groupExists = func(group string) (bool, error) {
+		_, ok := database[group]
+		return ok, nil
+	}
+	isUserInGroup = func(username, group string) (bool, error) {
+		for _, member := range database[group] {
+			if member == username {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	currentProcessGroups = func() ([]string, error) { return session, nil }
+	addUserToGroup = func(username, group string) error {
+		added = append(added, username+":"+group)
+		database[group] = append(database[group], username)
+		return nil
+	}
+	return &added
+}
+
+func TestRuntimeGroupMembership(t *testing.T) {
+	tests := []struct {
+		name          string
+		database      map[string][]string
+		session       []string
+		username      string
+		isCurrentUser bool
+		want          groupMembership
+	}{
+		{"no docker group", map[string][]string{"wheel": {"core"}}, nil, "core", true, groupMissing},
+		{"not a member", map[string][]string{"docker": {"alice"}}, nil, "core", true, groupNotMember},
+		{"member in session", map[string][]string{"docker": {"core"}}, []string{"core", "docker"}, "core", true, groupMember},
+		{"added since login", map[string][]string{"docker": {"core"}}, []string{"core", "wheel"}, "core", true, groupPendingLogin},
+		// The session of another user cannot be inspected
+		{"other user", map[string][]string{"docker": {"svc"}}, []string{"core"}, "svc", false, groupMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGroups(t, tt.database, tt.session)
+			got, err := runtimeGroupMembership(tt.username, "docker", tt.isCurrentUser)
+			if err != nil {
+				t.Fatalf("runtimeGroupMembership() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("runtimeGroupMembership() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRuntimeGroup(t *testing.T) {
+	orig := currentIdentity
+	t.Cleanup(func() { currentIdentity = orig })
+	currentIdentity = func() (int, string, error) { return 1000, "core", nil }
+
+	newConfig := func(t *testing.T, runtime string) *config.Config {
+		cfg := config.New(filepath.Join(t.TempDir(), "config"))
+		if err := cfg.Set(config.KeyContainerRuntime, runtime); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	t.Run("already a member", func(t *testing.T) {
+		added := fakeGroups(t, map[string][]string{"docker": {"core"}}, []string{"docker"})
+		if err := checkRuntimeGroup(newConfig(t, "docker"), ui.NewWithWriter(&bytes.Buffer{})); err != nil {
+			t.Errorf("checkRuntimeGroup() error = %v", err)
+		}
+		if len(*added) != 0 {
+			t.Errorf("checkRuntimeGroup() added %v for an existing member", *added)
+		}
+	})
+
+	t.Run("not a member, declined by default", func(t *testing.T) {
+		added := fakeGroups(t, map[string][]string{"docker": nil}, []string{"core"})
+		var buf bytes.Buffer
+		u := ui.NewWithWriter(&buf)
+		u.SetNonInteractive(true)
+		err := checkRuntimeGroup(newConfig(t, "docker"), u)
+		if err == nil || !strings.Contains(err.Error(), "is not in the docker group") {
+			t.Errorf("checkRuntimeGroup() error = %v, want a missing membership error", err)
+		}
+		if len(*added) != 0 {
+			t.Errorf("checkRuntimeGroup() added %v without confirmation", *added)
+		}
+		if !strings.Contains(buf.String(), "sudo usermod -aG docker core") {
+			t.Errorf("output missing the usermod hint:\n%s", buf.String())
+		}
+	})
+
+	t.Run("added, needs re-login", func(t *testing.T) {
+		// The membership exists but not yet in this session
+		added := fakeGroups(t, map[string][]string{"docker": {"core"}}, []string{"core"})
+		err := checkRuntimeGroup(newConfig(t, "docker"), ui.NewWithWriter(&bytes.Buffer{}))
+		if err == nil || !strings.Contains(err.Error(), "not active in this session") {
+			t.Errorf("checkRuntimeGroup() error = %v, want a pending-login error", err)
+		}
+		if len(*added) != 0 {
+			t.Errorf("checkRuntimeGroup() added the user again: %v", *added)
+		}
+	})
+
+	t.Run("podman needs no group", func(t *testing.T) {
+		added := fakeGroups(t, map[string][]string{}, nil)
+		if err := checkRuntimeGroup(newConfig(t, "podman"), ui.NewWithWriter(&bytes.Buffer{})); err != nil || len(*added) != 0 {
+			t.Errorf("checkRuntimeGroup() = %v, added %v", err, *added)
+		}
+	})
+}
+
+func TestRunPreflightListSkips(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	failing := func(context.Context, *config.Config, *ui.UI) error { return errors.New("no internet connectivity") }
+	ran := map[string]bool{}
+	checks := []preflightCheck{
+		{name: "network", title: "Checking Network Connectivity", fatal: true, run: func(ctx context.Context, cfg *config.Config, u *ui.UI) error {
+			ran["network"] = true
+			return failing(ctx, cfg, u)
+		}},
+		{name: "sudo", title: "Checking Sudo Access", fatal: true, run: failing},
+	}
+
+	var buf bytes.Buffer
+	messages, skipped, err := runPreflightList(context.Background(), cfg, ui.NewWithWriter(&buf), checks, map[string]bool{"network": true})
+	if err != nil {
+		t.Fatalf("runPreflightList() error = %v", err)
+	}
+	if ran["network"] {
+		t.Error("runPreflightList() ran a skipped check")
+	}
+	if skipped != 1 || len(messages) != 1 {
+		t.Errorf("runPreflightList() = %v, %d skipped; want only the sudo failure and 1 skipped", messages, skipped)
+	}
+	if !strings.Contains(buf.String(), "Skipped") {
+		t.Errorf("skipped check not reported:\n%s", buf.String())
+	}
+}
+
+func TestPreflightSkips(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeyPreflightSkip, "network, NFS"); err != nil {
+		t.Fatal(err)
+	}
+
+	skips, err := preflightSkips(cfg, []string{"time"})
+	if err != nil {
+		t.Fatalf("preflightSkips() error = %v", err)
+	}
+	for _, name := range []string{"network", "nfs", "time"} {
+		if !skips[name] {
+			t.Errorf("preflightSkips() missing %q: %v", name, skips)
+		}
+	}
+
+	if _, err := preflightSkips(cfg, []string{"firewall"}); err == nil {
+		t.Error("preflightSkips() accepted an unknown check name")
+	}
+}
+
+// fakeServices is a ServiceManager whose unit starts only if startWorks
+type fakeServices struct {
+	installed, active, startWorks bool
+	calls                         []string
+}
+
+func (f *fakeServices) Exists(string) (bool, error)   { return f.installed, nil }
+func (f *fakeServices) IsActive(string) (bool, error) { return f.active, nil }
+func (f *fakeServices) Enable(unit string) error {
+	f.calls = append(f.calls, "enable "+unit)
+	return nil
+}
+func (f *fakeServices) Start(unit string) error {
+	f.calls = append(f.calls, "start "+unit)
+	if !f.startWorks {
+		return errors.New("job for docker.service failed")
+	}
+	f.active = true
+	return nil
+}
+
+func TestEnsureDockerService(t *testing.T) {
+	origServices, origSudo := preflightServices, validateSudo
+	t.Cleanup(func() { preflightServices, validateSudo = origServices, origSudo })
+	validateSudo = func() error { return nil }
+
+	tests := []struct {
+		name      string
+		mode      string
+		services  fakeServices
+		wantErr   string
+		wantCalls []string
+	}{
+		{"running", "rootful", fakeServices{installed: true, active: true}, "", nil},
+		{"not installed", "rootful", fakeServices{}, "not installed", nil},
+		{"stopped and starts", "rootful", fakeServices{installed: true, startWorks: true}, "", []string{"start docker.service", "enable docker.service"}},
+		{"stopped and fails to start", "rootful", fakeServices{installed: true}, "failed", []string{"start docker.service"}},
+		{"stopped on a rootless host", "rootless", fakeServices{installed: true, startWorks: true}, "not active", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := tt.services
+			preflightServices = &services
+			cfg := config.New(filepath.Join(t.TempDir(), "config"))
+			if err := cfg.Set(config.KeyContainerRuntimeMode, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			u := ui.NewWithWriter(&bytes.Buffer{})
+			// Non-interactive mode accepts the default: start the service
+			u.SetNonInteractive(true)
+
+			err := ensureDockerService(cfg, u)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ensureDockerService() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ensureDockerService() error = %v, want %q", err, tt.wantErr)
+			}
+			if strings.Join(services.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("ensureDockerService() calls = %v, want %v", services.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPrivilegedPorts(t *testing.T) {
+	ports := []publishedPort{
+		{Stack: "media", Container: "plex", HostPort: 32400, Protocol: "tcp"},
+		{Stack: "web", Container: "nginx", HostPort: 80, Protocol: "tcp"},
+		{Stack: "web", Container: "nginx", HostPort: 443, Protocol: "tcp"},
+	}
+
+	low := privilegedPorts(ports, 1024)
+	if len(low) != 2 || low[0].HostPort != 80 || low[1].HostPort != 443 {
+		t.Errorf("privilegedPorts(1024) = %v, want ports 80 and 443", low)
+	}
+	if low := privilegedPorts(ports, 80); len(low) != 0 {
+		t.Errorf("privilegedPorts(80) = %v, want none", low)
+	}
+}
+
+func TestCheckKernelModules(t *testing.T) {
+	orig := kernelModuleState
+	t.Cleanup(func() { kernelModuleState = orig })
+	states := map[string]system.ModuleState{"nfs": system.ModuleLoaded, "wireguard": system.ModuleMissing}
+	kernelModuleState = func(name string) (system.ModuleState, error) { return states[name], nil }
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if modules := requiredKernelModules(cfg); len(modules) != 0 {
+		t.Errorf("requiredKernelModules() without features = %v, want none", modules)
+	}
+
+	if err := cfg.Set(config.KeyNFSServer, "192.168.1.10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkKernelModules(cfg, ui.NewWithWriter(&bytes.Buffer{})); err != nil {
+		t.Errorf("checkKernelModules() with nfs loaded error = %v", err)
+	}
+
+	if err := cfg.Set("WIREGUARD_ENABLED", "true"); err != nil {
+		t.Fatal(err)
+	}
+	err := checkKernelModules(cfg, ui.NewWithWriter(&bytes.Buffer{}))
+	if err == nil || !strings.Contains(err.Error(), "wireguard") || strings.Contains(err.Error(), "nfs") {
+		t.Errorf("checkKernelModules() error = %v, want only wireguard missing", err)
+	}
+}
+
+func TestCheckHostPorts(t *testing.T) {
+	origFree, origOpen, origListener := isPortFree, isPortOpen, portListener
+	t.Cleanup(func() { isPortFree, isPortOpen, portListener = origFree, origOpen, origListener })
+	isPortFree = func(port int) (bool, error) {
+		if port == 80 {
+			return false, errors.New("permission denied")
+		}
+		return port != 32400, nil
+	}
+	isPortOpen = func(_ string, port int, _ int) (bool, error) { return port == 80, nil }
+	portListener = func(port int) string {
+		if port == 32400 {
+			return "plexmediaserver (pid 42)"
+		}
+		return ""
+	}
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if ports := requiredHostPorts(cfg); len(ports) != 0 {
+		t.Errorf("requiredHostPorts() without selected services = %v, want none", ports)
+	}
+	if err := cfg.SetMany(map[string]string{
+		config.KeyContainersBase:   t.TempDir(),
+		config.KeySelectedServices: "web",
+		"OVERSEERR_PORT":           "80",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err := checkHostPorts(cfg, ui.NewWithWriter(&out))
+	if err == nil || !strings.Contains(err.Error(), "80") {
+		t.Errorf("checkHostPorts() error = %v, want privileged port 80 in use", err)
+	}
+
+	if err := cfg.Set(config.KeySelectedServices, "media"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err = checkHostPorts(cfg, ui.NewWithWriter(&out))
+	if err == nil || err.Error() != "host ports already in use: 32400" {
+		t.Errorf("checkHostPorts() error = %v, want only 32400 in use", err)
+	}
+	if !strings.Contains(out.String(), "plexmediaserver (pid 42)") {
+		t.Errorf("output does not name the listening process:\n%s", out.String())
+	}
+}
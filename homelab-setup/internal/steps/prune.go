@@ -0,0 +1,63 @@
+package steps
+
+import (
+	"fmt"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// RunPruneContainerResources lists dangling images and unused anonymous
+// volumes, then removes them after confirmation and reports the space
+// reclaimed. Named volumes are never touched.
+func RunPruneContainerResources(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Prune Unused Container Resources")
+
+	runtime, err := getRuntimeFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := system.ListPruneCandidates(runtime)
+	if err != nil {
+		return err
+	}
+	if candidates.Empty() {
+		ui.Success("Nothing to prune")
+		return nil
+	}
+
+	if len(candidates.Images) > 0 {
+		ui.Info("Dangling images:")
+		for _, image := range candidates.Images {
+			ui.Printf("  - %s", image)
+		}
+	}
+	if len(candidates.Volumes) > 0 {
+		ui.Info("Unused anonymous volumes:")
+		for _, volume := range candidates.Volumes {
+			ui.Printf("  - %s", volume)
+		}
+	}
+	if runtime == system.RuntimeDocker {
+		ui.Info("The Docker build cache will also be cleared")
+	}
+	ui.Print("")
+
+	confirmed, err := ui.PromptYesNo(fmt.Sprintf("Remove %s?", candidates), false)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		ui.Info("Prune cancelled")
+		return nil
+	}
+
+	reclaimed, err := system.PruneContainerResources(runtime, false)
+	if err != nil {
+		return err
+	}
+	ui.Successf("Reclaimed %s", reclaimed)
+	return nil
+}
@@ -0,0 +1,287 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// bootEnabled reports whether a stack's compose unit should start at boot.
+// Stacks start at boot unless BOOT_ENABLED_<STACK> is set to false.
+func bootEnabled(cfg *config.Config, serviceName string) bool {
+	value := cfg.GetOrDefault(config.KeyBootEnabledPrefix+strings.ToUpper(serviceName), "true")
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return true
+	}
+	return enabled
+}
+
+// EnableService makes a stack's compose unit start at boot and records the
+// choice in config
+func EnableService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	serviceInfo := getServiceInfo(cfg, serviceName)
+	if err := system.EnableService(serviceInfo.UnitName); err != nil {
+		return err
+	}
+	if err := cfg.Set(config.KeyBootEnabledPrefix+strings.ToUpper(serviceName), "true"); err != nil {
+		return fmt.Errorf("failed to save boot setting: %w", err)
+	}
+	ui.Successf("%s will start at boot", serviceInfo.UnitName)
+	return nil
+}
+
+// DisableService stops a stack's compose unit from starting at boot and
+// records the choice in config. A running stack is left running.
+func DisableService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	serviceInfo := getServiceInfo(cfg, serviceName)
+	if err := system.DisableService(serviceInfo.UnitName); err != nil {
+		return err
+	}
+	if err := cfg.Set(config.KeyBootEnabledPrefix+strings.ToUpper(serviceName), "false"); err != nil {
+		return fmt.Errorf("failed to save boot setting: %w", err)
+	}
+	ui.Successf("%s will no longer start at boot (still running until stopped)", serviceInfo.UnitName)
+	return nil
+}
+
+// RunServiceBootSettings shows whether each deployed stack starts at boot and
+// lets the user toggle one
+func RunServiceBootSettings(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Start Services at Boot")
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	options := make([]string, len(selectedServices))
+	for i, service := range selectedServices {
+		unitName := getServiceInfo(cfg, service).UnitName
+		state := "disabled"
+		if enabled, err := system.IsServiceEnabled(unitName); err != nil {
+			state = "unknown"
+		} else if enabled {
+			state = "enabled"
+		}
+		options[i] = fmt.Sprintf("%s (%s at boot)", unitName, state)
+	}
+
+	index, err := ui.PromptSelect("Select service", options)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for service: %w", err)
+	}
+	serviceName := selectedServices[index]
+
+	enable, err := ui.PromptYesNo(fmt.Sprintf("Start %s at boot?", getServiceInfo(cfg, serviceName).DisplayName), bootEnabled(cfg, serviceName))
+	if err != nil {
+		return err
+	}
+	if enable {
+		return EnableService(cfg, ui, serviceName)
+	}
+	return DisableService(cfg, ui, serviceName)
+}
+
+// serviceSettleTimeout bounds how long a control action waits for the unit to
+// leave a transitional state
+var serviceSettleTimeout = 2 * time.Minute
+
+// serviceSettlePoll is the interval between unit state checks while settling
+var serviceSettlePoll = time.Second
+
+// serviceAction is a lifecycle operation on a stack's compose unit
+type serviceAction struct {
+	name      string // verb shown in menus and messages
+	run       func(unitName string) error
+	wantState string // ActiveState expected once the unit settles
+}
+
+var (
+	actionStart   = serviceAction{"start", system.StartService, "active"}
+	actionStop    = serviceAction{"stop", system.StopService, "inactive"}
+	actionRestart = serviceAction{"restart", system.RestartService, "active"}
+)
+
+// apply runs the action on one stack (see controlService)
+func (a serviceAction) apply(_ context.Context, cfg *config.Config, ui *ui.UI, serviceName string) error {
+	return controlService(cfg, ui, serviceName, a)
+}
+
+// waitForUnitSettled polls the unit until it leaves the activating,
+// deactivating and reloading states, returning the final state
+func waitForUnitSettled(unitName string) (string, error) {
+	deadline := time.Now().Add(serviceSettleTimeout)
+	for {
+		state, err := system.ServiceActiveState(unitName)
+		if err != nil {
+			return "", err
+		}
+		switch state {
+		case "activating", "deactivating", "reloading":
+		default:
+			return state, nil
+		}
+		if time.Now().After(deadline) {
+			return state, fmt.Errorf("%s still %s after %s", unitName, state, serviceSettleTimeout)
+		}
+		time.Sleep(serviceSettlePoll)
+	}
+}
+
+// controlService runs action on one stack's systemd unit, waits for the unit
+// to settle and reports the resulting state
+func controlService(cfg *config.Config, ui *ui.UI, serviceName string, action serviceAction) error {
+	serviceInfo := getServiceInfo(cfg, serviceName)
+	ui.Infof("Running %s on %s...", action.name, serviceInfo.UnitName)
+
+	if err := action.run(serviceInfo.UnitName); err != nil {
+		ui.Errorf("%s %s failed to %s", ui.GlyphFail(), serviceInfo.UnitName, action.name)
+		ui.Infof("Check the logs with: sudo journalctl -u %s -n 50", serviceInfo.UnitName)
+		return err
+	}
+
+	state, err := waitForUnitSettled(serviceInfo.UnitName)
+	if err != nil {
+		ui.Errorf("%s %s: %v", ui.GlyphFail(), serviceInfo.UnitName, err)
+		return err
+	}
+	if state != action.wantState {
+		ui.Errorf("%s %s is %s after %s (expected %s)", ui.GlyphFail(), serviceInfo.UnitName, state, action.name, action.wantState)
+		ui.Infof("Check the logs with: sudo journalctl -u %s -n 50", serviceInfo.UnitName)
+		return fmt.Errorf("%s is %s after %s", serviceInfo.UnitName, state, action.name)
+	}
+
+	ui.Successf("%s %s is %s", ui.GlyphOK(), serviceInfo.UnitName, state)
+	return nil
+}
+
+// controlServices runs action on each stack, BULK_CONCURRENCY at a time,
+// continuing past failures, and returns an error naming every stack that
+// failed
+func controlServices(cfg *config.Config, ui *ui.UI, serviceNames []string, action serviceAction) error {
+	errs := forEachService(context.Background(), cfg, ui, serviceNames, action.apply)
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, serviceNames[i])
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to %s: %s", action.name, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// StartService starts a stack's compose unit
+func StartService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	return controlService(cfg, ui, serviceName, actionStart)
+}
+
+// StopService stops a stack's compose unit, bringing its containers down
+func StopService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	return controlService(cfg, ui, serviceName, actionStop)
+}
+
+// RestartService restarts a stack's compose unit and waits for it to settle
+func RestartService(cfg *config.Config, ui *ui.UI, serviceName string) error {
+	return controlService(cfg, ui, serviceName, actionRestart)
+}
+
+// StartAllServices starts every selected stack
+func StartAllServices(cfg *config.Config, ui *ui.UI) error {
+	return controlSelectedServices(cfg, ui, actionStart)
+}
+
+// StopAllServices stops every selected stack after the user confirms
+func StopAllServices(cfg *config.Config, ui *ui.UI) error {
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+	return stopServicesConfirmed(cfg, ui, selectedServices)
+}
+
+// stopServicesConfirmed asks before stopping several stacks at once, since
+// that takes every web UI offline, and defaults to no
+func stopServicesConfirmed(cfg *config.Config, ui *ui.UI, serviceNames []string) error {
+	stop, err := ui.PromptYesNo(fmt.Sprintf("Stop all %d stacks (%s)?", len(serviceNames), strings.Join(serviceNames, ", ")), false)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !stop {
+		ui.Info("Stop cancelled; services left running")
+		return nil
+	}
+	return controlServices(cfg, ui, serviceNames, actionStop)
+}
+
+// RestartAllServices restarts every selected stack
+func RestartAllServices(cfg *config.Config, ui *ui.UI) error {
+	return controlSelectedServices(cfg, ui, actionRestart)
+}
+
+// controlSelectedServices runs action on every stack in SELECTED_SERVICES
+func controlSelectedServices(cfg *config.Config, ui *ui.UI, action serviceAction) error {
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+	return controlServices(cfg, ui, selectedServices, action)
+}
+
+// RunServiceControl lets the user pick a stack (or all of them) and a
+// start/stop/restart action, then reports the resulting unit state
+func RunServiceControl(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Service Control")
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	options := make([]string, 0, len(selectedServices)+1)
+	for _, service := range selectedServices {
+		unitName := getServiceInfo(cfg, service).UnitName
+		state, err := system.ServiceActiveState(unitName)
+		if err != nil {
+			state = "unknown"
+		}
+		options = append(options, fmt.Sprintf("%s (%s)", unitName, state))
+	}
+	options = append(options, "All services")
+
+	serviceIndex, err := ui.PromptSelect("Select service", options)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for service: %w", err)
+	}
+
+	actions := []serviceAction{actionStart, actionStop, actionRestart}
+	actionNames := make([]string, len(actions))
+	for i, action := range actions {
+		actionNames[i] = cases.Title(language.English).String(action.name)
+	}
+	actionIndex, err := ui.PromptSelect("Select action", actionNames)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for action: %w", err)
+	}
+	action := actions[actionIndex]
+
+	ui.Print("")
+	if serviceIndex == len(selectedServices) {
+		if action.name == actionStop.name {
+			return stopServicesConfirmed(cfg, ui, selectedServices)
+		}
+		return controlServices(cfg, ui, selectedServices, action)
+	}
+	return controlService(cfg, ui, selectedServices[serviceIndex], action)
+}
@@ -0,0 +1,40 @@
+package steps
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+func TestStopAllServicesDefaultsToNo(t *testing.T) {
+	orig := actionStop
+	t.Cleanup(func() { actionStop = orig })
+	var stopped []string
+	actionStop.run = func(unitName string) error {
+		stopped = append(stopped, unitName)
+		return nil
+	}
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeySelectedServices, "media web"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	u := ui.NewWithWriter(&buf)
+	u.SetNonInteractive(true)
+
+	if err := StopAllServices(cfg, u); err != nil {
+		t.Fatalf("StopAllServices() error = %v", err)
+	}
+	if len(stopped) != 0 {
+		t.Errorf("StopAllServices() stopped %v without confirmation", stopped)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "Stop all 2 stacks (media, web)? -> false") || !strings.Contains(out, "services left running") {
+		t.Errorf("output = %q, want the declined confirmation", out)
+	}
+}
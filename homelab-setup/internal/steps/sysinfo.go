@@ -0,0 +1,84 @@
+package steps
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// System info report formats
+const (
+	SystemInfoText = "text"
+	SystemInfoJSON = "json"
+)
+
+// collectSystemInfo is the host probe used by CollectDiagnostics, overridable
+// in tests
+var collectSystemInfo = system.CollectSystemInfo
+
+// CollectDiagnostics returns the host details together with the selected
+// services, completed steps and configuration. Secret values are redacted.
+func CollectDiagnostics(cfg *config.Config) (*system.SystemInfo, error) {
+	info, err := collectSystemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	info.SelectedServices = SelectedServices(cfg)
+
+	markers, err := cfg.ListMarkers()
+	if err != nil {
+		info.Errors = append(info.Errors, err.Error())
+	}
+	sort.Strings(markers)
+	info.CompletedSteps = markers
+
+	info.Config = cfg.GetAllRedacted()
+	return info, nil
+}
+
+// FormatSystemInfo renders info in the given format (SystemInfoText or
+// SystemInfoJSON)
+func FormatSystemInfo(info *system.SystemInfo, format string) (string, error) {
+	switch format {
+	case SystemInfoText:
+		return info.Text(), nil
+	case SystemInfoJSON:
+		return info.JSON()
+	default:
+		return "", fmt.Errorf("unknown system info format %q (want %s or %s)", format, SystemInfoText, SystemInfoJSON)
+	}
+}
+
+// RunSystemInfo prints the system info report in a format chosen by the user
+func RunSystemInfo(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("System Info Report")
+	ui.Info("Collects environment details for bug reports; secrets are redacted")
+	ui.Print("")
+
+	formats := []string{SystemInfoText, SystemInfoJSON}
+	index, err := ui.PromptSelect("Output format", []string{"Text report", "JSON"})
+	if err != nil {
+		return fmt.Errorf("failed to prompt for format: %w", err)
+	}
+
+	spinner := ui.Spinner("Collecting system information...")
+	spinner.Start()
+	info, err := CollectDiagnostics(cfg)
+	if err != nil {
+		spinner.Fail("Failed to collect system information")
+		return err
+	}
+	spinner.Success("Collected system information")
+
+	report, err := FormatSystemInfo(info, formats[index])
+	if err != nil {
+		return err
+	}
+	ui.Print("")
+	ui.Print(report)
+	return nil
+}
@@ -0,0 +1,58 @@
+package steps
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+)
+
+func TestCollectDiagnostics(t *testing.T) {
+	orig := collectSystemInfo
+	t.Cleanup(func() { collectSystemInfo = orig })
+	collectSystemInfo = func() (*system.SystemInfo, error) {
+		return &system.SystemInfo{Hostname: "minipc"}, nil
+	}
+
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if err := cfg.Set(config.KeySelectedServices, "media web"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set("WIREGUARD_PRIVATE_KEY", "c2VjcmV0LWtleS1tYXRlcmlhbC1ub3QtZm9yLXJlcG9ydHM="); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	for _, marker := range []string{"user-setup-complete", "directory-setup-complete"} {
+		if err := cfg.MarkComplete(marker); err != nil {
+			t.Fatalf("MarkComplete failed: %v", err)
+		}
+	}
+
+	info, err := CollectDiagnostics(cfg)
+	if err != nil {
+		t.Fatalf("CollectDiagnostics() error = %v", err)
+	}
+	if want := []string{"media", "web"}; !reflect.DeepEqual(info.SelectedServices, want) {
+		t.Errorf("SelectedServices = %v, want %v", info.SelectedServices, want)
+	}
+	if want := []string{"directory-setup-complete", "user-setup-complete"}; !reflect.DeepEqual(info.CompletedSteps, want) {
+		t.Errorf("CompletedSteps = %v, want %v", info.CompletedSteps, want)
+	}
+
+	out, err := FormatSystemInfo(info, SystemInfoJSON)
+	if err != nil {
+		t.Fatalf("FormatSystemInfo() error = %v", err)
+	}
+	if strings.Contains(out, "c2VjcmV0") {
+		t.Errorf("report leaks the private key:\n%s", out)
+	}
+	if !strings.Contains(out, `"hostname": "minipc"`) {
+		t.Errorf("report missing hostname:\n%s", out)
+	}
+
+	if _, err := FormatSystemInfo(info, "yaml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,16 @@
+package steps
+
+import (
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// timeOperation starts timing a sub-operation and returns a function that
+// prints its duration at verbose level
+func timeOperation(ui *ui.UI, name string) func() {
+	start := time.Now()
+	return func() {
+		ui.Debugf("%s took %s", name, time.Since(start).Round(time.Millisecond))
+	}
+}
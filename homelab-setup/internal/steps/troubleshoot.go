@@ -0,0 +1,356 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// splitAddressFamilies separates CIDR addresses into IPv4 and IPv6 lists
+func splitAddressFamilies(addresses []string) (v4, v6 []string) {
+	for _, addr := range addresses {
+		ip, _, err := net.ParseCIDR(addr)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+	return v4, v6
+}
+
+// RunInterfacesAndRoutes prints interfaces, their addresses, MTU and link state,
+// followed by the default routes for each address family. Read-only.
+func RunInterfacesAndRoutes(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Interfaces & Routes")
+
+	interfaces, err := system.ListInterfaces()
+	if err != nil {
+		return err
+	}
+
+	// The Defaults table always supplies an interface name, so only use it
+	// once WireGuard has been set up
+	var wgInterface string
+	if cfg.Exists(config.KeyWGInterface) || cfg.GetOrDefault("WIREGUARD_ENABLED", "") == "true" {
+		wgInterface = cfg.GetOrDefault(config.KeyWGInterface, "wg0")
+	}
+
+	ui.Step("Interfaces")
+	for _, iface := range interfaces {
+		state := "DOWN"
+		if iface.Up {
+			state = "UP"
+		}
+
+		line := fmt.Sprintf("%s  [%s]  mtu %d", iface.Name, state, iface.MTU)
+		switch {
+		case iface.Name == wgInterface:
+			ui.Successf("%s  (WireGuard)", line)
+		case iface.Up:
+			ui.Info(line)
+		default:
+			ui.Warning(line)
+		}
+
+		v4, v6 := splitAddressFamilies(iface.Addresses)
+		if len(v4) > 0 {
+			ui.Printf("    IPv4: %s", strings.Join(v4, ", "))
+		}
+		if len(v6) > 0 {
+			ui.Printf("    IPv6: %s", strings.Join(v6, ", "))
+		}
+		if len(v4) == 0 && len(v6) == 0 {
+			ui.Print("    (no addresses)")
+		}
+	}
+
+	if wgInterface != "" {
+		found := false
+		for _, iface := range interfaces {
+			if iface.Name == wgInterface {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ui.Warningf("WireGuard interface %s is configured but not present", wgInterface)
+		}
+	}
+
+	ui.Step("Default Routes")
+	for _, family := range []string{"4", "6"} {
+		routes, err := system.GetDefaultRoutes(family)
+		if err != nil {
+			ui.Warningf("IPv%s: %v", family, err)
+			continue
+		}
+		if len(routes) == 0 {
+			ui.Infof("IPv%s: no default route", family)
+			continue
+		}
+		for _, route := range routes {
+			line := fmt.Sprintf("IPv%s: default", family)
+			if route.Gateway != "" {
+				line += " via " + route.Gateway
+			}
+			if route.Device != "" {
+				line += " dev " + route.Device
+			}
+			if route.Metric != "" {
+				line += " metric " + route.Metric
+			}
+			if route.Device != "" && route.Device == wgInterface {
+				ui.Successf("%s  (WireGuard)", line)
+			} else {
+				ui.Info(line)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matrixTimeoutSeconds bounds each ping and TCP probe in the connectivity matrix
+const matrixTimeoutSeconds = 2
+
+// matrixPorts are the TCP ports shown as columns in the connectivity matrix
+var matrixPorts = []int{22, 53, 111, 443, 2049}
+
+// matrixTarget is a host probed by the connectivity matrix
+type matrixTarget struct {
+	Name  string
+	Host  string
+	Ports []int // TCP ports relevant to this target; others are shown as "-"
+}
+
+// matrixResult holds the probe outcomes for one target
+type matrixResult struct {
+	ICMP bool
+	TCP  map[int]bool
+}
+
+// connectivityTargets collects the gateway, file server, VPS and DNS servers
+// from the system and config. Targets that cannot be determined are reported
+// in skipped rather than probed.
+func connectivityTargets(cfg *config.Config) (targets []matrixTarget, skipped []string) {
+	if gateway, err := system.GetDefaultGateway(); err == nil {
+		targets = append(targets, matrixTarget{"Gateway", gateway, []int{53, 443}})
+	} else {
+		skipped = append(skipped, fmt.Sprintf("Gateway: %v", err))
+	}
+
+	if nfsServer := cfg.GetOrDefault(config.KeyNFSServer, ""); nfsServer != "" {
+		targets = append(targets, matrixTarget{"File server", nfsServer, []int{22, 111, 2049}})
+	} else {
+		skipped = append(skipped, fmt.Sprintf("File server: %s not set", config.KeyNFSServer))
+	}
+
+	if vps := cfg.GetOrDefault(config.KeyVPSHost, ""); vps != "" {
+		targets = append(targets, matrixTarget{"VPS", vps, []int{22, 443}})
+	} else {
+		skipped = append(skipped, fmt.Sprintf("VPS: %s not set", config.KeyVPSHost))
+	}
+
+	nameservers, err := system.GetNameservers()
+	if err != nil {
+		skipped = append(skipped, fmt.Sprintf("DNS: %v", err))
+	}
+	for i, ns := range nameservers {
+		name := "DNS"
+		if len(nameservers) > 1 {
+			name = fmt.Sprintf("DNS %d", i+1)
+		}
+		targets = append(targets, matrixTarget{name, ns, []int{53}})
+	}
+
+	return targets, skipped
+}
+
+// probeTargets pings and dials every target concurrently
+func probeTargets(targets []matrixTarget, source string) []matrixResult {
+	results := make([]matrixResult, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		results[i].TCP = make(map[int]bool)
+
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			ok, _ := system.TestConnectivityFromContext(context.Background(), host, source, matrixTimeoutSeconds)
+			mu.Lock()
+			results[i].ICMP = ok
+			mu.Unlock()
+		}(i, target.Host)
+
+		for _, port := range target.Ports {
+			wg.Add(1)
+			go func(i int, host string, port int) {
+				defer wg.Done()
+				open, _ := system.IsPortOpen(host, port, matrixTimeoutSeconds)
+				mu.Lock()
+				results[i].TCP[port] = open
+				mu.Unlock()
+			}(i, target.Host, port)
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// matrixRows renders probe results as table rows: target, host, ICMP, then one
+// cell per matrixPorts entry
+func matrixRows(targets []matrixTarget, results []matrixResult) [][]string {
+	rows := make([][]string, 0, len(targets))
+	for i, target := range targets {
+		row := []string{target.Name, target.Host, ui.StatusGlyph(results[i].ICMP)}
+		for _, port := range matrixPorts {
+			if open, probed := results[i].TCP[port]; probed {
+				row = append(row, ui.StatusGlyph(open))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// RunConnectivityMatrix probes ICMP and key TCP ports on the gateway, file
+// server, VPS and DNS servers and prints the results as a grid. Read-only.
+func RunConnectivityMatrix(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Connectivity Matrix")
+
+	targets, skipped := connectivityTargets(cfg)
+	for _, reason := range skipped {
+		ui.Infof("Skipping %s", reason)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no connectivity targets could be determined")
+	}
+
+	source := cfg.GetOrDefault(config.KeyNetworkSource, "")
+	if source != "" {
+		ui.Infof("Sending pings from %s", source)
+	}
+	ui.Infof("Probing %d target(s) (timeout %ds per check)...", len(targets), matrixTimeoutSeconds)
+	ui.Print("")
+
+	results := probeTargets(targets, source)
+
+	headers := []string{"Target", "Host", "ICMP"}
+	for _, port := range matrixPorts {
+		headers = append(headers, "TCP/"+strconv.Itoa(port))
+	}
+	ui.Table(headers, matrixRows(targets, results))
+	ui.Print("")
+	ui.Infof("%s reachable, %s no response, - not checked for this target", ui.GlyphOK(), ui.GlyphFail())
+	ui.Info("Some hosts drop ICMP while still accepting TCP connections")
+
+	return nil
+}
+
+// wireGuardStaleHandshake is how old a peer's last handshake may be before the
+// peer is flagged as likely down. Active peers re-handshake every two minutes.
+const wireGuardStaleHandshake = 3 * time.Minute
+
+// handshakeAge describes how long ago a handshake happened and whether it is stale
+func handshakeAge(handshake, now time.Time) (desc string, stale bool) {
+	if handshake.IsZero() {
+		return "never", true
+	}
+	age := now.Sub(handshake).Round(time.Second)
+	return fmt.Sprintf("%s ago", age), age > wireGuardStaleHandshake
+}
+
+// wireGuardPeerNames maps peer public keys to the names recorded in the
+// "# Peer:" comments of the interface config. Best-effort: returns an empty
+// map if the config cannot be read.
+func wireGuardPeerNames(cfg *config.Config, interfaceName string) map[string]string {
+	names := make(map[string]string)
+	raw, err := system.ReadFile(filepath.Join(configDir(cfg), interfaceName+".conf"))
+	if err != nil {
+		return names
+	}
+	for _, peer := range parseWireGuardConfig(string(raw)).Peers {
+		if key, ok := lookupPeerValue(peer.Values, "PublicKey"); ok && peer.Comment != "" {
+			names[key] = peer.Comment
+		}
+	}
+	return names
+}
+
+// RunWireGuardHealth reports each peer's endpoint, last handshake age and
+// transfer counters, flagging peers with stale handshakes. Read-only.
+func RunWireGuardHealth(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("WireGuard Health")
+
+	interfaceName := cfg.GetOrDefault(config.KeyWGInterface, "wg0")
+	if _, err := net.InterfaceByName(interfaceName); err != nil {
+		ui.Infof("WireGuard interface %s is not present; nothing to check", interfaceName)
+		return nil
+	}
+
+	peers, err := system.GetWireGuardPeerStatus(interfaceName)
+	if err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		ui.Warningf("Interface %s has no peers configured", interfaceName)
+		return nil
+	}
+
+	names := wireGuardPeerNames(cfg, interfaceName)
+	now := time.Now()
+	rows := make([][]string, 0, len(peers))
+	var stalePeers []string
+	for _, peer := range peers {
+		name := names[peer.PublicKey]
+		if name == "" {
+			name = peer.PublicKey
+			if len(name) > 12 {
+				name = name[:12] + "..."
+			}
+		}
+		endpoint := peer.Endpoint
+		if endpoint == "" {
+			endpoint = "(none)"
+		}
+
+		age, stale := handshakeAge(peer.LatestHandshake, now)
+		status := "OK"
+		if stale {
+			status = "STALE"
+			stalePeers = append(stalePeers, name)
+		}
+		rows = append(rows, []string{name, endpoint, age, system.FormatBytes(peer.RxBytes), system.FormatBytes(peer.TxBytes), status})
+	}
+
+	ui.Step(fmt.Sprintf("Peers on %s", interfaceName))
+	ui.Table([]string{"Peer", "Endpoint", "Last handshake", "Received", "Sent", "Status"}, rows)
+	ui.Print("")
+
+	if len(stalePeers) == 0 {
+		ui.Success("All peers have a recent handshake")
+		return nil
+	}
+	ui.Warningf("%d peer(s) have not completed a handshake in the last %s and are likely down: %s",
+		len(stalePeers), wireGuardStaleHandshake, strings.Join(stalePeers, ", "))
+	ui.Info("Peers without PersistentKeepalive only handshake while sending traffic")
+	return nil
+}
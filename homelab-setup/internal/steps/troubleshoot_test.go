@@ -0,0 +1,51 @@
+package steps
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatrixRows(t *testing.T) {
+	targets := []matrixTarget{
+		{"File server", "192.168.1.50", []int{111, 2049}},
+		{"DNS", "192.168.1.1", []int{53}},
+	}
+	results := []matrixResult{
+		{ICMP: true, TCP: map[int]bool{111: true, 2049: false}},
+		{ICMP: false, TCP: map[int]bool{53: true}},
+	}
+
+	got := matrixRows(targets, results)
+	want := [][]string{
+		{"File server", "192.168.1.50", "✓", "-", "-", "✓", "-", "✗"},
+		{"DNS", "192.168.1.1", "✗", "-", "✓", "-", "-", "-"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matrixRows() = %v, want %v", got, want)
+	}
+}
+
+func TestHandshakeAge(t *testing.T) {
+	now := time.Unix(1714564800, 0)
+
+	tests := []struct {
+		name      string
+		handshake time.Time
+		wantDesc  string
+		wantStale bool
+	}{
+		{"recent", now.Add(-95 * time.Second), "1m35s ago", false},
+		{"stale", now.Add(-4 * time.Minute), "4m0s ago", true},
+		{"never", time.Time{}, "never", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desc, stale := handshakeAge(tt.handshake, now)
+			if desc != tt.wantDesc || stale != tt.wantStale {
+				t.Errorf("handshakeAge() = %q, %v; want %q, %v", desc, stale, tt.wantDesc, tt.wantStale)
+			}
+		})
+	}
+}
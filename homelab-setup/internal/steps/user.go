@@ -1,7 +1,11 @@
 package steps
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
@@ -147,7 +151,12 @@ func createUserIfNeeded(cfg *config.Config, username string, ui *ui.UI) error {
 		ui.Info("Creating system service account for Docker (non-login shell)...")
 		ui.Info("Note: Containers will run as this UID via PUID/PGID while Docker daemon runs as root")
 
-		if err := system.CreateSystemUser(username, false, "/sbin/nologin"); err != nil {
+		requestedUID, requestedGID, err := promptForRequestedIDs(cfg, ui)
+		if err != nil {
+			return err
+		}
+
+		if err := system.CreateSystemUserWithIDs(username, requestedUID, requestedGID, "/sbin/nologin"); err != nil {
 			return fmt.Errorf("failed to create system user: %w", err)
 		}
 
@@ -194,6 +203,84 @@ func createUserIfNeeded(cfg *config.Config, username string, ui *ui.UI) error {
 	return nil
 }
 
+// promptForRequestedIDs returns the UID/GID to create the homelab user with.
+// HOMELAB_UID/HOMELAB_GID are used when configured; otherwise the user is asked,
+// and an empty answer (0) lets the system assign the ID.
+func promptForRequestedIDs(cfg *config.Config, ui *ui.UI) (int, int, error) {
+	parseID := func(key, label string) (int, error) {
+		value := strings.TrimSpace(cfg.GetOrDefault(key, ""))
+		if value == "" {
+			input, err := ui.PromptInput(fmt.Sprintf("Requested %s (leave empty to assign automatically)", label), "")
+			if err != nil {
+				return 0, fmt.Errorf("failed to prompt for %s: %w", label, err)
+			}
+			value = strings.TrimSpace(input)
+		}
+		if value == "" {
+			return 0, nil
+		}
+		var id int
+		if _, err := fmt.Sscanf(value, "%d", &id); err != nil || id <= 0 || id > 65535 {
+			return 0, fmt.Errorf("invalid %s: %s", label, value)
+		}
+		return id, nil
+	}
+
+	uid, err := parseID(config.KeyHomelabUID, "UID")
+	if err != nil {
+		return 0, 0, err
+	}
+	gid, err := parseID(config.KeyHomelabGID, "GID")
+	if err != nil {
+		return 0, 0, err
+	}
+	return uid, gid, nil
+}
+
+// runtimeGroupName returns the group that grants access to the container runtime,
+// or "" when the runtime does not use one (rootless Podman)
+func runtimeGroupName(runtime string) string {
+	if runtime == "docker" {
+		return "docker"
+	}
+	return ""
+}
+
+// addUserToRuntimeGroup ensures the homelab user is a member of the container
+// runtime group so compose commands work without root (idempotent)
+func addUserToRuntimeGroup(cfg *config.Config, username string, ui *ui.UI) error {
+	group := runtimeGroupName(cfg.GetOrDefault(config.KeyContainerRuntime, "docker"))
+	if group == "" {
+		ui.Info("Container runtime does not use a group for access, skipping")
+		return nil
+	}
+
+	exists, err := system.GroupExists(group)
+	if err != nil {
+		return fmt.Errorf("failed to check group %s: %w", group, err)
+	}
+	if !exists {
+		ui.Warningf("Group '%s' does not exist (is the container runtime installed?)", group)
+		return nil
+	}
+
+	inGroup, err := system.IsUserInGroup(username, group)
+	if err != nil {
+		return fmt.Errorf("failed to check %s group membership: %w", group, err)
+	}
+	if inGroup {
+		ui.Successf("User %s is already in the '%s' group", username, group)
+		return nil
+	}
+
+	if err := system.AddUserToGroup(username, group); err != nil {
+		return err
+	}
+	ui.Successf("Added %s to the '%s' group", username, group)
+	ui.Info("Group membership takes effect after the user logs in again")
+	return nil
+}
+
 // configureSubuidSubgid configures subuid and subgid mappings for rootless containers
 func configureSubuidSubgid(username string, ui *ui.UI) error {
 	ui.Info("Checking subuid/subgid mappings for rootless containers...")
@@ -301,16 +388,18 @@ func validatePUIDPGID(cfg *config.Config, username string, ui *ui.UI) error {
 	// Parse stored values
 	var expectedUID, expectedGID int
 	if storedPUID != "" {
-		if _, err := fmt.Sscanf(storedPUID, "%d", &expectedUID); err != nil {
-			ui.Warning(fmt.Sprintf("Invalid stored PUID value: %s", storedPUID))
+		if err := common.ValidateUID(storedPUID); err != nil {
+			ui.Warning(fmt.Sprintf("Invalid stored PUID value: %v", err))
 			return nil // Non-fatal, will overwrite
 		}
+		expectedUID, _ = strconv.Atoi(storedPUID)
 	}
 	if storedPGID != "" {
-		if _, err := fmt.Sscanf(storedPGID, "%d", &expectedGID); err != nil {
-			ui.Warning(fmt.Sprintf("Invalid stored PGID value: %s", storedPGID))
+		if err := common.ValidateGID(storedPGID); err != nil {
+			ui.Warning(fmt.Sprintf("Invalid stored PGID value: %v", err))
 			return nil // Non-fatal, will overwrite
 		}
+		expectedGID, _ = strconv.Atoi(storedPGID)
 	}
 
 	// Validate consistency
@@ -351,9 +440,29 @@ func validatePUIDPGID(cfg *config.Config, username string, ui *ui.UI) error {
 	return nil
 }
 
-// getTimezoneInfo gets and displays timezone information
+// getTimezoneInfo lets the user choose the timezone and saves the choice to
+// config
 func getTimezoneInfo(cfg *config.Config, ui *ui.UI) error {
-	tz, err := system.GetTimezone()
+	tz, err := chooseTimezone(cfg, ui)
+	if err != nil {
+		return err
+	}
+
+	// Save timezone to config for later use
+	if err := cfg.Set("TIMEZONE", tz); err != nil {
+		return fmt.Errorf("failed to save timezone to config: %w", err)
+	}
+	if err := cfg.Set("TZ", tz); err != nil {
+		return fmt.Errorf("failed to save TZ to config: %w", err)
+	}
+
+	return nil
+}
+
+// chooseTimezone detects the system timezone and lets the user confirm or
+// change it
+func chooseTimezone(cfg *config.Config, ui *ui.UI) (string, error) {
+	tz, err := system.DetectTimezone()
 	if err != nil {
 		if loadErr := cfg.Load(); loadErr != nil {
 			ui.Warning(fmt.Sprintf("Could not load existing timezone configuration (defaulting to %s): %v", defaultTimezone, loadErr))
@@ -366,32 +475,94 @@ func getTimezoneInfo(cfg *config.Config, ui *ui.UI) error {
 
 		ui.Warning(fmt.Sprintf("Could not determine timezone automatically (using %s): %v", fallback, err))
 		tz = fallback
-		ui.Infof("Using timezone: %s", tz)
 	} else {
 		ui.Infof("System timezone: %s", tz)
 	}
 
-	// Save timezone to config for later use
-	if err := cfg.Set("TIMEZONE", tz); err != nil {
-		return fmt.Errorf("failed to save timezone to config: %w", err)
+	// An unreadable zoneinfo database only disables the picker and the
+	// existence check; the name is still validated for format
+	zones, zonesErr := system.ListTimezones()
+	if zonesErr != nil {
+		ui.Warning(fmt.Sprintf("Could not read the timezone database: %v", zonesErr))
 	}
-	if err := cfg.Set("TZ", tz); err != nil {
-		return fmt.Errorf("failed to save TZ to config: %w", err)
+	validate := func(value string) error {
+		if err := common.ValidateTimezone(value); err != nil {
+			return err
+		}
+		if zonesErr == nil && !system.TimezoneExists(value) {
+			return fmt.Errorf("unknown timezone: %s", value)
+		}
+		return nil
 	}
 
-	return nil
+	keep := false
+	if err := validate(tz); err != nil {
+		ui.Warning(fmt.Sprintf("Timezone %s is not valid: %v", tz, err))
+	} else {
+		keep, err = ui.PromptYesNo(fmt.Sprintf("Use timezone %s?", tz), true)
+		if err != nil {
+			return "", fmt.Errorf("failed to prompt for timezone: %w", err)
+		}
+	}
+
+	if !keep {
+		tz, err = promptForTimezone(ui, zones, tz, validate)
+		if err != nil {
+			return "", err
+		}
+	}
+	ui.Infof("Using timezone: %s", tz)
+
+	return tz, nil
+}
+
+// manualTimezoneOption is the region picker entry for typing a zone name
+const manualTimezoneOption = "Enter manually"
+
+// promptForTimezone lets the user pick a zone by region and city, or type one.
+// With no zone list (unreadable database) it falls back to free-form input.
+func promptForTimezone(ui *ui.UI, zones []string, current string, validate func(string) error) (string, error) {
+	if len(zones) > 0 {
+		groups := system.GroupTimezonesByRegion(zones)
+		regions := make([]string, 0, len(groups)+1)
+		for region := range groups {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		regions = append(regions, manualTimezoneOption)
+
+		idx, err := ui.PromptSelect("Select your region:", regions)
+		if err != nil {
+			return "", fmt.Errorf("failed to prompt for region: %w", err)
+		}
+
+		if region := regions[idx]; region != manualTimezoneOption {
+			cities := groups[region]
+			idx, err := ui.PromptSelect(fmt.Sprintf("Select your city in %s:", region), cities)
+			if err != nil {
+				return "", fmt.Errorf("failed to prompt for city: %w", err)
+			}
+			return region + "/" + cities[idx], nil
+		}
+	}
+
+	tz, err := ui.PromptInputWithValidation("Timezone (Region/City, e.g. America/Chicago)", current, validate)
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt for timezone: %w", err)
+	}
+	return tz, nil
 }
 
 // RunUserSetup executes the user configuration step
-func RunUserSetup(cfg *config.Config, ui *ui.UI) error {
+func RunUserSetup(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Check if already completed (and migrate legacy markers)
-	completed, err := ensureCanonicalMarker(cfg, userCompletionMarker, "user-configured")
+	completed, err := cfg.CanonicalizeMarker(userCompletionMarker, config.LegacyMarkerNames[userCompletionMarker]...)
 	if err != nil {
 		return fmt.Errorf("failed to check marker: %w", err)
 	}
 	if completed {
 		ui.Info("User configuration already completed (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + userCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), userCompletionMarker))
 		return nil
 	}
 
@@ -443,6 +614,13 @@ func RunUserSetup(cfg *config.Config, ui *ui.UI) error {
 		return fmt.Errorf("failed to get GID: %w", err)
 	}
 
+	// Grant access to the container runtime
+	ui.Step("Container Runtime Group")
+	if err := addUserToRuntimeGroup(cfg, username, ui); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to configure runtime group: %v", err))
+		// Non-critical error, continue
+	}
+
 	// Configure subuid/subgid
 	ui.Step("Checking Rootless Container Configuration")
 	if err := configureSubuidSubgid(username, ui); err != nil {
@@ -463,23 +641,27 @@ func RunUserSetup(cfg *config.Config, ui *ui.UI) error {
 		// Non-critical error, continue
 	}
 
-	// Save configuration
-	ui.Step("Saving Configuration")
-	if err := cfg.Set("HOMELAB_USER", username); err != nil {
-		return fmt.Errorf("failed to save homelab user: %w", err)
-	}
-
-	if err := cfg.Set("PUID", fmt.Sprintf("%d", uid)); err != nil {
-		return fmt.Errorf("failed to save PUID: %w", err)
+	// Stop before persisting anything if the operation was interrupted
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if err := cfg.Set("PGID", fmt.Sprintf("%d", gid)); err != nil {
-		return fmt.Errorf("failed to save PGID: %w", err)
+	// Save configuration
+	ui.Step("Saving Configuration")
+	if err := saveConfigValues(cfg, ui, map[string]string{
+		"HOMELAB_USER": username,
+		"PUID":         fmt.Sprintf("%d", uid),
+		"PGID":         fmt.Sprintf("%d", gid),
+		// ENV_PUID/ENV_PGID are read by legacy configs and .env templates
+		"ENV_PUID": fmt.Sprintf("%d", uid),
+		"ENV_PGID": fmt.Sprintf("%d", gid),
+	}); err != nil {
+		return err
 	}
 
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ User configuration completed successfully")
+	ui.Successf("%s User configuration completed successfully", ui.GlyphOK())
 	ui.Infof("Homelab user: %s (UID: %d, GID: %d)", username, uid, gid)
 
 	// Create completion marker
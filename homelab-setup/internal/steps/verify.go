@@ -0,0 +1,174 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// stackAppdataDirs maps each stack to the appdata subdirectories its containers use
+var stackAppdataDirs = map[string][]string{
+	"media": {"plex", "jellyfin", "tautulli"},
+	"web":   {"overseerr", "wizarr", "organizr", "homepage"},
+	"cloud": {"nextcloud", "nextcloud-db", "nextcloud-redis", "collabora", "immich", "immich-db", "immich-redis", "immich-ml"},
+}
+
+// verifyCheck is a single pass/fail line in the verification report
+type verifyCheck struct {
+	name   string
+	err    error
+	detail string
+}
+
+// findComposeFile returns the compose file in dir, preferring compose.yml
+func findComposeFile(dir string) (string, bool) {
+	for _, name := range []string{"compose.yml", "docker-compose.yml"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// verifyService runs every deployment check for one selected service
+func verifyService(cfg *config.Config, serviceName string) []verifyCheck {
+	serviceInfo := getServiceInfo(cfg, serviceName)
+	var checks []verifyCheck
+
+	// Service directory
+	if info, err := os.Stat(serviceInfo.Directory); err != nil {
+		checks = append(checks, verifyCheck{name: "service directory", err: fmt.Errorf("%s: %w", serviceInfo.Directory, err)})
+	} else if !info.IsDir() {
+		checks = append(checks, verifyCheck{name: "service directory", err: fmt.Errorf("%s is not a directory", serviceInfo.Directory)})
+	} else {
+		checks = append(checks, verifyCheck{name: "service directory", detail: serviceInfo.Directory})
+	}
+
+	// Compose file
+	if composeFile, ok := findComposeFile(serviceInfo.Directory); ok {
+		checks = append(checks, verifyCheck{name: "compose file", detail: composeFile})
+	} else {
+		checks = append(checks, verifyCheck{name: "compose file", err: fmt.Errorf("no compose.yml or docker-compose.yml in %s", serviceInfo.Directory)})
+	}
+	// The unit fixes the compose files when it is written, so check it still
+	// matches the override on disk
+	_, overridden := composeFileArgs(serviceInfo.Directory)
+	var mismatch error
+	if unit, err := os.ReadFile(unitFilePath(serviceInfo)); err == nil {
+		mismatch = unitOverrideMismatch(string(unit), overridden)
+	}
+	switch {
+	case mismatch != nil:
+		checks = append(checks, verifyCheck{name: "compose override", err: mismatch})
+	case overridden:
+		checks = append(checks, verifyCheck{name: "compose override", detail: filepath.Join(serviceInfo.Directory, composeOverrideFile)})
+	}
+
+	// Systemd unit
+	active, err := system.IsServiceActive(serviceInfo.UnitName)
+	switch {
+	case err != nil:
+		checks = append(checks, verifyCheck{name: "systemd unit", err: fmt.Errorf("failed to query %s: %w", serviceInfo.UnitName, err)})
+	case !active:
+		checks = append(checks, verifyCheck{name: "systemd unit", err: fmt.Errorf("%s is not active", serviceInfo.UnitName)})
+	default:
+		checks = append(checks, verifyCheck{name: "systemd unit", detail: serviceInfo.UnitName + " active"})
+	}
+
+	// Start at boot, compared against the per-stack choice
+	wantBoot := bootEnabled(cfg, serviceName)
+	enabled, err := system.IsServiceEnabled(serviceInfo.UnitName)
+	switch {
+	case err != nil:
+		checks = append(checks, verifyCheck{name: "start at boot", err: fmt.Errorf("failed to query %s: %w", serviceInfo.UnitName, err)})
+	case enabled && wantBoot:
+		checks = append(checks, verifyCheck{name: "start at boot", detail: "enabled"})
+	case !enabled && !wantBoot:
+		checks = append(checks, verifyCheck{name: "start at boot", detail: "disabled (by choice)"})
+	case wantBoot:
+		checks = append(checks, verifyCheck{name: "start at boot", err: fmt.Errorf("%s is not enabled at boot", serviceInfo.UnitName)})
+	default:
+		checks = append(checks, verifyCheck{name: "start at boot", err: fmt.Errorf("%s is enabled at boot but configured not to be", serviceInfo.UnitName)})
+	}
+
+	// Web UI health endpoints
+	for i, result := range probeHealth(context.Background(), serviceInfo) {
+		checks = append(checks, healthCheck(serviceInfo.Health[i].Name, result))
+	}
+
+	// The same endpoints through the reverse proxy, when enabled
+	if publicHealthEnabled(cfg) {
+		public := publicHealthEndpoints(cfg, serviceName)
+		for i, result := range probePublicHealth(context.Background(), public, publicHealthTLSVerify(cfg)) {
+			checks = append(checks, publicHealthCheck(public[i].Name, result))
+		}
+	}
+
+	// Appdata subdirectories
+	appdataBase := cfg.GetOrDefault("APPDATA_BASE", "/var/lib/containers/appdata")
+	var missing []string
+	for _, dir := range stackAppdataDirs[serviceName] {
+		path, _, err := appdataPath(cfg, appdataBase, dir)
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("%s (%v)", dir, err))
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		checks = append(checks, verifyCheck{name: "appdata", err: fmt.Errorf("missing in %s: %s", appdataBase, strings.Join(missing, ", "))})
+	} else {
+		checks = append(checks, verifyCheck{name: "appdata", detail: appdataBase})
+	}
+
+	return checks
+}
+
+// VerifyDeployment confirms every selected service is actually deployed and
+// running, independent of completion markers. Returns an error listing the
+// failed services if any check fails.
+func VerifyDeployment(cfg *config.Config, ui *ui.UI) error {
+	ui.Header("Deployment Verification")
+
+	selectedServices, err := getSelectedServices(cfg)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, serviceName := range selectedServices {
+		serviceInfo := getServiceInfo(cfg, serviceName)
+		ui.Step(serviceInfo.DisplayName)
+
+		serviceFailed := false
+		for _, check := range verifyService(cfg, serviceName) {
+			if check.err != nil {
+				ui.Errorf("  %s %s: %v", ui.GlyphFail(), check.name, check.err)
+				serviceFailed = true
+				continue
+			}
+			ui.Successf("  %s %s: %s", ui.GlyphOK(), check.name, check.detail)
+		}
+		if serviceFailed {
+			failed = append(failed, serviceName)
+		}
+	}
+
+	ui.Print("")
+	if len(failed) > 0 {
+		ui.Errorf("Verification failed for %d of %d service(s)", len(failed), len(selectedServices))
+		return fmt.Errorf("deployment verification failed for: %s", strings.Join(failed, ", "))
+	}
+
+	ui.Successf("All %d service(s) verified", len(selectedServices))
+	return nil
+}
@@ -0,0 +1,42 @@
+package steps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindComposeFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"compose.yml", []string{"compose.yml"}, "compose.yml"},
+		{"docker-compose.yml", []string{"docker-compose.yml"}, "docker-compose.yml"},
+		{"prefers compose.yml", []string{"docker-compose.yml", "compose.yml"}, "compose.yml"},
+		{"none", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("services: {}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, ok := findComposeFile(dir)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("expected no compose file, got %s", got)
+				}
+				return
+			}
+			if !ok || got != filepath.Join(dir, tt.want) {
+				t.Errorf("findComposeFile() = (%q, %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@
 package steps
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
@@ -25,11 +27,12 @@ const wireGuardCompletionMarker = "wireguard-setup-complete"
 
 // WireGuardConfig holds WireGuard configuration
 type WireGuardConfig struct {
-	InterfaceName string
-	InterfaceIP   string
-	ListenPort    string
-	PrivateKey    string
-	PublicKey     string
+	InterfaceName   string
+	InterfaceIP     string
+	ListenPort      string
+	ListenInterface string // Host interface peers connect through (empty = any)
+	PrivateKey      string
+	PublicKey       string
 }
 
 // WireGuardPeer holds peer configuration
@@ -279,17 +282,143 @@ func promptForConfig(_ *config.Config, ui *ui.UI, publicKey string) (*WireGuardC
 	}
 	wgCfg.ListenPort = listenPort
 
+	// On multi-homed hosts, record which interface peers should reach us on
+	listenInterface, err := promptForInterface(ui, "Interface peers will connect to")
+	if err != nil {
+		ui.Warningf("Could not list network interfaces: %v", err)
+	}
+	wgCfg.ListenInterface = listenInterface
+
 	return wgCfg, nil
 }
 
+// clientDNS returns the DNS servers for generated client configs, falling back
+// to WIREGUARD_PEER_DNS written by earlier versions
+func clientDNS(cfg *config.Config) string {
+	if dns := cfg.GetOrDefault(config.KeyWGClientDNS, ""); dns != "" {
+		return dns
+	}
+	return cfg.GetOrDefault("WIREGUARD_PEER_DNS", "")
+}
+
+// detectClientDNS suggests a resolver for clients: the first nameserver in
+// resolv.conf that is not a loopback stub, otherwise the default gateway
+func detectClientDNS() string {
+	if servers, err := system.GetNameservers(); err == nil {
+		for _, server := range servers {
+			if ip := net.ParseIP(server); ip != nil && !ip.IsLoopback() {
+				return server
+			}
+		}
+	}
+	if gateway, err := system.GetDefaultGateway(); err == nil {
+		return gateway
+	}
+	return ""
+}
+
+// validateClientDNS checks a comma-separated list of DNS server IPs
+func validateClientDNS(dns string) error {
+	for _, server := range splitConfigList(dns) {
+		if err := common.ValidateIPAny(server); err != nil {
+			return fmt.Errorf("invalid DNS server: %w", err)
+		}
+	}
+	return nil
+}
+
+// promptClientDNS asks which DNS servers clients should use, so internal
+// names resolve over the tunnel. Entering "none" omits the DNS line.
+func promptClientDNS(cfg *config.Config, ui *ui.UI) (string, error) {
+	defaultDNS := clientDNS(cfg)
+	if defaultDNS == "" {
+		defaultDNS = detectClientDNS()
+	}
+	if ui.IsNonInteractive() {
+		return defaultDNS, validateClientDNS(defaultDNS)
+	}
+
+	ui.Info("Clients use these DNS servers while connected, so internal names (e.g. *.lan) resolve")
+	dns, err := ui.PromptInputWithValidation("Client DNS servers (comma-separated IPs, 'none' to omit)", defaultDNS, func(value string) error {
+		if isNoneAnswer(value) {
+			return nil
+		}
+		return validateClientDNS(value)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to prompt for client DNS: %w", err)
+	}
+	if isNoneAnswer(dns) {
+		return "", nil
+	}
+	return strings.Join(splitConfigList(dns), ", "), nil
+}
+
+// isNoneAnswer reports whether a prompt answer is "none", which clears an
+// optional list
+func isNoneAnswer(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), "none")
+}
+
+// maxKeepalive bounds WIREGUARD_KEEPALIVE at the largest interval WireGuard
+// accepts. NAT mappings usually expire within a few minutes, so intervals
+// beyond that rarely keep a tunnel up, but they are not rejected.
+const maxKeepalive = 65535
+
+// validateKeepalive checks a PersistentKeepalive interval in seconds (0 disables)
+func validateKeepalive(seconds int) error {
+	if seconds < 0 || seconds > maxKeepalive {
+		return fmt.Errorf("keepalive out of range (0-%d seconds): %d", maxKeepalive, seconds)
+	}
+	return nil
+}
+
+// parseKeepalive parses and validates a PersistentKeepalive interval
+func parseKeepalive(value string) (int, error) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("keepalive must be a whole number of seconds: %s", value)
+	}
+	return seconds, validateKeepalive(seconds)
+}
+
+// keepalive returns the configured PersistentKeepalive interval, falling back
+// to the default when the saved value is invalid
+func keepalive(cfg *config.Config) int {
+	seconds, err := parseKeepalive(cfg.GetOrDefault(config.KeyWGKeepalive, "25"))
+	if err != nil {
+		return 25
+	}
+	return seconds
+}
+
+// promptKeepalive asks for the PersistentKeepalive interval of new peers
+func promptKeepalive(cfg *config.Config, ui *ui.UI) (int, error) {
+	ui.Info("PersistentKeepalive keeps clients behind NAT reachable by sending a packet every few seconds (0 disables)")
+	value, err := ui.PromptInputWithValidation("Persistent keepalive (seconds)", strconv.Itoa(keepalive(cfg)), func(value string) error {
+		_, err := parseKeepalive(value)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prompt for keepalive: %w", err)
+	}
+	return parseKeepalive(value)
+}
+
 // WriteConfig writes the WireGuard configuration file
 func writeConfig(cfgData *config.Config, ui *ui.UI, cfg *WireGuardConfig, privateKey string) error {
 	ui.Infof("Writing WireGuard configuration for %s...", cfg.InterfaceName)
 
+	listenComment := ""
+	if cfg.ListenInterface != "" {
+		listenComment = fmt.Sprintf("# Peers connect via host interface: %s\n", cfg.ListenInterface)
+	}
+
 	configContent := fmt.Sprintf(`[Interface]
 # WireGuard interface configuration
 # Generated by homelab-setup
-
+# Public key: %s
+%s
 Address = %s
 ListenPort = %s
 PrivateKey = %s
@@ -299,7 +428,7 @@ PrivateKey = %s
 # PublicKey = <peer-public-key>
 # AllowedIPs = 10.253.0.2/32
 # Endpoint = <peer-ip>:51820
-`, cfg.InterfaceIP, cfg.ListenPort, privateKey)
+`, cfg.PublicKey, listenComment, cfg.InterfaceIP, cfg.ListenPort, privateKey)
 
 	ui.Print("")
 	ui.Info("Configuration file content:")
@@ -340,7 +469,7 @@ PrivateKey = %s
 }
 
 // EnableService enables and starts the WireGuard service
-func enableService(_ *config.Config, ui *ui.UI, interfaceName string) error {
+func enableService(cfg *config.Config, ui *ui.UI, interfaceName string) error {
 	serviceName := fmt.Sprintf("wg-quick@%s.service", interfaceName)
 
 	ui.Print("")
@@ -362,6 +491,13 @@ func enableService(_ *config.Config, ui *ui.UI, interfaceName string) error {
 		return nil
 	}
 
+	// wg-quick fails with little explanation on a broken config
+	if err := checkWireGuardConfig(cfg, ui, interfaceName); err != nil {
+		ui.Info("Fix the configuration, then enable and start the service:")
+		ui.Infof("  sudo systemctl enable --now %s", serviceName)
+		return err
+	}
+
 	ui.Print("")
 	ui.Infof("Enabling %s...", serviceName)
 
@@ -486,7 +622,12 @@ func promptForPeer(_ *config.Config, ui *ui.UI, nextIP string) (*WireGuardPeer,
 
 	// Prompt for endpoint (optional)
 	ui.Info("Endpoint is optional - leave empty for road warrior clients")
-	endpoint, err := ui.PromptInput("Endpoint (e.g., 'server.example.com:51820')", "")
+	endpoint, err := ui.PromptInputWithValidation("Endpoint (e.g., 'server.example.com:51820')", "", func(value string) error {
+		if value == "" {
+			return nil
+		}
+		return common.ValidateEndpoint(value)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to prompt for endpoint: %w", err)
 	}
@@ -505,9 +646,8 @@ func promptForPeer(_ *config.Config, ui *ui.UI, nextIP string) (*WireGuardPeer,
 func addPeerToConfig(cfg *config.Config, ui *ui.UI, interfaceName string, peer *WireGuardPeer) error {
 	configPath := filepath.Join(configDir(cfg), fmt.Sprintf("%s.conf", interfaceName))
 
-	// Read current config (using sudo cat to handle permissions)
-	cmd := exec.Command("sudo", "-n", "cat", configPath)
-	output, err := cmd.Output()
+	// Read current config (falls back to sudo cat to handle permissions)
+	output, err := system.ReadFile(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -522,6 +662,11 @@ func addPeerToConfig(cfg *config.Config, ui *ui.UI, interfaceName string, peer *
 	if peer.Endpoint != "" {
 		sanitizedEndpoint := sanitizeConfigValue(peer.Endpoint)
 		peerSection += fmt.Sprintf("Endpoint = %s\n", sanitizedEndpoint)
+		// This host initiates to peers with a known endpoint, so it must keep
+		// its own NAT mapping open
+		if seconds := keepalive(cfg); seconds > 0 {
+			peerSection += fmt.Sprintf("PersistentKeepalive = %d\n", seconds)
+		}
 	}
 
 	// Append peer to config
@@ -640,7 +785,9 @@ func addPeers(cfg *config.Config, ui *ui.UI, _ WireGuardKeyGenerator, interfaceN
 		serviceName := fmt.Sprintf("wg-quick@%s.service", interfaceName)
 		active, _ := system.IsServiceActive(serviceName)
 
-		if active {
+		if active && checkWireGuardConfig(cfg, ui, interfaceName) != nil {
+			ui.Infof("Fix the configuration, then restart: sudo systemctl restart %s", serviceName)
+		} else if active {
 			ui.Print("")
 			ui.Info("The WireGuard service needs to be restarted to apply peer changes.")
 			restart, err := ui.PromptYesNo("Restart the service now?", true)
@@ -666,17 +813,17 @@ func addPeers(cfg *config.Config, ui *ui.UI, _ WireGuardKeyGenerator, interfaceN
 }
 
 // RunWireGuardSetup executes the WireGuard setup step
-func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
+func RunWireGuardSetup(ctx context.Context, cfg *config.Config, ui *ui.UI) error {
 	// Create default keygen
 	keygen := CommandKeyGenerator{}
 	// Check if already completed (and migrate legacy markers)
-	completed, err := ensureCanonicalMarker(cfg, wireGuardCompletionMarker, "wireguard-configured", "wireguard-skipped")
+	completed, err := cfg.CanonicalizeMarker(wireGuardCompletionMarker, config.LegacyMarkerNames[wireGuardCompletionMarker]...)
 	if err != nil {
 		return fmt.Errorf("failed to check marker: %w", err)
 	}
 	if completed {
 		ui.Info("WireGuard already configured (marker found)")
-		ui.Info("To re-run, remove marker: ~/.local/homelab-setup/" + wireGuardCompletionMarker)
+		ui.Info("To re-run, remove marker: " + filepath.Join(cfg.MarkerDir(), wireGuardCompletionMarker))
 		return nil
 	}
 
@@ -693,7 +840,7 @@ func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
 
 	if !useWireGuard {
 		ui.Info("Skipping WireGuard configuration")
-		ui.Info("To configure WireGuard later, remove marker: ~/.local/homelab-setup/" + wireGuardCompletionMarker)
+		ui.Info("To configure WireGuard later, remove marker: " + filepath.Join(cfg.MarkerDir(), wireGuardCompletionMarker))
 		if err := cfg.Set("WIREGUARD_ENABLED", "false"); err != nil {
 			return fmt.Errorf("failed to update WireGuard configuration: %w", err)
 		}
@@ -734,6 +881,15 @@ func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
 	}
 	wgCfg.PrivateKey = privateKey
 
+	dns, err := promptClientDNS(cfg, ui)
+	if err != nil {
+		return err
+	}
+	peerKeepalive, err := promptKeepalive(cfg, ui)
+	if err != nil {
+		return err
+	}
+
 	// Write configuration
 	ui.Step("Creating Configuration File")
 	if err := writeConfig(cfg, ui, wgCfg, privateKey); err != nil {
@@ -747,6 +903,12 @@ func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
 		// Non-critical, continue
 	}
 
+	ui.Step("Firewall")
+	if err := openWireGuardFirewallPort(ui, wgCfg.ListenPort, wgCfg.ListenInterface); err != nil {
+		ui.Warningf("Could not configure the firewall: %v", err)
+		// Non-critical, continue
+	}
+
 	// Add peers interactively
 	ui.Step("Peer Configuration")
 	if err := addPeers(cfg, ui, keygen, wgCfg.InterfaceName, publicKey, wgCfg.InterfaceIP); err != nil {
@@ -754,13 +916,24 @@ func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
 		// Non-critical, continue
 	}
 
+	// Stop before persisting anything if the operation was interrupted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ui.Step("Validating Configuration")
+	if err := checkWireGuardConfig(cfg, ui, wgCfg.InterfaceName); err != nil {
+		ui.Warningf("%v; fix it before relying on the VPN", err)
+		// Non-critical, continue
+	}
+
 	// Save configuration
 	ui.Step("Saving Configuration")
 	if err := cfg.Set("WIREGUARD_ENABLED", "true"); err != nil {
 		return fmt.Errorf("failed to save WireGuard enabled: %w", err)
 	}
 
-	if err := cfg.Set("WIREGUARD_INTERFACE", wgCfg.InterfaceName); err != nil {
+	if err := cfg.Set(config.KeyWGInterface, wgCfg.InterfaceName); err != nil {
 		return fmt.Errorf("failed to save WireGuard interface: %w", err)
 	}
 
@@ -768,12 +941,27 @@ func RunWireGuardSetup(cfg *config.Config, ui *ui.UI) error {
 		return fmt.Errorf("failed to save WireGuard public key: %w", err)
 	}
 
+	if err := cfg.Set(config.KeyWGClientDNS, dns); err != nil {
+		return fmt.Errorf("failed to save WireGuard client DNS: %w", err)
+	}
+
+	if err := cfg.Set(config.KeyWGKeepalive, strconv.Itoa(peerKeepalive)); err != nil {
+		return fmt.Errorf("failed to save WireGuard keepalive: %w", err)
+	}
+
+	if err := cfg.Set(config.KeyWGListenInterface, wgCfg.ListenInterface); err != nil {
+		return fmt.Errorf("failed to save WireGuard listen interface: %w", err)
+	}
+
 	ui.Print("")
 	ui.Separator()
-	ui.Success("✓ WireGuard configuration completed")
+	ui.Successf("%s WireGuard configuration completed", ui.GlyphOK())
 	ui.Infof("Interface: %s", wgCfg.InterfaceName)
 	ui.Infof("Address: %s", wgCfg.InterfaceIP)
 	ui.Infof("Port: %s", wgCfg.ListenPort)
+	if wgCfg.ListenInterface != "" {
+		ui.Infof("Listen interface: %s", wgCfg.ListenInterface)
+	}
 
 	// Create completion marker
 	if err := cfg.MarkComplete(wireGuardCompletionMarker); err != nil {
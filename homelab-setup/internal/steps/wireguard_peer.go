@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
 	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
@@ -261,7 +263,7 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 	}
 	interfaceName := strings.TrimSpace(opts.InterfaceName)
 	if interfaceName == "" {
-		interfaceName = cfg.GetOrDefault("WIREGUARD_INTERFACE", "wg0")
+		interfaceName = cfg.GetOrDefault(config.KeyWGInterface, "wg0")
 	}
 	if interfaceName == "" {
 		if opts.NonInteractive {
@@ -326,10 +328,12 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 		if opts.NonInteractive {
 			return fmt.Errorf("endpoint is required in non-interactive mode")
 		}
-		endpoint, err = ui.PromptInput("Server endpoint (host:port)", "")
+		endpoint, err = ui.PromptInputWithValidation("Server endpoint (host:port)", "", common.ValidateEndpoint)
 		if err != nil {
 			return err
 		}
+	} else if err := common.ValidateEndpoint(endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
 	}
 	if endpoint != "" {
 		if err := cfg.Set("WIREGUARD_ENDPOINT", endpoint); err != nil {
@@ -338,19 +342,19 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 	}
 
 	dns := strings.TrimSpace(opts.DNS)
-	if dns == "" {
-		dns = cfg.GetOrDefault("WIREGUARD_PEER_DNS", "")
+	if dns == "" && opts.NonInteractive {
+		dns = clientDNS(cfg)
 	}
 	if dns == "" && !opts.NonInteractive {
-		dns, err = ui.PromptInput("Client DNS server (optional)", "")
+		dns, err = promptClientDNS(cfg, ui)
 		if err != nil {
 			return err
 		}
+	} else if err := validateClientDNS(dns); err != nil {
+		return err
 	}
-	if dns != "" {
-		if err := cfg.Set("WIREGUARD_PEER_DNS", dns); err != nil {
-			ui.Warningf("failed to persist DNS: %v", err)
-		}
+	if err := cfg.Set(config.KeyWGClientDNS, dns); err != nil {
+		ui.Warningf("failed to persist DNS: %v", err)
 	}
 
 	// Validate that ClientAllowedIPs and RouteAll are not both set
@@ -377,16 +381,43 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 		if routeAll {
 			clientAllowed = "0.0.0.0/0, ::/0"
 		} else {
+			// Split tunnel: the VPN subnet plus any LAN subnets behind the server
 			clientAllowed = networkCIDR
+			lanSubnets := cfg.GetOrDefault("WIREGUARD_LAN_SUBNETS", "")
+			if !opts.NonInteractive {
+				lanSubnets, err = ui.PromptInputWithValidation("LAN subnets to reach through the VPN (comma-separated, optional)", lanSubnets, validateOptionalCIDRList)
+				if err != nil {
+					return err
+				}
+			} else if err := validateOptionalCIDRList(lanSubnets); err != nil {
+				return fmt.Errorf("invalid WIREGUARD_LAN_SUBNETS: %w", err)
+			}
+			if lanSubnets = strings.Join(splitConfigList(lanSubnets), ", "); lanSubnets != "" {
+				clientAllowed += ", " + lanSubnets
+				if err := cfg.Set("WIREGUARD_LAN_SUBNETS", lanSubnets); err != nil {
+					ui.Warningf("failed to persist LAN subnets: %v", err)
+				}
+			}
 		}
 	}
 
-	keepalive := 25
-	if opts.PersistentKeepaliveSeconds != nil {
-		if *opts.PersistentKeepaliveSeconds <= 0 {
-			keepalive = 0
-		} else {
-			keepalive = *opts.PersistentKeepaliveSeconds
+	var peerKeepalive int
+	switch {
+	case opts.PersistentKeepaliveSeconds != nil:
+		// Negative values have always meant "disabled"
+		peerKeepalive = max(*opts.PersistentKeepaliveSeconds, 0)
+		if err := validateKeepalive(peerKeepalive); err != nil {
+			return err
+		}
+	case opts.NonInteractive:
+		peerKeepalive = keepalive(cfg)
+	default:
+		peerKeepalive, err = promptKeepalive(cfg, ui)
+		if err != nil {
+			return err
+		}
+		if err := cfg.Set(config.KeyWGKeepalive, strconv.Itoa(peerKeepalive)); err != nil {
+			ui.Warningf("failed to persist keepalive: %v", err)
 		}
 	}
 
@@ -430,7 +461,7 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 	}
 
 	if opts.ProvidedPresharedKey != "" {
-		presharedKey = sanitizeConfigValue(opts.ProvidedPresharedKey)
+		presharedKey = strings.TrimSpace(opts.ProvidedPresharedKey)
 		usePSK = true
 	}
 
@@ -441,10 +472,33 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 		}
 	}
 
-	clientConfig := renderClientConfig(clientPrivate, nextIP, dns, serverPublicKey, presharedKey, endpoint, clientAllowed, keepalive)
+	client := wireGuardClientConfig{
+		PrivateKey:      clientPrivate,
+		Address:         nextIP,
+		DNS:             dns,
+		ServerPublicKey: serverPublicKey,
+		PresharedKey:    presharedKey,
+		Endpoint:        endpoint,
+		AllowedIPs:      clientAllowed,
+		Keepalive:       peerKeepalive,
+	}
+	if err := client.validate(); err != nil {
+		return fmt.Errorf("invalid client configuration: %w", err)
+	}
+	clientConfig := client.render()
+
 	exportDir := opts.OutputDir
 	if exportDir == "" {
 		exportDir = defaultPeerExportDir()
+		if !opts.NonInteractive {
+			exportDir, err = ui.PromptInput("Directory to save the client config in", exportDir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := common.ValidateSafePath(exportDir); err != nil {
+		return fmt.Errorf("invalid export directory: %w", err)
 	}
 	exportPath, err := writeClientConfigExport(peerName, exportDir, clientConfig)
 	if err != nil {
@@ -460,7 +514,7 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 		qrOutput, qrErr = renderASCIIQRCode(clientConfig)
 	}
 
-	serverBlock := buildServerPeerBlock(peerName, clientPublic, presharedKey, nextIP, keepalive)
+	serverBlock := buildServerPeerBlock(peerName, clientPublic, presharedKey, nextIP, peerKeepalive)
 	newConfig := appendPeerBlock(string(rawConfig), serverBlock)
 	if err := system.WriteFile(configPath, []byte(newConfig), 0600); err != nil {
 		return fmt.Errorf("failed to update %s: %w", configPath, err)
@@ -480,9 +534,22 @@ func RunWireGuardPeerWorkflow(cfg *config.Config, ui *ui.UI, opts *WireGuardPeer
 		}
 	}
 
+	if !opts.NonInteractive && ui.ClipboardAvailable() {
+		copyConfig, err := ui.PromptYesNo("Copy the client config to the clipboard?", false)
+		if err == nil && copyConfig {
+			if err := ui.CopyToClipboard(clientConfig); err != nil {
+				ui.Warningf("%v", err)
+			} else {
+				ui.Success("Client config copied to the clipboard")
+			}
+		}
+	}
+
 	if !opts.SkipServiceRestart {
 		restart, err := ui.PromptYesNo(fmt.Sprintf("Restart wg-quick@%s now?", interfaceName), true)
-		if err == nil && restart {
+		if err == nil && restart && checkWireGuardConfig(cfg, ui, interfaceName) != nil {
+			ui.Infof("Fix the configuration, then restart: sudo systemctl restart wg-quick@%s", interfaceName)
+		} else if err == nil && restart {
 			serviceName := fmt.Sprintf("wg-quick@%s.service", interfaceName)
 			if err := system.RestartService(serviceName); err != nil {
 				ui.Warningf("Failed to restart %s: %v", serviceName, err)
@@ -518,16 +585,21 @@ func writeClientConfigExport(peerName, exportDir, clientConfig string) (string,
 	return exportPath, nil
 }
 
+// buildServerPeerBlock renders the server-side [Peer] entry for a client. The
+// keys are generated locally and written verbatim: sanitizeConfigValue would
+// strip their '=' padding.
 func buildServerPeerBlock(name, publicKey, presharedKey, allowedIP string, keepalive int) string {
 	builder := strings.Builder{}
 	builder.WriteString(fmt.Sprintf("# Peer: %s\n", sanitizePeerName(name)))
 	builder.WriteString("[Peer]\n")
-	builder.WriteString(fmt.Sprintf("PublicKey = %s\n", sanitizeConfigValue(publicKey)))
+	builder.WriteString(fmt.Sprintf("PublicKey = %s\n", publicKey))
 	if presharedKey != "" {
-		builder.WriteString(fmt.Sprintf("PresharedKey = %s\n", sanitizeConfigValue(presharedKey)))
+		builder.WriteString(fmt.Sprintf("PresharedKey = %s\n", presharedKey))
 	}
 	builder.WriteString(fmt.Sprintf("AllowedIPs = %s\n", sanitizeConfigValue(allowedIP)))
-	builder.WriteString(fmt.Sprintf("PersistentKeepalive = %d\n", keepalive))
+	if keepalive > 0 {
+		builder.WriteString(fmt.Sprintf("PersistentKeepalive = %d\n", keepalive))
+	}
 	return builder.String()
 }
 
@@ -539,27 +611,105 @@ func appendPeerBlock(existing, block string) string {
 	return trimmed + "\n\n" + block + "\n"
 }
 
-func renderClientConfig(privateKey, address, dns, serverPublicKey, presharedKey, endpoint, allowedIPs string, keepalive int) string {
+// wireGuardClientConfig holds the fields of a client .conf file
+type wireGuardClientConfig struct {
+	PrivateKey      string
+	Address         string // client tunnel address, e.g. 10.253.0.2/32
+	DNS             string // optional, comma-separated IPs
+	ServerPublicKey string
+	PresharedKey    string // optional
+	Endpoint        string // optional, host:port
+	AllowedIPs      string // comma-separated CIDRs routed through the tunnel
+	Keepalive       int    // seconds, 0 disables
+}
+
+// validate checks every field so the rendered file imports cleanly and cannot
+// carry injected directives
+func (c wireGuardClientConfig) validate() error {
+	if err := common.ValidateWireGuardKey(c.PrivateKey); err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	if err := common.ValidateCIDR(c.Address); err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+	if err := validateClientDNS(c.DNS); err != nil {
+		return err
+	}
+	if err := common.ValidateWireGuardKey(c.ServerPublicKey); err != nil {
+		return fmt.Errorf("server public key: %w", err)
+	}
+	if c.PresharedKey != "" {
+		if err := common.ValidateWireGuardKey(c.PresharedKey); err != nil {
+			return fmt.Errorf("preshared key: %w", err)
+		}
+	}
+	if c.Endpoint != "" {
+		if err := common.ValidateEndpoint(c.Endpoint); err != nil {
+			return err
+		}
+	}
+	allowed := splitConfigList(c.AllowedIPs)
+	if len(allowed) == 0 {
+		return fmt.Errorf("allowed IPs cannot be empty")
+	}
+	for _, cidr := range allowed {
+		if err := common.ValidateCIDR(cidr); err != nil {
+			return fmt.Errorf("allowed IPs: %w", err)
+		}
+	}
+	if err := validateKeepalive(c.Keepalive); err != nil {
+		return err
+	}
+	return nil
+}
+
+// render returns the client .conf contents. Fields are written verbatim, so
+// callers must validate first.
+func (c wireGuardClientConfig) render() string {
 	builder := strings.Builder{}
 	builder.WriteString("[Interface]\n")
-	builder.WriteString(fmt.Sprintf("PrivateKey = %s\n", sanitizeConfigValue(privateKey)))
-	builder.WriteString(fmt.Sprintf("Address = %s\n", sanitizeConfigValue(address)))
-	if dns != "" {
-		builder.WriteString(fmt.Sprintf("DNS = %s\n", sanitizeConfigValue(dns)))
+	builder.WriteString(fmt.Sprintf("PrivateKey = %s\n", c.PrivateKey))
+	builder.WriteString(fmt.Sprintf("Address = %s\n", c.Address))
+	if c.DNS != "" {
+		builder.WriteString(fmt.Sprintf("DNS = %s\n", strings.Join(splitConfigList(c.DNS), ", ")))
 	}
 	builder.WriteString("\n[Peer]\n")
-	builder.WriteString(fmt.Sprintf("PublicKey = %s\n", sanitizeConfigValue(serverPublicKey)))
-	if presharedKey != "" {
-		builder.WriteString(fmt.Sprintf("PresharedKey = %s\n", sanitizeConfigValue(presharedKey)))
+	builder.WriteString(fmt.Sprintf("PublicKey = %s\n", c.ServerPublicKey))
+	if c.PresharedKey != "" {
+		builder.WriteString(fmt.Sprintf("PresharedKey = %s\n", c.PresharedKey))
 	}
-	if endpoint != "" {
-		builder.WriteString(fmt.Sprintf("Endpoint = %s\n", sanitizeConfigValue(endpoint)))
+	if c.Endpoint != "" {
+		builder.WriteString(fmt.Sprintf("Endpoint = %s\n", c.Endpoint))
+	}
+	builder.WriteString(fmt.Sprintf("AllowedIPs = %s\n", strings.Join(splitConfigList(c.AllowedIPs), ", ")))
+	if c.Keepalive > 0 {
+		builder.WriteString(fmt.Sprintf("PersistentKeepalive = %d\n", c.Keepalive))
 	}
-	builder.WriteString(fmt.Sprintf("AllowedIPs = %s\n", sanitizeConfigValue(allowedIPs)))
-	builder.WriteString(fmt.Sprintf("PersistentKeepalive = %d\n", keepalive))
 	return builder.String()
 }
 
+// splitConfigList splits a comma-separated config value into trimmed entries
+func splitConfigList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// validateOptionalCIDRList accepts an empty value or a comma-separated list
+// of networks in CIDR notation, such as WIREGUARD_LAN_SUBNETS
+func validateOptionalCIDRList(value string) error {
+	for _, cidr := range splitConfigList(value) {
+		if err := common.ValidateCIDR(cidr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func renderASCIIQRCode(content string) (string, error) {
 	if !system.CommandExists("qrencode") {
 		return "", errors.New("qrencode binary not found; install qrencode to enable QR output")
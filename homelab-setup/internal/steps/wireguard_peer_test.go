@@ -0,0 +1,195 @@
+package steps
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+)
+
+func testClientConfig() wireGuardClientConfig {
+	return wireGuardClientConfig{
+		PrivateKey:      "YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+		Address:         "10.253.0.2/32",
+		DNS:             "10.253.0.1",
+		ServerPublicKey: "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+		PresharedKey:    "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE=",
+		Endpoint:        "vpn.example.com:51820",
+		AllowedIPs:      "10.253.0.0/24,192.168.1.0/24",
+		Keepalive:       25,
+	}
+}
+
+func TestWireGuardClientConfigRoundTrip(t *testing.T) {
+	client := testClientConfig()
+	if err := client.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	parsed := parseWireGuardConfig(client.render())
+	wantInterface := map[string]string{
+		"PrivateKey": client.PrivateKey,
+		"Address":    client.Address,
+		"DNS":        client.DNS,
+	}
+	for key, want := range wantInterface {
+		if got := parsed.Interface[key]; got != want {
+			t.Errorf("[Interface] %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if len(parsed.Peers) != 1 {
+		t.Fatalf("got %d [Peer] sections, want 1", len(parsed.Peers))
+	}
+	wantPeer := map[string]string{
+		"PublicKey":           client.ServerPublicKey,
+		"PresharedKey":        client.PresharedKey,
+		"Endpoint":            client.Endpoint,
+		"AllowedIPs":          "10.253.0.0/24, 192.168.1.0/24",
+		"PersistentKeepalive": "25",
+	}
+	for key, want := range wantPeer {
+		if got := parsed.Peers[0].Values[key]; got != want {
+			t.Errorf("[Peer] %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestWireGuardClientConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*wireGuardClientConfig)
+	}{
+		{"stripped key padding", func(c *wireGuardClientConfig) { c.PrivateKey = strings.TrimSuffix(c.PrivateKey, "=") }},
+		{"bad address", func(c *wireGuardClientConfig) { c.Address = "10.253.0.2" }},
+		{"injected DNS", func(c *wireGuardClientConfig) { c.DNS = "1.1.1.1\nPostUp = rm -rf /" }},
+		{"endpoint without port", func(c *wireGuardClientConfig) { c.Endpoint = "vpn.example.com" }},
+		{"empty allowed IPs", func(c *wireGuardClientConfig) { c.AllowedIPs = " , " }},
+		{"bad allowed IPs", func(c *wireGuardClientConfig) { c.AllowedIPs = "0.0.0.0/0, lan" }},
+		{"negative keepalive", func(c *wireGuardClientConfig) { c.Keepalive = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testClientConfig()
+			tt.modify(&client)
+			if err := client.validate(); err == nil {
+				t.Error("validate() expected error")
+			}
+		})
+	}
+
+	// Optional fields may be left empty
+	client := testClientConfig()
+	client.DNS, client.PresharedKey, client.Endpoint = "", "", ""
+	if err := client.validate(); err != nil {
+		t.Errorf("validate() with optional fields empty: %v", err)
+	}
+}
+
+func TestClientDNSFallsBackToLegacyKey(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := clientDNS(cfg); got != "" {
+		t.Errorf("clientDNS() with nothing set = %q, want empty", got)
+	}
+
+	if err := cfg.Set("WIREGUARD_PEER_DNS", "192.168.1.1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := clientDNS(cfg); got != "192.168.1.1" {
+		t.Errorf("clientDNS() = %q, want legacy value", got)
+	}
+
+	if err := cfg.Set(config.KeyWGClientDNS, "192.168.1.53, fd00::53"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := clientDNS(cfg); got != "192.168.1.53, fd00::53" {
+		t.Errorf("clientDNS() = %q, want WIREGUARD_CLIENT_DNS value", got)
+	}
+	if err := validateClientDNS(clientDNS(cfg)); err != nil {
+		t.Errorf("validateClientDNS() unexpected error: %v", err)
+	}
+	if err := validateClientDNS("dns.lan"); err == nil {
+		t.Error("validateClientDNS() should reject host names")
+	}
+}
+
+func TestWireGuardClientConfigOmitsEmptyDNS(t *testing.T) {
+	client := testClientConfig()
+	client.DNS = ""
+	if strings.Contains(client.render(), "DNS") {
+		t.Errorf("render() wrote a DNS line without DNS servers:\n%s", client.render())
+	}
+}
+
+func TestParseKeepalive(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"25", 25, false},
+		{" 0 ", 0, false},
+		{"600", 600, false},
+		{"65535", 65535, false},
+		{"-1", 0, true},
+		{"65536", 0, true},
+		{"25s", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseKeepalive(tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("parseKeepalive(%q) = (%d, %v), want (%d, wantErr %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestKeepaliveDefault(t *testing.T) {
+	cfg := config.New(filepath.Join(t.TempDir(), "config"))
+	if got := keepalive(cfg); got != 25 {
+		t.Errorf("keepalive() default = %d, want 25", got)
+	}
+	if err := cfg.Set(config.KeyWGKeepalive, "0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := keepalive(cfg); got != 0 {
+		t.Errorf("keepalive() = %d, want 0", got)
+	}
+}
+
+func TestKeepaliveDisabledOmitsLine(t *testing.T) {
+	client := testClientConfig()
+	client.Keepalive = 0
+	if strings.Contains(client.render(), "PersistentKeepalive") {
+		t.Error("client config should omit PersistentKeepalive when disabled")
+	}
+	if strings.Contains(buildServerPeerBlock("phone", client.ServerPublicKey, "", "10.253.0.2/32", 0), "PersistentKeepalive") {
+		t.Error("server peer block should omit PersistentKeepalive when disabled")
+	}
+	if !strings.Contains(buildServerPeerBlock("phone", client.ServerPublicKey, "", "10.253.0.2/32", 25), "PersistentKeepalive = 25\n") {
+		t.Error("server peer block should include PersistentKeepalive")
+	}
+}
+
+func TestValidateOptionalCIDRList(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"", false},
+		{"192.168.1.0/24", false},
+		{" 192.168.1.0/24 , 10.0.0.0/8,", false},
+		{"192.168.1.0/24, fd00::/64", false},
+		{"192.168.1.0", true},
+		{"192.168.1.0/24, lan", true},
+		{"192.168.1.0/33", true},
+	}
+	for _, tt := range tests {
+		err := validateOptionalCIDRList(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateOptionalCIDRList(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+	}
+}
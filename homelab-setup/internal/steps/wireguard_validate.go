@@ -0,0 +1,196 @@
+package steps
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// publicKeyCommentPattern matches the "# Public key: ..." comment writeConfig
+// puts in the [Interface] section
+var publicKeyCommentPattern = regexp.MustCompile(`(?mi)^\s*#\s*Public key:\s*(\S+)\s*$`)
+
+// wireGuardPublicKey derives the public key of a WireGuard private key
+func wireGuardPublicKey(privateKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("private key is not valid base64")
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// peerAllowedIP is one AllowedIPs entry of a peer, in canonical CIDR form
+type peerAllowedIP struct {
+	peer string
+	cidr string
+}
+
+// peerLabel names a peer in lint messages
+func peerLabel(peer wireGuardPeerBlock, index int) string {
+	if peer.Comment != "" {
+		return fmt.Sprintf("peer %q", peer.Comment)
+	}
+	return fmt.Sprintf("peer #%d", index+1)
+}
+
+// canonicalAllowedIP returns entry as a network in CIDR notation; bare
+// addresses become /32 or /128
+func canonicalAllowedIP(entry string) (string, error) {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return "", fmt.Errorf("invalid address %q", entry)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	if err := common.ValidateCIDR(entry); err != nil {
+		return "", err
+	}
+	cidr, _, _, err := deriveNetworkCIDR(entry)
+	return cidr, err
+}
+
+// lintWireGuardConfig checks a server config for mistakes that stop the
+// interface from coming up or silently misroute traffic
+func lintWireGuardConfig(content string) []error {
+	var problems []error
+	parsed := parseWireGuardConfig(content)
+
+	privateKey, ok := lookupPeerValue(parsed.Interface, "PrivateKey")
+	if !ok {
+		problems = append(problems, fmt.Errorf("[Interface] is missing PrivateKey"))
+	} else if err := common.ValidateWireGuardKey(privateKey); err != nil {
+		problems = append(problems, fmt.Errorf("[Interface] PrivateKey: %w", err))
+	} else if match := publicKeyCommentPattern.FindStringSubmatch(content); match != nil {
+		if derived, err := wireGuardPublicKey(privateKey); err == nil && derived != match[1] {
+			problems = append(problems, fmt.Errorf("[Interface] PrivateKey does not match the advertised public key %s (it derives %s)", match[1], derived))
+		}
+	}
+
+	port, ok := lookupPeerValue(parsed.Interface, "ListenPort")
+	if !ok {
+		problems = append(problems, fmt.Errorf("[Interface] is missing ListenPort; peers cannot reach a server with a random port"))
+	} else if err := common.ValidatePort(port); err != nil {
+		problems = append(problems, fmt.Errorf("[Interface] ListenPort: %w", err))
+	}
+
+	var serverIPs []string
+	if address, ok := lookupPeerValue(parsed.Interface, "Address"); !ok {
+		problems = append(problems, fmt.Errorf("[Interface] is missing Address"))
+	} else {
+		for _, entry := range splitConfigList(address) {
+			ip, _, err := net.ParseCIDR(entry)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("[Interface] Address %q is not in CIDR notation", entry))
+				continue
+			}
+			if ip.To4() != nil {
+				serverIPs = append(serverIPs, ip.String()+"/32")
+			} else {
+				serverIPs = append(serverIPs, ip.String()+"/128")
+			}
+		}
+	}
+
+	publicKeys := make(map[string]string)
+	var allowed []peerAllowedIP
+	for i, peer := range parsed.Peers {
+		label := peerLabel(peer, i)
+
+		publicKey, ok := lookupPeerValue(peer.Values, "PublicKey")
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s is missing PublicKey", label))
+		} else if err := common.ValidateWireGuardKey(publicKey); err != nil {
+			problems = append(problems, fmt.Errorf("%s PublicKey: %w", label, err))
+		} else if other, exists := publicKeys[publicKey]; exists {
+			problems = append(problems, fmt.Errorf("%s has the same PublicKey as %s", label, other))
+		} else {
+			publicKeys[publicKey] = label
+		}
+
+		allowedIPs, ok := lookupPeerValue(peer.Values, "AllowedIPs")
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s is missing AllowedIPs", label))
+			continue
+		}
+		for _, entry := range splitConfigList(allowedIPs) {
+			cidr, err := canonicalAllowedIP(entry)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s AllowedIPs: %w", label, err))
+				continue
+			}
+			for _, serverIP := range serverIPs {
+				if overlap, _ := common.CIDRsOverlap(cidr, serverIP); overlap {
+					problems = append(problems, fmt.Errorf("%s AllowedIPs %s includes the server address %s", label, cidr, serverIP))
+				}
+			}
+			allowed = append(allowed, peerAllowedIP{peer: label, cidr: cidr})
+		}
+	}
+
+	// WireGuard routes each address to a single peer, so an address claimed
+	// by two peers only works for whichever was loaded last
+	for i := range allowed {
+		for j := i + 1; j < len(allowed); j++ {
+			a, b := allowed[i], allowed[j]
+			if a.peer == b.peer {
+				continue
+			}
+			if a.cidr == b.cidr {
+				problems = append(problems, fmt.Errorf("duplicate AllowedIPs %s in %s and %s", a.cidr, a.peer, b.peer))
+			} else if overlap, _ := common.CIDRsOverlap(a.cidr, b.cidr); overlap {
+				problems = append(problems, fmt.Errorf("AllowedIPs %s of %s overlaps %s of %s", a.cidr, a.peer, b.cidr, b.peer))
+			}
+		}
+	}
+
+	return problems
+}
+
+// ValidateWireGuardConfig parses the WireGuard server config at path and
+// reports common mistakes: a missing or invalid ListenPort, Address or key, a
+// private key that does not match the advertised public key, and AllowedIPs
+// that are duplicated or overlap across peers.
+func ValidateWireGuardConfig(path string) []error {
+	content, err := system.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("failed to read %s: %w", path, err)}
+	}
+	return lintWireGuardConfig(string(content))
+}
+
+// checkWireGuardConfig validates the config of interfaceName and prints the
+// problems found, returning an error if there are any. It is skipped in
+// dry-run mode, where the config is not written.
+func checkWireGuardConfig(cfg *config.Config, ui *ui.UI, interfaceName string) error {
+	if system.DryRun() {
+		return nil
+	}
+	path := filepath.Join(configDir(cfg), interfaceName+".conf")
+	problems := ValidateWireGuardConfig(path)
+	if len(problems) == 0 {
+		ui.Successf("WireGuard configuration %s is valid", path)
+		return nil
+	}
+	ui.Warningf("Found %d problem(s) in %s:", len(problems), path)
+	for _, problem := range problems {
+		ui.Warningf("  - %v", problem)
+	}
+	return fmt.Errorf("%s has %d problem(s)", path, len(problems))
+}
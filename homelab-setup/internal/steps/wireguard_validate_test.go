@@ -0,0 +1,100 @@
+package steps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testServerPrivateKey = "YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	testServerPublicKey  = "Y2FD6uTgrq+/bbBAXCNOtE+PGk8Papoh7qaWBpQUXn0="
+	testPeerKeyA         = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	testPeerKeyB         = "TUjXtouIMqALW5qpvFQgUzHztaFLco7QLw9JW5z2Xjk="
+)
+
+func testServerConfig(publicKey, listenPort, peers string) string {
+	content := "[Interface]\n# Public key: " + publicKey + "\nAddress = 10.253.0.1/24\n"
+	if listenPort != "" {
+		content += "ListenPort = " + listenPort + "\n"
+	}
+	return content + "PrivateKey = " + testServerPrivateKey + "\n" + peers
+}
+
+func TestValidateWireGuardConfig(t *testing.T) {
+	valid := testServerConfig(testServerPublicKey, "51820",
+		"\n# Peer: laptop\n[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 10.253.0.2/32\n"+
+			"\n# Peer: phone\n[Peer]\nPublicKey = "+testPeerKeyB+"\nAllowedIPs = 10.253.0.3/32, 192.168.50.0/24\n")
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	if err := os.WriteFile(path, []byte(valid), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if problems := ValidateWireGuardConfig(path); len(problems) != 0 {
+		t.Errorf("ValidateWireGuardConfig() on a valid config = %v, want none", problems)
+	}
+
+	if problems := ValidateWireGuardConfig(filepath.Join(t.TempDir(), "missing.conf")); len(problems) != 1 {
+		t.Errorf("ValidateWireGuardConfig() on a missing file = %v, want one read error", problems)
+	}
+}
+
+func TestLintWireGuardConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "duplicate AllowedIPs",
+			content: testServerConfig(testServerPublicKey, "51820",
+				"\n# Peer: laptop\n[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 10.253.0.2/32\n"+
+					"\n# Peer: phone\n[Peer]\nPublicKey = "+testPeerKeyB+"\nAllowedIPs = 10.253.0.2\n"),
+			want: []string{`duplicate AllowedIPs 10.253.0.2/32 in peer "laptop" and peer "phone"`},
+		},
+		{
+			name: "overlapping AllowedIPs",
+			content: testServerConfig(testServerPublicKey, "51820",
+				"[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 192.168.0.0/16\n"+
+					"[Peer]\nPublicKey = "+testPeerKeyB+"\nAllowedIPs = 192.168.50.0/24\n"),
+			want: []string{"AllowedIPs 192.168.0.0/16 of peer #1 overlaps 192.168.50.0/24 of peer #2"},
+		},
+		{
+			name: "peer claims the server address",
+			content: testServerConfig(testServerPublicKey, "51820",
+				"[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 10.253.0.1/32\n"),
+			want: []string{"includes the server address 10.253.0.1/32"},
+		},
+		{
+			name:    "missing ListenPort",
+			content: testServerConfig(testServerPublicKey, "", ""),
+			want:    []string{"missing ListenPort"},
+		},
+		{
+			name:    "private key does not match public key",
+			content: testServerConfig(testPeerKeyA, "51820", ""),
+			want:    []string{"does not match the advertised public key"},
+		},
+		{
+			name: "duplicate and invalid peer keys",
+			content: testServerConfig(testServerPublicKey, "51820",
+				"[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 10.253.0.2/32\n"+
+					"[Peer]\nPublicKey = "+testPeerKeyA+"\nAllowedIPs = 10.253.0.3/32\n"+
+					"[Peer]\nPublicKey = not-a-key\nAllowedIPs = 10.253.0.4/32\n"),
+			want: []string{"peer #2 has the same PublicKey as peer #1", "peer #3 PublicKey"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := lintWireGuardConfig(tt.content)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("lintWireGuardConfig() = %v, want %d problem(s)", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i].Error(), want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
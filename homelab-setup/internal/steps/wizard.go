@@ -0,0 +1,170 @@
+package steps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/config"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/system"
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/ui"
+)
+
+// standardStacks returns the stacks created under CONTAINERS_BASE, offered by
+// the wizard when no compose templates are installed yet
+func standardStacks() map[string]string {
+	stacks := make(map[string]string, len(containerServiceDirs))
+	for _, svc := range containerServiceDirs {
+		stacks[svc.name] = svc.name + ".yml"
+	}
+	return stacks
+}
+
+// wizardStacks returns the stacks to choose from: those with an installed
+// compose template, or the standard stacks when none are found
+func wizardStacks(cfg *config.Config, ui *ui.UI) map[string]string {
+	templateDir, err := findTemplateDirectory(cfg, ui)
+	if err == nil {
+		stacks, err := discoverStacks(cfg, ui, templateDir)
+		if err == nil && len(stacks) > 0 {
+			return stacks
+		}
+	}
+	ui.Info("Choosing from the standard stacks; install the compose templates before running container setup")
+	return standardStacks()
+}
+
+// RunFirstRunWizard walks a new user through the essential settings (homelab
+// user, containers base, timezone, stacks, and optionally NFS and WireGuard)
+// in one guided flow and saves them together at the end. The setup steps
+// then offer these values as their defaults. It does nothing in
+// non-interactive mode.
+func RunFirstRunWizard(cfg *config.Config, ui *ui.UI) error {
+	if ui.IsNonInteractive() {
+		return nil
+	}
+
+	ui.Header("First-Run Setup")
+	ui.Info("No configuration was found, so this looks like a first run.")
+	ui.Info("The wizard asks for the essential settings up front; every question has a default.")
+	ui.Print("")
+	start, err := ui.PromptYesNo("Run the first-run wizard now?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !start {
+		ui.Info("Skipped; each setup step will ask for the values it needs")
+		return nil
+	}
+
+	values := make(map[string]string)
+
+	// Homelab user
+	ui.Step("Homelab User")
+	defaultUser := ""
+	if current, err := system.GetCurrentUser(); err == nil {
+		defaultUser = current.Username
+	}
+	username, err := ui.PromptInputWithValidation("Homelab username (created during user setup if missing)", defaultUser, common.ValidateUsername)
+	if err != nil {
+		return fmt.Errorf("failed to prompt for username: %w", err)
+	}
+	values[config.KeyHomelabUser] = username
+
+	// Containers base directory
+	ui.Step("Container Services Directory")
+	base, err := ui.PromptInputWithValidation("Containers base directory", cfg.GetOrDefault(config.KeyContainersBase, ""), func(value string) error {
+		_, err := normalizeBasePath(value)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prompt for containers directory: %w", err)
+	}
+	base, err = normalizeBasePath(base)
+	if err != nil {
+		return fmt.Errorf("invalid containers base directory: %w", err)
+	}
+	if err := confirmBasePath(base, ui); err != nil {
+		return err
+	}
+	values[config.KeyContainersBase] = base
+
+	// Timezone
+	ui.Step("Timezone")
+	tz, err := chooseTimezone(cfg, ui)
+	if err != nil {
+		return err
+	}
+	values["TIMEZONE"] = tz
+	values["TZ"] = tz
+
+	// Stacks
+	stacks := wizardStacks(cfg, ui)
+	selected, err := pickStacks(cfg, ui, stacks)
+	if err != nil {
+		return err
+	}
+	if values[config.KeySelectedServices], err = normalizeSelectedServices(strings.Join(selected, " "), stacks); err != nil {
+		return err
+	}
+
+	// NFS (optional)
+	ui.Step("NFS Storage (optional)")
+	useNFS, err := promptForNFS(cfg, ui)
+	if err != nil {
+		return err
+	}
+	if useNFS {
+		host, export, mountPoint, err := promptForNFSDetails(cfg, ui)
+		if err != nil {
+			return err
+		}
+		values[config.KeyNFSServer] = host
+		values[config.KeyNFSExport] = export
+		values[config.KeyNFSMountPoint] = mountPoint
+	}
+
+	// WireGuard (optional); its settings are asked for by the WireGuard step
+	ui.Step("WireGuard VPN (optional)")
+	useWireGuard, err := promptForWireGuard(cfg, ui)
+	if err != nil {
+		return err
+	}
+	if useWireGuard {
+		// Lets preflight check the kernel module before the WireGuard step
+		values["WIREGUARD_ENABLED"] = "true"
+	}
+
+	ui.Step("Review")
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, []string{key, values[key]})
+	}
+	ui.Table([]string{"Setting", "Value"}, rows)
+	save, err := ui.PromptYesNo("Save this configuration?", true)
+	if err != nil {
+		return fmt.Errorf("failed to prompt: %w", err)
+	}
+	if !save {
+		ui.Info("Nothing was saved; the wizard runs again next time")
+		return nil
+	}
+
+	if err := saveConfigValues(cfg, ui, values); err != nil {
+		return err
+	}
+	ui.Successf("Configuration saved to %s", cfg.FilePath())
+	ui.Print("")
+	if useWireGuard {
+		ui.Info("Next: choose [A] Run All Steps (Complete Setup) from the menu")
+	} else {
+		ui.Info("Next: choose [Q] Quick Setup (Skip WireGuard) from the menu")
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package system
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveMembers converts absolute directories to the root-relative names
+// stored in an archive, so that extracting with -C / restores them in place
+func archiveMembers(dirs []string) ([]string, error) {
+	members := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("refusing to archive relative path: %s (must be absolute)", dir)
+		}
+		member := strings.TrimPrefix(filepath.Clean(dir), "/")
+		if member == "" {
+			return nil, fmt.Errorf("refusing to archive /")
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// ArchiveDirectories writes a gzip-compressed tar of dirs (absolute paths) to
+// archivePath, keeping numeric ownership and permissions so files owned by
+// container sub-UIDs survive a restore. The archive is readable by root only
+// since appdata holds application secrets.
+func ArchiveDirectories(archivePath string, dirs []string) error {
+	members, err := archiveMembers(dirs)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("no directories to archive")
+	}
+
+	args := append([]string{"--create", "--gzip", "--numeric-owner", "--file", archivePath, "-C", "/", "--"}, members...)
+	if output, err := RunPrivileged("tar", args...); err != nil {
+		return fmt.Errorf("failed to create archive %s: %w\nOutput: %s", archivePath, err, string(output))
+	}
+	return Chmod(archivePath, 0600)
+}
+
+// ArchiveEntry is one member of an archive
+type ArchiveEntry struct {
+	Name     string // as stored in the archive
+	Path     string // absolute, cleaned extraction path (archives extract to /)
+	Type     byte   // tar.Type* flag
+	Linkname string // target of a symlink or hard link
+}
+
+// readArchiveEntries lists the members of a gzip-compressed tar stream
+func readArchiveEntries(r io.Reader) ([]ArchiveEntry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	var entries []ArchiveEntry
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		entries = append(entries, ArchiveEntry{
+			Name:     hdr.Name,
+			Path:     filepath.Join("/", hdr.Name),
+			Type:     hdr.Typeflag,
+			Linkname: hdr.Linkname,
+		})
+	}
+}
+
+// ReadArchiveEntries lists the members of an archive created by
+// ArchiveDirectories. It reads through sudo since archives are root-only.
+func ReadArchiveEntries(archivePath string) ([]ArchiveEntry, error) {
+	cmd := exec.Command("sudo", "-n", "cat", "--", archivePath)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		auditCommand(cmd.Args, start, err)
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+
+	entries, readErr := readArchiveEntries(stdout)
+	// Drain so cat is not killed by a closed pipe after a parse error
+	_, _ = io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	auditCommand(cmd.Args, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("invalid archive %s: %w", archivePath, readErr)
+	}
+	return entries, nil
+}
+
+// ExtractArchive restores an archive created by ArchiveDirectories to its
+// original locations, keeping ownership and permissions
+func ExtractArchive(archivePath string) error {
+	args := []string{"--extract", "--gzip", "--numeric-owner", "--same-permissions", "--file", archivePath, "-C", "/"}
+	if output, err := RunPrivileged("tar", args...); err != nil {
+		return fmt.Errorf("failed to extract archive %s: %w\nOutput: %s", archivePath, err, string(output))
+	}
+	return nil
+}
+
+// parseDuTotal returns the total of "du -s -b" output lines
+func parseDuTotal(output string) (int64, error) {
+	var total int64
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected du output %q", line)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// DirectorySize returns the apparent size in bytes of everything under paths.
+// It runs with sudo because appdata may be owned by container sub-UIDs.
+func DirectorySize(paths ...string) (int64, error) {
+	args := append([]string{"-n", "du", "-s", "-b", "--"}, paths...)
+	cmd := exec.Command("sudo", args...)
+	start := time.Now()
+	output, err := cmd.Output()
+	auditCommand(cmd.Args, start, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", strings.Join(paths, ", "), err)
+	}
+	return parseDuTotal(string(output))
+}
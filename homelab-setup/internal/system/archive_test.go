@@ -0,0 +1,79 @@
+package system
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveMembers(t *testing.T) {
+	got, err := archiveMembers([]string{"/var/lib/containers/appdata/plex/", "/mnt/ssd/plex"})
+	if err != nil {
+		t.Fatalf("archiveMembers() error = %v", err)
+	}
+	if want := []string{"var/lib/containers/appdata/plex", "mnt/ssd/plex"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("archiveMembers() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"relative/plex", "/"} {
+		if _, err := archiveMembers([]string{bad}); err == nil {
+			t.Errorf("archiveMembers(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestReadArchiveEntries(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range []*tar.Header{
+		{Name: "var/lib/containers/appdata/plex/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "var/lib/containers/appdata/plex/Preferences.xml", Typeflag: tar.TypeReg, Mode: 0644, Size: 2},
+		{Name: "var/lib/containers/appdata/plex/link", Typeflag: tar.TypeSymlink, Linkname: "Preferences.xml"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte("ok")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readArchiveEntries(&buf)
+	if err != nil {
+		t.Fatalf("readArchiveEntries() error = %v", err)
+	}
+	want := []ArchiveEntry{
+		{Name: "var/lib/containers/appdata/plex/", Path: "/var/lib/containers/appdata/plex", Type: tar.TypeDir},
+		{Name: "var/lib/containers/appdata/plex/Preferences.xml", Path: "/var/lib/containers/appdata/plex/Preferences.xml", Type: tar.TypeReg},
+		{Name: "var/lib/containers/appdata/plex/link", Path: "/var/lib/containers/appdata/plex/link", Type: tar.TypeSymlink, Linkname: "Preferences.xml"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("readArchiveEntries() = %+v, want %+v", entries, want)
+	}
+
+	if _, err := readArchiveEntries(strings.NewReader("not gzip")); err == nil {
+		t.Error("expected an error for a non-gzip stream")
+	}
+}
+
+func TestParseDuTotal(t *testing.T) {
+	got, err := parseDuTotal("1024\t/var/lib/containers/appdata/plex\n2048\t/var/lib/containers/appdata/jellyfin\n")
+	if err != nil || got != 3072 {
+		t.Errorf("parseDuTotal() = (%d, %v), want (3072, nil)", got, err)
+	}
+	if _, err := parseDuTotal("du: cannot access\n"); err == nil {
+		t.Error("expected an error for unexpected output")
+	}
+}
@@ -0,0 +1,82 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// AuditRedactor masks secret values in an audited command line
+type AuditRedactor func(string) string
+
+var (
+	auditMu     sync.Mutex
+	auditPath   string
+	auditRedact AuditRedactor
+)
+
+// SetAuditLog sets the file every privileged (sudo) command is appended to,
+// with its time, duration and exit status. redact masks known secrets in the
+// command line before it is written. An empty path disables the audit log.
+func SetAuditLog(path string, redact AuditRedactor) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditPath = path
+	auditRedact = redact
+}
+
+// secretOptionPattern matches credentials passed inline, such as CIFS mount
+// options or --password=...
+var secretOptionPattern = regexp.MustCompile(`(?i)((?:^|[,\s-])(?:password|passwd|pass|token|secret)=)[^,\s]+`)
+
+// redactAuditLine masks inline credentials and the values matched by redact
+func redactAuditLine(line string, redact AuditRedactor) string {
+	line = secretOptionPattern.ReplaceAllString(line, "${1}***")
+	if redact != nil {
+		line = redact(line)
+	}
+	return line
+}
+
+// auditStatus describes how a command ended for the audit log
+func auditStatus(err error) string {
+	if err == nil {
+		return "exit=0"
+	}
+	if code := exitCode(err); code >= 0 {
+		return fmt.Sprintf("exit=%d", code)
+	}
+	return fmt.Sprintf("error=%q", err.Error())
+}
+
+// auditCommand appends a finished command to the audit log if it ran with
+// sudo. The log is append-only and private to the user; failing to write it
+// does not fail the command.
+func auditCommand(argv []string, start time.Time, err error) {
+	if len(argv) == 0 || filepath.Base(argv[0]) != "sudo" {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if auditPath == "" {
+		return
+	}
+
+	line := redactAuditLine(commandLine("sudo", argv[1:]), auditRedact)
+	entry := fmt.Sprintf("%s %s duration=%s %s\n",
+		start.Format(time.RFC3339), auditStatus(err), time.Since(start).Round(time.Millisecond), line)
+
+	if err := os.MkdirAll(filepath.Dir(auditPath), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(entry)
+}
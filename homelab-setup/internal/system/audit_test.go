@@ -0,0 +1,100 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSudo puts a "sudo" on PATH that exits 3 when its arguments mention
+// "fail" and 0 otherwise
+func fakeSudo(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$*\" in *fail*) exit 3;; esac\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAuditPrivilegedCommands(t *testing.T) {
+	fakeSudo(t)
+	logPath := filepath.Join(t.TempDir(), "state", "audit.log")
+	SetAuditLog(logPath, func(line string) string { return strings.ReplaceAll(line, "hunter22", "***") })
+	t.Cleanup(func() { SetAuditLog("", nil) })
+
+	if _, err := RunPrivileged("systemctl", "start", "plex.service"); err != nil {
+		t.Fatalf("RunPrivileged() error = %v", err)
+	}
+	if _, err := RunPrivileged("mount", "-o", "username=me,password=s3cret", "//nas/fail", "/mnt/x"); err == nil {
+		t.Fatal("RunPrivileged() with a failing command succeeded")
+	}
+	if _, _, err := (ExecRunner{}).Run(context.Background(), "sudo", "-n", "wg", "set", "wg0", "private-key", "hunter22"); err != nil {
+		t.Fatalf("ExecRunner.Run() error = %v", err)
+	}
+	// Unprivileged commands are not audited
+	if _, _, err := (ExecRunner{}).Run(context.Background(), "true"); err != nil {
+		t.Fatalf("ExecRunner.Run() error = %v", err)
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("audit log has %d entries, want 3:\n%s", len(lines), content)
+	}
+
+	checks := []struct {
+		contains []string
+		excludes string
+	}{
+		{[]string{"exit=0", "sudo -n systemctl start plex.service"}, ""},
+		{[]string{"exit=3", "password=***"}, "s3cret"},
+		{[]string{"exit=0", "private-key ***"}, "hunter22"},
+	}
+	for i, check := range checks {
+		fields := strings.Fields(lines[i])
+		if _, err := time.Parse(time.RFC3339, fields[0]); err != nil {
+			t.Errorf("entry %d does not start with a timestamp: %q", i, lines[i])
+		}
+		for _, want := range check.contains {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("entry %d = %q, want it to contain %q", i, lines[i], want)
+			}
+		}
+		if check.excludes != "" && strings.Contains(lines[i], check.excludes) {
+			t.Errorf("entry %d = %q leaks %q", i, lines[i], check.excludes)
+		}
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("audit log permissions = %o, want 600", perm)
+	}
+}
+
+func TestAuditStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "exit=0"},
+		{&FakeExitError{Code: 1}, "exit=1"},
+		{errors.New("signal: killed"), `error="signal: killed"`},
+	}
+	for _, tt := range tests {
+		if got := auditStatus(tt.err); got != tt.want {
+			t.Errorf("auditStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
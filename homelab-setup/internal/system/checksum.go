@@ -0,0 +1,84 @@
+package system
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by VerifyChecksum when a file's contents do
+// not match the expected digest
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of a file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// normalizeSHA256 lower-cases a hex SHA-256 digest, accepting an optional
+// "sha256:" prefix, and rejects anything that is not 64 hex digits
+func normalizeSHA256(digest string) (string, error) {
+	digest = strings.ToLower(strings.TrimSpace(digest))
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid SHA-256 checksum %q: want %d hex digits", digest, sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", fmt.Errorf("invalid SHA-256 checksum %q: not hexadecimal", digest)
+	}
+	return digest, nil
+}
+
+// VerifyChecksum compares the SHA-256 digest of path with expected (hex,
+// optionally prefixed with "sha256:"). A mismatch wraps ErrChecksumMismatch.
+func VerifyChecksum(path, expected string) error {
+	want, err := normalizeSHA256(expected)
+	if err != nil {
+		return err
+	}
+	got, err := FileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w for %s: expected %s, got %s", ErrChecksumMismatch, path, want, got)
+	}
+	return nil
+}
+
+// ParseChecksumManifest parses sha256sum output ("<digest>  <file>", with
+// "*<file>" for binary mode) into a map from file name to digest. Blank lines
+// and comments are skipped.
+func ParseChecksumManifest(content string) (map[string]string, error) {
+	sums := make(map[string]string)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum manifest line %d: %q", i+1, line)
+		}
+		digest, err := normalizeSHA256(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum manifest line %d: %w", i+1, err)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = digest
+	}
+	return sums, nil
+}
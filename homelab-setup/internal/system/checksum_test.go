@@ -0,0 +1,79 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testComposeSHA256 is a well-formed digest that matches none of the test files
+const testComposeSHA256 = "0b2a5b4bb54d1a7ea4adc2d2c9e8cbf8e2df4e7a3ea1a6b1a1e9f5d9a4b0f9c1"
+
+func writeChecksumFile(t *testing.T, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := FileSHA256(path)
+	if err != nil {
+		t.Fatalf("FileSHA256() error = %v", err)
+	}
+	return path, digest
+}
+
+func TestFileSHA256(t *testing.T) {
+	_, digest := writeChecksumFile(t, "")
+	if want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; digest != want {
+		t.Errorf("FileSHA256(empty) = %s, want %s", digest, want)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path, digest := writeChecksumFile(t, "services:\n")
+
+	for _, expected := range []string{digest, strings.ToUpper(digest), "sha256:" + digest, " " + digest + "\n"} {
+		if err := VerifyChecksum(path, expected); err != nil {
+			t.Errorf("VerifyChecksum(%q) error = %v", expected, err)
+		}
+	}
+
+	if err := VerifyChecksum(path, testComposeSHA256); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyChecksum() with wrong digest error = %v, want ErrChecksumMismatch", err)
+	}
+
+	for _, invalid := range []string{"", "abc", strings.Repeat("z", 64)} {
+		err := VerifyChecksum(path, invalid)
+		if err == nil || errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("VerifyChecksum(%q) error = %v, want an invalid checksum error", invalid, err)
+		}
+	}
+
+	if err := VerifyChecksum(filepath.Join(t.TempDir(), "missing.yml"), digest); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestParseChecksumManifest(t *testing.T) {
+	content := "# compose templates\n" +
+		testComposeSHA256 + "  media.yml\n" +
+		"\n" +
+		strings.ToUpper(testComposeSHA256) + " *web.yml\n"
+
+	sums, err := ParseChecksumManifest(content)
+	if err != nil {
+		t.Fatalf("ParseChecksumManifest() error = %v", err)
+	}
+	if len(sums) != 2 || sums["media.yml"] != testComposeSHA256 || sums["web.yml"] != testComposeSHA256 {
+		t.Errorf("ParseChecksumManifest() = %v", sums)
+	}
+
+	if _, err := ParseChecksumManifest("not-a-digest  media.yml\n"); err == nil {
+		t.Error("expected an error for an invalid digest")
+	}
+	if _, err := ParseChecksumManifest(testComposeSHA256 + "\n"); err == nil {
+		t.Error("expected an error for a line without a file name")
+	}
+}
@@ -0,0 +1,182 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeSync is the NTP state reported by timedatectl
+type TimeSync struct {
+	NTPEnabled   bool // a time synchronization service is enabled
+	Synchronized bool // the system clock is synchronized to a time source
+}
+
+// parseTimedatectlShow extracts NTP and NTPSynchronized from "timedatectl
+// show" output
+func parseTimedatectlShow(output string) (TimeSync, error) {
+	var status TimeSync
+	found := 0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "NTP":
+			status.NTPEnabled = value == "yes"
+			found++
+		case "NTPSynchronized":
+			status.Synchronized = value == "yes"
+			found++
+		}
+	}
+	if found < 2 {
+		return TimeSync{}, fmt.Errorf("NTP or NTPSynchronized missing from timedatectl output")
+	}
+	return status, nil
+}
+
+// GetTimeSync returns whether NTP is enabled and the clock is synchronized
+func GetTimeSync() (TimeSync, error) {
+	output, err := exec.Command("timedatectl", "show", "--property=NTP", "--property=NTPSynchronized").Output()
+	if err != nil {
+		return TimeSync{}, fmt.Errorf("timedatectl failed: %w", err)
+	}
+	return parseTimedatectlShow(string(output))
+}
+
+// parseChronyTracking returns the offset of the system clock from NTP time
+// in "chronyc tracking" output; positive means the clock is fast
+func parseChronyTracking(output string) (time.Duration, error) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "System time" {
+			continue
+		}
+		// e.g. "0.000012345 seconds fast of NTP time"
+		fields := strings.Fields(value)
+		if len(fields) < 3 {
+			return 0, fmt.Errorf("unexpected chronyc tracking line %q", line)
+		}
+		seconds, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected chronyc tracking line %q", line)
+		}
+		offset := time.Duration(seconds * float64(time.Second))
+		switch fields[2] {
+		case "fast":
+			return offset, nil
+		case "slow":
+			return -offset, nil
+		default:
+			return 0, fmt.Errorf("unexpected chronyc tracking line %q", line)
+		}
+	}
+	return 0, fmt.Errorf("system time missing from chronyc tracking output")
+}
+
+// ChronyOffset returns how far the system clock is ahead of NTP time
+// according to chronyd (negative when behind)
+func ChronyOffset() (time.Duration, error) {
+	output, err := exec.Command("chronyc", "tracking").Output()
+	if err != nil {
+		return 0, fmt.Errorf("chronyc tracking failed: %w", err)
+	}
+	return parseChronyTracking(string(output))
+}
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// ntpPacketSize is the size of an SNTP request or response without extensions
+const ntpPacketSize = 48
+
+// toNTPTime encodes t as a 64-bit NTP timestamp
+func toNTPTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// fromNTPTime decodes a 64-bit NTP timestamp
+func fromNTPTime(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := (int64(ts&0xffffffff) * int64(time.Second)) >> 32
+	return time.Unix(secs, nanos)
+}
+
+// parseNTPResponse checks an SNTP server response to a request sent at sent
+// and received at received, and returns how far the local clock is ahead of
+// the server (negative when behind)
+func parseNTPResponse(resp []byte, sent, received time.Time) (time.Duration, error) {
+	if len(resp) < ntpPacketSize {
+		return 0, fmt.Errorf("short NTP response (%d bytes)", len(resp))
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP response mode %d", mode)
+	}
+	if leap := resp[0] >> 6; leap == 3 {
+		return 0, fmt.Errorf("NTP server is not synchronized")
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return 0, fmt.Errorf("NTP server refused the request (kiss code %q)", bytes.TrimRight(resp[12:16], "\x00"))
+	}
+	origin := binary.BigEndian.Uint64(resp[24:32])
+	if origin != toNTPTime(sent) {
+		return 0, fmt.Errorf("NTP response does not match the request")
+	}
+
+	serverReceive := fromNTPTime(binary.BigEndian.Uint64(resp[32:40]))
+	serverTransmit := fromNTPTime(binary.BigEndian.Uint64(resp[40:48]))
+	// Standard NTP offset: ((t2 - t1) + (t3 - t4)) / 2 is the server's lead
+	lead := (serverReceive.Sub(sent) + serverTransmit.Sub(received)) / 2
+	return -lead, nil
+}
+
+// QueryNTPOffset asks server (host or host:port) for the time over SNTP and
+// returns how far the local clock is ahead of it (negative when behind)
+func QueryNTPOffset(ctx context.Context, server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to contact NTP server %s: %w", server, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := make([]byte, ntpPacketSize)
+	req[0] = 4<<3 | 3 // version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:48], toNTPTime(sent))
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("failed to query NTP server %s: %w", server, err)
+	}
+
+	resp := make([]byte, 512)
+	n, err := conn.Read(resp)
+	received := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("no response from NTP server %s: %w", server, err)
+	}
+	offset, err := parseNTPResponse(resp[:n], sent, received)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", server, err)
+	}
+	return offset, nil
+}
@@ -0,0 +1,135 @@
+package system
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseTimedatectlShow(t *testing.T) {
+	status, err := parseTimedatectlShow("NTP=yes\nNTPSynchronized=no\n")
+	if err != nil {
+		t.Fatalf("parseTimedatectlShow() error = %v", err)
+	}
+	if !status.NTPEnabled || status.Synchronized {
+		t.Errorf("parseTimedatectlShow() = %+v, want NTP enabled and not synchronized", status)
+	}
+
+	if _, err := parseTimedatectlShow("Timezone=UTC\n"); err == nil {
+		t.Error("parseTimedatectlShow() accepted output without NTP properties")
+	}
+}
+
+func TestParseChronyTracking(t *testing.T) {
+	const tracking = `Reference ID    : C0A80101 (gateway.lan)
+Stratum         : 3
+Ref time (UTC)  : Thu Oct 15 10:00:00 2026
+System time     : %s
+Last offset     : +0.000001234 seconds
+`
+	tests := []struct {
+		line    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"0.250000000 seconds fast of NTP time", 250 * time.Millisecond, false},
+		{"1.500000000 seconds slow of NTP time", -1500 * time.Millisecond, false},
+		{"garbage", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseChronyTracking(fmt.Sprintf(tracking, tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChronyTracking(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseChronyTracking(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	if _, err := parseChronyTracking("506 Cannot talk to daemon\n"); err == nil {
+		t.Error("parseChronyTracking() accepted output without a system time line")
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	want := time.Date(2026, 10, 15, 12, 30, 45, 123456789, time.UTC)
+	got := fromNTPTime(toNTPTime(want))
+	if diff := got.Sub(want); diff < -time.Microsecond || diff > time.Microsecond {
+		t.Errorf("fromNTPTime(toNTPTime(%v)) = %v", want, got)
+	}
+}
+
+// ntpResponse builds a server response to a request sent at sent, claiming
+// the server clock read serverTime throughout
+func ntpResponse(sent, serverTime time.Time) []byte {
+	resp := make([]byte, ntpPacketSize)
+	resp[0] = 4<<3 | 4 // version 4, server mode
+	resp[1] = 2
+	binary.BigEndian.PutUint64(resp[24:32], toNTPTime(sent))
+	binary.BigEndian.PutUint64(resp[32:40], toNTPTime(serverTime))
+	binary.BigEndian.PutUint64(resp[40:48], toNTPTime(serverTime))
+	return resp
+}
+
+func TestParseNTPResponse(t *testing.T) {
+	sent := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(20 * time.Millisecond)
+
+	// Server is 3s behind the midpoint of the exchange, so we are 3s ahead
+	resp := ntpResponse(sent, sent.Add(10*time.Millisecond).Add(-3*time.Second))
+	offset, err := parseNTPResponse(resp, sent, received)
+	if err != nil {
+		t.Fatalf("parseNTPResponse() error = %v", err)
+	}
+	if diff := offset - 3*time.Second; diff < -time.Millisecond || diff > time.Millisecond {
+		t.Errorf("parseNTPResponse() offset = %v, want 3s", offset)
+	}
+
+	if _, err := parseNTPResponse(resp, sent.Add(time.Second), received); err == nil {
+		t.Error("parseNTPResponse() accepted a response to a different request")
+	}
+
+	kod := ntpResponse(sent, sent)
+	kod[1] = 0
+	copy(kod[12:16], "RATE")
+	if _, err := parseNTPResponse(kod, sent, received); err == nil {
+		t.Error("parseNTPResponse() accepted a kiss-of-death response")
+	}
+
+	if _, err := parseNTPResponse(resp[:20], sent, received); err == nil {
+		t.Error("parseNTPResponse() accepted a short response")
+	}
+}
+
+func TestQueryNTPOffset(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	defer conn.Close()
+
+	// Answer one request from a server clock running an hour behind
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil || n < ntpPacketSize {
+			return
+		}
+		sent := fromNTPTime(binary.BigEndian.Uint64(buf[40:48]))
+		resp := ntpResponse(sent, time.Now().Add(-time.Hour))
+		copy(resp[24:32], buf[40:48])
+		_, _ = conn.WriteTo(resp, addr)
+	}()
+
+	offset, err := QueryNTPOffset(context.Background(), conn.LocalAddr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("QueryNTPOffset() error = %v", err)
+	}
+	if diff := offset - time.Hour; diff < -time.Second || diff > time.Second {
+		t.Errorf("QueryNTPOffset() = %v, want about 1h", offset)
+	}
+}
@@ -5,8 +5,10 @@
 package system
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
@@ -146,6 +148,85 @@ func ListRunningContainers(runtime ContainerRuntime) ([]string, error) {
 	return containers, nil
 }
 
+// composeProjectLabel is the label compose (and podman-compose) puts on
+// every container it starts, holding the project name
+const composeProjectLabel = "com.docker.compose.project"
+
+// RunningComposeProjects returns the sorted names of the compose projects
+// with at least one running container
+func RunningComposeProjects(runtime ContainerRuntime) ([]string, error) {
+	var cmd *exec.Cmd
+
+	switch runtime {
+	case RuntimePodman:
+		cmd = exec.Command("podman", "ps", "--format", fmt.Sprintf("{{index .Labels %q}}", composeProjectLabel))
+	case RuntimeDocker:
+		cmd = exec.Command("docker", "ps", "--format", fmt.Sprintf("{{.Label %q}}", composeProjectLabel))
+	default:
+		return nil, fmt.Errorf("unsupported runtime: %s", runtime)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running containers: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var projects []string
+	for _, line := range strings.Split(string(output), "\n") {
+		project := strings.TrimSpace(line)
+		// Containers not started by compose have no label
+		if project == "" || project == "<no value>" || seen[project] {
+			continue
+		}
+		seen[project] = true
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	return projects, nil
+}
+
+// ListComposeServices returns the sorted service names declared by the
+// compose files, as resolved by "<composeCmd> config --services" (so
+// overrides and profiles are taken into account). composeCmd is the compose
+// command as stored in COMPOSE_COMMAND, e.g. "docker compose".
+func ListComposeServices(composeCmd string, composeFiles ...string) ([]string, error) {
+	return listComposeServices(defaultRunner, composeCmd, composeFiles)
+}
+
+func listComposeServices(runner CommandRunner, composeCmd string, composeFiles []string) ([]string, error) {
+	parts := strings.Fields(composeCmd)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("compose command is empty")
+	}
+	if len(composeFiles) == 0 {
+		return nil, fmt.Errorf("no compose file given")
+	}
+	args := parts[1:]
+	for _, file := range composeFiles {
+		args = append(args, "-f", file)
+	}
+	args = append(args, "config", "--services")
+
+	stdout, stderr, err := runner.Run(context.Background(), parts[0], args...)
+	if err != nil {
+		if msg := strings.TrimSpace(string(stderr)); msg != "" {
+			return nil, fmt.Errorf("failed to read services from %s: %s", composeFiles[0], msg)
+		}
+		return nil, fmt.Errorf("failed to read services from %s: %w", composeFiles[0], err)
+	}
+
+	var services []string
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
 // IsContainerRunning checks if a specific container is running
 func IsContainerRunning(runtime ContainerRuntime, containerName string) (bool, error) {
 	running, err := ListRunningContainers(runtime)
@@ -310,16 +391,6 @@ func CheckRootless(runtime ContainerRuntime) (bool, error) {
 	}
 }
 
-// CheckDockerService checks if the docker.service systemd unit is active.
-// This is required for Docker-based deployments on Fedora CoreOS.
-func CheckDockerService() error {
-	cmd := exec.Command("systemctl", "is-active", "docker.service")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker.service is not active")
-	}
-	return nil
-}
-
 // CheckDockerComposeV2 checks if Docker Compose V2 plugin is available.
 // V2 is the preferred compose implementation (docker compose).
 func CheckDockerComposeV2() error {
@@ -0,0 +1,32 @@
+package system
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListComposeServices(t *testing.T) {
+	runner := NewFakeRunner().
+		On("docker compose -f /srv/containers/media/compose.yml -f /srv/containers/media/compose.override.yml config --services",
+			FakeResponse{Stdout: []byte("tautulli\nplex\njellyfin\n\n")}).
+		On("podman-compose -f /srv/containers/web/compose.yml config --services",
+			FakeResponse{Stderr: []byte("yaml: line 3: mapping values are not allowed in this context\n"), Err: &FakeExitError{Code: 1}})
+
+	got, err := listComposeServices(runner, "docker compose", []string{"/srv/containers/media/compose.yml", "/srv/containers/media/compose.override.yml"})
+	if err != nil {
+		t.Fatalf("listComposeServices() error = %v", err)
+	}
+	if want := []string{"jellyfin", "plex", "tautulli"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listComposeServices() = %v, want %v", got, want)
+	}
+
+	_, err = listComposeServices(runner, "podman-compose", []string{"/srv/containers/web/compose.yml"})
+	if err == nil || !strings.Contains(err.Error(), "mapping values are not allowed") {
+		t.Errorf("listComposeServices() error = %v, want the compose error", err)
+	}
+
+	if _, err := listComposeServices(runner, "", []string{"compose.yml"}); err == nil {
+		t.Error("listComposeServices() accepted an empty compose command")
+	}
+}
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// driDir holds DRM device nodes, overridable in tests
+var driDir = "/dev/dri"
+
+// drmSysfsDir exposes DRM device metadata, overridable in tests
+var drmSysfsDir = "/sys/class/drm"
+
+// gpuVendors maps PCI vendor IDs to names shown when picking a render device
+var gpuVendors = map[string]string{
+	"0x8086": "Intel",
+	"0x1002": "AMD",
+	"0x10de": "NVIDIA",
+}
+
+// DetectRenderDevices returns the GPU render nodes (/dev/dri/renderD*) present
+// on this host, sorted by name. An empty result means hardware transcoding is
+// unavailable.
+func DetectRenderDevices() ([]string, error) {
+	devices, err := filepath.Glob(filepath.Join(driDir, "renderD*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list render devices: %w", err)
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// RenderDeviceVendor returns the GPU vendor for a render node (e.g. "Intel"),
+// or an empty string if it cannot be determined
+func RenderDeviceVendor(device string) string {
+	data, err := os.ReadFile(filepath.Join(drmSysfsDir, filepath.Base(device), "device", "vendor"))
+	if err != nil {
+		return ""
+	}
+	return gpuVendors[strings.TrimSpace(string(data))]
+}
+
+// DeviceGroup returns the name of the group owning a device node (usually
+// "render" or "video" for GPU nodes)
+func DeviceGroup(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("failed to read owner of %s", path)
+	}
+
+	gid := strconv.FormatUint(uint64(stat.Gid), 10)
+	group, err := user.LookupGroupId(gid)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up group %s for %s: %w", gid, path, err)
+	}
+	return group.Name, nil
+}
@@ -0,0 +1,56 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectRenderDevices(t *testing.T) {
+	dev := t.TempDir()
+	sysfs := t.TempDir()
+	for _, name := range []string{"card0", "renderD129", "renderD128"} {
+		if err := os.WriteFile(filepath.Join(dev, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vendorDir := filepath.Join(sysfs, "renderD128", "device")
+	if err := os.MkdirAll(vendorDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vendorDir, "vendor"), []byte("0x8086\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	origDRI, origSysfs := driDir, drmSysfsDir
+	driDir, drmSysfsDir = dev, sysfs
+	t.Cleanup(func() { driDir, drmSysfsDir = origDRI, origSysfs })
+
+	devices, err := DetectRenderDevices()
+	if err != nil {
+		t.Fatalf("DetectRenderDevices() error = %v", err)
+	}
+	want := []string{filepath.Join(dev, "renderD128"), filepath.Join(dev, "renderD129")}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("DetectRenderDevices() = %v, want %v", devices, want)
+	}
+
+	if got := RenderDeviceVendor(devices[0]); got != "Intel" {
+		t.Errorf("RenderDeviceVendor(%s) = %q, want Intel", devices[0], got)
+	}
+	if got := RenderDeviceVendor(devices[1]); got != "" {
+		t.Errorf("RenderDeviceVendor(%s) = %q, want empty", devices[1], got)
+	}
+}
+
+func TestDetectRenderDevicesNone(t *testing.T) {
+	orig := driDir
+	driDir = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { driDir = orig })
+
+	devices, err := DetectRenderDevices()
+	if err != nil || len(devices) != 0 {
+		t.Errorf("DetectRenderDevices() = %v, %v; want none", devices, err)
+	}
+}
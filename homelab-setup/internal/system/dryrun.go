@@ -0,0 +1,64 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DryRunLogger receives a description of each privileged action skipped in
+// dry-run mode
+type DryRunLogger func(action string)
+
+var (
+	dryRunMu     sync.RWMutex
+	dryRun       bool
+	dryRunLogger DryRunLogger
+)
+
+// SetDryRun enables or disables dry-run mode. While enabled, privileged
+// operations (sudo commands, service control, directory creation, file
+// writes) are reported to log and skipped, and report success.
+func SetDryRun(enabled bool, log DryRunLogger) {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	dryRun = enabled
+	dryRunLogger = log
+}
+
+// DryRun reports whether dry-run mode is enabled
+func DryRun() bool {
+	dryRunMu.RLock()
+	defer dryRunMu.RUnlock()
+	return dryRun
+}
+
+// skipForDryRun reports whether the described action must be skipped because
+// dry-run mode is enabled, logging it if so
+func skipForDryRun(format string, args ...interface{}) bool {
+	dryRunMu.RLock()
+	enabled, log := dryRun, dryRunLogger
+	dryRunMu.RUnlock()
+	if !enabled {
+		return false
+	}
+	if log != nil {
+		log(fmt.Sprintf(format, args...))
+	}
+	return true
+}
+
+// RunPrivileged runs a command with "sudo -n" and returns its combined
+// output. In dry-run mode the command is only logged.
+func RunPrivileged(name string, args ...string) ([]byte, error) {
+	sudoArgs := append([]string{"-n", name}, args...)
+	if skipForDryRun("run %s", commandLine("sudo", sudoArgs)) {
+		return nil, nil
+	}
+	cmd := exec.Command("sudo", sudoArgs...)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	auditCommand(cmd.Args, start, err)
+	return output, sudoError(output, err)
+}
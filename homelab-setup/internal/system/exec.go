@@ -0,0 +1,262 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrCommandTimeout is returned when an external command exceeds its timeout
+var ErrCommandTimeout = errors.New("operation timed out")
+
+const (
+	// DefaultCommandTimeout bounds systemctl and compose control commands
+	DefaultCommandTimeout = 2 * time.Minute
+	// DefaultServiceStartTimeout bounds service starts and image pulls. It is
+	// longer than TimeoutStartSec=600 in the generated compose units.
+	DefaultServiceStartTimeout = 11 * time.Minute
+)
+
+var (
+	timeoutMu           sync.RWMutex
+	commandTimeout      = DefaultCommandTimeout
+	serviceStartTimeout = DefaultServiceStartTimeout
+)
+
+// SetCommandTimeouts sets the timeouts used for external commands.
+// Non-positive values keep the current setting.
+func SetCommandTimeouts(command, serviceStart time.Duration) {
+	timeoutMu.Lock()
+	defer timeoutMu.Unlock()
+	if command > 0 {
+		commandTimeout = command
+	}
+	if serviceStart > 0 {
+		serviceStartTimeout = serviceStart
+	}
+}
+
+// CommandTimeout returns the timeout for control commands
+func CommandTimeout() time.Duration {
+	timeoutMu.RLock()
+	defer timeoutMu.RUnlock()
+	return commandTimeout
+}
+
+// ServiceStartTimeout returns the timeout for service starts and image pulls
+func ServiceStartTimeout() time.Duration {
+	timeoutMu.RLock()
+	defer timeoutMu.RUnlock()
+	return serviceStartTimeout
+}
+
+// CommandTracer receives every external command run through the timeout
+// helpers, with how long it took and its error (nil on success)
+type CommandTracer func(commandLine string, elapsed time.Duration, err error)
+
+var (
+	tracerMu      sync.RWMutex
+	commandTracer CommandTracer
+)
+
+// SetCommandTracer installs fn to observe command invocations (nil disables tracing)
+func SetCommandTracer(fn CommandTracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	commandTracer = fn
+}
+
+// traceCommand reports a finished command to the installed tracer, if any
+func traceCommand(name string, args []string, start time.Time, err error) {
+	tracerMu.RLock()
+	fn := commandTracer
+	tracerMu.RUnlock()
+	if fn != nil {
+		fn(commandLine(name, args), time.Since(start), err)
+	}
+}
+
+// timedCommand builds a command that is killed, along with its whole process
+// group, when ctx is cancelled or timeout elapses. The returned cancel
+// function must be called once the command has finished.
+func timedCommand(ctx context.Context, timeout time.Duration, name string, args ...string) (*exec.Cmd, context.Context, context.CancelFunc) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	cmd := exec.CommandContext(timeoutCtx, name, args...)
+	// Run in a separate process group so children (compose, sudo) are killed too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	return cmd, timeoutCtx, cancel
+}
+
+// timeoutError converts a context failure into a descriptive error, or
+// returns nil when the command was not stopped by its context
+func timeoutError(ctx context.Context, timeout time.Duration, name string, args []string) error {
+	line := commandLine(name, args)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w after %s: %s", ErrCommandTimeout, timeout, line)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("command interrupted: %s: %w", line, ctx.Err())
+	}
+	return nil
+}
+
+// runCombinedWithTimeout runs a command and returns its combined output,
+// killing it after timeout
+func runCombinedWithTimeout(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	if name == "sudo" && skipForDryRun("run %s", commandLine(name, args)) {
+		return nil, nil
+	}
+	cmd, timeoutCtx, cancel := timedCommand(ctx, timeout, name, args...)
+	defer cancel()
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	traceCommand(name, args, start, err)
+	auditCommand(cmd.Args, start, err)
+	if err != nil {
+		if tErr := timeoutError(timeoutCtx, timeout, name, args); tErr != nil {
+			return output, newCommandError(name, args, tErr, output)
+		}
+		if name == "sudo" {
+			if sErr := sudoError(output, err); sErr != err {
+				return output, sErr
+			}
+		}
+		return output, newCommandError(name, args, err, output)
+	}
+	return output, nil
+}
+
+// stderrTailLines is how many lines of a failed command's error output are
+// kept in its CommandError
+const stderrTailLines = 20
+
+// CommandError is returned when an external command exits unsuccessfully or
+// is stopped by a timeout. It carries the last lines of the command's error
+// output for diagnosis.
+type CommandError struct {
+	Command string
+	Err     error
+	// StderrTail holds the last stderrTailLines lines of error output
+	StderrTail string
+}
+
+// Error implements error, appending the error output tail indented
+func (e *CommandError) Error() string {
+	if e.StderrTail == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + "\n  " + strings.ReplaceAll(e.StderrTail, "\n", "\n  ")
+}
+
+// Unwrap returns the underlying exec or timeout error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// newCommandError builds the CommandError for a failed command
+func newCommandError(name string, args []string, err error, stderr []byte) *CommandError {
+	return &CommandError{
+		Command:    commandLine(name, args),
+		Err:        err,
+		StderrTail: tailLines(stderr, stderrTailLines),
+	}
+}
+
+// tailLines returns the last n non-blank lines of output
+func tailLines(output []byte, n int) string {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimRight(line, " \t\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CommandResult is the captured output of an external command
+type CommandResult struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+var (
+	outputMu      sync.RWMutex
+	commandOutput io.Writer
+)
+
+// SetCommandOutput sets where long-running commands (compose pulls and the
+// like) stream their output while they run. With nil, the default, output is
+// only captured, and surfaced in the error if the command fails.
+func SetCommandOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	commandOutput = w
+}
+
+// lockedWriter serializes writes to w
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// RunCommandContext runs a long-running command, capturing its stdout and
+// stderr, and streams them to the writer set with SetCommandOutput. The
+// command is killed if ctx is cancelled or the service start timeout
+// elapses. A failure is returned as a *CommandError holding the tail of
+// stderr. In dry-run mode the command is only logged.
+func RunCommandContext(ctx context.Context, name string, args ...string) (CommandResult, error) {
+	if skipForDryRun("run %s", commandLine(name, args)) {
+		return CommandResult{}, nil
+	}
+	timeout := ServiceStartTimeout()
+	cmd, timeoutCtx, cancel := timedCommand(ctx, timeout, name, args...)
+	defer cancel()
+
+	outputMu.RLock()
+	live := commandOutput
+	outputMu.RUnlock()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if live != nil {
+		// exec copies stdout and stderr from separate goroutines
+		live = &lockedWriter{w: live}
+		cmd.Stdout = io.MultiWriter(&stdout, live)
+		cmd.Stderr = io.MultiWriter(&stderr, live)
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	traceCommand(name, args, start, err)
+	auditCommand(cmd.Args, start, err)
+	result := CommandResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	if err != nil {
+		if tErr := timeoutError(timeoutCtx, timeout, name, args); tErr != nil {
+			return result, newCommandError(name, args, tErr, result.Stderr)
+		}
+		return result, newCommandError(name, args, err, result.Stderr)
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunCombinedWithTimeout verifies hung commands are killed with a timeout error
+func TestRunCombinedWithTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available, skipping test")
+	}
+
+	start := time.Now()
+	_, err := runCombinedWithTimeout(context.Background(), 100*time.Millisecond, "sleep", "10")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("runCombinedWithTimeout() error = %v, want ErrCommandTimeout", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Errorf("runCombinedWithTimeout() error = %v, want *CommandError", err)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("runCombinedWithTimeout() took %v, want the command killed promptly", elapsed)
+	}
+}
+
+// TestRunCombinedWithTimeoutSuccess verifies commands finishing in time are unaffected
+func TestRunCombinedWithTimeoutSuccess(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo not available, skipping test")
+	}
+
+	output, err := runCombinedWithTimeout(context.Background(), 5*time.Second, "echo", "ok")
+	if err != nil {
+		t.Fatalf("runCombinedWithTimeout() error = %v", err)
+	}
+	if string(output) != "ok\n" {
+		t.Errorf("runCombinedWithTimeout() output = %q, want %q", output, "ok\n")
+	}
+}
+
+// TestRunCommandContextCapturesStderr verifies a failing command's stderr is
+// captured and its tail included in the error
+func TestRunCommandContextCapturesStderr(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available, skipping test")
+	}
+
+	script := "echo pulling; for i in $(seq 1 30); do echo line $i >&2; done; echo 'manifest unknown' >&2; exit 3"
+	result, err := RunCommandContext(context.Background(), "sh", "-c", script)
+	if string(result.Stdout) != "pulling\n" {
+		t.Errorf("RunCommandContext() stdout = %q, want %q", result.Stdout, "pulling\n")
+	}
+	if !strings.Contains(string(result.Stderr), "line 1\n") {
+		t.Errorf("RunCommandContext() stderr = %q, want all of it captured", result.Stderr)
+	}
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("RunCommandContext() error = %v, want *CommandError", err)
+	}
+	if exitCode(err) != 3 {
+		t.Errorf("exitCode() = %d, want 3", exitCode(err))
+	}
+	if !strings.Contains(err.Error(), "manifest unknown") {
+		t.Errorf("RunCommandContext() error = %q, want the stderr tail", err)
+	}
+	if strings.Contains(err.Error(), "line 10\n") || strings.Count(cmdErr.StderrTail, "\n") != stderrTailLines-1 {
+		t.Errorf("CommandError.StderrTail = %q, want the last %d lines", cmdErr.StderrTail, stderrTailLines)
+	}
+}
+
+// TestRunCommandContextInterruptedKeepsStderr verifies a command stopped by
+// its context still reports the tail of its stderr
+func TestRunCommandContextInterruptedKeepsStderr(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available, skipping test")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	_, err := RunCommandContext(ctx, "sh", "-c", "echo waiting for lock >&2; sleep 10")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunCommandContext() error = %v, want context.Canceled", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.StderrTail != "waiting for lock" {
+		t.Errorf("RunCommandContext() error = %q, want the stderr tail", err)
+	}
+}
+
+// TestRunCommandContextStreamsOutput verifies output is copied to the writer
+// set with SetCommandOutput as well as captured
+func TestRunCommandContextStreamsOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available, skipping test")
+	}
+	var live bytes.Buffer
+	SetCommandOutput(&live)
+	t.Cleanup(func() { SetCommandOutput(nil) })
+
+	result, err := RunCommandContext(context.Background(), "sh", "-c", "echo out; echo err >&2")
+	if err != nil {
+		t.Fatalf("RunCommandContext() error = %v", err)
+	}
+	if string(result.Stdout) != "out\n" || string(result.Stderr) != "err\n" {
+		t.Errorf("RunCommandContext() result = %q / %q", result.Stdout, result.Stderr)
+	}
+	if !strings.Contains(live.String(), "out\n") || !strings.Contains(live.String(), "err\n") {
+		t.Errorf("streamed output = %q, want stdout and stderr", live.String())
+	}
+}
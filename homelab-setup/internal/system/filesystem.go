@@ -5,52 +5,229 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 )
 
-// EnsureDirectory creates a directory with specified owner and permissions
-// If the directory already exists, it does nothing
+// EnsureDirectory creates a directory with specified owner and permissions.
+// An existing directory is left as it is; use EnsureDirectoryStrict to
+// correct its owner and mode.
 func EnsureDirectory(path string, owner string, perms os.FileMode) error {
+	_, err := ensureDirectory(path, owner, perms, false)
+	return err
+}
+
+// DirectoryDrift describes how an existing directory differed from the
+// requested owner and mode before it was corrected
+type DirectoryDrift struct {
+	Path     string
+	OldOwner string // "uid:gid", empty if ownership was not changed
+	NewOwner string
+	OldMode  os.FileMode // zero if the mode was not changed
+	NewMode  os.FileMode
+}
+
+// String describes the correction for logging
+func (d DirectoryDrift) String() string {
+	var parts []string
+	if d.NewOwner != "" {
+		parts = append(parts, fmt.Sprintf("owner %s -> %s", d.OldOwner, d.NewOwner))
+	}
+	if d.NewMode != 0 {
+		parts = append(parts, fmt.Sprintf("mode %o -> %o", d.OldMode, d.NewMode))
+	}
+	return fmt.Sprintf("corrected %s: %s", d.Path, strings.Join(parts, ", "))
+}
+
+// ownerNamePattern matches user and group names accepted by shadow-utils
+var ownerNamePattern = regexp.MustCompile(`^([a-z_][a-z0-9_-]*\$?|[0-9]+)$`)
+
+// ParseOwnerSpec validates an owner of the form "user" or "user:group" and
+// returns its parts. group is empty when only a user is given.
+func ParseOwnerSpec(owner string) (userName, groupName string, err error) {
+	userName, groupName, hasGroup := strings.Cut(owner, ":")
+	if !ownerNamePattern.MatchString(userName) {
+		return "", "", fmt.Errorf("invalid owner %q: expected \"user\" or \"user:group\"", owner)
+	}
+	if hasGroup && !ownerNamePattern.MatchString(groupName) {
+		return "", "", fmt.Errorf("invalid owner %q: expected \"user\" or \"user:group\"", owner)
+	}
+	return userName, groupName, nil
+}
+
+// ResolveOwner converts an owner spec to numeric IDs. It accepts "user",
+// "user:group", "1000" and "1000:1000"; numeric IDs are used as-is, so they
+// work before a passwd/group entry exists. When no group is given, the user's
+// primary group is used, or -1 if the user has no passwd entry (chown then
+// leaves the group unchanged).
+func ResolveOwner(spec string) (uid, gid int, err error) {
+	userName, groupName, err := ParseOwnerSpec(spec)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	gid = -1
+	if id, convErr := strconv.Atoi(userName); convErr == nil {
+		uid = id
+		// Use the primary group if the UID happens to have a passwd entry
+		if groupName == "" {
+			if u, lookupErr := user.LookupId(userName); lookupErr == nil {
+				if primary, convErr := strconv.Atoi(u.Gid); convErr == nil {
+					gid = primary
+				}
+			}
+		}
+	} else {
+		u, lookupErr := user.Lookup(userName)
+		if lookupErr != nil {
+			return 0, 0, fmt.Errorf("failed to lookup user %s: %w", userName, lookupErr)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("invalid UID for %s: %w", userName, err)
+		}
+		if groupName == "" {
+			if gid, err = strconv.Atoi(u.Gid); err != nil {
+				return 0, 0, fmt.Errorf("invalid GID for %s: %w", userName, err)
+			}
+		}
+	}
+
+	if groupName == "" {
+		return uid, gid, nil
+	}
+	if id, convErr := strconv.Atoi(groupName); convErr == nil {
+		return uid, id, nil
+	}
+	g, lookupErr := user.LookupGroup(groupName)
+	if lookupErr != nil {
+		return 0, 0, fmt.Errorf("failed to lookup group %s: %w", groupName, lookupErr)
+	}
+	if gid, err = strconv.Atoi(g.Gid); err != nil {
+		return 0, 0, fmt.Errorf("invalid GID for %s: %w", groupName, err)
+	}
+	return uid, gid, nil
+}
+
+// formatOwnerIDs renders numeric IDs for chown, omitting an unknown group
+func formatOwnerIDs(uid, gid int) string {
+	if gid < 0 {
+		return strconv.Itoa(uid)
+	}
+	return fmt.Sprintf("%d:%d", uid, gid)
+}
+
+// Ownership and mode changes go through these so tests can avoid sudo
+var (
+	chownPath = Chown
+	chmodPath = Chmod
+)
+
+// EnsureDirectoryStrict creates a directory with specified owner and
+// permissions. An existing directory whose owner or mode differs is corrected
+// and the returned drift describes what changed; it is nil when nothing had
+// to be corrected. An empty owner leaves ownership unmanaged.
+//
+// Only use it on directories the setup owns outright: a database directory,
+// for example, belongs to the user inside its container.
+func EnsureDirectoryStrict(path string, owner string, perms os.FileMode) (*DirectoryDrift, error) {
+	return ensureDirectory(path, owner, perms, true)
+}
+
+// ensureDirectory creates a directory, or with correctDrift fixes the owner
+// and mode of an existing one
+func ensureDirectory(path string, owner string, perms os.FileMode, correctDrift bool) (*DirectoryDrift, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		var err error
+		if uid, gid, err = ResolveOwner(owner); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if directory exists
-	if info, err := os.Stat(path); err == nil {
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to check directory %s: %w", path, err)
+	}
+
+	if err == nil {
 		if !info.IsDir() {
-			return fmt.Errorf("%s exists but is not a directory", path)
+			return nil, fmt.Errorf("%s exists but is not a directory", path)
 		}
-		// Directory exists, nothing to do
-		return nil
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("failed to check directory %s: %w", path, err)
+		// A mounted filesystem's root (e.g. an NFS share) belongs to that
+		// filesystem, so its owner and mode are left alone
+		if !correctDrift {
+			return nil, nil
+		}
+		if mounted, err := IsMount(path); err == nil && mounted {
+			return nil, nil
+		}
+		return correctDirectoryDrift(path, info, uid, gid, perms)
 	}
 
 	// Create directory with sudo
-	cmd := exec.Command("sudo", "-n", "mkdir", "-p", path)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w\nOutput: %s", path, err, string(output))
+	if output, err := RunPrivileged("mkdir", "-p", path); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w\nOutput: %s", path, err, string(output))
 	}
 
-	// Set ownership if specified
-	if owner != "" {
-		if err := Chown(path, owner); err != nil {
-			return fmt.Errorf("failed to set ownership on %s: %w", path, err)
+	// Set ownership if specified (numeric, so it works without a passwd entry)
+	if uid >= 0 {
+		if err := chownPath(path, formatOwnerIDs(uid, gid)); err != nil {
+			return nil, fmt.Errorf("failed to set ownership on %s: %w", path, err)
 		}
 	}
 
 	// Set permissions
-	if err := Chmod(path, perms); err != nil {
-		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	if err := chmodPath(path, perms); err != nil {
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", path, err)
 	}
 
-	return nil
+	return nil, nil
+}
+
+// correctDirectoryDrift fixes the owner and mode of an existing directory.
+// A negative uid leaves ownership unmanaged; a negative gid leaves the group.
+func correctDirectoryDrift(path string, info os.FileInfo, uid, gid int, perms os.FileMode) (*DirectoryDrift, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("failed to get stat info for %s: not a Unix filesystem", path)
+	}
+
+	drift := DirectoryDrift{Path: path}
+	current := fmt.Sprintf("%d:%d", stat.Uid, stat.Gid)
+
+	if uid >= 0 && (int(stat.Uid) != uid || (gid >= 0 && int(stat.Gid) != gid)) {
+		owner := formatOwnerIDs(uid, gid)
+		if err := chownPath(path, owner); err != nil {
+			return nil, fmt.Errorf("failed to correct ownership on %s: %w", path, err)
+		}
+		drift.OldOwner, drift.NewOwner = current, owner
+	}
+
+	if oldMode := info.Mode().Perm(); oldMode != perms.Perm() {
+		if err := chmodPath(path, perms); err != nil {
+			return nil, fmt.Errorf("failed to correct permissions on %s: %w", path, err)
+		}
+		drift.OldMode, drift.NewMode = oldMode, perms.Perm()
+	}
+
+	if drift.NewOwner == "" && drift.NewMode == 0 {
+		return nil, nil
+	}
+	return &drift, nil
 }
 
 // Chown changes the owner of a file or directory
 // owner should be in format "user:group" or just "user"
 func Chown(path string, owner string) error {
-	cmd := exec.Command("sudo", "-n", "chown", owner, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chown", owner, path); err != nil {
 		return fmt.Errorf("failed to chown %s to %s: %w\nOutput: %s", path, owner, err, string(output))
 	}
 	return nil
@@ -58,8 +235,7 @@ func Chown(path string, owner string) error {
 
 // ChownRecursive changes the owner of a file or directory recursively
 func ChownRecursive(path string, owner string) error {
-	cmd := exec.Command("sudo", "-n", "chown", "-R", owner, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chown", "-R", owner, path); err != nil {
 		return fmt.Errorf("failed to chown -R %s to %s: %w\nOutput: %s", path, owner, err, string(output))
 	}
 	return nil
@@ -68,8 +244,7 @@ func ChownRecursive(path string, owner string) error {
 // Chmod changes the permissions of a file or directory
 func Chmod(path string, perms os.FileMode) error {
 	permStr := fmt.Sprintf("%o", perms)
-	cmd := exec.Command("sudo", "-n", "chmod", permStr, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chmod", permStr, path); err != nil {
 		return fmt.Errorf("failed to chmod %s to %s: %w\nOutput: %s", path, permStr, err, string(output))
 	}
 	return nil
@@ -78,8 +253,7 @@ func Chmod(path string, perms os.FileMode) error {
 // ChmodRecursive changes permissions recursively
 func ChmodRecursive(path string, perms os.FileMode) error {
 	permStr := fmt.Sprintf("%o", perms)
-	cmd := exec.Command("sudo", "-n", "chmod", "-R", permStr, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chmod", "-R", permStr, path); err != nil {
 		return fmt.Errorf("failed to chmod -R %s to %s: %w\nOutput: %s", path, permStr, err, string(output))
 	}
 	return nil
@@ -96,7 +270,9 @@ func FileExists(path string) (bool, error) {
 	}
 	if errors.Is(err, os.ErrPermission) {
 		cmd := exec.Command("sudo", "-n", "test", "-e", path)
+		start := time.Now()
 		runErr := cmd.Run()
+		auditCommand(cmd.Args, start, runErr)
 		if runErr == nil {
 			return true, nil
 		}
@@ -152,7 +328,9 @@ func GetPermissions(path string) (os.FileMode, error) {
 	}
 	if errors.Is(err, os.ErrPermission) {
 		cmd := exec.Command("sudo", "-n", "stat", "-c", "%a", path)
+		start := time.Now()
 		output, runErr := cmd.CombinedOutput()
+		auditCommand(cmd.Args, start, runErr)
 		if runErr != nil {
 			return 0, fmt.Errorf("failed to stat %s: %w\nOutput: %s", path, runErr, string(output))
 		}
@@ -204,8 +382,12 @@ func RemoveDirectory(path string) error {
 		}
 	}
 
-	cmd := exec.Command("sudo", "-n", "rm", "-rf", path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	// Reject unsafe characters and dangerous roots such as /srv or /mnt
+	if err := common.ValidateBasePath(path); err != nil && !errors.Is(err, common.ErrPathNotAllowlisted) {
+		return fmt.Errorf("refusing to remove %s: %w", path, err)
+	}
+
+	if output, err := RunPrivileged("rm", "-rf", path); err != nil {
 		return fmt.Errorf("failed to remove directory %s: %w\nOutput: %s", path, err, string(output))
 	}
 	return nil
@@ -213,8 +395,7 @@ func RemoveDirectory(path string) error {
 
 // RemoveFile removes a file
 func RemoveFile(path string) error {
-	cmd := exec.Command("sudo", "-n", "rm", "-f", path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("rm", "-f", path); err != nil {
 		return fmt.Errorf("failed to remove file %s: %w\nOutput: %s", path, err, string(output))
 	}
 	return nil
@@ -222,13 +403,20 @@ func RemoveFile(path string) error {
 
 // CopyFile copies a file from src to dst
 func CopyFile(src, dst string) error {
-	cmd := exec.Command("sudo", "-n", "cp", src, dst)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("cp", src, dst); err != nil {
 		return fmt.Errorf("failed to copy %s to %s: %w\nOutput: %s", src, dst, err, string(output))
 	}
 	return nil
 }
 
+// MovePath renames src to dst
+func MovePath(src, dst string) error {
+	if output, err := RunPrivileged("mv", "--", src, dst); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w\nOutput: %s", src, dst, err, string(output))
+	}
+	return nil
+}
+
 // BackupFile creates a backup of a file with timestamp suffix
 func BackupFile(path string) (string, error) {
 	exists, err := FileExists(path)
@@ -275,6 +463,20 @@ func GetDiskUsage(path string) (total, used, free uint64, err error) {
 	return total, used, free, nil
 }
 
+// FormatBytes renders a byte count with a binary unit suffix
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // GetDiskUsageHuman returns human-readable disk usage for a path
 func GetDiskUsageHuman(path string) (string, error) {
 	cmd := exec.Command("df", "-h", path)
@@ -320,8 +522,7 @@ func ListDirectory(path string) ([]string, error) {
 
 // CreateSymlink creates a symbolic link
 func CreateSymlink(target, linkPath string) error {
-	cmd := exec.Command("sudo", "-n", "ln", "-sf", target, linkPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("ln", "-sf", target, linkPath); err != nil {
 		return fmt.Errorf("failed to create symlink %s -> %s: %w\nOutput: %s", linkPath, target, err, string(output))
 	}
 	return nil
@@ -329,8 +530,11 @@ func CreateSymlink(target, linkPath string) error {
 
 // WriteFile writes content to a file. It first attempts a direct write using the
 // current user's permissions and only falls back to sudo if that fails with
-// os.ErrPermission.
+// os.ErrPermission. In dry-run mode nothing is written.
 func WriteFile(path string, content []byte, perms os.FileMode) error {
+	if skipForDryRun("write %s (%d bytes, mode %o)", path, len(content), perms) {
+		return nil
+	}
 	if err := writeFileDirect(path, content, perms); err == nil {
 		return nil
 	} else if !errors.Is(err, os.ErrPermission) {
@@ -353,8 +557,7 @@ func WriteFile(path string, content []byte, perms os.FileMode) error {
 	tmpFile.Close()
 
 	// Move temp file to target with sudo
-	cmd := exec.Command("sudo", "-n", "mv", tmpPath, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("mv", tmpPath, path); err != nil {
 		return fmt.Errorf("failed to move file to %s: %w\nOutput: %s", path, err, string(output))
 	}
 
@@ -417,7 +620,9 @@ func ReadFile(path string) ([]byte, error) {
 	}
 
 	cmd := exec.Command("sudo", "-n", "cat", path)
+	start := time.Now()
 	output, runErr := cmd.Output()
+	auditCommand(cmd.Args, start, runErr)
 	if runErr != nil {
 		return nil, fmt.Errorf("failed to read %s with sudo: %w", path, runErr)
 	}
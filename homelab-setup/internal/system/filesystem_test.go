@@ -0,0 +1,234 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubOwnershipChanges replaces chown/chmod with local calls and records them
+func stubOwnershipChanges(t *testing.T) *[]string {
+	t.Helper()
+	var calls []string
+
+	origChown, origChmod := chownPath, chmodPath
+	chownPath = func(path, owner string) error {
+		calls = append(calls, "chown "+owner)
+		return nil
+	}
+	chmodPath = func(path string, perms os.FileMode) error {
+		calls = append(calls, fmt.Sprintf("chmod %o", perms))
+		return os.Chmod(path, perms)
+	}
+	t.Cleanup(func() {
+		chownPath, chmodPath = origChown, origChmod
+	})
+
+	return &calls
+}
+
+func TestEnsureDirectoryStrictCorrectsModeDrift(t *testing.T) {
+	calls := stubOwnershipChanges(t)
+
+	dir := filepath.Join(t.TempDir(), "appdata")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := EnsureDirectoryStrict(dir, "", 0755)
+	if err != nil {
+		t.Fatalf("EnsureDirectoryStrict() error = %v", err)
+	}
+	if drift == nil {
+		t.Fatal("expected drift to be reported")
+	}
+	if drift.OldMode != 0700 || drift.NewMode != 0755 {
+		t.Errorf("unexpected drift: %+v", drift)
+	}
+	if !strings.Contains(drift.String(), "mode 700 -> 755") {
+		t.Errorf("drift description = %q", drift.String())
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %o, want 755", info.Mode().Perm())
+	}
+	if len(*calls) != 1 {
+		t.Errorf("expected a single chmod, got %v", *calls)
+	}
+}
+
+func TestEnsureDirectoryStrictCorrectsOwnerDrift(t *testing.T) {
+	calls := stubOwnershipChanges(t)
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Request an owner that cannot match the directory's current UID
+	owner := fmt.Sprintf("%d", os.Getuid()+12345)
+	drift, err := EnsureDirectoryStrict(dir, owner, 0755)
+	if err != nil {
+		t.Fatalf("EnsureDirectoryStrict() error = %v", err)
+	}
+	if drift == nil || drift.NewOwner != owner {
+		t.Fatalf("expected owner drift to %s, got %+v", owner, drift)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "chown "+owner {
+		t.Errorf("expected a single chown, got %v", *calls)
+	}
+}
+
+func TestEnsureDirectoryStrictNoopWhenMatching(t *testing.T) {
+	calls := stubOwnershipChanges(t)
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	drift, err := EnsureDirectoryStrict(dir, owner, 0755)
+	if err != nil {
+		t.Fatalf("EnsureDirectoryStrict() error = %v", err)
+	}
+	if drift != nil {
+		t.Errorf("expected no drift, got %+v", drift)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no changes, got %v", *calls)
+	}
+}
+
+func TestEnsureDirectoryLeavesExistingDirectory(t *testing.T) {
+	calls := stubOwnershipChanges(t)
+
+	dir := filepath.Join(t.TempDir(), "immich-db")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := fmt.Sprintf("%d", os.Getuid()+12345)
+	if err := EnsureDirectory(dir, owner, 0755); err != nil {
+		t.Fatalf("EnsureDirectory() error = %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no changes to an existing directory, got %v", *calls)
+	}
+	if err := EnsureDirectory(dir, "bad user", 0755); err == nil {
+		t.Error("expected error for an invalid owner")
+	}
+}
+
+func TestEnsureDirectoryStrictRejectsBadOwner(t *testing.T) {
+	stubOwnershipChanges(t)
+
+	for _, owner := range []string{":", "user:", ":group", "bad user", "a:b:c", "UPPER"} {
+		if _, err := EnsureDirectoryStrict(t.TempDir(), owner, 0755); err == nil {
+			t.Errorf("expected error for owner %q", owner)
+		}
+	}
+}
+
+func TestParseOwnerSpec(t *testing.T) {
+	tests := []struct {
+		owner     string
+		wantUser  string
+		wantGroup string
+		wantErr   bool
+	}{
+		{"core", "core", "", false},
+		{"core:core", "core", "core", false},
+		{"1000:1000", "1000", "1000", false},
+		{"svc-media_1", "svc-media_1", "", false},
+		{"", "", "", true},
+		{"core:", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.owner, func(t *testing.T) {
+			u, g, err := ParseOwnerSpec(tt.owner)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOwnerSpec(%q) error = %v, wantErr %v", tt.owner, err, tt.wantErr)
+			}
+			if u != tt.wantUser || g != tt.wantGroup {
+				t.Errorf("ParseOwnerSpec(%q) = (%q, %q), want (%q, %q)", tt.owner, u, g, tt.wantUser, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestResolveOwner(t *testing.T) {
+	// root exists in every test environment with UID/GID 0
+	tests := []struct {
+		spec    string
+		wantUID int
+		wantGID int
+		wantErr bool
+	}{
+		{"root", 0, 0, false},
+		{"root:root", 0, 0, false},
+		{"0", 0, 0, false},
+		{"1000:1000", 1000, 1000, false},
+		{"54321", 54321, -1, false}, // numeric UID without a passwd entry
+		{"54321:54322", 54321, 54322, false},
+		{"root:54322", 0, 54322, false},
+		{"no-such-user-xyz", 0, 0, true},
+		{"root:no-such-group-xyz", 0, 0, true},
+		{"bad spec", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			uid, gid, err := ResolveOwner(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveOwner(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if uid != tt.wantUID || gid != tt.wantGID {
+				t.Errorf("ResolveOwner(%q) = (%d, %d), want (%d, %d)", tt.spec, uid, gid, tt.wantUID, tt.wantGID)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestEnsureDirectoryDryRun(t *testing.T) {
+	var actions []string
+	SetDryRun(true, func(action string) { actions = append(actions, action) })
+	t.Cleanup(func() { SetDryRun(false, nil) })
+
+	path := filepath.Join(t.TempDir(), "appdata", "plex")
+	if err := EnsureDirectory(path, "", 0755); err != nil {
+		t.Fatalf("EnsureDirectory() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("EnsureDirectory() in dry-run created %s", path)
+	}
+	if len(actions) == 0 || !strings.Contains(actions[0], "mkdir -p "+path) {
+		t.Errorf("EnsureDirectory() in dry-run logged %q, want the mkdir", actions)
+	}
+}
@@ -0,0 +1,87 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// IsFirewalldRunning reports whether firewalld is installed and running
+func IsFirewalldRunning() bool {
+	if !CommandExists("firewall-cmd") {
+		return false
+	}
+	cmd := exec.Command("sudo", "-n", "firewall-cmd", "--state")
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	auditCommand(cmd.Args, start, err)
+	return err == nil && strings.TrimSpace(string(output)) == "running"
+}
+
+// zoneArgs returns the firewall-cmd arguments selecting zone, or none for the
+// default zone
+func zoneArgs(zone string) []string {
+	if zone == "" {
+		return nil
+	}
+	return []string{"--zone=" + zone}
+}
+
+// FirewallZoneOfInterface returns the firewalld zone an interface is bound
+// to, or "" when it is not bound and falls back to the default zone
+func FirewallZoneOfInterface(name string) (string, error) {
+	cmd := exec.Command("sudo", "-n", "firewall-cmd", "--get-zone-of-interface="+name)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	auditCommand(cmd.Args, start, err)
+	answer := strings.TrimSpace(string(output))
+	if err != nil {
+		// firewall-cmd exits non-zero with "no zone" for unbound interfaces
+		if answer == "no zone" {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to query firewall zone of %s: %w\nOutput: %s", name, err, answer)
+	}
+	return answer, nil
+}
+
+// IsFirewallPortOpen reports whether port/protocol is open in a firewalld
+// zone, or in the default zone when zone is empty
+func IsFirewallPortOpen(port, protocol, zone string) (bool, error) {
+	spec := fmt.Sprintf("%s/%s", port, protocol)
+	args := append([]string{"-n", "firewall-cmd"}, zoneArgs(zone)...)
+	cmd := exec.Command("sudo", append(args, "--query-port="+spec)...)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	auditCommand(cmd.Args, start, err)
+	answer := strings.TrimSpace(string(output))
+	if answer == "yes" {
+		return true, nil
+	}
+	if answer == "no" {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query firewall port %s: %w\nOutput: %s", spec, err, answer)
+	}
+	return false, nil
+}
+
+// OpenFirewallPort permanently opens port/protocol in a firewalld zone, or
+// the default zone when zone is empty, and applies it to the running
+// configuration (idempotent)
+func OpenFirewallPort(port, protocol, zone string) error {
+	spec := fmt.Sprintf("%s/%s", port, protocol)
+	args := append(zoneArgs(zone), "--add-port="+spec)
+
+	if output, err := RunPrivileged("firewall-cmd", append([]string{"--permanent"}, args...)...); err != nil {
+		return fmt.Errorf("failed to open firewall port %s: %w\nOutput: %s", spec, err, string(output))
+	}
+
+	if output, err := RunPrivileged("firewall-cmd", args...); err != nil {
+		return fmt.Errorf("failed to apply firewall port %s: %w\nOutput: %s", spec, err, string(output))
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package system
+
+import (
+	"io/fs"
+	"os"
+)
+
+// FileSystem is the set of filesystem operations used by setup steps. Steps
+// hold one so their verification and display logic can run against
+// MemFileSystem in tests instead of the real disk.
+type FileSystem interface {
+	DirectoryExists(path string) (bool, error)
+	ReadDir(path string) ([]fs.DirEntry, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	Remove(path string) error
+}
+
+// osFileSystem implements FileSystem on the real disk, using the sudo
+// fallbacks of the package-level helpers where they exist
+type osFileSystem struct{}
+
+// NewFileSystem returns a FileSystem backed by the real disk
+func NewFileSystem() FileSystem {
+	return osFileSystem{}
+}
+
+func (osFileSystem) DirectoryExists(path string) (bool, error)  { return DirectoryExists(path) }
+func (osFileSystem) ReadDir(path string) ([]fs.DirEntry, error) { return os.ReadDir(path) }
+func (osFileSystem) ReadFile(path string) ([]byte, error)       { return os.ReadFile(path) }
+func (osFileSystem) Remove(path string) error                   { return os.Remove(path) }
+
+func (osFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return WriteFile(path, data, perm)
+}
@@ -0,0 +1,100 @@
+package system
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing/fstest"
+)
+
+// MemFileSystem is an in-memory FileSystem for tests. Parent directories of
+// added files exist implicitly.
+type MemFileSystem struct {
+	// WriteErr, when set, is returned by every WriteFile call
+	WriteErr error
+
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+// NewMemFileSystem creates an empty in-memory filesystem
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{files: make(fstest.MapFS)}
+}
+
+// memPath converts an absolute path to the unrooted form fstest.MapFS uses
+func memPath(p string) string {
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// AddDir creates a directory (and its parents)
+func (m *MemFileSystem) AddDir(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memPath(dir)] = &fstest.MapFile{Mode: fs.ModeDir | 0755}
+}
+
+// AddFile creates a file with the given contents (and its parent directories)
+func (m *MemFileSystem) AddFile(file string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memPath(file)] = &fstest.MapFile{Data: data, Mode: 0644}
+}
+
+// DirectoryExists reports whether dir exists and is a directory
+func (m *MemFileSystem) DirectoryExists(dir string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := fs.Stat(m.files, memPath(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// ReadDir lists dir sorted by name
+func (m *MemFileSystem) ReadDir(dir string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fs.ReadDir(m.files, memPath(dir))
+}
+
+// ReadFile returns the contents of file
+func (m *MemFileSystem) ReadFile(file string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fs.ReadFile(m.files, memPath(file))
+}
+
+// WriteFile creates or replaces file
+func (m *MemFileSystem) WriteFile(file string, data []byte, perm os.FileMode) error {
+	if m.WriteErr != nil {
+		return m.WriteErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memPath(file)] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+// Remove deletes file
+func (m *MemFileSystem) Remove(file string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memPath(file)
+	if _, ok := m.files[key]; !ok {
+		return &fs.PathError{Op: "remove", Path: file, Err: fs.ErrNotExist}
+	}
+	delete(m.files, key)
+	return nil
+}
@@ -0,0 +1,123 @@
+package system
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// HealthState classifies the outcome of an HTTP health probe
+type HealthState int
+
+const (
+	// HealthOK means the endpoint answered with a success status
+	HealthOK HealthState = iota
+	// HealthBadStatus means the endpoint answered, but with a failure status
+	HealthBadStatus
+	// HealthRefused means nothing is listening on the port yet
+	HealthRefused
+	// HealthUnreachable means the request timed out or failed for another reason
+	HealthUnreachable
+)
+
+// String returns a short description of the state
+func (s HealthState) String() string {
+	switch s {
+	case HealthOK:
+		return "healthy"
+	case HealthBadStatus:
+		return "up but unhealthy"
+	case HealthRefused:
+		return "not up yet"
+	default:
+		return "unreachable"
+	}
+}
+
+// HealthResult is the outcome of one HTTP health probe
+type HealthResult struct {
+	URL        string
+	State      HealthState
+	StatusCode int // zero unless the endpoint answered
+	Latency    time.Duration
+	FinalURL   string // URL that answered after redirects, if it differs from URL
+	Err        error  // nil when State is HealthOK
+}
+
+// IsCertificateError reports whether the probe failed because the server's
+// TLS certificate could not be verified
+func (r HealthResult) IsCertificateError() bool {
+	var certErr *tls.CertificateVerificationError
+	return errors.As(r.Err, &certErr)
+}
+
+// ProbeHTTP GETs url and classifies the response. A nil accept treats any 2xx
+// status as healthy. Connection refused is reported as HealthRefused so a
+// service that has not bound its port yet can be told apart from one that is
+// answering with errors.
+func ProbeHTTP(ctx context.Context, url string, timeout time.Duration, accept func(statusCode int) bool) HealthResult {
+	return probeHTTP(ctx, http.DefaultClient, url, timeout, accept)
+}
+
+// ProbePublicURL GETs url end to end, as a browser would through a reverse
+// proxy: redirects are followed and any 2xx at the final location is
+// healthy. verifyTLS=false accepts self-signed certificates.
+func ProbePublicURL(ctx context.Context, url string, timeout time.Duration, verifyTLS bool) HealthResult {
+	client := http.DefaultClient
+	if !verifyTLS {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client = &http.Client{Transport: transport}
+		defer transport.CloseIdleConnections()
+	}
+	return probeHTTP(ctx, client, url, timeout, nil)
+}
+
+// probeHTTP implements ProbeHTTP and ProbePublicURL with the given client
+func probeHTTP(ctx context.Context, client *http.Client, url string, timeout time.Duration, accept func(statusCode int) bool) HealthResult {
+	if accept == nil {
+		accept = func(code int) bool { return code >= 200 && code < 300 }
+	}
+	result := HealthResult{URL: url}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.State = HealthUnreachable
+		result.Err = fmt.Errorf("invalid health URL %s: %w", url, err)
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			result.State = HealthRefused
+		} else {
+			result.State = HealthUnreachable
+		}
+		result.Err = err
+		return result
+	}
+	resp.Body.Close()
+
+	if final := resp.Request.URL.String(); final != url {
+		result.FinalURL = final
+	}
+	result.StatusCode = resp.StatusCode
+	if !accept(resp.StatusCode) {
+		result.State = HealthBadStatus
+		result.Err = fmt.Errorf("unexpected status %s", resp.Status)
+		return result
+	}
+
+	result.State = HealthOK
+	return result
+}
@@ -0,0 +1,91 @@
+package system
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		accept func(int) bool
+		state  HealthState
+		code   int
+	}{
+		{"2xx is healthy", "/health", nil, HealthOK, 200},
+		{"500 is bad status", "/broken", nil, HealthBadStatus, 500},
+		{"custom accept", "/unauthorized", func(code int) bool { return code == 401 }, HealthOK, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProbeHTTP(context.Background(), srv.URL+tt.path, 2*time.Second, tt.accept)
+			if got.State != tt.state || got.StatusCode != tt.code {
+				t.Errorf("ProbeHTTP() = (%v, %d), want (%v, %d)", got.State, got.StatusCode, tt.state, tt.code)
+			}
+			if (got.Err == nil) != (tt.state == HealthOK) {
+				t.Errorf("ProbeHTTP() err = %v for state %v", got.Err, got.State)
+			}
+		})
+	}
+}
+
+func TestProbeHTTPRefused(t *testing.T) {
+	// Grab a free port, then close it so nothing is listening
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	got := ProbeHTTP(context.Background(), "http://"+addr+"/", 2*time.Second, nil)
+	if got.State != HealthRefused {
+		t.Errorf("ProbeHTTP() state = %v, want %v (err %v)", got.State, HealthRefused, got.Err)
+	}
+	if got.StatusCode != 0 {
+		t.Errorf("ProbeHTTP() status = %d, want 0", got.StatusCode)
+	}
+}
+
+func TestProbePublicURL(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/web/index.html", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// httptest's certificate is self-signed, so verification must fail
+	got := ProbePublicURL(context.Background(), srv.URL+"/", 2*time.Second, true)
+	if got.State != HealthUnreachable || !got.IsCertificateError() {
+		t.Errorf("ProbePublicURL() with verification = (%v, %v), want certificate error", got.State, got.Err)
+	}
+
+	got = ProbePublicURL(context.Background(), srv.URL+"/", 2*time.Second, false)
+	if got.State != HealthOK || got.StatusCode != http.StatusOK {
+		t.Fatalf("ProbePublicURL() without verification = (%v, %d, %v), want healthy 200", got.State, got.StatusCode, got.Err)
+	}
+	if got.FinalURL != srv.URL+"/web/index.html" {
+		t.Errorf("ProbePublicURL() FinalURL = %q, want redirect target", got.FinalURL)
+	}
+}
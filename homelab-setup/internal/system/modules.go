@@ -0,0 +1,63 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sysModulePath lists loaded and built-in kernel modules, overridable in tests
+var sysModulePath = "/sys/module"
+
+// ModuleState describes whether a kernel module can be used
+type ModuleState int
+
+const (
+	// ModuleMissing means the module is neither loaded nor installed for the
+	// running kernel
+	ModuleMissing ModuleState = iota
+	// ModuleAvailable means the module is installed and is loaded on demand
+	ModuleAvailable
+	// ModuleLoaded means the module is loaded or built into the kernel
+	ModuleLoaded
+)
+
+// String implements fmt.Stringer
+func (s ModuleState) String() string {
+	switch s {
+	case ModuleLoaded:
+		return "loaded"
+	case ModuleAvailable:
+		return "available"
+	default:
+		return "missing"
+	}
+}
+
+// KernelModuleState reports whether the named kernel module is loaded (or
+// built in) or can be loaded for the running kernel
+func KernelModuleState(name string) (ModuleState, error) {
+	return kernelModuleState(defaultRunner, name)
+}
+
+func kernelModuleState(runner CommandRunner, name string) (ModuleState, error) {
+	// /sys/module uses underscores, modprobe accepts either form
+	sysName := strings.ReplaceAll(name, "-", "_")
+	if _, err := os.Stat(filepath.Join(sysModulePath, sysName)); err == nil {
+		return ModuleLoaded, nil
+	}
+
+	_, stderr, err := runner.Run(context.Background(), "modinfo", "-F", "filename", name)
+	if err == nil {
+		return ModuleAvailable, nil
+	}
+	if exitCode(err) > 0 {
+		return ModuleMissing, nil
+	}
+	if msg := strings.TrimSpace(string(stderr)); msg != "" {
+		return ModuleMissing, fmt.Errorf("failed to look up kernel module %s: %s", name, msg)
+	}
+	return ModuleMissing, fmt.Errorf("failed to look up kernel module %s: %w", name, err)
+}
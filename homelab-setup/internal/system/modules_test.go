@@ -0,0 +1,48 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKernelModuleState(t *testing.T) {
+	orig := sysModulePath
+	t.Cleanup(func() { sysModulePath = orig })
+	sysModulePath = t.TempDir()
+	if err := os.Mkdir(filepath.Join(sysModulePath, "nfs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewFakeRunner().
+		On("modinfo -F filename wireguard", FakeResponse{Stdout: []byte("/lib/modules/6.11/kernel/drivers/net/wireguard/wireguard.ko.xz\n")}).
+		On("modinfo -F filename nfsd", FakeResponse{Stderr: []byte("modinfo: ERROR: Module nfsd not found.\n"), Err: &FakeExitError{Code: 1}}).
+		On("modinfo -F filename brokenmod", FakeResponse{Err: errors.New("executable file not found")})
+
+	tests := []struct {
+		name    string
+		want    ModuleState
+		wantErr bool
+	}{
+		{"nfs", ModuleLoaded, false},
+		{"wireguard", ModuleAvailable, false},
+		{"nfsd", ModuleMissing, false},
+		{"brokenmod", ModuleMissing, true},
+	}
+	for _, tt := range tests {
+		got, err := kernelModuleState(runner, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("kernelModuleState(%s) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("kernelModuleState(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, call := range runner.Calls() {
+		if call == "modinfo -F filename nfs" {
+			t.Error("kernelModuleState() ran modinfo for a loaded module")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procMountsPath is the kernel's mount table, overridable in tests
+var procMountsPath = "/proc/mounts"
+
+// mountEntry is one line of /proc/mounts
+type mountEntry struct {
+	Source     string
+	MountPoint string
+	FSType     string
+}
+
+// unescapeMountField decodes the octal escapes (\040 for a space, etc.) the
+// kernel uses for special characters in /proc/mounts fields
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if code, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// parseProcMounts parses the contents of /proc/mounts, skipping malformed
+// lines
+func parseProcMounts(content string) []mountEntry {
+	var entries []mountEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		entries = append(entries, mountEntry{
+			Source:     unescapeMountField(fields[0]),
+			MountPoint: unescapeMountField(fields[1]),
+			FSType:     fields[2],
+		})
+	}
+	return entries
+}
+
+// existingAncestor resolves symlinks in path, or in its nearest existing
+// parent when path does not exist yet
+func existingAncestor(path string) (string, error) {
+	path = filepath.Clean(path)
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
+
+// FilesystemType returns the type of the filesystem holding path as listed
+// in /proc/mounts (e.g. "xfs", "nfs4", "tmpfs", "fuse.sshfs"). A path that
+// does not exist yet reports the filesystem it would be created on.
+func FilesystemType(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path must be absolute: %s", path)
+	}
+	resolved, err := existingAncestor(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	content, err := os.ReadFile(procMountsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	// The longest matching mount point holds the path; for the same mount
+	// point the later entry is mounted on top
+	best := -1
+	fsType := ""
+	for _, entry := range parseProcMounts(string(content)) {
+		mountPoint := entry.MountPoint
+		if resolved != mountPoint && mountPoint != "/" && !strings.HasPrefix(resolved, mountPoint+"/") {
+			continue
+		}
+		if len(mountPoint) >= best {
+			best = len(mountPoint)
+			fsType = entry.FSType
+		}
+	}
+	if best < 0 {
+		return "", fmt.Errorf("no mount found for %s", path)
+	}
+	return fsType, nil
+}
+
+// networkFilesystems are filesystem types served over the network
+var networkFilesystems = map[string]bool{
+	"nfs":            true,
+	"nfs4":           true,
+	"cifs":           true,
+	"smb3":           true,
+	"smbfs":          true,
+	"ceph":           true,
+	"9p":             true,
+	"glusterfs":      true,
+	"fuse.sshfs":     true,
+	"fuse.glusterfs": true,
+	"fuse.rclone":    true,
+	"fuse.s3fs":      true,
+	"fuse.cephfs":    true,
+	"fuse.davfs":     true,
+}
+
+// IsNetworkFilesystem reports whether fsType is a network filesystem
+func IsNetworkFilesystem(fsType string) bool {
+	return networkFilesystems[fsType]
+}
+
+// IsMemoryFilesystem reports whether fsType keeps its data in memory only,
+// so its contents are lost on reboot
+func IsMemoryFilesystem(fsType string) bool {
+	return fsType == "tmpfs" || fsType == "ramfs"
+}
@@ -0,0 +1,86 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProcMounts(t *testing.T) {
+	content := "/dev/sda3 / xfs rw,relatime 0 0\n" +
+		"nas:/export/My\\040Media /mnt/my\\040media nfs4 rw 0 0\n" +
+		"bogus\n"
+
+	entries := parseProcMounts(content)
+	if len(entries) != 2 {
+		t.Fatalf("parseProcMounts() returned %d entries, want 2", len(entries))
+	}
+	if entries[1].MountPoint != "/mnt/my media" || entries[1].Source != "nas:/export/My Media" {
+		t.Errorf("parseProcMounts() unescaped entry = %+v", entries[1])
+	}
+}
+
+func TestFilesystemType(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nfsDir := filepath.Join(dir, "nfs")
+	if err := os.Mkdir(nfsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := procMountsPath
+	t.Cleanup(func() { procMountsPath = orig })
+	procMountsPath = filepath.Join(dir, "mounts")
+	mounts := "/dev/sda3 / xfs rw 0 0\n" +
+		"tmpfs " + dir + " tmpfs rw 0 0\n" +
+		"nas:/export " + nfsDir + " nfs rw 0 0\n" +
+		// Mounted over the earlier entry for the same directory
+		"nas:/export " + nfsDir + " nfs4 rw 0 0\n"
+	if err := os.WriteFile(procMountsPath, []byte(mounts), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "xfs"},
+		{dir, "tmpfs"},
+		{nfsDir, "nfs4"},
+		// Not created yet: reported for the directory it would be created in
+		{filepath.Join(nfsDir, "appdata", "plex"), "nfs4"},
+		{dir + "-sibling", "xfs"},
+	}
+	for _, tt := range tests {
+		got, err := FilesystemType(tt.path)
+		if err != nil {
+			t.Errorf("FilesystemType(%s) error = %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FilesystemType(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := FilesystemType("relative/path"); err == nil {
+		t.Error("FilesystemType() with a relative path succeeded, want error")
+	}
+}
+
+func TestFilesystemClassification(t *testing.T) {
+	for _, fsType := range []string{"nfs", "nfs4", "cifs", "fuse.sshfs"} {
+		if !IsNetworkFilesystem(fsType) {
+			t.Errorf("IsNetworkFilesystem(%q) = false, want true", fsType)
+		}
+	}
+	for _, fsType := range []string{"xfs", "ext4", "btrfs", "tmpfs"} {
+		if IsNetworkFilesystem(fsType) {
+			t.Errorf("IsNetworkFilesystem(%q) = true, want false", fsType)
+		}
+	}
+	if !IsMemoryFilesystem("tmpfs") || IsMemoryFilesystem("xfs") {
+		t.Error("IsMemoryFilesystem() misclassified tmpfs or xfs")
+	}
+}
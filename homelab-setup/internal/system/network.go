@@ -1,23 +1,52 @@
 package system
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 // TestConnectivity tests connectivity to a host using ping
 func TestConnectivity(host string, timeoutSeconds int) (bool, error) {
+	return TestConnectivityContext(context.Background(), host, timeoutSeconds)
+}
+
+// TestConnectivityContext tests connectivity to a host using ping, killing
+// the ping process if ctx is cancelled
+func TestConnectivityContext(ctx context.Context, host string, timeoutSeconds int) (bool, error) {
+	return TestConnectivityFromContext(ctx, host, "", timeoutSeconds)
+}
+
+// TestConnectivityFromContext pings host with the ICMP socket bound to source,
+// which may be an interface name or a local address. An empty source leaves
+// the choice to the kernel's routing table.
+func TestConnectivityFromContext(ctx context.Context, host, source string, timeoutSeconds int) (bool, error) {
+	args := []string{"-c", "1", "-W", fmt.Sprintf("%d", timeoutSeconds)}
+	if source != "" {
+		args = append(args, "-I", source)
+	}
+	args = append(args, host)
+
 	// Use ping with specified timeout
-	cmd := exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", timeoutSeconds), host)
+	cmd := exec.CommandContext(ctx, "ping", args...)
 	err := cmd.Run()
 
 	if err == nil {
 		return true, nil
 	}
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return false, ctxErr
+	}
+
 	if exitErr, ok := err.(*exec.ExitError); ok {
 		// Ping returns non-zero if host is unreachable
 		if exitErr.ExitCode() != 0 {
@@ -83,7 +112,66 @@ func GetDefaultGateway() (string, error) {
 		return "", fmt.Errorf("failed to get default gateway: %w", err)
 	}
 
-	lines := strings.Split(string(output), "\n")
+	return parseDefaultGateway(string(output))
+}
+
+// DefaultRoute is a default route entry from "ip route"
+type DefaultRoute struct {
+	Gateway string // Empty for device-only routes (e.g. point-to-point links)
+	Device  string
+	Metric  string
+}
+
+// GetDefaultRoutes returns all default routes for the given family ("4" or "6")
+func GetDefaultRoutes(family string) ([]DefaultRoute, error) {
+	cmd := exec.Command("ip", "-"+family, "route", "show", "default")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IPv%s default routes: %w", family, err)
+	}
+
+	return parseDefaultRoutes(string(output)), nil
+}
+
+// parseDefaultRoutes extracts every default route from "ip route" output
+func parseDefaultRoutes(output string) []DefaultRoute {
+	var routes []DefaultRoute
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "default") {
+			continue
+		}
+
+		var route DefaultRoute
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				route.Gateway = fields[i+1]
+			case "dev":
+				route.Device = fields[i+1]
+			case "metric":
+				route.Metric = fields[i+1]
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// GetDefaultGatewayV6 returns the IPv6 default gateway
+func GetDefaultGatewayV6() (string, error) {
+	cmd := exec.Command("ip", "-6", "route")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get IPv6 default gateway: %w", err)
+	}
+
+	return parseDefaultGateway(string(output))
+}
+
+// parseDefaultGateway extracts the gateway from "ip route" output
+func parseDefaultGateway(output string) (string, error) {
+	lines := strings.Split(output, "\n")
 	for _, line := range lines {
 		if strings.HasPrefix(line, "default") {
 			fields := strings.Fields(line)
@@ -125,6 +213,57 @@ func GetAllInterfaces() ([]string, error) {
 	return names, nil
 }
 
+// NetInterface describes a network interface and its addresses
+type NetInterface struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"` // CIDR notation, e.g. 192.168.1.10/24
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	Loopback  bool     `json:"loopback"`
+}
+
+// String returns a one-line description suitable for selection prompts
+func (i NetInterface) String() string {
+	state := "down"
+	if i.Up {
+		state = "up"
+	}
+	if len(i.Addresses) == 0 {
+		return fmt.Sprintf("%s (%s, no addresses)", i.Name, state)
+	}
+	return fmt.Sprintf("%s (%s, %s)", i.Name, state, strings.Join(i.Addresses, ", "))
+}
+
+// ListInterfaces returns all network interfaces with their addresses and link state
+func ListInterfaces() ([]NetInterface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+
+	result := make([]NetInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		ni := NetInterface{
+			Name:     iface.Name,
+			MTU:      iface.MTU,
+			Up:       iface.Flags&net.FlagUp != 0,
+			Loopback: iface.Flags&net.FlagLoopback != 0,
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses for %s: %w", iface.Name, err)
+		}
+		for _, addr := range addrs {
+			ni.Addresses = append(ni.Addresses, addr.String())
+		}
+
+		result = append(result, ni)
+	}
+
+	return result, nil
+}
+
 // IsPortOpen checks if a TCP port is open on a host
 func IsPortOpen(host string, port int, timeoutSeconds int) (bool, error) {
 	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
@@ -144,6 +283,72 @@ func IsPortOpen(host string, port int, timeoutSeconds int) (bool, error) {
 	return true, nil
 }
 
+// IsPortFree reports whether nothing is listening on the local TCP port, by
+// briefly binding it on all addresses. Ports below 1024 may return a
+// permission error when not running as root.
+func IsPortFree(port int) (bool, error) {
+	if port < 1 || port > 65535 {
+		return false, fmt.Errorf("invalid port number (must be 1-65535): %d", port)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check port %d: %w", port, err)
+	}
+	listener.Close()
+	return true, nil
+}
+
+// ssProcessPattern matches the first process in the users:(...) column of ss -p
+var ssProcessPattern = regexp.MustCompile(`users:\(\("([^"]+)",pid=(\d+)`)
+
+// PortListener describes the process listening on the local TCP port, such
+// as "nginx (pid 1234)". It returns an empty string when nothing is listening
+// or the process cannot be seen, which without root is the case for
+// processes of other users.
+func PortListener(port int) string {
+	return portListener(defaultRunner, port)
+}
+
+func portListener(runner CommandRunner, port int) string {
+	stdout, _, err := runner.Run(context.Background(), "ss", "-Hltnp", fmt.Sprintf("sport = :%d", port))
+	if err != nil {
+		return ""
+	}
+	match := ssProcessPattern.FindSubmatch(stdout)
+	if match == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (pid %s)", match[1], match[2])
+}
+
+// resolvConfPath is the resolver configuration, overridable in tests
+var resolvConfPath = "/etc/resolv.conf"
+
+// GetNameservers returns the DNS servers listed in /etc/resolv.conf
+func GetNameservers() ([]string, error) {
+	data, err := os.ReadFile(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", resolvConfPath, err)
+	}
+	return parseNameservers(string(data)), nil
+}
+
+// parseNameservers extracts "nameserver" entries from resolv.conf content
+func parseNameservers(content string) []string {
+	var servers []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
 // ResolveDNS resolves a hostname to IP addresses
 func ResolveDNS(hostname string) ([]string, error) {
 	addrs, err := net.LookupHost(hostname)
@@ -183,6 +388,22 @@ func GetLocalIP() (string, error) {
 	return "", fmt.Errorf("no local IP address found")
 }
 
+// GetLocalIPs returns all non-loopback addresses assigned to this host
+func GetLocalIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface addresses: %w", err)
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
+
 // CheckNFSServer checks if an NFS server is reachable and has exports
 func CheckNFSServer(serverIP string) (bool, error) {
 	// First check if server is reachable
@@ -0,0 +1,131 @@
+package system
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNetInterfaceString(t *testing.T) {
+	tests := []struct {
+		name  string
+		iface NetInterface
+		want  string
+	}{
+		{"up with addresses", NetInterface{Name: "eth0", Up: true, Addresses: []string{"192.168.1.10/24", "fe80::1/64"}}, "eth0 (up, 192.168.1.10/24, fe80::1/64)"},
+		{"down without addresses", NetInterface{Name: "eth1"}, "eth1 (down, no addresses)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.iface.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListInterfacesIncludesLoopback(t *testing.T) {
+	interfaces, err := ListInterfaces()
+	if err != nil {
+		t.Fatalf("ListInterfaces() error = %v", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Loopback {
+			return
+		}
+	}
+	t.Skip("no loopback interface visible in this environment")
+}
+
+func TestParseDefaultRoutes(t *testing.T) {
+	output := `default via 192.168.1.1 dev eth0 proto dhcp metric 100
+default via 10.0.0.1 dev eth1 proto static metric 200
+192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.10
+default dev wg0 scope link
+`
+	routes := parseDefaultRoutes(output)
+	want := []DefaultRoute{
+		{Gateway: "192.168.1.1", Device: "eth0", Metric: "100"},
+		{Gateway: "10.0.0.1", Device: "eth1", Metric: "200"},
+		{Device: "wg0"},
+	}
+
+	if len(routes) != len(want) {
+		t.Fatalf("parseDefaultRoutes() returned %d routes, want %d", len(routes), len(want))
+	}
+	for i := range want {
+		if routes[i] != want[i] {
+			t.Errorf("route %d = %+v, want %+v", i, routes[i], want[i])
+		}
+	}
+}
+
+func TestIsPortFree(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to bind test port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	free, err := IsPortFree(port)
+	if err != nil {
+		t.Fatalf("IsPortFree(%d) error = %v", port, err)
+	}
+	if free {
+		t.Errorf("IsPortFree(%d) = true while the port is bound", port)
+	}
+
+	listener.Close()
+	free, err = IsPortFree(port)
+	if err != nil {
+		t.Fatalf("IsPortFree(%d) after close error = %v", port, err)
+	}
+	if !free {
+		t.Errorf("IsPortFree(%d) = false after the listener was closed", port)
+	}
+
+	if _, err := IsPortFree(70000); err == nil {
+		t.Error("IsPortFree(70000) expected error for out-of-range port")
+	}
+}
+
+func TestPortListener(t *testing.T) {
+	runner := NewFakeRunner().
+		On("ss -Hltnp sport = :80", FakeResponse{Stdout: []byte(`LISTEN 0      511          0.0.0.0:80        0.0.0.0:*    users:(("nginx",pid=1234,fd=6),("nginx",pid=1235,fd=6))` + "\n")}).
+		On("ss -Hltnp sport = :443", FakeResponse{Stdout: []byte("LISTEN 0      511          0.0.0.0:443        0.0.0.0:*\n")}).
+		On("ss -Hltnp sport = :8080", FakeResponse{Err: &FakeExitError{Code: 1}})
+
+	tests := []struct {
+		port int
+		want string
+	}{
+		{80, "nginx (pid 1234)"},
+		{443, ""}, // owned by another user
+		{8080, ""},
+	}
+	for _, tt := range tests {
+		if got := portListener(runner, tt.port); got != tt.want {
+			t.Errorf("portListener(%d) = %q, want %q", tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestParseNameservers(t *testing.T) {
+	content := `# Generated by NetworkManager
+search lan
+nameserver 127.0.0.53
+nameserver  192.168.1.1
+options edns0 trust-ad
+nameserver
+`
+	got := parseNameservers(content)
+	want := []string{"127.0.0.53", "192.168.1.1"}
+	if len(got) != len(want) {
+		t.Fatalf("parseNameservers() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseNameservers()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
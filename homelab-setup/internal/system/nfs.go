@@ -0,0 +1,219 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// NFSMountTestResult describes the outcome of a temporary read/write mount test
+type NFSMountTestResult struct {
+	MountPoint string
+	// RootSquashed is true when root could not write to the export,
+	// which normally means root_squash is in effect on the server
+	RootSquashed bool
+}
+
+// classifyNFSMountError turns mount(8) output into an actionable message
+func classifyNFSMountError(output string) string {
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "access denied"):
+		return "server denied the mount (check that this host is allowed in the export's client list)"
+	case strings.Contains(lower, "stale file handle"):
+		return "stale file handle (the export changed on the server; restart nfs-server or re-export)"
+	case strings.Contains(lower, "no such file or directory"):
+		return "export path does not exist on the server"
+	case strings.Contains(lower, "connection refused"):
+		return "connection refused (is the NFS service running on the server?)"
+	case strings.Contains(lower, "timed out"):
+		return "connection timed out (check firewall rules for NFS)"
+	case strings.Contains(lower, "wrong fs type") || strings.Contains(lower, "bad option"):
+		return "mount helper missing or bad mount options (is nfs-utils installed?)"
+	}
+	return "mount failed"
+}
+
+// TestNFSMountReadWrite temporarily mounts server:export, writes and reads back
+// a test file as the invoking user, probes whether root is squashed, then
+// unmounts. Requires sudo for mount/umount.
+func TestNFSMountReadWrite(server, export, options string) (result *NFSMountTestResult, err error) {
+	mountPoint, err := os.MkdirTemp("", "homelab-nfs-test-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary mount point: %w", err)
+	}
+	defer os.Remove(mountPoint)
+
+	source := fmt.Sprintf("%s:%s", server, export)
+	args := []string{"-n", "mount", "-t", "nfs"}
+	if options != "" {
+		args = append(args, "-o", options)
+	}
+	args = append(args, source, mountPoint)
+
+	mountCmd := exec.Command("sudo", args...)
+	start := time.Now()
+	output, mountErr := mountCmd.CombinedOutput()
+	auditCommand(mountCmd.Args, start, mountErr)
+	if mountErr != nil {
+		return nil, fmt.Errorf("failed to mount %s: %s: %w\nOutput: %s", source, classifyNFSMountError(string(output)), mountErr, strings.TrimSpace(string(output)))
+	}
+	defer func() {
+		umountCmd := exec.Command("sudo", "-n", "umount", mountPoint)
+		start := time.Now()
+		output, umountErr := umountCmd.CombinedOutput()
+		auditCommand(umountCmd.Args, start, umountErr)
+		if umountErr != nil && err == nil {
+			err = fmt.Errorf("test succeeded but failed to unmount %s: %w\nOutput: %s", mountPoint, umountErr, string(output))
+		}
+	}()
+
+	result = &NFSMountTestResult{MountPoint: mountPoint}
+
+	// Write and read back as the invoking user (containers write as a regular UID)
+	testFile := filepath.Join(mountPoint, fmt.Sprintf(".homelab-setup-write-test-%d", os.Getpid()))
+	content := []byte("homelab-setup NFS write test")
+
+	if writeErr := os.WriteFile(testFile, content, 0644); writeErr != nil {
+		switch {
+		case errors.Is(writeErr, syscall.EROFS):
+			return result, fmt.Errorf("export is mounted read-only (check the rw option in the server's exports): %w", writeErr)
+		case errors.Is(writeErr, syscall.EACCES), errors.Is(writeErr, syscall.EPERM):
+			return result, fmt.Errorf("export is not writable by UID %d (check export ownership and all_squash/anonuid settings): %w", os.Getuid(), writeErr)
+		case errors.Is(writeErr, syscall.ESTALE):
+			return result, fmt.Errorf("stale file handle while writing: %w", writeErr)
+		}
+		return result, fmt.Errorf("failed to write test file: %w", writeErr)
+	}
+	defer os.Remove(testFile)
+
+	readBack, readErr := os.ReadFile(testFile)
+	if readErr != nil {
+		return result, fmt.Errorf("failed to read back test file: %w", readErr)
+	}
+	if !bytes.Equal(readBack, content) {
+		return result, fmt.Errorf("test file content mismatch after write")
+	}
+
+	// Probe root squashing; informational only
+	rootFile := testFile + "-root"
+	touchCmd := exec.Command("sudo", "-n", "touch", rootFile)
+	start = time.Now()
+	touchErr := touchCmd.Run()
+	auditCommand(touchCmd.Args, start, touchErr)
+	if touchErr == nil {
+		rmCmd := exec.Command("sudo", "-n", "rm", "-f", rootFile)
+		start = time.Now()
+		rmErr := rmCmd.Run()
+		auditCommand(rmCmd.Args, start, rmErr)
+	} else {
+		result.RootSquashed = true
+	}
+
+	return result, nil
+}
+
+// NFSExport is one entry of a server's export list
+type NFSExport struct {
+	Path    string
+	Clients []string // Client specs as reported by showmount (IP, CIDR, hostname, wildcard, @netgroup)
+}
+
+// ParseNFSExports parses `showmount -e` output into export entries, e.g.
+//
+//	Export list for nas:
+//	/volume1/media 192.168.1.0/24,10.0.0.5
+//	/volume1/photos *
+//
+// It fails on lines that do not start with an export path, so callers can
+// fall back to showing the raw output.
+func ParseNFSExports(raw string) ([]NFSExport, error) {
+	var exports []NFSExport
+	for i, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Export list") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if !strings.HasPrefix(fields[0], "/") {
+			return nil, fmt.Errorf("unexpected showmount output on line %d: %q", i+1, line)
+		}
+		export := NFSExport{Path: fields[0]}
+		for _, field := range fields[1:] {
+			for _, client := range strings.Split(field, ",") {
+				if client != "" {
+					export.Clients = append(export.Clients, client)
+				}
+			}
+		}
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+// AllowsClient reports whether the export's client list includes a host with
+// the given addresses or hostname. known is false when the ACL uses entries
+// (such as netgroups) that cannot be evaluated locally.
+func (e NFSExport) AllowsClient(addrs []net.IP, hostname string) (allowed bool, known bool) {
+	known = true
+	for _, client := range e.Clients {
+		switch {
+		case client == "*" || client == "(everyone)":
+			return true, true
+		case strings.HasPrefix(client, "@"):
+			known = false
+		case strings.Contains(client, "/"):
+			_, network, err := net.ParseCIDR(client)
+			if err != nil {
+				known = false
+				continue
+			}
+			for _, addr := range addrs {
+				if network.Contains(addr) {
+					return true, true
+				}
+			}
+		default:
+			if ip := net.ParseIP(client); ip != nil {
+				for _, addr := range addrs {
+					if ip.Equal(addr) {
+						return true, true
+					}
+				}
+				continue
+			}
+			if hostname == "" {
+				known = false
+				continue
+			}
+			if matched, _ := path.Match(strings.ToLower(client), strings.ToLower(hostname)); matched {
+				return true, true
+			}
+			if !strings.Contains(client, "*") && !strings.Contains(client, "?") {
+				// Plain hostname that may resolve to one of our addresses
+				resolved, err := net.LookupIP(client)
+				if err != nil {
+					known = false
+					continue
+				}
+				for _, r := range resolved {
+					for _, addr := range addrs {
+						if r.Equal(addr) {
+							return true, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false, known
+}
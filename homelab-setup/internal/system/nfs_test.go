@@ -0,0 +1,106 @@
+package system
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyNFSMountError(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"access denied", "mount.nfs: access denied by server while mounting 10.0.0.1:/srv", "server denied the mount (check that this host is allowed in the export's client list)"},
+		{"stale handle", "mount.nfs: Stale file handle", "stale file handle (the export changed on the server; restart nfs-server or re-export)"},
+		{"missing export", "mount.nfs: mounting 10.0.0.1:/nope failed, reason given by server: No such file or directory", "export path does not exist on the server"},
+		{"timeout", "mount.nfs: Connection timed out", "connection timed out (check firewall rules for NFS)"},
+		{"unknown", "something odd", "mount failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyNFSMountError(tt.output); got != tt.want {
+				t.Errorf("classifyNFSMountError(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNFSExports(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []NFSExport
+		wantErr bool
+	}{
+		{
+			name: "linux server",
+			raw: `Export list for nas:
+/mnt/media  192.168.1.0/24,10.0.0.5
+/mnt/photos *
+/mnt/backup @trusted
+`,
+			want: []NFSExport{
+				{Path: "/mnt/media", Clients: []string{"192.168.1.0/24", "10.0.0.5"}},
+				{Path: "/mnt/photos", Clients: []string{"*"}},
+				{Path: "/mnt/backup", Clients: []string{"@trusted"}},
+			},
+		},
+		{
+			name: "synology with hostname wildcards",
+			raw: `Export list for diskstation.lan:
+/volume1/media     *.lan,192.168.10.0/24
+/volume1/downloads (everyone)
+`,
+			want: []NFSExport{
+				{Path: "/volume1/media", Clients: []string{"*.lan", "192.168.10.0/24"}},
+				{Path: "/volume1/downloads", Clients: []string{"(everyone)"}},
+			},
+		},
+		{name: "header only", raw: "Export list for nas:\n"},
+		{name: "unparseable", raw: "clnt_create: RPC: Program not registered\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNFSExports(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNFSExports() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNFSExports() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNFSExportAllowsClient(t *testing.T) {
+	addrs := []net.IP{net.ParseIP("192.168.1.20")}
+
+	tests := []struct {
+		name        string
+		clients     []string
+		hostname    string
+		wantAllowed bool
+		wantKnown   bool
+	}{
+		{"everyone", []string{"*"}, "", true, true},
+		{"cidr match", []string{"192.168.1.0/24"}, "", true, true},
+		{"cidr miss", []string{"10.0.0.0/8"}, "", false, true},
+		{"exact ip", []string{"192.168.1.20"}, "", true, true},
+		{"wildcard hostname", []string{"*.lan"}, "minipc.lan", true, true},
+		{"netgroup", []string{"@trusted"}, "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := NFSExport{Path: "/mnt/media", Clients: tt.clients}
+			allowed, known := export.AllowsClient(addrs, tt.hostname)
+			if allowed != tt.wantAllowed || known != tt.wantKnown {
+				t.Errorf("AllowsClient() = (%v, %v), want (%v, %v)", allowed, known, tt.wantAllowed, tt.wantKnown)
+			}
+		})
+	}
+}
@@ -29,6 +29,22 @@ func IsPackageInstalled(packageName string) (bool, error) {
 	return false, fmt.Errorf("failed to check package %s: %w", packageName, err)
 }
 
+// InstallPackages layers packages with rpm-ostree. The change takes effect
+// after a reboot, which is recorded with MarkRebootRequired.
+func InstallPackages(packages ...string) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	args := append([]string{"install", "--idempotent", "--allow-inactive"}, packages...)
+	output, err := RunPrivileged("rpm-ostree", args...)
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %w\nOutput: %s", strings.Join(packages, ", "), err, string(output))
+	}
+
+	return MarkRebootRequired("layered packages: " + strings.Join(packages, ", "))
+}
+
 // CheckMultiplePackages checks if multiple packages are installed
 // Returns a map of package name -> installed status
 func CheckMultiplePackages(packages []string) (map[string]bool, error) {
@@ -0,0 +1,185 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+)
+
+// PermissionChange describes an ownership or mode fix needed for a path
+type PermissionChange struct {
+	Path     string
+	OldOwner string // "uid:gid"
+	NewOwner string // "uid:gid"
+	OldMode  os.FileMode
+	NewMode  os.FileMode
+}
+
+// NeedsChown reports whether the change alters ownership
+func (c PermissionChange) NeedsChown() bool {
+	return c.OldOwner != c.NewOwner
+}
+
+// NeedsChmod reports whether the change alters the permission bits
+func (c PermissionChange) NeedsChmod() bool {
+	return c.OldMode != c.NewMode
+}
+
+// saneMode returns perm with the owner bits required for containers to work:
+// rwx on directories and rw on files. Existing bits are never removed.
+func saneMode(perm os.FileMode, isDir bool) os.FileMode {
+	if isDir {
+		return perm | 0700
+	}
+	return perm | 0600
+}
+
+// permissionScanFormat is the find -printf format of a permission scan:
+// owner, octal mode, type and path, NUL-terminated so any file name parses
+const permissionScanFormat = `%U:%G %m %y %p\0`
+
+// findScope returns the find arguments selecting base (only base itself
+// unless recursive) without the trees under skip, and never symlinks, so
+// their targets are not modified
+func findScope(base string, recursive bool, skip []string) []string {
+	args := []string{base}
+	if !recursive {
+		args = append(args, "-maxdepth", "0")
+	}
+	if recursive && len(skip) > 0 {
+		args = append(args, "(")
+		for i, path := range skip {
+			if i > 0 {
+				args = append(args, "-o")
+			}
+			args = append(args, "-path", filepath.Clean(path))
+		}
+		args = append(args, ")", "-prune", "-o")
+	}
+	return append(args, "!", "-type", "l")
+}
+
+// parsePermissionScan returns the entries of a permission scan whose owner
+// or mode differs from uid:gid and saneMode. A negative gid (UID without a
+// passwd entry) keeps each entry's group.
+func parsePermissionScan(output []byte, uid, gid int) ([]PermissionChange, error) {
+	var changes []PermissionChange
+	for _, record := range strings.Split(string(output), "\x00") {
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected find output %q", record)
+		}
+		current, path := fields[0], fields[3]
+		mode, err := strconv.ParseUint(fields[1], 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected mode %q for %s", fields[1], path)
+		}
+
+		want := fmt.Sprintf("%d:%d", uid, gid)
+		if gid < 0 {
+			_, currentGid, _ := strings.Cut(current, ":")
+			want = fmt.Sprintf("%d:%s", uid, currentGid)
+		}
+		oldMode := os.FileMode(mode).Perm()
+		newMode := saneMode(oldMode, fields[2] == "d")
+		if current != want || oldMode != newMode {
+			changes = append(changes, PermissionChange{
+				Path:     path,
+				OldOwner: current,
+				NewOwner: want,
+				OldMode:  oldMode,
+				NewMode:  newMode,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// findPermissionChanges scans base (or only base when recursive is false)
+// with sudo, so directories the homelab user cannot read (such as a
+// database's 0700 data directory) do not stop the scan, and returns every
+// entry whose owner or mode differs from the expected values. Trees under
+// skip are left out.
+func findPermissionChanges(runner CommandRunner, base string, uid, gid int, recursive bool, skip []string) ([]PermissionChange, error) {
+	args := append([]string{"-n", "find"}, findScope(base, recursive, skip)...)
+	args = append(args, "-printf", permissionScanFormat)
+	stdout, stderr, err := runner.Run(context.Background(), "sudo", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", base, newCommandError("sudo", args, sudoError(stderr, err), stderr))
+	}
+	return parsePermissionScan(stdout, uid, gid)
+}
+
+// repairPermissions fixes the owner and adds the missing owner permission
+// bits of every entry findPermissionChanges would report, with one find per
+// kind of change instead of one command per entry
+func repairPermissions(runner CommandRunner, base string, uid, gid int, recursive bool, skip []string) error {
+	owner := strconv.Itoa(uid)
+	ownerTest := []string{"!", "-uid", owner}
+	if gid >= 0 {
+		owner = fmt.Sprintf("%d:%d", uid, gid)
+		ownerTest = []string{"(", "!", "-uid", strconv.Itoa(uid), "-o", "!", "-gid", strconv.Itoa(gid), ")"}
+	}
+
+	fixes := [][]string{
+		append(ownerTest, "-exec", "chown", owner, "{}", "+"),
+		{"-type", "d", "!", "-perm", "-700", "-exec", "chmod", "u+rwx", "{}", "+"},
+		{"!", "-type", "d", "!", "-perm", "-600", "-exec", "chmod", "u+rw", "{}", "+"},
+	}
+	for _, fix := range fixes {
+		args := append([]string{"-n", "find"}, findScope(base, recursive, skip)...)
+		args = append(args, fix...)
+		if skipForDryRun("run %s", commandLine("sudo", args)) {
+			continue
+		}
+		if _, stderr, err := runner.Run(context.Background(), "sudo", args...); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", base, newCommandError("sudo", args, sudoError(stderr, err), stderr))
+		}
+	}
+	return nil
+}
+
+// PreviewAppdataPermissions returns the changes RepairAppdataPermissions would
+// make without modifying anything (dry run). Trees under skip are left out.
+func PreviewAppdataPermissions(appdataBase, owner string, recursive bool, skip ...string) ([]PermissionChange, error) {
+	uid, gid, err := appdataOwner(appdataBase, owner)
+	if err != nil {
+		return nil, err
+	}
+	return findPermissionChanges(defaultRunner, appdataBase, uid, gid, recursive, skip)
+}
+
+// RepairAppdataPermissions chowns the appdata tree to owner ("user" or
+// "user:group") and adds missing owner permission bits, leaving the trees
+// under skip alone. Returns the number of entries that needed changes.
+func RepairAppdataPermissions(appdataBase, owner string, recursive bool, skip ...string) (int, error) {
+	uid, gid, err := appdataOwner(appdataBase, owner)
+	if err != nil {
+		return 0, err
+	}
+	changes, err := findPermissionChanges(defaultRunner, appdataBase, uid, gid, recursive, skip)
+	if err != nil || len(changes) == 0 {
+		return 0, err
+	}
+	if err := repairPermissions(defaultRunner, appdataBase, uid, gid, recursive, skip); err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// appdataOwner validates appdataBase and resolves owner to numeric IDs
+func appdataOwner(appdataBase, owner string) (int, int, error) {
+	if err := common.ValidateBasePath(appdataBase); err != nil && !errors.Is(err, common.ErrPathNotAllowlisted) {
+		return 0, 0, fmt.Errorf("invalid appdata path: %w", err)
+	}
+	return ResolveOwner(owner)
+}
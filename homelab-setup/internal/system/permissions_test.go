@@ -0,0 +1,134 @@
+package system
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePermissionScan(t *testing.T) {
+	output := strings.Join([]string{
+		"1000:1000 755 d /srv/appdata",
+		"0:0 700 d /srv/appdata/plex",
+		"1000:1000 644 f /srv/appdata/plex/Preferences.xml",
+		"1000:1000 400 f /srv/appdata/plex/with space",
+		"",
+	}, "\x00")
+
+	changes, err := parsePermissionScan([]byte(output), 1000, 1000)
+	if err != nil {
+		t.Fatalf("parsePermissionScan() error = %v", err)
+	}
+	want := []PermissionChange{
+		{Path: "/srv/appdata/plex", OldOwner: "0:0", NewOwner: "1000:1000", OldMode: 0700, NewMode: 0700},
+		{Path: "/srv/appdata/plex/with space", OldOwner: "1000:1000", NewOwner: "1000:1000", OldMode: 0400, NewMode: 0600},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("parsePermissionScan() = %+v, want %+v", changes, want)
+	}
+
+	// A negative gid keeps each entry's group
+	changes, err = parsePermissionScan([]byte("1000:5000 755 d /srv/appdata\x00"), 1000, -1)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("parsePermissionScan(gid -1) = %+v, %v, want no changes", changes, err)
+	}
+
+	if _, err := parsePermissionScan([]byte("garbage\x00"), 1000, 1000); err == nil {
+		t.Error("parsePermissionScan() accepted malformed output")
+	}
+}
+
+func TestFindPermissionChangesSkipsTrees(t *testing.T) {
+	scan := "sudo -n find /srv/appdata ( -path /srv/appdata/immich-db -o -path /srv/appdata/nextcloud-db ) -prune -o ! -type l -printf " + permissionScanFormat
+	runner := NewFakeRunner().On(scan, FakeResponse{Stdout: []byte("0:0 755 d /srv/appdata/plex\x00")})
+
+	changes, err := findPermissionChanges(runner, "/srv/appdata", 1000, 1000, true, []string{"/srv/appdata/immich-db", "/srv/appdata/nextcloud-db/"})
+	if err != nil {
+		t.Fatalf("findPermissionChanges() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "/srv/appdata/plex" {
+		t.Errorf("findPermissionChanges() = %+v", changes)
+	}
+	if calls := runner.Calls(); len(calls) != 1 || calls[0] != scan {
+		t.Errorf("calls = %q, want one privileged scan", calls)
+	}
+}
+
+func TestFindPermissionChangesNonRecursive(t *testing.T) {
+	scan := "sudo -n find /srv/appdata -maxdepth 0 ! -type l -printf " + permissionScanFormat
+	runner := NewFakeRunner().On(scan, FakeResponse{Stdout: []byte("1000:1000 755 d /srv/appdata\x00")})
+
+	changes, err := findPermissionChanges(runner, "/srv/appdata", 1000, 1000, false, []string{"/srv/appdata/immich-db"})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("findPermissionChanges() = %+v, %v, want no changes", changes, err)
+	}
+}
+
+func TestFindPermissionChangesReportsScanFailure(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.Default = FakeResponse{Stderr: []byte("find: '/srv/missing': No such file or directory\n"), Err: &FakeExitError{Code: 1}}
+
+	_, err := findPermissionChanges(runner, "/srv/missing", 1000, 1000, true, nil)
+	if err == nil || !strings.Contains(err.Error(), "No such file or directory") {
+		t.Errorf("findPermissionChanges() error = %v, want the find error output", err)
+	}
+}
+
+func TestRepairPermissionsRunsInBulk(t *testing.T) {
+	runner := NewFakeRunner()
+	if err := repairPermissions(runner, "/srv/appdata", 1000, 1000, true, []string{"/srv/appdata/immich-db"}); err != nil {
+		t.Fatalf("repairPermissions() error = %v", err)
+	}
+
+	scope := "sudo -n find /srv/appdata ( -path /srv/appdata/immich-db ) -prune -o ! -type l "
+	want := []string{
+		scope + "( ! -uid 1000 -o ! -gid 1000 ) -exec chown 1000:1000 {} +",
+		scope + "-type d ! -perm -700 -exec chmod u+rwx {} +",
+		scope + "! -type d ! -perm -600 -exec chmod u+rw {} +",
+	}
+	if calls := runner.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q\nwant %q", calls, want)
+	}
+
+	// Without a group only the user is changed
+	runner = NewFakeRunner()
+	if err := repairPermissions(runner, "/srv/appdata", 1000, -1, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := runner.Calls()[0]; got != "sudo -n find /srv/appdata -maxdepth 0 ! -type l ! -uid 1000 -exec chown 1000 {} +" {
+		t.Errorf("chown call = %q", got)
+	}
+}
+
+func TestRepairPermissionsDryRun(t *testing.T) {
+	var logged []string
+	SetDryRun(true, func(action string) { logged = append(logged, action) })
+	t.Cleanup(func() { SetDryRun(false, nil) })
+
+	runner := NewFakeRunner()
+	if err := repairPermissions(runner, "/srv/appdata", 1000, 1000, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.Calls()) != 0 || len(logged) != 3 {
+		t.Errorf("dry run ran %q and logged %q", runner.Calls(), logged)
+	}
+}
+
+func TestSaneMode(t *testing.T) {
+	tests := []struct {
+		perm  os.FileMode
+		isDir bool
+		want  os.FileMode
+	}{
+		{0755, true, 0755},
+		{0500, true, 0700},
+		{0044, false, 0644},
+		{0600, false, 0600},
+	}
+	for _, tt := range tests {
+		if got := saneMode(tt.perm, tt.isDir); got != tt.want {
+			t.Errorf("saneMode(%o, %v) = %o, want %o", tt.perm, tt.isDir, got, tt.want)
+		}
+	}
+}
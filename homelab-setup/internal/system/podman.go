@@ -0,0 +1,61 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Daemon sockets and sysctls read by the rootless Podman checks, overridable
+// in tests
+var (
+	rootfulPodmanSocket       = "/run/podman/podman.sock"
+	dockerSocket              = "/var/run/docker.sock"
+	unprivilegedPortStartPath = "/proc/sys/net/ipv4/ip_unprivileged_port_start"
+)
+
+// defaultUnprivilegedPortStart is the kernel's default lowest port that
+// unprivileged processes may bind
+const defaultUnprivilegedPortStart = 1024
+
+// RootlessPodmanSocket returns the current user's Podman API socket path
+func RootlessPodmanSocket() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, "podman", "podman.sock")
+}
+
+// DetectRootlessPodman reports whether containers on this host run under
+// rootless Podman: the current user's Podman socket exists and neither a
+// rootful Podman nor a Docker daemon socket does. It returns the user socket.
+func DetectRootlessPodman() (string, bool) {
+	socket := RootlessPodmanSocket()
+	if _, err := os.Stat(socket); err != nil {
+		return "", false
+	}
+	for _, daemon := range []string{rootfulPodmanSocket, dockerSocket} {
+		if _, err := os.Stat(daemon); err == nil {
+			return "", false
+		}
+	}
+	return socket, true
+}
+
+// UnprivilegedPortStart returns the lowest port rootless containers may
+// publish (net.ipv4.ip_unprivileged_port_start), or the kernel default when
+// it cannot be read
+func UnprivilegedPortStart() int {
+	content, err := os.ReadFile(unprivilegedPortStartPath)
+	if err != nil {
+		return defaultUnprivilegedPortStart
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || start < 0 {
+		return defaultUnprivilegedPortStart
+	}
+	return start
+}
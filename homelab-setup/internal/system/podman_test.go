@@ -0,0 +1,67 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withPodmanSockets points the daemon socket paths into a temp dir and
+// returns it
+func withPodmanSockets(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	origRootful, origDocker := rootfulPodmanSocket, dockerSocket
+	rootfulPodmanSocket = filepath.Join(dir, "run", "podman", "podman.sock")
+	dockerSocket = filepath.Join(dir, "docker.sock")
+	t.Cleanup(func() { rootfulPodmanSocket, dockerSocket = origRootful, origDocker })
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dir, "user"))
+	return dir
+}
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectRootlessPodman(t *testing.T) {
+	dir := withPodmanSockets(t)
+	if _, ok := DetectRootlessPodman(); ok {
+		t.Error("DetectRootlessPodman() = true without a user socket")
+	}
+
+	userSocket := filepath.Join(dir, "user", "podman", "podman.sock")
+	touch(t, userSocket)
+	socket, ok := DetectRootlessPodman()
+	if !ok || socket != userSocket {
+		t.Errorf("DetectRootlessPodman() = (%q, %v), want (%q, true)", socket, ok, userSocket)
+	}
+
+	touch(t, dockerSocket)
+	if _, ok := DetectRootlessPodman(); ok {
+		t.Error("DetectRootlessPodman() = true with a Docker daemon running")
+	}
+}
+
+func TestUnprivilegedPortStart(t *testing.T) {
+	orig := unprivilegedPortStartPath
+	t.Cleanup(func() { unprivilegedPortStartPath = orig })
+
+	unprivilegedPortStartPath = filepath.Join(t.TempDir(), "missing")
+	if got := UnprivilegedPortStart(); got != 1024 {
+		t.Errorf("UnprivilegedPortStart() without sysctl = %d, want 1024", got)
+	}
+
+	unprivilegedPortStartPath = filepath.Join(t.TempDir(), "ip_unprivileged_port_start")
+	if err := os.WriteFile(unprivilegedPortStartPath, []byte("80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := UnprivilegedPortStart(); got != 80 {
+		t.Errorf("UnprivilegedPortStart() = %d, want 80", got)
+	}
+}
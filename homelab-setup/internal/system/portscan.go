@@ -0,0 +1,56 @@
+package system
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ScanPorts dials each TCP port on host with at most concurrency dials in
+// flight and returns the open ports in ascending order. Each dial gives up
+// after timeout; cancelling ctx stops the scan early.
+func ScanPorts(ctx context.Context, host string, ports []int, concurrency int, timeout time.Duration) []int {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var open []int
+	var wg sync.WaitGroup
+
+	dialer := net.Dialer{Timeout: timeout}
+	for i := 0; i < concurrency && i < len(ports); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for port := range jobs {
+				conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+				if err != nil {
+					continue
+				}
+				conn.Close()
+				mu.Lock()
+				open = append(open, port)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, port := range ports {
+		select {
+		case jobs <- port:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Ints(open)
+	return open
+}
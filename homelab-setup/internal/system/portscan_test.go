@@ -0,0 +1,48 @@
+package system
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// closedPort returns a local port that nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestScanPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	openPort := ln.Addr().(*net.TCPAddr).Port
+
+	ports := []int{closedPort(t), openPort, closedPort(t)}
+	got := ScanPorts(context.Background(), "127.0.0.1", ports, 2, time.Second)
+	if len(got) != 1 || got[0] != openPort {
+		t.Errorf("ScanPorts() = %v, want [%d]", got, openPort)
+	}
+}
+
+func TestScanPortsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if got := ScanPorts(ctx, "127.0.0.1", []int{closedPort(t), closedPort(t)}, 1, time.Second); len(got) != 0 {
+		t.Errorf("ScanPorts() with cancelled context = %v, want none", got)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled scan took %s", elapsed)
+	}
+}
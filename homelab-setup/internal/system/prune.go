@@ -0,0 +1,169 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// anonymousVolumeName matches the generated names of anonymous volumes.
+// Named volumes (including any backing appdata) never match and are never
+// pruned.
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// PruneCandidates lists what PruneContainerResources would remove
+type PruneCandidates struct {
+	Images  []string // "ID SIZE" of each dangling image
+	Volumes []string // names of unused anonymous volumes
+}
+
+// Empty reports whether there is nothing to prune
+func (p PruneCandidates) Empty() bool {
+	return len(p.Images) == 0 && len(p.Volumes) == 0
+}
+
+// String summarises the candidates
+func (p PruneCandidates) String() string {
+	return fmt.Sprintf("%d dangling image(s), %d unused anonymous volume(s)", len(p.Images), len(p.Volumes))
+}
+
+// runtimeCommand returns the CLI binary for a runtime
+func runtimeCommand(runtime ContainerRuntime) (string, error) {
+	switch runtime {
+	case RuntimePodman, RuntimeDocker:
+		return string(runtime), nil
+	default:
+		return "", fmt.Errorf("unsupported runtime: %s", runtime)
+	}
+}
+
+// nonEmptyLines splits command output into trimmed, non-empty lines
+func nonEmptyLines(output []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// filterAnonymousVolumes keeps only generated (anonymous) volume names
+func filterAnonymousVolumes(names []string) []string {
+	var anonymous []string
+	for _, name := range names {
+		if anonymousVolumeName.MatchString(name) {
+			anonymous = append(anonymous, name)
+		}
+	}
+	return anonymous
+}
+
+// ListPruneCandidates returns the dangling images and unused anonymous
+// volumes of the runtime. Images used by any container, and all named
+// volumes, are excluded.
+func ListPruneCandidates(runtime ContainerRuntime) (PruneCandidates, error) {
+	bin, err := runtimeCommand(runtime)
+	if err != nil {
+		return PruneCandidates{}, err
+	}
+
+	images, err := exec.Command(bin, "images", "--filter", "dangling=true", "--format", "{{.ID}} {{.Size}}").Output()
+	if err != nil {
+		return PruneCandidates{}, fmt.Errorf("failed to list dangling images: %w", err)
+	}
+
+	volumes, err := exec.Command(bin, "volume", "ls", "--quiet", "--filter", "dangling=true").Output()
+	if err != nil {
+		return PruneCandidates{}, fmt.Errorf("failed to list unused volumes: %w", err)
+	}
+
+	return PruneCandidates{
+		Images:  nonEmptyLines(images),
+		Volumes: filterAnonymousVolumes(nonEmptyLines(volumes)),
+	}, nil
+}
+
+// containerStorageRoot returns the directory holding the runtime's images
+// and volumes
+func containerStorageRoot(runtime ContainerRuntime) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime {
+	case RuntimePodman:
+		cmd = exec.Command("podman", "info", "--format", "{{.Store.GraphRoot}}")
+	case RuntimeDocker:
+		cmd = exec.Command("docker", "info", "--format", "{{.DockerRootDir}}")
+	default:
+		return "", fmt.Errorf("unsupported runtime: %s", runtime)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get container storage root: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ContainerStorageFree returns the free space on the filesystem holding the
+// runtime's images and volumes, and that directory
+func ContainerStorageFree(runtime ContainerRuntime) (free uint64, root string, err error) {
+	root, err = containerStorageRoot(runtime)
+	if err != nil {
+		return 0, "", err
+	}
+	_, _, free, err = GetDiskUsage(root)
+	return free, root, err
+}
+
+// PruneContainerResources removes dangling images, unused anonymous volumes
+// and (for Docker) the build cache. Images used by any container, including
+// stopped ones, and named volumes are kept. With dryRun nothing is removed
+// and the result summarises the candidates instead of the space reclaimed.
+func PruneContainerResources(runtime ContainerRuntime, dryRun bool) (reclaimed string, err error) {
+	candidates, err := ListPruneCandidates(runtime)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		return candidates.String(), nil
+	}
+
+	bin, err := runtimeCommand(runtime)
+	if err != nil {
+		return "", err
+	}
+
+	// Reclaimed space is measured on the storage filesystem because podman
+	// does not report it for every prune subcommand
+	before, root, err := ContainerStorageFree(runtime)
+	if err != nil {
+		return "", err
+	}
+
+	if output, err := exec.Command(bin, "image", "prune", "--force").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to prune images: %w\nOutput: %s", err, string(output))
+	}
+
+	if len(candidates.Volumes) > 0 {
+		args := append([]string{"volume", "rm"}, candidates.Volumes...)
+		if output, err := exec.Command(bin, args...).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to remove unused volumes: %w\nOutput: %s", err, string(output))
+		}
+	}
+
+	if runtime == RuntimeDocker {
+		if output, err := exec.Command(bin, "builder", "prune", "--force").CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to prune build cache: %w\nOutput: %s", err, string(output))
+		}
+	}
+
+	_, _, after, err := GetDiskUsage(root)
+	if err != nil {
+		return "", err
+	}
+	if after < before {
+		return FormatBytes(0), nil
+	}
+	return FormatBytes(int64(after - before)), nil
+}
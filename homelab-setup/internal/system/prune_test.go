@@ -0,0 +1,30 @@
+package system
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterAnonymousVolumes(t *testing.T) {
+	anon := strings.Repeat("a1", 32)
+	names := []string{"appdata", "media_plex-config", anon, "ABC" + anon[3:], anon + "0"}
+
+	got := filterAnonymousVolumes(names)
+	if want := []string{anon}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filterAnonymousVolumes() = %v, want %v", got, want)
+	}
+}
+
+func TestPruneCandidatesString(t *testing.T) {
+	p := PruneCandidates{Images: []string{"abc 1.2GB", "def 300MB"}}
+	if p.Empty() {
+		t.Error("expected candidates to be non-empty")
+	}
+	if got, want := p.String(), "2 dangling image(s), 0 unused anonymous volume(s)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !(PruneCandidates{}).Empty() {
+		t.Error("expected zero value to be empty")
+	}
+}
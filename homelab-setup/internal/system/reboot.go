@@ -0,0 +1,121 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RebootFlagFile records that an operation requires a reboot. It stores the
+// boot ID at the time it was written so the flag expires once the system has
+// rebooted. /var/tmp persists across reboots and is writable without sudo.
+var RebootFlagFile = "/var/tmp/homelab-setup-reboot-required"
+
+// bootIDFile holds the kernel's random ID for the current boot
+const bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// currentBootID returns the ID of the current boot, or "" if unavailable
+func currentBootID() string {
+	data, err := os.ReadFile(bootIDFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// MarkRebootRequired records that a reboot is needed, with a short reason
+func MarkRebootRequired(reason string) error {
+	content := fmt.Sprintf("%s\n%s\n", currentBootID(), reason)
+	if err := os.WriteFile(RebootFlagFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to record pending reboot: %w", err)
+	}
+	return nil
+}
+
+// ClearRebootRequired removes the pending-reboot flag
+func ClearRebootRequired() error {
+	if err := os.Remove(RebootFlagFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear pending reboot flag: %w", err)
+	}
+	return nil
+}
+
+// trackedRebootReason returns the reason recorded by MarkRebootRequired for
+// the current boot. A flag written during an earlier boot is removed.
+func trackedRebootReason() (string, bool) {
+	data, err := os.ReadFile(RebootFlagFile)
+	if err != nil {
+		return "", false
+	}
+
+	bootID, reason, _ := strings.Cut(string(data), "\n")
+	if bootID != "" && bootID != currentBootID() {
+		// The system has rebooted since the flag was written
+		_ = ClearRebootRequired()
+		return "", false
+	}
+	return strings.TrimSpace(reason), true
+}
+
+// hasPendingDeployment reports whether rpm-ostree has a staged deployment
+// that will only take effect after a reboot
+func hasPendingDeployment() bool {
+	status, err := GetRpmOstreeStatus()
+	if err != nil {
+		return false
+	}
+	return stagedDeployment(status)
+}
+
+// stagedDeployment reports whether "rpm-ostree status --json" output lists a
+// staged deployment. rpm-ostree stages every upgrade, rebase and package
+// change, so a default deployment that merely differs from the booted one is
+// not counted: that is a rollback, either queued with "rpm-ostree rollback"
+// or chosen from the boot menu, and rebooting would undo the choice.
+func stagedDeployment(status string) bool {
+	var parsed struct {
+		Deployments []struct {
+			Staged bool `json:"staged"`
+		} `json:"deployments"`
+	}
+	if err := json.Unmarshal([]byte(status), &parsed); err != nil {
+		return false
+	}
+
+	for _, deployment := range parsed.Deployments {
+		if deployment.Staged {
+			return true
+		}
+	}
+	return false
+}
+
+// RebootRequired reports whether a reboot is pending, either because
+// rpm-ostree has a new deployment or an operation recorded the need
+func RebootRequired() bool {
+	return len(RebootReasons()) > 0
+}
+
+// RebootReasons returns a description of each reason a reboot is pending
+func RebootReasons() []string {
+	var reasons []string
+	if hasPendingDeployment() {
+		reasons = append(reasons, "rpm-ostree has a pending deployment")
+	}
+	if reason, ok := trackedRebootReason(); ok {
+		if reason == "" {
+			reason = "a setup operation requires a reboot"
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
+
+// Reboot reboots the system via systemctl
+func Reboot() error {
+	if output, err := RunPrivileged("systemctl", "reboot"); err != nil {
+		return fmt.Errorf("failed to reboot: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
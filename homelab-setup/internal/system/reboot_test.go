@@ -0,0 +1,23 @@
+package system
+
+import "testing"
+
+func TestStagedDeployment(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   bool
+	}{
+		{"booted only", `{"deployments":[{"booted":true},{"booted":false}]}`, false},
+		{"staged upgrade", `{"deployments":[{"booted":false,"staged":true},{"booted":true}]}`, true},
+		{"rollback", `{"deployments":[{"booted":false,"staged":false},{"booted":true}]}`, false},
+		{"no deployments", `{"deployments":[]}`, false},
+		{"invalid", `not json`, false},
+	}
+
+	for _, tt := range tests {
+		if got := stagedDeployment(tt.status); got != tt.want {
+			t.Errorf("%s: stagedDeployment() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
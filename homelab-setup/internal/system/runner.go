@@ -0,0 +1,53 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandRunner runs external commands. ExecRunner is the real
+// implementation; tests substitute FakeRunner so they do not need the
+// binaries installed.
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args ...string) (stdout, stderr []byte, err error)
+}
+
+// ExecRunner runs commands with os/exec
+type ExecRunner struct{}
+
+// Run executes name with args, returning its stdout and stderr separately.
+// The command is killed if ctx is cancelled.
+func (ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	traceCommand(name, args, start, err)
+	auditCommand(cmd.Args, start, err)
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// defaultRunner is used by managers constructed without an explicit runner
+var defaultRunner CommandRunner = ExecRunner{}
+
+// exitCode returns the exit status carried by err (from os/exec or
+// the tests' FakeExitError), or -1 if err does not describe a process exit
+func exitCode(err error) int {
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return -1
+}
+
+// commandLine joins a command and its arguments for display and lookups
+func commandLine(name string, args []string) string {
+	return strings.TrimSpace(name + " " + strings.Join(args, " "))
+}
@@ -0,0 +1,78 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeResponse is the canned result FakeRunner returns for a command
+type FakeResponse struct {
+	Stdout []byte
+	Stderr []byte
+	Err    error
+}
+
+// FakeExitError simulates a command exiting with a non-zero status
+type FakeExitError struct {
+	Code int
+}
+
+// Error implements error
+func (e *FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode mirrors exec.ExitError.ExitCode
+func (e *FakeExitError) ExitCode() int {
+	return e.Code
+}
+
+// FakeRunner is a CommandRunner for tests. It records every command line and
+// answers from Responses, keyed by the full command line (e.g. "sudo -n -v").
+// Unknown commands get Default.
+type FakeRunner struct {
+	Responses map[string]FakeResponse
+	Default   FakeResponse
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// NewFakeRunner creates a FakeRunner with no canned responses
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{Responses: make(map[string]FakeResponse)}
+}
+
+// On registers the response for a command line and returns the runner for chaining
+func (f *FakeRunner) On(commandLine string, resp FakeResponse) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Responses[commandLine] = resp
+	return f
+}
+
+// Run records the call and returns the canned response
+func (f *FakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	line := commandLine(name, args)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, line)
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	resp, ok := f.Responses[line]
+	if !ok {
+		resp = f.Default
+	}
+	return resp.Stdout, resp.Stderr, resp.Err
+}
+
+// Calls returns the command lines run so far, in order
+func (f *FakeRunner) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
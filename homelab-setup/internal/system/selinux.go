@@ -0,0 +1,62 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ContainerFileContext is the SELinux type containers need to access host files
+const ContainerFileContext = "container_file_t"
+
+// GetSELinuxMode returns the SELinux mode reported by getenforce
+// ("Enforcing", "Permissive" or "Disabled")
+func GetSELinuxMode() (string, error) {
+	if !CommandExists("getenforce") {
+		return "", fmt.Errorf("getenforce not found (SELinux tooling not installed)")
+	}
+	output, err := exec.Command("getenforce").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get SELinux mode: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetSELinuxType returns the SELinux type of path (e.g. "container_file_t")
+func GetSELinuxType(path string) (string, error) {
+	output, err := exec.Command("stat", "-c", "%C", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read SELinux context of %s: %w", path, err)
+	}
+
+	// Context format: user:role:type:level
+	parts := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(parts) < 3 {
+		return "", fmt.Errorf("unexpected SELinux context for %s: %s", path, strings.TrimSpace(string(output)))
+	}
+	return parts[2], nil
+}
+
+// ApplyContainerFileContext labels path recursively with container_file_t.
+// A persistent rule is added with semanage and applied with restorecon; when
+// semanage is unavailable the label is applied with chcon, which does not
+// survive a filesystem relabel.
+func ApplyContainerFileContext(path string) error {
+	if CommandExists("semanage") {
+		spec := fmt.Sprintf("%s(/.*)?", path)
+		output, err := RunPrivileged("semanage", "fcontext", "-a", "-t", ContainerFileContext, spec)
+		if err != nil && !strings.Contains(string(output), "already defined") {
+			return fmt.Errorf("failed to add SELinux rule for %s: %w\nOutput: %s", path, err, string(output))
+		}
+
+		if output, err := RunPrivileged("restorecon", "-R", path); err != nil {
+			return fmt.Errorf("failed to restore SELinux context on %s: %w\nOutput: %s", path, err, string(output))
+		}
+		return nil
+	}
+
+	if output, err := RunPrivileged("chcon", "-R", "-t", ContainerFileContext, path); err != nil {
+		return fmt.Errorf("failed to set SELinux context on %s: %w\nOutput: %s", path, err, string(output))
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 package system
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ServiceExists checks if a systemd service unit file exists
@@ -65,6 +68,22 @@ func IsServiceActive(serviceName string) (bool, error) {
 	return false, fmt.Errorf("failed to check service status: %w", err)
 }
 
+// ServiceActiveState returns the unit's ActiveState as printed by
+// systemctl is-active: "active", "inactive", "activating", "deactivating",
+// "failed" or "reloading"
+func ServiceActiveState(serviceName string) (string, error) {
+	output, err := exec.Command("systemctl", "is-active", serviceName).Output()
+	state := strings.TrimSpace(string(output))
+	if state != "" {
+		// is-active exits non-zero for every state but active; the state is still printed
+		return state, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get state of %s: %w", serviceName, err)
+	}
+	return "", fmt.Errorf("systemctl printed no state for %s", serviceName)
+}
+
 // IsServiceEnabled checks if a service is enabled to start on boot
 func IsServiceEnabled(serviceName string) (bool, error) {
 	cmd := exec.Command("systemctl", "is-enabled", "--quiet", serviceName)
@@ -86,70 +105,63 @@ func IsServiceEnabled(serviceName string) (bool, error) {
 
 // EnableService enables a service to start on boot
 func EnableService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "enable", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "sudo", "-n", "systemctl", "enable", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to enable service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to enable service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // DisableService disables a service from starting on boot
 func DisableService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "disable", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "sudo", "-n", "systemctl", "disable", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to disable service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to disable service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // StartService starts a service
 func StartService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "start", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), ServiceStartTimeout(), "sudo", "-n", "systemctl", "start", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to start service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to start service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // StopService stops a service
 func StopService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "stop", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "sudo", "-n", "systemctl", "stop", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to stop service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to stop service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // RestartService restarts a service
 func RestartService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "restart", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), ServiceStartTimeout(), "sudo", "-n", "systemctl", "restart", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to restart service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to restart service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // ReloadService reloads a service configuration
 func ReloadService(serviceName string) error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "reload", serviceName)
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "sudo", "-n", "systemctl", "reload", serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to reload service %s: %w\nOutput: %s", serviceName, err, string(output))
+		return fmt.Errorf("failed to reload service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 // SystemdDaemonReload reloads systemd manager configuration
 func SystemdDaemonReload() error {
-	cmd := exec.Command("sudo", "-n", "systemctl", "daemon-reload")
-	output, err := cmd.CombinedOutput()
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "sudo", "-n", "systemctl", "daemon-reload")
 	if err != nil {
-		return fmt.Errorf("failed to reload systemd daemon: %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to reload systemd daemon: %w", err)
 	}
 	return nil
 }
@@ -167,7 +179,9 @@ func GetServiceStatus(serviceName string) (string, error) {
 // GetServiceJournalLogs returns recent journal logs for a service
 func GetServiceJournalLogs(serviceName string, lines int) (string, error) {
 	cmd := exec.Command("sudo", "-n", "journalctl", "-u", serviceName, "-n", fmt.Sprintf("%d", lines), "--no-pager")
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	auditCommand(cmd.Args, start, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to get logs for %s: %w", serviceName, err)
 	}
@@ -199,14 +213,61 @@ func ListSystemdUnits(pattern string) ([]string, error) {
 	return units, nil
 }
 
+// ValidateCalendarSpec checks a systemd OnCalendar= expression with
+// systemd-analyze
+func ValidateCalendarSpec(spec string) error {
+	_, err := runCombinedWithTimeout(context.Background(), CommandTimeout(), "systemd-analyze", "calendar", spec)
+	if err != nil {
+		return fmt.Errorf("invalid calendar spec %q: %w", spec, err)
+	}
+	return nil
+}
+
 // RunSystemCommand runs a shell command with the given arguments
 func RunSystemCommand(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	return RunSystemCommandContext(context.Background(), command, args...)
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run command %s: %w", command, err)
+// RunSystemCommandContext runs a command with the given arguments, killing it
+// if ctx is cancelled or the service start timeout elapses (used for compose
+// commands such as pull, which can legitimately take several minutes). Its
+// output is captured as described for RunCommandContext. In dry-run mode the
+// command is only logged.
+func RunSystemCommandContext(ctx context.Context, command string, args ...string) error {
+	if _, err := RunCommandContext(ctx, command, args...); err != nil {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			return fmt.Errorf("failed to run command %s: %w", command, err)
+		}
+		return err
 	}
 	return nil
 }
+
+// ServiceManager is the set of systemd operations used by setup steps that
+// act on a service's state, so their decision logic can be tested against a
+// fake instead of the real systemd
+type ServiceManager interface {
+	Exists(serviceName string) (bool, error)
+	IsActive(serviceName string) (bool, error)
+	Start(serviceName string) error
+	Enable(serviceName string) error
+}
+
+// systemdServiceManager implements ServiceManager with the package-level
+// helpers
+type systemdServiceManager struct{}
+
+// NewServiceManager returns a ServiceManager backed by systemd
+func NewServiceManager() ServiceManager {
+	return systemdServiceManager{}
+}
+
+func (systemdServiceManager) Exists(serviceName string) (bool, error) {
+	return ServiceExists(serviceName)
+}
+func (systemdServiceManager) IsActive(serviceName string) (bool, error) {
+	return IsServiceActive(serviceName)
+}
+func (systemdServiceManager) Start(serviceName string) error  { return StartService(serviceName) }
+func (systemdServiceManager) Enable(serviceName string) error { return EnableService(serviceName) }
@@ -0,0 +1,195 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SSHTarget is a host reached over SSH. ~/.ssh/config is not read, so Host
+// must be a name or address the machine resolves itself.
+type SSHTarget struct {
+	User string
+	Host string
+	Port int // 0 uses the ssh default
+}
+
+// ParseSSHTarget parses [user@]host[:port]. IPv6 addresses with a port are
+// written in brackets ([fd00::1]:2222).
+func ParseSSHTarget(value string) (SSHTarget, error) {
+	var target SSHTarget
+	rest := strings.TrimSpace(value)
+	if user, host, ok := strings.Cut(rest, "@"); ok {
+		target.User, rest = user, host
+	}
+
+	if host, port, err := net.SplitHostPort(rest); err == nil {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return SSHTarget{}, fmt.Errorf("invalid SSH port %q in %q", port, value)
+		}
+		target.Host, target.Port = host, n
+	} else {
+		target.Host = strings.Trim(rest, "[]")
+	}
+
+	if target.Host == "" || strings.ContainsAny(target.Host, " /@") || strings.HasPrefix(target.Host, "-") {
+		return SSHTarget{}, fmt.Errorf("invalid SSH target %q: expected [user@]host[:port]", value)
+	}
+	if strings.ContainsAny(target.User, " :") || strings.HasPrefix(target.User, "-") {
+		return SSHTarget{}, fmt.Errorf("invalid SSH user %q", target.User)
+	}
+	return target, nil
+}
+
+// String formats the target as [user@]host[:port]
+func (t SSHTarget) String() string {
+	host := t.Host
+	if t.Port != 0 {
+		host = net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	}
+	if t.User != "" {
+		return t.User + "@" + host
+	}
+	return host
+}
+
+// safeShellWord matches arguments that need no quoting in a POSIX shell
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes arg for a POSIX shell
+func shellQuote(arg string) string {
+	if safeShellWord.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// RemoteHost runs commands and network probes on another machine over SSH,
+// so checks can be made from that machine's point of view. It implements
+// CommandRunner.
+type RemoteHost struct {
+	Target SSHTarget
+	runner CommandRunner // runs commands on the target
+}
+
+// NewRemoteHost returns a RemoteHost that connects to target with
+// golang.org/x/crypto/ssh on first use. Keys come from ssh-agent or the
+// unencrypted keys in ~/.ssh, and the host key must already be in
+// known_hosts. Call Close when done.
+func NewRemoteHost(target SSHTarget) *RemoteHost {
+	return NewRemoteHostWithRunner(target, &sshRunner{target: target})
+}
+
+// NewRemoteHostWithRunner returns a RemoteHost whose commands are run by
+// runner, which stands in for the remote host
+func NewRemoteHostWithRunner(target SSHTarget, runner CommandRunner) *RemoteHost {
+	return &RemoteHost{Target: target, runner: runner}
+}
+
+// Run runs name with args on the remote host. A failure to connect is
+// reported as an error naming the target rather than as an exit status.
+func (h *RemoteHost) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	return h.runner.Run(ctx, name, args...)
+}
+
+// Close closes the SSH connection, if one was opened
+func (h *RemoteHost) Close() error {
+	if closer, ok := h.runner.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Hostname returns the remote host's name, which also confirms that SSH works
+func (h *RemoteHost) Hostname(ctx context.Context) (string, error) {
+	stdout, _, err := h.Run(ctx, "hostname")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Addresses returns the remote host's IP addresses, from hostname -I
+func (h *RemoteHost) Addresses(ctx context.Context) ([]net.IP, error) {
+	stdout, _, err := h.Run(ctx, "hostname", "-I")
+	if err != nil {
+		return nil, err
+	}
+	var addrs []net.IP
+	for _, field := range strings.Fields(string(stdout)) {
+		if ip := net.ParseIP(field); ip != nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	return addrs, nil
+}
+
+// remoteProbeResult interprets the outcome of a remote probe command: exit 0
+// is a success, another exit status a negative answer, anything else (such
+// as an SSH failure) an error
+func remoteProbeResult(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if code := exitCode(err); code > 0 {
+		return false, nil
+	}
+	return false, err
+}
+
+// Ping pings host once from the remote host
+func (h *RemoteHost) Ping(ctx context.Context, host string, timeoutSeconds int) (bool, error) {
+	_, _, err := h.Run(ctx, "ping", "-c", "1", "-W", strconv.Itoa(timeoutSeconds), host)
+	return remoteProbeResult(err)
+}
+
+// tcpHostPattern matches host names and IPv4/IPv6 addresses
+var tcpHostPattern = regexp.MustCompile(`^[A-Za-z0-9.:-]+$`)
+
+// TCPPortOpen reports whether host accepts TCP connections on port from the
+// remote host, using bash's /dev/tcp so nothing needs to be installed there
+func (h *RemoteHost) TCPPortOpen(ctx context.Context, host string, port, timeoutSeconds int) (bool, error) {
+	// host ends up inside a bash script, so only plain names and addresses
+	if !tcpHostPattern.MatchString(host) {
+		return false, fmt.Errorf("invalid host %q", host)
+	}
+	script := fmt.Sprintf("exec 3<>/dev/tcp/%s/%d", host, port)
+	_, stderr, err := h.Run(ctx, "timeout", strconv.Itoa(timeoutSeconds), "bash", "-c", script)
+	// 126/127: timeout or bash is missing, which says nothing about the port
+	if code := exitCode(err); code == 126 || code == 127 {
+		return false, fmt.Errorf("cannot test TCP ports on %s: %s", h.Target, strings.TrimSpace(string(stderr)))
+	}
+	return remoteProbeResult(err)
+}
+
+// NFSExports returns the output of showmount -e server run on the remote host
+func (h *RemoteHost) NFSExports(ctx context.Context, server string) (string, error) {
+	stdout, stderr, err := h.Run(ctx, "showmount", "-e", server)
+	if err != nil {
+		if detail := strings.TrimSpace(string(stderr)); detail != "" {
+			return "", fmt.Errorf("showmount -e %s: %s", server, detail)
+		}
+		return "", fmt.Errorf("showmount -e %s: %w", server, err)
+	}
+	return string(stdout), nil
+}
+
+// PortListener reports whether a process listens on the remote host's TCP
+// port and, when ss can see it, which one (e.g. "nginx (pid 1234)")
+func (h *RemoteHost) PortListener(ctx context.Context, port int) (bool, string, error) {
+	stdout, _, err := h.Run(ctx, "ss", "-Hltnp", fmt.Sprintf("sport = :%d", port))
+	if err != nil {
+		return false, "", err
+	}
+	if strings.TrimSpace(string(stdout)) == "" {
+		return false, "", nil
+	}
+	if match := ssProcessPattern.FindSubmatch(stdout); match != nil {
+		return true, fmt.Sprintf("%s (pid %s)", match[1], match[2]), nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,279 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshConnectTimeout bounds the TCP connection and SSH handshake
+const sshConnectTimeout = 10 * time.Second
+
+// sshIdentityFiles are the private keys tried, in order, from ~/.ssh.
+// Passphrase-protected keys are skipped; load them into ssh-agent instead.
+var sshIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshKnownHostsFiles returns the known_hosts files host keys are checked
+// against
+func sshKnownHostsFiles() []string {
+	return []string{filepath.Join(homeDir(), ".ssh", "known_hosts"), "/etc/ssh/ssh_known_hosts"}
+}
+
+// homeDir returns the user's home directory, or "" if it is unknown
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// sshExitError is a remote command's non-zero exit status. It implements
+// ExitCode like exec.ExitError so exitCode can read it.
+type sshExitError struct {
+	*ssh.ExitError
+}
+
+// ExitCode returns the remote exit status
+func (e sshExitError) ExitCode() int {
+	return e.ExitStatus()
+}
+
+// sshRunner is a CommandRunner that runs commands on an SSH target with
+// golang.org/x/crypto/ssh. It connects on first use and reuses the
+// connection until Close.
+type sshRunner struct {
+	target SSHTarget
+
+	mu     sync.Mutex
+	client *ssh.Client
+	agent  net.Conn // ssh-agent connection, when one is used
+}
+
+// address returns the target as host:port, defaulting to port 22
+func (r *sshRunner) address() string {
+	port := r.target.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(r.target.Host, strconv.Itoa(port))
+}
+
+// connect returns the SSH client, dialing the target the first time
+func (r *sshRunner) connect(ctx context.Context) (*ssh.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil {
+		return r.client, nil
+	}
+
+	username := r.target.User
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine the SSH user: %w", err)
+		}
+		username = current.Username
+	}
+	auth, err := r.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownHostsCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := r.address()
+	dialer := net.Dialer{Timeout: sshConnectTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:              username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: knownHostAlgorithms(hostKeys, addr, conn.RemoteAddr()),
+		Timeout:           sshConnectTimeout,
+	}
+	_ = conn.SetDeadline(time.Now().Add(sshConnectTimeout))
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	r.client = ssh.NewClient(clientConn, channels, requests)
+	return r.client, nil
+}
+
+// authMethods offers the keys held by ssh-agent, then the unencrypted keys
+// in ~/.ssh. The caller must hold r.mu.
+func (r *sshRunner) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" && r.agent == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			r.agent = conn
+		}
+	}
+	if r.agent != nil {
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(r.agent).Signers))
+	}
+
+	var signers []ssh.Signer
+	for _, name := range sshIdentityFiles {
+		content, err := os.ReadFile(filepath.Join(homeDir(), ".ssh", name))
+		if err != nil {
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(content)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH key available: start ssh-agent with your key loaded, or create an unencrypted key in ~/.ssh (%s)", strings.Join(sshIdentityFiles, ", "))
+	}
+	return methods, nil
+}
+
+// knownHostsCallback checks host keys against the known_hosts files that
+// exist. An unknown host is an error rather than a prompt.
+func knownHostsCallback() (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, file := range sshKnownHostsFiles() {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known_hosts file; connect once with ssh to accept the host key")
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("host key of %s is not in known_hosts; connect once with ssh to accept it", hostname)
+			}
+			return fmt.Errorf("host key of %s does not match known_hosts (%s); the host may have been reinstalled, or the connection intercepted", hostname, keyErr.Want[0].String())
+		}
+		return err
+	}, nil
+}
+
+// knownHostAlgorithms returns the host key algorithms known_hosts holds for
+// addr, so the server is asked for a key that can be checked. It returns nil,
+// the library default, when the host is not listed.
+func knownHostAlgorithms(callback ssh.HostKeyCallback, addr string, remote net.Addr) []string {
+	// A throwaway key never matches, so the error lists the known keys
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(addr, remote, probe), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch keyType := known.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, keyType)
+		}
+	}
+	return algorithms
+}
+
+// Run runs name with args on the target through the shell of the remote
+// user. A non-zero exit status is returned as an error carrying ExitCode; a
+// failure to connect names the target.
+func (r *sshRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	client, err := r.connect(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh to %s failed: %w", r.target, err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh to %s failed: %w", r.target, err)
+	}
+	defer session.Close()
+
+	words := make([]string, 0, len(args)+1)
+	for _, word := range append([]string{name}, args...) {
+		words = append(words, shellQuote(word))
+	}
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- session.Run(strings.Join(words, " ")) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Closing the session ends Run; wait for it so the buffers are no
+		// longer being written
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		err = ctx.Err()
+	}
+	traceCommand(name, args, start, err)
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		err = sshExitError{exitErr}
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// Close closes the SSH connection and the ssh-agent connection, if open
+func (r *sshRunner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if r.client != nil {
+		err = r.client.Close()
+		r.client = nil
+	}
+	if r.agent != nil {
+		r.agent.Close()
+		r.agent = nil
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+package system
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is an in-process SSH server that answers exec requests from
+// a table of command lines
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+
+	mu       sync.Mutex
+	commands []string
+}
+
+// sshReply is the stdout and exit status the test server gives a command.
+// With hang, the server writes stdout and then waits for the client to close
+// the session.
+type sshReply struct {
+	stdout string
+	status uint32
+	hang   bool
+}
+
+// startTestSSHServer serves until the test ends, accepting only clientKey.
+// replies maps a command line to its stdout and exit status.
+func startTestSSHServer(t *testing.T, clientKey ssh.PublicKey, replies map[string]sshReply) *testSSHServer {
+	t.Helper()
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, os.ErrPermission
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &testSSHServer{addr: listener.Addr().String(), hostKey: hostSigner.PublicKey()}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config, replies)
+		}
+	}()
+	return server
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig, replies map[string]sshReply) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range channelRequests {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				command := string(req.Payload[4:])
+				s.mu.Lock()
+				s.commands = append(s.commands, command)
+				s.mu.Unlock()
+				_ = req.Reply(true, nil)
+
+				reply, ok := replies[command]
+				if !ok {
+					reply.status = 127
+				}
+				_, _ = channel.Write([]byte(reply.stdout))
+				if reply.hang {
+					// Ignore the kill signal; the loop ends when the
+					// client closes the channel
+					continue
+				}
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, reply.status)
+				_, _ = channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+// useSSHHome gives the test a HOME holding an unencrypted client key and no
+// ssh-agent. It returns the client's public key.
+func useSSHHome(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientKey
+}
+
+// trustHost adds the server's host key to ~/.ssh/known_hosts
+func trustHost(t *testing.T, server *testSSHServer) {
+	t.Helper()
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey) + "\n"
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sshTargetOf returns the target for a server listening on addr
+func sshTargetOf(t *testing.T, addr string) SSHTarget {
+	t.Helper()
+	target, err := ParseSSHTarget("core@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestRemoteHostOverSSH(t *testing.T) {
+	clientKey := useSSHHome(t)
+	server := startTestSSHServer(t, clientKey, map[string]sshReply{
+		"hostname":                   {stdout: "mini1\n"},
+		"ping -c 1 -W 3 10.0.0.9":    {status: 1},
+		"ss -Hltnp 'sport = :32400'": {},
+	})
+	trustHost(t, server)
+
+	host := NewRemoteHost(sshTargetOf(t, server.addr))
+	defer host.Close()
+	ctx := context.Background()
+
+	if name, err := host.Hostname(ctx); err != nil || name != "mini1" {
+		t.Fatalf("Hostname() = %q, %v", name, err)
+	}
+	if ok, err := host.Ping(ctx, "10.0.0.9", 3); ok || err != nil {
+		t.Errorf("Ping(unreachable) = %v, %v; want false from the exit status", ok, err)
+	}
+	if inUse, _, err := host.PortListener(ctx, 32400); inUse || err != nil {
+		t.Errorf("PortListener(32400) = %v, %v", inUse, err)
+	}
+
+	// Arguments are quoted for the remote shell
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if got := server.commands[len(server.commands)-1]; got != "ss -Hltnp 'sport = :32400'" {
+		t.Errorf("remote command = %q, want the quoted ss invocation", got)
+	}
+}
+
+func TestRemoteHostRejectsUnknownHostKey(t *testing.T) {
+	clientKey := useSSHHome(t)
+	server := startTestSSHServer(t, clientKey, nil)
+	// known_hosts exists but does not list the server
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	host := NewRemoteHost(sshTargetOf(t, server.addr))
+	defer host.Close()
+	_, err := host.Hostname(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("Hostname() error = %v, want an unknown host key error", err)
+	}
+}
+
+func TestRemoteHostRunCancelled(t *testing.T) {
+	clientKey := useSSHHome(t)
+	server := startTestSSHServer(t, clientKey, map[string]sshReply{
+		"journalctl -f": {stdout: "waiting\n", hang: true},
+	})
+	trustHost(t, server)
+
+	runner := &sshRunner{target: sshTargetOf(t, server.addr)}
+	defer runner.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	stdout, _, err := runner.Run(ctx, "journalctl", "-f")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if string(stdout) != "waiting\n" {
+		t.Errorf("Run() stdout = %q, want the output written before the cancel", stdout)
+	}
+}
@@ -0,0 +1,87 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    SSHTarget
+		wantErr bool
+	}{
+		{"mini1", SSHTarget{Host: "mini1"}, false},
+		{"core@192.168.1.20", SSHTarget{User: "core", Host: "192.168.1.20"}, false},
+		{"core@mini1:2222", SSHTarget{User: "core", Host: "mini1", Port: 2222}, false},
+		{"[fd00::1]:22", SSHTarget{Host: "fd00::1", Port: 22}, false},
+		{"fd00::1", SSHTarget{Host: "fd00::1"}, false},
+		{"", SSHTarget{}, true},
+		{"core@", SSHTarget{}, true},
+		{"-oProxyCommand=x", SSHTarget{}, true},
+		{"mini1:99999", SSHTarget{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSSHTarget(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSSHTarget(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSSHTarget(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteHostProbes(t *testing.T) {
+	runner := NewFakeRunner().
+		On("hostname", FakeResponse{Stdout: []byte("mini1\n")}).
+		On("hostname -I", FakeResponse{Stdout: []byte("192.168.1.20 fd00::20 \n")}).
+		On("ping -c 1 -W 3 8.8.8.8", FakeResponse{}).
+		On("ping -c 1 -W 3 10.0.0.9", FakeResponse{Err: &FakeExitError{Code: 1}}).
+		On("timeout 5 bash -c exec 3<>/dev/tcp/nas/2049", FakeResponse{Err: &FakeExitError{Code: 124}}).
+		On("ss -Hltnp sport = :80", FakeResponse{Stdout: []byte(`LISTEN 0 511 0.0.0.0:80 0.0.0.0:* users:(("nginx",pid=812,fd=6))`)}).
+		On("ss -Hltnp sport = :443", FakeResponse{})
+	host := NewRemoteHostWithRunner(SSHTarget{User: "core", Host: "mini1", Port: 2222}, runner)
+	ctx := context.Background()
+
+	if name, err := host.Hostname(ctx); err != nil || name != "mini1" {
+		t.Errorf("Hostname() = %q, %v", name, err)
+	}
+	if addrs, err := host.Addresses(ctx); err != nil || len(addrs) != 2 || !addrs[0].Equal(net.ParseIP("192.168.1.20")) {
+		t.Errorf("Addresses() = %v, %v", addrs, err)
+	}
+	if ok, err := host.Ping(ctx, "8.8.8.8", 3); !ok || err != nil {
+		t.Errorf("Ping(reachable) = %v, %v", ok, err)
+	}
+	if ok, err := host.Ping(ctx, "10.0.0.9", 3); ok || err != nil {
+		t.Errorf("Ping(unreachable) = %v, %v", ok, err)
+	}
+	if ok, err := host.TCPPortOpen(ctx, "nas", 2049, 5); ok || err != nil {
+		t.Errorf("TCPPortOpen(timeout) = %v, %v", ok, err)
+	}
+	if _, err := host.TCPPortOpen(ctx, "nas;reboot", 2049, 5); err == nil {
+		t.Error("TCPPortOpen() accepted a host with shell syntax")
+	}
+	if inUse, listener, err := host.PortListener(ctx, 80); !inUse || listener != "nginx (pid 812)" || err != nil {
+		t.Errorf("PortListener(80) = %v, %q, %v", inUse, listener, err)
+	}
+	if inUse, _, err := host.PortListener(ctx, 443); inUse || err != nil {
+		t.Errorf("PortListener(443) = %v, %v", inUse, err)
+	}
+}
+
+func TestRemoteHostConnectionFailure(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.Default = FakeResponse{Err: errors.New("ssh to mini1 failed: dial tcp: connect: no route to host")}
+	host := NewRemoteHostWithRunner(SSHTarget{Host: "mini1"}, runner)
+
+	// A connection failure is an error, not an unreachable ping target
+	ok, err := host.Ping(context.Background(), "8.8.8.8", 3)
+	if ok || err == nil || !strings.Contains(err.Error(), "no route to host") {
+		t.Errorf("Ping() over a failed connection = %v, %v", ok, err)
+	}
+}
@@ -1,27 +1,81 @@
 package system
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"strings"
 )
 
+// ErrSudoCredentialsExpired is returned when sudo needs a password again
+// (the cached credentials timed out) and it could not be supplied
+var ErrSudoCredentialsExpired = errors.New("sudo credentials expired")
+
 // SudoChecker handles sudo access validation
-type SudoChecker struct{}
+type SudoChecker struct {
+	runner         CommandRunner
+	nonInteractive bool // Never prompt for a password
+}
 
 // NewSudoChecker creates a new SudoChecker instance
 func NewSudoChecker() *SudoChecker {
-	return &SudoChecker{}
+	return NewSudoCheckerWithRunner(defaultRunner)
+}
+
+// NewSudoCheckerWithRunner creates a SudoChecker that runs commands through runner
+func NewSudoCheckerWithRunner(runner CommandRunner) *SudoChecker {
+	return &SudoChecker{runner: runner}
+}
+
+// SetNonInteractive stops Refresh from prompting for a password
+func (s *SudoChecker) SetNonInteractive(nonInteractive bool) {
+	s.nonInteractive = nonInteractive
+}
+
+// Refresh re-primes the sudo credential cache before a privileged phase of a
+// long run. When the cached credentials have expired it asks for the password
+// again (sudo -v), or in non-interactive mode fails fast with
+// ErrSudoCredentialsExpired.
+func (s *SudoChecker) Refresh() error {
+	if skipForDryRun("refresh sudo credentials") {
+		return nil
+	}
+
+	// Extends the cached credentials when they are still valid
+	requiresPwd, err := s.RequiresPassword()
+	if err != nil {
+		return fmt.Errorf("failed to refresh sudo credentials: %w", err)
+	}
+	if !requiresPwd {
+		return nil
+	}
+
+	if s.nonInteractive {
+		return fmt.Errorf("%w: sudo needs a password but cannot prompt in non-interactive mode; configure passwordless sudo (NOPASSWD) for this user", ErrSudoCredentialsExpired)
+	}
+	if _, _, err := s.runner.Run(context.Background(), "sudo", "-v"); err != nil {
+		return fmt.Errorf("%w: re-authentication failed: %v", ErrSudoCredentialsExpired, err)
+	}
+	return nil
+}
+
+// sudoError marks a failed sudo -n command as ErrSudoCredentialsExpired when
+// sudo refused to run it for lack of a password, and returns err otherwise
+func sudoError(output []byte, err error) error {
+	if err != nil && strings.Contains(string(output), "a password is required") {
+		return fmt.Errorf("%w (run 'sudo -v' or configure passwordless sudo): %v", ErrSudoCredentialsExpired, err)
+	}
+	return err
 }
 
 // RequiresPassword checks if sudo requires password authentication
 func (s *SudoChecker) RequiresPassword() (bool, error) {
 	// Use -n flag to prevent prompting, and -v to validate cached credentials
-	cmd := exec.Command("sudo", "-n", "-v")
-	err := cmd.Run()
+	_, _, err := s.runner.Run(context.Background(), "sudo", "-n", "-v")
 	if err != nil {
 		// If exit code is 1, sudo requires password
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return exitErr.ExitCode() == 1, nil
+		if code := exitCode(err); code >= 0 {
+			return code == 1, nil
 		}
 		return false, fmt.Errorf("failed to check sudo status: %w", err)
 	}
@@ -29,9 +83,13 @@ func (s *SudoChecker) RequiresPassword() (bool, error) {
 	return false, nil
 }
 
-// ValidateAccess checks if sudo access is available
+// ValidateAccess checks if sudo access is available (always succeeds in
+// dry-run mode)
 // Returns true if sudo works (with or without password)
 func (s *SudoChecker) ValidateAccess() error {
+	if skipForDryRun("validate sudo access") {
+		return nil
+	}
 	// Try passwordless first
 	requiresPwd, err := s.RequiresPassword()
 	if err != nil {
@@ -44,8 +102,7 @@ func (s *SudoChecker) ValidateAccess() error {
 	}
 
 	// Try with password prompt
-	cmd := exec.Command("sudo", "true")
-	if err := cmd.Run(); err != nil {
+	if _, _, err := s.runner.Run(context.Background(), "sudo", "true"); err != nil {
 		return fmt.Errorf("sudo authentication failed: %w", err)
 	}
 
@@ -70,8 +127,7 @@ func (s *SudoChecker) GetSudoConfig() (map[string]string, error) {
 	}
 
 	// Check if user is in sudo/wheel group
-	cmd := exec.Command("groups")
-	output, err := cmd.Output()
+	output, _, err := s.runner.Run(context.Background(), "groups")
 	if err == nil {
 		info["groups"] = string(output)
 	}
@@ -0,0 +1,110 @@
+package system
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSudoCheckerRequiresPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    FakeResponse
+		want    bool
+		wantErr bool
+	}{
+		{"passwordless", FakeResponse{}, false, false},
+		{"password required", FakeResponse{Err: &FakeExitError{Code: 1}}, true, false},
+		{"other exit status", FakeResponse{Err: &FakeExitError{Code: 2}}, false, false},
+		{"sudo missing", FakeResponse{Err: errors.New("executable file not found")}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewFakeRunner().On("sudo -n -v", tt.resp)
+			got, err := NewSudoCheckerWithRunner(runner).RequiresPassword()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequiresPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RequiresPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSudoCheckerValidateAccessFallsBackToPrompt(t *testing.T) {
+	runner := NewFakeRunner().
+		On("sudo -n -v", FakeResponse{Err: &FakeExitError{Code: 1}}).
+		On("sudo true", FakeResponse{Err: &FakeExitError{Code: 1}})
+
+	if err := NewSudoCheckerWithRunner(runner).ValidateAccess(); err == nil {
+		t.Fatal("ValidateAccess() expected error when authentication fails")
+	}
+	if got, want := runner.Calls(), []string{"sudo -n -v", "sudo true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commands run = %v, want %v", got, want)
+	}
+}
+
+func TestSudoCheckerGetSudoConfig(t *testing.T) {
+	runner := NewFakeRunner().On("groups", FakeResponse{Stdout: []byte("core wheel docker\n")})
+
+	info, err := NewSudoCheckerWithRunner(runner).GetSudoConfig()
+	if err != nil {
+		t.Fatalf("GetSudoConfig() error = %v", err)
+	}
+	if info["requires_password"] != "no" || info["groups"] != "core wheel docker\n" {
+		t.Errorf("GetSudoConfig() = %v", info)
+	}
+}
+
+func TestSudoCheckerRefresh(t *testing.T) {
+	expired := FakeResponse{Err: &FakeExitError{Code: 1}}
+	tests := []struct {
+		name           string
+		nonInteractive bool
+		validate       FakeResponse // sudo -n -v
+		reauth         FakeResponse // sudo -v
+		wantExpired    bool
+		wantErr        bool
+		wantCalls      []string
+	}{
+		{"still cached", false, FakeResponse{}, FakeResponse{}, false, false, []string{"sudo -n -v"}},
+		{"expired and re-authenticated", false, expired, FakeResponse{}, false, false, []string{"sudo -n -v", "sudo -v"}},
+		{"expired and re-authentication fails", false, expired, expired, true, true, []string{"sudo -n -v", "sudo -v"}},
+		{"expired in non-interactive mode", true, expired, FakeResponse{}, true, true, []string{"sudo -n -v"}},
+		{"sudo missing", false, FakeResponse{Err: errors.New("executable file not found")}, FakeResponse{}, false, true, []string{"sudo -n -v"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewFakeRunner().On("sudo -n -v", tt.validate).On("sudo -v", tt.reauth)
+			checker := NewSudoCheckerWithRunner(runner)
+			checker.SetNonInteractive(tt.nonInteractive)
+
+			err := checker.Refresh()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Refresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrSudoCredentialsExpired); got != tt.wantExpired {
+				t.Errorf("Refresh() error = %v, is ErrSudoCredentialsExpired = %v, want %v", err, got, tt.wantExpired)
+			}
+			if got := runner.Calls(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Errorf("commands run = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSudoError(t *testing.T) {
+	exitErr := &FakeExitError{Code: 1}
+	if err := sudoError([]byte("sudo: a password is required\n"), exitErr); !errors.Is(err, ErrSudoCredentialsExpired) {
+		t.Errorf("sudoError() = %v, want ErrSudoCredentialsExpired", err)
+	}
+	if err := sudoError([]byte("mkdir: cannot create directory\n"), exitErr); err != exitErr {
+		t.Errorf("sudoError() = %v, want the command error unchanged", err)
+	}
+	if err := sudoError(nil, nil); err != nil {
+		t.Errorf("sudoError(nil) = %v, want nil", err)
+	}
+}
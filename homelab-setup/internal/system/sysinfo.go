@@ -0,0 +1,258 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sources read by CollectSystemInfo, overridable in tests
+var (
+	memInfoPath       = "/proc/meminfo"
+	kernelReleasePath = "/proc/sys/kernel/osrelease"
+	osReleasePath     = "/etc/os-release"
+)
+
+// MemInfo is the memory summary from /proc/meminfo
+type MemInfo struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+// RpmOstreeDeployment is one deployment from "rpm-ostree status --json"
+type RpmOstreeDeployment struct {
+	Booted         bool   `json:"booted"`
+	Origin         string `json:"origin,omitempty"`
+	ContainerImage string `json:"container-image-reference,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Checksum       string `json:"checksum,omitempty"`
+}
+
+// Source returns what the deployment was built from: the container image for
+// image-based systems, otherwise the ostree origin
+func (d RpmOstreeDeployment) Source() string {
+	if d.ContainerImage != "" {
+		return d.ContainerImage
+	}
+	return d.Origin
+}
+
+// SystemInfo is an environment summary for bug reports. SelectedServices,
+// CompletedSteps and Config come from the setup configuration and are filled
+// in by the caller.
+type SystemInfo struct {
+	Hostname       string                `json:"hostname"`
+	OS             string                `json:"os,omitempty"`
+	Kernel         string                `json:"kernel"`
+	Deployments    []RpmOstreeDeployment `json:"deployments,omitempty"`
+	Runtime        ContainerRuntime      `json:"runtime"`
+	RuntimeVersion string                `json:"runtime_version,omitempty"`
+	ComposeCommand string                `json:"compose_command,omitempty"`
+	ComposeVersion string                `json:"compose_version,omitempty"`
+	Memory         MemInfo               `json:"memory"`
+	Interfaces     []NetInterface        `json:"interfaces"`
+
+	SelectedServices []string          `json:"selected_services"`
+	CompletedSteps   []string          `json:"completed_steps"`
+	Config           map[string]string `json:"config,omitempty"` // secrets redacted
+
+	// Errors lists the details that could not be collected
+	Errors []string `json:"errors,omitempty"`
+}
+
+// parseMemInfo extracts MemTotal and MemAvailable from /proc/meminfo content
+func parseMemInfo(content string) (MemInfo, error) {
+	var info MemInfo
+	found := 0
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var target *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			target = &info.TotalBytes
+		case "MemAvailable:":
+			target = &info.AvailableBytes
+		default:
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return MemInfo{}, fmt.Errorf("invalid meminfo line %q: %w", scanner.Text(), err)
+		}
+		*target = kb * 1024
+		found++
+	}
+	if found < 2 {
+		return MemInfo{}, fmt.Errorf("MemTotal or MemAvailable missing from meminfo")
+	}
+	return info, nil
+}
+
+// ReadMemInfo returns the total and available memory
+func ReadMemInfo() (MemInfo, error) {
+	content, err := os.ReadFile(memInfoPath)
+	if err != nil {
+		return MemInfo{}, fmt.Errorf("failed to read %s: %w", memInfoPath, err)
+	}
+	return parseMemInfo(string(content))
+}
+
+// ParseRpmOstreeStatus extracts the deployments from "rpm-ostree status
+// --json" output
+func ParseRpmOstreeStatus(output string) ([]RpmOstreeDeployment, error) {
+	var status struct {
+		Deployments []RpmOstreeDeployment `json:"deployments"`
+	}
+	if err := json.Unmarshal([]byte(output), &status); err != nil {
+		return nil, fmt.Errorf("failed to parse rpm-ostree status: %w", err)
+	}
+	return status.Deployments, nil
+}
+
+// parseOSPrettyName returns PRETTY_NAME from os-release content
+func parseOSPrettyName(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// composeVersion returns the first line of "<compose> version"
+func composeVersion(compose string) (string, error) {
+	args := append(strings.Fields(compose), "version")
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get compose version: %w", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line, nil
+}
+
+// CollectSystemInfo gathers the host details useful in bug reports. Details
+// that cannot be read (e.g. rpm-ostree on a non-ostree host) are recorded in
+// Errors; an error is returned only when the kernel release cannot be read.
+func CollectSystemInfo() (*SystemInfo, error) {
+	release, err := os.ReadFile(kernelReleasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel release: %w", err)
+	}
+	info := &SystemInfo{Kernel: strings.TrimSpace(string(release))}
+	note := func(err error) {
+		info.Errors = append(info.Errors, err.Error())
+	}
+
+	if info.Hostname, err = GetHostname(); err != nil {
+		note(err)
+	}
+	if content, err := os.ReadFile(osReleasePath); err == nil {
+		info.OS = parseOSPrettyName(string(content))
+	}
+
+	if IsRpmOstreeSystem() {
+		if output, err := GetRpmOstreeStatus(); err != nil {
+			note(err)
+		} else if info.Deployments, err = ParseRpmOstreeStatus(output); err != nil {
+			note(err)
+		}
+	}
+
+	info.Runtime, err = DetectRuntime()
+	if err != nil {
+		note(err)
+	} else {
+		if info.RuntimeVersion, err = GetRuntimeVersion(info.Runtime); err != nil {
+			note(err)
+		}
+		if info.ComposeCommand, err = GetComposeCommand(info.Runtime); err != nil {
+			note(err)
+		} else if info.ComposeVersion, err = composeVersion(info.ComposeCommand); err != nil {
+			note(err)
+		}
+	}
+
+	if info.Memory, err = ReadMemInfo(); err != nil {
+		note(err)
+	}
+	if info.Interfaces, err = ListInterfaces(); err != nil {
+		note(err)
+	}
+
+	return info, nil
+}
+
+// JSON renders the info as indented JSON
+func (s *SystemInfo) JSON() (string, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode system info: %w", err)
+	}
+	return string(data), nil
+}
+
+// Text renders the info as a plain-text report suitable for pasting into an
+// issue
+func (s *SystemInfo) Text() string {
+	var b strings.Builder
+	line := func(label, value string) {
+		if value == "" {
+			value = "(unknown)"
+		}
+		fmt.Fprintf(&b, "%-18s %s\n", label+":", value)
+	}
+
+	line("Hostname", s.Hostname)
+	line("OS", s.OS)
+	line("Kernel", s.Kernel)
+	for _, d := range s.Deployments {
+		label := "Deployment"
+		if d.Booted {
+			label = "Booted deployment"
+		}
+		line(label, strings.TrimSpace(d.Source()+" "+d.Version))
+	}
+	line("Runtime", strings.TrimSpace(string(s.Runtime)+" "+s.RuntimeVersion))
+	line("Compose", strings.TrimSpace(s.ComposeCommand+" "+s.ComposeVersion))
+	line("Memory", fmt.Sprintf("%s total, %s available", FormatBytes(int64(s.Memory.TotalBytes)), FormatBytes(int64(s.Memory.AvailableBytes))))
+	for _, iface := range s.Interfaces {
+		line("Interface", iface.String())
+	}
+	list := func(values []string) string {
+		if len(values) == 0 {
+			return "(none)"
+		}
+		return strings.Join(values, ", ")
+	}
+	line("Selected services", list(s.SelectedServices))
+	line("Completed steps", list(s.CompletedSteps))
+
+	if len(s.Config) > 0 {
+		keys := make([]string, 0, len(s.Config))
+		for key := range s.Config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("\nConfiguration:\n")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  %s=%s\n", key, s.Config[key])
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		b.WriteString("\nNot collected:\n")
+		for _, e := range s.Errors {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	return b.String()
+}
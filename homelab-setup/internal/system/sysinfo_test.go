@@ -0,0 +1,86 @@
+package system
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemInfo(t *testing.T) {
+	content := "MemTotal:       16303428 kB\nMemFree:         1224764 kB\nMemAvailable:    9876543 kB\n"
+	got, err := parseMemInfo(content)
+	if err != nil {
+		t.Fatalf("parseMemInfo() error = %v", err)
+	}
+	want := MemInfo{TotalBytes: 16303428 * 1024, AvailableBytes: 9876543 * 1024}
+	if got != want {
+		t.Errorf("parseMemInfo() = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseMemInfo("MemFree: 1 kB\n"); err == nil {
+		t.Error("expected an error when MemTotal is missing")
+	}
+	if _, err := parseMemInfo("MemTotal: lots kB\nMemAvailable: 1 kB\n"); err == nil {
+		t.Error("expected an error for a non-numeric value")
+	}
+}
+
+func TestParseRpmOstreeStatus(t *testing.T) {
+	output := `{"deployments":[
+		{"booted":true,"container-image-reference":"ostree-image-signed:docker://ghcr.io/ublue-os/ucore:stable","version":"41.20241020.3.0","checksum":"abc"},
+		{"booted":false,"origin":"fedora:fedora/x86_64/coreos/stable","version":"40.20240920.3.0"}
+	],"transaction":null}`
+
+	deployments, err := ParseRpmOstreeStatus(output)
+	if err != nil {
+		t.Fatalf("ParseRpmOstreeStatus() error = %v", err)
+	}
+	if len(deployments) != 2 {
+		t.Fatalf("got %d deployments, want 2", len(deployments))
+	}
+	if !deployments[0].Booted || deployments[0].Source() != "ostree-image-signed:docker://ghcr.io/ublue-os/ucore:stable" {
+		t.Errorf("unexpected booted deployment: %+v", deployments[0])
+	}
+	if deployments[1].Source() != "fedora:fedora/x86_64/coreos/stable" {
+		t.Errorf("Source() = %q, want the ostree origin", deployments[1].Source())
+	}
+
+	if _, err := ParseRpmOstreeStatus("not json"); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestParseOSPrettyName(t *testing.T) {
+	content := "NAME=\"Fedora Linux\"\nPRETTY_NAME=\"Fedora CoreOS 41.20241020.3.0\"\nID=fedora\n"
+	if got := parseOSPrettyName(content); got != "Fedora CoreOS 41.20241020.3.0" {
+		t.Errorf("parseOSPrettyName() = %q", got)
+	}
+	if got := parseOSPrettyName("ID=fedora\n"); got != "" {
+		t.Errorf("parseOSPrettyName() = %q, want empty", got)
+	}
+}
+
+func TestSystemInfoText(t *testing.T) {
+	info := &SystemInfo{
+		Hostname:         "minipc",
+		Kernel:           "6.11.3-300.fc41.x86_64",
+		Runtime:          RuntimePodman,
+		RuntimeVersion:   "podman version 5.2.3",
+		SelectedServices: []string{"media", "web"},
+		Config:           map[string]string{"WIREGUARD_PRIVATE_KEY": "***REDACTED***", "TZ": "UTC"},
+		Errors:           []string{"not an rpm-ostree system"},
+	}
+
+	text := info.Text()
+	for _, want := range []string{
+		"Hostname:          minipc\n",
+		"Runtime:           podman podman version 5.2.3\n",
+		"Selected services: media, web\n",
+		"Completed steps:   (none)\n",
+		"  TZ=UTC\n  WIREGUARD_PRIVATE_KEY=***REDACTED***\n",
+		"Not collected:\n  not an rpm-ostree system\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// zoneinfoDir is the system time zone database, overridable in tests
+var zoneinfoDir = "/usr/share/zoneinfo"
+
+// localtimePath is the symlink naming the system time zone, overridable in tests
+var localtimePath = "/etc/localtime"
+
+// tzifMagic is the header every compiled zoneinfo file starts with
+var tzifMagic = []byte("TZif")
+
+// DetectTimezone returns the system time zone, preferring the /etc/localtime
+// symlink target and falling back to timedatectl
+func DetectTimezone() (string, error) {
+	if target, err := os.Readlink(localtimePath); err == nil {
+		if tz := timezoneFromLocaltime(target); tz != "" {
+			return tz, nil
+		}
+	}
+	return GetTimezone()
+}
+
+// timezoneFromLocaltime extracts the zone name from an /etc/localtime link
+// target such as ../usr/share/zoneinfo/Europe/Berlin
+func timezoneFromLocaltime(target string) string {
+	const marker = "zoneinfo/"
+	idx := strings.LastIndex(target, marker)
+	if idx < 0 {
+		return ""
+	}
+	tz := strings.TrimPrefix(target[idx+len(marker):], "posix/")
+	tz = strings.TrimPrefix(tz, "right/")
+	return tz
+}
+
+// ListTimezones returns the Region/City zones in the zoneinfo database,
+// sorted by name. Aliases without a region (e.g. "UTC") and the posix/ and
+// right/ variant trees are left out; Etc/UTC covers the former.
+func ListTimezones() ([]string, error) {
+	var zones []string
+	err := filepath.WalkDir(zoneinfoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(zoneinfoDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "posix" || rel == "right" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.Contains(rel, "/") || !isZoneinfoFile(path) {
+			return nil
+		}
+		zones = append(zones, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zoneinfo database %s: %w", zoneinfoDir, err)
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no time zones found in %s", zoneinfoDir)
+	}
+
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// TimezoneExists reports whether tz names a compiled zone in the zoneinfo database
+func TimezoneExists(tz string) bool {
+	if tz == "" || strings.HasPrefix(tz, "/") || strings.Contains(tz, "..") {
+		return false
+	}
+	return isZoneinfoFile(filepath.Join(zoneinfoDir, filepath.FromSlash(tz)))
+}
+
+// isZoneinfoFile reports whether path is a compiled zoneinfo (TZif) file,
+// which excludes zone.tab, tzdata.zi and similar metadata files
+func isZoneinfoFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(tzifMagic))
+	if _, err := f.Read(header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, tzifMagic)
+}
+
+// GroupTimezonesByRegion groups Region/City zones by their region. Cities
+// keep any further path components (e.g. "Argentina/Buenos_Aires").
+func GroupTimezonesByRegion(zones []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, zone := range zones {
+		region, city, ok := strings.Cut(zone, "/")
+		if !ok {
+			continue
+		}
+		groups[region] = append(groups[region], city)
+	}
+	return groups
+}
@@ -0,0 +1,99 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withZoneinfo builds a fake zoneinfo database and points zoneinfoDir at it
+func withZoneinfo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"Europe/Berlin":                  "TZif2...",
+		"America/Chicago":                "TZif2...",
+		"America/Argentina/Buenos_Aires": "TZif2...",
+		"Etc/UTC":                        "TZif2...",
+		"UTC":                            "TZif2...",
+		"posix/Europe/Berlin":            "TZif2...",
+		"zone.tab":                       "# tz zone descriptions",
+		"Europe/notes.txt":               "not a zone",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orig := zoneinfoDir
+	zoneinfoDir = dir
+	t.Cleanup(func() { zoneinfoDir = orig })
+	return dir
+}
+
+func TestListTimezones(t *testing.T) {
+	withZoneinfo(t)
+
+	zones, err := ListTimezones()
+	if err != nil {
+		t.Fatalf("ListTimezones() error = %v", err)
+	}
+	want := []string{"America/Argentina/Buenos_Aires", "America/Chicago", "Etc/UTC", "Europe/Berlin"}
+	if !reflect.DeepEqual(zones, want) {
+		t.Errorf("ListTimezones() = %v, want %v", zones, want)
+	}
+
+	groups := GroupTimezonesByRegion(zones)
+	if got := groups["America"]; !reflect.DeepEqual(got, []string{"Argentina/Buenos_Aires", "Chicago"}) {
+		t.Errorf("GroupTimezonesByRegion()[America] = %v", got)
+	}
+}
+
+func TestListTimezonesUnreadable(t *testing.T) {
+	orig := zoneinfoDir
+	zoneinfoDir = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { zoneinfoDir = orig })
+
+	if _, err := ListTimezones(); err == nil {
+		t.Error("ListTimezones() expected error for missing database")
+	}
+}
+
+func TestTimezoneExists(t *testing.T) {
+	withZoneinfo(t)
+
+	tests := map[string]bool{
+		"Europe/Berlin":   true,
+		"UTC":             true,
+		"Europe/Atlantis": false,
+		"zone.tab":        false,
+		"../etc/passwd":   false,
+		"/Europe/Berlin":  false,
+		"":                false,
+	}
+	for tz, want := range tests {
+		if got := TimezoneExists(tz); got != want {
+			t.Errorf("TimezoneExists(%q) = %v, want %v", tz, got, want)
+		}
+	}
+}
+
+func TestTimezoneFromLocaltime(t *testing.T) {
+	tests := map[string]string{
+		"../usr/share/zoneinfo/Europe/Berlin":      "Europe/Berlin",
+		"/usr/share/zoneinfo/America/Indiana/Knox": "America/Indiana/Knox",
+		"/usr/share/zoneinfo/posix/Asia/Tokyo":     "Asia/Tokyo",
+		"/etc/some/other/file":                     "",
+	}
+	for target, want := range tests {
+		if got := timezoneFromLocaltime(target); got != want {
+			t.Errorf("timezoneFromLocaltime(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
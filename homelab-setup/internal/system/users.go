@@ -41,6 +41,34 @@ func GroupExists(groupName string) (bool, error) {
 	return false, fmt.Errorf("failed to lookup group %s: %w", groupName, err)
 }
 
+// UserIDExists checks if a user with the numeric UID exists
+func UserIDExists(uid string) (bool, error) {
+	_, err := user.LookupId(uid)
+	if err == nil {
+		return true, nil
+	}
+
+	if _, ok := err.(user.UnknownUserIdError); ok {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to lookup UID %s: %w", uid, err)
+}
+
+// GroupIDExists checks if a group with the numeric GID exists
+func GroupIDExists(gid string) (bool, error) {
+	_, err := user.LookupGroupId(gid)
+	if err == nil {
+		return true, nil
+	}
+
+	if _, ok := err.(user.UnknownGroupIdError); ok {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to lookup GID %s: %w", gid, err)
+}
+
 // GetUserInfo returns information about a user
 func GetUserInfo(username string) (*user.User, error) {
 	u, err := user.Lookup(username)
@@ -131,8 +159,7 @@ func CreateUser(username string, createHome bool) error {
 
 	args = append(args, username)
 
-	cmd := exec.Command("sudo", append([]string{"-n"}, args...)...)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged(args[0], args[1:]...)
 	if err != nil {
 		return fmt.Errorf("failed to create user %s: %w\nOutput: %s", username, err, string(output))
 	}
@@ -160,8 +187,45 @@ func CreateSystemUser(username string, createHome bool, shell string) error {
 
 	args = append(args, username)
 
-	cmd := exec.Command("sudo", append([]string{"-n"}, args...)...)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged(args[0], args[1:]...)
+	if err != nil {
+		return fmt.Errorf("failed to create system user %s: %w\nOutput: %s", username, err, string(output))
+	}
+
+	return nil
+}
+
+// CreateSystemUserWithIDs creates a system service account with a specific UID/GID.
+// A uid or gid of 0 lets useradd pick the ID. When a gid is requested and no group
+// with the username exists yet, a matching primary group is created first.
+func CreateSystemUserWithIDs(username string, uid, gid int, shell string) error {
+	if shell == "" {
+		shell = "/sbin/nologin"
+	}
+
+	args := []string{"useradd", "--system", "-s", shell}
+
+	if uid > 0 {
+		args = append(args, "-u", strconv.Itoa(uid))
+	}
+
+	if gid > 0 {
+		exists, err := GroupExists(username)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			output, err := RunPrivileged("groupadd", "--system", "-g", strconv.Itoa(gid), username)
+			if err != nil {
+				return fmt.Errorf("failed to create group %s: %w\nOutput: %s", username, err, string(output))
+			}
+		}
+		args = append(args, "-g", strconv.Itoa(gid))
+	}
+
+	args = append(args, username)
+
+	output, err := RunPrivileged(args[0], args[1:]...)
 	if err != nil {
 		return fmt.Errorf("failed to create system user %s: %w\nOutput: %s", username, err, string(output))
 	}
@@ -179,8 +243,7 @@ func DeleteUser(username string, removeHome bool) error {
 
 	args = append(args, username)
 
-	cmd := exec.Command("sudo", append([]string{"-n"}, args...)...)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged(args[0], args[1:]...)
 	if err != nil {
 		return fmt.Errorf("failed to delete user %s: %w\nOutput: %s", username, err, string(output))
 	}
@@ -190,8 +253,7 @@ func DeleteUser(username string, removeHome bool) error {
 
 // AddUserToGroup adds a user to a group
 func AddUserToGroup(username, groupName string) error {
-	cmd := exec.Command("sudo", "-n", "usermod", "-aG", groupName, username)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged("usermod", "-aG", groupName, username)
 	if err != nil {
 		return fmt.Errorf("failed to add user %s to group %s: %w\nOutput: %s", username, groupName, err, string(output))
 	}
@@ -201,8 +263,7 @@ func AddUserToGroup(username, groupName string) error {
 
 // SetUserShell sets the login shell for a user
 func SetUserShell(username, shell string) error {
-	cmd := exec.Command("sudo", "-n", "usermod", "-s", shell, username)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged("usermod", "-s", shell, username)
 	if err != nil {
 		return fmt.Errorf("failed to set shell for user %s: %w\nOutput: %s", username, err, string(output))
 	}
@@ -227,8 +288,7 @@ func IsLingerEnabled(username string) (bool, error) {
 // /run/user/<uid> on boot even before the first login, enabling rootless
 // runtimes to access their state sockets.
 func EnableLinger(username string) error {
-	cmd := exec.Command("sudo", "-n", "loginctl", "enable-linger", username)
-	output, err := cmd.CombinedOutput()
+	output, err := RunPrivileged("loginctl", "enable-linger", username)
 	if err != nil {
 		return fmt.Errorf("failed to enable lingering for %s: %w\nOutput: %s", username, err, string(output))
 	}
@@ -255,24 +315,44 @@ func EnsureUserRuntimeDir(username string) (string, error) {
 		return "", fmt.Errorf("failed to check runtime directory %s: %w", runtimeDir, err)
 	}
 
-	mkdirCmd := exec.Command("sudo", "-n", "mkdir", "-p", runtimeDir)
-	if output, err := mkdirCmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("mkdir", "-p", runtimeDir); err != nil {
 		return "", fmt.Errorf("failed to create runtime directory %s: %w\nOutput: %s", runtimeDir, err, string(output))
 	}
 
-	chownCmd := exec.Command("sudo", "-n", "chown", fmt.Sprintf("%d:%d", uid, uid), runtimeDir)
-	if output, err := chownCmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chown", fmt.Sprintf("%d:%d", uid, uid), runtimeDir); err != nil {
 		return "", fmt.Errorf("failed to chown runtime directory %s: %w\nOutput: %s", runtimeDir, err, string(output))
 	}
 
-	chmodCmd := exec.Command("sudo", "-n", "chmod", "0700", runtimeDir)
-	if output, err := chmodCmd.CombinedOutput(); err != nil {
+	if output, err := RunPrivileged("chmod", "0700", runtimeDir); err != nil {
 		return "", fmt.Errorf("failed to chmod runtime directory %s: %w\nOutput: %s", runtimeDir, err, string(output))
 	}
 
 	return runtimeDir, nil
 }
 
+// currentUserLookup resolves the invoking user; replaced in tests
+var currentUserLookup = user.Current
+
+// CurrentUIDGID returns the numeric UID and primary GID of the invoking user
+func CurrentUIDGID() (uid, gid int, err error) {
+	u, err := currentUserLookup()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	uid, err = strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid UID %q for %s: %w", u.Uid, u.Username, err)
+	}
+
+	gid, err = strconv.Atoi(u.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GID %q for %s: %w", u.Gid, u.Username, err)
+	}
+
+	return uid, gid, nil
+}
+
 // GetCurrentUser returns the current user information
 func GetCurrentUser() (*user.User, error) {
 	u, err := user.Current()
@@ -282,6 +362,29 @@ func GetCurrentUser() (*user.User, error) {
 	return u, nil
 }
 
+// CurrentProcessGroups returns the names of the groups this process runs
+// with. Unlike GetUserGroups, which reads the group database, it reflects
+// the login session, so a group added with usermod only appears here after
+// the user logs in again.
+func CurrentProcessGroups() ([]string, error) {
+	gids, err := os.Getgroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get process groups: %w", err)
+	}
+	gids = append(gids, os.Getgid())
+
+	var groups []string
+	for _, gid := range gids {
+		g, err := user.LookupGroupId(strconv.Itoa(gid))
+		if err != nil {
+			// Skip groups we can't lookup
+			continue
+		}
+		groups = append(groups, g.Name)
+	}
+	return groups, nil
+}
+
 // CheckSubUIDExists checks if a user has subuid mappings
 func CheckSubUIDExists(username string) (bool, error) {
 	cmd := exec.Command("grep", "-q", fmt.Sprintf("^%s:", username), "/etc/subuid")
@@ -1,6 +1,8 @@
 package system
 
 import (
+	"errors"
+	"os/user"
 	"testing"
 )
 
@@ -111,3 +113,54 @@ func TestGetUIDGIDForRoot(t *testing.T) {
 		t.Errorf("GetGID(root) = %d, want 0", gid)
 	}
 }
+
+// TestCurrentUIDGID tests UID/GID parsing with a mocked user lookup
+func TestCurrentUIDGID(t *testing.T) {
+	original := currentUserLookup
+	defer func() { currentUserLookup = original }()
+
+	tests := []struct {
+		name    string
+		user    *user.User
+		err     error
+		wantUID int
+		wantGID int
+		wantErr bool
+	}{
+		{
+			name:    "regular user",
+			user:    &user.User{Username: "core", Uid: "1001", Gid: "1002"},
+			wantUID: 1001,
+			wantGID: 1002,
+		},
+		{
+			name:    "lookup failure",
+			err:     errors.New("no passwd entry"),
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric uid",
+			user:    &user.User{Username: "odd", Uid: "S-1-5-21", Gid: "1000"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currentUserLookup = func() (*user.User, error) {
+				return tt.user, tt.err
+			}
+
+			uid, gid, err := CurrentUIDGID()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CurrentUIDGID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if uid != tt.wantUID || gid != tt.wantGID {
+				t.Errorf("CurrentUIDGID() = %d:%d, want %d:%d", uid, gid, tt.wantUID, tt.wantGID)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WireGuardPeerStatus is the live state of a peer as reported by "wg show"
+type WireGuardPeerStatus struct {
+	PublicKey       string
+	Endpoint        string // Empty if the peer has never connected
+	AllowedIPs      string
+	LatestHandshake time.Time // Zero if no handshake has completed
+	RxBytes         int64
+	TxBytes         int64
+}
+
+// GetWireGuardPeerStatus returns the live peer state for a WireGuard interface
+func GetWireGuardPeerStatus(interfaceName string) ([]WireGuardPeerStatus, error) {
+	cmd := exec.Command("sudo", "-n", "wg", "show", interfaceName, "dump")
+	start := time.Now()
+	output, err := cmd.Output()
+	auditCommand(cmd.Args, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WireGuard status for %s: %w", interfaceName, err)
+	}
+
+	return parseWireGuardDump(string(output))
+}
+
+// parseWireGuardDump parses "wg show <interface> dump" output. The first line
+// describes the interface; each following line is a tab-separated peer:
+// public-key, preshared-key, endpoint, allowed-ips, latest-handshake, rx, tx, keepalive.
+func parseWireGuardDump(output string) ([]WireGuardPeerStatus, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) <= 1 {
+		return nil, nil
+	}
+
+	peers := make([]WireGuardPeerStatus, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("unexpected wg dump line: %q", line)
+		}
+
+		handshake, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid handshake time %q: %w", fields[4], err)
+		}
+		rx, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rx bytes %q: %w", fields[5], err)
+		}
+		tx, err := strconv.ParseInt(fields[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx bytes %q: %w", fields[6], err)
+		}
+
+		peer := WireGuardPeerStatus{
+			PublicKey:  fields[0],
+			AllowedIPs: fields[3],
+			RxBytes:    rx,
+			TxBytes:    tx,
+		}
+		if fields[2] != "(none)" {
+			peer.Endpoint = fields[2]
+		}
+		if fields[3] == "(none)" {
+			peer.AllowedIPs = ""
+		}
+		if handshake > 0 {
+			peer.LatestHandshake = time.Unix(handshake, 0)
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
@@ -0,0 +1,46 @@
+package system
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWireGuardDump(t *testing.T) {
+	output := "cHJpdmF0ZQ==\tc2VydmVy\t51820\toff\n" +
+		"cGVlcjE=\t(none)\t203.0.113.5:41234\t10.253.0.2/32\t1714564800\t1024\t2048\t25\n" +
+		"cGVlcjI=\t(none)\t(none)\t10.253.0.3/32\t0\t0\t0\toff\n"
+
+	peers, err := parseWireGuardDump(output)
+	if err != nil {
+		t.Fatalf("parseWireGuardDump() error = %v", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("got %d peers, want 2", len(peers))
+	}
+
+	first := peers[0]
+	if first.PublicKey != "cGVlcjE=" || first.Endpoint != "203.0.113.5:41234" || first.AllowedIPs != "10.253.0.2/32" {
+		t.Errorf("unexpected first peer: %+v", first)
+	}
+	if !first.LatestHandshake.Equal(time.Unix(1714564800, 0)) || first.RxBytes != 1024 || first.TxBytes != 2048 {
+		t.Errorf("unexpected first peer counters: %+v", first)
+	}
+
+	second := peers[1]
+	if second.Endpoint != "" || !second.LatestHandshake.IsZero() {
+		t.Errorf("expected never-connected peer, got %+v", second)
+	}
+}
+
+func TestParseWireGuardDumpInterfaceOnly(t *testing.T) {
+	peers, err := parseWireGuardDump("cHJpdmF0ZQ==\tc2VydmVy\t51820\toff\n")
+	if err != nil || len(peers) != 0 {
+		t.Errorf("parseWireGuardDump() = %v, %v; want no peers", peers, err)
+	}
+}
+
+func TestParseWireGuardDumpMalformed(t *testing.T) {
+	if _, err := parseWireGuardDump("iface\nbad line\n"); err == nil {
+		t.Error("expected error for malformed peer line")
+	}
+}
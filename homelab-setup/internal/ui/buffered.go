@@ -0,0 +1,36 @@
+package ui
+
+import "bytes"
+
+// Buffered returns a UI with u's settings whose output is held in memory
+// until Flush, so operations running concurrently can print without their
+// lines interleaving. It cannot read input, so prompts take their defaults.
+// Warnings are recorded on u as they are issued.
+func (u *UI) Buffered() *UI {
+	u.secretsMu.RLock()
+	defer u.secretsMu.RUnlock()
+	return &UI{
+		output:         &bytes.Buffer{},
+		nonInteractive: true,
+		colorInfo:      u.colorInfo,
+		colorSuccess:   u.colorSuccess,
+		colorWarning:   u.colorWarning,
+		colorError:     u.colorError,
+		colorBold:      u.colorBold,
+		colorCyan:      u.colorCyan,
+		secrets:        append([]string(nil), u.secrets...),
+		verbosity:      u.verbosity,
+		parent:         u,
+	}
+}
+
+// Flush writes the output held by a UI from Buffered to the UI it came from.
+// It does nothing for other UIs.
+func (u *UI) Flush() {
+	buf, ok := u.output.(*bytes.Buffer)
+	if !ok || u.parent == nil {
+		return
+	}
+	_, _ = u.parent.output.Write(buf.Bytes())
+	buf.Reset()
+}
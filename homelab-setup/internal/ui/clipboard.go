@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clipboardTools are the clipboard commands tried in order. Each needs a
+// display to talk to, which over SSH comes from X11 forwarding.
+var clipboardTools = []struct {
+	name    string
+	args    []string
+	display string // environment variable naming the display the tool needs
+}{
+	{"wl-copy", nil, "WAYLAND_DISPLAY"},
+	{"xclip", []string{"-selection", "clipboard"}, "DISPLAY"},
+	{"xsel", []string{"--clipboard", "--input"}, "DISPLAY"},
+}
+
+// clipboardTimeout bounds a clipboard command; the tools fork to serve the
+// selection, so the command itself returns quickly
+const clipboardTimeout = 5 * time.Second
+
+// Clipboard tool lookup, overridable in tests
+var (
+	lookPath = exec.LookPath
+	getenv   = os.Getenv
+)
+
+var (
+	clipboardOnce    sync.Once
+	clipboardCommand []string // nil when no usable tool was found
+)
+
+// detectClipboard returns the command that copies stdin to the clipboard,
+// detected on first use
+func detectClipboard() []string {
+	clipboardOnce.Do(func() {
+		for _, tool := range clipboardTools {
+			if getenv(tool.display) == "" {
+				continue
+			}
+			path, err := lookPath(tool.name)
+			if err != nil {
+				continue
+			}
+			clipboardCommand = append([]string{path}, tool.args...)
+			return
+		}
+	})
+	return clipboardCommand
+}
+
+// ClipboardAvailable reports whether CopyToClipboard can reach a clipboard
+func (u *UI) ClipboardAvailable() bool {
+	return detectClipboard() != nil
+}
+
+// CopyToClipboard copies text to the clipboard with wl-copy, xclip or xsel.
+// When none of them can be used it prints a notice and does nothing.
+func (u *UI) CopyToClipboard(text string) error {
+	command := detectClipboard()
+	if command == nil {
+		u.Info("No clipboard available (needs wl-copy, xclip or xsel and a graphical or forwarded X11 session)")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	// Output is not captured: the forked tool would hold the pipe open and
+	// keep Run from returning
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to copy to clipboard with %s: %w", command[0], err)
+	}
+	return nil
+}
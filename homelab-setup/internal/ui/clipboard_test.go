@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// withClipboardEnv replaces tool lookup with env and the tools in bin, and
+// forgets any previously detected tool
+func withClipboardEnv(t *testing.T, env map[string]string, bin string) {
+	t.Helper()
+	origLookPath, origGetenv := lookPath, getenv
+	reset := func() {
+		clipboardOnce = sync.Once{}
+		clipboardCommand = nil
+	}
+	reset()
+	getenv = func(key string) string { return env[key] }
+	lookPath = func(name string) (string, error) {
+		path := filepath.Join(bin, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", exec.ErrNotFound
+		}
+		return path, nil
+	}
+	t.Cleanup(func() {
+		lookPath, getenv = origLookPath, origGetenv
+		reset()
+	})
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	bin := t.TempDir()
+	out := filepath.Join(bin, "clipboard")
+	script := "#!/bin/sh\ncat > " + out + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// wl-copy is installed but there is no Wayland session, so xclip is used
+	if err := os.WriteFile(filepath.Join(bin, "wl-copy"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	withClipboardEnv(t, map[string]string{"DISPLAY": "localhost:10.0"}, bin)
+
+	u := NewWithWriter(&bytes.Buffer{})
+	if !u.ClipboardAvailable() {
+		t.Fatal("ClipboardAvailable() = false with xclip and DISPLAY set")
+	}
+	if err := u.CopyToClipboard("[Interface]\n"); err != nil {
+		t.Fatalf("CopyToClipboard() error = %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "[Interface]\n" {
+		t.Errorf("clipboard = %q, want %q", got, "[Interface]\n")
+	}
+}
+
+func TestCopyToClipboardUnavailable(t *testing.T) {
+	withClipboardEnv(t, nil, t.TempDir())
+
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+	if u.ClipboardAvailable() {
+		t.Fatal("ClipboardAvailable() = true without any tool")
+	}
+	if err := u.CopyToClipboard("text"); err != nil {
+		t.Fatalf("CopyToClipboard() error = %v, want no-op", err)
+	}
+	if !strings.Contains(buf.String(), "No clipboard available") {
+		t.Errorf("CopyToClipboard() printed %q, want a notice", buf.String())
+	}
+}
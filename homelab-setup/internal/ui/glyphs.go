@@ -0,0 +1,79 @@
+package ui
+
+import "sync/atomic"
+
+// Status glyphs. Every check mark and cross the tool prints should come from
+// GlyphOK, GlyphFail and GlyphPending rather than a literal, so that plain
+// output stays consistent.
+const (
+	glyphOK      = "✓"
+	glyphFail    = "✗"
+	glyphPending = "-"
+
+	// ASCII forms used for plain output
+	plainGlyphOK      = "[x]"
+	plainGlyphFail    = "[!]"
+	plainGlyphPending = "[ ]"
+)
+
+// asciiGlyphs selects the ASCII glyphs, set by ConfigureTerminal for plain
+// output (NO_COLOR or an incapable terminal)
+var asciiGlyphs atomic.Bool
+
+// SetASCIIGlyphs switches the status glyphs between Unicode and ASCII
+func SetASCIIGlyphs(enabled bool) {
+	asciiGlyphs.Store(enabled)
+}
+
+// GlyphOK returns the mark for a passed check or completed step: "✓", or
+// "[x]" in plain output
+func GlyphOK() string {
+	if asciiGlyphs.Load() {
+		return plainGlyphOK
+	}
+	return glyphOK
+}
+
+// GlyphFail returns the mark for a failed check: "✗", or "[!]" in plain
+// output
+func GlyphFail() string {
+	if asciiGlyphs.Load() {
+		return plainGlyphFail
+	}
+	return glyphFail
+}
+
+// GlyphPending returns the mark for a step that has not run yet: "-", or
+// "[ ]" in plain output
+func GlyphPending() string {
+	if asciiGlyphs.Load() {
+		return plainGlyphPending
+	}
+	return glyphPending
+}
+
+// StatusGlyph returns GlyphOK if ok and GlyphFail otherwise
+func StatusGlyph(ok bool) string {
+	if ok {
+		return GlyphOK()
+	}
+	return GlyphFail()
+}
+
+// successTag prefixes success messages: "[✓]", or "[x]" in plain output
+func successTag() string {
+	if asciiGlyphs.Load() {
+		return plainGlyphOK
+	}
+	return "[" + glyphOK + "]"
+}
+
+// GlyphOK returns the package GlyphOK, for callers whose *UI parameter
+// shadows the package name
+func (u *UI) GlyphOK() string { return GlyphOK() }
+
+// GlyphFail returns the package GlyphFail (see UI.GlyphOK)
+func (u *UI) GlyphFail() string { return GlyphFail() }
+
+// GlyphPending returns the package GlyphPending (see UI.GlyphOK)
+func (u *UI) GlyphPending() string { return GlyphPending() }
@@ -0,0 +1,28 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestASCIIGlyphs(t *testing.T) {
+	t.Cleanup(func() { SetASCIIGlyphs(false) })
+
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+
+	u.Success("saved")
+	SetASCIIGlyphs(true)
+	u.Success("saved")
+	if got, want := buf.String(), "[✓] saved\n[x] saved\n"; got != want {
+		t.Errorf("Success() output = %q, want %q", got, want)
+	}
+
+	if GlyphOK() != "[x]" || GlyphFail() != "[!]" || StatusGlyph(false) != "[!]" || GlyphPending() != "[ ]" {
+		t.Errorf("ASCII glyphs = %q, %q", GlyphOK(), GlyphFail())
+	}
+	SetASCIIGlyphs(false)
+	if StatusGlyph(true) != "✓" || StatusGlyph(false) != "✗" {
+		t.Errorf("Unicode glyphs = %q, %q", StatusGlyph(true), StatusGlyph(false))
+	}
+}
@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// stdinIsTerminal reports whether stdin is a terminal, overridable in tests
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// CanRedraw reports whether a view can be redrawn in place and single
+// keypresses read: stdin and stdout are a capable terminal and u may prompt
+func (u *UI) CanRedraw() bool {
+	return !u.nonInteractive && CapableTerminal() && stdinIsTerminal()
+}
+
+// Redraw clears the screen and prints what draw writes to the UI it is
+// given. The output is written in one go so the view does not flicker, and
+// with CRLF line endings so it renders while ReadKeys holds the terminal in
+// raw mode.
+func (u *UI) Redraw(draw func(*UI)) {
+	frame := u.Buffered()
+	draw(frame)
+	content := frame.output.(*bytes.Buffer).String()
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\n", "\r\n")
+	// \033[H moves the cursor home, \033[2J clears the screen
+	fmt.Fprint(u.output, "\033[H\033[2J"+content)
+}
+
+// keyPollTimeout bounds how long ReadKeys waits for input before checking
+// whether it was stopped, in milliseconds
+const keyPollTimeout = 100
+
+// ReadKeys switches the terminal on stdin to raw mode and sends each byte
+// typed to keys. Ctrl-C arrives as byte 3 instead of raising SIGINT. restore
+// puts the terminal back and stops reading; stdin is only read when input is
+// waiting, so nothing typed afterwards is lost.
+func ReadKeys() (keys <-chan byte, restore func(), err error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to switch the terminal to raw mode: %w", err)
+	}
+
+	ch := make(chan byte)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		buf := make([]byte, 1)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+			n, err := unix.Poll(fds, keyPollTimeout)
+			if err == unix.EINTR || (err == nil && n == 0) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				return
+			}
+			select {
+			case ch <- buf[0]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	restore = func() {
+		close(done)
+		<-stopped
+		_ = term.Restore(fd, state)
+	}
+	return ch, restore, nil
+}
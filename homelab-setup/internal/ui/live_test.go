@@ -0,0 +1,21 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRedraw(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+
+	u.Redraw(func(frame *UI) {
+		frame.Print("first")
+		frame.Print("second")
+	})
+
+	want := "\033[H\033[2Jfirst\r\nsecond\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Redraw() wrote %q, want %q", got, want)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
 )
 
 // UI provides user interface methods
@@ -20,8 +23,34 @@ type UI struct {
 	colorError   *color.Color
 	colorBold    *color.Color
 	colorCyan    *color.Color
+	// secrets holds sensitive values that are masked in output
+	secretsMu sync.RWMutex
+	secrets   []string
+	// verbosity controls which messages are printed
+	verbosity Verbosity
+	// warnings records every warning issued, for end-of-run summaries
+	warningsMu sync.Mutex
+	warnings   []string
+	// parent is the UI a buffered UI flushes to (see Buffered)
+	parent *UI
 }
 
+// Verbosity selects how much output the UI prints
+type Verbosity int
+
+const (
+	// VerbosityQuiet prints only errors and final results
+	VerbosityQuiet Verbosity = iota
+	// VerbosityNormal prints regular progress output (default)
+	VerbosityNormal
+	// VerbosityVerbose additionally prints debug output such as command invocations and timings
+	VerbosityVerbose
+)
+
+// minSecretLength is the shortest value that will be redacted; masking very
+// short values would garble unrelated output
+const minSecretLength = 4
+
 // New creates a new UI instance
 func New() *UI {
 	return &UI{
@@ -33,9 +62,25 @@ func New() *UI {
 		colorError:     color.New(color.FgRed),
 		colorBold:      color.New(color.Bold),
 		colorCyan:      color.New(color.FgCyan, color.Bold),
+		verbosity:      VerbosityNormal,
 	}
 }
 
+// SetVerbosity sets the output level
+func (u *UI) SetVerbosity(v Verbosity) {
+	u.verbosity = v
+}
+
+// Verbosity returns the current output level
+func (u *UI) Verbosity() Verbosity {
+	return u.verbosity
+}
+
+// quiet reports whether progress output is suppressed
+func (u *UI) quiet() bool {
+	return u.verbosity <= VerbosityQuiet
+}
+
 // SetNonInteractive enables or disables non-interactive mode
 func (u *UI) SetNonInteractive(enabled bool) {
 	u.nonInteractive = enabled
@@ -53,9 +98,45 @@ func NewWithWriter(w io.Writer) *UI {
 	return ui
 }
 
+// AddSecret registers a sensitive value that must never be printed verbatim.
+// Every message, including Print and Printf, shows it as "***".
+func (u *UI) AddSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	u.secretsMu.Lock()
+	defer u.secretsMu.Unlock()
+	for _, s := range u.secrets {
+		if s == value {
+			return
+		}
+	}
+	u.secrets = append(u.secrets, value)
+}
+
+// AddSecrets registers multiple sensitive values
+func (u *UI) AddSecrets(values []string) {
+	for _, v := range values {
+		u.AddSecret(v)
+	}
+}
+
+// Redact masks any registered secret values in msg
+func (u *UI) Redact(msg string) string {
+	u.secretsMu.RLock()
+	defer u.secretsMu.RUnlock()
+	for _, s := range u.secrets {
+		msg = strings.ReplaceAll(msg, s, common.RedactedValue)
+	}
+	return msg
+}
+
 // Info prints an info message
 func (u *UI) Info(msg string) {
-	u.colorInfo.Fprintf(u.output, "[INFO] %s\n", msg)
+	if u.quiet() {
+		return
+	}
+	u.colorInfo.Fprintf(u.output, "[INFO] %s\n", u.Redact(msg))
 }
 
 // Infof prints a formatted info message
@@ -65,7 +146,10 @@ func (u *UI) Infof(format string, args ...interface{}) {
 
 // Success prints a success message
 func (u *UI) Success(msg string) {
-	u.colorSuccess.Fprintf(u.output, "[✓] %s\n", msg)
+	if u.quiet() {
+		return
+	}
+	u.colorSuccess.Fprintf(u.output, "%s %s\n", successTag(), u.Redact(msg))
 }
 
 // Successf prints a formatted success message
@@ -73,11 +157,31 @@ func (u *UI) Successf(format string, args ...interface{}) {
 	u.Success(fmt.Sprintf(format, args...))
 }
 
-// Warning prints a warning message
+// Warning prints a warning message and records it for Warnings. Warnings
+// are recorded even when quiet.
 func (u *UI) Warning(msg string) {
+	msg = u.Redact(msg)
+	root := u
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.warningsMu.Lock()
+	root.warnings = append(root.warnings, msg)
+	root.warningsMu.Unlock()
+
+	if u.quiet() {
+		return
+	}
 	u.colorWarning.Fprintf(u.output, "[WARNING] %s\n", msg)
 }
 
+// Warnings returns the warnings issued so far, oldest first
+func (u *UI) Warnings() []string {
+	u.warningsMu.Lock()
+	defer u.warningsMu.Unlock()
+	return append([]string(nil), u.warnings...)
+}
+
 // Warningf prints a formatted warning message
 func (u *UI) Warningf(format string, args ...interface{}) {
 	u.Warning(fmt.Sprintf(format, args...))
@@ -85,7 +189,7 @@ func (u *UI) Warningf(format string, args ...interface{}) {
 
 // Error prints an error message
 func (u *UI) Error(msg string) {
-	u.colorError.Fprintf(u.output, "[ERROR] %s\n", msg)
+	u.colorError.Fprintf(u.output, "[ERROR] %s\n", u.Redact(msg))
 }
 
 // Errorf prints a formatted error message
@@ -93,8 +197,25 @@ func (u *UI) Errorf(format string, args ...interface{}) {
 	u.Error(fmt.Sprintf(format, args...))
 }
 
+// Result prints a final outcome message. Unlike Success it is shown at every
+// verbosity level, so scripts running with --quiet still see the result.
+func (u *UI) Result(msg string) {
+	u.colorSuccess.Fprintf(u.output, "%s %s\n", successTag(), u.Redact(msg))
+}
+
+// Debugf prints a debug message, only at VerbosityVerbose
+func (u *UI) Debugf(format string, args ...interface{}) {
+	if u.verbosity < VerbosityVerbose {
+		return
+	}
+	fmt.Fprintf(u.output, "[DEBUG] %s\n", u.Redact(fmt.Sprintf(format, args...)))
+}
+
 // Step prints a step header
 func (u *UI) Step(msg string) {
+	if u.quiet() {
+		return
+	}
 	fmt.Fprintln(u.output)
 	u.colorCyan.Fprintf(u.output, "==> %s\n", msg)
 	fmt.Fprintln(u.output)
@@ -102,6 +223,9 @@ func (u *UI) Step(msg string) {
 
 // Header prints a header with a box
 func (u *UI) Header(title string) {
+	if u.quiet() {
+		return
+	}
 	width := 70
 	border := strings.Repeat("=", width)
 
@@ -114,21 +238,37 @@ func (u *UI) Header(title string) {
 
 // Separator prints a separator line
 func (u *UI) Separator() {
+	if u.quiet() {
+		return
+	}
 	u.colorCyan.Fprintln(u.output, strings.Repeat("-", 70))
 }
 
 // Print prints a plain message without formatting
 func (u *UI) Print(msg string) {
-	fmt.Fprintln(u.output, msg)
+	if u.quiet() {
+		return
+	}
+	fmt.Fprintln(u.output, u.Redact(msg))
 }
 
 // Printf prints a formatted plain message
 func (u *UI) Printf(format string, args ...interface{}) {
+	u.Print(fmt.Sprintf(format, args...))
+}
+
+// promptf prints part of a prompt, such as the options of a selection. It is
+// not subject to the verbosity level: a prompt must stay answerable with
+// --quiet.
+func (u *UI) promptf(format string, args ...interface{}) {
 	fmt.Fprintf(u.output, format+"\n", args...)
 }
 
 // Bold prints bold text
 func (u *UI) Bold(msg string) {
+	if u.quiet() {
+		return
+	}
 	u.colorBold.Fprintln(u.output, msg)
 }
 
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zoro11031/homelab-coreos-minipc/homelab-setup/internal/common"
+)
+
+func TestRedactSecretsInOutput(t *testing.T) {
+	secret := "claim-AbCdEf123456"
+
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+	u.AddSecret(secret)
+
+	u.Info("token is " + secret)
+	u.Infof("token is %s", secret)
+	u.Success("saved " + secret)
+	u.Warning("warn " + secret)
+	u.Errorf("failed to use %s", secret)
+	u.Print("plain " + secret)
+	u.Printf("plain %s", secret)
+
+	out := buf.String()
+	if strings.Contains(out, secret) {
+		t.Fatalf("output contains secret verbatim:\n%s", out)
+	}
+	if got := strings.Count(out, common.RedactedValue); got != 7 {
+		t.Errorf("expected 7 redacted occurrences, got %d:\n%s", got, out)
+	}
+}
+
+func TestAddSecretConcurrentWithOutput(t *testing.T) {
+	u := NewWithWriter(&bytes.Buffer{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			u.AddSecret(fmt.Sprintf("secret-%d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		u.Buffered().Info(u.Redact("secret-1"))
+	}
+	<-done
+	if got := u.Redact("token secret-99"); strings.Contains(got, "secret") {
+		t.Errorf("Redact() = %q, want every registered secret masked", got)
+	}
+}
+
+func TestAddSecretIgnoresShortValues(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+	u.AddSecret("")
+	u.AddSecret("ab")
+
+	u.Info("about")
+	if !strings.Contains(buf.String(), "about") {
+		t.Errorf("short values should not be redacted, got %q", buf.String())
+	}
+}
+
+func TestQuietSuppressesInfoButNotError(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+	u.SetVerbosity(VerbosityQuiet)
+
+	u.Info("progress message")
+	u.Step("step header")
+	u.Success("chatter")
+	u.Debugf("debug %d", 1)
+	u.Error("something failed")
+	u.Result("final result")
+
+	out := buf.String()
+	for _, hidden := range []string{"progress message", "step header", "chatter", "debug 1"} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("quiet output should not contain %q:\n%s", hidden, out)
+		}
+	}
+	for _, shown := range []string{"something failed", "final result"} {
+		if !strings.Contains(out, shown) {
+			t.Errorf("quiet output should contain %q:\n%s", shown, out)
+		}
+	}
+}
+
+func TestDebugfOnlyAtVerbose(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+
+	u.Debugf("hidden")
+	if strings.Contains(buf.String(), "hidden") {
+		t.Errorf("Debugf should not print at normal verbosity: %q", buf.String())
+	}
+
+	u.SetVerbosity(VerbosityVerbose)
+	u.Debugf("shown %s", "now")
+	if !strings.Contains(buf.String(), "[DEBUG] shown now") {
+		t.Errorf("Debugf should print at verbose level: %q", buf.String())
+	}
+}
+
+func TestWarningsRecorded(t *testing.T) {
+	u := NewWithWriter(&bytes.Buffer{})
+	u.AddSecret("hunter22")
+	u.SetVerbosity(VerbosityQuiet)
+
+	u.Warning("disk is low")
+	u.Warningf("password %s rejected", "hunter22")
+	u.Info("not a warning")
+
+	got := u.Warnings()
+	want := []string{"disk is low", "password *** rejected"}
+	if len(got) != len(want) {
+		t.Fatalf("Warnings() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Warnings()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -132,9 +132,9 @@ func (u *UI) PromptSelect(prompt string, options []string) (int, error) {
 		return 0, nil
 	}
 
-	u.Print(prompt)
+	u.promptf("%s", prompt)
 	for i, opt := range options {
-		u.Printf("  %d) %s\n", i+1, opt)
+		u.promptf("  %d) %s", i+1, opt)
 	}
 
 	for {
@@ -165,9 +165,9 @@ func (u *UI) PromptMultiSelect(prompt string, options []string) ([]int, error) {
 		return indices, nil
 	}
 
-	u.Print(prompt)
+	u.promptf("%s", prompt)
 	for i, opt := range options {
-		u.Printf("  %d) %s\n", i+1, opt)
+		u.promptf("  %d) %s", i+1, opt)
 	}
 	line, err := u.promptLine("Enter comma-separated numbers (leave blank for none, * for all)")
 	if err != nil {
@@ -239,3 +239,13 @@ func (u *UI) PromptInputWithValidation(prompt, defaultValue string, validator In
 		return value, nil
 	}
 }
+
+// PromptContinue shows prompt and waits for Enter. In non-interactive mode it
+// returns at once.
+func (u *UI) PromptContinue(prompt string) error {
+	if u.nonInteractive {
+		return nil
+	}
+	_, err := u.promptLine(prompt)
+	return err
+}
@@ -104,9 +104,9 @@ func (u *UI) PromptSelect(prompt string, options []string) (int, error) {
 		return 0, nil
 	}
 
-	u.Printf("%s\n", prompt)
+	u.promptf("%s", prompt)
 	for i, opt := range options {
-		u.Printf("  %d) %s\n", i+1, opt)
+		u.promptf("  %d) %s", i+1, opt)
 	}
 
 	for {
@@ -137,9 +137,9 @@ func (u *UI) PromptMultiSelect(prompt string, options []string) ([]int, error) {
 		return indices, nil
 	}
 
-	u.Printf("%s\n", prompt)
+	u.promptf("%s", prompt)
 	for i, opt := range options {
-		u.Printf("  %d) %s\n", i+1, opt)
+		u.promptf("  %d) %s", i+1, opt)
 	}
 
 	line, err := readLine("Enter comma-separated numbers (leave blank for none)")
@@ -182,3 +182,11 @@ func (u *UI) PromptInputWithValidation(prompt, defaultValue string, _ interface{
 	// Validation is skipped in lint builds; rely on subsequent checks.
 	return u.PromptInput(prompt, defaultValue)
 }
+
+func (u *UI) PromptContinue(prompt string) error {
+	if u.nonInteractive {
+		return nil
+	}
+	_, err := readLine(prompt)
+	return err
+}
@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// useStdin replaces os.Stdin with a pipe holding input
+func useStdin(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	saved := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = saved
+		r.Close()
+	})
+}
+
+func TestSelectPromptsShowOptionsWhenQuiet(t *testing.T) {
+	options := []string{"media", "web", "cloud"}
+
+	t.Run("select", func(t *testing.T) {
+		useStdin(t, "2\n")
+		var buf bytes.Buffer
+		u := NewWithWriter(&buf)
+		u.SetVerbosity(VerbosityQuiet)
+
+		index, err := u.PromptSelect("Pick a stack", options)
+		if err != nil || index != 1 {
+			t.Fatalf("PromptSelect() = %d, %v, want 1", index, err)
+		}
+		want := "Pick a stack\n  1) media\n  2) web\n  3) cloud\n"
+		if buf.String() != want {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	})
+
+	t.Run("multi-select", func(t *testing.T) {
+		useStdin(t, "1,3\n")
+		var buf bytes.Buffer
+		u := NewWithWriter(&buf)
+		u.SetVerbosity(VerbosityQuiet)
+
+		indices, err := u.PromptMultiSelect("Pick stacks", options)
+		if err != nil || len(indices) != 2 {
+			t.Fatalf("PromptMultiSelect() = %v, %v, want [0 2]", indices, err)
+		}
+		for _, option := range options {
+			if !strings.Contains(buf.String(), option) {
+				t.Errorf("output missing option %q: %q", option, buf.String())
+			}
+		}
+	})
+}
+
+func TestPromptContinue(t *testing.T) {
+	t.Run("interactive waits for a line", func(t *testing.T) {
+		useStdin(t, "\n")
+		u := NewWithWriter(&bytes.Buffer{})
+		if err := u.PromptContinue("Press Enter to continue..."); err != nil {
+			t.Errorf("PromptContinue() error = %v", err)
+		}
+	})
+
+	t.Run("non-interactive does not read stdin", func(t *testing.T) {
+		// Reading this closed, empty stdin would fail with EOF
+		useStdin(t, "")
+		u := NewWithWriter(&bytes.Buffer{})
+		u.SetNonInteractive(true)
+		if err := u.PromptContinue("Press Enter to continue..."); err != nil {
+			t.Errorf("PromptContinue() error = %v", err)
+		}
+	})
+}
@@ -12,6 +12,7 @@ type Spinner struct {
 	frames   []string
 	interval time.Duration
 	active   bool
+	plain    bool // no animation or escape codes (see CapableTerminal)
 	mu       sync.Mutex
 	done     chan bool
 }
@@ -34,8 +35,14 @@ func (s *Spinner) Start() {
 		return
 	}
 	s.active = true
+	s.plain = !CapableTerminal()
 	s.mu.Unlock()
 
+	if s.plain {
+		fmt.Printf("%s...\n", s.message)
+		return
+	}
+
 	go func() {
 		i := 0
 		for {
@@ -63,19 +70,29 @@ func (s *Spinner) Stop() {
 	}
 
 	s.active = false
-	s.done <- true
+	if !s.plain {
+		s.done <- true
+	}
+}
+
+// finish stops the spinner and prints a final line in place of it
+func (s *Spinner) finish(symbol, message string) {
+	s.Stop()
+	if s.plain {
+		fmt.Printf("%s %s\n", symbol, message)
+		return
+	}
+	fmt.Printf("\r\033[K%s %s\n", symbol, message)
 }
 
 // Success stops the spinner and shows success message
 func (s *Spinner) Success(message string) {
-	s.Stop()
-	fmt.Printf("\r\033[K✓ %s\n", message)
+	s.finish(GlyphOK(), message)
 }
 
 // Fail stops the spinner and shows error message
 func (s *Spinner) Fail(message string) {
-	s.Stop()
-	fmt.Printf("\r\033[K✗ %s\n", message)
+	s.finish(GlyphFail(), message)
 }
 
 // UpdateMessage changes the spinner message while it's running
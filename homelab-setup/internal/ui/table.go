@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table prints rows as left-aligned columns under a bold header row. Rows
+// shorter than headers are padded with empty cells.
+func (u *UI) Table(headers []string, rows [][]string) {
+	if u.quiet() {
+		return
+	}
+
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			if n := utf8.RuneCountInString(row[i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	u.colorBold.Fprintln(u.output, formatTableRow(headers, widths))
+	separators := make([]string, len(widths))
+	for i, w := range widths {
+		separators[i] = strings.Repeat("-", w)
+	}
+	fmt.Fprintln(u.output, formatTableRow(separators, widths))
+	for _, row := range rows {
+		fmt.Fprintln(u.output, u.Redact(formatTableRow(row, widths)))
+	}
+}
+
+// formatTableRow pads each cell to its column width
+func formatTableRow(cells []string, widths []int) string {
+	padded := make([]string, len(widths))
+	for i, w := range widths {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		padded[i] = cell + strings.Repeat(" ", w-utf8.RuneCountInString(cell))
+	}
+	return strings.TrimRight("  "+strings.Join(padded, "  "), " ")
+}
@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTableAlignsColumns(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+
+	u.Table([]string{"Target", "ICMP"}, [][]string{
+		{"Gateway", "✓"},
+		{"NFS", "✗", "extra"},
+		{"DNS"},
+	})
+
+	want := []string{
+		"  Target   ICMP",
+		"  -------  ----",
+		"  Gateway  ✓",
+		"  NFS      ✗",
+		"  DNS",
+	}
+	got := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(got), len(want), buf.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTableQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	u := NewWithWriter(&buf)
+	u.SetVerbosity(VerbosityQuiet)
+
+	u.Table([]string{"A"}, [][]string{{"1"}})
+	if buf.Len() != 0 {
+		t.Errorf("expected no output in quiet mode, got %q", buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// TerminalEnv overrides terminal detection: "1" forces colors and screen
+// clearing on, "0" forces them off (plain output)
+const TerminalEnv = "HOMELAB_SETUP_ANSI"
+
+// isTerminal reports whether stdout is a terminal, overridable in tests
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+var (
+	terminalOnce    sync.Once
+	terminalCapable bool
+)
+
+// detectTerminal reports whether stdout is a terminal that understands ANSI
+// escape sequences. TERM is unset or "dumb" in log captures, editors and some
+// SSH sessions, where escapes would print literally.
+func detectTerminal() bool {
+	switch strings.ToLower(strings.TrimSpace(getenv(TerminalEnv))) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	if !isTerminal() {
+		return false
+	}
+	switch getenv("TERM") {
+	case "", "dumb", "unknown":
+		return false
+	}
+	return true
+}
+
+// CapableTerminal reports whether colors and screen clearing can be used,
+// detected on first use (see TerminalEnv)
+func CapableTerminal() bool {
+	terminalOnce.Do(func() {
+		terminalCapable = detectTerminal()
+	})
+	return terminalCapable
+}
+
+// ConfigureTerminal disables colored output and switches to ASCII status
+// glyphs when stdout is not a capable terminal. NO_COLOR does the same on a
+// capable one. Call it once at startup, before anything is printed.
+func ConfigureTerminal() {
+	plain := !CapableTerminal() || getenv("NO_COLOR") != ""
+	color.NoColor = plain
+	SetASCIIGlyphs(plain)
+}
@@ -0,0 +1,32 @@
+package ui
+
+import "testing"
+
+func TestDetectTerminal(t *testing.T) {
+	origIsTerminal, origGetenv := isTerminal, getenv
+	t.Cleanup(func() { isTerminal, getenv = origIsTerminal, origGetenv })
+
+	tests := []struct {
+		name     string
+		terminal bool
+		env      map[string]string
+		want     bool
+	}{
+		{"terminal", true, map[string]string{"TERM": "xterm-256color"}, true},
+		{"redirected", false, map[string]string{"TERM": "xterm-256color"}, false},
+		{"dumb terminal", true, map[string]string{"TERM": "dumb"}, false},
+		{"TERM unset", true, nil, false},
+		{"forced on", false, map[string]string{TerminalEnv: "1"}, true},
+		{"forced off", true, map[string]string{"TERM": "xterm", TerminalEnv: "0"}, false},
+		{"invalid override ignored", true, map[string]string{"TERM": "screen", TerminalEnv: "maybe"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isTerminal = func() bool { return tt.terminal }
+			getenv = func(key string) string { return tt.env[key] }
+			if got := detectTerminal(); got != tt.want {
+				t.Errorf("detectTerminal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,25 +1,69 @@
 package version
 
-import "fmt"
+import (
+	"fmt"
+	"runtime/debug"
+)
 
+// Build information, injected at build time with -ldflags "-X ...". Empty
+// values fall back to the defaults below.
 var (
-	// Version is the current version of the application
-	Version = "0.1.0-dev"
+	// version is the release version of the application
+	version = ""
 
-	// GitCommit is the git commit hash (set during build)
-	GitCommit = "unknown"
+	// gitCommit is the git commit hash
+	gitCommit = ""
 
-	// BuildDate is the build date (set during build)
-	BuildDate = "unknown"
+	// buildDate is the UTC build date
+	buildDate = ""
 )
 
+// readBuildInfo returns the build information embedded by the Go toolchain,
+// overridable in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+// Version returns the injected version, or "dev" for builds without one
+func Version() string {
+	if version == "" {
+		return "dev"
+	}
+	return version
+}
+
+// Commit returns the injected git commit. Builds without one fall back to
+// the VCS revision recorded by the Go toolchain, then to "unknown".
+func Commit() string {
+	if gitCommit != "" {
+		return gitCommit
+	}
+	if info, ok := readBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				if len(setting.Value) > 7 {
+					return setting.Value[:7]
+				}
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// BuildDate returns the injected build date, or "unknown"
+func BuildDate() string {
+	if buildDate == "" {
+		return "unknown"
+	}
+	return buildDate
+}
+
 // Info returns formatted version information
 func Info() string {
 	return fmt.Sprintf("homelab-setup version %s (commit: %s, built: %s)",
-		Version, GitCommit, BuildDate)
+		Version(), Commit(), BuildDate())
 }
 
 // Short returns just the version number
 func Short() string {
-	return Version
+	return Version()
 }
@@ -0,0 +1,46 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	orig := version
+	t.Cleanup(func() { version = orig })
+
+	version = ""
+	if got := Version(); got != "dev" {
+		t.Errorf("Version() unset = %q, want %q", got, "dev")
+	}
+
+	version = "1.4.0"
+	if got := Version(); got != "1.4.0" {
+		t.Errorf("Version() = %q, want injected %q", got, "1.4.0")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	origCommit, origRead := gitCommit, readBuildInfo
+	t.Cleanup(func() { gitCommit, readBuildInfo = origCommit, origRead })
+
+	gitCommit = "abc1234"
+	if got := Commit(); got != "abc1234" {
+		t.Errorf("Commit() = %q, want injected %q", got, "abc1234")
+	}
+
+	gitCommit = ""
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "686a3df0c1e2d3b4"},
+		}}, true
+	}
+	if got := Commit(); got != "686a3df" {
+		t.Errorf("Commit() from build info = %q, want %q", got, "686a3df")
+	}
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	if got := Commit(); got != "unknown" {
+		t.Errorf("Commit() without build info = %q, want %q", got, "unknown")
+	}
+}